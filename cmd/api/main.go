@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -22,6 +23,8 @@ import (
 	"simnikah/internal/seeders"
 	"simnikah/internal/services"
 	"simnikah/pkg/crypto"
+	"simnikah/pkg/locale"
+	"simnikah/pkg/mailer"
 	"simnikah/pkg/utils"
 
 	"github.com/gin-contrib/cors"
@@ -39,17 +42,36 @@ type TokenClaims struct {
 	Email  string `json:"email"`
 	Role   string `json:"role"`
 	Nama   string `json:"nama"`
+	// SessionID menghubungkan access token dengan sesi login (refresh token) di tabel sesi_penggunas
+	SessionID string `json:"sid"`
+	// KuaID adalah KUA tempat user bertugas / KUA pilihan catin (0 = belum memilih)
+	KuaID uint `json:"kua_id"`
 	jwt.RegisteredClaims
 }
 
 var DB *gorm.DB
 
+// Mailer untuk email keluar (SMTP, atau in-memory jika SMTP_HOST tidak diset di mode pengembangan)
+var Mailer mailer.Mailer
+
 func main() {
 	var err error
 	// Validate JWT key is adequate
 	if len(jwtKey) < 32 {
 		log.Println("Warning: JWT key is less than 32 bytes. Consider using a stronger key.")
 	}
+	// Di luar mode pengembangan server tidak dijalankan tanpa kunci rahasia (JWT, undangan, feed kalender, 2FA)
+	if err := services.CheckSecretKeys(); err != nil {
+		log.Fatal("Konfigurasi tidak lengkap: ", err, ". Set variabel tersebut atau JWT_KEY")
+	}
+	// QR code undangan bimbingan harus menunjuk ke URL publik, bukan localhost
+	if err := services.CheckUndanganVerifyBaseURL(); err != nil {
+		log.Fatal("Konfigurasi undangan bimbingan tidak valid: ", err)
+	}
+	Mailer, err = mailer.NewFromEnv(config.IsDevEnvironment())
+	if err != nil {
+		log.Fatal("Konfigurasi email tidak lengkap: ", err, ". Set SMTP_* environment variable")
+	}
 
 	// Initialize database connection
 	DB, err = config.ConnectDB()
@@ -59,9 +81,31 @@ func main() {
 
 	// Migrate struct
 	log.Println("Starting database migration...")
-	if err := DB.AutoMigrate(&structs.Users{}, &structs.StaffKUA{}, &structs.Penghulu{}, &structs.DataOrangTua{}, &structs.CalonPasangan{}, &structs.PendaftaranNikah{}, &structs.WaliNikah{}, &structs.BimbinganPerkawinan{}, &structs.PendaftaranBimbingan{}, &structs.Notifikasi{}); err != nil {
+	// Slot balai KUA dulu berjarak jeda_minimal_menit; KUA lama mempertahankan slotnya saat kolom baru dibuat
+	copyIntervalSlot := DB.Migrator().HasTable(&structs.KUA{}) && !DB.Migrator().HasColumn(&structs.KUA{}, "interval_slot_menit")
+	if err := DB.AutoMigrate(&structs.Users{}, &structs.StaffKUA{}, &structs.Penghulu{}, &structs.DataOrangTua{}, &structs.CalonPasangan{}, &structs.PendaftaranNikah{}, &structs.WaliNikah{}, &structs.BimbinganPerkawinan{}, &structs.PendaftaranBimbingan{}, &structs.Notifikasi{}, &structs.RiwayatStatus{}, &structs.UndanganStaff{}, &structs.SesiPengguna{}, &structs.TokenDicabut{}, &structs.KodeResetPassword{}, &structs.LoginAudit{}, &structs.MfaPengguna{}, &structs.KodePemulihanMfa{}, &structs.PengaturanSistem{}, &structs.IzinRole{}, &structs.KUA{}, &structs.SlotNikah{}, &structs.HariLibur{}, &structs.KetidaksediaanPenghulu{}, &structs.PerubahanJadwal{}, &structs.DaftarTunggu{}, &structs.KalenderFeed{}, &structs.DokumenPendaftaran{}, &structs.KelengkapanBerkas{}); err != nil {
 		log.Fatal("Database migration failed:", err)
 	}
+
+	if copyIntervalSlot {
+		if err := DB.Model(&structs.KUA{}).Where("1 = 1").Update("interval_slot_menit", gorm.Expr("CASE WHEN jeda_minimal_menit >= 5 THEN jeda_minimal_menit ELSE 60 END")).Error; err != nil {
+			log.Fatal("Database migration failed:", err)
+		}
+	}
+
+	// Index unik lama izin_roles (role, izin) diganti index per KUA (kua_id, role, izin)
+	if DB.Migrator().HasIndex(&structs.IzinRole{}, "idx_izin_role_role_izin") {
+		if err := DB.Migrator().DropIndex(&structs.IzinRole{}, "idx_izin_role_role_izin"); err != nil {
+			log.Fatal("Database migration failed:", err)
+		}
+	}
+
+	// Secret TOTP lama yang masih plaintext dienkripsi
+	if n, err := services.NewMfaService(DB).EncryptStoredSecrets(); err != nil {
+		log.Fatal("Database migration failed:", err)
+	} else if n > 0 {
+		log.Printf("Encrypted %d stored 2FA secrets", n)
+	}
 	log.Println("Database migration completed successfully")
 
 	// Add database indexes for performance optimization
@@ -86,6 +130,26 @@ func main() {
 		// Don't fatal, just warn - seeder is optional
 	}
 
+	// Super admin (pembuat KUA baru) hanya dibuat jika SEEDER_SUPER_ADMIN_PASSWORD diset
+	if err := seeders.SeedSuperAdmin(DB, os.Getenv("SEEDER_SUPER_ADMIN_USERNAME"), os.Getenv("SEEDER_SUPER_ADMIN_EMAIL"), os.Getenv("SEEDER_SUPER_ADMIN_PASSWORD")); err != nil {
+		log.Printf("Warning: Failed to seed super admin: %v", err)
+	}
+
+	// KUA default untuk instalasi lama (sebelum multi-KUA) dan data yang belum punya KUA
+	if err := seeders.SeedDefaultKUA(DB); err != nil {
+		log.Printf("Warning: Failed to seed default KUA: %v", err)
+	}
+
+	// Izin default per role; tanpa ini semua route yang memakai RequirePermission akan ditolak
+	if err := seeders.SeedRolePermissions(DB); err != nil {
+		log.Printf("Warning: Failed to seed role permissions: %v", err)
+	}
+
+	// Hari libur nasional dan cuti bersama dari file tahunan (HARI_LIBUR_DIR, default migrations/hari_libur)
+	if err := seeders.SeedHolidays(DB, os.Getenv("HARI_LIBUR_DIR")); err != nil {
+		log.Printf("Warning: Failed to seed holidays: %v", err)
+	}
+
 	// Set Gin to release mode in production
 	ginMode := os.Getenv("GIN_MODE")
 	if ginMode == "release" {
@@ -136,8 +200,35 @@ func main() {
 	// Routes with strict rate limiting for auth endpoints
 	r.POST("/register", middleware.StrictRateLimiter(), RegisterUser)
 	r.POST("/login", middleware.StrictRateLimiter(), Login)
+	r.POST("/login/mfa", middleware.StrictRateLimiter(), LoginMfa)
+	r.POST("/login/mfa/setup", middleware.StrictRateLimiter(), LoginMfaSetup)
+	r.POST("/login/mfa/setup/confirm", middleware.StrictRateLimiter(), LoginMfaSetupConfirm)
+	r.POST("/refresh", middleware.StrictRateLimiter(), RefreshToken)
+	r.POST("/logout", AuthMiddleware(), Logout)
+	r.POST("/logout-all", AuthMiddleware(), LogoutAll)
+	r.POST("/change-password", middleware.StrictRateLimiter(), AuthMiddleware(), ChangePassword)
+	r.POST("/forgot-password", middleware.StrictRateLimiter(), ForgotPassword)
+	r.POST("/reset-password", middleware.StrictRateLimiter(), ResetPassword)
+
+	// Two-factor authentication (TOTP) untuk staff, penghulu, dan kepala KUA
+	mfaRoutes := r.Group("/mfa", AuthMiddleware(), RequirePermission(structs.IzinAkunMfa))
+	{
+		mfaRoutes.GET("/status", GetMfaStatus)
+		mfaRoutes.POST("/enroll", EnrollMfa)
+		mfaRoutes.POST("/enroll/confirm", middleware.StrictRateLimiter(), ConfirmMfaEnrollment)
+		mfaRoutes.POST("/disable", middleware.StrictRateLimiter(), DisableMfa)
+		mfaRoutes.POST("/recovery-codes", middleware.StrictRateLimiter(), RegenerateMfaRecoveryCodes)
+	}
+
+	// Aktivasi akun staff/penghulu lewat link undangan (publik)
+	r.GET("/undangan-staff/verify", staffHandler.VerifyStaffInvitation)
+	r.POST("/undangan-staff/redeem", middleware.StrictRateLimiter(), staffHandler.RedeemStaffInvitation)
 	r.GET("/profile", AuthMiddleware(), Profile)
 
+	// Daftar KUA aktif (publik) untuk memilih KUA saat mendaftar
+	r.GET("/kua", staffHandler.GetAllKUA)
+	r.GET("/kua/:id", staffHandler.GetKUAByID)
+
 	// SimNikah Routes
 	simnikahRoutes := r.Group("/simnikah")
 	{
@@ -152,7 +243,7 @@ func main() {
 		simnikahRoutes.GET("/pendaftaran/status", AuthMiddleware(), catinHandler.CheckUserRegistrationStatus)
 		simnikahRoutes.POST("/pendaftaran/form-baru", AuthMiddleware(), catinHandler.CreateMarriageRegistrationForm)
 		simnikahRoutes.POST("/pendaftaran/:id/mark-visited", AuthMiddleware(), catinHandler.MarkAsVisited)
-		simnikahRoutes.GET("/pendaftaran", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), catinHandler.GetAllMarriageRegistrations)
+		simnikahRoutes.GET("/pendaftaran", AuthMiddleware(), RequirePermission(structs.IzinPendaftaranViewAll), catinHandler.GetAllMarriageRegistrations)
 		// simnikahRoutes.GET("/pendaftaran/:id", AuthMiddleware(), catinHandler.GetPendaftaranNikah)
 		// simnikahRoutes.GET("/pendaftaran/:id/lengkap", AuthMiddleware(), catinHandler.GetPendaftaranLengkap)
 		// simnikahRoutes.PUT("/pendaftaran/:id/status", MultiRoleMiddleware("staff", "kepala_kua"), catinHandler.UpdateStatusPendaftaran)
@@ -162,30 +253,102 @@ func main() {
 		// simnikahRoutes.PUT("/wali/:id/status", AuthMiddleware(), catinHandler.UpdateStatusWali)
 
 		// Staff Management (hanya kepala KUA yang bisa akses)
-		simnikahRoutes.POST("/staff", AuthMiddleware(), RoleMiddleware("kepala_kua"), staffHandler.CreateStaffKUA)
-		simnikahRoutes.GET("/staff", AuthMiddleware(), RoleMiddleware("kepala_kua"), staffHandler.GetAllStaff)
-		simnikahRoutes.PUT("/staff/:id", AuthMiddleware(), RoleMiddleware("kepala_kua"), staffHandler.UpdateStaffKUA)
+		simnikahRoutes.POST("/staff", AuthMiddleware(), RequirePermission(structs.IzinStaffManage), staffHandler.CreateStaffKUA)
+		simnikahRoutes.GET("/staff", AuthMiddleware(), RequirePermission(structs.IzinStaffManage), staffHandler.GetAllStaff)
+		simnikahRoutes.PUT("/staff/:id", AuthMiddleware(), RequirePermission(structs.IzinStaffManage), staffHandler.UpdateStaffKUA)
 
 		// Penghulu Management
-		simnikahRoutes.POST("/penghulu", AuthMiddleware(), RoleMiddleware("kepala_kua"), staffHandler.CreatePenghulu)
+		simnikahRoutes.POST("/penghulu", AuthMiddleware(), RequirePermission(structs.IzinPenghuluManage), staffHandler.CreatePenghulu)
 		simnikahRoutes.GET("/penghulu", AuthMiddleware(), staffHandler.GetAllPenghulu)
-		simnikahRoutes.PUT("/penghulu/:id", AuthMiddleware(), RoleMiddleware("kepala_kua"), staffHandler.UpdatePenghulu)
+		simnikahRoutes.PUT("/penghulu/:id", AuthMiddleware(), RequirePermission(structs.IzinPenghuluManage), staffHandler.UpdatePenghulu)
+
+		// Status akun user (hanya kepala KUA), sesi user dicabut saat status berubah
+		simnikahRoutes.PUT("/users/:user_id/status", AuthMiddleware(), RequirePermission(structs.IzinAkunManage), staffHandler.UpdateUserStatus)
+		simnikahRoutes.POST("/users/:user_id/unlock", AuthMiddleware(), RequirePermission(structs.IzinAkunManage), staffHandler.UnlockUser)
+		simnikahRoutes.POST("/users/:user_id/mfa/reset", AuthMiddleware(), RequirePermission(structs.IzinAkunManage), staffHandler.ResetUserMfa)
+
+		// Pengaturan 2FA wajib (hanya kepala KUA)
+		simnikahRoutes.GET("/pengaturan/mfa", AuthMiddleware(), RequirePermission(structs.IzinPengaturanManage), staffHandler.GetMfaSetting)
+		simnikahRoutes.PUT("/pengaturan/mfa", AuthMiddleware(), RequirePermission(structs.IzinPengaturanManage), staffHandler.UpdateMfaSetting)
+
+		// Aturan penjadwalan per KUA (kapasitas, jeda, jam layanan, hari tutup)
+		simnikahRoutes.GET("/pengaturan/jadwal", AuthMiddleware(), staffHandler.GetAturanJadwal)
+		simnikahRoutes.PUT("/pengaturan/jadwal", AuthMiddleware(), RequirePermission(structs.IzinPengaturanManage), staffHandler.UpdateAturanJadwal)
+
+		// Hari libur nasional, cuti bersama, dan penutupan KUA
+		simnikahRoutes.GET("/hari-libur", AuthMiddleware(), staffHandler.GetHariLibur)
+		simnikahRoutes.POST("/hari-libur", AuthMiddleware(), RequirePermission(structs.IzinHariLiburManage), staffHandler.CreateHariLibur)
+		simnikahRoutes.POST("/hari-libur/import", AuthMiddleware(), RequirePermission(structs.IzinHariLiburManage), staffHandler.ImportHariLibur)
+		simnikahRoutes.DELETE("/hari-libur/:id", AuthMiddleware(), RequirePermission(structs.IzinHariLiburManage), staffHandler.DeleteHariLibur)
+
+		// Izin (permission) per role: izin efektif user login, dan pengelolaan oleh kepala KUA
+		simnikahRoutes.GET("/permissions/me", AuthMiddleware(), GetMyPermissions)
+		simnikahRoutes.GET("/permissions", AuthMiddleware(), RequirePermission(structs.IzinIzinManage), staffHandler.GetRolePermissions)
+		simnikahRoutes.PUT("/permissions/:role", AuthMiddleware(), RequirePermission(structs.IzinIzinManage), staffHandler.UpdateRolePermissions)
+
+		// Data KUA dan pemindahan user antar KUA
+		simnikahRoutes.POST("/kua", AuthMiddleware(), RequirePermission(structs.IzinKuaCreate), staffHandler.CreateKUA)
+		simnikahRoutes.PUT("/kua/:id", AuthMiddleware(), RequirePermission(structs.IzinKuaManage), staffHandler.UpdateKUA)
+		simnikahRoutes.PUT("/users/:user_id/kua", AuthMiddleware(), RequirePermission(structs.IzinKuaManage), staffHandler.MoveUserKUA)
+
+		// Audit Login (hanya kepala KUA)
+		simnikahRoutes.GET("/login-audit", AuthMiddleware(), RequirePermission(structs.IzinAuditView), staffHandler.GetLoginAudit)
+
+		// Undangan Staff/Penghulu (hanya kepala KUA)
+		simnikahRoutes.POST("/undangan-staff", AuthMiddleware(), RequirePermission(structs.IzinStaffManage), staffHandler.CreateStaffInvitation)
+		simnikahRoutes.GET("/undangan-staff", AuthMiddleware(), RequirePermission(structs.IzinStaffManage), staffHandler.GetStaffInvitations)
+		simnikahRoutes.DELETE("/undangan-staff/:id", AuthMiddleware(), RequirePermission(structs.IzinStaffManage), staffHandler.RevokeStaffInvitation)
 
 		// Staff Verification
-		simnikahRoutes.POST("/staff/verify-formulir/:id", AuthMiddleware(), RoleMiddleware("staff"), staffHandler.VerifyFormulir)
-		simnikahRoutes.POST("/staff/verify-berkas/:id", AuthMiddleware(), RoleMiddleware("staff"), staffHandler.VerifyBerkas)
+		simnikahRoutes.POST("/staff/verify-formulir/:id", AuthMiddleware(), RequirePermission(structs.IzinPendaftaranVerifyFormulir), staffHandler.VerifyFormulir)
+		simnikahRoutes.POST("/staff/verify-berkas/:id", AuthMiddleware(), RequirePermission(structs.IzinPendaftaranVerifyBerkas), staffHandler.VerifyBerkas)
 		
 		// Flexible Status Update (untuk Staff, Penghulu, Kepala KUA)
-		simnikahRoutes.PUT("/pendaftaran/:id/update-status", AuthMiddleware(), MultiRoleMiddleware("staff", "penghulu", "kepala_kua"), staffHandler.UpdateStatusFlexible)
+		simnikahRoutes.PUT("/pendaftaran/:id/update-status", AuthMiddleware(), RequirePermission(structs.IzinPendaftaranUpdateStatus), staffHandler.UpdateStatusFlexible)
 
 		// Penghulu Operations
-		simnikahRoutes.POST("/penghulu/verify-documents/:id", AuthMiddleware(), RoleMiddleware("penghulu"), penghuluHandler.VerifyDocuments)
-		simnikahRoutes.GET("/penghulu/assigned-registrations", AuthMiddleware(), RoleMiddleware("penghulu"), penghuluHandler.GetAssignedRegistrations)
+		simnikahRoutes.POST("/penghulu/verify-documents/:id", AuthMiddleware(), RequirePermission(structs.IzinPendaftaranVerifyPenghulu), penghuluHandler.VerifyDocuments)
+		simnikahRoutes.GET("/penghulu/assigned-registrations", AuthMiddleware(), RequirePermission(structs.IzinPenghuluViewAssigned), penghuluHandler.GetAssignedRegistrations)
+
+		// Cuti / ketidaksediaan penghulu: pengajuan oleh penghulu, persetujuan dan blokir oleh kepala KUA
+		simnikahRoutes.POST("/penghulu/ketidaksediaan", AuthMiddleware(), RequirePermission(structs.IzinPenghuluRequestCuti), penghuluHandler.AjukanKetidaksediaan)
+		simnikahRoutes.GET("/penghulu/ketidaksediaan", AuthMiddleware(), RequirePermission(structs.IzinPenghuluRequestCuti), penghuluHandler.GetKetidaksediaanSaya)
+		simnikahRoutes.DELETE("/penghulu/ketidaksediaan/:id", AuthMiddleware(), RequirePermission(structs.IzinPenghuluRequestCuti), penghuluHandler.BatalkanKetidaksediaan)
+		simnikahRoutes.GET("/ketidaksediaan-penghulu", AuthMiddleware(), RequirePermission(structs.IzinPenghuluApproveCuti), staffHandler.GetKetidaksediaanPenghulu)
+		simnikahRoutes.POST("/ketidaksediaan-penghulu", AuthMiddleware(), RequirePermission(structs.IzinPenghuluApproveCuti), staffHandler.CreateKetidaksediaanPenghulu)
+		simnikahRoutes.PUT("/ketidaksediaan-penghulu/:id/proses", AuthMiddleware(), RequirePermission(structs.IzinPenghuluApproveCuti), staffHandler.ProsesKetidaksediaanPenghulu)
+		simnikahRoutes.DELETE("/ketidaksediaan-penghulu/:id", AuthMiddleware(), RequirePermission(structs.IzinPenghuluApproveCuti), staffHandler.BatalkanKetidaksediaanPenghulu)
 
 		// Jadwal Nikah
-		simnikahRoutes.POST("/jadwal", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), CreateJadwalNikah)
+		simnikahRoutes.POST("/jadwal", AuthMiddleware(), RequirePermission(structs.IzinJadwalManage), CreateJadwalNikah)
 		simnikahRoutes.GET("/jadwal", AuthMiddleware(), GetJadwalNikah)
-		simnikahRoutes.PUT("/jadwal/:id", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), UpdateJadwalNikah)
+		simnikahRoutes.PUT("/jadwal/:id", AuthMiddleware(), RequirePermission(structs.IzinJadwalManage), UpdateJadwalNikah)
+
+		// Perubahan jadwal dan pembatalan: catin mengajukan, staff menyetujui atau menolak
+		simnikahRoutes.POST("/pendaftaran/:id/perubahan-jadwal", AuthMiddleware(), catinHandler.AjukanPerubahanJadwal)
+		simnikahRoutes.GET("/pendaftaran/:id/perubahan-jadwal", AuthMiddleware(), catinHandler.GetPerubahanJadwalPendaftaran)
+		simnikahRoutes.DELETE("/perubahan-jadwal/:id", AuthMiddleware(), catinHandler.BatalkanPerubahanJadwal)
+		simnikahRoutes.POST("/pendaftaran/:id/batalkan", AuthMiddleware(), catinHandler.BatalkanPendaftaran)
+		simnikahRoutes.GET("/perubahan-jadwal", AuthMiddleware(), RequirePermission(structs.IzinJadwalManage), staffHandler.GetPerubahanJadwal)
+		simnikahRoutes.PUT("/perubahan-jadwal/:id/proses", AuthMiddleware(), RequirePermission(structs.IzinJadwalManage), staffHandler.ProsesPerubahanJadwal)
+
+		// Daftar tunggu tanggal nikah dan sesi bimbingan yang penuh
+		simnikahRoutes.POST("/daftar-tunggu/tanggal", AuthMiddleware(), catinHandler.GabungDaftarTungguTanggal)
+		simnikahRoutes.POST("/bimbingan/:id/daftar-tunggu", AuthMiddleware(), catinHandler.GabungDaftarTungguBimbingan)
+		simnikahRoutes.GET("/daftar-tunggu", AuthMiddleware(), catinHandler.GetDaftarTungguSaya)
+		simnikahRoutes.POST("/daftar-tunggu/:id/terima", AuthMiddleware(), catinHandler.TerimaTawaranDaftarTunggu)
+		simnikahRoutes.DELETE("/daftar-tunggu/:id", AuthMiddleware(), catinHandler.KeluarDaftarTunggu)
+		simnikahRoutes.GET("/daftar-tunggu/kua", AuthMiddleware(), RequirePermission(structs.IzinJadwalManage), staffHandler.GetDaftarTungguKUA)
+
+		// Berkas persyaratan: unggah oleh catin/petugas, review oleh staff atau penghulu
+		simnikahRoutes.POST("/pendaftaran/:id/dokumen", AuthMiddleware(), catinHandler.UnggahDokumen)
+		simnikahRoutes.GET("/pendaftaran/:id/dokumen", AuthMiddleware(), catinHandler.GetDokumenPendaftaran)
+		simnikahRoutes.GET("/dokumen/:id/file", AuthMiddleware(), catinHandler.UnduhDokumen)
+		simnikahRoutes.DELETE("/dokumen/:id", AuthMiddleware(), catinHandler.HapusDokumen)
+		simnikahRoutes.PUT("/dokumen/:id/review", AuthMiddleware(), RequirePermission(structs.IzinDokumenReview), staffHandler.ReviewDokumen)
+		simnikahRoutes.GET("/pendaftaran/:id/checklist-dokumen", AuthMiddleware(), catinHandler.GetChecklistDokumen)
+		simnikahRoutes.PUT("/pendaftaran/:id/checklist-dokumen", AuthMiddleware(), RequirePermission(structs.IzinPendaftaranVerifyBerkas), staffHandler.TandaiKelengkapanBerkas)
+		simnikahRoutes.GET("/pendaftaran/:id/formulir/:model", AuthMiddleware(), catinHandler.UnduhFormulir)
 
 		// Kalender Ketersediaan
 		simnikahRoutes.GET("/kalender-ketersediaan", GetKalenderKetersediaan)
@@ -193,34 +356,45 @@ func main() {
 		simnikahRoutes.GET("/ketersediaan-tanggal/:tanggal", GetKetersediaanTanggal)
 		simnikahRoutes.GET("/penghulu-jadwal/:tanggal", GetPenghuluJadwal)
 
+		// Slot nikah di KUA: penahanan sementara saat catin mengisi formulir
+		simnikahRoutes.GET("/slot-nikah", GetSlotNikah)
+		simnikahRoutes.POST("/slot-nikah/hold", AuthMiddleware(), HoldSlotNikah)
+		simnikahRoutes.DELETE("/slot-nikah/hold/:id", AuthMiddleware(), ReleaseSlotNikah)
+
 		// Management Penghulu (Kepala KUA)
-		simnikahRoutes.POST("/pendaftaran/:id/assign-penghulu", AuthMiddleware(), MultiRoleMiddleware("kepala_kua"), AssignPenghulu)
-		simnikahRoutes.PUT("/pendaftaran/:id/change-penghulu", AuthMiddleware(), MultiRoleMiddleware("kepala_kua"), ChangePenghulu)
-		simnikahRoutes.GET("/pendaftaran/belum-assign-penghulu", AuthMiddleware(), MultiRoleMiddleware("kepala_kua"), GetPendaftaranBelumAssignPenghulu)
-		simnikahRoutes.GET("/penghulu/:id/ketersediaan/:tanggal", AuthMiddleware(), MultiRoleMiddleware("kepala_kua"), GetPenghuluKetersediaan)
+		simnikahRoutes.POST("/pendaftaran/:id/assign-penghulu", AuthMiddleware(), RequirePermission(structs.IzinPenghuluAssign), AssignPenghulu)
+		simnikahRoutes.PUT("/pendaftaran/:id/change-penghulu", AuthMiddleware(), RequirePermission(structs.IzinPenghuluAssign), ChangePenghulu)
+		simnikahRoutes.GET("/pendaftaran/:id/rekomendasi-penghulu", AuthMiddleware(), RequirePermission(structs.IzinPenghuluAssign), GetRekomendasiPenghulu)
+		simnikahRoutes.POST("/pendaftaran/auto-assign-penghulu/preview", AuthMiddleware(), RequirePermission(structs.IzinPenghuluAssign), PreviewAutoAssignPenghulu)
+		simnikahRoutes.POST("/pendaftaran/auto-assign-penghulu", AuthMiddleware(), RequirePermission(structs.IzinPenghuluAssign), ConfirmAutoAssignPenghulu)
+		simnikahRoutes.GET("/pendaftaran/belum-assign-penghulu", AuthMiddleware(), RequirePermission(structs.IzinPenghuluAssign), GetPendaftaranBelumAssignPenghulu)
+		simnikahRoutes.GET("/penghulu/:id/ketersediaan/:tanggal", AuthMiddleware(), RequirePermission(structs.IzinPenghuluAssign), GetPenghuluKetersediaan)
+		simnikahRoutes.GET("/penghulu/konflik-ketidaksediaan", AuthMiddleware(), RequirePermission(structs.IzinPenghuluAssign), GetKonflikKetidaksediaanPenghulu)
 
 		// Bimbingan Perkawinan
-		simnikahRoutes.POST("/bimbingan", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), CreateBimbinganPerkawinan)
+		simnikahRoutes.POST("/bimbingan", AuthMiddleware(), RequirePermission(structs.IzinBimbinganManage), CreateBimbinganPerkawinan)
 		simnikahRoutes.GET("/bimbingan", AuthMiddleware(), GetBimbinganPerkawinan)
 		simnikahRoutes.GET("/bimbingan/:id", AuthMiddleware(), GetBimbinganPerkawinanByID)
-		simnikahRoutes.PUT("/bimbingan/:id", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), UpdateBimbinganPerkawinan)
+		simnikahRoutes.PUT("/bimbingan/:id", AuthMiddleware(), RequirePermission(structs.IzinBimbinganManage), UpdateBimbinganPerkawinan)
 		simnikahRoutes.GET("/bimbingan-kalender", AuthMiddleware(), GetBimbinganKalender)
 		simnikahRoutes.POST("/bimbingan/:id/daftar", AuthMiddleware(), DaftarBimbinganPerkawinan)
-		simnikahRoutes.GET("/bimbingan/:id/participants", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), GetBimbinganParticipants)
-		simnikahRoutes.PUT("/bimbingan/:id/update-attendance", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), UpdateBimbinganAttendance)
+		simnikahRoutes.GET("/bimbingan/:id/participants", AuthMiddleware(), RequirePermission(structs.IzinBimbinganManage), GetBimbinganParticipants)
+		simnikahRoutes.PUT("/bimbingan/:id/update-attendance", AuthMiddleware(), RequirePermission(structs.IzinBimbinganManage), UpdateBimbinganAttendance)
 		simnikahRoutes.GET("/bimbingan/:id/undangan", AuthMiddleware(), CetakUndanganBimbingan)
-		simnikahRoutes.GET("/bimbingan/:id/undangan-semua", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), CetakUndanganBimbinganSemua)
+		simnikahRoutes.GET("/bimbingan/:id/undangan-semua", AuthMiddleware(), RequirePermission(structs.IzinBimbinganManage), CetakUndanganBimbinganSemua)
+		simnikahRoutes.GET("/undangan-bimbingan/verifikasi/:token", VerifikasiUndanganBimbingan)
 
 		// Geocoding API untuk mendapatkan koordinat alamat (GRATIS menggunakan OpenStreetMap)
 		simnikahRoutes.GET("/geocoding/coordinates", AuthMiddleware(), GetAddressCoordinates)
 
 		// Status Management
 		simnikahRoutes.GET("/pendaftaran/:id/status-flow", AuthMiddleware(), GetStatusFlow)
-		simnikahRoutes.PUT("/pendaftaran/:id/complete-bimbingan", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), CompleteBimbingan)
-		simnikahRoutes.PUT("/pendaftaran/:id/complete-nikah", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), CompleteNikah)
+		simnikahRoutes.GET("/pendaftaran/:id/next-transitions", AuthMiddleware(), GetNextTransitions)
+		simnikahRoutes.PUT("/pendaftaran/:id/complete-bimbingan", AuthMiddleware(), RequirePermission(structs.IzinPendaftaranCompleteBimbingan), CompleteBimbingan)
+		simnikahRoutes.PUT("/pendaftaran/:id/complete-nikah", AuthMiddleware(), RequirePermission(structs.IzinPendaftaranCompleteNikah), CompleteNikah)
 
 		// Wedding Address Management
-		simnikahRoutes.PUT("/pendaftaran/:id/alamat", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), catinHandler.UpdateWeddingAddress)
+		simnikahRoutes.PUT("/pendaftaran/:id/alamat", AuthMiddleware(), RequirePermission(structs.IzinPendaftaranUpdateAlamat), catinHandler.UpdateWeddingAddress)
 
 		// ==================== MAP & LOCATION INTEGRATION ====================
 		// Endpoints untuk integrasi peta dan koordinat lokasi nikah
@@ -247,15 +421,21 @@ func main() {
 		// simnikahRoutes.POST("/surat-undangan/verify", AuthMiddleware(), catinHandler.VerifyDigitalSignature)
 
 		// Notifikasi
-		simnikahRoutes.POST("/notifikasi", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), notificationHandler.CreateNotification)
+		simnikahRoutes.POST("/notifikasi", AuthMiddleware(), RequirePermission(structs.IzinNotifikasiManage), notificationHandler.CreateNotification)
 		simnikahRoutes.GET("/notifikasi/user/:user_id", AuthMiddleware(), notificationHandler.GetUserNotifications)
 		simnikahRoutes.GET("/notifikasi/:id", AuthMiddleware(), notificationHandler.GetNotificationByID)
 		simnikahRoutes.PUT("/notifikasi/:id/status", AuthMiddleware(), notificationHandler.UpdateNotificationStatus)
 		simnikahRoutes.PUT("/notifikasi/user/:user_id/mark-all-read", AuthMiddleware(), notificationHandler.MarkAllAsRead)
 		simnikahRoutes.DELETE("/notifikasi/:id", AuthMiddleware(), notificationHandler.DeleteNotification)
 		simnikahRoutes.GET("/notifikasi/user/:user_id/stats", AuthMiddleware(), notificationHandler.GetNotificationStats)
-		simnikahRoutes.POST("/notifikasi/send-to-role", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), notificationHandler.SendNotificationToRole)
-		simnikahRoutes.POST("/notifikasi/run-reminder", AuthMiddleware(), MultiRoleMiddleware("staff", "kepala_kua"), RunReminderNotification)
+		simnikahRoutes.POST("/notifikasi/send-to-role", AuthMiddleware(), RequirePermission(structs.IzinNotifikasiManage), notificationHandler.SendNotificationToRole)
+		simnikahRoutes.POST("/notifikasi/run-reminder", AuthMiddleware(), RequirePermission(structs.IzinNotifikasiManage), RunReminderNotification)
+
+		// Feed kalender iCalendar: URL langganan per user (penghulu, catin, staff/kepala KUA)
+		simnikahRoutes.GET("/kalender/feed", AuthMiddleware(), notificationHandler.GetKalenderFeed)
+		simnikahRoutes.POST("/kalender/feed/reset", AuthMiddleware(), notificationHandler.ResetKalenderFeed)
+		simnikahRoutes.DELETE("/kalender/feed", AuthMiddleware(), notificationHandler.CabutKalenderFeed)
+		simnikahRoutes.GET("/kalender/feed/:token", notificationHandler.GetKalenderFeedICS)
 
 	}
 
@@ -285,6 +465,23 @@ func main() {
 		}
 	}()
 
+	// Bersihkan denylist token, sesi, dan penahanan slot yang sudah kedaluwarsa setiap jam
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := services.NewSessionService(DB).PurgeExpired(); err != nil {
+				log.Printf("Warning: Failed to purge expired sessions: %v", err)
+			}
+			if _, err := services.NewSlotService(DB).PurgeExpiredHolds(); err != nil {
+				log.Printf("Warning: Failed to purge expired slot holds: %v", err)
+			}
+			if err := services.NewWaitlistService(DB).Run(); err != nil {
+				log.Printf("Warning: Failed to promote waitlists: %v", err)
+			}
+		}
+	}()
+
 	// Wait for interrupt signal for graceful shutdown
 	quit := make(chan os.Signal, 1)
 	// SIGINT (Ctrl+C) and SIGTERM (kill command) will trigger graceful shutdown
@@ -343,13 +540,8 @@ func createGinEngine(ginMode string) *gin.Engine {
 
 // getJWTKey returns the JWT key from environment or uses a fallback
 func getJWTKey() []byte {
-	key := os.Getenv("JWT_KEY")
-	if key == "" {
-		// Only for development, warn about using default key
-		log.Println("Warning: Using default JWT key. Set JWT_KEY environment variable in production.")
-		return []byte("secret-key-boleh-diubah-untuk-simnikah")
-	}
-	return []byte(key)
+	// Tanpa JWT_KEY hanya boleh di mode pengembangan (lihat services.CheckSecretKeys)
+	return services.SecretKey(services.EnvJWTKey)
 }
 
 // getAllowedOrigins returns allowed origins for CORS from environment or uses defaults
@@ -387,7 +579,7 @@ func RegisterUser(c *gin.Context) {
 		Email    string `json:"email" binding:"required,email"`
 		Password string `json:"password" binding:"required,min=6"`
 		Nama     string `json:"nama" binding:"required"`
-		Role     string `json:"role" binding:"required"`
+		Role     string `json:"role"` // opsional, hanya user_biasa
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -395,15 +587,10 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
-	// Validate role
-	validRoles := map[string]bool{
-		"user_biasa": true, // User biasa untuk daftar nikah
-		"penghulu":   true, // Penghulu untuk memimpin nikah
-		"staff":      true, // Staff KUA untuk verifikasi
-		"kepala_kua": true, // Kepala KUA untuk approval
-	}
-	if !validRoles[input.Role] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Role tidak valid. Role yang tersedia: user_biasa, penghulu, staff, kepala_kua"})
+	// Registrasi publik hanya untuk calon pengantin. Akun staff, penghulu, dan kepala KUA
+	// dibuat oleh Kepala KUA lewat undangan (/simnikah/undangan-staff).
+	if input.Role != "" && input.Role != structs.UserRoleUserBiasa {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registrasi publik hanya untuk role user_biasa. Akun staff/penghulu dibuat oleh Kepala KUA melalui undangan"})
 		return
 	}
 
@@ -430,7 +617,7 @@ func RegisterUser(c *gin.Context) {
 		Username:   input.Username,
 		Email:      input.Email,
 		Password:   string(hashedPassword),
-		Role:       input.Role,
+		Role:       structs.UserRoleUserBiasa,
 		Nama:       input.Nama,
 		Status:     "Aktif",
 		Created_at: time.Now(),
@@ -449,7 +636,7 @@ func RegisterUser(c *gin.Context) {
 			"username": input.Username,
 			"email":    input.Email,
 			"nama":     input.Nama,
-			"role":     input.Role,
+			"role":     user.Role,
 		},
 	})
 }
@@ -466,57 +653,585 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	var user structs.Users
+	// Verifikasi kredensial dengan penguncian akun bertahap; setiap percobaan dicatat di LoginAudit
+	user, err := services.NewLoginGuardService(DB).Authenticate(loginRequest.Username, loginRequest.Password, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrLoginFailed):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Username atau password salah. Akun dikunci sementara setelah beberapa kali gagal login"})
+		case errors.Is(err, services.ErrAccountInactive):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User tidak aktif"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses login"})
+		}
+		return
+	}
 
-	if err := DB.Where("username = ?", loginRequest.Username).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Username tidak ditemukan"})
+	// Akun dengan 2FA aktif harus memasukkan kode authenticator sebelum mendapat token
+	mfaService := services.NewMfaService(DB)
+	if mfaService.IsEnabled(user.User_id) {
+		respondMfaChallenge(c, user, mfaPurposeVerify, "Masukkan kode 2FA dari aplikasi authenticator")
+		return
+	}
+	if mfaService.IsRequiredFor(user.Kua_id, user.Role) {
+		respondMfaChallenge(c, user, mfaPurposeSetup, "2FA wajib untuk role "+user.Role+". Daftarkan aplikasi authenticator terlebih dahulu")
 		return
 	}
 
-	// Check if user is active
-	if user.Status != "Aktif" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User tidak aktif"})
+	completeLogin(c, user, nil)
+}
+
+// completeLogin mencatat login berhasil, membuat sesi baru (refresh token) dan access token berumur pendek
+func completeLogin(c *gin.Context, user *structs.Users, extra gin.H) {
+	services.NewLoginGuardService(DB).CompleteLogin(user, c.ClientIP(), c.Request.UserAgent())
+
+	sesi, refreshToken, err := services.NewSessionService(DB).StartSession(user.User_id, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat sesi login"})
 		return
 	}
 
-	// Validate password hash menggunakan sistem KUA yang aman
-	if err := crypto.VerifyPassword(loginRequest.Password, user.Password); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password salah"})
+	tokenString, err := signAccessToken(*user, sesi)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token"})
 		return
 	}
 
-	// Build JWT claims
+	response := gin.H{
+		"message":       "Login berhasil",
+		"token":         tokenString,
+		"refresh_token": refreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(services.AccessTokenTTL.Seconds()),
+		"user": gin.H{
+			"user_id": user.User_id,
+			"email":   user.Email,
+			"role":    user.Role,
+			"nama":    user.Nama,
+			"kua_id":  user.Kua_id,
+		},
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// signAccessToken membuat access token (JWT) untuk sesi yang aktif
+func signAccessToken(user structs.Users, sesi *structs.SesiPengguna) (string, error) {
 	claims := TokenClaims{
-		UserID: user.User_id,
-		Email:  user.Email,
-		Role:   user.Role,
-		Nama:   user.Nama,
+		UserID:    user.User_id,
+		Email:     user.Email,
+		Role:      user.Role,
+		Nama:      user.Nama,
+		SessionID: sesi.Session_id,
+		KuaID:     user.Kua_id,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Token berlaku 24 jam
+			ID:        sesi.Access_jti,
+			ExpiresAt: jwt.NewNumericDate(sesi.Access_kedaluwarsa_pada), // Token berlaku 15 menit
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	return token.SignedString(jwtKey)
+}
+
+// ==================== TWO-FACTOR AUTHENTICATION (TOTP) ====================
+
+const (
+	mfaPurposeVerify = "mfa_verify" // User sudah punya 2FA, tinggal memasukkan kode
+	mfaPurposeSetup  = "mfa_setup"  // 2FA wajib tetapi user belum mendaftarkan authenticator
+	mfaTokenTTL      = 5 * time.Minute
+)
+
+// MfaPendingClaims adalah isi token sementara antara verifikasi password dan verifikasi 2FA.
+// Token ini tidak bisa dipakai sebagai access token karena tidak memiliki sesi (sid).
+type MfaPendingClaims struct {
+	UserID  string `json:"user_id"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// respondMfaChallenge mengembalikan token "mfa pending" setelah password benar
+func respondMfaChallenge(c *gin.Context, user *structs.Users, purpose, message string) {
+	jti, err := services.NewTokenID()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token 2FA"})
+		return
+	}
+
+	now := time.Now()
+	claims := MfaPendingClaims{
+		UserID:  user.User_id,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Audience:  jwt.ClaimStrings{purpose},
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token 2FA"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login berhasil",
-		"token":   tokenString,
-		"user": gin.H{
-			"user_id": claims.UserID,
-			"email":   claims.Email,
-			"role":    claims.Role,
-			"nama":    claims.Nama,
+		"message":            message,
+		"mfa_required":       purpose == mfaPurposeVerify,
+		"mfa_setup_required": purpose == mfaPurposeSetup,
+		"mfa_token":          tokenString,
+		"expires_in":         int(mfaTokenTTL.Seconds()),
+	})
+}
+
+// parseMfaToken memvalidasi token "mfa pending" dan mengembalikan user pemiliknya
+func parseMfaToken(tokenString, purpose string) (*MfaPendingClaims, *structs.Users, error) {
+	claims := &MfaPendingClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("metode signing token tidak valid")
+		}
+		return jwtKey, nil
+	}, jwt.WithAudience(purpose))
+	if err != nil || !token.Valid || claims.Purpose != purpose || claims.ID == "" {
+		return nil, nil, errors.New("token 2FA tidak valid atau kedaluwarsa, silakan login ulang")
+	}
+
+	revoked, err := services.NewSessionService(DB).IsAccessTokenRevoked(claims.ID)
+	if err != nil || revoked {
+		return nil, nil, errors.New("token 2FA sudah digunakan, silakan login ulang")
+	}
+
+	var user structs.Users
+	if err := DB.Where("user_id = ?", claims.UserID).First(&user).Error; err != nil || user.Status != structs.UserStatusAktif {
+		return nil, nil, errors.New("user tidak aktif")
+	}
+
+	return claims, &user, nil
+}
+
+// consumeMfaToken membuat token "mfa pending" tidak bisa dipakai lagi
+func consumeMfaToken(claims *MfaPendingClaims) {
+	if err := services.NewSessionService(DB).DenyAccessToken(claims.ID, claims.UserID, claims.ExpiresAt.Time, structs.SesiAlasanLogout); err != nil {
+		log.Printf("Warning: Failed to revoke MFA token: %v", err)
+	}
+}
+
+// LoginMfa menyelesaikan login dengan kode TOTP atau kode pemulihan
+func LoginMfa(c *gin.Context) {
+	var input struct {
+		MfaToken      string `json:"mfa_token" binding:"required"`
+		Kode          string `json:"kode"`
+		KodePemulihan string `json:"kode_pemulihan"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil || (input.Kode == "" && input.KodePemulihan == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token dan kode (atau kode_pemulihan) diperlukan"})
+		return
+	}
+
+	claims, user, err := parseMfaToken(input.MfaToken, mfaPurposeVerify)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	guard := services.NewLoginGuardService(DB)
+	if err := guard.CheckSecondFactorAllowed(user, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Kode 2FA salah. Akun dikunci sementara setelah beberapa kali gagal login"})
+		return
+	}
+
+	mfaService := services.NewMfaService(DB)
+	if err := mfaService.VerifySecondFactor(user.User_id, input.Kode, input.KodePemulihan); err != nil {
+		if errors.Is(err, services.ErrMfaCodeInvalid) {
+			guard.RegisterSecondFactorFailure(user, c.ClientIP(), c.Request.UserAgent())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Kode 2FA salah. Akun dikunci sementara setelah beberapa kali gagal login"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memverifikasi kode 2FA"})
+		return
+	}
+
+	consumeMfaToken(claims)
+
+	var extra gin.H
+	if input.KodePemulihan != "" {
+		extra = gin.H{"sisa_kode_pemulihan": mfaService.RemainingRecoveryCodes(user.User_id)}
+	}
+	completeLogin(c, user, extra)
+}
+
+// LoginMfaSetup memulai pendaftaran authenticator saat 2FA wajib tetapi belum didaftarkan
+func LoginMfaSetup(c *gin.Context) {
+	var input struct {
+		MfaToken string `json:"mfa_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token diperlukan"})
+		return
+	}
+
+	_, user, err := parseMfaToken(input.MfaToken, mfaPurposeSetup)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, uri, err := services.NewMfaService(DB).BeginEnrollment(user)
+	if err != nil {
+		respondMfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Scan QR/otpauth URI dengan aplikasi authenticator, lalu konfirmasi dengan kode",
+		"data": gin.H{
+			"secret":      secret,
+			"otpauth_uri": uri,
+		},
+	})
+}
+
+// LoginMfaSetupConfirm mengaktifkan 2FA wajib lalu menyelesaikan login
+func LoginMfaSetupConfirm(c *gin.Context) {
+	var input struct {
+		MfaToken string `json:"mfa_token" binding:"required"`
+		Kode     string `json:"kode" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token dan kode diperlukan"})
+		return
+	}
+
+	claims, user, err := parseMfaToken(input.MfaToken, mfaPurposeSetup)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	recoveryCodes, err := services.NewMfaService(DB).ConfirmEnrollment(user.User_id, input.Kode)
+	if err != nil {
+		respondMfaError(c, err)
+		return
+	}
+
+	consumeMfaToken(claims)
+	completeLogin(c, user, gin.H{"kode_pemulihan": recoveryCodes})
+}
+
+// GetMfaStatus menampilkan status 2FA user yang login
+func GetMfaStatus(c *gin.Context) {
+	userID := c.GetString("user_id")
+	mfaService := services.NewMfaService(DB)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Status 2FA berhasil diambil",
+		"data": gin.H{
+			"aktif":               mfaService.IsEnabled(userID),
+			"wajib":               mfaService.IsRequiredFor(c.GetUint("kua_id"), c.GetString("role")),
+			"sisa_kode_pemulihan": mfaService.RemainingRecoveryCodes(userID),
+		},
+	})
+}
+
+// EnrollMfa memulai pendaftaran 2FA opsional untuk user yang login
+func EnrollMfa(c *gin.Context) {
+	var user structs.Users
+	if err := DB.Where("user_id = ?", c.GetString("user_id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+
+	secret, uri, err := services.NewMfaService(DB).BeginEnrollment(&user)
+	if err != nil {
+		respondMfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Scan QR/otpauth URI dengan aplikasi authenticator, lalu konfirmasi dengan kode",
+		"data": gin.H{
+			"secret":      secret,
+			"otpauth_uri": uri,
+		},
+	})
+}
+
+// ConfirmMfaEnrollment mengaktifkan 2FA dan mengembalikan kode pemulihan (hanya ditampilkan sekali)
+func ConfirmMfaEnrollment(c *gin.Context) {
+	var input struct {
+		Kode string `json:"kode" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode diperlukan"})
+		return
+	}
+
+	recoveryCodes, err := services.NewMfaService(DB).ConfirmEnrollment(c.GetString("user_id"), input.Kode)
+	if err != nil {
+		respondMfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "2FA berhasil diaktifkan. Simpan kode pemulihan di tempat yang aman",
+		"data": gin.H{
+			"kode_pemulihan": recoveryCodes,
+		},
+	})
+}
+
+// DisableMfa menonaktifkan 2FA (wajib password dan kode 2FA), ditolak jika 2FA diwajibkan
+func DisableMfa(c *gin.Context) {
+	var input struct {
+		Password string `json:"password" binding:"required"`
+		Kode     string `json:"kode" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password dan kode 2FA diperlukan"})
+		return
+	}
+
+	var user structs.Users
+	if err := DB.Where("user_id = ?", c.GetString("user_id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+	if err := crypto.VerifyPassword(input.Password, user.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password salah"})
+		return
+	}
+
+	mfaService := services.NewMfaService(DB)
+	if err := mfaService.VerifyCode(user.User_id, input.Kode); err != nil {
+		respondMfaError(c, err)
+		return
+	}
+	if err := mfaService.Disable(&user); err != nil {
+		respondMfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "2FA berhasil dinonaktifkan"})
+}
+
+// RegenerateMfaRecoveryCodes membuat ulang kode pemulihan (wajib kode 2FA)
+func RegenerateMfaRecoveryCodes(c *gin.Context) {
+	var input struct {
+		Kode string `json:"kode" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode 2FA diperlukan"})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	mfaService := services.NewMfaService(DB)
+	if err := mfaService.VerifyCode(userID, input.Kode); err != nil {
+		respondMfaError(c, err)
+		return
+	}
+
+	recoveryCodes, err := mfaService.RegenerateRecoveryCodes(userID)
+	if err != nil {
+		respondMfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Kode pemulihan baru berhasil dibuat. Kode lama tidak berlaku lagi",
+		"data": gin.H{
+			"kode_pemulihan": recoveryCodes,
 		},
 	})
 }
 
+// respondMfaError memetakan error MfaService ke HTTP response
+func respondMfaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrMfaCodeInvalid),
+		errors.Is(err, services.ErrMfaNotEnrolled),
+		errors.Is(err, services.ErrMfaAlreadyActive):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMfaRequired),
+		errors.Is(err, services.ErrMfaRoleNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses 2FA"})
+	}
+}
+
+// RefreshToken menukar refresh token dengan access token dan refresh token baru (rotasi)
+func RefreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token diperlukan"})
+		return
+	}
+
+	sessionService := services.NewSessionService(DB)
+	sesi, refreshToken, err := sessionService.RotateSession(input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrRefreshTokenInvalid) || errors.Is(err, services.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui sesi"})
+		return
+	}
+
+	// Role/nama bisa berubah sejak login, selalu ambil data terbaru
+	var user structs.Users
+	if err := DB.Where("user_id = ?", sesi.User_id).First(&user).Error; err != nil || user.Status != structs.UserStatusAktif {
+		sessionService.RevokeSession(sesi.Session_id, structs.SesiAlasanStatusBerubah)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User tidak aktif"})
+		return
+	}
+
+	// Setelah 2FA diwajibkan, user yang belum mendaftarkan authenticator harus login ulang
+	mfaService := services.NewMfaService(DB)
+	if mfaService.IsRequiredFor(user.Kua_id, user.Role) && !mfaService.IsEnabled(user.User_id) {
+		sessionService.RevokeSession(sesi.Session_id, structs.SesiAlasanLogout)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "2FA wajib untuk role ini, silakan login ulang dan daftarkan authenticator"})
+		return
+	}
+
+	tokenString, err := signAccessToken(user, sesi)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Token berhasil diperbarui",
+		"token":         tokenString,
+		"refresh_token": refreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(services.AccessTokenTTL.Seconds()),
+	})
+}
+
+// Logout mencabut sesi yang sedang dipakai (access token dan refresh token-nya)
+func Logout(c *gin.Context) {
+	sessionID := c.GetString("session_id")
+
+	if err := services.NewSessionService(DB).RevokeSession(sessionID, structs.SesiAlasanLogout); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logout berhasil"})
+}
+
+// LogoutAll mencabut semua sesi milik user yang login (logout dari semua perangkat)
+func LogoutAll(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	count, err := services.NewSessionService(DB).RevokeAllSessions(userID, structs.SesiAlasanLogoutSemua)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal logout dari semua perangkat"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Logout dari semua perangkat berhasil",
+		"sesi_dicabut": count,
+	})
+}
+
+// ChangePassword mengganti password user yang login (wajib password lama)
+func ChangePassword(c *gin.Context) {
+	var input struct {
+		PasswordLama string `json:"password_lama" binding:"required"`
+		PasswordBaru string `json:"password_baru" binding:"required,min=6"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format data tidak valid. Password baru minimal 6 karakter"})
+		return
+	}
+
+	passwordService := services.NewPasswordService(DB, Mailer)
+	err := passwordService.ChangePassword(c.GetString("user_id"), c.GetString("session_id"), input.PasswordLama, input.PasswordBaru)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrOldPasswordWrong):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrPasswordUnchanged):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengganti password"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password berhasil diganti. Sesi di perangkat lain telah dikeluarkan"})
+}
+
+// ForgotPassword mengirim kode reset password ke email user
+func ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email tidak valid"})
+		return
+	}
+
+	if err := services.NewPasswordService(DB, Mailer).RequestPasswordReset(input.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengirim kode reset password"})
+		return
+	}
+
+	// Response sama untuk email terdaftar maupun tidak
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Jika email terdaftar, kode reset password telah dikirim",
+		"berlaku_menit": int(services.PasswordResetCodeTTL.Minutes()),
+	})
+}
+
+// ResetPassword mengganti password menggunakan kode dari email
+func ResetPassword(c *gin.Context) {
+	var input struct {
+		Email        string `json:"email" binding:"required,email"`
+		Kode         string `json:"kode" binding:"required,len=6,numeric"`
+		PasswordBaru string `json:"password_baru" binding:"required,min=6"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format data tidak valid. Kode 6 digit dan password baru minimal 6 karakter"})
+		return
+	}
+
+	if err := services.NewPasswordService(DB, Mailer).ResetPassword(input.Email, input.Kode, input.PasswordBaru); err != nil {
+		if errors.Is(err, services.ErrResetCodeInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mereset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password berhasil direset. Silakan login dengan password baru"})
+}
+
 // A more robust and type-safe AuthMiddleware
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -544,6 +1259,23 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Token tanpa jti/sesi (format lama) tidak bisa dicabut, minta login ulang
+		if claims.ID == "" || claims.SessionID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token tidak valid atau kedaluwarsa"})
+			return
+		}
+
+		// Cek denylist: token dari sesi yang sudah logout/dicabut ditolak
+		revoked, err := services.NewSessionService(DB).IsAccessTokenRevoked(claims.ID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Gagal memvalidasi token"})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token sudah dicabut, silakan login ulang"})
+			return
+		}
+
 		// --- From here on, everything is type-safe! ---
 
 		// Validasi role (lebih bersih dengan akses struct)
@@ -563,6 +1295,8 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("role", claims.Role)
 		c.Set("email", claims.Email)
 		c.Set("nama", claims.Nama)
+		c.Set("session_id", claims.SessionID)
+		c.Set("kua_id", claims.KuaID)
 
 		c.Next()
 	}
@@ -621,6 +1355,33 @@ func MultiRoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	}
 }
 
+// RequirePermission untuk validasi izin (permission) role user di KUA-nya berdasarkan tabel izin_roles
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Role tidak ditemukan"})
+			c.Abort()
+			return
+		}
+
+		allowed, err := services.NewPermissionService(DB).HasPermission(c.GetUint("kua_id"), role.(string), permission)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa izin akses"})
+			c.Abort()
+			return
+		}
+
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak. Izin " + permission + " diperlukan"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 func Profile(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -654,6 +1415,27 @@ func Profile(c *gin.Context) {
 			"email":    user.Email,
 			"role":     user.Role,
 			"nama":     user.Nama,
+			"kua_id":   user.Kua_id,
+		},
+	})
+}
+
+// GetMyPermissions mengembalikan izin efektif user yang login, dipakai frontend untuk menampilkan/menyembunyikan aksi
+func GetMyPermissions(c *gin.Context) {
+	role := c.GetString("role")
+
+	permissions, err := services.NewPermissionService(DB).PermissionsForRole(c.GetUint("kua_id"), role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil izin"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Izin berhasil diambil",
+		"data": gin.H{
+			"user_id": c.GetString("user_id"),
+			"role":    role,
+			"izin":    permissions,
 		},
 	})
 }
@@ -681,6 +1463,11 @@ func GetAvailableRoles(c *gin.Context) {
 			"role_name":   "Kepala KUA",
 			"description": "Supervisor dan approval tertinggi",
 		},
+		{
+			"role_code":   "super_admin",
+			"role_name":   "Super Admin",
+			"description": "Pengelola pusat yang membuat KUA baru",
+		},
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -689,17 +1476,170 @@ func GetAvailableRoles(c *gin.Context) {
 	})
 }
 
-// Placeholder functions for missing handlers
+// CreateJadwalNikah menetapkan jadwal akad (tanggal, waktu, dan lokasi) sebuah pendaftaran oleh staff.
+// Semua field jadwal wajib; jadwal divalidasi ulang terhadap aturan KUA, kuota, dan jadwal penghulu.
 func CreateJadwalNikah(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Fungsi CreateJadwalNikah belum diimplementasi"})
+	var input struct {
+		PendaftaranID uint `json:"pendaftaran_id" binding:"required"`
+		services.JadwalInput
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.Tanggal == "" || input.Waktu == "" || input.Tempat == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field pendaftaran_id, tanggal, waktu, dan tempat diperlukan"})
+		return
+	}
+
+	rescheduleJadwalNikah(c, input.PendaftaranID, input.JadwalInput, http.StatusCreated, "Jadwal nikah berhasil ditetapkan")
 }
 
+// GetJadwalNikah menampilkan jadwal akad KUA per hari, minggu (Senin-Minggu), atau bulan.
+// Query: ?tampilan=harian|mingguan|bulanan&tanggal=YYYY-MM-DD&penghulu_id=&tempat=&status=
+// Catin (user_biasa) hanya melihat jadwal pendaftarannya sendiri.
 func GetJadwalNikah(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Fungsi GetJadwalNikah belum diimplementasi"})
+	tanggal := time.Now()
+	if raw := c.Query("tanggal"); raw != "" {
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format tanggal tidak valid (YYYY-MM-DD)"})
+			return
+		}
+		tanggal = parsed
+	} else {
+		tanggal = time.Date(tanggal.Year(), tanggal.Month(), tanggal.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	tampilan := c.DefaultQuery("tampilan", services.JadwalTampilanHarian)
+	mulai, selesai, err := services.JadwalRange(tampilan, tanggal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := services.JadwalFilter{
+		KuaID:   c.GetUint("kua_id"),
+		Mulai:   mulai,
+		Selesai: selesai,
+		Tempat:  c.Query("tempat"),
+		Status:  c.Query("status"),
+	}
+	if raw := c.Query("penghulu_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "penghulu_id tidak valid"})
+			return
+		}
+		filter.PenghuluID = uint(id)
+	}
+	if filter.Tempat != "" && filter.Tempat != "Di KUA" && filter.Tempat != "Di Luar KUA" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tempat harus 'Di KUA' atau 'Di Luar KUA'"})
+		return
+	}
+	if c.GetString("role") == structs.UserRoleUserBiasa {
+		filter.PendaftarID = c.GetString("user_id")
+	}
+
+	jadwal, err := services.NewJadwalService(DB).List(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil jadwal nikah"})
+		return
+	}
+
+	// Kelompokkan per tanggal; tanggal tanpa akad tetap ditampilkan untuk tampilan kalender
+	perTanggal := make(map[string][]services.JadwalItem)
+	diKUA, diLuarKUA, tanpaPenghulu := 0, 0, 0
+	for _, j := range jadwal {
+		perTanggal[j.Tanggal] = append(perTanggal[j.Tanggal], j)
+		if j.Tempat == "Di KUA" {
+			diKUA++
+		} else {
+			diLuarKUA++
+		}
+		if j.PenghuluID == nil {
+			tanpaPenghulu++
+		}
+	}
+	hari := make([]gin.H, 0)
+	for d := mulai; d.Before(selesai); d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		items := perTanggal[key]
+		if items == nil {
+			items = []services.JadwalItem{}
+		}
+		hari = append(hari, gin.H{
+			"tanggal": key,
+			"jumlah":  len(items),
+			"jadwal":  items,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Jadwal nikah berhasil diambil",
+		"data": gin.H{
+			"tampilan":        tampilan,
+			"tanggal_mulai":   mulai.Format("2006-01-02"),
+			"tanggal_selesai": selesai.AddDate(0, 0, -1).Format("2006-01-02"),
+			"filter": gin.H{
+				"penghulu_id": filter.PenghuluID,
+				"tempat":      filter.Tempat,
+				"status":      filter.Status,
+			},
+			"ringkasan": gin.H{
+				"total":          len(jadwal),
+				"di_kua":         diKUA,
+				"di_luar_kua":    diLuarKUA,
+				"tanpa_penghulu": tanpaPenghulu,
+			},
+			"hari": hari,
+		},
+	})
 }
 
+// UpdateJadwalNikah mengubah tanggal, waktu, dan/atau lokasi akad sebuah pendaftaran (:id = ID pendaftaran).
+// Field yang tidak dikirim tetap; jadwal baru divalidasi ulang terhadap aturan KUA, kuota, dan jadwal penghulu.
 func UpdateJadwalNikah(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Fungsi UpdateJadwalNikah belum diimplementasi"})
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID pendaftaran tidak valid"})
+		return
+	}
+
+	var input services.JadwalInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format data tidak valid"})
+		return
+	}
+
+	rescheduleJadwalNikah(c, uint(id), input, http.StatusOK, "Jadwal nikah berhasil diubah")
+}
+
+// rescheduleJadwalNikah menjalankan perubahan jadwal akad dan memetakan error ke response HTTP
+func rescheduleJadwalNikah(c *gin.Context, pendaftaranID uint, input services.JadwalInput, status int, message string) {
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	change, err := services.NewJadwalService(DB).Reschedule(c.GetUint("kua_id"), pendaftaranID, input, actor)
+	if err != nil {
+		var conflict *services.ScheduleConflict
+		switch {
+		case errors.Is(err, services.ErrJadwalNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pendaftaran tidak ditemukan"})
+		case errors.Is(err, services.ErrJadwalLocked):
+			c.JSON(http.StatusConflict, gin.H{"error": "Jadwal pendaftaran yang sudah selesai, ditolak, atau dibatalkan tidak bisa diubah"})
+		case errors.Is(err, services.ErrJadwalInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrSlotUnavailable):
+			c.JSON(http.StatusConflict, gin.H{"error": "Slot balai KUA pada jadwal tersebut sudah dipesan atau sedang ditahan catin lain", "field": "waktu"})
+		case errors.As(err, &conflict):
+			respondScheduleConflict(c, err)
+		case errors.Is(err, services.ErrKUANotFound):
+			respondKUAError(c, err)
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengubah jadwal nikah"})
+		}
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"message": message,
+		"data":    change,
+	})
 }
 
 // GetKalenderKetersediaan menampilkan kalender ketersediaan untuk pendaftaran nikah
@@ -730,24 +1670,53 @@ func GetKalenderKetersediaan(c *gin.Context) {
 		return
 	}
 
-	// Hitung tanggal awal dan akhir bulan
-	awalBulan := time.Date(tahunInt, time.Month(bulanInt), 1, 0, 0, 0, 0, time.UTC)
-	akhirBulan := awalBulan.AddDate(0, 1, -1)
-
-	// Query SEMUA pendaftaran nikah pada bulan tersebut (ditampilkan di kalender)
-	var pendaftaran []structs.PendaftaranNikah
-	err = DB.Where("tanggal_nikah >= ? AND tanggal_nikah <= ?",
-		awalBulan, akhirBulan).Find(&pendaftaran).Error
-
+	kua, ok := kuaFromRequest(c)
+	if !ok {
+		return
+	}
+
+	// Hitung tanggal awal dan akhir bulan
+	awalBulan := time.Date(tahunInt, time.Month(bulanInt), 1, 0, 0, 0, 0, time.UTC)
+	akhirBulan := awalBulan.AddDate(0, 1, -1)
+
+	// Query SEMUA pendaftaran nikah pada bulan tersebut (ditampilkan di kalender),
+	// kecuali yang ditolak atau dibatalkan karena slotnya sudah dilepas
+	var pendaftaran []structs.PendaftaranNikah
+	err = DB.Scopes(services.TenantScope(kua.ID)).Where("tanggal_nikah >= ? AND tanggal_nikah <= ? AND status_pendaftaran NOT IN ?",
+		awalBulan, akhirBulan, []string{structs.StatusPendaftaranDitolak, structs.StatusPendaftaranDibatalkan}).Find(&pendaftaran).Error
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pendaftaran"})
+		return
+	}
+
+	// Hitung kapasitas per hari sesuai aturan penjadwalan KUA
+	// Nikah di KUA: 1 per slot waktu (tidak bisa bersamaan)
+	// Nikah di luar KUA: tidak dibatasi (semua penghulu aktif bisa bersamaan)
+	aturan := services.RulesForKUA(kua)
+	kapasitasPerHari := aturan.KapasitasKUAHarian
+	jumlahPenghulu := services.NewKUAService(DB).CountActivePenghulu(kua.ID)
+
+	// Hari libur nasional, cuti bersama, dan penutupan KUA pada bulan tersebut
+	hariLibur, err := services.NewHolidayService(DB).ForRange(kua.ID, awalBulan, akhirBulan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data hari libur"})
+		return
+	}
+
+	// Beban balai KUA per tanggal (pendaftaran "Di KUA" aktif dan penahanan slot), sama dengan kuota penugasan
+	bebanKUA, err := services.KUADailyLoads(DB, kua.ID, awalBulan, akhirBulan, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pendaftaran"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung kuota harian KUA"})
 		return
 	}
 
-	// Hitung kapasitas per hari: maksimal 9 pernikahan di KUA per hari
-	// Nikah di KUA: 1 per slot waktu (tidak bisa bersamaan)
-	// Nikah di luar KUA: tidak dibatasi (4 penghulu bisa bersamaan)
-	kapasitasPerHari := 9 // Total maksimal 9 pernikahan di KUA per hari
+	// Jumlah catin di daftar tunggu per tanggal (untuk tanggal yang penuh)
+	daftarTunggu, err := services.NewWaitlistService(DB).CountDates(kua.ID, awalBulan, akhirBulan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data daftar tunggu"})
+		return
+	}
 
 	// Buat map untuk menghitung jumlah per hari dan kategori warna
 	totalPerHari := make(map[string]int)
@@ -803,13 +1772,25 @@ func GetKalenderKetersediaan(c *gin.Context) {
 		var status string
 		var tersedia bool
 		var sisaKuota int
+		var liburInfo interface{}
 
 		if tanggalTime.Before(now.Truncate(24 * time.Hour)) {
 			// Tanggal sudah lewat
 			status = "Terlewat"
 			tersedia = false
 			sisaKuota = 0
-		} else if jumlahNikahKUA >= kapasitasPerHari {
+		} else if libur, ok := hariLibur[tanggalStr]; ok {
+			// Hari libur nasional / penutupan KUA
+			status = "Libur"
+			tersedia = false
+			sisaKuota = 0
+			liburInfo = libur
+		} else if aturan.IsClosedDay(tanggalTime) {
+			// KUA tidak melayani nikah di hari ini
+			status = "Tutup"
+			tersedia = false
+			sisaKuota = 0
+		} else if bebanKUA[tanggalStr] >= kapasitasPerHari {
 			// Sudah penuh untuk nikah di KUA
 			status = "Penuh"
 			tersedia = false
@@ -818,23 +1799,25 @@ func GetKalenderKetersediaan(c *gin.Context) {
 			// Masih tersedia
 			status = "Tersedia"
 			tersedia = true
-			sisaKuota = kapasitasPerHari - jumlahNikahKUA
+			sisaKuota = kapasitasPerHari - bebanKUA[tanggalStr]
 		}
 
 		// Tambahkan ke kalender
 		kalender = append(kalender, map[string]interface{}{
-			"tanggal":            tanggal,
-			"tanggal_str":        tanggalStr,
-			"status":             status,
-			"tersedia":           tersedia,
-			"jumlah_nikah_total": jumlahNikahTotal,
-			"jumlah_nikah_kua":   jumlahNikahKUA,
-			"jumlah_nikah_luar":  jumlahNikahLuarKUA,
-			"kuning_count":       kuningCount,
-			"hijau_count":        hijauCount,
-			"warna":              warnaHari,
-			"sisa_kuota_kua":     sisaKuota,
-			"kapasitas_kua":      kapasitasPerHari,
+			"tanggal":              tanggal,
+			"tanggal_str":          tanggalStr,
+			"status":               status,
+			"tersedia":             tersedia,
+			"jumlah_nikah_total":   jumlahNikahTotal,
+			"jumlah_nikah_kua":     jumlahNikahKUA,
+			"jumlah_nikah_luar":    jumlahNikahLuarKUA,
+			"kuning_count":         kuningCount,
+			"hijau_count":          hijauCount,
+			"warna":                warnaHari,
+			"sisa_kuota_kua":       sisaKuota,
+			"kapasitas_kua":        kapasitasPerHari,
+			"hari_libur":           liburInfo,
+			"jumlah_daftar_tunggu": daftarTunggu[tanggalStr],
 		})
 	}
 
@@ -842,19 +1825,23 @@ func GetKalenderKetersediaan(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Kalender ketersediaan berhasil diambil",
 		"data": gin.H{
+			"kua_id":           kua.ID,
+			"kua_nama":         kua.Nama,
 			"bulan":            bulanInt,
 			"tahun":            tahunInt,
-			"nama_bulan":       awalBulan.Month().String(),
+			"nama_bulan":       locale.Bulan(awalBulan.Month()),
 			"kapasitas_harian": kapasitasPerHari,
 			"penghulu_info": gin.H{
-				"total_penghulu":         4,
-				"penghulu_aktif":         4,
-				"penghulu_cadangan":      0,
-				"slot_waktu_per_hari":    9,
-				"nikah_per_slot":         4,
-				"total_kapasitas_harian": kapasitasPerHari,
+				"total_penghulu":          jumlahPenghulu,
+				"penghulu_aktif":          jumlahPenghulu,
+				"penghulu_cadangan":       0,
+				"slot_waktu_per_hari":     len(aturan.TimeSlots()),
+				"nikah_per_slot":          jumlahPenghulu,
+				"maks_nikah_per_penghulu": aturan.MaksNikahPenghuluHarian,
+				"total_kapasitas_harian":  kapasitasPerHari,
 			},
-			"kalender": kalender,
+			"aturan_penjadwalan": aturan,
+			"kalender":           kalender,
 		},
 	})
 }
@@ -874,9 +1861,14 @@ func GetKalenderTanggalDetail(c *gin.Context) {
 		return
 	}
 
+	kua, ok := kuaFromRequest(c)
+	if !ok {
+		return
+	}
+
 	// Ambil semua pendaftaran di tanggal tsb
 	var pendaftaran []structs.PendaftaranNikah
-	if err := DB.Where("DATE(tanggal_nikah) = ?", tanggal.Format("2006-01-02")).Order("waktu_nikah ASC").Find(&pendaftaran).Error; err != nil {
+	if err := DB.Scopes(services.TenantScope(kua.ID)).Where("DATE(tanggal_nikah) = ?", tanggal.Format("2006-01-02")).Order("waktu_nikah ASC").Find(&pendaftaran).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pendaftaran"})
 		return
 	}
@@ -921,6 +1913,7 @@ func GetKalenderTanggalDetail(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Detail kalender berhasil diambil",
 		"data": gin.H{
+			"kua_id":  kua.ID,
 			"tanggal": tanggalStr,
 			"items":   entries,
 		},
@@ -945,10 +1938,23 @@ func GetKetersediaanTanggal(c *gin.Context) {
 		return
 	}
 
-	// Query pendaftaran nikah untuk tanggal tersebut
+	kua, ok := kuaFromRequest(c)
+	if !ok {
+		return
+	}
+
+	aturan := services.RulesForKUA(kua)
+
+	libur, err := services.NewHolidayService(DB).Get(kua.ID, tanggal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data hari libur"})
+		return
+	}
+
+	// Query pendaftaran nikah yang sudah terjadwal untuk tanggal tersebut
 	var pendaftaran []structs.PendaftaranNikah
-	err = DB.Where("DATE(tanggal_nikah) = ? AND status_pendaftaran = ?",
-		tanggal.Format("2006-01-02"), "Disetujui").Find(&pendaftaran).Error
+	err = DB.Scopes(services.TenantScope(kua.ID)).Where("DATE(tanggal_nikah) = ? AND status_pendaftaran IN ?",
+		tanggal.Format("2006-01-02"), services.ScheduledStatuses).Find(&pendaftaran).Error
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pendaftaran"})
@@ -956,8 +1962,8 @@ func GetKetersediaanTanggal(c *gin.Context) {
 	}
 
 	// Hitung kapasitas berdasarkan lokasi nikah
-	// Nikah di KUA: maksimal 9 pernikahan per hari (1 per slot)
-	// Nikah di luar KUA: tidak ada batasan (4 penghulu bisa bersamaan)
+	// Nikah di KUA: maksimal sesuai kapasitas harian KUA (1 per slot)
+	// Nikah di luar KUA: tidak ada batasan (semua penghulu aktif bisa bersamaan)
 	var kapasitasPerHari int
 	var jumlahNikah int
 
@@ -973,9 +1979,8 @@ func GetKetersediaanTanggal(c *gin.Context) {
 		}
 	}
 
-	// Kapasitas untuk nikah di KUA: 9 per hari (1 per slot waktu)
-	kapasitasKUA := 9
-	kapasitasPerHari = kapasitasKUA
+	// Kapasitas untuk nikah di KUA sesuai aturan penjadwalan KUA (1 per slot waktu)
+	kapasitasPerHari = aturan.KapasitasKUAHarian
 	jumlahNikah = nikahDiKUA // Hanya hitung nikah di KUA untuk kapasitas
 	sisaKuota := kapasitasPerHari - jumlahNikah
 
@@ -983,14 +1988,32 @@ func GetKetersediaanTanggal(c *gin.Context) {
 	var status string
 	var tersedia bool
 
-	if jumlahNikah >= kapasitasPerHari {
+	if libur != nil {
+		status = "Libur"
+		tersedia = false
+		sisaKuota = 0
+	} else if aturan.IsClosedDay(tanggal) {
+		status = "Tutup"
+		tersedia = false
+		sisaKuota = 0
+	} else if jumlahNikah >= kapasitasPerHari {
 		status = "Penuh"
 		tersedia = false
+		if sisaKuota < 0 {
+			sisaKuota = 0
+		}
 	} else {
 		status = "Tersedia"
 		tersedia = true
 	}
 
+	// Status slot balai KUA (termasuk slot yang sedang ditahan catin)
+	slots, err := services.NewSlotService(DB).ListDay(kua, tanggal, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data slot nikah"})
+		return
+	}
+
 	// Buat detail jadwal untuk tanggal tersebut
 	jadwalDetail := make([]map[string]interface{}, 0)
 	for _, p := range pendaftaran {
@@ -1006,6 +2029,7 @@ func GetKetersediaanTanggal(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Detail ketersediaan tanggal berhasil diambil",
 		"data": gin.H{
+			"kua_id":            kua.ID,
 			"tanggal":           tanggalParam,
 			"status":            status,
 			"tersedia":          tersedia,
@@ -1014,12 +2038,113 @@ func GetKetersediaanTanggal(c *gin.Context) {
 			"total_nikah":       len(pendaftaran),
 			"sisa_kuota_kua":    sisaKuota,
 			"kapasitas_kua":     kapasitasPerHari,
+			"jam_layanan":       gin.H{"mulai": aturan.JamMulai, "selesai": aturan.JamSelesai},
+			"slot_kua":          slots,
+			"hari_libur":        libur,
 			"keterangan":        "Kapasitas hanya berlaku untuk nikah di KUA. Nikah di luar KUA tidak dibatasi.",
 			"jadwal_detail":     jadwalDetail,
 		},
 	})
 }
 
+// GetSlotNikah menampilkan slot waktu nikah di KUA untuk tanggal tertentu (?tanggal=YYYY-MM-DD&kua_id=)
+func GetSlotNikah(c *gin.Context) {
+	tanggal, err := time.Parse("2006-01-02", c.Query("tanggal"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parameter tanggal wajib (YYYY-MM-DD)"})
+		return
+	}
+
+	kua, ok := kuaFromRequest(c)
+	if !ok {
+		return
+	}
+
+	slots, err := services.NewSlotService(DB).ListDay(kua, tanggal, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data slot nikah"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Slot nikah berhasil diambil",
+		"data": gin.H{
+			"kua_id":  kua.ID,
+			"tanggal": tanggal.Format("2006-01-02"),
+			"slot":    slots,
+		},
+	})
+}
+
+// HoldSlotNikah menahan slot nikah di KUA sementara catin mengisi formulir pendaftaran
+func HoldSlotNikah(c *gin.Context) {
+	var input struct {
+		KuaID   uint   `json:"kua_id"`
+		Tanggal string `json:"tanggal" binding:"required"`
+		Waktu   string `json:"waktu" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field tanggal dan waktu diperlukan"})
+		return
+	}
+
+	tanggal, err := time.Parse("2006-01-02", input.Tanggal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format tanggal tidak valid (YYYY-MM-DD)"})
+		return
+	}
+
+	kua, err := services.NewKUAService(DB).Resolve(input.KuaID, "", "", c.GetUint("kua_id"))
+	if err != nil {
+		respondKUAError(c, err)
+		return
+	}
+
+	slot, err := services.NewSlotService(DB).Hold(kua, tanggal, input.Waktu, c.GetString("user_id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSlotInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrSlotUnavailable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menahan slot nikah"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Slot berhasil ditahan. Selesaikan pendaftaran sebelum waktu penahanan habis",
+		"data": gin.H{
+			"hold_id":        slot.ID,
+			"kua_id":         slot.Kua_id,
+			"tanggal":        slot.Tanggal,
+			"waktu":          slot.Waktu,
+			"ditahan_sampai": slot.Kedaluwarsa_pada,
+		},
+	})
+}
+
+// ReleaseSlotNikah melepas slot yang sedang ditahan user
+func ReleaseSlotNikah(c *gin.Context) {
+	holdID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID penahanan tidak valid"})
+		return
+	}
+
+	if err := services.NewSlotService(DB).Release(uint(holdID), c.GetString("user_id")); err != nil {
+		if errors.Is(err, services.ErrSlotHoldNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal melepas slot nikah"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Slot berhasil dilepas"})
+}
+
 // GetPenghuluJadwal menampilkan jadwal penghulu untuk tanggal tertentu
 func GetPenghuluJadwal(c *gin.Context) {
 	tanggalParam := c.Param("tanggal")
@@ -1038,18 +2163,33 @@ func GetPenghuluJadwal(c *gin.Context) {
 		return
 	}
 
-	// Query semua penghulu yang aktif
+	kua, ok := kuaFromRequest(c)
+	if !ok {
+		return
+	}
+
+	// Query semua penghulu yang aktif di KUA
 	var penghulu []structs.Penghulu
-	err = DB.Where("status = ?", "Aktif").Find(&penghulu).Error
+	err = DB.Scopes(services.TenantScope(kua.ID)).Where("status = ?", "Aktif").Find(&penghulu).Error
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data penghulu"})
 		return
 	}
 
-	// Query pendaftaran nikah untuk tanggal tersebut
+	aturan := services.RulesForKUA(kua)
+	maksPerPenghulu := aturan.MaksNikahPenghuluHarian
+
+	// Cuti, sakit, tugas luar, dan blokir jadwal yang disetujui pada tanggal tersebut
+	ketidaksediaan, err := services.NewPenghuluAvailabilityService(DB).ForDate(kua.ID, tanggal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data ketidaksediaan penghulu"})
+		return
+	}
+
+	// Query pendaftaran nikah yang sudah terjadwal untuk tanggal tersebut
 	var pendaftaran []structs.PendaftaranNikah
-	err = DB.Where("DATE(tanggal_nikah) = ? AND status_pendaftaran = ?",
-		tanggal.Format("2006-01-02"), "Disetujui").Find(&pendaftaran).Error
+	err = DB.Scopes(services.TenantScope(kua.ID)).Where("DATE(tanggal_nikah) = ? AND status_pendaftaran IN ?",
+		tanggal.Format("2006-01-02"), services.ScheduledStatuses).Find(&pendaftaran).Error
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pendaftaran"})
@@ -1058,6 +2198,7 @@ func GetPenghuluJadwal(c *gin.Context) {
 
 	// Buat map untuk menghitung jadwal per penghulu
 	jadwalPerPenghulu := make(map[uint][]map[string]interface{})
+	pendaftaranPerPenghulu := make(map[uint][]structs.PendaftaranNikah)
 
 	// Inisialisasi jadwal untuk semua penghulu
 	for _, p := range penghulu {
@@ -1068,7 +2209,9 @@ func GetPenghuluJadwal(c *gin.Context) {
 	for _, pendaftaran := range pendaftaran {
 		if pendaftaran.Penghulu_id != nil {
 			penghuluID := *pendaftaran.Penghulu_id
+			pendaftaranPerPenghulu[penghuluID] = append(pendaftaranPerPenghulu[penghuluID], pendaftaran)
 			jadwalPerPenghulu[penghuluID] = append(jadwalPerPenghulu[penghuluID], map[string]interface{}{
+				"pendaftaran_id":    pendaftaran.ID,
 				"nomor_pendaftaran": pendaftaran.Nomor_pendaftaran,
 				"waktu_nikah":       pendaftaran.Waktu_nikah,
 				"tempat_nikah":      pendaftaran.Tempat_nikah,
@@ -1081,50 +2224,140 @@ func GetPenghuluJadwal(c *gin.Context) {
 
 	// Buat response data penghulu dengan jadwal mereka
 	penghuluJadwal := make([]map[string]interface{}, 0)
+	penghuluTersedia := 0
+	totalKonflikPerjalanan := 0
+	estimator := services.NewTravelEstimator(aturan)
 	for _, p := range penghulu {
 		jadwal := jadwalPerPenghulu[p.ID]
 		jumlahJadwal := len(jadwal)
-		sisaKuota := 3 - jumlahJadwal // Maksimal 3 nikah per penghulu per hari
+		sisaKuota := maksPerPenghulu - jumlahJadwal // Maksimal nikah per penghulu per hari sesuai aturan KUA
+		if sisaKuota < 0 {
+			sisaKuota = 0
+		}
+
+		// Penghulu yang tidak tersedia sepanjang hari tidak bisa menerima jadwal baru
+		tidakTersedia := ketidaksediaan[p.ID]
+		sepanjangHari := false
+		for i := range tidakTersedia {
+			if services.UnavailabilityFullDay(&tidakTersedia[i]) {
+				sepanjangHari = true
+			}
+		}
+		if tidakTersedia == nil {
+			tidakTersedia = []structs.KetidaksediaanPenghulu{}
+		}
 
 		var status string
-		if jumlahJadwal >= 3 {
+		if sepanjangHari {
+			status = "Tidak Tersedia"
+			sisaKuota = 0
+		} else if jumlahJadwal >= maksPerPenghulu {
 			status = "Penuh"
 		} else if jumlahJadwal > 0 {
 			status = "Sebagian"
 		} else {
 			status = "Kosong"
 		}
+		if !sepanjangHari {
+			penghuluTersedia++
+		}
+
+		// Perjalanan antar akad berurutan; yang tidak layak berarti penghulu tidak sempat tiba tepat waktu
+		perjalanan := estimator.PlanTravel(aturan, kua, pendaftaranPerPenghulu[p.ID])
+		konflikPerjalanan := 0
+		for _, leg := range perjalanan {
+			if !leg.Layak {
+				konflikPerjalanan++
+			}
+		}
+		totalKonflikPerjalanan += konflikPerjalanan
 
 		penghuluJadwal = append(penghuluJadwal, map[string]interface{}{
-			"id":            p.ID,
-			"nama":          p.Nama_lengkap,
-			"status":        status,
-			"jumlah_jadwal": jumlahJadwal,
-			"sisa_kuota":    sisaKuota,
-			"maksimal":      3,
-			"jadwal":        jadwal,
+			"id":                 p.ID,
+			"nama":               p.Nama_lengkap,
+			"status":             status,
+			"jumlah_jadwal":      jumlahJadwal,
+			"sisa_kuota":         sisaKuota,
+			"maksimal":           maksPerPenghulu,
+			"jadwal":             jadwal,
+			"ketidaksediaan":     tidakTersedia,
+			"perjalanan":         perjalanan,
+			"konflik_perjalanan": konflikPerjalanan,
 		})
 	}
 
-	// Hitung total kapasitas dan sisa
-	totalKapasitas := len(penghulu) * 3
+	// Hitung total kapasitas dan sisa (hanya penghulu yang tersedia)
+	totalKapasitas := penghuluTersedia * maksPerPenghulu
 	totalTerisi := len(pendaftaran)
 	totalSisa := totalKapasitas - totalTerisi
+	if totalSisa < 0 {
+		totalSisa = 0
+	}
 
 	// Response
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Jadwal penghulu berhasil diambil",
 		"data": gin.H{
-			"tanggal":         tanggalParam,
-			"total_penghulu":  len(penghulu),
-			"total_kapasitas": totalKapasitas,
-			"total_terisi":    totalTerisi,
-			"total_sisa":      totalSisa,
-			"penghulu":        penghuluJadwal,
+			"kua_id":             kua.ID,
+			"tanggal":            tanggalParam,
+			"total_penghulu":     len(penghulu),
+			"penghulu_tersedia":  penghuluTersedia,
+			"total_kapasitas":    totalKapasitas,
+			"total_terisi":       totalTerisi,
+			"total_sisa":         totalSisa,
+			"konflik_perjalanan": totalKonflikPerjalanan,
+			"penghulu":           penghuluJadwal,
 		},
 	})
 }
 
+// kuaFromRequest menentukan KUA untuk endpoint kalender/ketersediaan:
+// parameter ?kua_id=, KUA user yang login, lalu satu-satunya KUA aktif
+func kuaFromRequest(c *gin.Context) (*structs.KUA, bool) {
+	var kuaID uint
+	if raw := c.Query("kua_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kua_id tidak valid"})
+			return nil, false
+		}
+		kuaID = uint(id)
+	}
+
+	kua, err := services.NewKUAService(DB).Resolve(kuaID, "", "", c.GetUint("kua_id"))
+	if err != nil {
+		respondKUAError(c, err)
+		return nil, false
+	}
+	return kua, true
+}
+
+// respondKUAError memetakan error KUAService ke response HTTP
+func respondKUAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrKUARequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "KUA harus dipilih (parameter kua_id). Daftar KUA: GET /kua"})
+	case errors.Is(err, services.ErrKUANotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "KUA tidak ditemukan"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data KUA"})
+	}
+}
+
+// respondScheduleConflict memetakan error pengecekan aturan penjadwalan ke response HTTP
+func respondScheduleConflict(c *gin.Context, err error) {
+	var conflict *services.ScheduleConflict
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   conflict.Message,
+			"type":    conflict.Type,
+			"details": conflict.Details,
+		})
+		return
+	}
+	respondKUAError(c, err)
+}
+
 // AssignPenghulu mengassign penghulu untuk pendaftaran nikah (hanya kepala KUA)
 func AssignPenghulu(c *gin.Context) {
 	pendaftaranID := c.Param("id")
@@ -1148,106 +2381,50 @@ func AssignPenghulu(c *gin.Context) {
 
 	// Cek apakah pendaftaran ada
 	var pendaftaran structs.PendaftaranNikah
-	if err := DB.First(&pendaftaran, pendaftaranID).Error; err != nil {
+	if err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).First(&pendaftaran, pendaftaranID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Pendaftaran tidak ditemukan"})
 		return
 	}
 
-	// Cek apakah pendaftaran sudah siap untuk assign penghulu
-	if pendaftaran.Status_pendaftaran != "Menunggu Penugasan" {
+	// Cek apakah pendaftaran sudah siap untuk assign penghulu (sesuai tabel transisi status)
+	if _, ok := services.FindTransition(pendaftaran.Status_pendaftaran, structs.StatusPendaftaranMenungguVerifikasiPenghulu); !ok {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Pendaftaran harus dalam status 'Menunggu Penugasan' untuk assign penghulu"})
 		return
 	}
 
 	// Cek apakah penghulu ada dan aktif
 	var penghulu structs.Penghulu
-	if err := DB.Where("id = ? AND status = ?", input.PenghuluID, "Aktif").First(&penghulu).Error; err != nil {
+	if err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).Where("id = ? AND status = ?", input.PenghuluID, "Aktif").First(&penghulu).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Penghulu tidak ditemukan atau tidak aktif"})
 		return
 	}
 
-	// Hitung jumlah jadwal penghulu pada tanggal yang sama (tanpa membatasi, hanya untuk peringatan)
-	tanggalNikah := pendaftaran.Tanggal_nikah.Format("2006-01-02")
-	var count int64
-	DB.Model(&structs.PendaftaranNikah{}).Where(
-		"penghulu_id = ? AND DATE(tanggal_nikah) = ? AND status_pendaftaran IN ?",
-		input.PenghuluID,
-		tanggalNikah,
-		[]string{"Menunggu Verifikasi Penghulu", "Menunggu Bimbingan", "Sudah Bimbingan", "Selesai"},
-	).Count(&count)
-
-	// Batasi total pernikahan per hari: maksimal 9 pernikahan per hari
-	var totalPerHari int64
-	DB.Model(&structs.PendaftaranNikah{}).Where(
-		"DATE(tanggal_nikah) = ? AND status_pendaftaran IN ?",
-		tanggalNikah,
-		[]string{"Menunggu Verifikasi Penghulu", "Menunggu Bimbingan", "Sudah Bimbingan", "Selesai"},
-	).Count(&totalPerHari)
-	if totalPerHari >= 9 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Kuota pernikahan harian penuh",
-			"details": gin.H{"tanggal": tanggalNikah, "maksimal": 9},
-		})
+	// Cek aturan penjadwalan KUA: kuota harian, maksimal nikah per penghulu, dan jeda minimal
+	if _, err := time.Parse("15:04", pendaftaran.Waktu_nikah); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format waktu nikah tidak valid (HH:MM)"})
 		return
 	}
-
-	// Cek konflik waktu - pastikan tidak ada jadwal yang berbarengan
-	var existingJadwal []structs.PendaftaranNikah
-	DB.Where(
-		"penghulu_id = ? AND DATE(tanggal_nikah) = ? AND status_pendaftaran IN ?",
-		input.PenghuluID,
-		tanggalNikah,
-		[]string{"Menunggu Verifikasi Penghulu", "Menunggu Bimbingan", "Sudah Bimbingan", "Selesai"},
-	).Find(&existingJadwal)
-
-	// Parse waktu nikah yang akan di-assign
-	waktuNikahBaru, err := time.Parse("15:04", pendaftaran.Waktu_nikah)
+	tanggalNikah := pendaftaran.Tanggal_nikah.Format("2006-01-02")
+	aturan, err := services.NewSchedulingRuleService(DB).ForKUA(pendaftaran.Kua_id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Format waktu nikah tidak valid (HH:MM)"})
+		respondKUAError(c, err)
 		return
 	}
 
-	// Cek konflik dengan jadwal yang sudah ada
-	for _, jadwal := range existingJadwal {
-		waktuJadwalLama, err := time.Parse("15:04", jadwal.Waktu_nikah)
-		if err != nil {
-			continue // Skip jika format waktu tidak valid
-		}
-
-		// Hitung selisih waktu dalam menit
-		selisihMenit := int(waktuNikahBaru.Sub(waktuJadwalLama).Minutes())
-		if selisihMenit < 0 {
-			selisihMenit = -selisihMenit
-		}
-
-		// Jika selisih kurang dari 1 jam (60 menit), dianggap konflik
-		// Asumsi: setiap slot berjarak 1 jam, maksimal 4 pernikahan bersamaan per slot
-		if selisihMenit < 60 {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Konflik jadwal! Penghulu sudah memiliki jadwal pada waktu yang berdekatan",
-				"details": gin.H{
-					"waktu_konflik":   jadwal.Waktu_nikah,
-					"tempat":          jadwal.Tempat_nikah,
-					"selisih_menit":   selisihMenit,
-					"minimal_selisih": "60 menit (1 jam)",
-				},
-			})
+	// Update pendaftaran dengan penghulu dan jalankan transisi status; aturan penjadwalan diperiksa
+	// ulang di dalam transaksi yang sama dengan penghulu terkunci
+	actor := services.TransitionActor{UserID: userID.(string), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	count, err := services.AssignPenghulu(DB, &pendaftaran, input.PenghuluID, actor)
+	if err != nil {
+		var conflict *services.ScheduleConflict
+		if errors.As(err, &conflict) {
+			respondScheduleConflict(c, err)
 			return
 		}
-	}
-
-	// Update pendaftaran dengan penghulu
-	now := time.Now()
-	pendaftaran.Penghulu_id = &input.PenghuluID
-	pendaftaran.Penghulu_assigned_by = userID.(string)
-	pendaftaran.Penghulu_assigned_at = &now
-	pendaftaran.Status_pendaftaran = "Menunggu Verifikasi Penghulu"
-	pendaftaran.Updated_at = now
-
-	if err := DB.Save(&pendaftaran).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengassign penghulu"})
+		respondTransitionError(c, err, "Gagal mengassign penghulu")
 		return
 	}
+	now := *pendaftaran.Penghulu_assigned_at
 
 	// Kirim notifikasi otomatis setelah penghulu berhasil diassign
 	notificationService := services.NewNotificationService(DB)
@@ -1257,10 +2434,10 @@ func AssignPenghulu(c *gin.Context) {
 		fmt.Printf("Gagal mengirim notifikasi penugasan penghulu: %v\n", err)
 	}
 
-	// Overload warning (non-blocking)
+	// Peringatan jika penghulu mencapai batas jadwal harian (non-blocking)
 	projectedCount := count + 1
 	var warning string
-	if projectedCount >= 3 {
+	if projectedCount >= aturan.MaksNikahPenghuluHarian {
 		warning = fmt.Sprintf("Peringatan: penghulu ini memiliki %d jadwal pada tanggal %s", projectedCount, tanggalNikah)
 	}
 
@@ -1278,6 +2455,106 @@ func AssignPenghulu(c *gin.Context) {
 	})
 }
 
+// GetRekomendasiPenghulu memberi peringkat penghulu untuk pendaftaran berdasarkan ketersediaan, jadwal harian,
+// jeda dan jarak antar akad, serta pemerataan beban bulanan (hanya kepala KUA)
+func GetRekomendasiPenghulu(c *gin.Context) {
+	var pendaftaran structs.PendaftaranNikah
+	if err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).First(&pendaftaran, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pendaftaran tidak ditemukan"})
+		return
+	}
+
+	rekomendasi, err := services.NewPenghuluRecommenderService(DB).Recommend(&pendaftaran)
+	if err != nil {
+		respondKUAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Rekomendasi penghulu berhasil diambil",
+		"data": gin.H{
+			"pendaftaran_id":     pendaftaran.ID,
+			"nomor_pendaftaran":  pendaftaran.Nomor_pendaftaran,
+			"status_pendaftaran": pendaftaran.Status_pendaftaran,
+			"tanggal_nikah":      pendaftaran.Tanggal_nikah.Format("2006-01-02"),
+			"waktu_nikah":        pendaftaran.Waktu_nikah,
+			"tempat_nikah":       pendaftaran.Tempat_nikah,
+			"rekomendasi":        rekomendasi,
+		},
+	})
+}
+
+// PreviewAutoAssignPenghulu menampilkan rencana penugasan otomatis untuk semua pendaftaran
+// "Menunggu Penugasan" tanpa menyimpan apa pun (hanya kepala KUA)
+func PreviewAutoAssignPenghulu(c *gin.Context) {
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	rencana, err := services.NewPenghuluRecommenderService(DB).PreviewAutoAssign(c.GetUint("kua_id"), actor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyusun rencana penugasan penghulu"})
+		return
+	}
+
+	// Rencana yang bisa dikirim ulang ke POST /pendaftaran/auto-assign-penghulu untuk dikonfirmasi
+	penugasan := make([]services.AutoAssignItem, 0)
+	for _, r := range rencana {
+		if r.Berhasil {
+			penugasan = append(penugasan, services.AutoAssignItem{PendaftaranID: r.PendaftaranID, PenghuluID: r.PenghuluID})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pratinjau penugasan penghulu otomatis",
+		"data": gin.H{
+			"total":          len(rencana),
+			"dapat_ditugasi": len(penugasan),
+			"rencana":        rencana,
+			"penugasan":      penugasan,
+		},
+	})
+}
+
+// ConfirmAutoAssignPenghulu menjalankan rencana penugasan otomatis hasil pratinjau (boleh diubah).
+// Setiap penugasan divalidasi ulang; yang gagal dilaporkan tanpa membatalkan yang lain (hanya kepala KUA).
+func ConfirmAutoAssignPenghulu(c *gin.Context) {
+	var input struct {
+		Penugasan []services.AutoAssignItem `json:"penugasan" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || len(input.Penugasan) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field penugasan (daftar pendaftaran_id dan penghulu_id) diperlukan"})
+		return
+	}
+
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	hasil, err := services.NewPenghuluRecommenderService(DB).ConfirmAutoAssign(c.GetUint("kua_id"), input.Penugasan, actor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menjalankan penugasan penghulu otomatis"})
+		return
+	}
+
+	// Kirim notifikasi penugasan untuk setiap penugasan yang berhasil
+	berhasil := 0
+	notificationService := services.NewNotificationService(DB)
+	for _, h := range hasil {
+		if !h.Berhasil {
+			continue
+		}
+		berhasil++
+		if err := notificationService.SendPenghuluAssignmentNotification(h.PendaftaranID, h.PenghuluUserID); err != nil {
+			fmt.Printf("Gagal mengirim notifikasi penugasan penghulu: %v\n", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("%d dari %d penugasan berhasil", berhasil, len(hasil)),
+		"data": gin.H{
+			"total":    len(hasil),
+			"berhasil": berhasil,
+			"gagal":    len(hasil) - berhasil,
+			"hasil":    hasil,
+		},
+	})
+}
+
 // ChangePenghulu mengubah penghulu untuk pendaftaran nikah (hanya kepala KUA)
 func ChangePenghulu(c *gin.Context) {
 	pendaftaranID := c.Param("id")
@@ -1301,91 +2578,86 @@ func ChangePenghulu(c *gin.Context) {
 
 	// Cek apakah pendaftaran ada
 	var pendaftaran structs.PendaftaranNikah
-	if err := DB.First(&pendaftaran, pendaftaranID).Error; err != nil {
+	if err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).First(&pendaftaran, pendaftaranID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Pendaftaran tidak ditemukan"})
 		return
 	}
 
-	// Cek apakah pendaftaran sudah siap untuk mengubah penghulu
-	if pendaftaran.Status_pendaftaran != "Menunggu Penugasan" {
+	// Cek apakah pendaftaran sudah siap untuk mengubah penghulu.
+	// Jadwal yang bentrok dengan cuti/ketidaksediaan penghulu boleh diganti selama pendaftaran belum berstatus akhir.
+	bentrokCuti := pendaftaran.Konflik_ketidaksediaan_id != nil && !services.IsFinalStatus(pendaftaran.Status_pendaftaran)
+	if pendaftaran.Status_pendaftaran != "Menunggu Penugasan" && !bentrokCuti {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Pendaftaran harus dalam status 'Menunggu Penugasan' untuk mengubah penghulu"})
 		return
 	}
 
 	// Cek apakah penghulu ada dan aktif
 	var penghulu structs.Penghulu
-	if err := DB.Where("id = ? AND status = ?", input.PenghuluID, "Aktif").First(&penghulu).Error; err != nil {
+	if err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).Where("id = ? AND status = ?", input.PenghuluID, "Aktif").First(&penghulu).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Penghulu tidak ditemukan atau tidak aktif"})
 		return
 	}
 
-	// Cek apakah penghulu baru sudah memiliki 3 jadwal pada tanggal yang sama
-	tanggalNikah := pendaftaran.Tanggal_nikah.Format("2006-01-02")
-	var count int64
-	DB.Model(&structs.PendaftaranNikah{}).Where("penghulu_id = ? AND DATE(tanggal_nikah) = ? AND status_pendaftaran = ? AND id != ?",
-		input.PenghuluID, tanggalNikah, "Disetujui", pendaftaranID).Count(&count)
-
-	if count >= 3 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Penghulu baru sudah memiliki 3 jadwal pada tanggal tersebut"})
-		return
-	}
-
-	// Cek konflik waktu - pastikan tidak ada jadwal yang berbarengan
-	var existingJadwal []structs.PendaftaranNikah
-	DB.Where("penghulu_id = ? AND DATE(tanggal_nikah) = ? AND status_pendaftaran = ? AND id != ?",
-		input.PenghuluID, tanggalNikah, "Disetujui", pendaftaranID).Find(&existingJadwal)
-
-	// Parse waktu nikah yang akan di-assign
-	waktuNikahBaru, err := time.Parse("15:04", pendaftaran.Waktu_nikah)
-	if err != nil {
+	// Cek aturan penjadwalan KUA untuk penghulu baru
+	if _, err := time.Parse("15:04", pendaftaran.Waktu_nikah); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Format waktu nikah tidak valid (HH:MM)"})
 		return
 	}
 
-	// Cek konflik dengan jadwal yang sudah ada
-	for _, jadwal := range existingJadwal {
-		waktuJadwalLama, err := time.Parse("15:04", jadwal.Waktu_nikah)
-		if err != nil {
-			continue // Skip jika format waktu tidak valid
-		}
-
-		// Hitung selisih waktu dalam menit
-		selisihMenit := int(waktuNikahBaru.Sub(waktuJadwalLama).Minutes())
-		if selisihMenit < 0 {
-			selisihMenit = -selisihMenit
-		}
-
-		// Jika selisih kurang dari 2 jam (120 menit), dianggap konflik
-		// Asumsi: setiap nikah membutuhkan minimal 2 jam (persiapan + akad + dokumentasi)
-		if selisihMenit < 120 {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Konflik jadwal! Penghulu baru sudah memiliki jadwal pada waktu yang berdekatan",
-				"details": gin.H{
-					"waktu_konflik":   jadwal.Waktu_nikah,
-					"tempat":          jadwal.Tempat_nikah,
-					"selisih_menit":   selisihMenit,
-					"minimal_selisih": "60 menit (1 jam)",
-				},
-			})
-			return
-		}
-	}
-
 	// Simpan penghulu lama untuk audit
 	penghuluLama := pendaftaran.Penghulu_id
-
-	// Update pendaftaran dengan penghulu baru
 	now := time.Now()
-	pendaftaran.Penghulu_id = &input.PenghuluID
-	pendaftaran.Penghulu_assigned_by = userID.(string)
-	pendaftaran.Penghulu_assigned_at = &now
-	pendaftaran.Updated_at = now
 
-	if err := DB.Save(&pendaftaran).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengubah penghulu"})
+	// Simpan penggantian penghulu beserta riwayatnya (status tidak berubah). Aturan penjadwalan untuk
+	// penghulu baru diperiksa di dalam transaksi dengan pendaftaran dan penghulu terkunci; rute perjalanan
+	// diminta lebih dulu agar server rute tidak dihubungi selama kunci dipegang.
+	services.NewSchedulingRuleService(DB).PrefetchTravel(&pendaftaran, input.PenghuluID)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.LockPendaftaran(tx, &pendaftaran); err != nil {
+			return err
+		}
+		if err := services.LockPenghulu(tx, input.PenghuluID); err != nil {
+			return err
+		}
+		if _, err := services.NewSchedulingRuleService(tx).CheckPenghuluAssignment(&pendaftaran, input.PenghuluID); err != nil {
+			return err
+		}
+
+		// Update pendaftaran dengan penghulu baru
+		penghuluLama = pendaftaran.Penghulu_id
+		if penghuluLama != nil && *penghuluLama != input.PenghuluID {
+			pendaftaran.Penghulu_sebelumnya_id = penghuluLama
+		}
+		pendaftaran.Penghulu_id = &input.PenghuluID
+		pendaftaran.Penghulu_assigned_by = userID.(string)
+		pendaftaran.Penghulu_assigned_at = &now
+		pendaftaran.Updated_at = now
+		pendaftaran.Konflik_ketidaksediaan_id = nil
+
+		if err := tx.Save(&pendaftaran).Error; err != nil {
+			return err
+		}
+		actor := services.TransitionActor{UserID: userID.(string), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+		catatan := fmt.Sprintf("Penghulu diganti menjadi %s", penghulu.Nama_lengkap)
+		return services.RecordStatusHistory(tx, pendaftaran.ID, pendaftaran.Status_pendaftaran, pendaftaran.Status_pendaftaran, structs.RiwayatAksiGantiPenghulu, actor, catatan)
+	})
+	if err != nil {
+		var conflict *services.ScheduleConflict
+		if errors.As(err, &conflict) {
+			respondScheduleConflict(c, err)
+			return
+		}
+		respondTransitionError(c, err, "Gagal mengubah penghulu")
 		return
 	}
 
+	// Penghulu pengganti untuk jadwal yang bentrok dengan cuti perlu tahu penugasan barunya
+	if bentrokCuti {
+		if err := services.NewNotificationService(DB).SendPenghuluAssignmentNotification(pendaftaran.ID, penghulu.User_id); err != nil {
+			fmt.Printf("Gagal mengirim notifikasi penugasan penghulu: %v\n", err)
+		}
+	}
+
 	// Response
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Penghulu berhasil diubah",
@@ -1400,36 +2672,95 @@ func ChangePenghulu(c *gin.Context) {
 	})
 }
 
-// GetPendaftaranBelumAssignPenghulu mendapatkan pendaftaran yang belum di-assign penghulu
-func GetPendaftaranBelumAssignPenghulu(c *gin.Context) {
-	// Query pendaftaran yang sudah siap untuk assign penghulu tapi belum di-assign
+// GetPendaftaranBelumAssignPenghulu mendapatkan pendaftaran yang belum di-assign penghulu
+func GetPendaftaranBelumAssignPenghulu(c *gin.Context) {
+	// Query pendaftaran yang sudah siap untuk assign penghulu tapi belum di-assign
+	var pendaftaran []structs.PendaftaranNikah
+	err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).Where("status_pendaftaran = ? AND penghulu_id IS NULL", "Menunggu Penugasan").
+		Order("tanggal_nikah ASC").Find(&pendaftaran).Error
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pendaftaran"})
+		return
+	}
+
+	// Buat response data
+	pendaftaranData := make([]map[string]interface{}, 0)
+	for _, p := range pendaftaran {
+		pendaftaranData = append(pendaftaranData, map[string]interface{}{
+			"id":                 p.ID,
+			"nomor_pendaftaran":  p.Nomor_pendaftaran,
+			"tanggal_nikah":      p.Tanggal_nikah.Format("2006-01-02"),
+			"waktu_nikah":        p.Waktu_nikah,
+			"tempat_nikah":       p.Tempat_nikah,
+			"alamat_akad":        p.Alamat_akad,
+			"status_pendaftaran": p.Status_pendaftaran,
+			"created_at":         p.Created_at,
+		})
+	}
+
+	// Response
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pendaftaran belum assign penghulu berhasil diambil",
+		"data": gin.H{
+			"total":       len(pendaftaran),
+			"pendaftaran": pendaftaranData,
+		},
+	})
+}
+
+// GetKonflikKetidaksediaanPenghulu mendapatkan jadwal nikah yang bentrok dengan cuti/ketidaksediaan penghulu
+// dan perlu penghulu pengganti (lihat ChangePenghulu)
+func GetKonflikKetidaksediaanPenghulu(c *gin.Context) {
 	var pendaftaran []structs.PendaftaranNikah
-	err := DB.Where("status_pendaftaran = ? AND penghulu_id IS NULL", "Menunggu Penugasan").
+	err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).
+		Where("konflik_ketidaksediaan_id IS NOT NULL AND status_pendaftaran NOT IN ?", services.FinalStatuses).
 		Order("tanggal_nikah ASC").Find(&pendaftaran).Error
-
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pendaftaran"})
 		return
 	}
 
-	// Buat response data
+	// Ambil ketidaksediaan dan nama penghulu untuk setiap konflik
+	ketidaksediaan := make(map[uint]structs.KetidaksediaanPenghulu)
+	namaPenghulu := make(map[uint]string)
+	for _, p := range pendaftaran {
+		id := *p.Konflik_ketidaksediaan_id
+		if _, ok := ketidaksediaan[id]; !ok {
+			var k structs.KetidaksediaanPenghulu
+			DB.First(&k, id)
+			ketidaksediaan[id] = k
+		}
+		if p.Penghulu_id != nil {
+			if _, ok := namaPenghulu[*p.Penghulu_id]; !ok {
+				var penghulu structs.Penghulu
+				DB.First(&penghulu, *p.Penghulu_id)
+				namaPenghulu[*p.Penghulu_id] = penghulu.Nama_lengkap
+			}
+		}
+	}
+
 	pendaftaranData := make([]map[string]interface{}, 0)
 	for _, p := range pendaftaran {
+		var penghuluNama string
+		if p.Penghulu_id != nil {
+			penghuluNama = namaPenghulu[*p.Penghulu_id]
+		}
 		pendaftaranData = append(pendaftaranData, map[string]interface{}{
 			"id":                 p.ID,
 			"nomor_pendaftaran":  p.Nomor_pendaftaran,
 			"tanggal_nikah":      p.Tanggal_nikah.Format("2006-01-02"),
 			"waktu_nikah":        p.Waktu_nikah,
 			"tempat_nikah":       p.Tempat_nikah,
-			"alamat_akad":        p.Alamat_akad,
 			"status_pendaftaran": p.Status_pendaftaran,
-			"created_at":         p.Created_at,
+			"penghulu_id":        p.Penghulu_id,
+			"penghulu_nama":      penghuluNama,
+			"ketidaksediaan":     ketidaksediaan[*p.Konflik_ketidaksediaan_id],
 		})
 	}
 
-	// Response
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Pendaftaran belum assign penghulu berhasil diambil",
+		"message": "Jadwal yang bentrok dengan ketidaksediaan penghulu berhasil diambil",
 		"data": gin.H{
 			"total":       len(pendaftaran),
 			"pendaftaran": pendaftaranData,
@@ -1474,9 +2805,13 @@ func CreateBimbinganPerkawinan(c *gin.Context) {
 		return
 	}
 
-	// Cek apakah sudah ada bimbingan pada tanggal yang sama
+	// Cek apakah sudah ada bimbingan pada tanggal yang sama di KUA ini
+	kuaID := c.GetUint("kua_id")
+	if !bimbinganBukanHariLibur(c, kuaID, tanggal) {
+		return
+	}
 	var existingBimbingan structs.BimbinganPerkawinan
-	if err := DB.Where("DATE(tanggal_bimbingan) = ? AND status = ?",
+	if err := DB.Scopes(services.TenantScope(kuaID)).Where("DATE(tanggal_bimbingan) = ? AND status = ?",
 		tanggal.Format("2006-01-02"), "Aktif").First(&existingBimbingan).Error; err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Sudah ada bimbingan perkawinan pada tanggal tersebut"})
 		return
@@ -1498,6 +2833,7 @@ func CreateBimbinganPerkawinan(c *gin.Context) {
 		Kapasitas:         kapasitas,
 		Status:            "Aktif",
 		Catatan:           input.Catatan,
+		Kua_id:            kuaID,
 		Created_at:        now,
 		Updated_at:        now,
 	}
@@ -1521,6 +2857,23 @@ func CreateBimbinganPerkawinan(c *gin.Context) {
 	})
 }
 
+// bimbinganBukanHariLibur menolak jadwal bimbingan pada hari libur nasional atau penutupan KUA
+func bimbinganBukanHariLibur(c *gin.Context, kuaID uint, tanggal time.Time) bool {
+	libur, err := services.NewHolidayService(DB).Get(kuaID, tanggal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa hari libur"})
+		return false
+	}
+	if libur != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      fmt.Sprintf("Tanggal bimbingan jatuh pada hari libur (%s)", libur.Nama),
+			"hari_libur": libur,
+		})
+		return false
+	}
+	return true
+}
+
 // GetBimbinganPerkawinan mendapatkan daftar bimbingan perkawinan
 func GetBimbinganPerkawinan(c *gin.Context) {
 	// Ambil parameter query
@@ -1550,13 +2903,18 @@ func GetBimbinganPerkawinan(c *gin.Context) {
 		return
 	}
 
+	kua, ok := kuaFromRequest(c)
+	if !ok {
+		return
+	}
+
 	// Hitung tanggal awal dan akhir bulan
 	awalBulan := time.Date(tahunInt, time.Month(bulanInt), 1, 0, 0, 0, 0, time.UTC)
 	akhirBulan := awalBulan.AddDate(0, 1, -1)
 
 	// Query bimbingan perkawinan
 	var bimbingan []structs.BimbinganPerkawinan
-	query := DB.Where("tanggal_bimbingan >= ? AND tanggal_bimbingan <= ?", awalBulan, akhirBulan)
+	query := DB.Scopes(services.TenantScope(kua.ID)).Where("tanggal_bimbingan >= ? AND tanggal_bimbingan <= ?", awalBulan, akhirBulan)
 
 	if status != "" {
 		query = query.Where("status = ?", status)
@@ -1636,7 +2994,7 @@ func UpdateBimbinganPerkawinan(c *gin.Context) {
 	bimbinganID := c.Param("id")
 
 	var bimbingan structs.BimbinganPerkawinan
-	if err := DB.First(&bimbingan, bimbinganID).Error; err != nil {
+	if err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).First(&bimbingan, bimbinganID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Bimbingan perkawinan tidak ditemukan"})
 		return
 	}
@@ -1686,6 +3044,11 @@ func UpdateBimbinganPerkawinan(c *gin.Context) {
 		return
 	}
 
+	// Kapasitas yang dinaikkan ditawarkan ke daftar tunggu; sesi yang dinonaktifkan menutup antreannya
+	if err := services.NewWaitlistService(DB).PromoteBimbingan(bimbingan.ID); err != nil {
+		log.Printf("Gagal mempromosikan daftar tunggu bimbingan: %v", err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Bimbingan perkawinan berhasil diupdate",
 		"data":    bimbingan,
@@ -1720,13 +3083,18 @@ func GetBimbinganKalender(c *gin.Context) {
 		return
 	}
 
+	kua, ok := kuaFromRequest(c)
+	if !ok {
+		return
+	}
+
 	// Hitung tanggal awal dan akhir bulan
 	awalBulan := time.Date(tahunInt, time.Month(bulanInt), 1, 0, 0, 0, 0, time.UTC)
 	akhirBulan := awalBulan.AddDate(0, 1, -1)
 
 	// Query bimbingan perkawinan untuk bulan tersebut
 	var bimbingan []structs.BimbinganPerkawinan
-	err = DB.Where("tanggal_bimbingan >= ? AND tanggal_bimbingan <= ? AND status = ?",
+	err = DB.Scopes(services.TenantScope(kua.ID)).Where("tanggal_bimbingan >= ? AND tanggal_bimbingan <= ? AND status = ?",
 		awalBulan, akhirBulan, "Aktif").Find(&bimbingan).Error
 
 	if err != nil {
@@ -1734,6 +3102,13 @@ func GetBimbinganKalender(c *gin.Context) {
 		return
 	}
 
+	// Hari libur nasional dan penutupan KUA pada bulan tersebut
+	hariLibur, err := services.NewHolidayService(DB).ForRange(kua.ID, awalBulan, akhirBulan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data hari libur"})
+		return
+	}
+
 	// Buat map untuk menghitung jumlah peserta per bimbingan
 	pesertaPerBimbingan := make(map[uint]int64)
 	for _, b := range bimbingan {
@@ -1763,12 +3138,19 @@ func GetBimbinganKalender(c *gin.Context) {
 		var tersedia bool
 		var sisaKuota int
 		var bimbinganInfo map[string]interface{}
+		var liburInfo interface{}
 
 		if tanggalTime.Before(now.Truncate(24 * time.Hour)) {
 			// Tanggal sudah lewat
 			status = "Terlewat"
 			tersedia = false
 			sisaKuota = 0
+		} else if libur, ok := hariLibur[tanggalStr]; ok {
+			// Hari libur nasional / penutupan KUA
+			status = "Libur"
+			tersedia = false
+			sisaKuota = 0
+			liburInfo = libur
 		} else if bimbinganHariIni == nil {
 			// Tidak ada bimbingan
 			if tanggalTime.Weekday() == time.Wednesday {
@@ -1812,6 +3194,7 @@ func GetBimbinganKalender(c *gin.Context) {
 			"tersedia":    tersedia,
 			"sisa_kuota":  sisaKuota,
 			"bimbingan":   bimbinganInfo,
+			"hari_libur":  liburInfo,
 		})
 	}
 
@@ -1821,7 +3204,7 @@ func GetBimbinganKalender(c *gin.Context) {
 		"data": gin.H{
 			"bulan":      bulanInt,
 			"tahun":      tahunInt,
-			"nama_bulan": awalBulan.Month().String(),
+			"nama_bulan": locale.Bulan(awalBulan.Month()),
 			"kalender":   kalender,
 		},
 	})
@@ -1864,6 +3247,12 @@ func DaftarBimbinganPerkawinan(c *gin.Context) {
 		return
 	}
 
+	// Bimbingan harus diselenggarakan KUA yang menangani pendaftaran
+	if bimbingan.Kua_id != pendaftaran.Kua_id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bimbingan perkawinan ini diselenggarakan oleh KUA lain"})
+		return
+	}
+
 	// Cek apakah sudah terdaftar di bimbingan ini
 	var existingDaftar structs.PendaftaranBimbingan
 	if err := DB.Where("pendaftaran_nikah_id = ? AND bimbingan_perkawinan_id = ?", pendaftaran.ID, bimbingan.ID).First(&existingDaftar).Error; err == nil {
@@ -1871,12 +3260,17 @@ func DaftarBimbinganPerkawinan(c *gin.Context) {
 		return
 	}
 
-	// Cek kapasitas
+	// Cek kapasitas, termasuk kursi yang sedang ditawarkan ke catin lain dari daftar tunggu
+	waitlistService := services.NewWaitlistService(DB)
 	var count int64
 	DB.Model(&structs.PendaftaranBimbingan{}).Where("bimbingan_perkawinan_id = ?", bimbingan.ID).Count(&count)
+	ditawarkan, _ := waitlistService.ReservedBimbinganSeats(bimbingan.ID, userID.(string))
 
-	if count >= int64(bimbingan.Kapasitas) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Bimbingan perkawinan sudah penuh"})
+	if count+ditawarkan >= int64(bimbingan.Kapasitas) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":         "Bimbingan perkawinan sudah penuh",
+			"daftar_tunggu": fmt.Sprintf("POST /simnikah/bimbingan/%d/daftar-tunggu untuk masuk daftar tunggu", bimbingan.ID),
+		})
 		return
 	}
 
@@ -1899,6 +3293,11 @@ func DaftarBimbinganPerkawinan(c *gin.Context) {
 
 	// Status sudah "Menunggu Bimbingan" jadi tidak perlu diupdate lagi
 
+	// Tawaran atau antrean daftar tunggu user untuk sesi ini dianggap diterima
+	if err := waitlistService.CompleteBimbingan(bimbingan.ID, userID.(string)); err != nil {
+		log.Printf("Gagal memperbarui daftar tunggu bimbingan: %v", err)
+	}
+
 	// Kirim notifikasi otomatis setelah berhasil mendaftar bimbingan
 	notificationService := services.NewNotificationService(DB)
 	err := notificationService.SendBimbinganNotification(bimbingan.ID, "created")
@@ -1923,9 +3322,9 @@ func DaftarBimbinganPerkawinan(c *gin.Context) {
 func GetBimbinganParticipants(c *gin.Context) {
 	bimbinganID := c.Param("id")
 
-	// Cek apakah bimbingan ada
+	// Cek apakah bimbingan ada di KUA user
 	var bimbingan structs.BimbinganPerkawinan
-	if err := DB.First(&bimbingan, bimbinganID).Error; err != nil {
+	if err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).First(&bimbingan, bimbinganID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Bimbingan perkawinan tidak ditemukan"})
 		return
 	}
@@ -1983,85 +3382,111 @@ func GetBimbinganParticipants(c *gin.Context) {
 func GetStatusFlow(c *gin.Context) {
 	pendaftaranID := c.Param("id")
 
-	// Cek apakah pendaftaran ada
-	var pendaftaran structs.PendaftaranNikah
-	if err := DB.First(&pendaftaran, pendaftaranID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Pendaftaran tidak ditemukan"})
+	// Cek apakah pendaftaran ada dan boleh dilihat user ini
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pendaftaran, err := services.NewAccessPolicy(DB).LoadRegistration(actor, pendaftaranID, services.AccessView)
+	if err != nil {
+		respondAccessError(c, err, "Pendaftaran tidak ditemukan")
 		return
 	}
 
-	// Definisikan alur status sesuai workflow yang baru dan lebih sederhana
-	statusFlow := []map[string]interface{}{
-		{
-			"status":      "Draft",
-			"description": "Data belum lengkap",
-			"completed":   pendaftaran.Status_pendaftaran != "Draft",
-			"current":     pendaftaran.Status_pendaftaran == "Draft",
-			"can_edit":    true,
-		},
-		{
-			"status":      "Menunggu Verifikasi",
-			"description": "Staff verifikasi formulir online",
-			"completed":   pendaftaran.Status_pendaftaran == "Menunggu Pengumpulan Berkas" || pendaftaran.Status_pendaftaran == "Berkas Diterima" || pendaftaran.Status_pendaftaran == "Menunggu Penugasan" || pendaftaran.Status_pendaftaran == "Penghulu Ditugaskan" || pendaftaran.Status_pendaftaran == "Menunggu Bimbingan" || pendaftaran.Status_pendaftaran == "Sudah Bimbingan" || pendaftaran.Status_pendaftaran == "Selesai",
-			"current":     pendaftaran.Status_pendaftaran == "Menunggu Verifikasi",
-			"can_edit":    false,
-		},
-		{
-			"status":      "Menunggu Pengumpulan Berkas",
-			"description": "Formulir disetujui, siap kumpulkan berkas",
-			"completed":   pendaftaran.Status_pendaftaran == "Berkas Diterima" || pendaftaran.Status_pendaftaran == "Menunggu Penugasan" || pendaftaran.Status_pendaftaran == "Penghulu Ditugaskan" || pendaftaran.Status_pendaftaran == "Menunggu Bimbingan" || pendaftaran.Status_pendaftaran == "Sudah Bimbingan" || pendaftaran.Status_pendaftaran == "Selesai",
-			"current":     pendaftaran.Status_pendaftaran == "Menunggu Pengumpulan Berkas",
-			"can_edit":    false,
-		},
-		{
-			"status":      "Berkas Diterima",
-			"description": "Staff menerima berkas hardcopy",
-			"completed":   pendaftaran.Status_pendaftaran == "Menunggu Penugasan" || pendaftaran.Status_pendaftaran == "Penghulu Ditugaskan" || pendaftaran.Status_pendaftaran == "Menunggu Bimbingan" || pendaftaran.Status_pendaftaran == "Sudah Bimbingan" || pendaftaran.Status_pendaftaran == "Selesai",
-			"current":     pendaftaran.Status_pendaftaran == "Berkas Diterima",
-			"can_edit":    false,
-		},
-		{
-			"status":      "Menunggu Penugasan",
-			"description": "Siap untuk assign penghulu",
-			"completed":   pendaftaran.Status_pendaftaran == "Penghulu Ditugaskan" || pendaftaran.Status_pendaftaran == "Menunggu Bimbingan" || pendaftaran.Status_pendaftaran == "Sudah Bimbingan" || pendaftaran.Status_pendaftaran == "Selesai",
-			"current":     pendaftaran.Status_pendaftaran == "Menunggu Penugasan",
-			"can_edit":    false,
-		},
-		{
-			"status":      "Penghulu Ditugaskan",
-			"description": "Penghulu sudah ditugaskan",
-			"completed":   pendaftaran.Status_pendaftaran == "Menunggu Verifikasi Penghulu" || pendaftaran.Status_pendaftaran == "Menunggu Bimbingan" || pendaftaran.Status_pendaftaran == "Sudah Bimbingan" || pendaftaran.Status_pendaftaran == "Selesai",
-			"current":     pendaftaran.Status_pendaftaran == "Penghulu Ditugaskan",
-			"can_edit":    false,
-		},
-		{
-			"status":      "Menunggu Verifikasi Penghulu",
-			"description": "Penghulu harus mengecek berkas",
-			"completed":   pendaftaran.Status_pendaftaran == "Menunggu Bimbingan" || pendaftaran.Status_pendaftaran == "Sudah Bimbingan" || pendaftaran.Status_pendaftaran == "Selesai",
-			"current":     pendaftaran.Status_pendaftaran == "Menunggu Verifikasi Penghulu",
-			"can_edit":    false,
-		},
-		{
-			"status":      "Menunggu Bimbingan",
-			"description": "Siap untuk bimbingan perkawinan",
-			"completed":   pendaftaran.Status_pendaftaran == "Sudah Bimbingan" || pendaftaran.Status_pendaftaran == "Selesai",
-			"current":     pendaftaran.Status_pendaftaran == "Menunggu Bimbingan",
-			"can_edit":    false,
-		},
-		{
-			"status":      "Sudah Bimbingan",
-			"description": "Bimbingan selesai",
-			"completed":   pendaftaran.Status_pendaftaran == "Selesai",
-			"current":     pendaftaran.Status_pendaftaran == "Sudah Bimbingan",
-			"can_edit":    false,
-		},
-		{
-			"status":      "Selesai",
-			"description": "Nikah telah dilaksanakan",
-			"completed":   pendaftaran.Status_pendaftaran == "Selesai",
-			"current":     pendaftaran.Status_pendaftaran == "Selesai",
+	// Ambil riwayat status yang tercatat untuk pendaftaran ini
+	transitionService := services.NewStatusTransitionService(DB)
+	riwayat, err := transitionService.GetStatusHistory(pendaftaran.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil riwayat status"})
+		return
+	}
+
+	// Ambil nama user yang melakukan perubahan status
+	actorIDs := make([]string, 0, len(riwayat))
+	for _, r := range riwayat {
+		actorIDs = append(actorIDs, r.User_id)
+	}
+	actorNames := make(map[string]string)
+	if len(actorIDs) > 0 {
+		var users []structs.Users
+		DB.Select("user_id", "nama").Where("user_id IN ?", actorIDs).Find(&users)
+		for _, u := range users {
+			actorNames[u.User_id] = u.Nama
+		}
+	}
+
+	// Riwayat terakhir yang mencapai setiap status
+	reached := make(map[string]structs.RiwayatStatus)
+	timeline := make([]map[string]interface{}, 0, len(riwayat))
+	for _, r := range riwayat {
+		if r.Status_dari != r.Status_ke {
+			reached[r.Status_ke] = r
+		}
+		timeline = append(timeline, map[string]interface{}{
+			"status_dari": r.Status_dari,
+			"status_ke":   r.Status_ke,
+			"aksi":        r.Aksi,
+			"oleh":        r.User_id,
+			"nama":        actorNames[r.User_id],
+			"role":        r.Role,
+			"catatan":     r.Catatan,
+			"waktu":       r.Created_at,
+		})
+	}
+
+	// Alur status sesuai workflow; langkah yang dicapai dan completed dihitung dari riwayat yang tercatat
+	descriptions := map[string]string{
+		structs.StatusPendaftaranMenungguVerifikasi:         "Staff verifikasi formulir online",
+		structs.StatusPendaftaranMenungguPengumpulanBerkas:  "Formulir disetujui, siap kumpulkan berkas",
+		structs.StatusPendaftaranBerkasDiterima:             "Staff menerima berkas hardcopy",
+		structs.StatusPendaftaranMenungguPenugasan:          "Siap untuk assign penghulu",
+		structs.StatusPendaftaranMenungguVerifikasiPenghulu: "Penghulu harus mengecek berkas",
+		structs.StatusPendaftaranMenungguBimbingan:          "Siap untuk bimbingan perkawinan",
+		structs.StatusPendaftaranSudahBimbingan:             "Bimbingan selesai",
+		structs.StatusPendaftaranSelesai:                    "Nikah telah dilaksanakan",
+	}
+	stepReached, completed := services.StatusFlowProgress(riwayat, pendaftaran.Status_pendaftaran)
+
+	statusFlow := make([]map[string]interface{}, 0, len(services.StatusFlowSteps))
+	for _, status := range services.StatusFlowSteps {
+		item := map[string]interface{}{
+			"status":      status,
+			"description": descriptions[status],
+			"completed":   completed[status],
+			"current":     pendaftaran.Status_pendaftaran == status,
 			"can_edit":    false,
-		},
+		}
+		if r, ok := stepReached[status]; ok {
+			item["tanggal"] = r.Created_at
+			item["oleh"] = r.User_id
+			item["nama"] = actorNames[r.User_id]
+			item["role"] = r.Role
+			item["catatan"] = r.Catatan
+		}
+		statusFlow = append(statusFlow, item)
+	}
+
+	// Informasi penolakan jika pendaftaran ditolak
+	var penolakanInfo map[string]interface{}
+	if r, ok := reached[structs.StatusPendaftaranDitolak]; ok && pendaftaran.Status_pendaftaran == structs.StatusPendaftaranDitolak {
+		penolakanInfo = map[string]interface{}{
+			"status_dari": r.Status_dari,
+			"tanggal":     r.Created_at,
+			"oleh":        r.User_id,
+			"nama":        actorNames[r.User_id],
+			"role":        r.Role,
+			"catatan":     r.Catatan,
+		}
+	}
+
+	// Informasi pembatalan jika pendaftaran dibatalkan
+	var pembatalanInfo map[string]interface{}
+	if r, ok := reached[structs.StatusPendaftaranDibatalkan]; ok && pendaftaran.Status_pendaftaran == structs.StatusPendaftaranDibatalkan {
+		pembatalanInfo = map[string]interface{}{
+			"status_dari": r.Status_dari,
+			"tanggal":     r.Created_at,
+			"oleh":        r.User_id,
+			"nama":        actorNames[r.User_id],
+			"role":        r.Role,
+			"alasan":      r.Catatan,
+		}
 	}
 
 	// Informasi tambahan
@@ -2101,7 +3526,10 @@ func GetStatusFlow(c *gin.Context) {
 			"tempat_nikah":      pendaftaran.Tempat_nikah,
 			"penghulu_assigned": pendaftaran.Penghulu_id != nil,
 			"bimbingan_info":    bimbinganInfo,
+			"penolakan_info":    penolakanInfo,
+			"pembatalan_info":   pembatalanInfo,
 			"status_flow":       statusFlow,
+			"riwayat":           timeline,
 		},
 	})
 }
@@ -2117,43 +3545,19 @@ func CompleteBimbingan(c *gin.Context) {
 		return
 	}
 
-	// Cek apakah pendaftaran ada
+	// Cek apakah pendaftaran ada di KUA user
 	var pendaftaran structs.PendaftaranNikah
-	if err := DB.First(&pendaftaran, pendaftaranID).Error; err != nil {
+	if err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).First(&pendaftaran, pendaftaranID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Pendaftaran tidak ditemukan"})
 		return
 	}
 
-	// Cek apakah status sudah siap untuk bimbingan
-	if pendaftaran.Status_pendaftaran != "Menunggu Bimbingan" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Pendaftaran harus dalam status 'Menunggu Bimbingan' untuk menyelesaikan bimbingan"})
-		return
-	}
-
-	// Cek apakah sudah terdaftar bimbingan
-	var pendaftaranBimbingan structs.PendaftaranBimbingan
-	if err := DB.Where("pendaftaran_nikah_id = ?", pendaftaran.ID).First(&pendaftaranBimbingan).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Belum terdaftar bimbingan perkawinan"})
-		return
-	}
-
-	// Update status pendaftaran
-	pendaftaran.Status_pendaftaran = "Sudah Bimbingan"
-	pendaftaran.Status_bimbingan = "Sudah"
-	pendaftaran.Updated_at = time.Now()
-
-	if err := DB.Save(&pendaftaran).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate status bimbingan"})
-		return
-	}
-
-	// Update status kehadiran bimbingan
-	pendaftaranBimbingan.Status_kehadiran = "Hadir"
-	pendaftaranBimbingan.Status_sertifikat = "Sudah"
-	pendaftaranBimbingan.Updated_at = time.Now()
-
-	if err := DB.Save(&pendaftaranBimbingan).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate status kehadiran bimbingan"})
+	// Transisi "Menunggu Bimbingan" -> "Sudah Bimbingan". State machine memastikan
+	// catin sudah terdaftar bimbingan, lalu menandai kehadiran dan sertifikatnya.
+	transitionService := services.NewStatusTransitionService(DB)
+	actor := services.TransitionActor{UserID: userID.(string), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	if _, err := transitionService.Apply(&pendaftaran, structs.StatusPendaftaranSudahBimbingan, actor, ""); err != nil {
+		respondTransitionError(c, err, "Gagal mengupdate status bimbingan")
 		return
 	}
 
@@ -2182,26 +3586,18 @@ func CompleteNikah(c *gin.Context) {
 		return
 	}
 
-	// Cek apakah pendaftaran ada
+	// Cek apakah pendaftaran ada di KUA user
 	var pendaftaran structs.PendaftaranNikah
-	if err := DB.First(&pendaftaran, pendaftaranID).Error; err != nil {
+	if err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).First(&pendaftaran, pendaftaranID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Pendaftaran tidak ditemukan"})
 		return
 	}
 
-	// Cek apakah status sudah bimbingan
-	if pendaftaran.Status_pendaftaran != "Sudah Bimbingan" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Pendaftaran harus dalam status 'Sudah Bimbingan' untuk menyelesaikan nikah"})
-		return
-	}
-
-	// Update status pendaftaran
-	pendaftaran.Status_pendaftaran = "Selesai"
-	pendaftaran.Status_bimbingan = "Sertifikat Diterbitkan"
-	pendaftaran.Updated_at = time.Now()
-
-	if err := DB.Save(&pendaftaran).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate status nikah"})
+	// Transisi "Sudah Bimbingan" -> "Selesai" melalui state machine
+	transitionService := services.NewStatusTransitionService(DB)
+	actor := services.TransitionActor{UserID: userID.(string), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	if _, err := transitionService.Apply(&pendaftaran, structs.StatusPendaftaranSelesai, actor, ""); err != nil {
+		respondTransitionError(c, err, "Gagal mengupdate status nikah")
 		return
 	}
 
@@ -2220,6 +3616,64 @@ func CompleteNikah(c *gin.Context) {
 	})
 }
 
+// GetNextTransitions menampilkan transisi status berikutnya yang sah untuk pendaftaran dan user yang login
+func GetNextTransitions(c *gin.Context) {
+	pendaftaranID := c.Param("id")
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID tidak ditemukan"})
+		return
+	}
+	role := c.GetString("role")
+
+	actor := services.TransitionActor{UserID: userID.(string), Role: role, KuaID: c.GetUint("kua_id")}
+	pendaftaran, err := services.NewAccessPolicy(DB).LoadRegistration(actor, pendaftaranID, services.AccessView)
+	if err != nil {
+		respondAccessError(c, err, "Pendaftaran tidak ditemukan")
+		return
+	}
+
+	transitionService := services.NewStatusTransitionService(DB)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Transisi status berikutnya berhasil diambil",
+		"data": gin.H{
+			"pendaftaran_id":    pendaftaran.ID,
+			"nomor_pendaftaran": pendaftaran.Nomor_pendaftaran,
+			"status_sekarang":   pendaftaran.Status_pendaftaran,
+			"role":              role,
+			"transisi":          transitionService.NextTransitions(pendaftaran, actor),
+		},
+	})
+}
+
+// respondAccessError memetakan error AccessPolicy ke response HTTP
+func respondAccessError(c *gin.Context, err error, notFoundMessage string) {
+	switch {
+	case errors.Is(err, services.ErrAccessDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+	case errors.Is(err, services.ErrResourceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFoundMessage})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data"})
+	}
+}
+
+// respondTransitionError mengirim response error dari state machine status pendaftaran
+func respondTransitionError(c *gin.Context, err error, fallbackMessage string) {
+	var transitionErr *services.TransitionError
+	if errors.As(err, &transitionErr) {
+		c.JSON(transitionErr.StatusCode(), gin.H{
+			"error":  transitionErr.Error(),
+			"type":   transitionErr.Type,
+			"alasan": transitionErr.Reasons,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallbackMessage})
+}
+
 // UpdateBimbinganAttendance mengupdate kehadiran bimbingan perkawinan
 func UpdateBimbinganAttendance(c *gin.Context) {
 	bimbinganID := c.Param("id")
@@ -2263,9 +3717,9 @@ func UpdateBimbinganAttendance(c *gin.Context) {
 		return
 	}
 
-	// Cek apakah bimbingan ada
+	// Cek apakah bimbingan ada di KUA user
 	var bimbingan structs.BimbinganPerkawinan
-	if err := DB.First(&bimbingan, bimbinganID).Error; err != nil {
+	if err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).First(&bimbingan, bimbinganID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Bimbingan perkawinan tidak ditemukan"})
 		return
 	}
@@ -2320,50 +3774,62 @@ func GetPenghuluKetersediaan(c *gin.Context) {
 
 	// Cek apakah penghulu ada dan aktif
 	var penghulu structs.Penghulu
-	if err := DB.Where("id = ? AND status = ?", penghuluID, "Aktif").First(&penghulu).Error; err != nil {
+	if err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).Where("id = ? AND status = ?", penghuluID, "Aktif").First(&penghulu).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Penghulu tidak ditemukan atau tidak aktif"})
 		return
 	}
 
-	// Query jadwal penghulu untuk tanggal tersebut
+	aturan, err := services.NewSchedulingRuleService(DB).ForKUA(penghulu.Kua_id)
+	if err != nil {
+		respondKUAError(c, err)
+		return
+	}
+
+	// Query jadwal penghulu yang sudah terjadwal untuk tanggal tersebut
 	var jadwalPenghulu []structs.PendaftaranNikah
-	err = DB.Where("penghulu_id = ? AND DATE(tanggal_nikah) = ? AND status_pendaftaran = ?",
-		penghuluID, tanggal.Format("2006-01-02"), "Disetujui").Find(&jadwalPenghulu).Error
+	err = DB.Where("penghulu_id = ? AND DATE(tanggal_nikah) = ? AND status_pendaftaran IN ?",
+		penghuluID, tanggal.Format("2006-01-02"), services.ScheduledStatuses).Find(&jadwalPenghulu).Error
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data jadwal penghulu"})
 		return
 	}
 
-	// Buat slot waktu yang tersedia (08:00 - 16:00, 9 slot per hari)
-	slotWaktu := []string{"08:00", "09:00", "10:00", "11:00", "12:00", "13:00", "14:00", "15:00", "16:00"}
+	// Cuti, sakit, tugas luar, dan blokir jadwal penghulu yang disetujui pada tanggal tersebut
+	ketidaksediaan, err := services.NewPenghuluAvailabilityService(DB).ForDate(penghulu.Kua_id, tanggal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data ketidaksediaan penghulu"})
+		return
+	}
+	tidakTersedia := ketidaksediaan[penghulu.ID]
+	if tidakTersedia == nil {
+		tidakTersedia = []structs.KetidaksediaanPenghulu{}
+	}
+
+	// Buat slot waktu sesuai jam layanan dan jeda minimal KUA
+	slotWaktu := aturan.TimeSlots()
+	hariTutup := aturan.IsClosedDay(tanggal)
+	penuh := len(jadwalPenghulu) >= aturan.MaksNikahPenghuluHarian
 	slotTersedia := make([]map[string]interface{}, 0)
 
 	for _, slot := range slotWaktu {
-		// Parse waktu slot
-		waktuSlot, err := time.Parse("15:04", slot)
-		if err != nil {
-			continue
-		}
-
-		// Cek apakah slot ini tersedia
-		tersedia := true
+		// Slot tidak tersedia jika KUA tutup atau kuota penghulu sudah penuh
+		tersedia := !hariTutup && !penuh
 		var konflikJadwal []map[string]interface{}
+		var blokir *structs.KetidaksediaanPenghulu
 
-		for _, jadwal := range jadwalPenghulu {
-			waktuJadwal, err := time.Parse("15:04", jadwal.Waktu_nikah)
-			if err != nil {
-				continue
-			}
-
-			// Hitung selisih waktu dalam menit
-			selisihMenit := int(waktuSlot.Sub(waktuJadwal).Minutes())
-			if selisihMenit < 0 {
-				selisihMenit = -selisihMenit
+		// Slot tidak tersedia jika penghulu cuti/sakit/tugas luar pada jam tersebut
+		for i := range tidakTersedia {
+			if services.UnavailabilityBlocks(&tidakTersedia[i], tanggal, slot) {
+				tersedia = false
+				blokir = &tidakTersedia[i]
+				break
 			}
+		}
 
-			// Jika selisih kurang dari 1 jam, slot tidak tersedia (untuk 4 penghulu bersamaan per slot)
-			if selisihMenit < 60 {
+		for _, jadwal := range jadwalPenghulu {
+			// Jika selisih kurang dari jeda minimal, slot tidak tersedia
+			if selisihMenit, konflik := aturan.GapConflict(slot, jadwal.Waktu_nikah); konflik {
 				tersedia = false
 				konflikJadwal = append(konflikJadwal, map[string]interface{}{
 					"waktu":         jadwal.Waktu_nikah,
@@ -2377,12 +3843,16 @@ func GetPenghuluKetersediaan(c *gin.Context) {
 			"waktu":          slot,
 			"tersedia":       tersedia,
 			"konflik_jadwal": konflikJadwal,
+			"ketidaksediaan": blokir,
 		})
 	}
 
 	// Hitung statistik
 	jumlahJadwal := len(jadwalPenghulu)
-	sisaKuota := 3 - jumlahJadwal
+	sisaKuota := aturan.MaksNikahPenghuluHarian - jumlahJadwal
+	if sisaKuota < 0 {
+		sisaKuota = 0
+	}
 	slotTersediaCount := 0
 	for _, slot := range slotTersedia {
 		if slot["tersedia"].(bool) {
@@ -2399,21 +3869,25 @@ func GetPenghuluKetersediaan(c *gin.Context) {
 				"nama":   penghulu.Nama_lengkap,
 				"status": penghulu.Status,
 			},
-			"tanggal": tanggalParam,
+			"tanggal":    tanggalParam,
+			"hari_tutup": hariTutup,
 			"statistik": gin.H{
 				"jumlah_jadwal":     jumlahJadwal,
 				"sisa_kuota":        sisaKuota,
-				"maksimal_per_hari": 3,
+				"maksimal_per_hari": aturan.MaksNikahPenghuluHarian,
+				"jeda_minimal":      aturan.JedaMinimalMenit,
 				"slot_tersedia":     slotTersediaCount,
 				"total_slot":        len(slotWaktu),
 			},
 			"jadwal_hari_ini": jadwalPenghulu,
+			"ketidaksediaan":  tidakTersedia,
 			"slot_waktu":      slotTersedia,
 		},
 	})
 }
 
-// CetakUndanganBimbingan mencetak undangan bimbingan perkawinan untuk peserta
+// CetakUndanganBimbingan mencetak undangan bimbingan perkawinan untuk pendaftaran milik user.
+// Query format: pdf (default), html, atau json
 func CetakUndanganBimbingan(c *gin.Context) {
 	bimbinganID := c.Param("id")
 
@@ -2431,224 +3905,131 @@ func CetakUndanganBimbingan(c *gin.Context) {
 		return
 	}
 
-	// Cek apakah user sudah terdaftar di bimbingan ini
-	var pendaftaranBimbingan structs.PendaftaranBimbingan
-	if err := DB.Where("bimbingan_perkawinan_id = ? AND (calon_suami_id = ? OR calon_istri_id = ?)",
-		bimbinganID, userID.(string), userID.(string)).First(&pendaftaranBimbingan).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Anda belum terdaftar di bimbingan perkawinan ini"})
+	// Cek apakah pendaftaran nikah user terdaftar di bimbingan ini
+	undanganService := services.NewUndanganBimbinganService(DB)
+	peserta, err := undanganService.PesertaMilik(bimbingan.ID, userID.(string))
+	if err != nil {
+		if errors.Is(err, services.ErrResourceNotFound) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Anda belum terdaftar di bimbingan perkawinan ini"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data peserta"})
 		return
 	}
 
-	// Ambil data pendaftaran nikah
-	var pendaftaran structs.PendaftaranNikah
-	if err := DB.First(&pendaftaran, pendaftaranBimbingan.Pendaftaran_nikah_id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Data pendaftaran nikah tidak ditemukan"})
+	undangan, err := undanganService.Undangan(&bimbingan, []structs.PendaftaranBimbingan{*peserta})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyusun undangan"})
 		return
 	}
-
-	// Ambil data calon pasangan
-	var calonSuami, calonIstri structs.CalonPasangan
-	DB.First(&calonSuami, pendaftaran.Calon_suami_id)
-	DB.First(&calonIstri, pendaftaran.Calon_istri_id)
-
-	// Format tanggal dan waktu
-	tanggalBimbingan := bimbingan.Tanggal_bimbingan.Format("02 Januari 2006")
-	hariBimbingan := bimbingan.Tanggal_bimbingan.Format("Monday")
-	waktuBimbingan := bimbingan.Waktu_mulai + " - " + bimbingan.Waktu_selesai
-
-	// Buat data undangan
-	undanganData := map[string]interface{}{
-		"bimbingan": gin.H{
-			"id":         bimbingan.ID,
-			"tanggal":    tanggalBimbingan,
-			"hari":       hariBimbingan,
-			"waktu":      waktuBimbingan,
-			"tempat":     bimbingan.Tempat_bimbingan,
-			"pembimbing": bimbingan.Pembimbing,
-			"kapasitas":  bimbingan.Kapasitas,
-			"status":     bimbingan.Status,
-		},
-		"pendaftaran": gin.H{
-			"nomor_pendaftaran": pendaftaran.Nomor_pendaftaran,
-			"tanggal_nikah":     pendaftaran.Tanggal_nikah.Format("02 Januari 2006"),
-			"waktu_nikah":       pendaftaran.Waktu_nikah,
-			"tempat_nikah":      pendaftaran.Tempat_nikah,
-		},
-		"calon_pasangan": gin.H{
-			"calon_suami": gin.H{
-				"nama":          calonSuami.Nama_lengkap,
-				"nik":           calonSuami.NIK,
-				"tempat_lahir":  calonSuami.Tempat_lahir,
-				"tanggal_lahir": calonSuami.Tanggal_lahir.Format("02 Januari 2006"),
-				"alamat":        calonSuami.Alamat,
-			},
-			"calon_istri": gin.H{
-				"nama":          calonIstri.Nama_lengkap,
-				"nik":           calonIstri.NIK,
-				"tempat_lahir":  calonIstri.Tempat_lahir,
-				"tanggal_lahir": calonIstri.Tanggal_lahir.Format("02 Januari 2006"),
-				"alamat":        calonIstri.Alamat,
-			},
-		},
-		"status_kehadiran":  pendaftaranBimbingan.Status_kehadiran,
-		"status_sertifikat": pendaftaranBimbingan.Status_sertifikat,
-		"no_sertifikat":     pendaftaranBimbingan.No_sertifikat,
-		"tanggal_daftar":    pendaftaranBimbingan.Created_at.Format("02 Januari 2006"),
-		"keterangan": gin.H{
-			"title":    "UNDANGAN BIMBINGAN PERKAWINAN",
-			"subtitle": "Kantor Urusan Agama (KUA)",
-			"message":  "Dengan hormat, kami mengundang Bapak/Ibu untuk mengikuti Bimbingan Perkawinan sebagai persyaratan sebelum melaksanakan pernikahan.",
-			"instruksi": []string{
-				"1. Harap hadir tepat waktu sesuai jadwal yang telah ditentukan",
-				"2. Membawa dokumen asli untuk verifikasi",
-				"3. Menggunakan pakaian yang sopan dan rapi",
-				"4. Mengikuti seluruh rangkaian bimbingan dengan baik",
-				"5. Sertifikat bimbingan akan diberikan setelah selesai mengikuti bimbingan",
-			},
-			"contact": gin.H{
-				"alamat_kua": "Jl. Merdeka No. 123, Kota Bandung",
-				"telepon":    "022-1234567",
-				"email":      "kua@bandung.go.id",
-			},
-		},
+	if len(undangan) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Data pendaftaran nikah tidak ditemukan"})
+		return
 	}
 
-	// Set header untuk PDF (jika ingin generate PDF)
-	// c.Header("Content-Type", "application/pdf")
-	// c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"undangan_bimbingan_%s.pdf\"", pendaftaran.Nomor_pendaftaran))
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Undangan bimbingan perkawinan berhasil dibuat",
-		"data":    undanganData,
-		"print_info": gin.H{
-			"filename": fmt.Sprintf("undangan_bimbingan_%s_%s", pendaftaran.Nomor_pendaftaran, bimbingan.Tanggal_bimbingan.Format("20060102")),
-			"format":   "PDF/HTML",
-			"size":     "A4",
-		},
-	})
+	filename := fmt.Sprintf("undangan_bimbingan_%s_%s", undangan[0].NomorPendaftaran, bimbingan.Tanggal_bimbingan.Format("20060102"))
+	kirimUndanganBimbingan(c, undanganService, &bimbingan, undangan, filename)
 }
 
 // CetakUndanganBimbinganSemua mencetak undangan bimbingan perkawinan untuk semua peserta (Staff/Kepala KUA)
+// dalam satu dokumen, satu halaman per pasangan. Query format: pdf (default), html, atau json
 func CetakUndanganBimbinganSemua(c *gin.Context) {
 	bimbinganID := c.Param("id")
 
-	// Cek apakah bimbingan ada
+	// Cek apakah bimbingan ada di KUA user
 	var bimbingan structs.BimbinganPerkawinan
-	if err := DB.First(&bimbingan, bimbinganID).Error; err != nil {
+	if err := DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).First(&bimbingan, bimbinganID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Bimbingan perkawinan tidak ditemukan"})
 		return
 	}
 
 	// Query semua peserta bimbingan
 	var peserta []structs.PendaftaranBimbingan
-	err := DB.Where("bimbingan_perkawinan_id = ?", bimbingan.ID).Find(&peserta).Error
+	err := DB.Where("bimbingan_perkawinan_id = ?", bimbingan.ID).Order("id").Find(&peserta).Error
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data peserta"})
 		return
 	}
+	if len(peserta) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Belum ada peserta di bimbingan perkawinan ini"})
+		return
+	}
 
-	// Buat data undangan untuk semua peserta
-	undanganSemua := make([]map[string]interface{}, 0)
+	undanganService := services.NewUndanganBimbinganService(DB)
+	undangan, err := undanganService.Undangan(&bimbingan, peserta)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyusun undangan"})
+		return
+	}
 
-	for _, p := range peserta {
-		// Ambil data pendaftaran nikah
-		var pendaftaran structs.PendaftaranNikah
-		if err := DB.First(&pendaftaran, p.Pendaftaran_nikah_id).Error; err != nil {
-			continue // Skip jika data tidak ditemukan
-		}
+	filename := fmt.Sprintf("undangan_bimbingan_semua_%d_%s", bimbingan.ID, bimbingan.Tanggal_bimbingan.Format("20060102"))
+	kirimUndanganBimbingan(c, undanganService, &bimbingan, undangan, filename)
+}
 
-		// Ambil data calon pasangan
-		var calonSuami, calonIstri structs.CalonPasangan
-		DB.First(&calonSuami, p.Calon_suami_id)
-		DB.First(&calonIstri, p.Calon_istri_id)
+// kirimUndanganBimbingan menulis undangan dalam format yang diminta (?format=pdf|html|json)
+func kirimUndanganBimbingan(c *gin.Context, undanganService *services.UndanganBimbinganService, bimbingan *structs.BimbinganPerkawinan, undangan []services.UndanganBimbingan, filename string) {
+	kop, err := undanganService.Kop(bimbingan.Kua_id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data KUA"})
+		return
+	}
+	tanggalCetak := undanganService.TanggalCetak(bimbingan.Kua_id)
 
-		// Format tanggal dan waktu
-		tanggalBimbingan := bimbingan.Tanggal_bimbingan.Format("02 Januari 2006")
-		hariBimbingan := bimbingan.Tanggal_bimbingan.Format("Monday")
-		waktuBimbingan := bimbingan.Waktu_mulai + " - " + bimbingan.Waktu_selesai
-
-		// Buat data undangan untuk peserta ini
-		undanganPeserta := map[string]interface{}{
-			"pendaftaran_bimbingan_id": p.ID,
-			"bimbingan": gin.H{
-				"id":         bimbingan.ID,
-				"tanggal":    tanggalBimbingan,
-				"hari":       hariBimbingan,
-				"waktu":      waktuBimbingan,
-				"tempat":     bimbingan.Tempat_bimbingan,
-				"pembimbing": bimbingan.Pembimbing,
-				"kapasitas":  bimbingan.Kapasitas,
-				"status":     bimbingan.Status,
-			},
-			"pendaftaran": gin.H{
-				"nomor_pendaftaran": pendaftaran.Nomor_pendaftaran,
-				"tanggal_nikah":     pendaftaran.Tanggal_nikah.Format("02 Januari 2006"),
-				"waktu_nikah":       pendaftaran.Waktu_nikah,
-				"tempat_nikah":      pendaftaran.Tempat_nikah,
-			},
-			"calon_pasangan": gin.H{
-				"calon_suami": gin.H{
-					"nama":          calonSuami.Nama_lengkap,
-					"nik":           calonSuami.NIK,
-					"tempat_lahir":  calonSuami.Tempat_lahir,
-					"tanggal_lahir": calonSuami.Tanggal_lahir.Format("02 Januari 2006"),
-					"alamat":        calonSuami.Alamat,
-				},
-				"calon_istri": gin.H{
-					"nama":          calonIstri.Nama_lengkap,
-					"nik":           calonIstri.NIK,
-					"tempat_lahir":  calonIstri.Tempat_lahir,
-					"tanggal_lahir": calonIstri.Tanggal_lahir.Format("02 Januari 2006"),
-					"alamat":        calonIstri.Alamat,
-				},
-			},
-			"status_kehadiran":  p.Status_kehadiran,
-			"status_sertifikat": p.Status_sertifikat,
-			"no_sertifikat":     p.No_sertifikat,
-			"tanggal_daftar":    p.Created_at.Format("02 Januari 2006"),
-			"keterangan": gin.H{
-				"title":    "UNDANGAN BIMBINGAN PERKAWINAN",
-				"subtitle": "Kantor Urusan Agama (KUA)",
-				"message":  "Dengan hormat, kami mengundang Bapak/Ibu untuk mengikuti Bimbingan Perkawinan sebagai persyaratan sebelum melaksanakan pernikahan.",
-				"instruksi": []string{
-					"1. Harap hadir tepat waktu sesuai jadwal yang telah ditentukan",
-					"2. Membawa dokumen asli untuk verifikasi",
-					"3. Menggunakan pakaian yang sopan dan rapi",
-					"4. Mengikuti seluruh rangkaian bimbingan dengan baik",
-					"5. Sertifikat bimbingan akan diberikan setelah selesai mengikuti bimbingan",
-				},
-				"contact": gin.H{
-					"alamat_kua": "Jl. Merdeka No. 123, Kota Bandung",
-					"telepon":    "022-1234567",
-					"email":      "kua@bandung.go.id",
-				},
-			},
+	switch c.DefaultQuery("format", "pdf") {
+	case "pdf":
+		out, err := services.RenderUndanganPDF(kop, undangan, tanggalCetak)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat PDF undangan"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.pdf\"", filename))
+		c.Data(http.StatusOK, "application/pdf", out)
+	case "html":
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := services.RenderUndanganHTML(c.Writer, kop, undangan, tanggalCetak); err != nil {
+			log.Printf("Gagal membuat HTML undangan bimbingan %d: %v", bimbingan.ID, err)
 		}
+	case "json":
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Undangan bimbingan perkawinan berhasil dibuat",
+			"data": gin.H{
+				"kop":              kop,
+				"tanggal_cetak":    tanggalCetak,
+				"undangan_peserta": undangan,
+				"total_undangan":   len(undangan),
+			},
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format tidak valid. Gunakan pdf, html, atau json"})
+	}
+}
 
-		undanganSemua = append(undanganSemua, undanganPeserta)
+// VerifikasiUndanganBimbingan memeriksa keaslian undangan dari token di QR code (publik)
+func VerifikasiUndanganBimbingan(c *gin.Context) {
+	undangan, kop, err := services.NewUndanganBimbinganService(DB).Verify(c.Param("token"))
+	if err != nil {
+		if errors.Is(err, services.ErrUndanganInvalid) {
+			c.JSON(http.StatusNotFound, gin.H{"valid": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memverifikasi undangan"})
+		return
 	}
 
-	// Response
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Undangan bimbingan perkawinan untuk semua peserta berhasil dibuat",
+		"valid":   true,
+		"message": "Undangan bimbingan perkawinan terdaftar di " + kop.NamaKUA,
 		"data": gin.H{
-			"bimbingan_info": gin.H{
-				"id":             bimbingan.ID,
-				"tanggal":        bimbingan.Tanggal_bimbingan.Format("02 Januari 2006"),
-				"hari":           bimbingan.Tanggal_bimbingan.Format("Monday"),
-				"waktu":          bimbingan.Waktu_mulai + " - " + bimbingan.Waktu_selesai,
-				"tempat":         bimbingan.Tempat_bimbingan,
-				"pembimbing":     bimbingan.Pembimbing,
-				"kapasitas":      bimbingan.Kapasitas,
-				"jumlah_peserta": len(peserta),
-			},
-			"undangan_peserta": undanganSemua,
-			"print_info": gin.H{
-				"filename":       fmt.Sprintf("undangan_bimbingan_semua_%d_%s", bimbingan.ID, bimbingan.Tanggal_bimbingan.Format("20060102")),
-				"format":         "PDF/HTML",
-				"size":           "A4",
-				"total_undangan": len(undanganSemua),
-			},
+			"kua":               kop.NamaKUA,
+			"nomor_pendaftaran": undangan.NomorPendaftaran,
+			"calon_suami":       undangan.CalonSuami,
+			"calon_istri":       undangan.CalonIstri,
+			"hari":              undangan.Hari,
+			"tanggal":           undangan.Tanggal,
+			"waktu":             undangan.Waktu,
+			"tempat":            undangan.Tempat,
+			"status_kehadiran":  undangan.StatusKehadiran,
 		},
 	})
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	structs "simnikah/internal/models"
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useTestDB mengganti DB global dengan SQLite sementara berisi izin default
+func useTestDB(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "simnikah.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	if err := db.AutoMigrate(&structs.IzinRole{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	if _, err := services.NewPermissionService(db).EnsureDefaults(); err != nil {
+		t.Fatalf("EnsureDefaults() error = %v", err)
+	}
+
+	old := DB
+	DB = db
+	t.Cleanup(func() {
+		DB = old
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDB(t)
+
+	if err := services.NewPermissionService(DB).SetRolePermissions(2, structs.UserRoleStaff,
+		[]string{structs.IzinPenghuluAssign}, "KPL2"); err != nil {
+		t.Fatalf("SetRolePermissions() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		role  string
+		kuaID uint
+		want  int
+	}{
+		{"kepala KUA", structs.UserRoleKepalaKUA, 1, http.StatusOK},
+		{"staff tanpa izin", structs.UserRoleStaff, 1, http.StatusForbidden},
+		{"staff KUA yang memberi izin", structs.UserRoleStaff, 2, http.StatusOK},
+		{"catin", structs.UserRoleUserBiasa, 1, http.StatusForbidden},
+		{"tanpa role", "", 1, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if tt.role != "" {
+					c.Set("role", tt.role)
+				}
+				c.Set("kua_id", tt.kuaID)
+				c.Next()
+			})
+			r.POST("/penghulu", RequirePermission(structs.IzinPenghuluAssign), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/penghulu", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
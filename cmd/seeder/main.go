@@ -13,7 +13,7 @@ import (
 
 func main() {
 	// Parse command line flags
-	seedType := flag.String("type", "all", "Type of seeder to run: all, kepala_kua, staff, penghulu")
+	seedType := flag.String("type", "all", "Type of seeder to run: all, super_admin, kepala_kua, staff, penghulu, permissions, hari_libur")
 	flag.Parse()
 
 	// Initialize database connection
@@ -26,24 +26,44 @@ func main() {
 	log.Println("")
 
 	switch *seedType {
+	case "super_admin":
+		seedSuperAdmin(db)
 	case "kepala_kua":
 		seedKepalaKUA(db)
 	case "staff":
 		seedStaff(db)
 	case "penghulu":
 		seedPenghulu(db)
+	case "permissions":
+		seedPermissions(db)
+	case "hari_libur":
+		seedHolidays(db)
 	case "all":
+		seedSuperAdmin(db)
 		seedKepalaKUA(db)
 		seedStaff(db)
 		seedPenghulu(db)
+		seedPermissions(db)
+		seedHolidays(db)
 	default:
-		log.Fatalf("Invalid seed type: %s. Valid types: all, kepala_kua, staff, penghulu", *seedType)
+		log.Fatalf("Invalid seed type: %s. Valid types: all, super_admin, kepala_kua, staff, penghulu, permissions, hari_libur", *seedType)
 	}
 
 	log.Println("")
 	log.Println("✅ All seeding completed successfully!")
 }
 
+func seedSuperAdmin(db *gorm.DB) {
+	// Credentials from environment variables; skipped when no password is set
+	username := os.Getenv("SEEDER_SUPER_ADMIN_USERNAME")
+	email := os.Getenv("SEEDER_SUPER_ADMIN_EMAIL")
+	password := os.Getenv("SEEDER_SUPER_ADMIN_PASSWORD")
+
+	if err := seeders.SeedSuperAdmin(db, username, email, password); err != nil {
+		log.Printf("⚠️  Warning: Failed to seed super admin: %v", err)
+	}
+}
+
 func seedKepalaKUA(db *gorm.DB) {
 	// Get credentials from environment variables (optional)
 	username := os.Getenv("SEEDER_KEPALA_KUA_USERNAME")
@@ -115,3 +135,15 @@ func seedPenghulu(db *gorm.DB) {
 	}
 }
 
+func seedPermissions(db *gorm.DB) {
+	if err := seeders.SeedRolePermissions(db); err != nil {
+		log.Printf("⚠️  Warning: Failed to seed role permissions: %v", err)
+	}
+}
+
+func seedHolidays(db *gorm.DB) {
+	// Directory with yearly holiday files (optional, default migrations/hari_libur)
+	if err := seeders.SeedHolidays(db, os.Getenv("HARI_LIBUR_DIR")); err != nil {
+		log.Printf("⚠️  Warning: Failed to seed holidays: %v", err)
+	}
+}
@@ -55,7 +55,7 @@ func ConnectDB() (*gorm.DB, error) {
 			SlowThreshold:             2 * time.Second, // Only log queries slower than 2s
 			LogLevel:                  logLevel,
 			IgnoreRecordNotFoundError: true,
-			Colorful:                  isDevEnvironment(), // Disable colors in production
+			Colorful:                  IsDevEnvironment(), // Disable colors in production
 		},
 	)
 
@@ -133,8 +133,8 @@ func getLogLevel() logger.LogLevel {
 	return logger.Warn
 }
 
-// isDevEnvironment checks if running in development mode
-func isDevEnvironment() bool {
+// IsDevEnvironment checks if running in development mode (GIN_MODE is not release and ENVIRONMENT is not production)
+func IsDevEnvironment() bool {
 	ginMode := os.Getenv("GIN_MODE")
 	environment := os.Getenv("ENVIRONMENT")
 	return ginMode != "release" && environment != "production"
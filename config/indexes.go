@@ -89,6 +89,9 @@ func AddDatabaseIndexes(db *gorm.DB) error {
 	// Composite index for unread notifications (IMPORTANT!)
 	createIndex("idx_notifikasi_user_status", "notifikasis", "user_id, status_baca")
 
+	// ==================== RIWAYAT STATUS TABLE ====================
+	createIndex("idx_riwayat_status_pendaftaran_id", "riwayat_statuses", "pendaftaran_nikah_id, created_at")
+
 	// ==================== STAFF KUA TABLE ====================
 	createIndex("idx_staff_kua_user_id", "staff_kuas", "user_id")
 	createIndex("idx_staff_kua_nip", "staff_kuas", "n_ip") // GORM converts NIP -> n_ip
@@ -2,6 +2,7 @@ package catin
 
 import (
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -115,10 +116,41 @@ func (h *InDB) CreateMarriageRegistrationForm(c *gin.Context) {
 		return
 	}
 
+	// Tentukan KUA yang menangani pendaftaran
+	kua, err := services.NewKUAService(h.DB).Resolve(
+		dataFormPendaftaran.JadwalDanLokasi.KuaID,
+		dataFormPendaftaran.JadwalDanLokasi.Kecamatan,
+		dataFormPendaftaran.JadwalDanLokasi.Kabupaten,
+		c.GetUint("kua_id"),
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrKUARequired) || errors.Is(err, services.ErrKUANotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Validasi gagal",
+				"error":   err.Error() + ". Pilih KUA dari daftar GET /kua",
+				"field":   "kua_id",
+				"type":    "required",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Database error",
+			"error":   "Gagal menentukan KUA",
+			"type":    "database",
+		})
+		return
+	}
+
 	// --- DISPENSASI VALIDATION LOGIC START ---
-	// Calculate working days between registration date (now) and wedding date
+	// Calculate working days between registration date (now) and wedding date,
+	// excluding national holidays, cuti bersama and closures of the KUA
 	now := time.Now()
-	workingDays := utils.CalculateWorkingDays(now, tanggalNikah)
+	workingDays, err := services.NewHolidayService(h.DB).WorkingDays(kua.ID, now, tanggalNikah)
+	if err != nil {
+		workingDays = utils.CalculateWorkingDays(now, tanggalNikah)
+	}
 
 	// Calculate ages of bride and groom at registration date
 	groomAge := utils.CalculateAge(tanggalLahirSuami, now)
@@ -411,7 +443,7 @@ func (h *InDB) CreateMarriageRegistrationForm(c *gin.Context) {
 
 	// Check if user already has an active marriage registration
 	var existingRegistration structs.PendaftaranNikah
-	if err := h.DB.Where("pendaftar_id = ? AND status_pendaftaran NOT IN (?)", userID.(string), []string{structs.StatusPendaftaranSelesai, structs.StatusPendaftaranDitolak}).First(&existingRegistration).Error; err == nil {
+	if err := h.DB.Where("pendaftar_id = ? AND status_pendaftaran NOT IN (?)", userID.(string), []string{structs.StatusPendaftaranSelesai, structs.StatusPendaftaranDitolak, structs.StatusPendaftaranDibatalkan}).First(&existingRegistration).Error; err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": "Pendaftaran sudah ada",
@@ -427,6 +459,54 @@ func (h *InDB) CreateMarriageRegistrationForm(c *gin.Context) {
 		return
 	}
 
+	// Validasi hari dan jam nikah terhadap aturan penjadwalan KUA
+	aturan := services.RulesForKUA(kua)
+	if aturan.IsClosedDay(tanggalNikah) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Validasi gagal",
+			"error":   kua.Nama + " tidak melayani pernikahan pada hari tersebut",
+			"field":   "tanggal_nikah",
+			"type":    "validation",
+		})
+		return
+	}
+	if libur, err := services.NewHolidayService(h.DB).Get(kua.ID, tanggalNikah); err == nil && libur != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Validasi gagal",
+			"error":   "Tanggal nikah jatuh pada hari libur (" + libur.Nama + "). Silakan pilih tanggal lain",
+			"field":   "tanggal_nikah",
+			"type":    "validation",
+		})
+		return
+	}
+	if !aturan.WithinWorkingHours(dataFormPendaftaran.JadwalDanLokasi.WaktuNikah) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Validasi gagal",
+			"error":   fmt.Sprintf("Waktu nikah harus di antara %s dan %s", aturan.JamMulai, aturan.JamSelesai),
+			"field":   "waktu_nikah",
+			"type":    "validation",
+		})
+		return
+	}
+
+	// Nikah di KUA memakai slot balai KUA, waktu harus salah satu slot layanan
+	nikahDiKUA := dataFormPendaftaran.JadwalDanLokasi.LokasiNikah == "Di KUA"
+	if nikahDiKUA {
+		if err := services.ValidateSlot(aturan, services.NewKUAService(h.DB).ZonaWaktu(kua.ID), tanggalNikah, dataFormPendaftaran.JadwalDanLokasi.WaktuNikah); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Validasi gagal",
+				"error":   err.Error(),
+				"field":   "waktu_nikah",
+				"type":    "validation",
+			})
+			return
+		}
+	}
+
 	// Generate unique user IDs for groom and bride profiles (max 20 chars)
 	userIDStr := userID.(string)
 	timestamp := time.Now().Unix()
@@ -585,26 +665,26 @@ func (h *InDB) CreateMarriageRegistrationForm(c *gin.Context) {
 		})
 	}
 
-	if dataFormPendaftaran.OrangTuaCalonIstri.Ayah.StatusKeberadaan == structs.StatusKeberadaanHidup {
-		dataOrangTuaList = append(dataOrangTuaList, structs.DataOrangTua{
-			User_id:             userID.(string),
-			Jenis_kelamin_calon: "P",
-			Hubungan:            structs.HubunganAyah,
-			NIK:                 dataFormPendaftaran.OrangTuaCalonIstri.Ayah.Nik,
-			Nama_lengkap:        dataFormPendaftaran.OrangTuaCalonIstri.Ayah.Nama,
-			Warga_negara:        dataFormPendaftaran.OrangTuaCalonIstri.Ayah.Kewarganegaraan,
-			Agama:               dataFormPendaftaran.OrangTuaCalonIstri.Ayah.Agama,
-			Tempat_lahir:        dataFormPendaftaran.OrangTuaCalonIstri.Ayah.TempatLahir,
-			Negara_asal:         dataFormPendaftaran.OrangTuaCalonIstri.Ayah.NegaraAsal,
-			Pekerjaan:           dataFormPendaftaran.OrangTuaCalonIstri.Ayah.Pekerjaan,
-			Pekerjaan_lain:      dataFormPendaftaran.OrangTuaCalonIstri.Ayah.DeskripsiPekerjaan,
-			Alamat:              dataFormPendaftaran.OrangTuaCalonIstri.Ayah.Alamat,
-			Status_keberadaan:   structs.StatusKeberadaanHidup,
-			Jenis_kelamin:       "L",
-			Created_at:          createdAt,
-			Updated_at:          createdAt,
-		})
-	}
+	// Ayah calon istri selalu dicatat beserta status keberadaannya; checklist dokumen membaca
+	// status ini untuk mewajibkan akta kematian ayah
+	dataOrangTuaList = append(dataOrangTuaList, structs.DataOrangTua{
+		User_id:             userID.(string),
+		Jenis_kelamin_calon: "P",
+		Hubungan:            structs.HubunganAyah,
+		NIK:                 dataFormPendaftaran.OrangTuaCalonIstri.Ayah.Nik,
+		Nama_lengkap:        dataFormPendaftaran.OrangTuaCalonIstri.Ayah.Nama,
+		Warga_negara:        dataFormPendaftaran.OrangTuaCalonIstri.Ayah.Kewarganegaraan,
+		Agama:               dataFormPendaftaran.OrangTuaCalonIstri.Ayah.Agama,
+		Tempat_lahir:        dataFormPendaftaran.OrangTuaCalonIstri.Ayah.TempatLahir,
+		Negara_asal:         dataFormPendaftaran.OrangTuaCalonIstri.Ayah.NegaraAsal,
+		Pekerjaan:           dataFormPendaftaran.OrangTuaCalonIstri.Ayah.Pekerjaan,
+		Pekerjaan_lain:      dataFormPendaftaran.OrangTuaCalonIstri.Ayah.DeskripsiPekerjaan,
+		Alamat:              dataFormPendaftaran.OrangTuaCalonIstri.Ayah.Alamat,
+		Status_keberadaan:   dataFormPendaftaran.OrangTuaCalonIstri.Ayah.StatusKeberadaan,
+		Jenis_kelamin:       "L",
+		Created_at:          createdAt,
+		Updated_at:          createdAt,
+	})
 
 	if dataFormPendaftaran.OrangTuaCalonIstri.Ibu.StatusKeberadaan == structs.StatusKeberadaanHidup {
 		dataOrangTuaList = append(dataOrangTuaList, structs.DataOrangTua{
@@ -649,12 +729,9 @@ func (h *InDB) CreateMarriageRegistrationForm(c *gin.Context) {
 	var latitude, longitude *float64
 
 	if dataFormPendaftaran.JadwalDanLokasi.LokasiNikah == "Di KUA" {
-		alamatAkad = "KUA Kecamatan Banjarmasin Utara, Kelurahan Pangeran, Kecamatan Banjarmasin Utara, Kota Banjarmasin, Kalimantan Selatan"
-		// Koordinat KUA Banjarmasin Utara (contoh)
-		lat := -3.3148
-		lon := 114.5925
-		latitude = &lat
-		longitude = &lon
+		alamatAkad = kua.Nama + ", " + kua.Alamat
+		latitude = kua.Latitude
+		longitude = kua.Longitude
 	} else {
 		// Use provided address for outside KUA
 		alamatAkad = dataFormPendaftaran.JadwalDanLokasi.AlamatNikah
@@ -677,6 +754,7 @@ func (h *InDB) CreateMarriageRegistrationForm(c *gin.Context) {
 		Latitude:            latitude,
 		Longitude:           longitude,
 		Status_pendaftaran:  structs.StatusPendaftaranMenungguVerifikasi,
+		Kua_id:              kua.ID,
 		Created_at:          createdAt,
 		Updated_at:          createdAt,
 	}
@@ -692,6 +770,30 @@ func (h *InDB) CreateMarriageRegistrationForm(c *gin.Context) {
 		return
 	}
 
+	// Pesan slot KUA secara atomik: slot yang ditahan catin lain atau sudah dipesan ditolak
+	if nikahDiKUA {
+		if err := services.BookSlot(tx, kua.ID, tanggalNikah.Format("2006-01-02"), pendaftaranNikah.Waktu_nikah, userID.(string), pendaftaranNikah.ID); err != nil {
+			tx.Rollback()
+			if errors.Is(err, services.ErrSlotUnavailable) {
+				c.JSON(http.StatusConflict, gin.H{
+					"success": false,
+					"message": "Slot tidak tersedia",
+					"error":   "Slot " + pendaftaranNikah.Waktu_nikah + " pada tanggal tersebut sudah dipesan. Silakan pilih slot lain",
+					"field":   "waktu_nikah",
+					"type":    "duplicate",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Database error",
+				"error":   "Gagal memesan slot nikah",
+				"type":    "database",
+			})
+			return
+		}
+	}
+
 	// Create marriage guardian
 	waliNikah := structs.WaliNikah{
 		Pendaftaran_id:   pendaftaranNikah.ID,
@@ -717,6 +819,31 @@ func (h *InDB) CreateMarriageRegistrationForm(c *gin.Context) {
 		return
 	}
 
+	// User yang belum memilih KUA mengikuti KUA pendaftaran pertamanya
+	if err := tx.Model(&structs.Users{}).Where("user_id = ? AND kua_id = 0", userID.(string)).Update("kua_id", kua.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Database error",
+			"error":   "Gagal menyimpan KUA user",
+			"type":    "database",
+		})
+		return
+	}
+
+	// Catat awal riwayat status pendaftaran
+	actor := services.TransitionActor{UserID: userID.(string), Role: c.GetString("role"), KuaID: kua.ID}
+	if err := services.RecordStatusHistory(tx, pendaftaranNikah.ID, "", pendaftaranNikah.Status_pendaftaran, structs.RiwayatAksiPendaftaranBaru, actor, ""); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Database error",
+			"error":   "Gagal mencatat riwayat status pendaftaran",
+			"type":    "database",
+		})
+		return
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -801,22 +928,20 @@ func (h *InDB) MarkAsVisited(c *gin.Context) {
 		return
 	}
 
-	// Check if registration is in correct status
-	if pendaftaran.Status_pendaftaran != structs.StatusPendaftaranBerkasDiterima {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "Status tidak sesuai",
-			"error":   "Pendaftaran harus dalam status 'Berkas Diterima' untuk menandai kunjungan",
-			"type":    "validation",
-		})
-		return
-	}
-
-	// Update status to indicate they have visited with documents
-	pendaftaran.Status_pendaftaran = structs.StatusPendaftaranMenungguPenugasan
-	pendaftaran.Updated_at = time.Now()
-
-	if err := h.DB.Save(&pendaftaran).Error; err != nil {
+	// Update status to indicate they have visited with documents (via state machine)
+	transitionService := services.NewStatusTransitionService(h.DB)
+	actor := services.TransitionActor{UserID: userID.(string), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	if _, err := transitionService.Apply(&pendaftaran, structs.StatusPendaftaranMenungguPenugasan, actor, ""); err != nil {
+		var transitionErr *services.TransitionError
+		if errors.As(err, &transitionErr) {
+			c.JSON(transitionErr.StatusCode(), gin.H{
+				"success": false,
+				"message": "Status tidak sesuai",
+				"error":   transitionErr.Error(),
+				"type":    transitionErr.Type,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"message": "Database error",
@@ -880,17 +1005,14 @@ func (h *InDB) UpdateWeddingAddress(c *gin.Context) {
 		return
 	}
 
-	// Check if registration exists
-	var pendaftaran structs.PendaftaranNikah
-	if err := h.DB.Where("id = ?", registrationID).First(&pendaftaran).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"message": "Pendaftaran tidak ditemukan",
-			"error":   "Pendaftaran dengan ID tersebut tidak ditemukan",
-			"type":    "not_found",
-		})
+	// Check if registration exists (hanya pendaftaran di KUA user)
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	loaded, err := services.NewAccessPolicy(h.DB).LoadRegistration(actor, registrationID, services.AccessEdit)
+	if err != nil {
+		respondRegistrationAccessError(c, err)
 		return
 	}
+	pendaftaran := *loaded
 
 	// Check if wedding location is outside KUA
 	if pendaftaran.Tempat_nikah != "Di Luar KUA" {
@@ -1011,8 +1133,9 @@ func (h *InDB) GetAllMarriageRegistrations(c *gin.Context) {
 	}
 	offset := (pageInt - 1) * limitInt
 
-	// Build query
-	query := h.DB.Model(&structs.PendaftaranNikah{})
+	// Build query (hanya pendaftaran di KUA user)
+	kuaScope := services.TenantScope(c.GetUint("kua_id"))
+	query := h.DB.Model(&structs.PendaftaranNikah{}).Scopes(kuaScope)
 
 	// Apply filters
 	if status != "" {
@@ -1066,7 +1189,7 @@ func (h *InDB) GetAllMarriageRegistrations(c *gin.Context) {
 
 	// Get total count for pagination
 	var total int64
-	countQuery := h.DB.Model(&structs.PendaftaranNikah{})
+	countQuery := h.DB.Model(&structs.PendaftaranNikah{}).Scopes(kuaScope)
 
 	// Apply same filters to count query
 	if status != "" {
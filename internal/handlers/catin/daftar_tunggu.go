@@ -0,0 +1,171 @@
+package catin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== DAFTAR TUNGGU ====================
+// Catin yang mendapati tanggal nikah di balai KUA atau sesi bimbingan sudah penuh bisa masuk daftar tunggu.
+// Saat ada tempat kosong, catin terdepan mendapat tawaran (notifikasi) yang harus diterima sebelum
+// berlaku_sampai; tawaran yang tidak diterima diteruskan ke antrean berikutnya.
+
+// GabungDaftarTungguTanggal memasukkan catin ke daftar tunggu tanggal nikah di balai KUA yang penuh
+func (h *InDB) GabungDaftarTungguTanggal(c *gin.Context) {
+	var input struct {
+		KuaID   uint   `json:"kua_id"`
+		Tanggal string `json:"tanggal" binding:"required"` // YYYY-MM-DD
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Format data tidak valid",
+			"error":   "Field tanggal (YYYY-MM-DD) wajib diisi",
+			"type":    "validation",
+		})
+		return
+	}
+
+	tanggal, err := time.Parse("2006-01-02", input.Tanggal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Format data tidak valid",
+			"error":   "Format tanggal tidak valid (YYYY-MM-DD)",
+			"field":   "tanggal",
+			"type":    "format",
+		})
+		return
+	}
+
+	kua, err := services.NewKUAService(h.DB).Resolve(input.KuaID, "", "", c.GetUint("kua_id"))
+	if err != nil {
+		if errors.Is(err, services.ErrKUARequired) || errors.Is(err, services.ErrKUANotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Validasi gagal",
+				"error":   err.Error() + ". Pilih KUA dari daftar GET /kua",
+				"field":   "kua_id",
+				"type":    "required",
+			})
+			return
+		}
+		respondDaftarTungguError(c, err)
+		return
+	}
+
+	entry, err := services.NewWaitlistService(h.DB).JoinDate(kua, tanggal, c.GetString("user_id"))
+	if err != nil {
+		respondDaftarTungguError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Berhasil masuk daftar tunggu tanggal nikah. Anda akan diberi tahu jika ada slot kosong",
+		"data":    entry,
+	})
+}
+
+// GabungDaftarTungguBimbingan memasukkan catin ke daftar tunggu sesi bimbingan perkawinan yang penuh
+func (h *InDB) GabungDaftarTungguBimbingan(c *gin.Context) {
+	entry, err := services.NewWaitlistService(h.DB).JoinBimbingan(c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		respondDaftarTungguError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Berhasil masuk daftar tunggu bimbingan perkawinan. Anda akan diberi tahu jika ada kursi kosong",
+		"data":    entry,
+	})
+}
+
+// GetDaftarTungguSaya menampilkan daftar tunggu milik catin beserta posisi antrean dan tawaran yang berlaku
+func (h *InDB) GetDaftarTungguSaya(c *gin.Context) {
+	list, err := services.NewWaitlistService(h.DB).ListMine(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Database error",
+			"error":   "Gagal mengambil daftar tunggu",
+			"type":    "database",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Daftar tunggu berhasil diambil",
+		"data": gin.H{
+			"total":         len(list),
+			"daftar_tunggu": list,
+		},
+	})
+}
+
+// TerimaTawaranDaftarTunggu menerima tawaran slot nikah atau kursi bimbingan dari daftar tunggu
+func (h *InDB) TerimaTawaranDaftarTunggu(c *gin.Context) {
+	entry, err := services.NewWaitlistService(h.DB).Accept(c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		respondDaftarTungguError(c, err)
+		return
+	}
+
+	message := "Tawaran diterima, Anda sudah terdaftar di bimbingan perkawinan"
+	if entry.Jenis == structs.DaftarTungguJenisTanggal {
+		message = "Tawaran diterima. Slot nikah " + entry.Tanggal + " pukul " + entry.Waktu_tawaran +
+			" ditahan untuk Anda, selesaikan pendaftaran nikah dengan jadwal tersebut"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+		"data":    entry,
+	})
+}
+
+// KeluarDaftarTunggu membatalkan antrean atau menolak tawaran daftar tunggu milik catin
+func (h *InDB) KeluarDaftarTunggu(c *gin.Context) {
+	entry, err := services.NewWaitlistService(h.DB).Cancel(c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		respondDaftarTungguError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Berhasil keluar dari daftar tunggu",
+		"data":    entry,
+	})
+}
+
+// respondDaftarTungguError memetakan error WaitlistService ke response HTTP
+func respondDaftarTungguError(c *gin.Context, err error) {
+	status, message, errType := http.StatusInternalServerError, "Gagal memproses daftar tunggu", "database"
+	switch {
+	case errors.Is(err, services.ErrDaftarTungguInvalid):
+		status, message, errType = http.StatusBadRequest, "Tidak dapat masuk daftar tunggu", "validation"
+	case errors.Is(err, services.ErrDaftarTungguNotFull):
+		status, message, errType = http.StatusConflict, "Masih tersedia", "available"
+	case errors.Is(err, services.ErrDaftarTungguNotFound):
+		status, message, errType = http.StatusNotFound, "Daftar tunggu tidak ditemukan", "not_found"
+	case errors.Is(err, services.ErrDaftarTungguExists), errors.Is(err, services.ErrDaftarTungguProcessed):
+		status, message, errType = http.StatusConflict, "Daftar tunggu tidak dapat diproses", "conflict"
+	case errors.Is(err, services.ErrDaftarTungguExpired):
+		status, message, errType = http.StatusGone, "Tawaran kedaluwarsa", "expired"
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": message,
+		"error":   err.Error(),
+		"type":    errType,
+	})
+}
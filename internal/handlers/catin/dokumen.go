@@ -0,0 +1,215 @@
+package catin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== BERKAS PERSYARATAN ====================
+// Catin (atau petugas KUA di loket) mengunggah scan berkas persyaratan per jenis dan pihak
+// (Calon Suami, Calon Istri, atau Bersama). Checklist dokumen disusun dari keadaan pasangan
+// (status perkawinan, umur, pekerjaan) dan setiap dokumen direview petugas atau penghulu.
+
+// UnggahDokumen mengunggah satu berkas persyaratan (multipart: jenis, pihak, file)
+func (h *InDB) UnggahDokumen(c *gin.Context) {
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pendaftaran, err := services.NewAccessPolicy(h.DB).LoadRegistration(actor, c.Param("id"), services.AccessEdit)
+	if err != nil {
+		respondRegistrationAccessError(c, err)
+		return
+	}
+
+	// Batasi body sebelum multipart diparse; batas per jenis dokumen dicek di service
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.DokumenMaksUkuran+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"message": "File terlalu besar",
+				"error":   fmt.Sprintf("Ukuran file maksimal %d MB", services.DokumenMaksUkuran>>20),
+				"type":    "too_large",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Format data tidak valid",
+			"error":   "File dokumen (field file) wajib diunggah",
+			"field":   "file",
+			"type":    "required",
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Format data tidak valid",
+			"error":   "File tidak dapat dibaca",
+			"type":    "validation",
+		})
+		return
+	}
+	defer file.Close()
+
+	dokumen, err := services.NewDokumenService(h.DB).Upload(
+		pendaftaran,
+		strings.TrimSpace(c.PostForm("jenis")),
+		strings.TrimSpace(c.PostForm("pihak")),
+		fileHeader.Filename,
+		file,
+		fileHeader.Size,
+		actor.UserID,
+	)
+	if err != nil {
+		respondDokumenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Dokumen berhasil diunggah dan menunggu pemeriksaan petugas",
+		"data":    dokumen,
+	})
+}
+
+// GetDokumenPendaftaran menampilkan dokumen yang sudah diunggah beserta checklist dokumen yang dibutuhkan
+func (h *InDB) GetDokumenPendaftaran(c *gin.Context) {
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pendaftaran, err := services.NewAccessPolicy(h.DB).LoadRegistration(actor, c.Param("id"), services.AccessView)
+	if err != nil {
+		respondRegistrationAccessError(c, err)
+		return
+	}
+
+	list, err := services.NewDokumenService(h.DB).List(pendaftaran.ID)
+	if err != nil {
+		respondDokumenError(c, err)
+		return
+	}
+	checklist, err := services.NewDokumenChecklistService(h.DB).Build(pendaftaran)
+	if err != nil {
+		respondDokumenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Dokumen pendaftaran berhasil diambil",
+		"data": gin.H{
+			"pendaftaran_id": pendaftaran.ID,
+			"dokumen":        list,
+			"checklist":      checklist,
+			"lengkap":        services.ChecklistLengkap(checklist),
+		},
+	})
+}
+
+// GetChecklistDokumen menampilkan dokumen yang wajib dilengkapi pendaftaran, diturunkan dari data calon pasangan
+// dan wali nikah, beserta item yang masih kurang. Staff tidak bisa menerima berkas sebelum checklist lengkap.
+func (h *InDB) GetChecklistDokumen(c *gin.Context) {
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pendaftaran, err := services.NewAccessPolicy(h.DB).LoadRegistration(actor, c.Param("id"), services.AccessView)
+	if err != nil {
+		respondRegistrationAccessError(c, err)
+		return
+	}
+
+	checklist, err := services.NewDokumenChecklistService(h.DB).Build(pendaftaran)
+	if err != nil {
+		respondDokumenError(c, err)
+		return
+	}
+
+	kurang := services.ChecklistKurang(checklist)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Checklist dokumen berhasil diambil",
+		"data": gin.H{
+			"pendaftaran_id": pendaftaran.ID,
+			"checklist":      checklist,
+			"lengkap":        len(kurang) == 0,
+			"kurang":         kurang,
+		},
+	})
+}
+
+// UnduhDokumen mengirim isi file dokumen kepada pemilik pendaftaran atau petugas yang berhak
+func (h *InDB) UnduhDokumen(c *gin.Context) {
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	dokumenService := services.NewDokumenService(h.DB)
+	dokumen, _, err := dokumenService.Load(actor, c.Param("id"), services.AccessView)
+	if err != nil {
+		respondDokumenError(c, err)
+		return
+	}
+
+	file, err := dokumenService.Open(dokumen)
+	if err != nil {
+		respondDokumenError(c, err)
+		return
+	}
+	defer file.Close()
+
+	c.DataFromReader(http.StatusOK, dokumen.Ukuran, dokumen.Tipe_mime, file, map[string]string{
+		"Content-Disposition": fmt.Sprintf("inline; filename=%q", dokumen.Nama_file),
+		"Cache-Control":       "private, no-store",
+		"ETag":                `"` + dokumen.Checksum + `"`,
+	})
+}
+
+// HapusDokumen menghapus dokumen yang belum diterima petugas (mis. salah unggah)
+func (h *InDB) HapusDokumen(c *gin.Context) {
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	dokumenService := services.NewDokumenService(h.DB)
+	dokumen, pendaftaran, err := dokumenService.Load(actor, c.Param("id"), services.AccessEdit)
+	if err != nil {
+		respondDokumenError(c, err)
+		return
+	}
+
+	if err := dokumenService.Delete(dokumen, pendaftaran); err != nil {
+		respondDokumenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Dokumen berhasil dihapus",
+		"data":    gin.H{"id": dokumen.ID},
+	})
+}
+
+// respondDokumenError memetakan error DokumenService ke response HTTP
+func respondDokumenError(c *gin.Context, err error) {
+	status, message, errType := http.StatusInternalServerError, "Gagal memproses dokumen", "database"
+	switch {
+	case errors.Is(err, services.ErrDokumenInvalid):
+		status, message, errType = http.StatusBadRequest, "Dokumen tidak valid", "validation"
+	case errors.Is(err, services.ErrDokumenTooLarge):
+		status, message, errType = http.StatusRequestEntityTooLarge, "File terlalu besar", "too_large"
+	case errors.Is(err, services.ErrDokumenNotFound):
+		status, message, errType = http.StatusNotFound, "Dokumen tidak ditemukan", "not_found"
+	case errors.Is(err, services.ErrDokumenLocked):
+		status, message, errType = http.StatusConflict, "Dokumen tidak dapat diubah", "conflict"
+	case errors.Is(err, services.ErrAccessDenied):
+		status, message, errType = http.StatusForbidden, "Akses ditolak", "forbidden"
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": message,
+		"error":   err.Error(),
+		"type":    errType,
+	})
+}
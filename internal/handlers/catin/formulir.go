@@ -0,0 +1,44 @@
+package catin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnduhFormulir mencetak formulir nikah model N1-N4 atau NB sebagai PDF.
+// N1 dan N4 dicetak per calon dengan query pihak=suami|istri.
+func (h *InDB) UnduhFormulir(c *gin.Context) {
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pendaftaran, err := services.NewAccessPolicy(h.DB).LoadRegistration(actor, c.Param("id"), services.AccessView)
+	if err != nil {
+		respondRegistrationAccessError(c, err)
+		return
+	}
+
+	out, filename, err := services.NewFormulirService(h.DB).Generate(pendaftaran, c.Param("model"), c.Query("pihak"))
+	if err != nil {
+		status, message, errType := http.StatusInternalServerError, "Gagal mencetak formulir", "database"
+		switch {
+		case errors.Is(err, services.ErrFormulirInvalid):
+			status, message, errType = http.StatusBadRequest, "Formulir tidak valid", "validation"
+		case errors.Is(err, services.ErrFormulirIncomplete):
+			status, message, errType = http.StatusUnprocessableEntity, "Data pendaftaran belum lengkap", "incomplete"
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": message,
+			"error":   err.Error(),
+			"type":    errType,
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "application/pdf", out)
+}
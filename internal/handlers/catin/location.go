@@ -2,13 +2,14 @@ package catin
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"time"
 
-	"simnikah/internal/models"
+	"simnikah/internal/services"
 	"simnikah/pkg/cache"
 
 	"github.com/gin-gonic/gin"
@@ -224,13 +225,11 @@ func (h *InDB) UpdateWeddingLocationWithCoordinates(c *gin.Context) {
 		return
 	}
 
-	// Check if registration exists and belongs to this user
-	var pendaftaran structs.PendaftaranNikah
-	if err := h.DB.Where("id = ? AND pendaftar_id = ?", registrationID, userID.(string)).First(&pendaftaran).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"message": "Pendaftaran tidak ditemukan",
-		})
+	// Catin hanya pendaftarannya sendiri; staff/kepala KUA semua pendaftaran; penghulu tidak boleh mengubah
+	actor := services.TransitionActor{UserID: userID.(string), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pendaftaran, err := services.NewAccessPolicy(h.DB).LoadRegistration(actor, registrationID, services.AccessEdit)
+	if err != nil {
+		respondRegistrationAccessError(c, err)
 		return
 	}
 
@@ -273,7 +272,7 @@ func (h *InDB) UpdateWeddingLocationWithCoordinates(c *gin.Context) {
 		updates["longitude"] = longitude
 	}
 
-	if err := h.DB.Model(&pendaftaran).Updates(updates).Error; err != nil {
+	if err := h.DB.Model(pendaftaran).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"message": "Gagal mengupdate lokasi",
@@ -283,7 +282,7 @@ func (h *InDB) UpdateWeddingLocationWithCoordinates(c *gin.Context) {
 	}
 
 	// Get updated data
-	h.DB.Where("id = ?", registrationID).First(&pendaftaran)
+	h.DB.Where("id = ?", registrationID).First(pendaftaran)
 
 	response := gin.H{
 		"pendaftaran_id":    pendaftaran.ID,
@@ -312,13 +311,11 @@ func (h *InDB) UpdateWeddingLocationWithCoordinates(c *gin.Context) {
 func (h *InDB) GetWeddingLocationDetail(c *gin.Context) {
 	registrationID := c.Param("id")
 
-	// Get registration
-	var pendaftaran structs.PendaftaranNikah
-	if err := h.DB.Where("id = ?", registrationID).First(&pendaftaran).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"message": "Pendaftaran tidak ditemukan",
-		})
+	// Get registration (catin: miliknya sendiri, penghulu: yang ditugaskan, staff/kepala KUA: semua)
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pendaftaran, err := services.NewAccessPolicy(h.DB).LoadRegistration(actor, registrationID, services.AccessView)
+	if err != nil {
+		respondRegistrationAccessError(c, err)
 		return
 	}
 
@@ -362,6 +359,27 @@ func (h *InDB) GetWeddingLocationDetail(c *gin.Context) {
 	})
 }
 
+// respondRegistrationAccessError memetakan error AccessPolicy ke response HTTP
+func respondRegistrationAccessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAccessDenied):
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "Akses ditolak",
+		})
+	case errors.Is(err, services.ErrResourceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Pendaftaran tidak ditemukan",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Gagal mengambil pendaftaran",
+		})
+	}
+}
+
 // SearchAddress - Search alamat menggunakan Nominatim API (untuk autocomplete)
 func (h *InDB) SearchAddress(c *gin.Context) {
 	query := c.Query("q")
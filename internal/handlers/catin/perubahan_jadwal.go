@@ -0,0 +1,193 @@
+package catin
+
+import (
+	"errors"
+	"net/http"
+
+	structs "simnikah/internal/models"
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== PERUBAHAN JADWAL & PEMBATALAN ====================
+// Setelah pendaftaran diajukan, catin tidak bisa mengubah tanggal/waktu akad secara langsung.
+// Catin mengajukan perubahan jadwal yang diproses staff (PUT /perubahan-jadwal/:id/proses),
+// atau membatalkan pendaftaran sehingga slot balai KUA dan penugasan penghulu dilepas.
+
+// AjukanPerubahanJadwal mengajukan perubahan tanggal/waktu akad untuk pendaftaran milik catin
+func (h *InDB) AjukanPerubahanJadwal(c *gin.Context) {
+	if c.GetString("role") != structs.UserRoleUserBiasa {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "Akses ditolak",
+			"error":   "Petugas KUA mengubah jadwal langsung melalui PUT /simnikah/jadwal/:id",
+			"type":    "forbidden",
+		})
+		return
+	}
+
+	var input services.PerubahanJadwalInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Format data tidak valid",
+			"error":   "Format data tidak valid: " + err.Error(),
+			"type":    "validation",
+		})
+		return
+	}
+
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pendaftaran, err := services.NewAccessPolicy(h.DB).LoadRegistration(actor, c.Param("id"), services.AccessEdit)
+	if err != nil {
+		respondRegistrationAccessError(c, err)
+		return
+	}
+
+	pengajuan, change, err := services.NewPerubahanJadwalService(h.DB).Create(pendaftaran, input, actor.UserID)
+	if err != nil {
+		respondPerubahanJadwalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Pengajuan perubahan jadwal berhasil dikirim dan menunggu persetujuan staff",
+		"data": gin.H{
+			"pengajuan": pengajuan,
+			"sebelum":   change.Sebelum,
+			"sesudah":   change.Sesudah,
+		},
+	})
+}
+
+// GetPerubahanJadwalPendaftaran menampilkan riwayat pengajuan perubahan jadwal sebuah pendaftaran
+func (h *InDB) GetPerubahanJadwalPendaftaran(c *gin.Context) {
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pendaftaran, err := services.NewAccessPolicy(h.DB).LoadRegistration(actor, c.Param("id"), services.AccessView)
+	if err != nil {
+		respondRegistrationAccessError(c, err)
+		return
+	}
+
+	list, err := services.NewPerubahanJadwalService(h.DB).ListForRegistration(pendaftaran.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Database error",
+			"error":   "Gagal mengambil pengajuan perubahan jadwal",
+			"type":    "database",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Pengajuan perubahan jadwal berhasil diambil",
+		"data": gin.H{
+			"total":     len(list),
+			"pengajuan": list,
+		},
+	})
+}
+
+// BatalkanPerubahanJadwal membatalkan pengajuan perubahan jadwal milik catin yang belum diproses
+func (h *InDB) BatalkanPerubahanJadwal(c *gin.Context) {
+	pengajuan, err := services.NewPerubahanJadwalService(h.DB).Cancel(c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		respondPerubahanJadwalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Pengajuan perubahan jadwal dibatalkan",
+		"data":    pengajuan,
+	})
+}
+
+// BatalkanPendaftaran membatalkan pendaftaran nikah (catin pemilik atau petugas KUA).
+// Slot balai KUA, penugasan penghulu, dan kursi bimbingan yang belum diikuti dilepas;
+// penghulu yang ditugaskan diberi tahu.
+func (h *InDB) BatalkanPendaftaran(c *gin.Context) {
+	var input struct {
+		Alasan string `json:"alasan" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Format data tidak valid",
+			"error":   "Alasan pembatalan wajib diisi",
+			"type":    "validation",
+		})
+		return
+	}
+
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pendaftaran, err := services.NewAccessPolicy(h.DB).LoadRegistration(actor, c.Param("id"), services.AccessEdit)
+	if err != nil {
+		respondRegistrationAccessError(c, err)
+		return
+	}
+
+	if err := services.NewPerubahanJadwalService(h.DB).CancelRegistration(pendaftaran, actor, input.Alasan); err != nil {
+		respondPerubahanJadwalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Pendaftaran nikah berhasil dibatalkan",
+		"data": gin.H{
+			"id":                 pendaftaran.ID,
+			"nomor_pendaftaran":  pendaftaran.Nomor_pendaftaran,
+			"status_pendaftaran": pendaftaran.Status_pendaftaran,
+			"alasan":             pendaftaran.Catatan,
+			"updated_at":         pendaftaran.Updated_at,
+		},
+	})
+}
+
+// respondPerubahanJadwalError memetakan error pengajuan perubahan jadwal dan pembatalan ke response HTTP
+func respondPerubahanJadwalError(c *gin.Context, err error) {
+	var conflict *services.ScheduleConflict
+	var transitionErr *services.TransitionError
+	status, message, errType := http.StatusInternalServerError, "Gagal memproses pengajuan", "database"
+	switch {
+	case errors.Is(err, services.ErrPerubahanJadwalInvalid), errors.Is(err, services.ErrJadwalInvalid):
+		status, message, errType = http.StatusBadRequest, "Jadwal tidak valid", "validation"
+	case errors.Is(err, services.ErrPerubahanJadwalNotFound):
+		status, message, errType = http.StatusNotFound, "Pengajuan tidak ditemukan", "not_found"
+	case errors.Is(err, services.ErrPerubahanJadwalProcessed), errors.Is(err, services.ErrPerubahanJadwalPending):
+		status, message, errType = http.StatusConflict, "Pengajuan tidak dapat diproses", "conflict"
+	case errors.Is(err, services.ErrJadwalLocked):
+		status, message, errType = http.StatusConflict, "Jadwal pendaftaran yang sudah selesai, ditolak, atau dibatalkan tidak bisa diubah", "conflict"
+	case errors.Is(err, services.ErrSlotUnavailable):
+		status, message, errType = http.StatusConflict, "Slot balai KUA pada jadwal tersebut sudah dipesan", "conflict"
+	case errors.As(err, &conflict):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Jadwal bentrok",
+			"error":   conflict.Message,
+			"type":    conflict.Type,
+			"details": conflict.Details,
+		})
+		return
+	case errors.As(err, &transitionErr):
+		c.JSON(transitionErr.StatusCode(), gin.H{
+			"success": false,
+			"message": "Status tidak sesuai",
+			"error":   transitionErr.Error(),
+			"type":    transitionErr.Type,
+		})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": message,
+		"error":   err.Error(),
+		"type":    errType,
+	})
+}
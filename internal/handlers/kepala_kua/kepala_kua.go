@@ -1,10 +1,12 @@
 package kepala_kua
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"simnikah/internal/models"
+	"simnikah/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -45,6 +47,14 @@ func (h *InDB) AssignPenghulu(c *gin.Context) {
 		})
 		return
 	}
+	if err := services.ValidateCatatan(input.Catatan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Format data tidak valid",
+			"error":   err.Error(),
+		})
+		return
+	}
 
 	// Check if registration exists
 	var pendaftaran structs.PendaftaranNikah
@@ -57,17 +67,6 @@ func (h *InDB) AssignPenghulu(c *gin.Context) {
 		return
 	}
 
-	// Check if registration is in correct status for penghulu assignment
-	// Note: "Berkas Disetujui" status might be a custom status or should be added to constants
-	if pendaftaran.Status_pendaftaran != "Berkas Disetujui" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "Status tidak sesuai",
-			"error":   "Pendaftaran harus dalam status 'Berkas Disetujui' untuk ditugaskan ke penghulu",
-		})
-		return
-	}
-
 	// Check if penghulu exists and is active
 	var penghulu structs.Penghulu
 	if err := h.DB.Where("id = ? AND status = ?", input.PenghuluID, structs.PenghuluStatusAktif).First(&penghulu).Error; err != nil {
@@ -79,16 +78,24 @@ func (h *InDB) AssignPenghulu(c *gin.Context) {
 		return
 	}
 
-	// Update registration with penghulu assignment
-	pendaftaran.Status_pendaftaran = structs.StatusPendaftaranPenghuluDitugaskan
+	// Update registration with penghulu assignment, status diubah melalui state machine
 	pendaftaran.Penghulu_id = &input.PenghuluID
 	pendaftaran.Penghulu_assigned_by = kepalaKuaID.(string)
 	now := time.Now()
 	pendaftaran.Penghulu_assigned_at = &now
-	pendaftaran.Catatan = input.Catatan
-	pendaftaran.Updated_at = time.Now()
 
-	if err := h.DB.Save(&pendaftaran).Error; err != nil {
+	transitionService := services.NewStatusTransitionService(h.DB)
+	actor := services.TransitionActor{UserID: kepalaKuaID.(string), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	if _, err := transitionService.Apply(&pendaftaran, structs.StatusPendaftaranMenungguVerifikasiPenghulu, actor, input.Catatan); err != nil {
+		var transitionErr *services.TransitionError
+		if errors.As(err, &transitionErr) {
+			c.JSON(transitionErr.StatusCode(), gin.H{
+				"success": false,
+				"message": "Status tidak sesuai",
+				"error":   transitionErr.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"message": "Database error",
@@ -0,0 +1,82 @@
+package notification
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== FEED KALENDER (iCalendar) ====================
+// Setiap user mendapat URL langganan .ics bertanda tangan yang bisa ditambahkan ke Google Calendar,
+// Outlook, atau aplikasi kalender lain. URL tidak butuh login; siapa pun yang memegang URL bisa membaca
+// jadwalnya, sehingga user bisa mengganti URL (URL lama langsung tidak berlaku) atau mencabutnya.
+
+// GetKalenderFeed mengembalikan URL langganan kalender milik user yang login, membuat feed jika belum ada
+func (h *InDB) GetKalenderFeed(c *gin.Context) {
+	h.respondKalenderFeed(c, false, "URL kalender berhasil diambil")
+}
+
+// ResetKalenderFeed mengganti URL langganan kalender; URL lama tidak berlaku lagi
+func (h *InDB) ResetKalenderFeed(c *gin.Context) {
+	h.respondKalenderFeed(c, true, "URL kalender berhasil diganti, perbarui langganan di aplikasi kalender Anda")
+}
+
+// CabutKalenderFeed mencabut URL langganan kalender milik user yang login
+func (h *InDB) CabutKalenderFeed(c *gin.Context) {
+	if err := services.NewCalendarFeedService(h.DB).Revoke(c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencabut URL kalender"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "URL kalender berhasil dicabut"})
+}
+
+// GetKalenderFeedICS menyajikan feed iCalendar dari URL bertanda tangan (tanpa login)
+func (h *InDB) GetKalenderFeedICS(c *gin.Context) {
+	feedService := services.NewCalendarFeedService(h.DB)
+	user, err := feedService.Verify(c.Param("token"))
+	if err != nil {
+		if errors.Is(err, services.ErrKalenderFeedInvalid) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat kalender"})
+		return
+	}
+
+	cal, err := feedService.Build(user)
+	if err != nil {
+		if errors.Is(err, services.ErrKalenderFeedInvalid) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat kalender"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Header("Content-Disposition", `inline; filename="simnikah.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(cal.String()))
+}
+
+// respondKalenderFeed membuat (atau mengganti) feed user lalu mengembalikan URL langganannya
+func (h *InDB) respondKalenderFeed(c *gin.Context, reset bool, message string) {
+	url, feed, err := services.NewCalendarFeedService(h.DB).Subscribe(c.GetString("user_id"), reset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat URL kalender"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data": gin.H{
+			"url":              url,
+			"refresh_menit":    int(services.KalenderFeedRefresh / time.Minute),
+			"dibuat_pada":      feed.Created_at,
+			"terakhir_diakses": feed.Terakhir_diakses,
+		},
+	})
+}
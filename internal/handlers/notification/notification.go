@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"simnikah/internal/models"
+	"simnikah/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -17,6 +18,20 @@ type InDB struct {
 	DB *gorm.DB
 }
 
+// actorFromContext mengambil user yang sedang login dari context (diisi AuthMiddleware)
+func actorFromContext(c *gin.Context) services.TransitionActor {
+	return services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+}
+
+// authorizeUserScope menolak akses ke notifikasi user lain lewat parameter :user_id
+func authorizeUserScope(c *gin.Context, userID string) bool {
+	if err := services.CheckUserScope(actorFromContext(c), userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak. Anda hanya dapat mengakses notifikasi milik sendiri"})
+		return false
+	}
+	return true
+}
+
 // CreateNotificationRequest untuk input notifikasi baru
 type CreateNotificationRequest struct {
 	User_id string `json:"user_id" binding:"required"`
@@ -112,6 +127,9 @@ func (h *InDB) GetUserNotifications(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID diperlukan"})
 		return
 	}
+	if !authorizeUserScope(c, userID) {
+		return
+	}
 
 	// Cek apakah user ada
 	var user structs.Users
@@ -220,6 +238,10 @@ func (h *InDB) GetNotificationByID(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Notifikasi tidak ditemukan"})
 		return
 	}
+	if err := services.CheckNotificationOwner(actorFromContext(c), &notification); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notifikasi tidak ditemukan"})
+		return
+	}
 
 	response := NotificationResponse{
 		ID:          notification.ID,
@@ -269,6 +291,10 @@ func (h *InDB) UpdateNotificationStatus(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Notifikasi tidak ditemukan"})
 		return
 	}
+	if err := services.CheckNotificationOwner(actorFromContext(c), &notification); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notifikasi tidak ditemukan"})
+		return
+	}
 
 	// Update status
 	if err := h.DB.Model(&notification).Update("status_baca", req.Status_baca).Error; err != nil {
@@ -304,6 +330,9 @@ func (h *InDB) MarkAllAsRead(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID diperlukan"})
 		return
 	}
+	if !authorizeUserScope(c, userID) {
+		return
+	}
 
 	// Cek apakah user ada
 	var user structs.Users
@@ -339,6 +368,10 @@ func (h *InDB) DeleteNotification(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Notifikasi tidak ditemukan"})
 		return
 	}
+	if err := services.CheckNotificationOwner(actorFromContext(c), &notification); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notifikasi tidak ditemukan"})
+		return
+	}
 
 	// Hapus notifikasi
 	if err := h.DB.Delete(&notification).Error; err != nil {
@@ -358,6 +391,9 @@ func (h *InDB) GetNotificationStats(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID diperlukan"})
 		return
 	}
+	if !authorizeUserScope(c, userID) {
+		return
+	}
 
 	// Cek apakah user ada
 	var user structs.Users
@@ -448,9 +484,9 @@ func (h *InDB) SendNotificationToRole(c *gin.Context) {
 		return
 	}
 
-	// Ambil semua user dengan role tersebut
+	// Ambil semua user dengan role tersebut di KUA pengirim
 	var users []structs.Users
-	if err := h.DB.Where("role = ? AND status = ?", req.Role, structs.UserStatusAktif).Find(&users).Error; err != nil {
+	if err := h.DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).Where("role = ? AND status = ?", req.Role, structs.UserStatusAktif).Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data user"})
 		return
 	}
@@ -0,0 +1,49 @@
+package notification
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter memasang route notifikasi per user dengan user login palsu.
+// DB sengaja nil: akses ke user lain harus ditolak sebelum query apa pun.
+func newTestRouter(loggedInUserID, role string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := &InDB{}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", loggedInUserID)
+		c.Set("role", role)
+		c.Next()
+	})
+	r.GET("/notifikasi/user/:user_id", h.GetUserNotifications)
+	r.PUT("/notifikasi/user/:user_id/mark-all-read", h.MarkAllAsRead)
+	r.GET("/notifikasi/user/:user_id/stats", h.GetNotificationStats)
+	return r
+}
+
+func TestNotificationUserScopeRejectsOtherUser(t *testing.T) {
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/notifikasi/user/USR2"},
+		{http.MethodPut, "/notifikasi/user/USR2/mark-all-read"},
+		{http.MethodGet, "/notifikasi/user/USR2/stats"},
+	}
+
+	for _, role := range []string{"user_biasa", "penghulu", "staff", "kepala_kua"} {
+		r := newTestRouter("USR1", role)
+		for _, req := range requests {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(req.method, req.path, nil))
+			if w.Code != http.StatusForbidden {
+				t.Errorf("%s %s as %s: expected 403, got %d", req.method, req.path, role, w.Code)
+			}
+		}
+	}
+}
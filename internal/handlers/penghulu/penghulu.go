@@ -1,11 +1,13 @@
 package penghulu
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"simnikah/internal/models"
+	"simnikah/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -46,6 +48,14 @@ func (h *InDB) VerifyDocuments(c *gin.Context) {
 		})
 		return
 	}
+	if err := services.ValidateCatatan(input.Catatan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Format data tidak valid",
+			"error":   err.Error(),
+		})
+		return
+	}
 
 	// Validate status
 	if input.Status != "Menunggu Pelaksanaan" && input.Status != "Ditolak" {
@@ -68,57 +78,29 @@ func (h *InDB) VerifyDocuments(c *gin.Context) {
 		return
 	}
 
-	// Check if registration is in correct status for document verification
-	if pendaftaran.Status_pendaftaran != "Menunggu Verifikasi Penghulu" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "Status tidak sesuai",
-			"error":   "Pendaftaran harus dalam status 'Menunggu Verifikasi Penghulu' untuk diverifikasi",
-		})
-		return
-	}
-
-	// Check if this penghulu is assigned to this registration
-	if pendaftaran.Penghulu_id == nil || *pendaftaran.Penghulu_id == 0 {
-		c.JSON(http.StatusForbidden, gin.H{
-			"success": false,
-			"message": "Akses ditolak",
-			"error":   "Anda tidak ditugaskan untuk pendaftaran ini",
-		})
-		return
-	}
-
-	// Get penghulu info to verify assignment
-	var penghulu structs.Penghulu
-	if err := h.DB.Where("user_id = ?", penghuluID.(string)).First(&penghulu).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{
-			"success": false,
-			"message": "Akses ditolak",
-			"error":   "Data penghulu tidak ditemukan",
-		})
-		return
-	}
-
-	if penghulu.ID != *pendaftaran.Penghulu_id {
-		c.JSON(http.StatusForbidden, gin.H{
-			"success": false,
-			"message": "Akses ditolak",
-			"error":   "Anda tidak ditugaskan untuk pendaftaran ini",
-		})
-		return
-	}
-
-	// Update registration status
-	// Jika disetujui, ubah ke "Menunggu Bimbingan"
+	// Jalankan transisi melalui state machine
+	// Jika disetujui, status menjadi "Menunggu Bimbingan". State machine juga
+	// memastikan penghulu yang login adalah penghulu yang ditugaskan.
+	targetStatus := structs.StatusPendaftaranDitolak
 	if input.Status == "Menunggu Pelaksanaan" {
-		pendaftaran.Status_pendaftaran = "Menunggu Bimbingan"
-	} else {
-		pendaftaran.Status_pendaftaran = input.Status
+		targetStatus = structs.StatusPendaftaranMenungguBimbingan
 	}
-	pendaftaran.Catatan = input.Catatan
-	pendaftaran.Updated_at = time.Now()
 
-	if err := h.DB.Save(&pendaftaran).Error; err != nil {
+	transitionService := services.NewStatusTransitionService(h.DB)
+	actor := services.TransitionActor{UserID: penghuluID.(string), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pendaftaran.Catatan = input.Catatan
+	if _, err := transitionService.Apply(&pendaftaran, targetStatus, actor, input.Catatan); err != nil {
+		var transitionErr *services.TransitionError
+		if errors.As(err, &transitionErr) {
+			c.JSON(transitionErr.StatusCode(), gin.H{
+				"success": false,
+				"message": transitionErr.Message,
+				"error":   transitionErr.Error(),
+				"type":    transitionErr.Type,
+				"alasan":  transitionErr.Reasons,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"message": "Database error",
@@ -255,3 +237,130 @@ func (h *InDB) GetAssignedRegistrations(c *gin.Context) {
 		},
 	})
 }
+
+// ==================== KETIDAKSEDIAAN (CUTI) PENGHULU ====================
+
+// penghuluFromContext mengambil data penghulu milik user yang login
+func (h *InDB) penghuluFromContext(c *gin.Context) (*structs.Penghulu, bool) {
+	var penghulu structs.Penghulu
+	if err := h.DB.Where("user_id = ?", c.GetString("user_id")).First(&penghulu).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Data penghulu tidak ditemukan",
+			"error":   "Penghulu dengan ID tersebut tidak ditemukan",
+		})
+		return nil, false
+	}
+	return &penghulu, true
+}
+
+// AjukanKetidaksediaan mengajukan cuti, sakit, tugas luar, atau ketidaksediaan berulang.
+// Pengajuan berstatus Menunggu Persetujuan sampai diproses kepala KUA.
+func (h *InDB) AjukanKetidaksediaan(c *gin.Context) {
+	penghulu, ok := h.penghuluFromContext(c)
+	if !ok {
+		return
+	}
+
+	var input services.UnavailabilityInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Format data tidak valid",
+			"error":   err.Error(),
+		})
+		return
+	}
+	input.PenghuluID = penghulu.ID
+
+	k, _, err := services.NewPenghuluAvailabilityService(h.DB).Create(penghulu.Kua_id, input, penghulu.User_id, false)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidUnavailability) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Data ketidaksediaan tidak valid",
+				"error":   err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Database error",
+			"error":   "Gagal menyimpan pengajuan ketidaksediaan",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Pengajuan ketidaksediaan berhasil dikirim dan menunggu persetujuan kepala KUA",
+		"data":    k,
+	})
+}
+
+// GetKetidaksediaanSaya menampilkan semua pengajuan ketidaksediaan milik penghulu yang login (?status=)
+func (h *InDB) GetKetidaksediaanSaya(c *gin.Context) {
+	penghulu, ok := h.penghuluFromContext(c)
+	if !ok {
+		return
+	}
+
+	list, err := services.NewPenghuluAvailabilityService(h.DB).List(penghulu.Kua_id, penghulu.ID, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Database error",
+			"error":   "Gagal mengambil data ketidaksediaan",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Data ketidaksediaan berhasil diambil",
+		"data": gin.H{
+			"penghulu":       penghulu.Nama_lengkap,
+			"ketidaksediaan": list,
+			"total":          len(list),
+		},
+	})
+}
+
+// BatalkanKetidaksediaan membatalkan pengajuan milik penghulu yang login yang belum diproses
+func (h *InDB) BatalkanKetidaksediaan(c *gin.Context) {
+	penghulu, ok := h.penghuluFromContext(c)
+	if !ok {
+		return
+	}
+
+	k, err := services.NewPenghuluAvailabilityService(h.DB).Cancel(c.Param("id"), penghulu.Kua_id, penghulu.ID, penghulu.User_id)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnavailabilityNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "Pengajuan tidak ditemukan",
+				"error":   err.Error(),
+			})
+		case errors.Is(err, services.ErrUnavailabilityProcessed):
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": "Pengajuan sudah diproses kepala KUA dan tidak bisa dibatalkan",
+				"error":   err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Database error",
+				"error":   "Gagal membatalkan pengajuan",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Pengajuan ketidaksediaan dibatalkan",
+		"data":    k,
+	})
+}
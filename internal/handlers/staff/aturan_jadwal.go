@@ -0,0 +1,84 @@
+package staff
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== ATURAN PENJADWALAN HANDLERS ====================
+// Kapasitas harian, maksimal nikah per penghulu, jeda antar nikah, jam layanan, dan hari tutup
+// disimpan per KUA dan dipakai semua endpoint kalender, ketersediaan, dan penugasan penghulu.
+
+// GetAturanJadwal menampilkan aturan penjadwalan KUA user yang login (atau ?kua_id=)
+func (h *InDB) GetAturanJadwal(c *gin.Context) {
+	kuaID := c.GetUint("kua_id")
+	if raw := c.Query("kua_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kua_id tidak valid"})
+			return
+		}
+		kuaID = uint(id)
+	}
+
+	aturan, err := services.NewSchedulingRuleService(h.DB).ForKUA(kuaID)
+	if err != nil {
+		respondKUAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Aturan penjadwalan berhasil diambil",
+		"data": gin.H{
+			"kua_id":     kuaID,
+			"aturan":     aturan,
+			"slot_waktu": aturan.TimeSlots(),
+		},
+	})
+}
+
+// UpdateAturanJadwal mengubah aturan penjadwalan KUA user yang login (izin pengaturan.manage).
+// Field yang tidak dikirim tetap memakai nilai sebelumnya.
+func (h *InDB) UpdateAturanJadwal(c *gin.Context) {
+	kuaID := c.GetUint("kua_id")
+	schedulingService := services.NewSchedulingRuleService(h.DB)
+
+	aturan, err := schedulingService.ForKUA(kuaID)
+	if err != nil {
+		respondKUAError(c, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&aturan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format data tidak valid"})
+		return
+	}
+
+	kua, err := schedulingService.Update(kuaID, aturan)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSchedulingRules) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		respondKUAError(c, err)
+		return
+	}
+
+	// Kapasitas harian atau slot waktu yang bertambah ditawarkan ke daftar tunggu
+	services.NewWaitlistService(h.DB).PromoteAfterRelease(kua.ID)
+
+	aturan = services.RulesForKUA(kua)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Aturan penjadwalan berhasil diupdate",
+		"data": gin.H{
+			"kua_id":     kua.ID,
+			"aturan":     aturan,
+			"slot_waktu": aturan.TimeSlots(),
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package staff
+
+import (
+	"net/http"
+
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDaftarTungguKUA menampilkan daftar tunggu tanggal nikah dan bimbingan di KUA (?jenis=, ?status=)
+// beserta posisi antrean, agar petugas bisa memantau tanggal dan sesi yang paling diminati
+func (h *InDB) GetDaftarTungguKUA(c *gin.Context) {
+	list, err := services.NewWaitlistService(h.DB).List(c.GetUint("kua_id"), c.Query("jenis"), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil daftar tunggu"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Daftar tunggu berhasil diambil",
+		"data": gin.H{
+			"total":         len(list),
+			"daftar_tunggu": list,
+		},
+	})
+}
@@ -0,0 +1,101 @@
+package staff
+
+import (
+	"errors"
+	"net/http"
+
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReviewDokumen menerima atau menolak berkas persyaratan yang diunggah catin.
+// Staff memeriksa dokumen di KUA-nya, penghulu hanya dokumen pendaftaran yang ditugaskan kepadanya.
+func (h *InDB) ReviewDokumen(c *gin.Context) {
+	var input struct {
+		Status  string `json:"status" binding:"required"` // "Diterima" atau "Ditolak"
+		Catatan string `json:"catatan"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field status (Diterima/Ditolak) diperlukan"})
+		return
+	}
+
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	dokumenService := services.NewDokumenService(h.DB)
+	dokumen, pendaftaran, err := dokumenService.Load(actor, c.Param("id"), services.AccessView)
+	if err == nil {
+		err = dokumenService.Review(dokumen, pendaftaran, input.Status, input.Catatan, actor.UserID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDokumenInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDokumenNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDokumenLocked):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrAccessDenied):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan review dokumen"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Dokumen " + dokumen.Jenis + " (" + dokumen.Pihak + ") " + dokumen.Status_review,
+		"data":    dokumen,
+	})
+}
+
+// TandaiKelengkapanBerkas menandai item checklist dokumen sebagai ada/tidak ada setelah petugas memeriksa
+// berkas fisik di KUA. Item yang ditandai ada dianggap lengkap meskipun tidak diunggah.
+func (h *InDB) TandaiKelengkapanBerkas(c *gin.Context) {
+	var input struct {
+		Items []services.KelengkapanInput `json:"items" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field items (jenis, pihak, ada) diperlukan"})
+		return
+	}
+
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pendaftaran, err := services.NewAccessPolicy(h.DB).LoadRegistration(actor, c.Param("id"), services.AccessEdit)
+	if err != nil {
+		if errors.Is(err, services.ErrResourceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pendaftaran tidak ditemukan"})
+			return
+		}
+		if errors.Is(err, services.ErrAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Akses ditolak"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil pendaftaran"})
+		return
+	}
+
+	checklist, err := services.NewDokumenChecklistService(h.DB).Mark(pendaftaran, input.Items, actor.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDokumenInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDokumenLocked):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan kelengkapan berkas"})
+		}
+		return
+	}
+
+	kurang := services.ChecklistKurang(checklist)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Kelengkapan berkas berhasil disimpan",
+		"data": gin.H{
+			"pendaftaran_id": pendaftaran.ID,
+			"checklist":      checklist,
+			"lengkap":        len(kurang) == 0,
+			"kurang":         kurang,
+		},
+	})
+}
@@ -0,0 +1,134 @@
+package staff
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== HARI LIBUR HANDLERS ====================
+// Hari libur nasional dan cuti bersama diisi dari file seed tahunan (migrations/hari_libur).
+// Kepala KUA menambahkan penutupan KUA sendiri; semuanya dipakai untuk menghitung hari kerja,
+// kalender ketersediaan, dan jadwal bimbingan.
+
+// GetHariLibur menampilkan hari libur yang berlaku untuk KUA pada satu tahun (?tahun=, ?kua_id=)
+func (h *InDB) GetHariLibur(c *gin.Context) {
+	kuaID := c.GetUint("kua_id")
+	if raw := c.Query("kua_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kua_id tidak valid"})
+			return
+		}
+		kuaID = uint(id)
+	}
+
+	tahun := time.Now().Year()
+	if raw := c.Query("tahun"); raw != "" {
+		t, err := strconv.Atoi(raw)
+		if err != nil || t < 1900 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tahun tidak valid"})
+			return
+		}
+		tahun = t
+	}
+
+	list, err := services.NewHolidayService(h.DB).List(kuaID, tahun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data hari libur"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Data hari libur berhasil diambil",
+		"data": gin.H{
+			"kua_id":     kuaID,
+			"tahun":      tahun,
+			"hari_libur": list,
+		},
+	})
+}
+
+// CreateHariLibur menambahkan atau memperbarui hari libur/penutupan KUA user yang login (izin hari_libur.manage)
+func (h *InDB) CreateHariLibur(c *gin.Context) {
+	var input services.HolidayEntry
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format data tidak valid"})
+		return
+	}
+
+	h.importHariLibur(c, []services.HolidayEntry{input}, http.StatusCreated, "Hari libur berhasil disimpan")
+}
+
+// ImportHariLibur mengimpor hari libur KUA dari file JSON atau CSV (multipart field "file")
+func (h *InDB) ImportHariLibur(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File hari libur (field file) diperlukan"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File tidak dapat dibaca"})
+		return
+	}
+	defer file.Close()
+
+	entries, err := services.ParseHolidayFile(file, filepath.Ext(fileHeader.Filename))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.importHariLibur(c, entries, http.StatusOK, "Hari libur berhasil diimpor")
+}
+
+// importHariLibur menyimpan entri hari libur untuk KUA user yang login
+func (h *InDB) importHariLibur(c *gin.Context, entries []services.HolidayEntry, status int, message string) {
+	kuaID := c.GetUint("kua_id")
+	if kuaID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User tidak terhubung ke KUA"})
+		return
+	}
+
+	jumlah, err := services.NewHolidayService(h.DB).Import(kuaID, entries, c.GetString("user_id"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidHoliday) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan hari libur"})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"message": message,
+		"data": gin.H{
+			"kua_id": kuaID,
+			"jumlah": jumlah,
+			"entri":  entries,
+		},
+	})
+}
+
+// DeleteHariLibur menghapus hari libur milik KUA user yang login (izin hari_libur.manage)
+func (h *InDB) DeleteHariLibur(c *gin.Context) {
+	err := services.NewHolidayService(h.DB).Delete(c.Param("id"), c.GetUint("kua_id"))
+	if err != nil {
+		if errors.Is(err, services.ErrHolidayNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hari libur tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus hari libur"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Hari libur berhasil dihapus"})
+}
@@ -0,0 +1,74 @@
+package staff
+
+import (
+	"errors"
+	"net/http"
+
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== ROLE PERMISSION HANDLERS ====================
+// Izin (permission) dipetakan ke role lewat tabel izin_roles. Kepala KUA bisa melihat katalog
+// izin beserta matriksnya dan mengganti izin sebuah role tanpa mengubah kode route. Perubahan hanya
+// berlaku untuk KUA Kepala KUA tersebut; KUA yang belum mengubah izin memakai default dari katalog.
+
+// GetRolePermissions mengambil katalog izin dan matriks izin per role di KUA user yang login (hanya Kepala KUA)
+func (h *InDB) GetRolePermissions(c *gin.Context) {
+	matrix, err := services.NewPermissionService(h.DB).Matrix(c.GetUint("kua_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil izin"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Izin per role berhasil diambil",
+		"data": gin.H{
+			"katalog": services.PermissionCatalog,
+			"role":    services.PermissionRoles,
+			"matriks": matrix,
+		},
+	})
+}
+
+// UpdateRolePermissions mengganti seluruh izin sebuah role di KUA user yang login (hanya Kepala KUA)
+func (h *InDB) UpdateRolePermissions(c *gin.Context) {
+	role := c.Param("role")
+	kuaID := c.GetUint("kua_id")
+
+	var input struct {
+		Izin []string `json:"izin" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field izin (array nama izin) diperlukan"})
+		return
+	}
+
+	permissionService := services.NewPermissionService(h.DB)
+	if err := permissionService.SetRolePermissions(kuaID, role, input.Izin, c.GetString("user_id")); err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownRole):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role tidak dikenal"})
+		case errors.Is(err, services.ErrUnknownPermission), errors.Is(err, services.ErrPermissionLockout), errors.Is(err, services.ErrKUARequired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan izin"})
+		}
+		return
+	}
+
+	permissions, err := permissionService.PermissionsForRole(kuaID, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil izin"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Izin role " + role + " berhasil diperbarui",
+		"data": gin.H{
+			"role": role,
+			"izin": permissions,
+		},
+	})
+}
@@ -0,0 +1,134 @@
+package staff
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"simnikah/internal/models"
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== KETIDAKSEDIAAN PENGHULU HANDLERS ====================
+// Penghulu mengajukan cuti/sakit/tugas luar lewat /penghulu/ketidaksediaan; kepala KUA menyetujui
+// atau menolak di sini, dan bisa langsung memblokir jadwal penghulu. Ketidaksediaan yang disetujui
+// dihormati endpoint jadwal, ketersediaan, dan penugasan penghulu, dan jadwal yang sudah ada dan
+// bentrok otomatis ditandai (GET /penghulu/konflik-ketidaksediaan).
+
+// GetKetidaksediaanPenghulu menampilkan ketidaksediaan penghulu di KUA (?penghulu_id=, ?status=)
+func (h *InDB) GetKetidaksediaanPenghulu(c *gin.Context) {
+	var penghuluID uint
+	if raw := c.Query("penghulu_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "penghulu_id tidak valid"})
+			return
+		}
+		penghuluID = uint(id)
+	}
+
+	list, err := services.NewPenghuluAvailabilityService(h.DB).List(c.GetUint("kua_id"), penghuluID, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data ketidaksediaan penghulu"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Data ketidaksediaan penghulu berhasil diambil",
+		"data": gin.H{
+			"total":          len(list),
+			"ketidaksediaan": list,
+		},
+	})
+}
+
+// CreateKetidaksediaanPenghulu mencatat ketidaksediaan penghulu yang langsung disetujui
+// (mis. blokir jadwal, tugas luar) oleh kepala KUA
+func (h *InDB) CreateKetidaksediaanPenghulu(c *gin.Context) {
+	var input services.UnavailabilityInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format data tidak valid"})
+		return
+	}
+	if input.PenghuluID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field penghulu_id diperlukan"})
+		return
+	}
+
+	k, konflik, err := services.NewPenghuluAvailabilityService(h.DB).Create(c.GetUint("kua_id"), input, c.GetString("user_id"), true)
+	if err != nil {
+		respondUnavailabilityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Ketidaksediaan penghulu berhasil dicatat",
+		"data": gin.H{
+			"ketidaksediaan": k,
+			"jadwal_bentrok": konflik,
+			"jumlah_bentrok": len(konflik),
+		},
+	})
+}
+
+// ProsesKetidaksediaanPenghulu menyetujui atau menolak pengajuan ketidaksediaan penghulu
+func (h *InDB) ProsesKetidaksediaanPenghulu(c *gin.Context) {
+	var input struct {
+		Disetujui *bool  `json:"disetujui" binding:"required"`
+		Catatan   string `json:"catatan"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field disetujui (true/false) diperlukan"})
+		return
+	}
+
+	k, konflik, err := services.NewPenghuluAvailabilityService(h.DB).Process(c.Param("id"), c.GetUint("kua_id"), *input.Disetujui, c.GetString("user_id"), input.Catatan)
+	if err != nil {
+		respondUnavailabilityError(c, err)
+		return
+	}
+
+	message := "Pengajuan ketidaksediaan ditolak"
+	if k.Status == structs.KetidaksediaanStatusDisetujui {
+		message = "Pengajuan ketidaksediaan disetujui"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data": gin.H{
+			"ketidaksediaan": k,
+			"jadwal_bentrok": konflik,
+			"jumlah_bentrok": len(konflik),
+		},
+	})
+}
+
+// BatalkanKetidaksediaanPenghulu membatalkan ketidaksediaan penghulu (menunggu atau sudah disetujui).
+// Tanda bentrok pada jadwal nikah dari ketidaksediaan ini ikut dihapus.
+func (h *InDB) BatalkanKetidaksediaanPenghulu(c *gin.Context) {
+	k, err := services.NewPenghuluAvailabilityService(h.DB).Cancel(c.Param("id"), c.GetUint("kua_id"), 0, c.GetString("user_id"))
+	if err != nil {
+		respondUnavailabilityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ketidaksediaan penghulu dibatalkan",
+		"data":    k,
+	})
+}
+
+// respondUnavailabilityError memetakan error PenghuluAvailabilityService ke response HTTP
+func respondUnavailabilityError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidUnavailability):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUnavailabilityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ketidaksediaan penghulu tidak ditemukan"})
+	case errors.Is(err, services.ErrUnavailabilityProcessed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Pengajuan ketidaksediaan sudah diproses"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses ketidaksediaan penghulu"})
+	}
+}
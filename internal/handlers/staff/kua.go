@@ -0,0 +1,252 @@
+package staff
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"simnikah/internal/models"
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ==================== KUA (TENANT) HANDLERS ====================
+// Setiap KUA adalah tenant sendiri: staff, penghulu, pendaftaran, dan bimbingan hanya terlihat
+// oleh user di KUA yang sama. Daftar KUA aktif publik agar catin bisa memilih KUA saat mendaftar.
+
+// GetAllKUA mengambil daftar KUA aktif, bisa difilter ?kecamatan= dan ?kabupaten= (publik)
+func (h *InDB) GetAllKUA(c *gin.Context) {
+	list, err := services.NewKUAService(h.DB).ListActive(c.Query("kecamatan"), c.Query("kabupaten"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data KUA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Data KUA berhasil diambil",
+		"data":    list,
+	})
+}
+
+// GetKUAByID mengambil detail KUA aktif (publik)
+func (h *InDB) GetKUAByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID KUA tidak valid"})
+		return
+	}
+
+	kua, err := services.NewKUAService(h.DB).GetActive(uint(id))
+	if err != nil {
+		respondKUAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Detail KUA berhasil diambil",
+		"data":    kua,
+	})
+}
+
+// kuaInput adalah body untuk membuat dan mengubah KUA
+type kuaInput struct {
+	Kode                 string   `json:"kode"`
+	Nama                 string   `json:"nama"`
+	Kecamatan            string   `json:"kecamatan"`
+	Kabupaten            string   `json:"kabupaten"`
+	Provinsi             string   `json:"provinsi"`
+	Alamat               string   `json:"alamat"`
+	Latitude             *float64 `json:"latitude"`
+	Longitude            *float64 `json:"longitude"`
+	NoTelepon            string   `json:"nomor_telepon"`
+	Email                string   `json:"email"`
+	KapasitasNikahHarian int      `json:"kapasitas_nikah_harian"`
+	Status               string   `json:"status"`
+}
+
+// CreateKUA menambahkan KUA baru (izin kua.create, hanya super admin)
+func (h *InDB) CreateKUA(c *gin.Context) {
+	var input kuaInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format data tidak valid"})
+		return
+	}
+
+	if strings.TrimSpace(input.Kode) == "" || strings.TrimSpace(input.Nama) == "" ||
+		strings.TrimSpace(input.Kecamatan) == "" || strings.TrimSpace(input.Kabupaten) == "" ||
+		strings.TrimSpace(input.Provinsi) == "" || strings.TrimSpace(input.Alamat) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode, nama, kecamatan, kabupaten, provinsi, dan alamat wajib diisi"})
+		return
+	}
+
+	var existing structs.KUA
+	if err := h.DB.Where("kode = ? OR (kecamatan = ? AND kabupaten = ?)", input.Kode, input.Kecamatan, input.Kabupaten).First(&existing).Error; err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode KUA atau KUA untuk kecamatan tersebut sudah terdaftar"})
+		return
+	}
+
+	now := time.Now()
+	kua := structs.KUA{
+		Kode:       strings.TrimSpace(input.Kode),
+		Nama:       strings.TrimSpace(input.Nama),
+		Kecamatan:  strings.TrimSpace(input.Kecamatan),
+		Kabupaten:  strings.TrimSpace(input.Kabupaten),
+		Provinsi:   strings.TrimSpace(input.Provinsi),
+		Alamat:     strings.TrimSpace(input.Alamat),
+		Latitude:   input.Latitude,
+		Longitude:  input.Longitude,
+		No_telepon: input.NoTelepon,
+		Email:      input.Email,
+		Status:     structs.KUAStatusAktif,
+		Created_at: now,
+		Updated_at: now,
+	}
+	services.DefaultSchedulingRules().ApplyTo(&kua)
+	if input.KapasitasNikahHarian > 0 {
+		kua.Kapasitas_nikah_harian = input.KapasitasNikahHarian
+	}
+
+	if err := h.DB.Create(&kua).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat KUA"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "KUA berhasil dibuat",
+		"data":    kua,
+	})
+}
+
+// UpdateKUA mengubah data KUA (izin kua.manage). Kepala KUA hanya bisa mengubah KUA-nya sendiri;
+// KUA lain hanya bisa diubah oleh super admin (izin kua.create).
+func (h *InDB) UpdateKUA(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID KUA tidak valid"})
+		return
+	}
+
+	if uint(id) != c.GetUint("kua_id") {
+		allowed, err := services.NewPermissionService(h.DB).HasPermission(c.GetUint("kua_id"), c.GetString("role"), structs.IzinKuaCreate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa izin akses"})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "KUA tidak ditemukan"})
+			return
+		}
+	}
+
+	var input kuaInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format data tidak valid"})
+		return
+	}
+
+	var kua structs.KUA
+	if err := h.DB.First(&kua, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "KUA tidak ditemukan"})
+		return
+	}
+
+	// Kode dan wilayah KUA tidak bisa diubah karena menjadi identitas tenant
+	if input.Nama != "" {
+		kua.Nama = input.Nama
+	}
+	if input.Alamat != "" {
+		kua.Alamat = input.Alamat
+	}
+	if input.Latitude != nil {
+		kua.Latitude = input.Latitude
+	}
+	if input.Longitude != nil {
+		kua.Longitude = input.Longitude
+	}
+	if input.NoTelepon != "" {
+		kua.No_telepon = input.NoTelepon
+	}
+	if input.Email != "" {
+		kua.Email = input.Email
+	}
+	if input.KapasitasNikahHarian > 0 {
+		kua.Kapasitas_nikah_harian = input.KapasitasNikahHarian
+	}
+	if input.Status != "" {
+		if input.Status != structs.KUAStatusAktif && input.Status != structs.KUAStatusNonaktif {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status tidak valid. Status yang tersedia: Aktif, Nonaktif"})
+			return
+		}
+		kua.Status = input.Status
+	}
+
+	kua.Updated_at = time.Now()
+	if err := h.DB.Save(&kua).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate KUA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "KUA berhasil diupdate",
+		"data":    kua,
+	})
+}
+
+// MoveUserKUA memindahkan user (staff/penghulu beserta profilnya) ke KUA lain (izin kua.manage).
+// Semua sesi user dicabut sehingga user perlu login ulang di KUA yang baru.
+func (h *InDB) MoveUserKUA(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	var input struct {
+		KuaID uint `json:"kua_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field kua_id diperlukan"})
+		return
+	}
+
+	if userID == c.GetString("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tidak dapat memindahkan akun sendiri"})
+		return
+	}
+
+	if !h.userInActorKUA(c, userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+
+	if err := services.NewKUAService(h.DB).MoveUser(userID, input.KuaID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+			return
+		}
+		respondKUAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User berhasil dipindahkan ke KUA lain",
+		"data": gin.H{
+			"user_id": userID,
+			"kua_id":  input.KuaID,
+		},
+	})
+}
+
+// respondKUAError memetakan error KUAService ke response HTTP
+func respondKUAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrKUANotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "KUA tidak ditemukan atau tidak aktif"})
+	case errors.Is(err, services.ErrKUARequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPenghuluMasihDitugaskan):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses data KUA"})
+	}
+}
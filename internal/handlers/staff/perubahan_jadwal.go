@@ -0,0 +1,89 @@
+package staff
+
+import (
+	"errors"
+	"net/http"
+
+	"simnikah/internal/models"
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== PERUBAHAN JADWAL HANDLERS ====================
+// Catin mengajukan perubahan tanggal/waktu akad lewat /pendaftaran/:id/perubahan-jadwal;
+// staff atau kepala KUA menyetujui atau menolak di sini. Persetujuan memvalidasi ulang jadwal
+// seperti PUT /jadwal/:id dan memberi tahu catin serta penghulu yang ditugaskan.
+
+// GetPerubahanJadwal menampilkan pengajuan perubahan jadwal di KUA (?status=)
+func (h *InDB) GetPerubahanJadwal(c *gin.Context) {
+	list, err := services.NewPerubahanJadwalService(h.DB).List(c.GetUint("kua_id"), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil pengajuan perubahan jadwal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pengajuan perubahan jadwal berhasil diambil",
+		"data": gin.H{
+			"total":     len(list),
+			"pengajuan": list,
+		},
+	})
+}
+
+// ProsesPerubahanJadwal menyetujui atau menolak pengajuan perubahan jadwal dari catin
+func (h *InDB) ProsesPerubahanJadwal(c *gin.Context) {
+	var input struct {
+		Disetujui *bool  `json:"disetujui" binding:"required"`
+		Catatan   string `json:"catatan"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field disetujui (true/false) diperlukan"})
+		return
+	}
+
+	actor := services.TransitionActor{UserID: c.GetString("user_id"), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
+	pengajuan, change, err := services.NewPerubahanJadwalService(h.DB).Process(c.Param("id"), c.GetUint("kua_id"), *input.Disetujui, actor, input.Catatan)
+	if err != nil {
+		respondPerubahanJadwalError(c, err)
+		return
+	}
+
+	message := "Pengajuan perubahan jadwal ditolak"
+	if pengajuan.Status == structs.PerubahanJadwalStatusDisetujui {
+		message = "Pengajuan perubahan jadwal disetujui, jadwal nikah telah diubah"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data": gin.H{
+			"pengajuan": pengajuan,
+			"perubahan": change,
+		},
+	})
+}
+
+// respondPerubahanJadwalError memetakan error PerubahanJadwalService ke response HTTP
+func respondPerubahanJadwalError(c *gin.Context, err error) {
+	var conflict *services.ScheduleConflict
+	switch {
+	case errors.Is(err, services.ErrPerubahanJadwalNotFound), errors.Is(err, services.ErrJadwalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pengajuan perubahan jadwal tidak ditemukan"})
+	case errors.Is(err, services.ErrPerubahanJadwalProcessed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Pengajuan perubahan jadwal sudah diproses"})
+	case errors.Is(err, services.ErrJadwalLocked):
+		c.JSON(http.StatusConflict, gin.H{"error": "Jadwal pendaftaran yang sudah selesai, ditolak, atau dibatalkan tidak bisa diubah"})
+	case errors.Is(err, services.ErrJadwalInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSlotUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Slot balai KUA pada jadwal tersebut sudah dipesan atau sedang ditahan catin lain"})
+	case errors.As(err, &conflict):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   conflict.Message,
+			"type":    conflict.Type,
+			"details": conflict.Details,
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses pengajuan perubahan jadwal"})
+	}
+}
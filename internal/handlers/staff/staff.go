@@ -1,8 +1,10 @@
 package staff
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -51,49 +53,15 @@ func (h *InDB) CreateStaffKUA(c *gin.Context) {
 		return
 	}
 
-	// Check if username/email already exists
-	var existingUser structs.Users
-	if err := h.DB.Where("username = ? OR email = ?", input.Username, input.Email).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Username atau email sudah digunakan"})
-		return
-	}
-
-	// Check if NIP already exists
-	var existingStaff structs.StaffKUA
-	if err := h.DB.Where("nip = ?", input.NIP).First(&existingStaff).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "NIP sudah terdaftar"})
-		return
-	}
-
 	// Generate user_id
 	userID := "STF" + fmt.Sprintf("%d", time.Now().Unix())
 
-	// Hash password
-	hashedPassword, err := crypto.HashPassword(input.Password)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengenkripsi password"})
-		return
-	}
-
-	// Create user account
 	user := structs.Users{
-		User_id:    userID,
-		Username:   input.Username,
-		Email:      input.Email,
-		Password:   hashedPassword,
-		Role:       structs.UserRoleStaff,
-		Status:     structs.UserStatusAktif,
-		Nama:       input.Nama,
-		Created_at: time.Now(),
-		Updated_at: time.Now(),
-	}
-
-	if err := h.DB.Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat user account"})
-		return
+		User_id:  userID,
+		Username: input.Username,
+		Email:    input.Email,
+		Nama:     input.Nama,
 	}
-
-	// Create staff profile
 	staff := structs.StaffKUA{
 		User_id:      userID,
 		NIP:          input.NIP,
@@ -103,13 +71,14 @@ func (h *InDB) CreateStaffKUA(c *gin.Context) {
 		No_hp:        input.No_hp,
 		Email:        input.Email,
 		Alamat:       input.Alamat,
-		Status:       structs.StaffStatusAktif,
-		Created_at:   time.Now(),
-		Updated_at:   time.Now(),
+		Kua_id:       c.GetUint("kua_id"),
 	}
 
-	if err := h.DB.Create(&staff).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat profile staff"})
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		return createStaffAccount(tx, &user, input.Password, &staff)
+	})
+	if err != nil {
+		respondAccountError(c, err)
 		return
 	}
 
@@ -130,11 +99,107 @@ func (h *InDB) CreateStaffKUA(c *gin.Context) {
 	})
 }
 
+var (
+	errAccountTaken = errors.New("Username atau email sudah digunakan")
+	errNIPTaken     = errors.New("NIP sudah terdaftar")
+)
+
+// createStaffAccount membuat user dengan role staff beserta profile StaffKUA di dalam tx.
+// Satu-satunya jalur pembuatan akun staff (dipakai CreateStaffKUA dan undangan staff).
+func createStaffAccount(tx *gorm.DB, user *structs.Users, password string, staff *structs.StaffKUA) error {
+	var existingStaff structs.StaffKUA
+	if err := tx.Where("nip = ?", staff.NIP).First(&existingStaff).Error; err == nil {
+		return errNIPTaken
+	}
+
+	user.Role = structs.UserRoleStaff
+	user.Kua_id = staff.Kua_id
+	if err := createUserAccount(tx, user, password); err != nil {
+		return err
+	}
+
+	staff.User_id = user.User_id
+	staff.Status = structs.StaffStatusAktif
+	staff.Created_at = time.Now()
+	staff.Updated_at = time.Now()
+	if err := tx.Create(staff).Error; err != nil {
+		return fmt.Errorf("gagal membuat profile staff: %v", err)
+	}
+	return nil
+}
+
+// createPenghuluAccount membuat user dengan role penghulu beserta profile Penghulu di dalam tx.
+// Satu-satunya jalur pembuatan akun penghulu (dipakai CreatePenghulu dan undangan staff).
+func createPenghuluAccount(tx *gorm.DB, user *structs.Users, password string, penghulu *structs.Penghulu) error {
+	var existingPenghulu structs.Penghulu
+	if err := tx.Where("nip = ?", penghulu.NIP).First(&existingPenghulu).Error; err == nil {
+		return errNIPTaken
+	}
+
+	user.Role = structs.UserRolePenghulu
+	user.Kua_id = penghulu.Kua_id
+	if err := createUserAccount(tx, user, password); err != nil {
+		return err
+	}
+
+	penghulu.User_id = user.User_id
+	penghulu.Status = structs.PenghuluStatusAktif
+	penghulu.Jumlah_nikah = 0
+	penghulu.Rating = 0.0
+	penghulu.Created_at = time.Now()
+	penghulu.Updated_at = time.Now()
+	if err := tx.Create(penghulu).Error; err != nil {
+		return fmt.Errorf("gagal membuat profile penghulu: %v", err)
+	}
+	return nil
+}
+
+// createUserAccount mengecek username/email lalu membuat user dengan password yang di-hash
+func createUserAccount(tx *gorm.DB, user *structs.Users, password string) error {
+	var existingUser structs.Users
+	if err := tx.Where("username = ? OR email = ?", user.Username, user.Email).First(&existingUser).Error; err == nil {
+		return errAccountTaken
+	}
+
+	hashedPassword, err := crypto.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("gagal mengenkripsi password: %v", err)
+	}
+
+	user.Password = hashedPassword
+	user.Status = structs.UserStatusAktif
+	user.Created_at = time.Now()
+	user.Updated_at = time.Now()
+	if err := tx.Create(user).Error; err != nil {
+		return fmt.Errorf("gagal membuat user account: %v", err)
+	}
+	return nil
+}
+
+// respondAccountError mengirim response error pembuatan akun staff/penghulu
+func respondAccountError(c *gin.Context, err error) {
+	if errors.Is(err, errAccountTaken) || errors.Is(err, errNIPTaken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat akun: " + err.Error()})
+}
+
+// userInActorKUA mengecek apakah user target berada di KUA yang sama dengan user yang login.
+// Catin yang belum memilih KUA (kua_id = 0) masih boleh dikelola oleh KUA mana pun.
+func (h *InDB) userInActorKUA(c *gin.Context, userID string) bool {
+	var count int64
+	h.DB.Model(&structs.Users{}).
+		Where("user_id = ? AND (kua_id = ? OR (kua_id = 0 AND role = ?))", userID, c.GetUint("kua_id"), structs.UserRoleUserBiasa).
+		Count(&count)
+	return count > 0
+}
+
 // GetAllStaff gets all staff KUA
 func (h *InDB) GetAllStaff(c *gin.Context) {
 	var staff []structs.StaffKUA
 
-	if err := h.DB.Find(&staff).Error; err != nil {
+	if err := h.DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).Find(&staff).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data staff"})
 		return
 	}
@@ -165,7 +230,7 @@ func (h *InDB) UpdateStaffKUA(c *gin.Context) {
 	}
 
 	var staff structs.StaffKUA
-	if err := h.DB.First(&staff, id).Error; err != nil {
+	if err := h.DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).First(&staff, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Staff tidak ditemukan"})
 		return
 	}
@@ -190,12 +255,26 @@ func (h *InDB) UpdateStaffKUA(c *gin.Context) {
 		staff.Alamat = input.Alamat
 	}
 	if input.Status != "" {
+		if input.Status != structs.StaffStatusAktif && input.Status != structs.StaffStatusNonaktif {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status tidak valid. Status yang tersedia: Aktif, Nonaktif"})
+			return
+		}
 		staff.Status = input.Status
 	}
 
 	staff.Updated_at = time.Now()
 
-	if err := h.DB.Save(&staff).Error; err != nil {
+	// Status akun login ikut berubah; sesi staff yang dinonaktifkan langsung dicabut
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&staff).Error; err != nil {
+			return err
+		}
+		if input.Status == "" {
+			return nil
+		}
+		return services.NewSessionService(tx).SetUserStatus(staff.User_id, input.Status)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate data staff"})
 		return
 	}
@@ -225,49 +304,15 @@ func (h *InDB) CreatePenghulu(c *gin.Context) {
 		return
 	}
 
-	// Check if username/email already exists
-	var existingUser structs.Users
-	if err := h.DB.Where("username = ? OR email = ?", input.Username, input.Email).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Username atau email sudah digunakan"})
-		return
-	}
-
-	// Check if NIP already exists
-	var existingPenghulu structs.Penghulu
-	if err := h.DB.Where("nip = ?", input.NIP).First(&existingPenghulu).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "NIP sudah terdaftar"})
-		return
-	}
-
 	// Generate user_id
 	userID := "PNG" + fmt.Sprintf("%d", time.Now().Unix())
 
-	// Hash password
-	hashedPassword, err := crypto.HashPassword(input.Password)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengenkripsi password"})
-		return
-	}
-
-	// Create user account
 	user := structs.Users{
-		User_id:    userID,
-		Username:   input.Username,
-		Email:      input.Email,
-		Password:   hashedPassword,
-		Role:       structs.UserRolePenghulu,
-		Status:     structs.UserStatusAktif,
-		Nama:       input.Nama,
-		Created_at: time.Now(),
-		Updated_at: time.Now(),
+		User_id:  userID,
+		Username: input.Username,
+		Email:    input.Email,
+		Nama:     input.Nama,
 	}
-
-	if err := h.DB.Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat user account"})
-		return
-	}
-
-	// Create penghulu profile
 	penghulu := structs.Penghulu{
 		User_id:      userID,
 		NIP:          input.NIP,
@@ -275,15 +320,14 @@ func (h *InDB) CreatePenghulu(c *gin.Context) {
 		No_hp:        input.No_hp,
 		Email:        input.Email,
 		Alamat:       input.Alamat,
-		Status:       structs.PenghuluStatusAktif,
-		Jumlah_nikah: 0,
-		Rating:       0.0,
-		Created_at:   time.Now(),
-		Updated_at:   time.Now(),
+		Kua_id:       c.GetUint("kua_id"),
 	}
 
-	if err := h.DB.Create(&penghulu).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat profile penghulu"})
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		return createPenghuluAccount(tx, &user, input.Password, &penghulu)
+	})
+	if err != nil {
+		respondAccountError(c, err)
 		return
 	}
 
@@ -296,11 +340,18 @@ func (h *InDB) CreatePenghulu(c *gin.Context) {
 	})
 }
 
-// GetAllPenghulu gets all penghulu
+// GetAllPenghulu gets all penghulu di KUA user (catin tanpa KUA bisa memilih lewat ?kua_id=)
 func (h *InDB) GetAllPenghulu(c *gin.Context) {
 	var penghulu []structs.Penghulu
 
-	if err := h.DB.Find(&penghulu).Error; err != nil {
+	kuaID := c.GetUint("kua_id")
+	if kuaID == 0 {
+		if id, err := strconv.ParseUint(c.Query("kua_id"), 10, 64); err == nil {
+			kuaID = uint(id)
+		}
+	}
+
+	if err := h.DB.Scopes(services.TenantScope(kuaID)).Find(&penghulu).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data penghulu"})
 		return
 	}
@@ -330,7 +381,7 @@ func (h *InDB) UpdatePenghulu(c *gin.Context) {
 	}
 
 	var penghulu structs.Penghulu
-	if err := h.DB.First(&penghulu, id).Error; err != nil {
+	if err := h.DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).First(&penghulu, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Penghulu tidak ditemukan"})
 		return
 	}
@@ -349,6 +400,10 @@ func (h *InDB) UpdatePenghulu(c *gin.Context) {
 		penghulu.Alamat = input.Alamat
 	}
 	if input.Status != "" {
+		if input.Status != structs.PenghuluStatusAktif && input.Status != structs.PenghuluStatusNonaktif {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status tidak valid. Status yang tersedia: Aktif, Nonaktif"})
+			return
+		}
 		penghulu.Status = input.Status
 	}
 	if input.Rating > 0 {
@@ -357,7 +412,17 @@ func (h *InDB) UpdatePenghulu(c *gin.Context) {
 
 	penghulu.Updated_at = time.Now()
 
-	if err := h.DB.Save(&penghulu).Error; err != nil {
+	// Status akun login ikut berubah; sesi penghulu yang dinonaktifkan langsung dicabut
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&penghulu).Error; err != nil {
+			return err
+		}
+		if input.Status == "" {
+			return nil
+		}
+		return services.NewSessionService(tx).SetUserStatus(penghulu.User_id, input.Status)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate data penghulu"})
 		return
 	}
@@ -368,6 +433,253 @@ func (h *InDB) UpdatePenghulu(c *gin.Context) {
 	})
 }
 
+// ==================== USER ACCOUNT STATUS ====================
+
+// UpdateUserStatus mengubah status akun user (Aktif, Nonaktif, Blokir) oleh Kepala KUA.
+// Semua sesi user dicabut saat status berubah sehingga akses langsung berhenti.
+func (h *InDB) UpdateUserStatus(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	var input struct {
+		Status string `json:"status" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format data tidak valid"})
+		return
+	}
+
+	validStatus := map[string]bool{
+		structs.UserStatusAktif:    true,
+		structs.UserStatusNonaktif: true,
+		structs.UserStatusBlokir:   true,
+	}
+	if !validStatus[input.Status] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status tidak valid. Status yang tersedia: Aktif, Nonaktif, Blokir"})
+		return
+	}
+
+	if userID == c.GetString("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tidak dapat mengubah status akun sendiri"})
+		return
+	}
+
+	if !h.userInActorKUA(c, userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+
+	if err := services.NewSessionService(h.DB).SetUserStatus(userID, input.Status); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengubah status user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Status user berhasil diubah",
+		"data": gin.H{
+			"user_id": userID,
+			"status":  input.Status,
+		},
+	})
+}
+
+// UnlockUser membuka kunci akun yang terkunci karena login gagal berulang (hanya Kepala KUA)
+func (h *InDB) UnlockUser(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	if !h.userInActorKUA(c, userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+
+	if err := services.NewLoginGuardService(h.DB).UnlockAccount(userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuka kunci akun"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Kunci akun berhasil dibuka"})
+}
+
+// ResetUserMfa menghapus 2FA user yang kehilangan authenticator (hanya Kepala KUA)
+func (h *InDB) ResetUserMfa(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	if !h.userInActorKUA(c, userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+
+	if err := services.NewMfaService(h.DB).Reset(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mereset 2FA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "2FA user berhasil direset. User perlu mendaftarkan authenticator kembali"})
+}
+
+// GetMfaSetting menampilkan pengaturan 2FA wajib per role di KUA user yang login (hanya Kepala KUA)
+func (h *InDB) GetMfaSetting(c *gin.Context) {
+	kuaID := c.GetUint("kua_id")
+	wajib := services.NewMfaService(h.DB).MandatoryRoles(kuaID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pengaturan 2FA berhasil diambil",
+		"data": gin.H{
+			"wajib":              wajib,
+			"role":               services.MfaRoles(),
+			"belum_mengaktifkan": h.countUsersWithoutMfa(kuaID, wajib),
+		},
+	})
+}
+
+// UpdateMfaSetting mewajibkan/membebaskan 2FA per role (staff, penghulu, kepala KUA) di KUA user yang login
+// (hanya Kepala KUA). Role yang tidak dikirim tidak berubah.
+func (h *InDB) UpdateMfaSetting(c *gin.Context) {
+	var input struct {
+		Wajib map[string]bool `json:"wajib" binding:"required"` // mis. {"penghulu": true, "staff": false}
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil || len(input.Wajib) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field wajib (role: true/false) diperlukan, mis. {\"wajib\": {\"penghulu\": true}}"})
+		return
+	}
+	for role := range input.Wajib {
+		if !services.RoleSupportsMfa(role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "2FA hanya bisa diwajibkan untuk role staff, penghulu, dan kepala_kua", "role": role})
+			return
+		}
+	}
+
+	kuaID := c.GetUint("kua_id")
+	mfaService := services.NewMfaService(h.DB)
+	for role, wajib := range input.Wajib {
+		if err := mfaService.SetMandatory(kuaID, role, wajib, c.GetString("user_id")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan pengaturan 2FA"})
+			return
+		}
+	}
+
+	wajib := mfaService.MandatoryRoles(kuaID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pengaturan 2FA disimpan. User pada role yang diwajibkan dan belum mengaktifkan 2FA akan diminta mendaftar saat login berikutnya",
+		"data": gin.H{
+			"wajib":              wajib,
+			"belum_mengaktifkan": h.countUsersWithoutMfa(kuaID, wajib),
+		},
+	})
+}
+
+// countUsersWithoutMfa menghitung akun aktif di sebuah KUA pada role yang mewajibkan 2FA tetapi belum mengaktifkannya
+func (h *InDB) countUsersWithoutMfa(kuaID uint, wajib map[string]bool) int64 {
+	roles := make([]string, 0, len(wajib))
+	for role, ya := range wajib {
+		if ya {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		return 0
+	}
+
+	var count int64
+	h.DB.Model(&structs.Users{}).Scopes(services.TenantScope(kuaID)).
+		Where("role IN ? AND status = ?", roles, structs.UserStatusAktif).
+		Where("user_id NOT IN (?)", h.DB.Model(&structs.MfaPengguna{}).Select("user_id").Where("aktif = ?", true)).
+		Count(&count)
+	return count
+}
+
+// GetLoginAudit mengambil jejak audit login user di KUA yang sama dengan filter dan pagination (hanya Kepala KUA).
+// Percobaan login dengan username yang tidak dikenal tidak terikat ke KUA mana pun sehingga tidak ditampilkan.
+func (h *InDB) GetLoginAudit(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	limit := c.DefaultQuery("limit", "20")
+	userID := c.Query("user_id")
+	username := c.Query("username")
+	ipAddress := c.Query("ip_address")
+	berhasil := c.Query("berhasil")
+	alasan := c.Query("alasan")
+	dateFrom := c.Query("date_from")
+	dateTo := c.Query("date_to")
+
+	pageInt, err := strconv.Atoi(page)
+	if err != nil || pageInt < 1 {
+		pageInt = 1
+	}
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil || limitInt < 1 || limitInt > 100 {
+		limitInt = 20
+	}
+	offset := (pageInt - 1) * limitInt
+
+	query := h.DB.Model(&structs.LoginAudit{}).
+		Joins("JOIN users ON users.user_id = login_audits.user_id").
+		Where("users.kua_id = ?", c.GetUint("kua_id"))
+	if userID != "" {
+		query = query.Where("login_audits.user_id = ?", userID)
+	}
+	if username != "" {
+		query = query.Where("login_audits.username = ?", username)
+	}
+	if ipAddress != "" {
+		query = query.Where("login_audits.ip_address = ?", ipAddress)
+	}
+	if berhasil != "" {
+		query = query.Where("login_audits.berhasil = ?", berhasil == "true")
+	}
+	if alasan != "" {
+		query = query.Where("login_audits.alasan = ?", alasan)
+	}
+	if dateFrom != "" {
+		if dateFromParsed, err := time.Parse("2006-01-02", dateFrom); err == nil {
+			query = query.Where("login_audits.created_at >= ?", dateFromParsed)
+		}
+	}
+	if dateTo != "" {
+		if dateToParsed, err := time.Parse("2006-01-02", dateTo); err == nil {
+			query = query.Where("login_audits.created_at < ?", dateToParsed.Add(24*time.Hour))
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil audit login"})
+		return
+	}
+
+	var audits []structs.LoginAudit
+	if err := query.Select("login_audits.*").Order("login_audits.created_at DESC").Offset(offset).Limit(limitInt).Find(&audits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil audit login"})
+		return
+	}
+
+	totalPages := int((total + int64(limitInt) - 1) / int64(limitInt))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Audit login berhasil diambil",
+		"data": gin.H{
+			"audit": audits,
+			"pagination": gin.H{
+				"current_page":  pageInt,
+				"total_pages":   totalPages,
+				"total_records": total,
+				"per_page":      limitInt,
+				"has_next":      pageInt < totalPages,
+				"has_previous":  pageInt > 1,
+			},
+		},
+	})
+}
+
 // ==================== MARRIAGE REGISTRATION VERIFICATION ====================
 
 // VerifyFormulir verifies the form data by staff (Tahap 1)
@@ -398,9 +710,24 @@ func (h *InDB) VerifyFormulir(c *gin.Context) {
 		})
 		return
 	}
+	if err := services.ValidateCatatan(input.Catatan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Format data tidak valid",
+			"error":   err.Error(),
+		})
+		return
+	}
 
-	// Validate status
-	if input.Status != "Formulir Disetujui" && input.Status != "Formulir Ditolak" {
+	// Validate status: hanya dua keputusan yang dikenal, masing-masing dipetakan ke status tujuan
+	// di tabel transisi. Nilai lain ditolak agar tidak diam-diam dianggap penolakan.
+	var targetStatus string
+	switch input.Status {
+	case "Formulir Disetujui":
+		targetStatus = structs.StatusPendaftaranMenungguPengumpulanBerkas
+	case "Formulir Ditolak":
+		targetStatus = structs.StatusPendaftaranDitolak
+	default:
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": "Status tidak valid",
@@ -411,7 +738,7 @@ func (h *InDB) VerifyFormulir(c *gin.Context) {
 
 	// Check if registration exists
 	var pendaftaran structs.PendaftaranNikah
-	if err := h.DB.Where("id = ?", registrationID).First(&pendaftaran).Error; err != nil {
+	if err := h.DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).Where("id = ?", registrationID).First(&pendaftaran).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
 			"message": "Pendaftaran tidak ditemukan",
@@ -420,36 +747,13 @@ func (h *InDB) VerifyFormulir(c *gin.Context) {
 		return
 	}
 
-	// Check if registration is in correct status for form verification
-	if pendaftaran.Status_pendaftaran != structs.StatusPendaftaranMenungguVerifikasi {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "Status tidak sesuai",
-			"error":   "Pendaftaran harus dalam status 'Menunggu Verifikasi' untuk diverifikasi",
-		})
-		return
-	}
-
-	// Update registration status
-	// Jika formulir disetujui, langsung ubah ke "Menunggu Pengumpulan Berkas"
-	if input.Status == "Formulir Disetujui" {
-		pendaftaran.Status_pendaftaran = structs.StatusPendaftaranMenungguPengumpulanBerkas
-	} else {
-		pendaftaran.Status_pendaftaran = input.Status
-	}
-
+	// Jalankan transisi melalui state machine
+	// Jika formulir disetujui, status menjadi "Menunggu Pengumpulan Berkas"
+	transitionService := services.NewStatusTransitionService(h.DB)
+	actor := services.TransitionActor{UserID: staffID.(string), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
 	pendaftaran.Catatan = input.Catatan
-	pendaftaran.Disetujui_oleh = staffID.(string)
-	now := time.Now()
-	pendaftaran.Disetujui_pada = &now
-	pendaftaran.Updated_at = time.Now()
-
-	if err := h.DB.Save(&pendaftaran).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "Database error",
-			"error":   "Gagal mengupdate status pendaftaran",
-		})
+	if _, err := transitionService.Apply(&pendaftaran, targetStatus, actor, input.Catatan); err != nil {
+		respondTransitionError(c, err)
 		return
 	}
 
@@ -534,9 +838,24 @@ func (h *InDB) VerifyBerkas(c *gin.Context) {
 		})
 		return
 	}
+	if err := services.ValidateCatatan(input.Catatan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Format data tidak valid",
+			"error":   err.Error(),
+		})
+		return
+	}
 
-	// Validate status
-	if input.Status != "Berkas Diterima" && input.Status != "Berkas Ditolak" {
+	// Validate status: hanya dua keputusan yang dikenal, masing-masing dipetakan ke status tujuan
+	// di tabel transisi. Nilai lain ditolak agar tidak diam-diam dianggap penolakan.
+	var targetStatus string
+	switch input.Status {
+	case "Berkas Diterima":
+		targetStatus = structs.StatusPendaftaranBerkasDiterima
+	case "Berkas Ditolak":
+		targetStatus = structs.StatusPendaftaranDitolak
+	default:
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": "Status tidak valid",
@@ -547,7 +866,7 @@ func (h *InDB) VerifyBerkas(c *gin.Context) {
 
 	// Check if registration exists
 	var pendaftaran structs.PendaftaranNikah
-	if err := h.DB.Where("id = ?", registrationID).First(&pendaftaran).Error; err != nil {
+	if err := h.DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).Where("id = ?", registrationID).First(&pendaftaran).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
 			"message": "Pendaftaran tidak ditemukan",
@@ -556,35 +875,13 @@ func (h *InDB) VerifyBerkas(c *gin.Context) {
 		return
 	}
 
-	// Check if registration is in correct status for document verification
-	if pendaftaran.Status_pendaftaran != structs.StatusPendaftaranMenungguPengumpulanBerkas {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "Status tidak sesuai",
-			"error":   "Pendaftaran harus dalam status 'Menunggu Pengumpulan Berkas' untuk verifikasi berkas",
-		})
-		return
-	}
-
-	// Update registration status
-	// Jika berkas diterima, ubah ke "Berkas Diterima"
-	if input.Status == "Berkas Diterima" {
-		pendaftaran.Status_pendaftaran = structs.StatusPendaftaranBerkasDiterima
-	} else {
-		pendaftaran.Status_pendaftaran = input.Status
-	}
+	// Jalankan transisi melalui state machine
+	// Jika berkas diterima, status menjadi "Berkas Diterima"
+	transitionService := services.NewStatusTransitionService(h.DB)
+	actor := services.TransitionActor{UserID: staffID.(string), Role: c.GetString("role"), KuaID: c.GetUint("kua_id")}
 	pendaftaran.Catatan = input.Catatan
-	pendaftaran.Disetujui_oleh = staffID.(string)
-	now := time.Now()
-	pendaftaran.Disetujui_pada = &now
-	pendaftaran.Updated_at = time.Now()
-
-	if err := h.DB.Save(&pendaftaran).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "Database error",
-			"error":   "Gagal mengupdate status pendaftaran",
-		})
+	if _, err := transitionService.Apply(&pendaftaran, targetStatus, actor, input.Catatan); err != nil {
+		respondTransitionError(c, err)
 		return
 	}
 
@@ -635,8 +932,9 @@ func (h *InDB) VerifyBerkas(c *gin.Context) {
 
 // ==================== FLEKSIBEL STATUS UPDATE ====================
 
-// UpdateStatusFlexible - Update status pendaftaran secara fleksibel tanpa validasi ketat
-// Bisa digunakan oleh Staff, Penghulu, dan Kepala KUA untuk update status secara manual
+// UpdateStatusFlexible - Update status pendaftaran secara manual
+// Bisa digunakan oleh Staff, Penghulu, dan Kepala KUA, tetapi hanya untuk transisi
+// yang sah menurut tabel transisi status (services.StatusTransitionService)
 func (h *InDB) UpdateStatusFlexible(c *gin.Context) {
 	registrationID := c.Param("id")
 
@@ -662,21 +960,8 @@ func (h *InDB) UpdateStatusFlexible(c *gin.Context) {
 		return
 	}
 
-	// Validasi role - hanya staff, penghulu, atau kepala_kua yang bisa update
-	allowedRoles := map[string]bool{
-		structs.UserRoleStaff:     true,
-		structs.UserRolePenghulu: true,
-		structs.UserRoleKepalaKUA: true,
-	}
-	if !allowedRoles[userRole.(string)] {
-		c.JSON(http.StatusForbidden, gin.H{
-			"success": false,
-			"message": "Akses ditolak",
-			"error":   "Hanya Staff, Penghulu, atau Kepala KUA yang dapat mengupdate status",
-		})
-		return
-	}
-
+	// Akses route dibatasi izin pendaftaran.update_status (RequirePermission);
+	// transisi status yang boleh dilakukan per role tetap divalidasi StatusTransitionService
 	var input struct {
 		Status  string `json:"status"`
 		Catatan string `json:"catatan"`
@@ -690,58 +975,28 @@ func (h *InDB) UpdateStatusFlexible(c *gin.Context) {
 		})
 		return
 	}
-
-	// Validate status is not empty (manual validation)
-	if strings.TrimSpace(input.Status) == "" {
+	if err := services.ValidateCatatan(input.Catatan); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": "Format data tidak valid",
-			"error":   "Field 'status' wajib diisi",
-		})
-		return
-	}
-
-	// Status yang terkait dengan assign penghulu hanya bisa diubah oleh Kepala KUA
-	// melalui endpoint khusus assign-penghulu
-	penghuluRelatedStatuses := map[string]bool{
-		structs.StatusPendaftaranMenungguPenugasan:         true,
-		structs.StatusPendaftaranPenghuluDitugaskan:         true,
-		structs.StatusPendaftaranMenungguVerifikasiPenghulu: true,
-	}
-
-	if penghuluRelatedStatuses[input.Status] {
-		c.JSON(http.StatusForbidden, gin.H{
-			"success": false,
-			"message": "Akses ditolak",
-			"error":   "Status '" + input.Status + "' hanya bisa diubah oleh Kepala KUA melalui endpoint assign-penghulu. Gunakan endpoint POST /simnikah/pendaftaran/:id/assign-penghulu untuk menugaskan penghulu.",
+			"error":   err.Error(),
 		})
 		return
 	}
 
-	// Validasi status yang diizinkan (tanpa status terkait penghulu)
-	validStatuses := map[string]bool{
-		structs.StatusPendaftaranDraft:                     true,
-		structs.StatusPendaftaranMenungguVerifikasi:        true,
-		structs.StatusPendaftaranMenungguPengumpulanBerkas: true,
-		structs.StatusPendaftaranBerkasDiterima:            true,
-		structs.StatusPendaftaranMenungguBimbingan:         true,
-		structs.StatusPendaftaranSudahBimbingan:             true,
-		structs.StatusPendaftaranSelesai:                   true,
-		structs.StatusPendaftaranDitolak:                   true,
-	}
-
-	if !validStatuses[input.Status] {
+	// Validate status is not empty (manual validation)
+	if strings.TrimSpace(input.Status) == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "Status tidak valid",
-			"error":   "Status yang diizinkan: Draft, Menunggu Verifikasi, Menunggu Pengumpulan Berkas, Berkas Diterima, Menunggu Bimbingan, Sudah Bimbingan, Selesai, Ditolak. Untuk status terkait penghulu, gunakan endpoint assign-penghulu.",
+			"message": "Format data tidak valid",
+			"error":   "Field 'status' wajib diisi",
 		})
 		return
 	}
 
 	// Check if registration exists
 	var pendaftaran structs.PendaftaranNikah
-	if err := h.DB.Where("id = ?", registrationID).First(&pendaftaran).Error; err != nil {
+	if err := h.DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).Where("id = ?", registrationID).First(&pendaftaran).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
 			"message": "Pendaftaran tidak ditemukan",
@@ -753,23 +1008,25 @@ func (h *InDB) UpdateStatusFlexible(c *gin.Context) {
 	// Simpan status lama untuk logging
 	statusLama := pendaftaran.Status_pendaftaran
 
-	// Update status tanpa validasi ketat (fleksibel)
-	pendaftaran.Status_pendaftaran = input.Status
-	pendaftaran.Catatan = input.Catatan
-	pendaftaran.Disetujui_oleh = userID.(string)
-	now := time.Now()
-	pendaftaran.Disetujui_pada = &now
-	pendaftaran.Updated_at = time.Now()
-
-	if err := h.DB.Save(&pendaftaran).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	// Transisi yang memiliki endpoint khusus (misalnya assign penghulu) tidak boleh
+	// dilakukan lewat endpoint ini
+	if transition, ok := services.FindTransition(statusLama, input.Status); ok && transition.Endpoint != "" {
+		c.JSON(http.StatusForbidden, gin.H{
 			"success": false,
-			"message": "Database error",
-			"error":   "Gagal mengupdate status pendaftaran",
+			"message": "Akses ditolak",
+			"error":   "Status '" + input.Status + "' hanya bisa diubah melalui endpoint " + transition.Endpoint,
+			"type":    services.TransitionErrorForbidden,
 		})
 		return
 	}
 
+	transitionService := services.NewStatusTransitionService(h.DB)
+	actor := services.TransitionActor{UserID: userID.(string), Role: userRole.(string), KuaID: c.GetUint("kua_id")}
+	if _, err := transitionService.Apply(&pendaftaran, input.Status, actor, input.Catatan); err != nil {
+		respondTransitionError(c, err)
+		return
+	}
+
 	// Create notification untuk user
 	notification := structs.Notifikasi{
 		User_id:     pendaftaran.Pendaftar_id,
@@ -800,4 +1057,24 @@ func (h *InDB) UpdateStatusFlexible(c *gin.Context) {
 			"updated_at":          pendaftaran.Updated_at,
 		},
 	})
-}
\ No newline at end of file
+}
+// respondTransitionError mengirim response error dari state machine status pendaftaran
+func respondTransitionError(c *gin.Context, err error) {
+	var transitionErr *services.TransitionError
+	if errors.As(err, &transitionErr) {
+		c.JSON(transitionErr.StatusCode(), gin.H{
+			"success": false,
+			"message": transitionErr.Message,
+			"error":   transitionErr.Error(),
+			"type":    transitionErr.Type,
+			"alasan":  transitionErr.Reasons,
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"success": false,
+		"message": "Database error",
+		"error":   "Gagal mengupdate status pendaftaran",
+	})
+}
@@ -0,0 +1,252 @@
+package staff
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"simnikah/internal/models"
+	"simnikah/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ==================== STAFF INVITATION HANDLERS ====================
+// Akun staff/penghulu tidak bisa dibuat lewat registrasi publik. Kepala KUA membuat
+// undangan, lalu staff yang diundang menukarkan link undangan untuk mengatur password sendiri.
+
+// CreateStaffInvitation membuat undangan akun staff/penghulu (hanya Kepala KUA)
+func (h *InDB) CreateStaffInvitation(c *gin.Context) {
+	kepalaKuaID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID tidak ditemukan"})
+		return
+	}
+
+	var input struct {
+		Email      string `json:"email" binding:"required,email"`
+		Nama       string `json:"nama" binding:"required"`
+		Role       string `json:"role" binding:"required"`
+		NIP        string `json:"nip" binding:"required"`
+		Jabatan    string `json:"jabatan"`
+		Bagian     string `json:"bagian"`
+		No_hp      string `json:"no_hp"`
+		Alamat     string `json:"alamat"`
+		BerlakuJam int    `json:"berlaku_jam"` // default 72 jam
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format data tidak valid"})
+		return
+	}
+
+	// Hanya staff dan penghulu yang bisa diundang
+	switch input.Role {
+	case structs.UserRoleStaff:
+		validJabatan := map[string]bool{
+			structs.StaffJabatanStaff:     true,
+			structs.StaffJabatanPenghulu:  true,
+			structs.StaffJabatanKepalaKUA: true,
+		}
+		if !validJabatan[input.Jabatan] || input.Bagian == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Jabatan dan bagian wajib diisi dengan benar untuk role staff"})
+			return
+		}
+	case structs.UserRolePenghulu:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role tidak valid. Role yang bisa diundang: staff, penghulu"})
+		return
+	}
+
+	// Email tidak boleh sudah dipakai akun lain
+	var existingUser structs.Users
+	if err := h.DB.Where("email = ?", input.Email).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email sudah digunakan"})
+		return
+	}
+
+	undangan := structs.UndanganStaff{
+		Email:       input.Email,
+		Nama:        input.Nama,
+		Role:        input.Role,
+		NIP:         input.NIP,
+		Jabatan:     input.Jabatan,
+		Bagian:      input.Bagian,
+		No_hp:       input.No_hp,
+		Alamat:      input.Alamat,
+		Dibuat_oleh: kepalaKuaID.(string),
+		Kua_id:      c.GetUint("kua_id"),
+	}
+
+	invitationService := services.NewInvitationService(h.DB)
+	token, link, err := invitationService.CreateInvitation(&undangan, time.Duration(input.BerlakuJam)*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat undangan"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Undangan berhasil dibuat. Bagikan link undangan kepada " + undangan.Nama,
+		"data": gin.H{
+			"undangan":         undangan,
+			"token":            token,
+			"link":             link,
+			"kedaluwarsa_pada": undangan.Kedaluwarsa_pada,
+		},
+	})
+}
+
+// GetStaffInvitations mengambil semua undangan beserta statusnya (hanya Kepala KUA)
+func (h *InDB) GetStaffInvitations(c *gin.Context) {
+	var undangan []structs.UndanganStaff
+	if err := h.DB.Scopes(services.TenantScope(c.GetUint("kua_id"))).Order("created_at DESC").Find(&undangan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data undangan"})
+		return
+	}
+
+	now := time.Now()
+	data := make([]gin.H, 0, len(undangan))
+	for i := range undangan {
+		data = append(data, gin.H{
+			"undangan": undangan[i],
+			"status":   services.InvitationStatus(&undangan[i], now),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Data undangan berhasil diambil",
+		"data":    data,
+	})
+}
+
+// RevokeStaffInvitation membatalkan undangan yang belum digunakan (hanya Kepala KUA)
+func (h *InDB) RevokeStaffInvitation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID undangan tidak valid"})
+		return
+	}
+
+	invitationService := services.NewInvitationService(h.DB)
+	if err := invitationService.RevokeInvitation(uint(id), c.GetUint("kua_id")); err != nil {
+		if errors.Is(err, services.ErrInvitationInvalid) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Undangan tidak ditemukan atau sudah tidak aktif"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membatalkan undangan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Undangan berhasil dibatalkan"})
+}
+
+// VerifyStaffInvitation mengecek link undangan sebelum ditukarkan (publik)
+func (h *InDB) VerifyStaffInvitation(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token undangan diperlukan"})
+		return
+	}
+
+	invitationService := services.NewInvitationService(h.DB)
+	undangan, err := invitationService.VerifyInvitation(token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvitationInvalid.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Undangan valid",
+		"data": gin.H{
+			"email":            undangan.Email,
+			"nama":             undangan.Nama,
+			"role":             undangan.Role,
+			"kedaluwarsa_pada": undangan.Kedaluwarsa_pada,
+		},
+	})
+}
+
+// RedeemStaffInvitation menukarkan link undangan: membuat akun dengan password pilihan staff (publik)
+func (h *InDB) RedeemStaffInvitation(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required,min=6"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format data tidak valid"})
+		return
+	}
+
+	var user structs.Users
+	invitationService := services.NewInvitationService(h.DB)
+	undangan, err := invitationService.RedeemInvitation(input.Token, func(tx *gorm.DB, undangan *structs.UndanganStaff) (string, error) {
+		user = structs.Users{
+			Username: input.Username,
+			Email:    undangan.Email,
+			Nama:     undangan.Nama,
+		}
+
+		switch undangan.Role {
+		case structs.UserRoleStaff:
+			user.User_id = "STF" + fmt.Sprintf("%d", time.Now().Unix())
+			staff := structs.StaffKUA{
+				NIP:          undangan.NIP,
+				Nama_lengkap: undangan.Nama,
+				Jabatan:      undangan.Jabatan,
+				Bagian:       undangan.Bagian,
+				No_hp:        undangan.No_hp,
+				Email:        undangan.Email,
+				Alamat:       undangan.Alamat,
+				Kua_id:       undangan.Kua_id,
+			}
+			return user.User_id, createStaffAccount(tx, &user, input.Password, &staff)
+		case structs.UserRolePenghulu:
+			user.User_id = "PNG" + fmt.Sprintf("%d", time.Now().Unix())
+			penghulu := structs.Penghulu{
+				NIP:          undangan.NIP,
+				Nama_lengkap: undangan.Nama,
+				No_hp:        undangan.No_hp,
+				Email:        undangan.Email,
+				Alamat:       undangan.Alamat,
+				Kua_id:       undangan.Kua_id,
+			}
+			return user.User_id, createPenghuluAccount(tx, &user, input.Password, &penghulu)
+		default:
+			return "", services.ErrInvitationInvalid
+		}
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvitationInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		respondAccountError(c, err)
+		return
+	}
+
+	// Kirim notifikasi otomatis setelah akun staff berhasil dibuat
+	jabatan := undangan.Jabatan
+	if undangan.Role == structs.UserRolePenghulu {
+		jabatan = structs.StaffJabatanPenghulu
+	}
+	notificationService := services.NewNotificationService(h.DB)
+	if err := notificationService.SendStaffCreatedNotification(user.User_id, undangan.Nama, jabatan); err != nil {
+		fmt.Printf("Gagal mengirim notifikasi pembuatan staff: %v\n", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Akun berhasil diaktifkan. Silakan login dengan username dan password Anda",
+		"user": gin.H{
+			"user_id":  user.User_id,
+			"username": user.Username,
+			"email":    user.Email,
+			"nama":     user.Nama,
+			"role":     user.Role,
+		},
+	})
+}
@@ -36,6 +36,7 @@ const (
 	StatusPendaftaranSudahBimbingan             = "Sudah Bimbingan"
 	StatusPendaftaranSelesai                    = "Selesai"
 	StatusPendaftaranDitolak                    = "Ditolak"
+	StatusPendaftaranDibatalkan                 = "Dibatalkan"
 )
 
 // Define constants for PendaftaranNikah Status_bimbingan
@@ -78,6 +79,8 @@ const (
 	UserRolePenghulu  = "penghulu"
 	UserRoleStaff     = "staff"
 	UserRoleKepalaKUA = "kepala_kua"
+	// UserRoleSuperAdmin adalah pengelola pusat yang tidak terikat ke satu KUA (kua_id = 0)
+	UserRoleSuperAdmin = "super_admin"
 )
 
 // Define constants for Users Status
@@ -140,6 +143,169 @@ const (
 	PendaftaranBimbinganSertifikatSudah = "Sudah"
 )
 
+// Define constants for RiwayatStatus Aksi (di luar aksi tabel transisi status)
+const (
+	RiwayatAksiPendaftaranBaru = "pendaftaran_baru"
+	RiwayatAksiGantiPenghulu   = "ganti_penghulu"
+)
+
+// Define constants for UndanganStaff status (dihitung dari Digunakan_pada, Dibatalkan_pada, Kedaluwarsa_pada)
+const (
+	UndanganStatusAktif       = "Aktif"
+	UndanganStatusDigunakan   = "Digunakan"
+	UndanganStatusDibatalkan  = "Dibatalkan"
+	UndanganStatusKedaluwarsa = "Kedaluwarsa"
+)
+
+// Define constants for alasan pencabutan sesi/token
+const (
+	SesiAlasanLogout        = "logout"
+	SesiAlasanLogoutSemua   = "logout_semua"
+	SesiAlasanRotasi        = "rotasi"
+	SesiAlasanPakaiUlang    = "refresh_token_dipakai_ulang"
+	SesiAlasanStatusBerubah = "status_pengguna_berubah"
+	SesiAlasanGantiPassword = "ganti_password"
+	SesiAlasanResetPassword = "reset_password"
+	SesiAlasanKuaBerubah    = "kua_berubah"
+)
+
+// Define constants for LoginAudit Alasan
+const (
+	LoginAlasanBerhasil           = "berhasil"
+	LoginAlasanUserTidakDitemukan = "user_tidak_ditemukan"
+	LoginAlasanPasswordSalah      = "password_salah"
+	LoginAlasanAkunTerkunci       = "akun_terkunci"
+	LoginAlasanAkunTidakAktif     = "akun_tidak_aktif"
+	LoginAlasanKodeMfaSalah       = "kode_mfa_salah"
+)
+
+// Define constants for Izin (permission) - dipetakan ke role lewat tabel izin_roles
+const (
+	IzinPendaftaranViewAll           = "pendaftaran.view_all"
+	IzinPendaftaranVerifyFormulir    = "pendaftaran.verify_formulir"
+	IzinPendaftaranVerifyBerkas      = "pendaftaran.verify_berkas"
+	IzinPendaftaranVerifyPenghulu    = "pendaftaran.verify_penghulu"
+	IzinPendaftaranUpdateStatus      = "pendaftaran.update_status"
+	IzinPendaftaranUpdateAlamat      = "pendaftaran.update_alamat"
+	IzinPendaftaranCompleteBimbingan = "pendaftaran.complete_bimbingan"
+	IzinPendaftaranCompleteNikah     = "pendaftaran.complete_nikah"
+	IzinPenghuluAssign               = "penghulu.assign"
+	IzinPenghuluViewAssigned         = "penghulu.view_assigned"
+	IzinPenghuluManage               = "penghulu.manage"
+	IzinStaffManage                  = "staff.manage"
+	IzinJadwalManage                 = "jadwal.manage"
+	IzinBimbinganManage              = "bimbingan.manage"
+	IzinNotifikasiManage             = "notifikasi.manage"
+	IzinAkunManage                   = "akun.manage"
+	IzinAkunMfa                      = "akun.mfa"
+	IzinAuditView                    = "audit.view"
+	IzinPengaturanManage             = "pengaturan.manage"
+	IzinIzinManage                   = "izin.manage"
+	IzinKuaManage                    = "kua.manage"
+	IzinKuaCreate                    = "kua.create"
+	IzinHariLiburManage              = "hari_libur.manage"
+	IzinPenghuluRequestCuti          = "penghulu.request_cuti"
+	IzinPenghuluApproveCuti          = "penghulu.approve_cuti"
+	IzinDokumenReview                = "dokumen.review"
+)
+
+// Define constants for KUA Status
+const (
+	KUAStatusAktif    = "Aktif"
+	KUAStatusNonaktif = "Nonaktif"
+)
+
+// Define constants for SlotNikah Status
+const (
+	SlotNikahStatusDitahan  = "Ditahan"
+	SlotNikahStatusTerpesan = "Terpesan"
+)
+
+// Define constants for HariLibur Jenis
+const (
+	HariLiburJenisNasional     = "Libur Nasional"
+	HariLiburJenisCutiBersama  = "Cuti Bersama"
+	HariLiburJenisPenutupanKUA = "Penutupan KUA"
+)
+
+// Define constants for KetidaksediaanPenghulu Jenis
+const (
+	KetidaksediaanJenisCuti      = "Cuti"
+	KetidaksediaanJenisSakit     = "Sakit"
+	KetidaksediaanJenisTugasLuar = "Tugas Luar"
+	KetidaksediaanJenisBlokir    = "Blokir"
+)
+
+// Define constants for KetidaksediaanPenghulu Status
+const (
+	KetidaksediaanStatusMenunggu   = "Menunggu Persetujuan"
+	KetidaksediaanStatusDisetujui  = "Disetujui"
+	KetidaksediaanStatusDitolak    = "Ditolak"
+	KetidaksediaanStatusDibatalkan = "Dibatalkan"
+)
+
+// Define constants for PerubahanJadwal Status
+const (
+	PerubahanJadwalStatusMenunggu   = "Menunggu Persetujuan"
+	PerubahanJadwalStatusDisetujui  = "Disetujui"
+	PerubahanJadwalStatusDitolak    = "Ditolak"
+	PerubahanJadwalStatusDibatalkan = "Dibatalkan"
+)
+
+// Define constants for DaftarTunggu Jenis
+const (
+	DaftarTungguJenisTanggal   = "Tanggal Nikah" // slot balai KUA pada tanggal tertentu
+	DaftarTungguJenisBimbingan = "Bimbingan"     // kursi sesi bimbingan perkawinan
+)
+
+// Define constants for DaftarTunggu Status
+const (
+	DaftarTungguStatusMenunggu    = "Menunggu"
+	DaftarTungguStatusDitawarkan  = "Ditawarkan"
+	DaftarTungguStatusDiterima    = "Diterima"
+	DaftarTungguStatusKedaluwarsa = "Kedaluwarsa"
+	DaftarTungguStatusDibatalkan  = "Dibatalkan"
+)
+
+// Define constants for DokumenPendaftaran Jenis (berkas persyaratan nikah)
+const (
+	DokumenJenisKTP              = "KTP"
+	DokumenJenisKK               = "Kartu Keluarga"
+	DokumenJenisAktaKelahiran    = "Akta Kelahiran"
+	DokumenJenisN1               = "N1" // surat pengantar nikah dari kelurahan/desa
+	DokumenJenisN2               = "N2" // permohonan kehendak nikah
+	DokumenJenisN3               = "N3" // persetujuan calon mempelai
+	DokumenJenisN4               = "N4" // izin orang tua (calon di bawah 21 tahun)
+	DokumenJenisPasFoto          = "Pas Foto"
+	DokumenJenisAktaCerai        = "Akta Cerai"         // status Cerai Hidup
+	DokumenJenisAktaKematian     = "Akta Kematian"      // status Cerai Mati (pasangan terdahulu)
+	DokumenJenisSuratDispensasi  = "Surat Dispensasi"   // dispensasi pengadilan (calon di bawah 19 tahun)
+	DokumenJenisIzinKomandan     = "Izin Komandan"      // anggota TNI/Polri
+	DokumenJenisPaspor           = "Paspor"             // calon WNA
+	DokumenJenisIzinKedutaan     = "Izin Kedutaan"      // calon WNA, surat izin dari kedutaan/perwakilan negaranya
+	DokumenJenisAktaKematianAyah = "Akta Kematian Ayah" // ayah calon istri meninggal, wali berpindah ke nasab berikutnya
+	DokumenJenisKTPWali          = "KTP Wali"           // wali nasab selain ayah kandung
+)
+
+// Define constants for DokumenPendaftaran Pihak (pemilik dokumen)
+const (
+	DokumenPihakSuami   = "Calon Suami"
+	DokumenPihakIstri   = "Calon Istri"
+	DokumenPihakBersama = "Bersama" // dokumen milik pasangan (mis. N2, N3)
+)
+
+// Define constants for DokumenPendaftaran Status_review
+const (
+	DokumenStatusMenunggu = "Menunggu Review"
+	DokumenStatusDiterima = "Diterima"
+	DokumenStatusDitolak  = "Ditolak"
+)
+
+// Define constants for PengaturanSistem Kunci
+const (
+	PengaturanMfaWajib = "mfa_wajib" // "mfa_wajib.<role>" = "true" jika 2FA wajib untuk role tersebut; "mfa_wajib" saja adalah pengaturan lama untuk semua role
+)
+
 // ==================== HELPER FUNCTIONS ====================
 
 // GetUrutanWaliNasab - Mengembalikan urutan wali nasab sesuai syariat Islam
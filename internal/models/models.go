@@ -77,6 +77,16 @@ type PendaftaranNikah struct {
 	Disetujui_pada       *time.Time `json:"disetujui_pada"`
 	Created_at           time.Time  `json:"dibuat_pada"`
 	Updated_at           time.Time  `json:"diperbarui_pada"`
+
+	// KUA yang menangani data ini (lihat KUA)
+	Kua_id uint `gorm:"not null;default:0;index" json:"id_kua"`
+
+	// Ketidaksediaan penghulu (cuti/sakit/tugas luar) yang bentrok dengan jadwal ini; diisi otomatis saat disetujui
+	Konflik_ketidaksediaan_id *uint `gorm:"index" json:"id_konflik_ketidaksediaan"`
+
+	// Penghulu terakhir yang dilepas dari pendaftaran ini (diganti atau pendaftaran dibatalkan),
+	// agar feed kalendernya menerima event yang dibatalkan
+	Penghulu_sebelumnya_id *uint `gorm:"index" json:"id_penghulu_sebelumnya"`
 }
 
 type WaliNikah struct {
@@ -107,6 +117,13 @@ type Users struct {
 	Nama       string    `gorm:"size:100;not null" json:"nama"`                  // Nama lengkap user
 	Created_at time.Time `gorm:"autoCreateTime" json:"dibuat_pada"`
 	Updated_at time.Time `gorm:"autoUpdateTime" json:"diperbarui_pada"`
+
+	// Penguncian akun setelah login gagal berturut-turut (lihat services.LoginGuardService)
+	Gagal_login     int        `gorm:"not null;default:0" json:"-"`
+	Terkunci_sampai *time.Time `json:"-"`
+
+	// KUA tempat user bertugas (staff, penghulu, kepala KUA) atau KUA pilihan catin; 0 = belum memilih
+	Kua_id uint `gorm:"not null;default:0;index" json:"id_kua"`
 }
 
 // Role definitions - role tersimpan langsung di tabel Users
@@ -115,6 +132,7 @@ type Users struct {
 // - penghulu: Penghulu untuk memimpin nikah
 // - staff: Staff KUA untuk verifikasi
 // - kepala_kua: Kepala KUA untuk approval
+// - super_admin: Pengelola pusat untuk membuat KUA baru (tidak terikat ke satu KUA)
 
 // StaffKUA untuk data staff KUA
 type StaffKUA struct {
@@ -130,6 +148,9 @@ type StaffKUA struct {
 	Status       string    `gorm:"size:20;not null;default:'Aktif'" json:"status"` // Use constants from constants.go
 	Created_at   time.Time `json:"dibuat_pada"`
 	Updated_at   time.Time `json:"diperbarui_pada"`
+
+	// KUA yang menangani data ini (lihat KUA)
+	Kua_id uint `gorm:"not null;default:0;index" json:"id_kua"`
 }
 
 // Penghulu untuk data penghulu
@@ -146,6 +167,9 @@ type Penghulu struct {
 	Rating       float64   `gorm:"default:0" json:"rating"`
 	Created_at   time.Time `json:"dibuat_pada"`
 	Updated_at   time.Time `json:"diperbarui_pada"`
+
+	// KUA yang menangani data ini (lihat KUA)
+	Kua_id uint `gorm:"not null;default:0;index" json:"id_kua"`
 }
 
 // ==================== ADDITIONAL SIMNIKAH MODELS ====================
@@ -176,6 +200,9 @@ type BimbinganPerkawinan struct {
 	Catatan           string    `gorm:"size:500" json:"catatan"`
 	Created_at        time.Time `json:"dibuat_pada"`
 	Updated_at        time.Time `json:"diperbarui_pada"`
+
+	// KUA yang menangani data ini (lihat KUA)
+	Kua_id uint `gorm:"not null;default:0;index" json:"id_kua"`
 }
 
 // ==================== NEW MARRIAGE REGISTRATION FORM STRUCTS ====================
@@ -188,6 +215,11 @@ type DataFormPendaftaranNikah struct {
 		TanggalNikah    string `json:"weddingDate" binding:"required"`
 		WaktuNikah      string `json:"weddingTime" binding:"required"`
 		NomorDispensasi string `json:"dispensationNumber"`
+
+		// KUA tujuan; jika kosong ditentukan dari kecamatan/kabupaten tempat tinggal
+		KuaID     uint   `json:"kuaId"`
+		Kecamatan string `json:"kecamatan"`
+		Kabupaten string `json:"kabupaten"`
 	} `json:"scheduleAndLocation" binding:"required"`
 
 	CalonSuami struct {
@@ -313,3 +345,313 @@ type PendaftaranBimbingan struct {
 	Created_at              time.Time `json:"dibuat_pada"`
 	Updated_at              time.Time `json:"diperbarui_pada"`
 }
+
+// RiwayatStatus model untuk riwayat perubahan status pendaftaran nikah (timeline)
+// Setiap transisi status (termasuk penggantian penghulu) dicatat sebagai satu baris
+type RiwayatStatus struct {
+	ID                   uint      `gorm:"primaryKey" json:"id"`
+	Pendaftaran_nikah_id uint      `gorm:"not null" json:"id_pendaftaran_nikah"`
+	Status_dari          string    `gorm:"size:40" json:"status_dari"` // Kosong untuk pendaftaran baru
+	Status_ke            string    `gorm:"size:40;not null" json:"status_ke"`
+	Aksi                 string    `gorm:"size:50;not null" json:"aksi"`
+	User_id              string    `gorm:"size:20;not null" json:"id_pengguna"` // User yang melakukan perubahan
+	Role                 string    `gorm:"size:20;not null" json:"role"`
+	Catatan              string    `gorm:"size:500" json:"catatan"`
+	Created_at           time.Time `json:"dibuat_pada"`
+}
+
+// UndanganStaff model untuk undangan akun staff/penghulu dari Kepala KUA
+// Link undangan bersifat one-time: setelah ditukarkan, Digunakan_pada terisi dan link tidak berlaku lagi
+type UndanganStaff struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	Token_id         string     `gorm:"size:64;not null;unique" json:"-"` // jti dari token undangan yang ditandatangani
+	Email            string     `gorm:"size:100;not null" json:"email"`
+	Nama             string     `gorm:"size:100;not null" json:"nama"`
+	Role             string     `gorm:"size:20;not null" json:"peran"` // staff atau penghulu
+	NIP              string     `gorm:"size:30" json:"nip"`
+	Jabatan          string     `gorm:"size:50" json:"jabatan"` // Hanya untuk role staff
+	Bagian           string     `gorm:"size:50" json:"bagian"`  // Hanya untuk role staff
+	No_hp            string     `gorm:"size:15" json:"nomor_telepon"`
+	Alamat           string     `gorm:"size:200" json:"alamat"`
+	Dibuat_oleh      string     `gorm:"size:20;not null" json:"dibuat_oleh"`
+	Kedaluwarsa_pada time.Time  `gorm:"not null" json:"kedaluwarsa_pada"`
+	Digunakan_pada   *time.Time `json:"digunakan_pada"`
+	Dibatalkan_pada  *time.Time `json:"dibatalkan_pada"`
+	User_id          string     `gorm:"size:20" json:"id_pengguna"` // Diisi saat undangan ditukarkan
+	Created_at       time.Time  `json:"dibuat_pada"`
+	Updated_at       time.Time  `json:"diperbarui_pada"`
+
+	// KUA yang menangani data ini (lihat KUA)
+	Kua_id uint `gorm:"not null;default:0;index" json:"id_kua"`
+}
+
+// SesiPengguna model untuk sesi login (satu baris per perangkat)
+// Refresh token hanya disimpan dalam bentuk hash SHA-256 dan dirotasi setiap kali dipakai
+type SesiPengguna struct {
+	ID                      uint       `gorm:"primaryKey" json:"id"`
+	Session_id              string     `gorm:"size:64;not null;unique" json:"id_sesi"`
+	User_id                 string     `gorm:"size:20;not null;index" json:"id_pengguna"`
+	Refresh_token_hash      string     `gorm:"size:64;not null;unique" json:"-"`
+	Refresh_token_lama_hash string     `gorm:"size:64;index" json:"-"`    // Hash refresh token sebelum rotasi terakhir (deteksi pemakaian ulang)
+	Access_jti              string     `gorm:"size:64;not null" json:"-"` // jti access token terakhir yang diterbitkan untuk sesi ini
+	Access_kedaluwarsa_pada time.Time  `gorm:"not null" json:"-"`
+	Kedaluwarsa_pada        time.Time  `gorm:"not null" json:"kedaluwarsa_pada"`
+	User_agent              string     `gorm:"size:255" json:"user_agent"`
+	Ip_address              string     `gorm:"size:45" json:"ip_address"`
+	Terakhir_digunakan_pada time.Time  `json:"terakhir_digunakan_pada"`
+	Dicabut_pada            *time.Time `json:"dicabut_pada"`
+	Alasan_dicabut          string     `gorm:"size:50" json:"alasan_dicabut"`
+	Created_at              time.Time  `json:"dibuat_pada"`
+	Updated_at              time.Time  `json:"diperbarui_pada"`
+}
+
+// TokenDicabut model untuk denylist jti access token yang dicabut sebelum kedaluwarsa
+// Baris boleh dihapus setelah Kedaluwarsa_pada lewat karena token sudah tidak berlaku
+type TokenDicabut struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	Jti              string    `gorm:"size:64;not null;unique" json:"jti"`
+	User_id          string    `gorm:"size:20;not null" json:"id_pengguna"`
+	Kedaluwarsa_pada time.Time `gorm:"not null;index" json:"kedaluwarsa_pada"`
+	Alasan           string    `gorm:"size:50" json:"alasan"`
+	Created_at       time.Time `json:"dibuat_pada"`
+}
+
+// KodeResetPassword model untuk kode OTP lupa password (sekali pakai, berlaku singkat)
+// Kode hanya disimpan dalam bentuk hash bcrypt
+type KodeResetPassword struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	User_id          string     `gorm:"size:20;not null;index" json:"id_pengguna"`
+	Kode_hash        string     `gorm:"size:255;not null" json:"-"`
+	Kedaluwarsa_pada time.Time  `gorm:"not null" json:"kedaluwarsa_pada"`
+	Digunakan_pada   *time.Time `json:"digunakan_pada"`
+	Percobaan        int        `gorm:"not null;default:0" json:"percobaan"` // Jumlah percobaan kode yang salah
+	Created_at       time.Time  `json:"dibuat_pada"`
+}
+
+// LoginAudit model untuk jejak audit setiap percobaan login (berhasil maupun gagal)
+type LoginAudit struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	User_id    string    `gorm:"size:20;index" json:"id_pengguna"` // Kosong jika username tidak ditemukan
+	Username   string    `gorm:"size:50;not null" json:"nama_pengguna"`
+	Ip_address string    `gorm:"size:45;index" json:"ip_address"`
+	User_agent string    `gorm:"size:255" json:"user_agent"`
+	Berhasil   bool      `gorm:"not null" json:"berhasil"`
+	Alasan     string    `gorm:"size:30;not null" json:"alasan"` // Use constants from constants.go
+	Created_at time.Time `gorm:"index" json:"dibuat_pada"`
+}
+
+// MfaPengguna model untuk pengaturan TOTP 2FA per user
+// Aktif=false berarti pendaftaran authenticator belum dikonfirmasi dengan kode
+type MfaPengguna struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	User_id         string     `gorm:"size:20;not null;unique" json:"id_pengguna"`
+	Secret          string     `gorm:"size:255;not null" json:"-"` // base32, dienkripsi AES-GCM (lihat crypto.EncryptString)
+	Aktif           bool       `gorm:"not null;default:false" json:"aktif"`
+	Terakhir_step   int64      `gorm:"not null;default:0" json:"-"` // Langkah TOTP terakhir yang dipakai (mencegah pemakaian ulang kode)
+	Diaktifkan_pada *time.Time `json:"diaktifkan_pada"`
+	Created_at      time.Time  `json:"dibuat_pada"`
+	Updated_at      time.Time  `json:"diperbarui_pada"`
+}
+
+// KodePemulihanMfa model untuk kode pemulihan 2FA (sekali pakai, disimpan dalam bentuk hash)
+type KodePemulihanMfa struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	User_id        string     `gorm:"size:20;not null;index" json:"id_pengguna"`
+	Kode_hash      string     `gorm:"size:64;not null" json:"-"`
+	Digunakan_pada *time.Time `json:"digunakan_pada"`
+	Created_at     time.Time  `json:"dibuat_pada"`
+}
+
+// PengaturanSistem model untuk pengaturan aplikasi yang bisa diubah Kepala KUA (key-value)
+type PengaturanSistem struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Kua_id      uint      `gorm:"not null;default:0;uniqueIndex:idx_pengaturan_kua_kunci" json:"kua_id"` // 0 = default untuk semua KUA
+	Kunci       string    `gorm:"size:100;not null;uniqueIndex:idx_pengaturan_kua_kunci" json:"kunci"`
+	Nilai       string    `gorm:"type:text" json:"nilai"`
+	Diubah_oleh string    `gorm:"size:20" json:"diubah_oleh"`
+	Created_at  time.Time `json:"dibuat_pada"`
+	Updated_at  time.Time `json:"diperbarui_pada"`
+}
+
+// IzinRole model untuk pemetaan izin (permission) ke role yang bisa diubah Kepala KUA
+// Setiap pasangan role-izin punya satu baris per KUA; Diizinkan=false berarti izin dicabut dari role tersebut.
+// Baris Kua_id 0 adalah default; baris KUA tertentu menimpa default hanya untuk KUA tersebut.
+type IzinRole struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Kua_id      uint      `gorm:"not null;default:0;uniqueIndex:idx_izin_role_kua_role_izin" json:"kua_id"`
+	Role        string    `gorm:"size:20;not null;uniqueIndex:idx_izin_role_kua_role_izin" json:"peran"`
+	Izin        string    `gorm:"size:100;not null;uniqueIndex:idx_izin_role_kua_role_izin" json:"izin"`
+	Diizinkan   bool      `gorm:"not null;default:false" json:"diizinkan"`
+	Diubah_oleh string    `gorm:"size:20" json:"diubah_oleh"`
+	Created_at  time.Time `json:"dibuat_pada"`
+	Updated_at  time.Time `json:"diperbarui_pada"`
+}
+
+// KUA untuk data kantor KUA. Users, staff, penghulu, bimbingan, dan pendaftaran terikat ke satu KUA
+type KUA struct {
+	ID                     uint      `gorm:"primaryKey" json:"id"`
+	Kode                   string    `gorm:"size:20;not null;unique" json:"kode"`
+	Nama                   string    `gorm:"size:100;not null" json:"nama"`
+	Kecamatan              string    `gorm:"size:50;not null;uniqueIndex:idx_kua_wilayah" json:"kecamatan"`
+	Kabupaten              string    `gorm:"size:50;not null;uniqueIndex:idx_kua_wilayah" json:"kabupaten"`
+	Provinsi               string    `gorm:"size:50;not null" json:"provinsi"`
+	Alamat                 string    `gorm:"size:200;not null" json:"alamat"`
+	Latitude               *float64  `json:"latitude"`
+	Longitude              *float64  `json:"longitude"`
+	No_telepon             string    `gorm:"size:20" json:"nomor_telepon"`
+	Email                  string    `gorm:"size:100" json:"email"`
+	Kapasitas_nikah_harian int       `gorm:"not null;default:9" json:"kapasitas_nikah_harian"` // Maksimal nikah di KUA per hari (1 per slot waktu)
+	Status                 string    `gorm:"size:20;not null;default:'Aktif'" json:"status"`   // Aktif, Nonaktif
+	Created_at             time.Time `json:"dibuat_pada"`
+	Updated_at             time.Time `json:"diperbarui_pada"`
+
+	// Aturan penjadwalan (lihat services.SchedulingRules)
+	Maks_nikah_penghulu_harian int    `gorm:"not null;default:3" json:"maks_nikah_penghulu_harian"`
+	Jeda_minimal_menit         int    `gorm:"not null;default:60" json:"jeda_minimal_menit"`
+	Interval_slot_menit        int    `gorm:"not null;default:60" json:"interval_slot_menit"` // Jarak antar slot waktu akad di balai KUA
+	Jam_mulai                  string `gorm:"size:5;not null;default:'08:00'" json:"jam_mulai"`
+	Jam_selesai                string `gorm:"size:5;not null;default:'16:00'" json:"jam_selesai"`
+	Hari_tutup                 string `gorm:"size:20;not null;default:''" json:"hari_tutup"`      // Hari tanpa layanan nikah, mis. "0,6" (0 = Minggu)
+	Kecepatan_tempuh_km_jam    int    `gorm:"not null;default:30" json:"kecepatan_tempuh_km_jam"` // Kecepatan rata-rata penghulu antar lokasi akad
+}
+
+// SlotNikah model untuk slot waktu akad nikah di balai KUA.
+// Baris hanya ada untuk slot yang sedang ditahan atau sudah dipesan; slot tanpa baris berarti tersedia.
+// Unique index (kua_id, tanggal, waktu) menjamin satu slot tidak bisa dipesan dua pendaftaran.
+type SlotNikah struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	Kua_id           uint       `gorm:"not null;uniqueIndex:idx_slot_nikah" json:"kua_id"`
+	Tanggal          string     `gorm:"size:10;not null;uniqueIndex:idx_slot_nikah" json:"tanggal"` // YYYY-MM-DD
+	Waktu            string     `gorm:"size:5;not null;uniqueIndex:idx_slot_nikah" json:"waktu"`    // HH:MM
+	Status           string     `gorm:"size:20;not null;index" json:"status"`                       // Ditahan, Terpesan
+	Ditahan_oleh     string     `gorm:"size:20;index" json:"ditahan_oleh"`                          // user_id catin yang menahan/memesan
+	Pendaftaran_id   *uint      `gorm:"index" json:"pendaftaran_id"`
+	Kedaluwarsa_pada *time.Time `json:"kedaluwarsa_pada"` // Batas waktu penahanan (nil jika sudah dipesan)
+	Created_at       time.Time  `json:"dibuat_pada"`
+	Updated_at       time.Time  `json:"diperbarui_pada"`
+}
+
+// HariLibur model untuk hari libur nasional, cuti bersama, dan penutupan KUA.
+// Kua_id 0 berlaku untuk semua KUA; baris dengan Kua_id tertentu hanya berlaku untuk KUA tersebut.
+type HariLibur struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Tanggal     string    `gorm:"size:10;not null;uniqueIndex:idx_hari_libur" json:"tanggal"` // YYYY-MM-DD
+	Kua_id      uint      `gorm:"not null;default:0;uniqueIndex:idx_hari_libur" json:"kua_id"`
+	Nama        string    `gorm:"size:100;not null" json:"nama"`
+	Jenis       string    `gorm:"size:30;not null" json:"jenis"` // Libur Nasional, Cuti Bersama, Penutupan KUA
+	Dibuat_oleh string    `gorm:"size:20" json:"dibuat_oleh"`
+	Created_at  time.Time `json:"dibuat_pada"`
+	Updated_at  time.Time `json:"diperbarui_pada"`
+}
+
+// KetidaksediaanPenghulu model untuk cuti, sakit, tugas luar, dan blokir jadwal penghulu.
+// Jam kosong berarti sepanjang hari; Hari_berulang (mis. "5" = setiap Jumat) membuat ketidaksediaan
+// berulang setiap minggu di dalam rentang tanggal. Hanya status Disetujui yang memblokir penugasan.
+type KetidaksediaanPenghulu struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	Kua_id          uint       `gorm:"not null;index" json:"kua_id"`
+	Penghulu_id     uint       `gorm:"not null;index" json:"penghulu_id"`
+	Jenis           string     `gorm:"size:20;not null" json:"jenis"`                 // Cuti, Sakit, Tugas Luar, Blokir
+	Tanggal_mulai   string     `gorm:"size:10;not null;index" json:"tanggal_mulai"`   // YYYY-MM-DD
+	Tanggal_selesai string     `gorm:"size:10;not null;index" json:"tanggal_selesai"` // YYYY-MM-DD (inklusif)
+	Jam_mulai       string     `gorm:"size:5" json:"jam_mulai"`                       // HH:MM, kosong = sepanjang hari
+	Jam_selesai     string     `gorm:"size:5" json:"jam_selesai"`                     // HH:MM
+	Hari_berulang   string     `gorm:"size:20" json:"hari_berulang"`                  // mis. "1,3" (0 = Minggu), kosong = setiap hari dalam rentang
+	Alasan          string     `gorm:"size:300" json:"alasan"`
+	Status          string     `gorm:"size:30;not null;index" json:"status"` // Menunggu Persetujuan, Disetujui, Ditolak, Dibatalkan
+	Diajukan_oleh   string     `gorm:"size:20" json:"diajukan_oleh"`
+	Diproses_oleh   string     `gorm:"size:20" json:"diproses_oleh"` // kepala KUA yang menyetujui/menolak
+	Diproses_pada   *time.Time `json:"diproses_pada"`
+	Catatan_proses  string     `gorm:"size:300" json:"catatan_proses"`
+	Created_at      time.Time  `json:"dibuat_pada"`
+	Updated_at      time.Time  `json:"diperbarui_pada"`
+}
+
+// PerubahanJadwal model untuk pengajuan perubahan tanggal/waktu akad oleh catin.
+// Pengajuan diproses staff; jika disetujui jadwal pendaftaran diubah dan divalidasi ulang.
+type PerubahanJadwal struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	Kua_id           uint       `gorm:"not null;index" json:"kua_id"`
+	Pendaftaran_id   uint       `gorm:"not null;index" json:"pendaftaran_id"`
+	Tanggal_lama     string     `gorm:"size:10;not null" json:"tanggal_lama"` // YYYY-MM-DD
+	Waktu_lama       string     `gorm:"size:5;not null" json:"waktu_lama"`    // HH:MM
+	Tanggal_baru     string     `gorm:"size:10;not null" json:"tanggal_baru"` // YYYY-MM-DD
+	Waktu_baru       string     `gorm:"size:5;not null" json:"waktu_baru"`    // HH:MM
+	Nomor_dispensasi string     `gorm:"size:50" json:"nomor_dispensasi"`
+	Alasan           string     `gorm:"size:300;not null" json:"alasan"`
+	Status           string     `gorm:"size:30;not null;index" json:"status"` // Menunggu Persetujuan, Disetujui, Ditolak, Dibatalkan
+	Diajukan_oleh    string     `gorm:"size:20" json:"diajukan_oleh"`
+	Diproses_oleh    string     `gorm:"size:20" json:"diproses_oleh"` // staff/kepala KUA yang menyetujui/menolak
+	Diproses_pada    *time.Time `json:"diproses_pada"`
+	Catatan_proses   string     `gorm:"size:300" json:"catatan_proses"`
+	Created_at       time.Time  `json:"dibuat_pada"`
+	Updated_at       time.Time  `json:"diperbarui_pada"`
+}
+
+// DaftarTunggu model untuk antrean catin pada tanggal nikah di KUA yang penuh atau sesi bimbingan yang penuh.
+// Antrean dipromosikan berurutan (FIFO): catin terdepan mendapat tawaran yang berlaku sampai Berlaku_sampai;
+// untuk tanggal nikah, slot balai KUA ditahan atas nama catin selama tawaran berlaku.
+type DaftarTunggu struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	Kua_id          uint       `gorm:"not null;index" json:"kua_id"`
+	Jenis           string     `gorm:"size:20;not null;index" json:"jenis"` // Tanggal Nikah, Bimbingan
+	Tanggal         string     `gorm:"size:10;index" json:"tanggal"`        // YYYY-MM-DD, untuk jenis Tanggal Nikah
+	Bimbingan_id    *uint      `gorm:"index" json:"bimbingan_id"`           // untuk jenis Bimbingan
+	Pendaftaran_id  *uint      `json:"pendaftaran_id"`                      // pendaftaran yang didaftarkan ke bimbingan
+	User_id         string     `gorm:"size:20;not null;index" json:"user_id"`
+	Status          string     `gorm:"size:20;not null;index" json:"status"` // Menunggu, Ditawarkan, Diterima, Kedaluwarsa, Dibatalkan
+	Waktu_tawaran   string     `gorm:"size:5" json:"waktu_tawaran"`          // HH:MM slot balai KUA yang ditawarkan
+	Ditawarkan_pada *time.Time `json:"ditawarkan_pada"`
+	Berlaku_sampai  *time.Time `json:"berlaku_sampai"` // batas menerima tawaran
+	Diproses_pada   *time.Time `json:"diproses_pada"`
+	Created_at      time.Time  `json:"dibuat_pada"`
+	Updated_at      time.Time  `json:"diperbarui_pada"`
+}
+
+// KalenderFeed model untuk URL langganan iCalendar per user. Token_id adalah jti dari token feed yang
+// ditandatangani; membuat ulang feed mengganti Token_id sehingga URL lama tidak berlaku lagi.
+type KalenderFeed struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	User_id          string     `gorm:"size:20;not null;unique" json:"user_id"`
+	Token_id         string     `gorm:"size:64;not null;unique" json:"-"`
+	Terakhir_diakses *time.Time `json:"terakhir_diakses"`
+	Created_at       time.Time  `json:"dibuat_pada"`
+	Updated_at       time.Time  `json:"diperbarui_pada"`
+}
+
+// DokumenPendaftaran model untuk berkas persyaratan nikah yang diunggah (KTP, KK, N1-N4, dll).
+// File disimpan di storage (lokal atau S3) dengan Storage_key; Checksum adalah SHA-256 isi file.
+// Unggahan ulang untuk jenis dan pihak yang sama membuat baris baru, review terakhir yang berlaku.
+type DokumenPendaftaran struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Kua_id         uint       `gorm:"not null;index" json:"kua_id"`
+	Pendaftaran_id uint       `gorm:"not null;index" json:"pendaftaran_id"`
+	Jenis          string     `gorm:"size:30;not null" json:"jenis"` // Use constants from constants.go
+	Pihak          string     `gorm:"size:20;not null" json:"pihak"` // Calon Suami, Calon Istri, Bersama
+	Nama_file      string     `gorm:"size:255;not null" json:"nama_file"`
+	Tipe_mime      string     `gorm:"size:50;not null" json:"tipe_mime"`
+	Ukuran         int64      `gorm:"not null" json:"ukuran"` // byte
+	Checksum       string     `gorm:"size:64;not null" json:"checksum"`
+	Storage_key    string     `gorm:"size:255;not null" json:"-"`
+	Status_review  string     `gorm:"size:20;not null;default:'Menunggu Review';index" json:"status_review"`
+	Catatan_review string     `gorm:"size:300" json:"catatan_review"`
+	Direview_oleh  string     `gorm:"size:20" json:"direview_oleh"`
+	Direview_pada  *time.Time `json:"direview_pada"`
+	Diunggah_oleh  string     `gorm:"size:20;not null" json:"diunggah_oleh"`
+	Created_at     time.Time  `json:"dibuat_pada"`
+	Updated_at     time.Time  `json:"diperbarui_pada"`
+}
+
+// KelengkapanBerkas model untuk penanda petugas bahwa satu item checklist dokumen sudah ada
+// (mis. berkas fisik diserahkan langsung di KUA tanpa diunggah). Satu baris per jenis dan pihak.
+type KelengkapanBerkas struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Pendaftaran_id uint      `gorm:"not null;uniqueIndex:idx_kelengkapan_berkas" json:"pendaftaran_id"`
+	Jenis          string    `gorm:"size:30;not null;uniqueIndex:idx_kelengkapan_berkas" json:"jenis"`
+	Pihak          string    `gorm:"size:20;not null;uniqueIndex:idx_kelengkapan_berkas" json:"pihak"`
+	Ada            bool      `gorm:"not null;default:false" json:"ada"`
+	Catatan        string    `gorm:"size:300" json:"catatan"`
+	Ditandai_oleh  string    `gorm:"size:20" json:"ditandai_oleh"`
+	Created_at     time.Time `json:"dibuat_pada"`
+	Updated_at     time.Time `json:"diperbarui_pada"`
+}
@@ -0,0 +1,68 @@
+package seeders
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"simnikah/internal/services"
+
+	"gorm.io/gorm"
+)
+
+// DefaultHolidayDir is the directory with yearly national holiday files (e.g. 2026.json, 2027.csv)
+const DefaultHolidayDir = "migrations/hari_libur"
+
+// SeedHolidays imports every *.json and *.csv file in dir as national holidays (kua_id = 0).
+// Existing dates are updated, so the files stay the source of truth for national holidays.
+// KUA-specific closures created by Kepala KUA are never touched.
+func SeedHolidays(db *gorm.DB, dir string) error {
+	if dir == "" {
+		dir = DefaultHolidayDir
+	}
+	log.Printf("🌱 Seeding national holidays from %s...", dir)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("✅ Holiday directory %s not found, skipping", dir)
+			return nil
+		}
+		return fmt.Errorf("error reading holiday directory: %v", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (ext == ".json" || ext == ".csv") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	holidayService := services.NewHolidayService(db)
+	total := 0
+	for _, name := range names {
+		file, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("error opening %s: %v", name, err)
+		}
+		holidays, err := services.ParseHolidayFile(file, filepath.Ext(name))
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %v", name, err)
+		}
+
+		count, err := holidayService.Import(0, holidays, "")
+		if err != nil {
+			return fmt.Errorf("error importing %s: %v", name, err)
+		}
+		total += count
+	}
+
+	log.Printf("✅ National holidays ready (%d dates from %d files)", total, len(names))
+	return nil
+}
@@ -0,0 +1,90 @@
+package seeders
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/internal/services"
+
+	"gorm.io/gorm"
+)
+
+// DefaultKUAKode is the code of the KUA created for installations that predate multi-KUA support
+const DefaultKUAKode = "KUA-BJM-UTARA"
+
+// SeedDefaultKUA creates the default KUA if no KUA exists yet, then assigns every record
+// without a KUA (kua_id = 0) to it. Catin accounts are left alone so they can pick their own KUA,
+// and super admin accounts stay outside every KUA.
+func SeedDefaultKUA(db *gorm.DB) error {
+	log.Println("🌱 Seeding default KUA...")
+
+	var kua structs.KUA
+	err := db.Where("kode = ?", DefaultKUAKode).First(&kua).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("error checking default KUA: %v", err)
+	}
+
+	if err == gorm.ErrRecordNotFound {
+		var count int64
+		db.Model(&structs.KUA{}).Count(&count)
+		if count > 0 {
+			// Installation already configured its own KUAs; nothing to backfill against
+			log.Println("✅ KUA already configured, skipping default KUA")
+			return nil
+		}
+
+		lat := -3.3148
+		lon := 114.5925
+		now := time.Now()
+		kua = structs.KUA{
+			Kode:       DefaultKUAKode,
+			Nama:       "KUA Kecamatan Banjarmasin Utara",
+			Kecamatan:  "Banjarmasin Utara",
+			Kabupaten:  "Kota Banjarmasin",
+			Provinsi:   "Kalimantan Selatan",
+			Alamat:     "KUA Kecamatan Banjarmasin Utara, Kelurahan Pangeran, Kecamatan Banjarmasin Utara, Kota Banjarmasin, Kalimantan Selatan",
+			Latitude:   &lat,
+			Longitude:  &lon,
+			Status:     structs.KUAStatusAktif,
+			Created_at: now,
+			Updated_at: now,
+		}
+		services.DefaultSchedulingRules().ApplyTo(&kua)
+		if err := db.Create(&kua).Error; err != nil {
+			return fmt.Errorf("error creating default KUA: %v", err)
+		}
+		log.Printf("✅ Default KUA created (ID: %d, Kode: %s)", kua.ID, kua.Kode)
+	}
+
+	// Backfill records created before multi-KUA support (or by the user seeders)
+	backfills := []struct {
+		name  string
+		query *gorm.DB
+	}{
+		{"users", db.Model(&structs.Users{}).Where("kua_id = 0 AND role NOT IN ?", []string{structs.UserRoleUserBiasa, structs.UserRoleSuperAdmin})},
+		{"staff", db.Model(&structs.StaffKUA{}).Where("kua_id = 0")},
+		{"penghulu", db.Model(&structs.Penghulu{}).Where("kua_id = 0")},
+		{"pendaftaran nikah", db.Model(&structs.PendaftaranNikah{}).Where("kua_id = 0")},
+		{"bimbingan perkawinan", db.Model(&structs.BimbinganPerkawinan{}).Where("kua_id = 0")},
+		{"undangan staff", db.Model(&structs.UndanganStaff{}).Where("kua_id = 0")},
+	}
+	for _, b := range backfills {
+		result := b.query.Update("kua_id", kua.ID)
+		if result.Error != nil {
+			return fmt.Errorf("error assigning %s to default KUA: %v", b.name, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("✅ Assigned %d %s record(s) to %s", result.RowsAffected, b.name, kua.Nama)
+		}
+	}
+
+	// Catin yang sudah mendaftar nikah ikut KUA pendaftarannya
+	if err := db.Exec("UPDATE users SET kua_id = ? WHERE kua_id = 0 AND role = ? AND user_id IN (SELECT pendaftar_id FROM pendaftaran_nikahs WHERE kua_id = ?)",
+		kua.ID, structs.UserRoleUserBiasa, kua.ID).Error; err != nil {
+		return fmt.Errorf("error assigning catin to default KUA: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package seeders
+
+import (
+	"log"
+
+	"simnikah/internal/services"
+
+	"gorm.io/gorm"
+)
+
+// SeedRolePermissions creates default role-permission mappings that do not exist yet.
+// Existing rows (including ones changed by Kepala KUA) are left untouched.
+func SeedRolePermissions(db *gorm.DB) error {
+	log.Println("🌱 Seeding role permissions...")
+
+	created, err := services.NewPermissionService(db).EnsureDefaults()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ Role permissions ready (%d new mappings)", created)
+	return nil
+}
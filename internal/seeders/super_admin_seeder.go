@@ -0,0 +1,69 @@
+package seeders
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/crypto"
+
+	"gorm.io/gorm"
+)
+
+// SeedSuperAdmin creates the super admin account (role super_admin, not bound to any KUA) if it
+// does not exist yet. Unlike the other user seeders there is no default password: the account
+// can create new KUAs, so it is only seeded when a password is provided.
+func SeedSuperAdmin(db *gorm.DB, username, email, password string) error {
+	if password == "" {
+		log.Println("ℹ️  SEEDER_SUPER_ADMIN_PASSWORD not set, skipping super admin")
+		return nil
+	}
+
+	log.Println("🌱 Seeding super admin user...")
+
+	if username == "" {
+		username = "superadmin"
+	}
+	if email == "" {
+		email = "superadmin@kua.go.id"
+	}
+
+	var existingUser structs.Users
+	err := db.Where("username = ? OR email = ?", username, email).First(&existingUser).Error
+	if err == nil {
+		log.Printf("✅ Super admin user already exists (ID: %s, Username: %s)", existingUser.User_id, existingUser.Username)
+		return nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("error checking existing super admin: %v", err)
+	}
+
+	hashedPassword, err := crypto.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %v", err)
+	}
+
+	userID := "SADM" + fmt.Sprintf("%d", time.Now().Unix())
+	user := structs.Users{
+		User_id:    userID,
+		Username:   username,
+		Email:      email,
+		Password:   hashedPassword,
+		Role:       structs.UserRoleSuperAdmin,
+		Status:     structs.UserStatusAktif,
+		Nama:       "Super Admin SimNikah",
+		Created_at: time.Now(),
+		Updated_at: time.Now(),
+	}
+	if err := db.Create(&user).Error; err != nil {
+		return fmt.Errorf("error creating super admin user: %v", err)
+	}
+
+	log.Printf("✅ Super admin user created successfully!")
+	log.Printf("   User ID: %s", userID)
+	log.Printf("   Username: %s", username)
+	log.Printf("   Role: %s", structs.UserRoleSuperAdmin)
+
+	return nil
+}
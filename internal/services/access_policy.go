@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AccessMode membedakan akses baca dan ubah terhadap satu data
+type AccessMode int
+
+const (
+	// AccessView untuk melihat data
+	AccessView AccessMode = iota
+	// AccessEdit untuk mengubah data
+	AccessEdit
+)
+
+var (
+	// ErrAccessDenied dikembalikan jika user boleh tahu data ada, tetapi tidak boleh melakukan aksi (403)
+	ErrAccessDenied = errors.New("akses ditolak")
+	// ErrResourceNotFound dikembalikan jika data tidak ada atau bukan milik user (404, agar ID milik orang lain tidak bisa ditebak)
+	ErrResourceNotFound = errors.New("data tidak ditemukan")
+)
+
+// AccessPolicy menerapkan aturan kepemilikan data:
+// catin hanya pendaftaran miliknya (Pendaftar_id), penghulu hanya pendaftaran yang ditugaskan kepadanya,
+// staff dan kepala KUA semua pendaftaran di KUA-nya. Notifikasi hanya bisa diakses pemiliknya.
+type AccessPolicy struct {
+	DB *gorm.DB
+}
+
+// NewAccessPolicy membuat instance baru dari AccessPolicy
+func NewAccessPolicy(db *gorm.DB) *AccessPolicy {
+	return &AccessPolicy{DB: db}
+}
+
+// LoadRegistration mengambil pendaftaran nikah jika actor boleh mengaksesnya dengan mode tertentu
+func (ap *AccessPolicy) LoadRegistration(actor TransitionActor, registrationID string, mode AccessMode) (*structs.PendaftaranNikah, error) {
+	var pendaftaran structs.PendaftaranNikah
+	if err := ap.DB.Where("id = ?", registrationID).First(&pendaftaran).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrResourceNotFound
+		}
+		return nil, err
+	}
+
+	var penghuluID uint
+	if actor.Role == structs.UserRolePenghulu {
+		var penghulu structs.Penghulu
+		if err := ap.DB.Select("id").Where("user_id = ?", actor.UserID).First(&penghulu).Error; err == nil {
+			penghuluID = penghulu.ID
+		}
+	}
+
+	if err := CheckRegistrationAccess(actor, &pendaftaran, penghuluID, mode); err != nil {
+		return nil, err
+	}
+	return &pendaftaran, nil
+}
+
+// CheckRegistrationAccess memutuskan apakah actor boleh mengakses pendaftaran.
+// penghuluID adalah ID profil penghulu milik actor (0 jika actor bukan penghulu).
+func CheckRegistrationAccess(actor TransitionActor, p *structs.PendaftaranNikah, penghuluID uint, mode AccessMode) error {
+	switch actor.Role {
+	case structs.UserRoleStaff, structs.UserRoleKepalaKUA:
+		if p.Kua_id != actor.KuaID {
+			return ErrResourceNotFound
+		}
+		return nil
+	case structs.UserRoleUserBiasa:
+		if p.Pendaftar_id != actor.UserID {
+			return ErrResourceNotFound
+		}
+		return nil
+	case structs.UserRolePenghulu:
+		if penghuluID == 0 || p.Penghulu_id == nil || *p.Penghulu_id != penghuluID {
+			return ErrResourceNotFound
+		}
+		if mode == AccessEdit {
+			return ErrAccessDenied
+		}
+		return nil
+	default:
+		return ErrAccessDenied
+	}
+}
+
+// CheckUserScope memastikan actor hanya mengakses data atas nama dirinya sendiri (mis. /notifikasi/user/:user_id)
+func CheckUserScope(actor TransitionActor, targetUserID string) error {
+	if actor.UserID == "" || actor.UserID != targetUserID {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// CheckNotificationOwner memastikan notifikasi milik actor; notifikasi orang lain diperlakukan tidak ada
+func CheckNotificationOwner(actor TransitionActor, n *structs.Notifikasi) error {
+	if actor.UserID == "" || n.User_id != actor.UserID {
+		return ErrResourceNotFound
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	structs "simnikah/internal/models"
+)
+
+func uintPtr(v uint) *uint { return &v }
+
+func TestCheckRegistrationAccess(t *testing.T) {
+	pendaftaran := &structs.PendaftaranNikah{
+		ID:           1,
+		Pendaftar_id: "USR1",
+		Penghulu_id:  uintPtr(7),
+		Kua_id:       1,
+	}
+	unassigned := &structs.PendaftaranNikah{ID: 2, Pendaftar_id: "USR1", Kua_id: 1}
+
+	catinA := TransitionActor{UserID: "USR1", Role: structs.UserRoleUserBiasa}
+	catinB := TransitionActor{UserID: "USR2", Role: structs.UserRoleUserBiasa}
+	penghulu := TransitionActor{UserID: "PGH1", Role: structs.UserRolePenghulu}
+	staff := TransitionActor{UserID: "STF1", Role: structs.UserRoleStaff, KuaID: 1}
+	kepala := TransitionActor{UserID: "KUA1", Role: structs.UserRoleKepalaKUA, KuaID: 1}
+	staffLain := TransitionActor{UserID: "STF2", Role: structs.UserRoleStaff, KuaID: 2}
+	kepalaLain := TransitionActor{UserID: "KUA2", Role: structs.UserRoleKepalaKUA, KuaID: 2}
+
+	tests := []struct {
+		name       string
+		actor      TransitionActor
+		p          *structs.PendaftaranNikah
+		penghuluID uint
+		mode       AccessMode
+		want       error
+	}{
+		{"catin melihat miliknya", catinA, pendaftaran, 0, AccessView, nil},
+		{"catin mengubah miliknya", catinA, pendaftaran, 0, AccessEdit, nil},
+		{"catin melihat milik orang lain", catinB, pendaftaran, 0, AccessView, ErrResourceNotFound},
+		{"catin mengubah milik orang lain", catinB, pendaftaran, 0, AccessEdit, ErrResourceNotFound},
+		{"penghulu melihat yang ditugaskan", penghulu, pendaftaran, 7, AccessView, nil},
+		{"penghulu mengubah yang ditugaskan", penghulu, pendaftaran, 7, AccessEdit, ErrAccessDenied},
+		{"penghulu lain", penghulu, pendaftaran, 8, AccessView, ErrResourceNotFound},
+		{"penghulu tanpa profil", penghulu, pendaftaran, 0, AccessView, ErrResourceNotFound},
+		{"penghulu pada pendaftaran belum ditugaskan", penghulu, unassigned, 7, AccessView, ErrResourceNotFound},
+		{"staff melihat semua di KUA-nya", staff, pendaftaran, 0, AccessView, nil},
+		{"staff mengubah semua di KUA-nya", staff, unassigned, 0, AccessEdit, nil},
+		{"kepala KUA melihat semua di KUA-nya", kepala, pendaftaran, 0, AccessView, nil},
+		{"staff KUA lain", staffLain, pendaftaran, 0, AccessView, ErrResourceNotFound},
+		{"kepala KUA lain mengubah", kepalaLain, pendaftaran, 0, AccessEdit, ErrResourceNotFound},
+		{"role tidak dikenal", TransitionActor{UserID: "X", Role: "tamu"}, pendaftaran, 0, AccessView, ErrAccessDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRegistrationAccess(tt.actor, tt.p, tt.penghuluID, tt.mode)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCheckUserScope(t *testing.T) {
+	actor := TransitionActor{UserID: "USR1", Role: structs.UserRoleUserBiasa}
+
+	if err := CheckUserScope(actor, "USR1"); err != nil {
+		t.Errorf("Expected own scope to be allowed, got %v", err)
+	}
+	if err := CheckUserScope(actor, "USR2"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Expected ErrAccessDenied for other user, got %v", err)
+	}
+	if err := CheckUserScope(TransitionActor{Role: structs.UserRoleStaff}, ""); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Expected ErrAccessDenied for empty actor, got %v", err)
+	}
+	staff := TransitionActor{UserID: "STF1", Role: structs.UserRoleStaff}
+	if err := CheckUserScope(staff, "USR1"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Expected staff to be denied access to another user's inbox, got %v", err)
+	}
+}
+
+func TestCheckNotificationOwner(t *testing.T) {
+	notif := &structs.Notifikasi{ID: 10, User_id: "USR1"}
+
+	if err := CheckNotificationOwner(TransitionActor{UserID: "USR1"}, notif); err != nil {
+		t.Errorf("Expected owner to be allowed, got %v", err)
+	}
+	if err := CheckNotificationOwner(TransitionActor{UserID: "USR2"}, notif); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("Expected ErrResourceNotFound for other user, got %v", err)
+	}
+}
@@ -0,0 +1,414 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/ical"
+	"simnikah/pkg/locale"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// DurasiAkadNikah adalah perkiraan lama akad untuk event kalender (jadwal hanya menyimpan jam mulai)
+	DurasiAkadNikah = time.Hour
+	// KalenderFeedRefresh adalah interval pembaruan yang disarankan ke aplikasi kalender
+	KalenderFeedRefresh = time.Hour
+	// kalenderFeedLewat adalah rentang event yang sudah lewat yang masih dimuat di feed
+	kalenderFeedLewat = 30 * 24 * time.Hour
+)
+
+// ZonaWaktuJadwal adalah zona waktu default jam nikah dan bimbingan yang disimpan (waktu lokal KUA)
+var ZonaWaktuJadwal = locale.WIB
+
+var (
+	// ErrKalenderFeedInvalid dikembalikan jika token feed tidak valid, sudah diganti, atau akun tidak aktif
+	ErrKalenderFeedInvalid = errors.New("link kalender tidak valid atau sudah tidak berlaku")
+)
+
+// KalenderFeedClaims adalah isi token feed kalender yang ditandatangani (HS256). Token tidak kedaluwarsa;
+// pencabutan dilakukan dengan mengganti Token_id di KalenderFeed.
+type KalenderFeedClaims struct {
+	UserID string `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// CalendarFeedService untuk URL langganan iCalendar: penghulu menerima jadwal akad yang ditugaskan,
+// catin menerima akad dan bimbingannya, staff/kepala KUA menerima seluruh jadwal KUA
+type CalendarFeedService struct {
+	DB         *gorm.DB
+	signingKey []byte
+	baseURL    string
+}
+
+// NewCalendarFeedService membuat instance baru dari CalendarFeedService.
+// Kunci tanda tangan diambil dari CALENDAR_FEED_SIGNING_KEY (fallback ke JWT_KEY).
+func NewCalendarFeedService(db *gorm.DB) *CalendarFeedService {
+	baseURL := os.Getenv("CALENDAR_FEED_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080/simnikah/kalender/feed"
+	}
+
+	return &CalendarFeedService{DB: db, signingKey: SecretKey(EnvCalendarFeedSigningKey), baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Subscribe mengembalikan URL feed milik user, membuat feed jika belum ada.
+// reset mengganti token sehingga URL yang lama tidak berlaku lagi.
+func (cs *CalendarFeedService) Subscribe(userID string, reset bool) (string, *structs.KalenderFeed, error) {
+	var feed structs.KalenderFeed
+	err := cs.DB.Where("user_id = ?", userID).First(&feed).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	if errors.Is(err, gorm.ErrRecordNotFound) || reset {
+		tokenID, err := randomTokenID()
+		if err != nil {
+			return "", nil, fmt.Errorf("gagal membuat token kalender: %v", err)
+		}
+		feed.User_id = userID
+		feed.Token_id = tokenID
+		feed.Updated_at = now
+		if feed.ID == 0 {
+			feed.Created_at = now
+		}
+		if err := cs.DB.Save(&feed).Error; err != nil {
+			return "", nil, err
+		}
+	}
+
+	claims := KalenderFeedClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       feed.Token_id,
+			IssuedAt: jwt.NewNumericDate(feed.Updated_at),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(cs.signingKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("gagal menandatangani token kalender: %v", err)
+	}
+	return cs.baseURL + "/" + token + ".ics", &feed, nil
+}
+
+// Revoke menghapus feed user sehingga URL langganan tidak berlaku lagi
+func (cs *CalendarFeedService) Revoke(userID string) error {
+	return cs.DB.Where("user_id = ?", userID).Delete(&structs.KalenderFeed{}).Error
+}
+
+// Verify memvalidasi token dari URL feed (akhiran .ics boleh ada) dan mengembalikan user aktif pemiliknya
+func (cs *CalendarFeedService) Verify(token string) (*structs.Users, error) {
+	token = strings.TrimSuffix(token, ".ics")
+	claims := &KalenderFeedClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("metode signing token tidak valid")
+		}
+		return cs.signingKey, nil
+	})
+	if err != nil || !parsed.Valid {
+		return nil, ErrKalenderFeedInvalid
+	}
+
+	var feed structs.KalenderFeed
+	if err := cs.DB.Where("user_id = ? AND token_id = ?", claims.UserID, claims.ID).First(&feed).Error; err != nil {
+		return nil, ErrKalenderFeedInvalid
+	}
+	var user structs.Users
+	if err := cs.DB.Where("user_id = ? AND status = ?", feed.User_id, structs.UserStatusAktif).First(&user).Error; err != nil {
+		return nil, ErrKalenderFeedInvalid
+	}
+
+	now := time.Now()
+	cs.DB.Model(&feed).Update("terakhir_diakses", now)
+	return &user, nil
+}
+
+// Build menyusun kalender untuk user sesuai perannya. Pendaftaran yang ditolak, dibatalkan, atau
+// dialihkan ke penghulu lain tetap dimuat dengan STATUS:CANCELLED agar event di aplikasi klien ikut dibatalkan.
+func (cs *CalendarFeedService) Build(user *structs.Users) (*ical.Calendar, error) {
+	from := time.Now().Add(-kalenderFeedLewat).Truncate(24 * time.Hour)
+	cal := &ical.Calendar{ProdID: "-//SimNikah//Jadwal Nikah//ID", Refresh: KalenderFeedRefresh}
+
+	var err error
+	switch user.Role {
+	case structs.UserRolePenghulu:
+		cal.Name = "Jadwal Akad - " + user.Nama
+		cal.Events, err = cs.penghuluEvents(user.User_id, from)
+	case structs.UserRoleUserBiasa:
+		cal.Name = "Jadwal Nikah Saya"
+		cal.Events, err = cs.catinEvents(user.User_id, from)
+	case structs.UserRoleStaff, structs.UserRoleKepalaKUA:
+		cal.Name = "Jadwal Nikah KUA"
+		cal.Events, err = cs.kuaEvents(user.Kua_id, from)
+	default:
+		return nil, ErrKalenderFeedInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cal, nil
+}
+
+// penghuluEvents memuat akad yang ditugaskan ke penghulu, termasuk yang sudah dialihkan atau dibatalkan
+func (cs *CalendarFeedService) penghuluEvents(userID string, from time.Time) ([]ical.Event, error) {
+	var penghulu structs.Penghulu
+	if err := cs.DB.Where("user_id = ?", userID).First(&penghulu).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []ical.Event{}, nil
+		}
+		return nil, err
+	}
+
+	var list []structs.PendaftaranNikah
+	if err := cs.DB.Where("(penghulu_id = ? OR penghulu_sebelumnya_id = ?) AND tanggal_nikah >= ?", penghulu.ID, penghulu.ID, from).
+		Order("tanggal_nikah, waktu_nikah").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return cs.registrationEvents(list, func(p *structs.PendaftaranNikah) bool {
+		return p.Penghulu_id == nil || *p.Penghulu_id != penghulu.ID
+	})
+}
+
+// catinEvents memuat akad dan sesi bimbingan pendaftaran milik catin (pendaftar atau calon pasangan)
+func (cs *CalendarFeedService) catinEvents(userID string, from time.Time) ([]ical.Event, error) {
+	query := cs.DB.Where("pendaftar_id = ?", userID)
+	var calon structs.CalonPasangan
+	if err := cs.DB.Select("id").Where("user_id = ?", userID).First(&calon).Error; err == nil {
+		calonID := fmt.Sprintf("%d", calon.ID)
+		query = cs.DB.Where("pendaftar_id = ? OR calon_suami_id = ? OR calon_istri_id = ?", userID, calonID, calonID)
+	}
+
+	var list []structs.PendaftaranNikah
+	if err := cs.DB.Where(query).Where("tanggal_nikah >= ?", from).
+		Order("tanggal_nikah, waktu_nikah").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	events, err := cs.registrationEvents(list, nil)
+	if err != nil || len(list) == 0 {
+		return events, err
+	}
+
+	ids := make([]uint, len(list))
+	for i := range list {
+		ids[i] = list[i].ID
+	}
+	var bimbingan []structs.BimbinganPerkawinan
+	if err := cs.DB.Where("id IN (?) AND tanggal_bimbingan >= ?",
+		cs.DB.Model(&structs.PendaftaranBimbingan{}).Select("bimbingan_perkawinan_id").Where("pendaftaran_nikah_id IN ?", ids), from).
+		Order("tanggal_bimbingan, waktu_mulai").Find(&bimbingan).Error; err != nil {
+		return nil, err
+	}
+	return append(events, cs.bimbinganEvents(bimbingan)...), nil
+}
+
+// kuaEvents memuat seluruh akad (kecuali draft) dan sesi bimbingan di KUA
+func (cs *CalendarFeedService) kuaEvents(kuaID uint, from time.Time) ([]ical.Event, error) {
+	var list []structs.PendaftaranNikah
+	if err := cs.DB.Scopes(TenantScope(kuaID)).
+		Where("tanggal_nikah >= ? AND status_pendaftaran <> ?", from, structs.StatusPendaftaranDraft).
+		Order("tanggal_nikah, waktu_nikah").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	events, err := cs.registrationEvents(list, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var bimbingan []structs.BimbinganPerkawinan
+	if err := cs.DB.Scopes(TenantScope(kuaID)).Where("tanggal_bimbingan >= ?", from).
+		Order("tanggal_bimbingan, waktu_mulai").Find(&bimbingan).Error; err != nil {
+		return nil, err
+	}
+	return append(events, cs.bimbinganEvents(bimbingan)...), nil
+}
+
+// registrationEvents mengubah pendaftaran menjadi event akad. dialihkan (boleh nil) menandai pendaftaran
+// yang tidak lagi menjadi milik penerima feed sehingga ikut dikirim sebagai event yang dibatalkan.
+func (cs *CalendarFeedService) registrationEvents(list []structs.PendaftaranNikah, dialihkan func(*structs.PendaftaranNikah) bool) ([]ical.Event, error) {
+	events := make([]ical.Event, 0, len(list))
+	if len(list) == 0 {
+		return events, nil
+	}
+
+	ids := make([]uint, 0, len(list))
+	calonIDs := make([]string, 0, len(list)*2)
+	kuaIDs := make([]uint, 0)
+	penghuluIDs := make([]uint, 0)
+	for _, p := range list {
+		ids = append(ids, p.ID)
+		calonIDs = append(calonIDs, p.Calon_suami_id, p.Calon_istri_id)
+		kuaIDs = append(kuaIDs, p.Kua_id)
+		if p.Penghulu_id != nil {
+			penghuluIDs = append(penghuluIDs, *p.Penghulu_id)
+		}
+	}
+
+	// Jumlah perubahan jadwal dipakai sebagai SEQUENCE agar aplikasi klien menimpa event lama
+	var perubahan []struct {
+		Pendaftaran_nikah_id uint
+		Jumlah               int
+	}
+	if err := cs.DB.Model(&structs.RiwayatStatus{}).Select("pendaftaran_nikah_id, COUNT(*) AS jumlah").
+		Where("pendaftaran_nikah_id IN ? AND aksi = ?", ids, AksiUbahJadwal).
+		Group("pendaftaran_nikah_id").Scan(&perubahan).Error; err != nil {
+		return nil, err
+	}
+	sequence := make(map[uint]int, len(perubahan))
+	for _, row := range perubahan {
+		sequence[row.Pendaftaran_nikah_id] = row.Jumlah
+	}
+
+	var calon []structs.CalonPasangan
+	if err := cs.DB.Select("id", "nama_lengkap").Where("id IN ?", calonIDs).Find(&calon).Error; err != nil {
+		return nil, err
+	}
+	nama := make(map[string]string, len(calon))
+	for _, c := range calon {
+		nama[fmt.Sprintf("%d", c.ID)] = c.Nama_lengkap
+	}
+
+	var kuaList []structs.KUA
+	if err := cs.DB.Where("id IN ?", kuaIDs).Find(&kuaList).Error; err != nil {
+		return nil, err
+	}
+	kuaByID := make(map[uint]*structs.KUA, len(kuaList))
+	for i := range kuaList {
+		kuaByID[kuaList[i].ID] = &kuaList[i]
+	}
+
+	penghuluNama := make(map[uint]string)
+	if len(penghuluIDs) > 0 {
+		var penghulu []structs.Penghulu
+		if err := cs.DB.Select("id", "nama_lengkap").Where("id IN ?", penghuluIDs).Find(&penghulu).Error; err != nil {
+			return nil, err
+		}
+		for _, ph := range penghulu {
+			penghuluNama[ph.ID] = ph.Nama_lengkap
+		}
+	}
+
+	for i := range list {
+		p := &list[i]
+		batal := registrationCancelled(p.Status_pendaftaran) || (dialihkan != nil && dialihkan(p))
+		event := RegistrationEvent(p, kuaByID[p.Kua_id], nama[p.Calon_suami_id], nama[p.Calon_istri_id], sequence[p.ID], batal)
+		if p.Penghulu_id != nil && !batal {
+			event.Description += "\nPenghulu: " + penghuluNama[*p.Penghulu_id]
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// bimbinganEvents mengubah sesi bimbingan perkawinan menjadi event
+func (cs *CalendarFeedService) bimbinganEvents(list []structs.BimbinganPerkawinan) []ical.Event {
+	events := make([]ical.Event, 0, len(list))
+	for i := range list {
+		events = append(events, BimbinganEvent(&list[i]))
+	}
+	return events
+}
+
+// RegistrationEvent menyusun event akad nikah sebuah pendaftaran. UID tetap per pendaftaran;
+// sequence adalah jumlah perubahan jadwal (ditambah satu jika dibatalkan).
+func RegistrationEvent(p *structs.PendaftaranNikah, kua *structs.KUA, namaSuami, namaIstri string, sequence int, batal bool) ical.Event {
+	start := JadwalTime(p.Tanggal_nikah, p.Waktu_nikah)
+	event := ical.Event{
+		UID:          fmt.Sprintf("pendaftaran-%d@simnikah", p.ID),
+		Sequence:     sequence,
+		Stamp:        p.Updated_at,
+		LastModified: p.Updated_at,
+		Start:        start,
+		End:          start.Add(DurasiAkadNikah),
+		Summary:      "Akad Nikah " + pasangan(namaSuami, namaIstri, p.Nomor_pendaftaran),
+		Description: fmt.Sprintf("Nomor pendaftaran: %s\nStatus: %s\nTempat: %s",
+			p.Nomor_pendaftaran, p.Status_pendaftaran, p.Tempat_nikah),
+		Categories: []string{"Akad Nikah"},
+	}
+
+	if p.Tempat_nikah == "Di KUA" && kua != nil {
+		event.Location = kua.Nama + ", " + kua.Alamat
+		event.Lat, event.Lon = kua.Latitude, kua.Longitude
+	} else {
+		event.Location = p.Alamat_akad
+		event.Lat, event.Lon = p.Latitude, p.Longitude
+	}
+
+	switch {
+	case batal:
+		event.Status = ical.StatusCancelled
+		event.Sequence++
+	case isScheduledStatus(p.Status_pendaftaran):
+		event.Status = ical.StatusConfirmed
+	default:
+		event.Status = ical.StatusTentative
+	}
+	return event
+}
+
+// BimbinganEvent menyusun event sesi bimbingan perkawinan; sesi yang dibatalkan dikirim STATUS:CANCELLED
+func BimbinganEvent(b *structs.BimbinganPerkawinan) ical.Event {
+	start := JadwalTime(b.Tanggal_bimbingan, b.Waktu_mulai)
+	end := JadwalTime(b.Tanggal_bimbingan, b.Waktu_selesai)
+	if !end.After(start) {
+		end = start.Add(2 * time.Hour)
+	}
+
+	event := ical.Event{
+		UID:          fmt.Sprintf("bimbingan-%d@simnikah", b.ID),
+		Stamp:        b.Updated_at,
+		LastModified: b.Updated_at,
+		Start:        start,
+		End:          end,
+		Summary:      "Bimbingan Perkawinan",
+		Description:  "Pembimbing: " + b.Pembimbing,
+		Location:     b.Tempat_bimbingan,
+		Categories:   []string{"Bimbingan Perkawinan"},
+		Status:       ical.StatusConfirmed,
+	}
+	if b.Status == structs.BimbinganStatusDibatalkan {
+		event.Status = ical.StatusCancelled
+		event.Sequence = 1
+	}
+	return event
+}
+
+// JadwalTime menggabungkan tanggal dan jam HH:MM menjadi waktu di ZonaWaktuJadwal.
+// Jam yang tidak valid dianggap 00:00.
+func JadwalTime(tanggal time.Time, waktu string) time.Time {
+	jam, menit := 0, 0
+	if t, err := time.Parse("15:04", strings.TrimSpace(waktu)); err == nil {
+		jam, menit = t.Hour(), t.Minute()
+	}
+	return time.Date(tanggal.Year(), tanggal.Month(), tanggal.Day(), jam, menit, 0, 0, ZonaWaktuJadwal)
+}
+
+// registrationCancelled mengecek apakah status pendaftaran berarti akad tidak akan berlangsung
+func registrationCancelled(status string) bool {
+	return status == structs.StatusPendaftaranDitolak || status == structs.StatusPendaftaranDibatalkan
+}
+
+// isScheduledStatus mengecek apakah status termasuk ScheduledStatuses (jadwal sudah pasti)
+func isScheduledStatus(status string) bool {
+	for _, s := range ScheduledStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// pasangan menyusun "Suami & Istri", atau nomor pendaftaran jika nama belum tersedia
+func pasangan(suami, istri, nomor string) string {
+	if suami == "" || istri == "" {
+		return nomor
+	}
+	return suami + " & " + istri
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/ical"
+)
+
+func TestJadwalTime(t *testing.T) {
+	tanggal := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	got := JadwalTime(tanggal, "09:30")
+	if want := time.Date(2026, 11, 2, 2, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("JadwalTime() = %v, want %v (09:30 WIB)", got.UTC(), want)
+	}
+	if got := JadwalTime(tanggal, "pagi"); got.Hour() != 0 || got.Day() != 2 {
+		t.Errorf("JadwalTime() dengan jam tidak valid = %v, want 00:00 pada tanggal yang sama", got)
+	}
+}
+
+func TestRegistrationEvent(t *testing.T) {
+	lat, lon := -6.99, 110.42
+	kuaLat, kuaLon := -6.98, 110.41
+	kua := &structs.KUA{Nama: "KUA Semarang Tengah", Alamat: "Jl. Pandanaran 1", Latitude: &kuaLat, Longitude: &kuaLon}
+	base := structs.PendaftaranNikah{
+		ID:                 12,
+		Nomor_pendaftaran:  "NIK/2026/012",
+		Tanggal_nikah:      time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC),
+		Waktu_nikah:        "09:00",
+		Tempat_nikah:       "Di Luar KUA",
+		Alamat_akad:        "Gedung Serbaguna",
+		Latitude:           &lat,
+		Longitude:          &lon,
+		Status_pendaftaran: structs.StatusPendaftaranMenungguBimbingan,
+	}
+
+	event := RegistrationEvent(&base, kua, "Ahmad", "Siti", 2, false)
+	if event.UID != "pendaftaran-12@simnikah" || event.Sequence != 2 || event.Status != ical.StatusConfirmed {
+		t.Errorf("RegistrationEvent() = %+v, want UID stabil, sequence 2, CONFIRMED", event)
+	}
+	if event.Summary != "Akad Nikah Ahmad & Siti" || event.Location != "Gedung Serbaguna" || event.Lat != &lat {
+		t.Errorf("RegistrationEvent() summary/lokasi = %q, %q", event.Summary, event.Location)
+	}
+	if event.End.Sub(event.Start) != DurasiAkadNikah {
+		t.Errorf("durasi event = %v, want %v", event.End.Sub(event.Start), DurasiAkadNikah)
+	}
+
+	diKUA := base
+	diKUA.Tempat_nikah = "Di KUA"
+	diKUA.Status_pendaftaran = structs.StatusPendaftaranMenungguVerifikasi
+	event = RegistrationEvent(&diKUA, kua, "", "", 0, false)
+	if event.Location != "KUA Semarang Tengah, Jl. Pandanaran 1" || event.Lat != &kuaLat || event.Status != ical.StatusTentative {
+		t.Errorf("RegistrationEvent() di KUA = %+v, want lokasi KUA dan TENTATIVE", event)
+	}
+	if event.Summary != "Akad Nikah NIK/2026/012" {
+		t.Errorf("RegistrationEvent() tanpa nama = %q, want nomor pendaftaran", event.Summary)
+	}
+
+	event = RegistrationEvent(&base, kua, "Ahmad", "Siti", 2, true)
+	if event.Status != ical.StatusCancelled || event.Sequence != 3 {
+		t.Errorf("RegistrationEvent() dibatalkan = %s sequence %d, want CANCELLED sequence 3", event.Status, event.Sequence)
+	}
+}
+
+func TestBimbinganEvent(t *testing.T) {
+	b := structs.BimbinganPerkawinan{
+		ID:                4,
+		Tanggal_bimbingan: time.Date(2026, 11, 5, 0, 0, 0, 0, time.UTC),
+		Waktu_mulai:       "08:00",
+		Waktu_selesai:     "12:00",
+		Tempat_bimbingan:  "Aula KUA",
+		Status:            structs.BimbinganStatusAktif,
+	}
+	event := BimbinganEvent(&b)
+	if event.UID != "bimbingan-4@simnikah" || event.End.Sub(event.Start) != 4*time.Hour || event.Status != ical.StatusConfirmed {
+		t.Errorf("BimbinganEvent() = %+v", event)
+	}
+
+	b.Status = structs.BimbinganStatusDibatalkan
+	if event := BimbinganEvent(&b); event.Status != ical.StatusCancelled || event.Sequence != 1 {
+		t.Errorf("BimbinganEvent() dibatalkan = %s sequence %d, want CANCELLED sequence 1", event.Status, event.Sequence)
+	}
+}
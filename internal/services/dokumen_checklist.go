@@ -0,0 +1,447 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== CHECKLIST DOKUMEN (RULE ENGINE) ====================
+// Dokumen yang dibutuhkan sebuah pendaftaran diturunkan dari data yang sudah diisi catin
+// (calon pasangan dan wali nikah) lewat daftar aturan di bawah ini. Satu item checklist
+// terpenuhi jika dokumennya sudah diunggah dan diterima, atau ditandai ada oleh petugas.
+
+// ChecklistBelumDiunggah adalah status item checklist yang belum punya dokumen
+const ChecklistBelumDiunggah = "Belum Diunggah"
+
+// DokumenContext adalah data pendaftaran yang dibaca aturan checklist dokumen.
+// Suami, Istri, Wali, dan AyahIstri bisa nil untuk data lama yang tidak lengkap.
+type DokumenContext struct {
+	Pendaftaran   *structs.PendaftaranNikah
+	Suami         *structs.CalonPasangan
+	Istri         *structs.CalonPasangan
+	Wali          *structs.WaliNikah
+	AyahIstri     *structs.DataOrangTua // ayah kandung calon istri beserta status keberadaannya
+	TanggalDaftar time.Time             // dasar perhitungan umur, sama seperti syarat dispensasi
+}
+
+// DokumenRequirement adalah satu dokumen yang diwajibkan sebuah aturan
+type DokumenRequirement struct {
+	Jenis  string
+	Pihak  string
+	Alasan string // kosong untuk dokumen dasar
+}
+
+// DokumenRule adalah satu aturan yang menurunkan dokumen wajib dari data pendaftaran
+type DokumenRule struct {
+	Name        string
+	Description string
+	Apply       func(ctx *DokumenContext) []DokumenRequirement
+}
+
+// calonDokumen adalah data satu calon beserta pihaknya di checklist
+type calonDokumen struct {
+	pihak string
+	data  *structs.CalonPasangan
+}
+
+func (ctx *DokumenContext) calon() []calonDokumen {
+	return []calonDokumen{
+		{structs.DokumenPihakSuami, ctx.Suami},
+		{structs.DokumenPihakIstri, ctx.Istri},
+	}
+}
+
+// ayahIstriMeninggal bernilai true jika ayah kandung calon istri tercatat meninggal
+func ayahIstriMeninggal(ctx *DokumenContext) bool {
+	if ctx.AyahIstri != nil {
+		return ctx.AyahIstri.Status_keberadaan == structs.StatusKeberadaanMeninggal
+	}
+	return ctx.Wali != nil && ctx.Wali.Hubungan_wali != "" && ctx.Wali.Hubungan_wali != structs.WaliHubunganAyahKandung
+}
+
+// isWNA bernilai true jika calon berkewarganegaraan asing
+func isWNA(cp *structs.CalonPasangan) bool {
+	return cp != nil && strings.EqualFold(strings.TrimSpace(cp.Warga_negara), "WNA")
+}
+
+var (
+	// ruleIdentitas - dokumen dasar setiap calon; KTP, KK, dan N1 hanya untuk WNI
+	ruleIdentitas = DokumenRule{
+		Name:        "identitas",
+		Description: "KTP, KK, akta kelahiran, N1, dan pas foto masing-masing calon (KTP, KK, N1 hanya WNI)",
+		Apply: func(ctx *DokumenContext) []DokumenRequirement {
+			var req []DokumenRequirement
+			for _, cp := range ctx.calon() {
+				if !isWNA(cp.data) {
+					req = append(req,
+						DokumenRequirement{Jenis: structs.DokumenJenisKTP, Pihak: cp.pihak},
+						DokumenRequirement{Jenis: structs.DokumenJenisKK, Pihak: cp.pihak},
+					)
+				}
+				req = append(req, DokumenRequirement{Jenis: structs.DokumenJenisAktaKelahiran, Pihak: cp.pihak})
+				if !isWNA(cp.data) {
+					req = append(req, DokumenRequirement{Jenis: structs.DokumenJenisN1, Pihak: cp.pihak})
+				}
+				req = append(req, DokumenRequirement{Jenis: structs.DokumenJenisPasFoto, Pihak: cp.pihak})
+			}
+			return req
+		},
+	}
+
+	// ruleWargaNegaraAsing - calon WNA menyerahkan paspor dan izin dari kedutaan/perwakilan negaranya
+	ruleWargaNegaraAsing = DokumenRule{
+		Name:        "warga_negara_asing",
+		Description: "Calon WNA: paspor dan surat izin kedutaan/perwakilan negara asal",
+		Apply: func(ctx *DokumenContext) []DokumenRequirement {
+			var req []DokumenRequirement
+			for _, cp := range ctx.calon() {
+				if isWNA(cp.data) {
+					req = append(req,
+						DokumenRequirement{Jenis: structs.DokumenJenisPaspor, Pihak: cp.pihak, Alasan: "Warga negara asing"},
+						DokumenRequirement{Jenis: structs.DokumenJenisIzinKedutaan, Pihak: cp.pihak, Alasan: "Warga negara asing"},
+					)
+				}
+			}
+			return req
+		},
+	}
+
+	// ruleStatusPerkawinan - calon yang pernah menikah membuktikan perceraian atau kematian pasangan terdahulu
+	ruleStatusPerkawinan = DokumenRule{
+		Name:        "status_perkawinan",
+		Description: "Cerai Hidup: akta cerai; Cerai Mati: akta kematian pasangan terdahulu",
+		Apply: func(ctx *DokumenContext) []DokumenRequirement {
+			var req []DokumenRequirement
+			for _, cp := range ctx.calon() {
+				if cp.data == nil {
+					continue
+				}
+				switch cp.data.Status_perkawinan {
+				case structs.StatusPerkawinanCeraiHidup:
+					req = append(req, DokumenRequirement{Jenis: structs.DokumenJenisAktaCerai, Pihak: cp.pihak, Alasan: "Status perkawinan Cerai Hidup"})
+				case structs.StatusPerkawinanCeraiMati:
+					req = append(req, DokumenRequirement{Jenis: structs.DokumenJenisAktaKematian, Pihak: cp.pihak, Alasan: "Status perkawinan Cerai Mati"})
+				}
+			}
+			return req
+		},
+	}
+
+	// ruleUmur - izin orang tua di bawah 21 tahun, dispensasi pengadilan di bawah 19 tahun
+	ruleUmur = DokumenRule{
+		Name:        "umur",
+		Description: "Di bawah 21 tahun: N4 (izin orang tua); di bawah 19 tahun: surat dispensasi pengadilan",
+		Apply: func(ctx *DokumenContext) []DokumenRequirement {
+			var req []DokumenRequirement
+			for _, cp := range ctx.calon() {
+				if cp.data == nil {
+					continue
+				}
+				umur := utils.CalculateAge(cp.data.Tanggal_lahir, ctx.TanggalDaftar)
+				if umur < 21 {
+					req = append(req, DokumenRequirement{Jenis: structs.DokumenJenisN4, Pihak: cp.pihak, Alasan: "Berumur kurang dari 21 tahun"})
+				}
+				if umur < 19 {
+					req = append(req, DokumenRequirement{Jenis: structs.DokumenJenisSuratDispensasi, Pihak: cp.pihak, Alasan: "Berumur kurang dari 19 tahun"})
+				}
+			}
+			return req
+		},
+	}
+
+	// ruleAparat - anggota TNI/Polri wajib izin menikah dari komandan/atasan
+	ruleAparat = DokumenRule{
+		Name:        "aparat",
+		Description: "Anggota TNI/Polri: surat izin komandan/atasan",
+		Apply: func(ctx *DokumenContext) []DokumenRequirement {
+			var req []DokumenRequirement
+			for _, cp := range ctx.calon() {
+				if cp.data != nil && isAparat(cp.data.Pekerjaan) {
+					req = append(req, DokumenRequirement{Jenis: structs.DokumenJenisIzinKomandan, Pihak: cp.pihak, Alasan: "Anggota TNI/Polri"})
+				}
+			}
+			return req
+		},
+	}
+
+	// ruleWali - kematian ayah calon istri dibuktikan dengan akta kematian, dan wali nasab selain
+	// ayah kandung menyerahkan KTP. Status ayah dibaca dari data orang tua; pendaftaran lama hanya
+	// menyimpan ayah yang masih hidup, sehingga tanpa data ayah wali selain ayah kandung berarti
+	// ayah sudah meninggal (lihat IsValidWaliNikah).
+	ruleWali = DokumenRule{
+		Name:        "wali_nikah",
+		Description: "Ayah calon istri meninggal: akta kematian ayah; wali nasab selain ayah kandung: KTP wali",
+		Apply: func(ctx *DokumenContext) []DokumenRequirement {
+			var req []DokumenRequirement
+			if ayahIstriMeninggal(ctx) {
+				req = append(req, DokumenRequirement{Jenis: structs.DokumenJenisAktaKematianAyah, Pihak: structs.DokumenPihakIstri, Alasan: "Ayah kandung calon istri meninggal"})
+			}
+			if ctx.Wali != nil && ctx.Wali.Hubungan_wali != "" && ctx.Wali.Hubungan_wali != structs.WaliHubunganAyahKandung &&
+				ctx.Wali.Hubungan_wali != structs.WaliHubunganWaliHakim {
+				req = append(req, DokumenRequirement{Jenis: structs.DokumenJenisKTPWali, Pihak: structs.DokumenPihakIstri, Alasan: "Wali nikah " + ctx.Wali.Hubungan_wali})
+			}
+			return req
+		},
+	}
+
+	// ruleDokumenBersama - formulir yang ditandatangani kedua calon
+	ruleDokumenBersama = DokumenRule{
+		Name:        "dokumen_bersama",
+		Description: "N2 (permohonan kehendak nikah) dan N3 (persetujuan calon mempelai)",
+		Apply: func(ctx *DokumenContext) []DokumenRequirement {
+			return []DokumenRequirement{
+				{Jenis: structs.DokumenJenisN2, Pihak: structs.DokumenPihakBersama},
+				{Jenis: structs.DokumenJenisN3, Pihak: structs.DokumenPihakBersama},
+			}
+		},
+	}
+)
+
+// DokumenRules adalah aturan checklist dokumen yang berlaku, dievaluasi berurutan
+var DokumenRules = []DokumenRule{
+	ruleIdentitas,
+	ruleWargaNegaraAsing,
+	ruleStatusPerkawinan,
+	ruleUmur,
+	ruleAparat,
+	ruleWali,
+	ruleDokumenBersama,
+}
+
+// EvaluateDokumenRules menjalankan aturan dan menggabungkan dokumen yang sama (jenis dan pihak)
+func EvaluateDokumenRules(ctx *DokumenContext, rules []DokumenRule) []DokumenRequirement {
+	var result []DokumenRequirement
+	index := map[string]int{}
+	for _, rule := range rules {
+		for _, req := range rule.Apply(ctx) {
+			key := req.Jenis + "|" + req.Pihak
+			if i, ok := index[key]; ok {
+				if req.Alasan != "" && !strings.Contains(result[i].Alasan, req.Alasan) {
+					result[i].Alasan = strings.TrimPrefix(result[i].Alasan+"; "+req.Alasan, "; ")
+				}
+				continue
+			}
+			index[key] = len(result)
+			result = append(result, req)
+		}
+	}
+	return result
+}
+
+// ChecklistItem adalah satu dokumen yang dibutuhkan sebuah pendaftaran beserta statusnya
+type ChecklistItem struct {
+	Jenis           string `json:"jenis"`
+	Pihak           string `json:"pihak"`
+	Keterangan      string `json:"keterangan"`
+	Alasan          string `json:"alasan,omitempty"` // kenapa dokumen tambahan diperlukan
+	Status          string `json:"status"`           // Belum Diunggah atau status review dokumen terakhir
+	DokumenID       *uint  `json:"dokumen_id"`
+	DitandaiPetugas bool   `json:"ditandai_petugas"` // berkas fisik ditandai ada oleh petugas
+	Ada             bool   `json:"ada"`              // dokumen diterima atau ditandai ada
+}
+
+// Label mengembalikan nama item untuk pesan, mis. "KTP (Calon Istri)"
+func (item ChecklistItem) Label() string {
+	return item.Jenis + " (" + item.Pihak + ")"
+}
+
+// DokumenChecklist mencocokkan dokumen wajib dengan dokumen yang sudah diunggah dan penanda petugas.
+// Status item adalah review unggahan terakhir; dokumen yang sudah diterima tidak tergeser unggahan yang lebih baru.
+func DokumenChecklist(required []DokumenRequirement, dokumen []structs.DokumenPendaftaran, tanda []structs.KelengkapanBerkas) []ChecklistItem {
+	items := make([]ChecklistItem, 0, len(required))
+	for _, req := range required {
+		item := ChecklistItem{
+			Jenis:      req.Jenis,
+			Pihak:      req.Pihak,
+			Keterangan: DokumenTypes[req.Jenis].Keterangan,
+			Alasan:     req.Alasan,
+			Status:     ChecklistBelumDiunggah,
+		}
+		for j := range dokumen {
+			d := &dokumen[j]
+			if d.Jenis != item.Jenis || d.Pihak != item.Pihak {
+				continue
+			}
+			if item.Status == structs.DokumenStatusDiterima {
+				break
+			}
+			id := d.ID
+			item.Status = d.Status_review
+			item.DokumenID = &id
+		}
+		for _, t := range tanda {
+			if t.Jenis == item.Jenis && t.Pihak == item.Pihak {
+				item.DitandaiPetugas = t.Ada
+			}
+		}
+		item.Ada = item.Status == structs.DokumenStatusDiterima || item.DitandaiPetugas
+		items = append(items, item)
+	}
+	return items
+}
+
+// ChecklistLengkap bernilai true jika semua item checklist sudah ada
+func ChecklistLengkap(items []ChecklistItem) bool {
+	return len(ChecklistKurang(items)) == 0
+}
+
+// ChecklistKurang mengembalikan label item checklist yang belum ada
+func ChecklistKurang(items []ChecklistItem) []string {
+	var kurang []string
+	for _, item := range items {
+		if !item.Ada {
+			kurang = append(kurang, item.Label())
+		}
+	}
+	return kurang
+}
+
+// isAparat mendeteksi pekerjaan anggota TNI/Polri dari isian pekerjaan bebas
+func isAparat(pekerjaan string) bool {
+	upper := strings.ToUpper(pekerjaan)
+	for _, kata := range []string{"TNI", "POLRI", "POLISI", "TENTARA"} {
+		if strings.Contains(upper, kata) {
+			return true
+		}
+	}
+	return false
+}
+
+// ==================== SERVICE ====================
+
+// KelengkapanInput adalah penanda petugas untuk satu item checklist
+type KelengkapanInput struct {
+	Jenis   string `json:"jenis"`
+	Pihak   string `json:"pihak"`
+	Ada     bool   `json:"ada"`
+	Catatan string `json:"catatan"`
+}
+
+// DokumenChecklistService menyusun checklist dokumen pendaftaran dan menyimpan penanda kelengkapan dari petugas
+type DokumenChecklistService struct {
+	DB    *gorm.DB
+	Rules []DokumenRule
+}
+
+// NewDokumenChecklistService membuat instance baru dari DokumenChecklistService dengan DokumenRules
+func NewDokumenChecklistService(db *gorm.DB) *DokumenChecklistService {
+	return &DokumenChecklistService{DB: db, Rules: DokumenRules}
+}
+
+// Context mengumpulkan data calon pasangan dan wali nikah yang dibaca aturan checklist
+func (cs *DokumenChecklistService) Context(p *structs.PendaftaranNikah) (*DokumenContext, error) {
+	ctx := &DokumenContext{Pendaftaran: p, TanggalDaftar: p.Tanggal_pendaftaran}
+	if ctx.TanggalDaftar.IsZero() {
+		ctx.TanggalDaftar = p.Created_at
+	}
+
+	for _, cp := range []struct {
+		id  string
+		dst **structs.CalonPasangan
+	}{{p.Calon_suami_id, &ctx.Suami}, {p.Calon_istri_id, &ctx.Istri}} {
+		id, err := strconv.ParseUint(cp.id, 10, 64)
+		if err != nil {
+			continue
+		}
+		var calon structs.CalonPasangan
+		if err := cs.DB.First(&calon, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		*cp.dst = &calon
+	}
+
+	var wali structs.WaliNikah
+	if err := cs.DB.Where("pendaftaran_id = ?", p.ID).First(&wali).Error; err == nil {
+		ctx.Wali = &wali
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Data orang tua disimpan per pendaftar; data terbaru milik pendaftaran ini
+	var ayah structs.DataOrangTua
+	if err := cs.DB.Where("user_id = ? AND jenis_kelamin_calon = ? AND hubungan = ?", p.Pendaftar_id, "P", structs.HubunganAyah).
+		Order("id DESC").First(&ayah).Error; err == nil {
+		ctx.AyahIstri = &ayah
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// Build menyusun checklist dokumen pendaftaran p
+func (cs *DokumenChecklistService) Build(p *structs.PendaftaranNikah) ([]ChecklistItem, error) {
+	ctx, err := cs.Context(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var dokumen []structs.DokumenPendaftaran
+	if err := cs.DB.Where("pendaftaran_id = ?", p.ID).Order("id ASC").Find(&dokumen).Error; err != nil {
+		return nil, err
+	}
+	var tanda []structs.KelengkapanBerkas
+	if err := cs.DB.Where("pendaftaran_id = ?", p.ID).Find(&tanda).Error; err != nil {
+		return nil, err
+	}
+	return DokumenChecklist(EvaluateDokumenRules(ctx, cs.Rules), dokumen, tanda), nil
+}
+
+// Mark menyimpan penanda ada/tidak ada dari petugas untuk item checklist pendaftaran p.
+// Hanya item yang memang ada di checklist pendaftaran yang bisa ditandai.
+func (cs *DokumenChecklistService) Mark(p *structs.PendaftaranNikah, input []KelengkapanInput, userID string) ([]ChecklistItem, error) {
+	if registrationLocked(p) {
+		return nil, fmt.Errorf("%w: pendaftaran berstatus %s", ErrDokumenLocked, p.Status_pendaftaran)
+	}
+	if len(input) == 0 {
+		return nil, fmt.Errorf("%w: minimal satu item checklist", ErrDokumenInvalid)
+	}
+
+	items, err := cs.Build(p)
+	if err != nil {
+		return nil, err
+	}
+	wajib := map[string]bool{}
+	for _, item := range items {
+		wajib[item.Jenis+"|"+item.Pihak] = true
+	}
+
+	tanda := make([]structs.KelengkapanBerkas, 0, len(input))
+	for _, in := range input {
+		in.Catatan = strings.TrimSpace(in.Catatan)
+		if !wajib[in.Jenis+"|"+in.Pihak] {
+			return nil, fmt.Errorf("%w: %s (%s) tidak ada di checklist pendaftaran ini", ErrDokumenInvalid, in.Jenis, in.Pihak)
+		}
+		if utf8.RuneCountInString(in.Catatan) > 300 {
+			return nil, fmt.Errorf("%w: catatan maksimal 300 karakter", ErrDokumenInvalid)
+		}
+		tanda = append(tanda, structs.KelengkapanBerkas{
+			Pendaftaran_id: p.ID,
+			Jenis:          in.Jenis,
+			Pihak:          in.Pihak,
+			Ada:            in.Ada,
+			Catatan:        in.Catatan,
+			Ditandai_oleh:  userID,
+		})
+	}
+
+	if err := cs.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "pendaftaran_id"}, {Name: "jenis"}, {Name: "pihak"}},
+		DoUpdates: clause.AssignmentColumns([]string{"ada", "catatan", "ditandai_oleh", "updated_at"}),
+	}).Create(&tanda).Error; err != nil {
+		return nil, err
+	}
+	return cs.Build(p)
+}
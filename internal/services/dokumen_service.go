@@ -0,0 +1,324 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/storage"
+
+	"gorm.io/gorm"
+)
+
+// NewDocumentStorage membuat storage berkas persyaratan.
+// Default dari environment (S3 jika STORAGE_DRIVER=s3, selain itu folder lokal STORAGE_LOCAL_DIR);
+// bisa diganti untuk memakai storage lain.
+var NewDocumentStorage = storage.NewFromEnv
+
+// Batas ukuran file unggahan
+const (
+	DokumenMaksUkuran        int64 = 5 << 20 // 5 MB untuk scan dokumen
+	DokumenMaksUkuranPasFoto int64 = 2 << 20 // 2 MB untuk pas foto
+)
+
+var (
+	// ErrDokumenInvalid dikembalikan jika jenis, pihak, tipe file, atau data review tidak valid
+	ErrDokumenInvalid = errors.New("dokumen tidak valid")
+	// ErrDokumenTooLarge dikembalikan jika ukuran file melebihi batas jenis dokumen
+	ErrDokumenTooLarge = errors.New("ukuran file melebihi batas")
+	// ErrDokumenNotFound dikembalikan jika dokumen tidak ada
+	ErrDokumenNotFound = errors.New("dokumen tidak ditemukan")
+	// ErrDokumenLocked dikembalikan jika dokumen sudah diterima atau pendaftaran sudah tidak bisa diubah
+	ErrDokumenLocked = errors.New("dokumen tidak dapat diubah")
+)
+
+// DokumenType adalah aturan satu jenis dokumen
+type DokumenType struct {
+	Keterangan string
+	PerCalon   bool // true: satu dokumen untuk masing-masing calon; false: satu dokumen untuk pasangan
+	TipeMime   []string
+	MaksUkuran int64
+}
+
+var (
+	mimeScan = []string{"application/pdf", "image/jpeg", "image/png"}
+	mimeFoto = []string{"image/jpeg", "image/png"}
+)
+
+// DokumenTypes adalah jenis berkas persyaratan yang bisa diunggah
+var DokumenTypes = map[string]DokumenType{
+	structs.DokumenJenisKTP:              {"Kartu Tanda Penduduk", true, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisKK:               {"Kartu Keluarga", true, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisAktaKelahiran:    {"Akta kelahiran", true, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisN1:               {"Surat pengantar nikah (N1) dari kelurahan/desa", true, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisN2:               {"Permohonan kehendak nikah (N2)", false, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisN3:               {"Persetujuan calon mempelai (N3)", false, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisN4:               {"Izin orang tua (N4)", true, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisPasFoto:          {"Pas foto latar biru", true, mimeFoto, DokumenMaksUkuranPasFoto},
+	structs.DokumenJenisAktaCerai:        {"Akta cerai", true, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisAktaKematian:     {"Akta kematian pasangan terdahulu", true, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisSuratDispensasi:  {"Surat dispensasi nikah dari pengadilan agama", true, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisIzinKomandan:     {"Surat izin komandan/atasan (TNI/Polri)", true, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisPaspor:           {"Paspor", true, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisIzinKedutaan:     {"Surat izin kedutaan/perwakilan negara asal", true, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisAktaKematianAyah: {"Akta kematian ayah kandung calon istri", true, mimeScan, DokumenMaksUkuran},
+	structs.DokumenJenisKTPWali:          {"KTP wali nikah", true, mimeScan, DokumenMaksUkuran},
+}
+
+// mimeExtensions menentukan ekstensi file di storage dari tipe MIME hasil deteksi
+var mimeExtensions = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// ValidateDokumen memeriksa jenis, pihak, tipe MIME, dan ukuran file sebelum disimpan
+func ValidateDokumen(jenis, pihak, tipeMime string, ukuran int64) error {
+	rule, ok := DokumenTypes[jenis]
+	if !ok {
+		return fmt.Errorf("%w: jenis dokumen %q tidak dikenal", ErrDokumenInvalid, jenis)
+	}
+
+	switch pihak {
+	case structs.DokumenPihakSuami, structs.DokumenPihakIstri:
+		if !rule.PerCalon {
+			return fmt.Errorf("%w: %s adalah dokumen bersama, pihak harus %q", ErrDokumenInvalid, jenis, structs.DokumenPihakBersama)
+		}
+	case structs.DokumenPihakBersama:
+		if rule.PerCalon {
+			return fmt.Errorf("%w: pihak %s harus %q atau %q", ErrDokumenInvalid, jenis, structs.DokumenPihakSuami, structs.DokumenPihakIstri)
+		}
+	default:
+		return fmt.Errorf("%w: pihak %q tidak dikenal", ErrDokumenInvalid, pihak)
+	}
+
+	allowed := false
+	for _, m := range rule.TipeMime {
+		if m == tipeMime {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s harus berupa %s, file yang diunggah %s", ErrDokumenInvalid, jenis, describeMime(rule.TipeMime), tipeMime)
+	}
+
+	if ukuran <= 0 {
+		return fmt.Errorf("%w: file kosong", ErrDokumenInvalid)
+	}
+	if ukuran > rule.MaksUkuran {
+		return fmt.Errorf("%w: %s maksimal %d MB", ErrDokumenTooLarge, jenis, rule.MaksUkuran>>20)
+	}
+	return nil
+}
+
+// DetectMime menentukan tipe MIME dari isi file (bukan dari nama file atau header klien)
+func DetectMime(head []byte) string {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+func describeMime(types []string) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = strings.ToUpper(strings.TrimPrefix(mimeExtensions[t], "."))
+	}
+	return strings.Join(names, "/")
+}
+
+// DokumenService untuk unggah dan review berkas persyaratan pendaftaran nikah
+type DokumenService struct {
+	DB      *gorm.DB
+	Storage storage.Storage
+}
+
+// NewDokumenService membuat instance baru dari DokumenService
+func NewDokumenService(db *gorm.DB) *DokumenService {
+	return &DokumenService{DB: db, Storage: NewDocumentStorage()}
+}
+
+// registrationLocked menandai pendaftaran yang berkasnya tidak boleh diubah lagi
+func registrationLocked(p *structs.PendaftaranNikah) bool {
+	switch p.Status_pendaftaran {
+	case structs.StatusPendaftaranSelesai, structs.StatusPendaftaranDitolak, structs.StatusPendaftaranDibatalkan:
+		return true
+	}
+	return false
+}
+
+// Upload memvalidasi dan menyimpan file dokumen untuk pendaftaran p. Tipe file dideteksi dari isinya,
+// checksum SHA-256 dihitung saat file dialirkan ke storage.
+func (ds *DokumenService) Upload(p *structs.PendaftaranNikah, jenis, pihak, namaFile string, r io.Reader, ukuran int64, userID string) (*structs.DokumenPendaftaran, error) {
+	if registrationLocked(p) {
+		return nil, fmt.Errorf("%w: pendaftaran berstatus %s", ErrDokumenLocked, p.Status_pendaftaran)
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("gagal membaca file: %v", err)
+	}
+	head = head[:n]
+	tipeMime := DetectMime(head)
+	if err := ValidateDokumen(jenis, pihak, tipeMime, ukuran); err != nil {
+		return nil, err
+	}
+
+	namaFile = filepath.Base(strings.TrimSpace(namaFile))
+	if namaFile == "." || namaFile == "/" || namaFile == "" {
+		namaFile = strings.ReplaceAll(strings.ToLower(jenis), " ", "-") + mimeExtensions[tipeMime]
+	}
+	if utf8.RuneCountInString(namaFile) > 255 {
+		namaFile = string([]rune(namaFile)[:255])
+	}
+
+	tokenID, err := randomTokenID()
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("pendaftaran/%d/%s-%s%s", p.ID, strings.ReplaceAll(strings.ToLower(jenis), " ", "-"), tokenID[:16], mimeExtensions[tipeMime])
+
+	hasher := sha256.New()
+	body := io.TeeReader(io.MultiReader(bytes.NewReader(head), r), hasher)
+	if err := ds.Storage.Put(key, body, ukuran, tipeMime); err != nil {
+		return nil, err
+	}
+
+	dokumen := structs.DokumenPendaftaran{
+		Kua_id:         p.Kua_id,
+		Pendaftaran_id: p.ID,
+		Jenis:          jenis,
+		Pihak:          pihak,
+		Nama_file:      namaFile,
+		Tipe_mime:      tipeMime,
+		Ukuran:         ukuran,
+		Checksum:       hex.EncodeToString(hasher.Sum(nil)),
+		Storage_key:    key,
+		Status_review:  structs.DokumenStatusMenunggu,
+		Diunggah_oleh:  userID,
+	}
+	if err := ds.DB.Create(&dokumen).Error; err != nil {
+		if delErr := ds.Storage.Delete(key); delErr != nil {
+			log.Printf("Warning: Gagal menghapus file %s setelah gagal menyimpan dokumen: %v", key, delErr)
+		}
+		return nil, err
+	}
+	return &dokumen, nil
+}
+
+// List mengembalikan semua dokumen pendaftaran, terlama lebih dulu
+func (ds *DokumenService) List(pendaftaranID uint) ([]structs.DokumenPendaftaran, error) {
+	var list []structs.DokumenPendaftaran
+	err := ds.DB.Where("pendaftaran_id = ?", pendaftaranID).Order("id ASC").Find(&list).Error
+	return list, err
+}
+
+// Load mengambil dokumen beserta pendaftarannya jika actor boleh mengakses pendaftaran tersebut
+func (ds *DokumenService) Load(actor TransitionActor, idStr string, mode AccessMode) (*structs.DokumenPendaftaran, *structs.PendaftaranNikah, error) {
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, nil, ErrDokumenNotFound
+	}
+	var dokumen structs.DokumenPendaftaran
+	if err := ds.DB.First(&dokumen, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrDokumenNotFound
+		}
+		return nil, nil, err
+	}
+
+	pendaftaran, err := NewAccessPolicy(ds.DB).LoadRegistration(actor, strconv.FormatUint(uint64(dokumen.Pendaftaran_id), 10), mode)
+	if err != nil {
+		if errors.Is(err, ErrResourceNotFound) {
+			return nil, nil, ErrDokumenNotFound
+		}
+		return nil, nil, err
+	}
+	return &dokumen, pendaftaran, nil
+}
+
+// Open membuka isi file dokumen dari storage; pemanggil wajib menutupnya
+func (ds *DokumenService) Open(dokumen *structs.DokumenPendaftaran) (io.ReadCloser, error) {
+	rc, err := ds.Storage.Get(dokumen.Storage_key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: file sudah tidak ada di storage", ErrDokumenNotFound)
+	}
+	return rc, err
+}
+
+// Delete menghapus dokumen yang belum diterima beserta filenya
+func (ds *DokumenService) Delete(dokumen *structs.DokumenPendaftaran, p *structs.PendaftaranNikah) error {
+	if dokumen.Status_review == structs.DokumenStatusDiterima {
+		return fmt.Errorf("%w: dokumen sudah diterima petugas", ErrDokumenLocked)
+	}
+	if registrationLocked(p) {
+		return fmt.Errorf("%w: pendaftaran berstatus %s", ErrDokumenLocked, p.Status_pendaftaran)
+	}
+
+	if err := ds.DB.Delete(dokumen).Error; err != nil {
+		return err
+	}
+	if err := ds.Storage.Delete(dokumen.Storage_key); err != nil {
+		log.Printf("Warning: Gagal menghapus file dokumen #%d (%s): %v", dokumen.ID, dokumen.Storage_key, err)
+	}
+	return nil
+}
+
+// Review menerima atau menolak dokumen; catatan wajib jika ditolak. Catin diberi tahu jika dokumen ditolak.
+func (ds *DokumenService) Review(dokumen *structs.DokumenPendaftaran, p *structs.PendaftaranNikah, status, catatan, reviewerID string) error {
+	catatan = strings.TrimSpace(catatan)
+	if status != structs.DokumenStatusDiterima && status != structs.DokumenStatusDitolak {
+		return fmt.Errorf("%w: status review harus %q atau %q", ErrDokumenInvalid, structs.DokumenStatusDiterima, structs.DokumenStatusDitolak)
+	}
+	if status == structs.DokumenStatusDitolak && catatan == "" {
+		return fmt.Errorf("%w: catatan wajib diisi jika dokumen ditolak", ErrDokumenInvalid)
+	}
+	if utf8.RuneCountInString(catatan) > 300 {
+		return fmt.Errorf("%w: catatan maksimal 300 karakter", ErrDokumenInvalid)
+	}
+	if registrationLocked(p) {
+		return fmt.Errorf("%w: pendaftaran berstatus %s", ErrDokumenLocked, p.Status_pendaftaran)
+	}
+
+	now := time.Now()
+	dokumen.Status_review = status
+	dokumen.Catatan_review = catatan
+	dokumen.Direview_oleh = reviewerID
+	dokumen.Direview_pada = &now
+	if err := ds.DB.Model(dokumen).Updates(map[string]interface{}{
+		"status_review":  dokumen.Status_review,
+		"catatan_review": dokumen.Catatan_review,
+		"direview_oleh":  dokumen.Direview_oleh,
+		"direview_pada":  dokumen.Direview_pada,
+	}).Error; err != nil {
+		return err
+	}
+
+	if status == structs.DokumenStatusDitolak {
+		if err := NewNotificationService(ds.DB).SendSystemNotification(
+			p.Pendaftar_id,
+			"Dokumen Ditolak",
+			fmt.Sprintf("Dokumen %s (%s) untuk pendaftaran %s ditolak: %s. Silakan unggah ulang.", dokumen.Jenis, dokumen.Pihak, p.Nomor_pendaftaran, catatan),
+			structs.NotifikasiTipeWarning,
+			fmt.Sprintf("/pendaftaran/%d/dokumen", p.ID),
+		); err != nil {
+			log.Printf("Warning: Gagal mengirim notifikasi dokumen ditolak: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+)
+
+func TestValidateDokumen(t *testing.T) {
+	tests := []struct {
+		name         string
+		jenis, pihak string
+		tipeMime     string
+		ukuran       int64
+		wantErr      error
+	}{
+		{"KTP pdf", structs.DokumenJenisKTP, structs.DokumenPihakSuami, "application/pdf", 1 << 20, nil},
+		{"pas foto png", structs.DokumenJenisPasFoto, structs.DokumenPihakIstri, "image/png", 1 << 20, nil},
+		{"N2 bersama", structs.DokumenJenisN2, structs.DokumenPihakBersama, "image/jpeg", 1 << 20, nil},
+		{"jenis tidak dikenal", "SIM", structs.DokumenPihakSuami, "application/pdf", 1 << 20, ErrDokumenInvalid},
+		{"N2 per calon", structs.DokumenJenisN2, structs.DokumenPihakSuami, "application/pdf", 1 << 20, ErrDokumenInvalid},
+		{"KTP bersama", structs.DokumenJenisKTP, structs.DokumenPihakBersama, "application/pdf", 1 << 20, ErrDokumenInvalid},
+		{"pihak tidak dikenal", structs.DokumenJenisKTP, "Wali", "application/pdf", 1 << 20, ErrDokumenInvalid},
+		{"pas foto pdf", structs.DokumenJenisPasFoto, structs.DokumenPihakSuami, "application/pdf", 1 << 20, ErrDokumenInvalid},
+		{"file exe", structs.DokumenJenisKK, structs.DokumenPihakSuami, "application/octet-stream", 1 << 20, ErrDokumenInvalid},
+		{"file kosong", structs.DokumenJenisKK, structs.DokumenPihakSuami, "application/pdf", 0, ErrDokumenInvalid},
+		{"KK 5 MB", structs.DokumenJenisKK, structs.DokumenPihakSuami, "application/pdf", DokumenMaksUkuran, nil},
+		{"KK lebih dari 5 MB", structs.DokumenJenisKK, structs.DokumenPihakSuami, "application/pdf", DokumenMaksUkuran + 1, ErrDokumenTooLarge},
+		{"pas foto lebih dari 2 MB", structs.DokumenJenisPasFoto, structs.DokumenPihakSuami, "image/jpeg", DokumenMaksUkuranPasFoto + 1, ErrDokumenTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDokumen(tt.jenis, tt.pihak, tt.tipeMime, tt.ukuran)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("ValidateDokumen() error = %v, want nil", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateDokumen() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDetectMime(t *testing.T) {
+	tests := map[string]string{
+		"%PDF-1.7\n%âãÏÓ":                     "application/pdf",
+		"\xff\xd8\xff\xe0\x00\x10JFIF\x00":    "image/jpeg",
+		"\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR": "image/png",
+		"MZ\x90\x00\x03\x00\x00\x00":          "application/octet-stream",
+		"<html><body>ktp</body></html>":       "text/html",
+	}
+	for head, want := range tests {
+		if got := DetectMime([]byte(head)); got != want {
+			t.Errorf("DetectMime(%q) = %q, want %q", head, got, want)
+		}
+	}
+}
+
+func TestEvaluateDokumenRules(t *testing.T) {
+	daftar := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	ctx := &DokumenContext{
+		Pendaftaran: &structs.PendaftaranNikah{ID: 7, Tanggal_pendaftaran: daftar},
+		Suami: &structs.CalonPasangan{
+			Tanggal_lahir:     daftar.AddDate(-30, 0, 0),
+			Status_perkawinan: structs.StatusPerkawinanCeraiHidup,
+			Pekerjaan:         "TNI/Polri",
+			Warga_negara:      "WNI",
+		},
+		Istri: &structs.CalonPasangan{
+			Tanggal_lahir:     daftar.AddDate(-18, 0, 0),
+			Status_perkawinan: structs.StatusPerkawinanCeraiMati,
+			Pekerjaan:         "Mahasiswa",
+			Warga_negara:      "WNA",
+		},
+		Wali:          &structs.WaliNikah{Hubungan_wali: structs.WaliHubunganKakek},
+		TanggalDaftar: daftar,
+	}
+	required := EvaluateDokumenRules(ctx, DokumenRules)
+
+	find := func(req []DokumenRequirement, jenis, pihak string) *DokumenRequirement {
+		for i := range req {
+			if req[i].Jenis == jenis && req[i].Pihak == pihak {
+				return &req[i]
+			}
+		}
+		return nil
+	}
+
+	wajib := []struct{ jenis, pihak string }{
+		{structs.DokumenJenisKTP, structs.DokumenPihakSuami},
+		{structs.DokumenJenisAktaCerai, structs.DokumenPihakSuami},
+		{structs.DokumenJenisIzinKomandan, structs.DokumenPihakSuami},
+		{structs.DokumenJenisAktaKematian, structs.DokumenPihakIstri},
+		{structs.DokumenJenisPaspor, structs.DokumenPihakIstri},
+		{structs.DokumenJenisIzinKedutaan, structs.DokumenPihakIstri},
+		{structs.DokumenJenisN4, structs.DokumenPihakIstri},
+		{structs.DokumenJenisSuratDispensasi, structs.DokumenPihakIstri},
+		{structs.DokumenJenisAktaKematianAyah, structs.DokumenPihakIstri},
+		{structs.DokumenJenisKTPWali, structs.DokumenPihakIstri},
+		{structs.DokumenJenisN2, structs.DokumenPihakBersama},
+		{structs.DokumenJenisN3, structs.DokumenPihakBersama},
+	}
+	for _, w := range wajib {
+		if find(required, w.jenis, w.pihak) == nil {
+			t.Errorf("checklist tidak memuat %s (%s)", w.jenis, w.pihak)
+		}
+	}
+
+	tidakWajib := []struct{ jenis, pihak string }{
+		{structs.DokumenJenisKTP, structs.DokumenPihakIstri},
+		{structs.DokumenJenisKK, structs.DokumenPihakIstri},
+		{structs.DokumenJenisN1, structs.DokumenPihakIstri},
+		{structs.DokumenJenisPaspor, structs.DokumenPihakSuami},
+		{structs.DokumenJenisN4, structs.DokumenPihakSuami},
+		{structs.DokumenJenisAktaKematian, structs.DokumenPihakSuami},
+		{structs.DokumenJenisAktaCerai, structs.DokumenPihakIstri},
+		{structs.DokumenJenisIzinKomandan, structs.DokumenPihakIstri},
+	}
+	for _, w := range tidakWajib {
+		if find(required, w.jenis, w.pihak) != nil {
+			t.Errorf("checklist seharusnya tidak memuat %s (%s)", w.jenis, w.pihak)
+		}
+	}
+
+	// Wali hakim tidak menyerahkan KTP wali, akta kematian ayah tetap diperlukan
+	ctx.Wali = &structs.WaliNikah{Hubungan_wali: structs.WaliHubunganWaliHakim}
+	hakim := EvaluateDokumenRules(ctx, DokumenRules)
+	if find(hakim, structs.DokumenJenisKTPWali, structs.DokumenPihakIstri) != nil {
+		t.Errorf("wali hakim seharusnya tidak mewajibkan KTP wali")
+	}
+	if find(hakim, structs.DokumenJenisAktaKematianAyah, structs.DokumenPihakIstri) == nil {
+		t.Errorf("wali hakim seharusnya mewajibkan akta kematian ayah")
+	}
+
+	// Status ayah dari data orang tua menentukan akta kematian ayah, bukan hubungan wali
+	ctx.AyahIstri = &structs.DataOrangTua{Hubungan: structs.HubunganAyah, Status_keberadaan: "Tidak Diketahui"}
+	if find(EvaluateDokumenRules(ctx, DokumenRules), structs.DokumenJenisAktaKematianAyah, structs.DokumenPihakIstri) != nil {
+		t.Errorf("ayah tidak diketahui seharusnya tidak mewajibkan akta kematian ayah")
+	}
+	ctx.AyahIstri.Status_keberadaan = structs.StatusKeberadaanMeninggal
+	ctx.Wali = &structs.WaliNikah{Hubungan_wali: structs.WaliHubunganSaudaraLakiLakiKandung}
+	saudara := EvaluateDokumenRules(ctx, DokumenRules)
+	if find(saudara, structs.DokumenJenisAktaKematianAyah, structs.DokumenPihakIstri) == nil || find(saudara, structs.DokumenJenisKTPWali, structs.DokumenPihakIstri) == nil {
+		t.Errorf("ayah meninggal dengan wali saudara seharusnya mewajibkan akta kematian ayah dan KTP wali")
+	}
+
+	// Data lama tanpa calon dan wali tetap mendapat dokumen dasar WNI
+	if dasar := EvaluateDokumenRules(&DokumenContext{TanggalDaftar: daftar}, DokumenRules); len(dasar) != 12 {
+		t.Errorf("checklist tanpa data calon = %d item, want 12", len(dasar))
+	}
+}
+
+func TestEvaluateDokumenRulesDedupe(t *testing.T) {
+	rule := func(alasan string) DokumenRule {
+		return DokumenRule{Apply: func(*DokumenContext) []DokumenRequirement {
+			return []DokumenRequirement{{Jenis: structs.DokumenJenisN4, Pihak: structs.DokumenPihakIstri, Alasan: alasan}}
+		}}
+	}
+	got := EvaluateDokumenRules(&DokumenContext{}, []DokumenRule{rule(""), rule("A"), rule("B"), rule("A")})
+	if len(got) != 1 || got[0].Alasan != "A; B" {
+		t.Errorf("EvaluateDokumenRules() = %+v, want satu item dengan alasan \"A; B\"", got)
+	}
+}
+
+func TestDokumenChecklist(t *testing.T) {
+	required := []DokumenRequirement{
+		{Jenis: structs.DokumenJenisKTP, Pihak: structs.DokumenPihakSuami},
+		{Jenis: structs.DokumenJenisKTP, Pihak: structs.DokumenPihakIstri},
+		{Jenis: structs.DokumenJenisKK, Pihak: structs.DokumenPihakIstri},
+		{Jenis: structs.DokumenJenisN2, Pihak: structs.DokumenPihakBersama},
+	}
+	dokumen := []structs.DokumenPendaftaran{
+		{ID: 1, Jenis: structs.DokumenJenisKTP, Pihak: structs.DokumenPihakSuami, Status_review: structs.DokumenStatusDiterima},
+		{ID: 2, Jenis: structs.DokumenJenisKTP, Pihak: structs.DokumenPihakSuami, Status_review: structs.DokumenStatusMenunggu},
+		{ID: 3, Jenis: structs.DokumenJenisKTP, Pihak: structs.DokumenPihakIstri, Status_review: structs.DokumenStatusDitolak},
+		{ID: 4, Jenis: structs.DokumenJenisKTP, Pihak: structs.DokumenPihakIstri, Status_review: structs.DokumenStatusMenunggu},
+	}
+	tanda := []structs.KelengkapanBerkas{
+		{Jenis: structs.DokumenJenisN2, Pihak: structs.DokumenPihakBersama, Ada: true},
+	}
+	items := DokumenChecklist(required, dokumen, tanda)
+
+	if ktp := items[0]; ktp.Status != structs.DokumenStatusDiterima || *ktp.DokumenID != 1 || !ktp.Ada {
+		t.Errorf("KTP suami = %s #%d, want dokumen diterima #1 tetap berlaku", ktp.Status, *ktp.DokumenID)
+	}
+	if ktp := items[1]; ktp.Status != structs.DokumenStatusMenunggu || *ktp.DokumenID != 4 || ktp.Ada {
+		t.Errorf("KTP istri = %s #%d, want unggahan ulang #4 menunggu review", ktp.Status, *ktp.DokumenID)
+	}
+	if kk := items[2]; kk.Status != ChecklistBelumDiunggah || kk.DokumenID != nil || kk.Ada {
+		t.Errorf("KK istri = %s, want %s", kk.Status, ChecklistBelumDiunggah)
+	}
+	if n2 := items[3]; !n2.DitandaiPetugas || !n2.Ada {
+		t.Errorf("N2 ditandai petugas = %v, ada = %v; want keduanya true", n2.DitandaiPetugas, n2.Ada)
+	}
+
+	kurang := ChecklistKurang(items)
+	want := []string{"KTP (Calon Istri)", structs.DokumenJenisKK + " (Calon Istri)"}
+	if len(kurang) != len(want) || kurang[0] != want[0] || kurang[1] != want[1] {
+		t.Errorf("ChecklistKurang() = %v, want %v", kurang, want)
+	}
+	if ChecklistLengkap(items) {
+		t.Errorf("ChecklistLengkap() = true untuk checklist yang belum lengkap")
+	}
+}
+
+func TestDokumenChecklistServiceContextAyahIstri(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	p := createTestPendaftaran(t, db, structs.PendaftaranNikah{Kua_id: kua.ID})
+	db.Create(&structs.WaliNikah{Pendaftaran_id: p.ID, NIK: "6371010101700001", Nama_lengkap: "KH. Hasan", Hubungan_wali: structs.WaliHubunganWaliHakim, Alamat: "Banjarmasin", Agama: "Islam"})
+	cs := NewDokumenChecklistService(db)
+
+	// Data lama tanpa ayah calon istri: wali selain ayah kandung berarti ayah meninggal
+	ctx, err := cs.Context(&p)
+	if err != nil {
+		t.Fatalf("Context() error = %v", err)
+	}
+	if ctx.AyahIstri != nil || !ayahIstriMeninggal(ctx) {
+		t.Errorf("Context() tanpa data ayah: AyahIstri = %+v, meninggal = %v, want nil dan true", ctx.AyahIstri, ayahIstriMeninggal(ctx))
+	}
+
+	// Data ayah terbaru yang dipakai; ayah tidak diketahui tidak butuh akta kematian
+	db.Create(&structs.DataOrangTua{User_id: p.Pendaftar_id, Jenis_kelamin_calon: "P", Hubungan: structs.HubunganAyah, Nama_lengkap: "Rahman", Status_keberadaan: structs.StatusKeberadaanMeninggal, Jenis_kelamin: "L"})
+	db.Create(&structs.DataOrangTua{User_id: p.Pendaftar_id, Jenis_kelamin_calon: "P", Hubungan: structs.HubunganAyah, Status_keberadaan: "Tidak Diketahui", Jenis_kelamin: "L"})
+	items, err := cs.Build(&p)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	for _, item := range items {
+		if item.Jenis == structs.DokumenJenisAktaKematianAyah || item.Jenis == structs.DokumenJenisKTPWali {
+			t.Errorf("checklist memuat %s, want tidak ada untuk ayah tidak diketahui dan wali hakim", item.Label())
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/locale"
+	"simnikah/pkg/pdf"
+	"simnikah/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+// ==================== FORMULIR NIKAH (N1-N4, NB) ====================
+// Formulir model Kemenag diisi dari data pendaftaran (calon pasangan, orang tua, wali nikah) dan
+// dicetak sebagai PDF. Tata letak setiap formulir ada di templates/formulir/*.tmpl: satu baris satu
+// blok, "perintah|argumen|...", setelah template dieksekusi dengan FormulirData.
+//
+//	kop|baris 1|baris 2|...  kop surat rata tengah diakhiri garis
+//	model|Model N1           kode formulir di pojok kanan atas
+//	judul|teks               judul tebal rata tengah
+//	subjudul|teks            teks rata tengah
+//	teks|teks                paragraf
+//	bagian|teks              sub judul tebal
+//	isian|label|nilai        baris isian "label : nilai" (menjorok)
+//	kanan|teks               teks rata kanan
+//	jarak|mm                 jarak kosong
+//	ttd|baris;baris|nama|... kolom tanda tangan, pasangan keterangan dan nama
+//
+// Nilai dari data sudah dibersihkan dari "|", ";" dan baris baru (lihat formulirText),
+// sehingga isian pengguna tidak bisa mengubah tata letak.
+
+//go:embed templates/formulir/*.tmpl
+var formulirTemplateFS embed.FS
+
+var formulirTemplates = template.Must(template.New("formulir").Funcs(template.FuncMap{
+	// isi mencetak "-" untuk nilai kosong
+	"isi": func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	},
+	// ttl menggabungkan tempat dan tanggal lahir, mis. "Bandung, 2 Januari 2000"
+	"ttl": func(tempat, tanggal string) string {
+		switch {
+		case tempat == "" && tanggal == "":
+			return "-"
+		case tempat == "" || tanggal == "":
+			return tempat + tanggal
+		}
+		return tempat + ", " + tanggal
+	},
+	"upper": strings.ToUpper,
+}).ParseFS(formulirTemplateFS, "templates/formulir/*.tmpl"))
+
+var (
+	// ErrFormulirInvalid dikembalikan jika model formulir atau pihak tidak dikenal
+	ErrFormulirInvalid = errors.New("formulir tidak valid")
+	// ErrFormulirIncomplete dikembalikan jika data calon pasangan yang dibutuhkan formulir belum ada
+	ErrFormulirIncomplete = errors.New("data pendaftaran belum lengkap untuk formulir ini")
+)
+
+// FormulirModel adalah satu jenis formulir yang bisa dicetak
+type FormulirModel struct {
+	Kode     string // mis. "N1"
+	Judul    string
+	PerCalon bool // true: dicetak untuk calon suami atau calon istri (parameter pihak)
+	Template string
+}
+
+// FormulirModels adalah formulir yang tersedia, dengan kunci slug di URL
+var FormulirModels = map[string]FormulirModel{
+	"n1": {"N1", "Surat Pengantar Perkawinan", true, "n1.tmpl"},
+	"n2": {"N2", "Permohonan Kehendak Perkawinan", false, "n2.tmpl"},
+	"n3": {"N3", "Persetujuan Calon Pengantin", false, "n3.tmpl"},
+	"n4": {"N4", "Izin Orang Tua", true, "n4.tmpl"},
+	"nb": {"NB", "Lembar Pemeriksaan Nikah", false, "nb.tmpl"},
+}
+
+// Pihak formulir per calon (parameter pihak di URL)
+const (
+	FormulirPihakSuami = "suami"
+	FormulirPihakIstri = "istri"
+)
+
+// FormulirOrang adalah data satu orang yang siap dicetak; string kosong dicetak "-" oleh template
+type FormulirOrang struct {
+	Ada              bool
+	Nama             string
+	BinBinti         string // "bin Fulan" / "binti Fulan" jika nama ayah diketahui
+	NIK              string
+	JenisKelamin     string
+	TempatLahir      string
+	TanggalLahir     string
+	Umur             string
+	WargaNegara      string
+	Agama            string
+	Pekerjaan        string
+	Pendidikan       string
+	StatusPerkawinan string
+	Alamat           string
+	Kelurahan        string
+	Kecamatan        string
+	Kabupaten        string
+	NoHP             string
+}
+
+// FormulirWali adalah data wali nikah yang siap dicetak
+type FormulirWali struct {
+	Ada      bool
+	Nama     string
+	NIK      string
+	Hubungan string
+	Agama    string
+	Alamat   string
+}
+
+// FormulirData adalah isi formulir; semua nilai sudah diformat sebagai teks
+type FormulirData struct {
+	Kode             string
+	NomorPendaftaran string
+	TanggalCetak     string // mis. "18 Oktober 2026"
+	KUA              struct{ Nama, Alamat, Kecamatan, Kabupaten, Provinsi string }
+	Akad             struct{ Hari, Tanggal, TanggalHijriah, Waktu, Tempat, Alamat string }
+	NomorDispensasi  string
+	Penghulu         string
+
+	Suami, Istri                             FormulirOrang
+	AyahSuami, IbuSuami, AyahIstri, IbuIstri FormulirOrang
+	Wali                                     FormulirWali
+	Pihak                                    string // "Calon Suami"/"Calon Istri" untuk formulir per calon
+	Calon, AyahCalon, IbuCalon, Pasangan     FormulirOrang
+	AnakLabel                                string // "anak laki-laki"/"anak perempuan" (N4)
+	PasanganLabel                            string // "calon istri"/"calon suami"
+}
+
+// formulirText membersihkan nilai agar aman dipakai di baris template formulir
+func formulirText(s string) string {
+	return strings.Join(strings.Fields(strings.NewReplacer("|", "/", ";", ",").Replace(s)), " ")
+}
+
+// formulirCalon menyusun data calon pasangan; tanggal umur dihitung terhadap tanggal pendaftaran
+func formulirCalon(cp *structs.CalonPasangan, ayah *structs.DataOrangTua, tanggalDaftar time.Time) FormulirOrang {
+	if cp == nil {
+		return FormulirOrang{}
+	}
+	o := FormulirOrang{
+		Ada:              true,
+		Nama:             formulirText(cp.Nama_lengkap),
+		NIK:              formulirText(cp.NIK),
+		TempatLahir:      formulirText(cp.Tempat_lahir),
+		TanggalLahir:     locale.Tanggal(cp.Tanggal_lahir),
+		WargaNegara:      formulirText(cp.Warga_negara),
+		Agama:            formulirText(cp.Agama),
+		Pekerjaan:        formulirText(cp.Pekerjaan),
+		Pendidikan:       formulirText(cp.Pendidikan_terakhir),
+		StatusPerkawinan: formulirText(cp.Status_perkawinan),
+		Alamat:           formulirAlamat(cp),
+		Kelurahan:        formulirText(cp.Kelurahan),
+		Kecamatan:        formulirText(cp.Kecamatan),
+		Kabupaten:        formulirText(cp.Kabupaten),
+		NoHP:             formulirText(cp.No_hp),
+	}
+	if !cp.Tanggal_lahir.IsZero() {
+		o.Umur = strconv.Itoa(utils.CalculateAge(cp.Tanggal_lahir, tanggalDaftar)) + " tahun"
+	}
+	switch cp.Jenis_kelamin {
+	case "L":
+		o.JenisKelamin = "Laki-laki"
+	case "P":
+		o.JenisKelamin = "Perempuan"
+	}
+	if ayah != nil && ayah.Nama_lengkap != "" {
+		if cp.Jenis_kelamin == "P" {
+			o.BinBinti = "binti " + formulirText(ayah.Nama_lengkap)
+		} else {
+			o.BinBinti = "bin " + formulirText(ayah.Nama_lengkap)
+		}
+	}
+	return o
+}
+
+// formulirAlamat menggabungkan alamat lengkap calon pasangan
+func formulirAlamat(cp *structs.CalonPasangan) string {
+	var parts []string
+	if strings.TrimSpace(cp.Alamat) != "" {
+		parts = append(parts, cp.Alamat)
+	}
+	if cp.RT != "" || cp.RW != "" {
+		parts = append(parts, "RT "+cp.RT+"/RW "+cp.RW)
+	}
+	for _, p := range []string{cp.Kelurahan, cp.Kecamatan, cp.Kabupaten, cp.Provinsi} {
+		if strings.TrimSpace(p) != "" {
+			parts = append(parts, p)
+		}
+	}
+	return formulirText(strings.Join(parts, ", "))
+}
+
+// formulirOrangTua menyusun data ayah/ibu; orang tua yang tidak tercatat (meninggal) dicetak "-"
+func formulirOrangTua(ot *structs.DataOrangTua) FormulirOrang {
+	if ot == nil {
+		return FormulirOrang{}
+	}
+	o := FormulirOrang{
+		Ada:         true,
+		Nama:        formulirText(ot.Nama_lengkap),
+		NIK:         formulirText(ot.NIK),
+		TempatLahir: formulirText(ot.Tempat_lahir),
+		WargaNegara: formulirText(ot.Warga_negara),
+		Agama:       formulirText(ot.Agama),
+		Pekerjaan:   formulirText(ot.Pekerjaan),
+		Alamat:      formulirText(ot.Alamat),
+	}
+	if ot.Tanggal_lahir != nil {
+		o.TanggalLahir = locale.Tanggal(*ot.Tanggal_lahir)
+	}
+	return o
+}
+
+// ForPihak mengisi data formulir per calon (N1, N4) untuk pihak "suami" atau "istri"
+func (d *FormulirData) ForPihak(pihak string) error {
+	switch pihak {
+	case FormulirPihakSuami:
+		d.Pihak, d.Calon, d.AyahCalon, d.IbuCalon, d.Pasangan = structs.DokumenPihakSuami, d.Suami, d.AyahSuami, d.IbuSuami, d.Istri
+		d.AnakLabel, d.PasanganLabel = "anak laki-laki", "calon istri"
+	case FormulirPihakIstri:
+		d.Pihak, d.Calon, d.AyahCalon, d.IbuCalon, d.Pasangan = structs.DokumenPihakIstri, d.Istri, d.AyahIstri, d.IbuIstri, d.Suami
+		d.AnakLabel, d.PasanganLabel = "anak perempuan", "calon suami"
+	default:
+		return fmt.Errorf("%w: pihak harus %q atau %q", ErrFormulirInvalid, FormulirPihakSuami, FormulirPihakIstri)
+	}
+	if !d.Calon.Ada {
+		return fmt.Errorf("%w: data %s belum ada", ErrFormulirIncomplete, strings.ToLower(d.Pihak))
+	}
+	return nil
+}
+
+// RenderFormulir mengeksekusi template formulir dan menghasilkan PDF. Hasilnya hanya bergantung pada data.
+func RenderFormulir(model FormulirModel, data *FormulirData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := formulirTemplates.ExecuteTemplate(&buf, model.Template, data); err != nil {
+		return nil, err
+	}
+
+	doc := pdf.New()
+	doc.Title = "Model " + model.Kode + " - " + model.Judul
+	doc.Subject = data.NomorPendaftaran
+	doc.Author = data.KUA.Nama
+	l := pdf.NewLayout(doc)
+
+	for n, raw := range strings.Split(buf.String(), "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args := strings.Split(line, "|")
+		cmd, args := args[0], args[1:]
+		if err := renderFormulirLine(l, cmd, args); err != nil {
+			return nil, fmt.Errorf("%s baris %d: %w", model.Template, n+1, err)
+		}
+	}
+	return doc.Bytes(), nil
+}
+
+// Ukuran huruf formulir (pt) dan lebar kolom label isian (mm)
+const (
+	formulirFontSize   = 10.5
+	formulirLabelWidth = 52
+)
+
+func renderFormulirLine(l *pdf.Layout, cmd string, args []string) error {
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+	switch cmd {
+	case "kop":
+		for i, a := range args {
+			size := 11.0
+			if i == 0 {
+				size = 12.5
+			}
+			l.Paragraph(pdf.HelveticaBold, size, a, pdf.AlignCenter)
+		}
+		l.Rule(0.6)
+		l.Space(2)
+	case "model":
+		l.Page.TextAlign(l.Left, l.Top-6, l.Width(), pdf.HelveticaBold, 9, arg(0), pdf.AlignRight)
+	case "judul":
+		l.Paragraph(pdf.HelveticaBold, 12, arg(0), pdf.AlignCenter)
+	case "subjudul":
+		l.Paragraph(pdf.Helvetica, formulirFontSize, arg(0), pdf.AlignCenter)
+	case "teks":
+		l.Paragraph(pdf.Helvetica, formulirFontSize, arg(0), pdf.AlignLeft)
+	case "bagian":
+		l.Space(1.5)
+		l.Paragraph(pdf.HelveticaBold, formulirFontSize, arg(0), pdf.AlignLeft)
+	case "isian":
+		l.Field(arg(0), arg(1), 6, formulirLabelWidth, formulirFontSize)
+	case "kanan":
+		l.Paragraph(pdf.Helvetica, formulirFontSize, arg(0), pdf.AlignRight)
+	case "jarak":
+		mm, err := strconv.ParseFloat(arg(0), 64)
+		if err != nil {
+			return fmt.Errorf("jarak %q bukan angka", arg(0))
+		}
+		l.Space(mm)
+	case "ttd":
+		if len(args)%2 != 0 {
+			return errors.New("ttd membutuhkan pasangan keterangan dan nama")
+		}
+		var cols []pdf.Signature
+		for i := 0; i < len(args); i += 2 {
+			cols = append(cols, pdf.Signature{Lines: strings.Split(args[i], ";"), Name: args[i+1]})
+		}
+		l.Space(4)
+		l.Signatures(cols, formulirFontSize)
+	default:
+		return fmt.Errorf("perintah %q tidak dikenal", cmd)
+	}
+	return nil
+}
+
+// FormulirService mengumpulkan data pendaftaran dan mencetak formulir nikah
+type FormulirService struct {
+	DB  *gorm.DB
+	Now func() time.Time
+}
+
+// NewFormulirService membuat instance baru dari FormulirService
+func NewFormulirService(db *gorm.DB) *FormulirService {
+	return &FormulirService{DB: db, Now: time.Now}
+}
+
+// Data mengumpulkan isi formulir untuk pendaftaran p
+func (fs *FormulirService) Data(p *structs.PendaftaranNikah) (*FormulirData, error) {
+	ctx, err := NewDokumenChecklistService(fs.DB).Context(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var kua structs.KUA
+	if err := fs.DB.First(&kua, p.Kua_id).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	// Jam akad disimpan dalam waktu lokal KUA
+	zona := locale.ZonaProvinsi(kua.Provinsi)
+
+	d := &FormulirData{
+		NomorPendaftaran: formulirText(p.Nomor_pendaftaran),
+		TanggalCetak:     locale.Tanggal(fs.Now().In(zona)),
+		NomorDispensasi:  formulirText(p.Nomor_dispensasi),
+	}
+	if !p.Tanggal_nikah.IsZero() {
+		d.Akad.Hari = locale.Hari(p.Tanggal_nikah.Weekday())
+		d.Akad.Tanggal = locale.Tanggal(p.Tanggal_nikah)
+		d.Akad.TanggalHijriah = locale.TanggalHijriahTeks(p.Tanggal_nikah)
+	}
+	if p.Waktu_nikah != "" {
+		d.Akad.Waktu = formulirText(locale.FormatJam(p.Waktu_nikah, zona))
+	}
+	d.Akad.Tempat = formulirText(p.Tempat_nikah)
+	d.Akad.Alamat = formulirText(p.Alamat_akad)
+
+	if kua.ID != 0 {
+		d.KUA.Nama = formulirText(kua.Nama)
+		d.KUA.Alamat = formulirText(kua.Alamat)
+		d.KUA.Kecamatan = formulirText(kua.Kecamatan)
+		d.KUA.Kabupaten = formulirText(kua.Kabupaten)
+		d.KUA.Provinsi = formulirText(kua.Provinsi)
+	}
+
+	if p.Penghulu_id != nil {
+		var penghulu structs.Penghulu
+		if err := fs.DB.First(&penghulu, *p.Penghulu_id).Error; err == nil {
+			d.Penghulu = formulirText(penghulu.Nama_lengkap)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	// Data orang tua disimpan per pendaftar dan jenis kelamin calon; selain ayah calon istri hanya yang masih hidup
+	var orangTua []structs.DataOrangTua
+	if err := fs.DB.Where("user_id = ?", p.Pendaftar_id).Order("id").Find(&orangTua).Error; err != nil {
+		return nil, err
+	}
+	find := func(jk, hubungan string) *structs.DataOrangTua {
+		var found *structs.DataOrangTua
+		for i := range orangTua {
+			if orangTua[i].Jenis_kelamin_calon == jk && orangTua[i].Hubungan == hubungan {
+				found = &orangTua[i] // data terbaru menimpa data pendaftaran sebelumnya
+			}
+		}
+		return found
+	}
+	ayahSuami, ayahIstri := find("L", structs.HubunganAyah), find("P", structs.HubunganAyah)
+	d.AyahSuami, d.IbuSuami = formulirOrangTua(ayahSuami), formulirOrangTua(find("L", structs.HubunganIbu))
+	d.AyahIstri, d.IbuIstri = formulirOrangTua(ayahIstri), formulirOrangTua(find("P", structs.HubunganIbu))
+	if ayahIstri != nil && ayahIstri.Status_keberadaan != structs.StatusKeberadaanHidup {
+		d.AyahIstri = FormulirOrang{} // ayah yang tidak hidup hanya dipakai untuk binti
+	}
+	d.Suami = formulirCalon(ctx.Suami, ayahSuami, ctx.TanggalDaftar)
+	d.Istri = formulirCalon(ctx.Istri, ayahIstri, ctx.TanggalDaftar)
+
+	if ctx.Wali != nil {
+		d.Wali = FormulirWali{
+			Ada:      true,
+			Nama:     formulirText(ctx.Wali.Nama_lengkap),
+			NIK:      formulirText(ctx.Wali.NIK),
+			Hubungan: formulirText(ctx.Wali.Hubungan_wali),
+			Agama:    formulirText(ctx.Wali.Agama),
+			Alamat:   formulirText(ctx.Wali.Alamat),
+		}
+	}
+	return d, nil
+}
+
+// Generate mencetak formulir slug ("n1".."n4", "nb") untuk pendaftaran p dan mengembalikan PDF beserta nama filenya.
+// Formulir per calon (N1, N4) membutuhkan pihak "suami" atau "istri".
+func (fs *FormulirService) Generate(p *structs.PendaftaranNikah, slug, pihak string) ([]byte, string, error) {
+	model, ok := FormulirModels[strings.ToLower(slug)]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: model %q tidak dikenal (n1, n2, n3, n4, nb)", ErrFormulirInvalid, slug)
+	}
+
+	data, err := fs.Data(p)
+	if err != nil {
+		return nil, "", err
+	}
+	data.Kode = model.Kode
+
+	filename := "Model-" + model.Kode + "-" + p.Nomor_pendaftaran
+	if model.PerCalon {
+		if err := data.ForPihak(strings.ToLower(pihak)); err != nil {
+			return nil, "", err
+		}
+		filename += "-" + strings.ToLower(pihak)
+	} else if !data.Suami.Ada || !data.Istri.Ada {
+		return nil, "", fmt.Errorf("%w: data calon suami dan calon istri harus ada", ErrFormulirIncomplete)
+	}
+
+	out, err := RenderFormulir(model, data)
+	if err != nil {
+		return nil, "", err
+	}
+	return out, filename + ".pdf", nil
+}
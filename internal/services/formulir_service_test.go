@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/locale"
+)
+
+// go test ./internal/services -run TestRenderFormulir -update menulis ulang golden file
+var updateGolden = flag.Bool("update", false, "tulis ulang golden file di testdata")
+
+func formulirTestData() *FormulirData {
+	daftar := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	lahirAyah := time.Date(1968, 3, 12, 0, 0, 0, 0, time.UTC)
+	ayahSuami := &structs.DataOrangTua{Nama_lengkap: "Ahmad Fauzi", NIK: "3273011203680001", Tempat_lahir: "Garut", Tanggal_lahir: &lahirAyah,
+		Warga_negara: "WNI", Agama: "Islam", Pekerjaan: "Wiraswasta", Alamat: "Jl. Merdeka No. 10, Bandung"}
+	ibuSuami := &structs.DataOrangTua{Nama_lengkap: "Siti Aminah", NIK: "3273014506700002", Tempat_lahir: "Bandung",
+		Warga_negara: "WNI", Agama: "Islam", Pekerjaan: "Ibu Rumah Tangga", Alamat: "Jl. Merdeka No. 10, Bandung"}
+	ibuIstri := &structs.DataOrangTua{Nama_lengkap: "Nur Halimah", NIK: "3204025508720003", Tempat_lahir: "Cianjur",
+		Warga_negara: "WNI", Agama: "Islam", Pekerjaan: "Guru", Alamat: "Kp. Sukamaju RT 02/RW 05, Soreang"}
+
+	d := &FormulirData{
+		NomorPendaftaran: "NIK-20261001-0007",
+		TanggalCetak:     locale.Tanggal(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)),
+		NomorDispensasi:  "",
+		Penghulu:         "H. Abdul Rahman, S.Ag.",
+	}
+	d.KUA.Nama = "KUA Kecamatan Coblong"
+	d.KUA.Alamat = "Jl. Ir. H. Juanda No. 200, Bandung"
+	d.KUA.Kecamatan = "Coblong"
+	d.KUA.Kabupaten = "Kota Bandung"
+	d.Akad.Hari = "Sabtu"
+	d.Akad.Tanggal = locale.Tanggal(time.Date(2026, 11, 14, 0, 0, 0, 0, time.UTC))
+	d.Akad.TanggalHijriah = locale.TanggalHijriahTeks(time.Date(2026, 11, 14, 0, 0, 0, 0, time.UTC))
+	d.Akad.Waktu = locale.FormatJam("09:00", locale.WIB)
+	d.Akad.Tempat = "Di Luar KUA"
+	d.Akad.Alamat = formulirText("Gedung Serbaguna (Aula Timur) | Jl. Dago 55; Bandung")
+
+	d.Suami = formulirCalon(&structs.CalonPasangan{
+		Nama_lengkap: "Muhammad Rizki", NIK: "3273010101950001", Jenis_kelamin: "L", Tempat_lahir: "Bandung",
+		Tanggal_lahir: time.Date(1995, 1, 1, 0, 0, 0, 0, time.UTC), Alamat: "Jl. Merdeka No. 10", RT: "001", RW: "002",
+		Kelurahan: "Dago", Kecamatan: "Coblong", Kabupaten: "Kota Bandung", Provinsi: "Jawa Barat",
+		Agama: "Islam", Status_perkawinan: structs.StatusPerkawinanBelumKawin, Pekerjaan: "Karyawan Swasta", Warga_negara: "WNI",
+	}, ayahSuami, daftar)
+	d.Istri = formulirCalon(&structs.CalonPasangan{
+		Nama_lengkap: "Aisyah Putri Ramadhani", NIK: "3204024107060004", Jenis_kelamin: "P", Tempat_lahir: "Cianjur",
+		Tanggal_lahir: time.Date(2006, 7, 1, 0, 0, 0, 0, time.UTC), Alamat: "Kp. Sukamaju", RT: "002", RW: "005",
+		Kelurahan: "Soreang", Kecamatan: "Soreang", Kabupaten: "Kabupaten Bandung", Provinsi: "Jawa Barat",
+		Agama: "Islam", Status_perkawinan: structs.StatusPerkawinanBelumKawin, Pekerjaan: "Mahasiswa", Warga_negara: "WNI",
+	}, nil, daftar)
+	d.AyahSuami, d.IbuSuami = formulirOrangTua(ayahSuami), formulirOrangTua(ibuSuami)
+	d.AyahIstri, d.IbuIstri = formulirOrangTua(nil), formulirOrangTua(ibuIstri)
+	d.Wali = FormulirWali{Ada: true, Nama: "Hasan Basri", NIK: "3204021010450005", Hubungan: structs.WaliHubunganKakek,
+		Agama: "Islam", Alamat: "Kp. Sukamaju RT 02/RW 05, Soreang"}
+	return d
+}
+
+func TestRenderFormulir(t *testing.T) {
+	tests := []struct {
+		golden string
+		slug   string
+		pihak  string
+	}{
+		{"n1-suami.pdf", "n1", FormulirPihakSuami},
+		{"n2.pdf", "n2", ""},
+		{"n3.pdf", "n3", ""},
+		{"n4-istri.pdf", "n4", FormulirPihakIstri},
+		{"nb.pdf", "nb", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			model := FormulirModels[tt.slug]
+			data := formulirTestData()
+			data.Kode = model.Kode
+			if model.PerCalon {
+				if err := data.ForPihak(tt.pihak); err != nil {
+					t.Fatalf("ForPihak(%q) error = %v", tt.pihak, err)
+				}
+			}
+			got, err := RenderFormulir(model, data)
+			if err != nil {
+				t.Fatalf("RenderFormulir() error = %v", err)
+			}
+
+			// Keluaran deterministik: render kedua harus identik
+			again, _ := RenderFormulir(model, data)
+			if !bytes.Equal(got, again) {
+				t.Fatalf("RenderFormulir() tidak deterministik")
+			}
+
+			path := filepath.Join("testdata", "formulir", tt.golden)
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("golden file: %v (jalankan dengan -update)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s berbeda dari golden file; periksa perubahan lalu jalankan dengan -update", tt.golden)
+			}
+		})
+	}
+}
+
+func TestFormulirData(t *testing.T) {
+	d := formulirTestData()
+	if d.Suami.BinBinti != "bin Ahmad Fauzi" || d.Istri.BinBinti != "" {
+		t.Errorf("bin/binti = %q, %q; want \"bin Ahmad Fauzi\" dan kosong (ayah tidak tercatat)", d.Suami.BinBinti, d.Istri.BinBinti)
+	}
+	if d.Istri.Umur != "20 tahun" {
+		t.Errorf("umur istri = %q, want 20 tahun saat mendaftar", d.Istri.Umur)
+	}
+	if d.Suami.Alamat != "Jl. Merdeka No. 10, RT 001/RW 002, Dago, Coblong, Kota Bandung, Jawa Barat" {
+		t.Errorf("alamat suami = %q", d.Suami.Alamat)
+	}
+	if got := formulirText("Gedung | A;\nB"); got != "Gedung / A, B" {
+		t.Errorf("formulirText() = %q, want pemisah template dibersihkan", got)
+	}
+
+	if err := d.ForPihak("wali"); !errors.Is(err, ErrFormulirInvalid) {
+		t.Errorf("ForPihak(wali) error = %v, want ErrFormulirInvalid", err)
+	}
+	d.Istri = FormulirOrang{}
+	if err := d.ForPihak(FormulirPihakIstri); !errors.Is(err, ErrFormulirIncomplete) {
+		t.Errorf("ForPihak(istri) tanpa data error = %v, want ErrFormulirIncomplete", err)
+	}
+	if err := d.ForPihak(FormulirPihakSuami); err != nil || d.Pihak != structs.DokumenPihakSuami || d.PasanganLabel != "calon istri" {
+		t.Errorf("ForPihak(suami) = %v, pihak %q, pasangan %q", err, d.Pihak, d.PasanganLabel)
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidHoliday dikembalikan jika data hari libur tidak valid
+	ErrInvalidHoliday = errors.New("data hari libur tidak valid")
+	// ErrHolidayNotFound dikembalikan jika hari libur tidak ada atau bukan milik KUA user
+	ErrHolidayNotFound = errors.New("hari libur tidak ditemukan")
+)
+
+// HolidayEntry adalah satu baris hari libur dari file JSON/CSV atau request API
+type HolidayEntry struct {
+	Tanggal string `json:"tanggal"` // YYYY-MM-DD
+	Nama    string `json:"nama"`
+	Jenis   string `json:"jenis"` // Libur Nasional (default), Cuti Bersama, Penutupan KUA
+}
+
+// Normalize merapikan dan memvalidasi entri hari libur
+func (e *HolidayEntry) Normalize() error {
+	e.Tanggal = strings.TrimSpace(e.Tanggal)
+	e.Nama = strings.TrimSpace(e.Nama)
+	e.Jenis = strings.TrimSpace(e.Jenis)
+
+	if _, err := time.Parse("2006-01-02", e.Tanggal); err != nil {
+		return fmt.Errorf("%w: tanggal %q harus YYYY-MM-DD", ErrInvalidHoliday, e.Tanggal)
+	}
+	if e.Nama == "" {
+		return fmt.Errorf("%w: nama hari libur %s wajib diisi", ErrInvalidHoliday, e.Tanggal)
+	}
+	switch e.Jenis {
+	case "":
+		e.Jenis = structs.HariLiburJenisNasional
+	case structs.HariLiburJenisNasional, structs.HariLiburJenisCutiBersama, structs.HariLiburJenisPenutupanKUA:
+	default:
+		return fmt.Errorf("%w: jenis %q tidak dikenal", ErrInvalidHoliday, e.Jenis)
+	}
+	return nil
+}
+
+// ParseHolidayFile membaca daftar hari libur dari file JSON (array objek) atau CSV (header tanggal,nama,jenis)
+func ParseHolidayFile(r io.Reader, format string) ([]HolidayEntry, error) {
+	var entries []HolidayEntry
+
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "json":
+		if err := json.NewDecoder(r).Decode(&entries); err != nil {
+			return nil, fmt.Errorf("%w: JSON tidak valid: %v", ErrInvalidHoliday, err)
+		}
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("%w: CSV tidak valid: %v", ErrInvalidHoliday, err)
+		}
+		for i, record := range records {
+			if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "tanggal") {
+				continue
+			}
+			if len(record) < 2 {
+				return nil, fmt.Errorf("%w: baris %d harus berisi tanggal,nama[,jenis]", ErrInvalidHoliday, i+1)
+			}
+			entry := HolidayEntry{Tanggal: record[0], Nama: record[1]}
+			if len(record) > 2 {
+				entry.Jenis = record[2]
+			}
+			entries = append(entries, entry)
+		}
+	default:
+		return nil, fmt.Errorf("%w: format file harus json atau csv", ErrInvalidHoliday)
+	}
+
+	for i := range entries {
+		if err := entries[i].Normalize(); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// HolidayService untuk hari libur nasional, cuti bersama, dan penutupan KUA
+type HolidayService struct {
+	DB *gorm.DB
+}
+
+// NewHolidayService membuat instance baru dari HolidayService
+func NewHolidayService(db *gorm.DB) *HolidayService {
+	return &HolidayService{DB: db}
+}
+
+// holidayScope membatasi query ke hari libur nasional (kua_id = 0) dan hari libur KUA tertentu
+func holidayScope(kuaID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("kua_id IN ?", []uint{0, kuaID})
+	}
+}
+
+// ForRange mengembalikan hari libur yang berlaku untuk KUA antara from dan to (inklusif), per tanggal YYYY-MM-DD.
+// Jika tanggal yang sama ada di data nasional dan KUA, data KUA yang dipakai.
+func (hs *HolidayService) ForRange(kuaID uint, from, to time.Time) (map[string]structs.HariLibur, error) {
+	var rows []structs.HariLibur
+	if err := hs.DB.Scopes(holidayScope(kuaID)).
+		Where("tanggal >= ? AND tanggal <= ?", from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Order("kua_id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]structs.HariLibur, len(rows))
+	for _, row := range rows {
+		result[row.Tanggal] = row
+	}
+	return result, nil
+}
+
+// Get mengembalikan hari libur KUA pada tanggal tertentu, atau nil jika bukan hari libur
+func (hs *HolidayService) Get(kuaID uint, tanggal time.Time) (*structs.HariLibur, error) {
+	holidays, err := hs.ForRange(kuaID, tanggal, tanggal)
+	if err != nil {
+		return nil, err
+	}
+	if h, ok := holidays[tanggal.Format("2006-01-02")]; ok {
+		return &h, nil
+	}
+	return nil, nil
+}
+
+// WorkingDays menghitung hari kerja KUA antara start dan end (tanpa akhir pekan dan hari libur)
+func (hs *HolidayService) WorkingDays(kuaID uint, start, end time.Time) (int, error) {
+	holidays, err := hs.ForRange(kuaID, start, end)
+	if err != nil {
+		return 0, err
+	}
+	return utils.CalculateWorkingDaysExcluding(start, end, func(t time.Time) bool {
+		_, ok := holidays[t.Format("2006-01-02")]
+		return ok
+	}), nil
+}
+
+// List mengambil hari libur yang berlaku untuk KUA pada satu tahun
+func (hs *HolidayService) List(kuaID uint, tahun int) ([]structs.HariLibur, error) {
+	var rows []structs.HariLibur
+	err := hs.DB.Scopes(holidayScope(kuaID)).
+		Where("tanggal LIKE ?", fmt.Sprintf("%04d-%%", tahun)).
+		Order("tanggal ASC, kua_id ASC").Find(&rows).Error
+	return rows, err
+}
+
+// Import menyimpan daftar hari libur untuk KUA (0 = nasional). Tanggal yang sudah ada diperbarui.
+func (hs *HolidayService) Import(kuaID uint, entries []HolidayEntry, dibuatOleh string) (int, error) {
+	now := time.Now()
+	rows := make([]structs.HariLibur, 0, len(entries))
+	for _, entry := range entries {
+		if err := entry.Normalize(); err != nil {
+			return 0, err
+		}
+		rows = append(rows, structs.HariLibur{
+			Tanggal:     entry.Tanggal,
+			Kua_id:      kuaID,
+			Nama:        entry.Nama,
+			Jenis:       entry.Jenis,
+			Dibuat_oleh: dibuatOleh,
+			Created_at:  now,
+			Updated_at:  now,
+		})
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	err := hs.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tanggal"}, {Name: "kua_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"nama", "jenis", "dibuat_oleh", "updated_at"}),
+	}).Create(&rows).Error
+	if err != nil {
+		return 0, fmt.Errorf("gagal menyimpan hari libur: %v", err)
+	}
+	return len(rows), nil
+}
+
+// Delete menghapus hari libur milik KUA. Hari libur nasional (kua_id = 0) hanya bisa diubah lewat file seed.
+func (hs *HolidayService) Delete(id string, kuaID uint) error {
+	if kuaID == 0 {
+		return ErrHolidayNotFound
+	}
+	result := hs.DB.Scopes(TenantScope(kuaID)).Where("id = ?", id).Delete(&structs.HariLibur{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrHolidayNotFound
+	}
+	return nil
+}
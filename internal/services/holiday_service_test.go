@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/utils"
+)
+
+func TestParseHolidayFile(t *testing.T) {
+	jsonInput := `[
+		{"tanggal": "2026-08-17", "nama": "Hari Kemerdekaan"},
+		{"tanggal": "2026-12-24", "nama": "Cuti Bersama Natal", "jenis": "Cuti Bersama"}
+	]`
+	entries, err := ParseHolidayFile(strings.NewReader(jsonInput), "json")
+	if err != nil {
+		t.Fatalf("ParseHolidayFile(json) error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Jenis != structs.HariLiburJenisNasional || entries[1].Jenis != structs.HariLiburJenisCutiBersama {
+		t.Fatalf("ParseHolidayFile(json) = %+v", entries)
+	}
+
+	csvInput := "tanggal,nama,jenis\n2026-03-20, Idul Fitri ,\n2026-05-04,Rapat Kerja KUA,Penutupan KUA\n"
+	entries, err = ParseHolidayFile(strings.NewReader(csvInput), ".CSV")
+	if err != nil {
+		t.Fatalf("ParseHolidayFile(csv) error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Nama != "Idul Fitri" || entries[1].Jenis != structs.HariLiburJenisPenutupanKUA {
+		t.Fatalf("ParseHolidayFile(csv) = %+v", entries)
+	}
+
+	invalid := map[string]string{
+		"csv":  "17-08-2026,Hari Kemerdekaan\n",
+		"json": `[{"tanggal": "2026-08-17", "nama": "", "jenis": "Libur Nasional"}]`,
+		"xlsx": "",
+	}
+	for format, input := range invalid {
+		if _, err := ParseHolidayFile(strings.NewReader(input), format); !errors.Is(err, ErrInvalidHoliday) {
+			t.Errorf("ParseHolidayFile(%s) error = %v, want ErrInvalidHoliday", format, err)
+		}
+	}
+
+	entry := HolidayEntry{Tanggal: "2026-01-01", Nama: "Tahun Baru", Jenis: "Libur Daerah"}
+	if err := entry.Normalize(); !errors.Is(err, ErrInvalidHoliday) {
+		t.Errorf("Normalize() jenis tidak dikenal = %v, want ErrInvalidHoliday", err)
+	}
+}
+
+func TestCalculateWorkingDaysExcluding(t *testing.T) {
+	// Senin 17 Agustus 2026 s.d. Minggu 23 Agustus 2026
+	start := time.Date(2026, 8, 17, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 8, 23, 0, 0, 0, 0, time.UTC)
+
+	if got := utils.CalculateWorkingDays(start, end); got != 5 {
+		t.Fatalf("CalculateWorkingDays() = %d, want 5", got)
+	}
+
+	libur := map[string]bool{"2026-08-17": true, "2026-08-22": true}
+	got := utils.CalculateWorkingDaysExcluding(start, end, func(d time.Time) bool {
+		return libur[d.Format("2006-01-02")]
+	})
+	if got != 4 {
+		t.Fatalf("CalculateWorkingDaysExcluding() = %d, want 4", got)
+	}
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// DefaultInvitationTTL adalah masa berlaku default link undangan staff
+const DefaultInvitationTTL = 72 * time.Hour
+
+var (
+	// ErrInvitationInvalid dikembalikan jika token undangan tidak valid, sudah dipakai, dibatalkan, atau kedaluwarsa
+	ErrInvitationInvalid = errors.New("link undangan tidak valid atau sudah tidak berlaku")
+)
+
+// InvitationClaims adalah isi token undangan yang ditandatangani (HS256)
+type InvitationClaims struct {
+	InvitationID uint   `json:"invitation_id"`
+	Role         string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// InvitationService untuk membuat dan menukarkan undangan akun staff/penghulu
+type InvitationService struct {
+	DB         *gorm.DB
+	signingKey []byte
+	baseURL    string
+}
+
+// NewInvitationService membuat instance baru dari InvitationService
+// Kunci tanda tangan diambil dari INVITE_SIGNING_KEY (fallback ke JWT_KEY)
+func NewInvitationService(db *gorm.DB) *InvitationService {
+	baseURL := os.Getenv("INVITE_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:3000/aktivasi-akun"
+	}
+
+	return &InvitationService{DB: db, signingKey: SecretKey(EnvInviteSigningKey), baseURL: baseURL}
+}
+
+// CreateInvitation menyimpan undangan dan mengembalikan token bertanda tangan beserta link-nya
+func (is *InvitationService) CreateInvitation(undangan *structs.UndanganStaff, ttl time.Duration) (string, string, error) {
+	if ttl <= 0 {
+		ttl = DefaultInvitationTTL
+	}
+
+	tokenID, err := randomTokenID()
+	if err != nil {
+		return "", "", fmt.Errorf("gagal membuat token undangan: %v", err)
+	}
+
+	now := time.Now()
+	undangan.Token_id = tokenID
+	undangan.Kedaluwarsa_pada = now.Add(ttl)
+	undangan.Created_at = now
+	undangan.Updated_at = now
+
+	if err := is.DB.Create(undangan).Error; err != nil {
+		return "", "", fmt.Errorf("gagal menyimpan undangan: %v", err)
+	}
+
+	claims := InvitationClaims{
+		InvitationID: undangan.ID,
+		Role:         undangan.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   undangan.Email,
+			ExpiresAt: jwt.NewNumericDate(undangan.Kedaluwarsa_pada),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(is.signingKey)
+	if err != nil {
+		return "", "", fmt.Errorf("gagal menandatangani token undangan: %v", err)
+	}
+
+	return token, is.baseURL + "?token=" + url.QueryEscape(token), nil
+}
+
+// VerifyInvitation memvalidasi tanda tangan token dan mengembalikan undangan yang masih aktif
+func (is *InvitationService) VerifyInvitation(token string) (*structs.UndanganStaff, error) {
+	return is.verifyInvitation(is.DB, token)
+}
+
+func (is *InvitationService) verifyInvitation(db *gorm.DB, token string) (*structs.UndanganStaff, error) {
+	claims := &InvitationClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("metode signing token tidak valid")
+		}
+		return is.signingKey, nil
+	})
+	if err != nil || !parsed.Valid {
+		return nil, ErrInvitationInvalid
+	}
+
+	var undangan structs.UndanganStaff
+	if err := db.Where("id = ? AND token_id = ?", claims.InvitationID, claims.ID).First(&undangan).Error; err != nil {
+		return nil, ErrInvitationInvalid
+	}
+	if InvitationStatus(&undangan, time.Now()) != structs.UndanganStatusAktif {
+		return nil, ErrInvitationInvalid
+	}
+
+	return &undangan, nil
+}
+
+// RedeemInvitation menukarkan undangan satu kali di dalam satu database transaction.
+// createAccount dipanggil dengan data undangan untuk membuat akun; jika gagal, undangan tetap aktif.
+func (is *InvitationService) RedeemInvitation(token string, createAccount func(tx *gorm.DB, undangan *structs.UndanganStaff) (string, error)) (*structs.UndanganStaff, error) {
+	var redeemed *structs.UndanganStaff
+
+	err := is.DB.Transaction(func(tx *gorm.DB) error {
+		undangan, err := is.verifyInvitation(tx, token)
+		if err != nil {
+			return err
+		}
+
+		userID, err := createAccount(tx, undangan)
+		if err != nil {
+			return err
+		}
+
+		// Tandai digunakan hanya jika belum pernah digunakan (mencegah penukaran ganda bersamaan)
+		now := time.Now()
+		result := tx.Model(&structs.UndanganStaff{}).
+			Where("id = ? AND digunakan_pada IS NULL AND dibatalkan_pada IS NULL", undangan.ID).
+			Updates(map[string]interface{}{
+				"digunakan_pada": now,
+				"user_id":        userID,
+				"updated_at":     now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationInvalid
+		}
+
+		undangan.Digunakan_pada = &now
+		undangan.User_id = userID
+		redeemed = undangan
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return redeemed, nil
+}
+
+// RevokeInvitation membatalkan undangan yang belum digunakan milik sebuah KUA
+func (is *InvitationService) RevokeInvitation(id uint, kuaID uint) error {
+	now := time.Now()
+	result := is.DB.Model(&structs.UndanganStaff{}).Scopes(TenantScope(kuaID)).
+		Where("id = ? AND digunakan_pada IS NULL AND dibatalkan_pada IS NULL", id).
+		Updates(map[string]interface{}{
+			"dibatalkan_pada": now,
+			"updated_at":      now,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("gagal membatalkan undangan: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvitationInvalid
+	}
+	return nil
+}
+
+// InvitationStatus menghitung status undangan pada waktu tertentu
+func InvitationStatus(undangan *structs.UndanganStaff, now time.Time) string {
+	switch {
+	case undangan.Digunakan_pada != nil:
+		return structs.UndanganStatusDigunakan
+	case undangan.Dibatalkan_pada != nil:
+		return structs.UndanganStatusDibatalkan
+	case now.After(undangan.Kedaluwarsa_pada):
+		return structs.UndanganStatusKedaluwarsa
+	default:
+		return structs.UndanganStatusAktif
+	}
+}
+
+// NewTokenID membuat ID acak untuk jti token yang ditandatangani di luar package services
+func NewTokenID() (string, error) {
+	return randomTokenID()
+}
+
+// randomTokenID membuat ID acak 32 byte (hex) untuk jti token undangan
+func randomTokenID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
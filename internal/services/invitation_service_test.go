@@ -0,0 +1,75 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+)
+
+func TestInvitationCreateAndRedeem(t *testing.T) {
+	db := newTestDB(t)
+	is := &InvitationService{DB: db, signingKey: []byte("uji"), baseURL: "https://simnikah.example.go.id/aktivasi-akun"}
+
+	undang := func(email string) (*structs.UndanganStaff, string) {
+		undangan := &structs.UndanganStaff{Email: email, Nama: "Petugas Baru", Role: structs.UserRoleStaff, Dibuat_oleh: "KPL1", Kua_id: 1}
+		token, link, err := is.CreateInvitation(undangan, time.Hour)
+		if err != nil {
+			t.Fatalf("CreateInvitation() error = %v", err)
+		}
+		if !strings.HasPrefix(link, is.baseURL+"?token=") {
+			t.Errorf("link = %q, want diawali base URL", link)
+		}
+		return undangan, token
+	}
+
+	undangan, token := undang("staff1@kua.go.id")
+	if got, err := is.VerifyInvitation(token); err != nil || got.ID != undangan.ID {
+		t.Fatalf("VerifyInvitation() = %v, %v", got, err)
+	}
+
+	// Akun gagal dibuat: undangan tetap aktif
+	gagal := errors.New("username sudah dipakai")
+	if _, err := is.RedeemInvitation(token, func(tx *gorm.DB, u *structs.UndanganStaff) (string, error) { return "", gagal }); !errors.Is(err, gagal) {
+		t.Fatalf("RedeemInvitation(gagal) error = %v, want %v", err, gagal)
+	}
+
+	redeemed, err := is.RedeemInvitation(token, func(tx *gorm.DB, u *structs.UndanganStaff) (string, error) { return "STF9", nil })
+	if err != nil {
+		t.Fatalf("RedeemInvitation() error = %v", err)
+	}
+	if redeemed.User_id != "STF9" || redeemed.Digunakan_pada == nil {
+		t.Errorf("RedeemInvitation() = %+v", redeemed)
+	}
+	if _, err := is.RedeemInvitation(token, func(tx *gorm.DB, u *structs.UndanganStaff) (string, error) { return "STF10", nil }); !errors.Is(err, ErrInvitationInvalid) {
+		t.Errorf("RedeemInvitation(ulang) error = %v, want ErrInvitationInvalid", err)
+	}
+
+	// Token dengan kunci lain, kedaluwarsa, atau dibatalkan ditolak
+	_, token2 := undang("staff2@kua.go.id")
+	other := &InvitationService{DB: db, signingKey: []byte("lain")}
+	if _, err := other.VerifyInvitation(token2); !errors.Is(err, ErrInvitationInvalid) {
+		t.Errorf("VerifyInvitation(kunci lain) error = %v, want ErrInvitationInvalid", err)
+	}
+
+	kedaluwarsa, token3 := undang("staff3@kua.go.id")
+	db.Model(kedaluwarsa).Update("kedaluwarsa_pada", time.Now().Add(-time.Minute))
+	if _, err := is.VerifyInvitation(token3); !errors.Is(err, ErrInvitationInvalid) {
+		t.Errorf("VerifyInvitation(kedaluwarsa) error = %v, want ErrInvitationInvalid", err)
+	}
+
+	dibatalkan, token4 := undang("staff4@kua.go.id")
+	if err := is.RevokeInvitation(dibatalkan.ID, 2); !errors.Is(err, ErrInvitationInvalid) {
+		t.Errorf("RevokeInvitation(KUA lain) error = %v, want ErrInvitationInvalid", err)
+	}
+	if err := is.RevokeInvitation(dibatalkan.ID, 1); err != nil {
+		t.Fatalf("RevokeInvitation() error = %v", err)
+	}
+	if _, err := is.RedeemInvitation(token4, func(tx *gorm.DB, u *structs.UndanganStaff) (string, error) { return "STF11", nil }); !errors.Is(err, ErrInvitationInvalid) {
+		t.Errorf("RedeemInvitation(dibatalkan) error = %v, want ErrInvitationInvalid", err)
+	}
+}
@@ -0,0 +1,482 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/locale"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrJadwalInvalid dikembalikan jika perubahan jadwal nikah tidak valid
+	ErrJadwalInvalid = errors.New("jadwal nikah tidak valid")
+	// ErrJadwalNotFound dikembalikan jika pendaftaran tidak ada di KUA
+	ErrJadwalNotFound = errors.New("jadwal nikah tidak ditemukan")
+	// ErrJadwalLocked dikembalikan jika jadwal pendaftaran sudah tidak bisa diubah (selesai, ditolak, atau dibatalkan)
+	ErrJadwalLocked = errors.New("jadwal nikah sudah tidak bisa diubah")
+)
+
+// Tampilan jadwal nikah
+const (
+	JadwalTampilanHarian   = "harian"
+	JadwalTampilanMingguan = "mingguan"
+	JadwalTampilanBulanan  = "bulanan"
+)
+
+// AksiUbahJadwal dicatat di riwayat status setiap kali jadwal akad diubah
+const AksiUbahJadwal = "ubah_jadwal"
+
+// JadwalRange menghitung rentang tanggal [mulai, selesai) untuk tampilan harian,
+// mingguan (Senin-Minggu), atau bulanan yang memuat tanggal tersebut
+func JadwalRange(tampilan string, tanggal time.Time) (time.Time, time.Time, error) {
+	hari := time.Date(tanggal.Year(), tanggal.Month(), tanggal.Day(), 0, 0, 0, 0, tanggal.Location())
+	switch tampilan {
+	case JadwalTampilanHarian, "":
+		return hari, hari.AddDate(0, 0, 1), nil
+	case JadwalTampilanMingguan:
+		mulai := hari.AddDate(0, 0, -((int(hari.Weekday()) + 6) % 7))
+		return mulai, mulai.AddDate(0, 0, 7), nil
+	case JadwalTampilanBulanan:
+		mulai := time.Date(hari.Year(), hari.Month(), 1, 0, 0, 0, 0, hari.Location())
+		return mulai, mulai.AddDate(0, 1, 0), nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: tampilan harus harian, mingguan, atau bulanan", ErrJadwalInvalid)
+	}
+}
+
+// JadwalFilter adalah filter daftar jadwal nikah
+type JadwalFilter struct {
+	KuaID       uint
+	Mulai       time.Time // inklusif
+	Selesai     time.Time // eksklusif
+	PenghuluID  uint
+	Tempat      string // Di KUA, Di Luar KUA
+	Status      string // kosong = semua kecuali Draft, Ditolak, dan Dibatalkan
+	PendaftarID string // Diisi untuk catin: hanya jadwal miliknya (tanpa filter KUA)
+}
+
+// JadwalItem adalah satu akad nikah di jadwal KUA
+type JadwalItem struct {
+	PendaftaranID         uint     `json:"pendaftaran_id"`
+	NomorPendaftaran      string   `json:"nomor_pendaftaran"`
+	Tanggal               string   `json:"tanggal"`
+	Waktu                 string   `json:"waktu"`
+	Tempat                string   `json:"tempat"`
+	AlamatAkad            string   `json:"alamat_akad"`
+	Latitude              *float64 `json:"latitude"`
+	Longitude             *float64 `json:"longitude"`
+	Status                string   `json:"status_pendaftaran"`
+	PenghuluID            *uint    `json:"penghulu_id"`
+	PenghuluNama          string   `json:"penghulu_nama"`
+	CalonSuami            string   `json:"calon_suami"`
+	CalonIstri            string   `json:"calon_istri"`
+	KonflikKetidaksediaan bool     `json:"konflik_ketidaksediaan"`
+}
+
+// JadwalInput adalah perubahan jadwal akad oleh staff. Field kosong tidak diubah.
+type JadwalInput struct {
+	Tanggal         string   `json:"tanggal"` // YYYY-MM-DD
+	Waktu           string   `json:"waktu"`   // HH:MM
+	Tempat          string   `json:"tempat"`  // Di KUA, Di Luar KUA
+	AlamatAkad      string   `json:"alamat_akad"`
+	Latitude        *float64 `json:"latitude"`
+	Longitude       *float64 `json:"longitude"`
+	NomorDispensasi string   `json:"nomor_dispensasi"`
+	Catatan         string   `json:"catatan"` // Alasan perubahan, dicatat di riwayat status
+}
+
+// JadwalChange adalah hasil perubahan jadwal akad
+type JadwalChange struct {
+	Pendaftaran *structs.PendaftaranNikah `json:"pendaftaran"`
+	Sebelum     string                    `json:"sebelum"` // Ringkasan jadwal lama
+	Sesudah     string                    `json:"sesudah"` // Ringkasan jadwal baru
+}
+
+// JadwalService untuk tampilan dan perubahan jadwal akad nikah KUA
+type JadwalService struct {
+	DB *gorm.DB
+}
+
+// NewJadwalService membuat instance baru dari JadwalService
+func NewJadwalService(db *gorm.DB) *JadwalService {
+	return &JadwalService{DB: db}
+}
+
+// List mengambil jadwal akad di rentang tanggal beserta nama penghulu dan calon pasangan,
+// urut tanggal dan waktu akad
+func (js *JadwalService) List(f JadwalFilter) ([]JadwalItem, error) {
+	query := js.DB.Table("pendaftaran_nikahs").
+		Select("pendaftaran_nikahs.*, ph.nama_lengkap AS penghulu_nama, cs.nama_lengkap AS calon_suami_nama, ci.nama_lengkap AS calon_istri_nama").
+		Joins("LEFT JOIN penghulus ph ON ph.id = pendaftaran_nikahs.penghulu_id").
+		Joins("LEFT JOIN calon_pasangans cs ON pendaftaran_nikahs.calon_suami_id = cs.id").
+		Joins("LEFT JOIN calon_pasangans ci ON pendaftaran_nikahs.calon_istri_id = ci.id").
+		Where("pendaftaran_nikahs.tanggal_nikah >= ? AND pendaftaran_nikahs.tanggal_nikah < ?", f.Mulai, f.Selesai)
+
+	// Catin melihat jadwal miliknya di KUA mana pun; petugas hanya jadwal KUA-nya
+	if f.PendaftarID != "" {
+		query = query.Where("pendaftaran_nikahs.pendaftar_id = ?", f.PendaftarID)
+	} else {
+		query = query.Where("pendaftaran_nikahs.kua_id = ?", f.KuaID)
+	}
+
+	if f.Status != "" {
+		query = query.Where("pendaftaran_nikahs.status_pendaftaran = ?", f.Status)
+	} else {
+		query = query.Where("pendaftaran_nikahs.status_pendaftaran NOT IN ?",
+			[]string{structs.StatusPendaftaranDraft, structs.StatusPendaftaranDitolak, structs.StatusPendaftaranDibatalkan})
+	}
+	if f.PenghuluID != 0 {
+		query = query.Where("pendaftaran_nikahs.penghulu_id = ?", f.PenghuluID)
+	}
+	if f.Tempat != "" {
+		query = query.Where("pendaftaran_nikahs.tempat_nikah = ?", f.Tempat)
+	}
+
+	var rows []struct {
+		structs.PendaftaranNikah
+		PenghuluNama   string `gorm:"column:penghulu_nama"`
+		CalonSuamiNama string `gorm:"column:calon_suami_nama"`
+		CalonIstriNama string `gorm:"column:calon_istri_nama"`
+	}
+	if err := query.Order("pendaftaran_nikahs.tanggal_nikah ASC, pendaftaran_nikahs.waktu_nikah ASC").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]JadwalItem, 0, len(rows))
+	for _, r := range rows {
+		items = append(items, JadwalItem{
+			PendaftaranID:         r.ID,
+			NomorPendaftaran:      r.Nomor_pendaftaran,
+			Tanggal:               r.Tanggal_nikah.Format("2006-01-02"),
+			Waktu:                 r.Waktu_nikah,
+			Tempat:                r.Tempat_nikah,
+			AlamatAkad:            r.Alamat_akad,
+			Latitude:              r.Latitude,
+			Longitude:             r.Longitude,
+			Status:                r.Status_pendaftaran,
+			PenghuluID:            r.Penghulu_id,
+			PenghuluNama:          r.PenghuluNama,
+			CalonSuami:            r.CalonSuamiNama,
+			CalonIstri:            r.CalonIstriNama,
+			KonflikKetidaksediaan: r.Konflik_ketidaksediaan_id != nil,
+		})
+	}
+	return items, nil
+}
+
+// Reschedule mengubah tanggal, waktu, dan/atau lokasi akad sebuah pendaftaran. Jadwal baru divalidasi ulang
+// terhadap aturan penjadwalan, hari libur, dispensasi, kuota balai KUA, dan jadwal penghulu yang ditugaskan.
+// Slot balai KUA lama dilepas dan slot baru dipesan di transaction yang sama; perubahan dicatat di riwayat status.
+func (js *JadwalService) Reschedule(kuaID, pendaftaranID uint, input JadwalInput, actor TransitionActor) (*JadwalChange, error) {
+	js.prefetchTravel(kuaID, pendaftaranID, input)
+
+	var change *JadwalChange
+	var lama structs.PendaftaranNikah
+	err := js.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		change, lama, err = NewJadwalService(tx).rescheduleTx(kuaID, pendaftaranID, input, actor)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	js.finishReschedule(kuaID, &lama, change)
+	return change, nil
+}
+
+// rescheduleTx menjalankan Reschedule dengan js.DB sebagai transaction yang sedang berjalan. Pendaftaran
+// (dan penghulunya) dikunci sebelum dibaca sehingga validasi memakai data terbaru, lalu hanya kolom jadwal
+// yang diperbarui. Mengembalikan jadwal lama untuk finishReschedule setelah transaction selesai.
+func (js *JadwalService) rescheduleTx(kuaID, pendaftaranID uint, input JadwalInput, actor TransitionActor) (*JadwalChange, structs.PendaftaranNikah, error) {
+	p, baru, kua, err := js.prepare(kuaID, pendaftaranID, input, true)
+	if err != nil {
+		return nil, p, err
+	}
+
+	zona := locale.ZonaProvinsi(kua.Provinsi)
+	change := &JadwalChange{Sebelum: describeJadwal(&p, zona), Sesudah: describeJadwal(&baru, zona)}
+
+	if err := ReleaseRegistrationSlot(js.DB, p.ID); err != nil {
+		return nil, p, err
+	}
+	if baru.Tempat_nikah == "Di KUA" {
+		if err := BookSlot(js.DB, kua.ID, baru.Tanggal_nikah.Format("2006-01-02"), baru.Waktu_nikah, actor.UserID, p.ID); err != nil {
+			return nil, p, err
+		}
+	}
+
+	baru.Updated_at = time.Now()
+	if err := js.DB.Model(&structs.PendaftaranNikah{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
+		"tanggal_nikah":             baru.Tanggal_nikah,
+		"waktu_nikah":               baru.Waktu_nikah,
+		"tempat_nikah":              baru.Tempat_nikah,
+		"alamat_akad":               baru.Alamat_akad,
+		"latitude":                  baru.Latitude,
+		"longitude":                 baru.Longitude,
+		"nomor_dispensasi":          baru.Nomor_dispensasi,
+		"konflik_ketidaksediaan_id": baru.Konflik_ketidaksediaan_id,
+		"updated_at":                baru.Updated_at,
+	}).Error; err != nil {
+		return nil, p, err
+	}
+
+	catatan := "Jadwal diubah: " + change.Sebelum + " → " + change.Sesudah
+	if input.Catatan != "" {
+		catatan += ". " + input.Catatan
+	}
+	if err := RecordStatusHistory(js.DB, p.ID, p.Status_pendaftaran, p.Status_pendaftaran, AksiUbahJadwal, actor, catatan); err != nil {
+		return nil, p, err
+	}
+
+	change.Pendaftaran = &baru
+	return change, p, nil
+}
+
+// finishReschedule memberi tahu pendaftar dan penghulu, lalu menawarkan slot balai KUA lama ke daftar tunggu.
+// Dipanggil setelah transaction perubahan jadwal selesai.
+func (js *JadwalService) finishReschedule(kuaID uint, lama *structs.PendaftaranNikah, change *JadwalChange) {
+	js.notifyChange(change)
+
+	// Slot balai KUA di tanggal lama kosong dan ditawarkan ke daftar tunggu tanggal tersebut
+	if lama.Tempat_nikah == "Di KUA" {
+		if err := NewWaitlistService(js.DB).PromoteDate(kuaID, lama.Tanggal_nikah.Format("2006-01-02")); err != nil {
+			log.Printf("Gagal mempromosikan daftar tunggu: %v", err)
+		}
+	}
+}
+
+// Check memvalidasi perubahan jadwal seperti Reschedule tanpa menyimpan apapun, termasuk apakah slot
+// balai KUA yang dituju masih tersedia. Slot tidak ditahan; ketersediaan diperiksa ulang saat Reschedule.
+func (js *JadwalService) Check(kuaID, pendaftaranID uint, input JadwalInput) (*JadwalChange, error) {
+	p, baru, kua, err := js.prepare(kuaID, pendaftaranID, input, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if baru.Tempat_nikah == "Di KUA" {
+		slots, err := NewSlotService(js.DB).ListDay(kua, baru.Tanggal_nikah, p.Pendaftar_id)
+		if err != nil {
+			return nil, err
+		}
+		for _, slot := range slots {
+			if slot.Waktu == baru.Waktu_nikah && !slot.Tersedia && !slot.MilikSaya {
+				return nil, ErrSlotUnavailable
+			}
+		}
+	}
+
+	zona := locale.ZonaProvinsi(kua.Provinsi)
+	return &JadwalChange{Pendaftaran: &baru, Sebelum: describeJadwal(&p, zona), Sesudah: describeJadwal(&baru, zona)}, nil
+}
+
+// prepare memuat pendaftaran dan KUA-nya, lalu menerapkan serta memvalidasi jadwal baru:
+// aturan penjadwalan, hari libur, dispensasi, kuota balai KUA, dan jadwal penghulu yang ditugaskan.
+// Dengan lock=true (js.DB harus transaction) pendaftaran dan penghulu yang ditugaskan dikunci FOR UPDATE.
+func (js *JadwalService) prepare(kuaID, pendaftaranID uint, input JadwalInput, lock bool) (structs.PendaftaranNikah, structs.PendaftaranNikah, *structs.KUA, error) {
+	query := js.DB.Scopes(TenantScope(kuaID))
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+
+	var p structs.PendaftaranNikah
+	if err := query.First(&p, pendaftaranID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return p, p, nil, ErrJadwalNotFound
+		}
+		return p, p, nil, err
+	}
+	switch p.Status_pendaftaran {
+	case structs.StatusPendaftaranSelesai, structs.StatusPendaftaranDitolak, structs.StatusPendaftaranDibatalkan:
+		return p, p, nil, ErrJadwalLocked
+	}
+
+	var kua structs.KUA
+	if err := js.DB.First(&kua, p.Kua_id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return p, p, nil, ErrKUANotFound
+		}
+		return p, p, nil, err
+	}
+
+	baru, err := applyJadwalInput(p, &kua, input)
+	if err != nil {
+		return p, baru, &kua, err
+	}
+	if err := js.validate(&kua, &baru); err != nil {
+		return p, baru, &kua, err
+	}
+
+	// Kuota balai KUA dan jadwal penghulu yang sudah ditugaskan (termasuk ketidaksediaan dan waktu tempuh)
+	schedulingService := NewSchedulingRuleService(js.DB)
+	if baru.Penghulu_id != nil {
+		if lock {
+			if err := LockPenghulu(js.DB, *baru.Penghulu_id); err != nil {
+				return p, baru, &kua, err
+			}
+		} else {
+			schedulingService.PrefetchTravel(&baru, *baru.Penghulu_id)
+		}
+		if _, err := schedulingService.CheckPenghuluAssignment(&baru, *baru.Penghulu_id); err != nil {
+			return p, baru, &kua, err
+		}
+		baru.Konflik_ketidaksediaan_id = nil
+	} else if err := schedulingService.CheckKUACapacity(&baru); err != nil {
+		return p, baru, &kua, err
+	}
+	return p, baru, &kua, nil
+}
+
+// prefetchTravel meminta rute perjalanan penghulu untuk jadwal baru sebelum transaction dimulai, sehingga
+// pengecekan waktu tempuh di dalam kunci memakai cache dan tidak menunggu server rute. Error diabaikan
+// karena jadwal divalidasi ulang di dalam transaction.
+func (js *JadwalService) prefetchTravel(kuaID, pendaftaranID uint, input JadwalInput) {
+	var p structs.PendaftaranNikah
+	if err := js.DB.Scopes(TenantScope(kuaID)).First(&p, pendaftaranID).Error; err != nil || p.Penghulu_id == nil {
+		return
+	}
+	var kua structs.KUA
+	if err := js.DB.First(&kua, p.Kua_id).Error; err != nil {
+		return
+	}
+	baru, err := applyJadwalInput(p, &kua, input)
+	if err != nil {
+		return
+	}
+	NewSchedulingRuleService(js.DB).PrefetchTravel(&baru, *p.Penghulu_id)
+}
+
+// notifyChange memberi tahu pendaftar dan penghulu yang ditugaskan tentang perubahan jadwal akad
+func (js *JadwalService) notifyChange(change *JadwalChange) {
+	p := change.Pendaftaran
+	userIDs := []string{p.Pendaftar_id}
+	if p.Penghulu_id != nil {
+		var penghulu structs.Penghulu
+		if err := js.DB.Select("user_id").First(&penghulu, *p.Penghulu_id).Error; err == nil && penghulu.User_id != "" {
+			userIDs = append(userIDs, penghulu.User_id)
+		}
+	}
+
+	pesan := fmt.Sprintf("Jadwal akad nikah %s diubah dari %s menjadi %s", p.Nomor_pendaftaran, change.Sebelum, change.Sesudah)
+	if err := NewNotificationService(js.DB).SendBulkNotification(userIDs, "Perubahan Jadwal Nikah", pesan,
+		structs.NotifikasiTipeWarning, "/simnikah/jadwal"); err != nil {
+		log.Printf("Gagal mengirim notifikasi perubahan jadwal: %v", err)
+	}
+}
+
+// validate memeriksa jadwal baru: bukan tanggal lampau (zona waktu KUA), hari tutup, atau hari libur, di dalam jam layanan,
+// slot balai KUA yang valid untuk nikah di KUA, dan nomor dispensasi jika diperlukan
+func (js *JadwalService) validate(kua *structs.KUA, p *structs.PendaftaranNikah) error {
+	rules := RulesForKUA(kua)
+
+	zona := NewKUAService(js.DB).ZonaWaktu(kua.ID)
+	if p.Tanggal_nikah.Before(TanggalLokal(time.Now(), zona)) {
+		return fmt.Errorf("%w: tanggal nikah sudah lewat", ErrJadwalInvalid)
+	}
+	if rules.IsClosedDay(p.Tanggal_nikah) {
+		return fmt.Errorf("%w: %s tidak melayani pernikahan pada hari tersebut", ErrJadwalInvalid, kua.Nama)
+	}
+	libur, err := NewHolidayService(js.DB).Get(kua.ID, p.Tanggal_nikah)
+	if err != nil {
+		return err
+	}
+	if libur != nil {
+		return fmt.Errorf("%w: tanggal nikah jatuh pada hari libur (%s)", ErrJadwalInvalid, libur.Nama)
+	}
+	if !rules.WithinWorkingHours(p.Waktu_nikah) {
+		return fmt.Errorf("%w: waktu nikah harus di antara %s dan %s", ErrJadwalInvalid, rules.JamMulai, rules.JamSelesai)
+	}
+	if p.Tempat_nikah == "Di KUA" {
+		if err := ValidateSlot(rules, zona, p.Tanggal_nikah, p.Waktu_nikah); err != nil {
+			return fmt.Errorf("%w: %v", ErrJadwalInvalid, err)
+		}
+	}
+
+	if reasons := DispensationReasons(js.DB, p); len(reasons) > 0 && strings.TrimSpace(p.Nomor_dispensasi) == "" {
+		return fmt.Errorf("%w: nomor dispensasi wajib diisi karena: %s", ErrJadwalInvalid, strings.Join(reasons, " dan "))
+	}
+	return nil
+}
+
+// applyJadwalInput menerapkan input ke salinan pendaftaran. Nikah di KUA memakai alamat dan koordinat KUA;
+// nikah di luar KUA wajib punya alamat, dan koordinat lama dihapus jika alamat berubah tanpa koordinat baru.
+func applyJadwalInput(p structs.PendaftaranNikah, kua *structs.KUA, input JadwalInput) (structs.PendaftaranNikah, error) {
+	baru := p
+
+	if input.Tanggal != "" {
+		tanggal, err := time.Parse("2006-01-02", input.Tanggal)
+		if err != nil {
+			return baru, fmt.Errorf("%w: format tanggal harus YYYY-MM-DD", ErrJadwalInvalid)
+		}
+		baru.Tanggal_nikah = tanggal
+	}
+	if input.Waktu != "" {
+		if _, err := time.Parse("15:04", input.Waktu); err != nil {
+			return baru, fmt.Errorf("%w: format waktu harus HH:MM", ErrJadwalInvalid)
+		}
+		baru.Waktu_nikah = input.Waktu
+	}
+	if input.Tempat != "" {
+		if input.Tempat != "Di KUA" && input.Tempat != "Di Luar KUA" {
+			return baru, fmt.Errorf("%w: tempat harus 'Di KUA' atau 'Di Luar KUA'", ErrJadwalInvalid)
+		}
+		baru.Tempat_nikah = input.Tempat
+	}
+	if input.NomorDispensasi != "" {
+		baru.Nomor_dispensasi = strings.TrimSpace(input.NomorDispensasi)
+	}
+
+	if baru.Tempat_nikah == "Di KUA" {
+		baru.Alamat_akad = kua.Nama + ", " + kua.Alamat
+		baru.Latitude = kua.Latitude
+		baru.Longitude = kua.Longitude
+	} else {
+		if p.Tempat_nikah == "Di KUA" {
+			baru.Alamat_akad, baru.Latitude, baru.Longitude = "", nil, nil
+		}
+		if alamat := strings.TrimSpace(input.AlamatAkad); alamat != "" && alamat != baru.Alamat_akad {
+			baru.Alamat_akad = alamat
+			baru.Latitude, baru.Longitude = nil, nil
+		}
+		if (input.Latitude == nil) != (input.Longitude == nil) {
+			return baru, fmt.Errorf("%w: latitude dan longitude harus diisi bersamaan", ErrJadwalInvalid)
+		}
+		if input.Latitude != nil {
+			baru.Latitude, baru.Longitude = input.Latitude, input.Longitude
+		}
+		if baru.Alamat_akad == "" {
+			return baru, fmt.Errorf("%w: alamat akad wajib diisi untuk nikah di luar KUA", ErrJadwalInvalid)
+		}
+	}
+
+	if describeJadwal(&baru, nil) == describeJadwal(&p, nil) && sameCoordinate(baru.Latitude, p.Latitude) &&
+		sameCoordinate(baru.Longitude, p.Longitude) && baru.Nomor_dispensasi == p.Nomor_dispensasi {
+		return baru, fmt.Errorf("%w: tidak ada perubahan jadwal", ErrJadwalInvalid)
+	}
+	return baru, nil
+}
+
+// describeJadwal meringkas jadwal akad dalam bahasa Indonesia dengan jam di zona KUA,
+// mis. "Senin, 2 November 2026 pukul 09.00 WITA Di KUA"; alamat akad ditambahkan untuk nikah di luar KUA
+func describeJadwal(p *structs.PendaftaranNikah, zona *time.Location) string {
+	s := locale.HariTanggal(p.Tanggal_nikah) + " pukul " + locale.FormatJam(p.Waktu_nikah, zona) + " " + p.Tempat_nikah
+	if p.Tempat_nikah != "Di KUA" && p.Alamat_akad != "" {
+		s += " (" + p.Alamat_akad + ")"
+	}
+	return s
+}
+
+// sameCoordinate membandingkan dua koordinat opsional
+func sameCoordinate(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
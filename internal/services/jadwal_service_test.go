@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/locale"
+)
+
+func TestJadwalRange(t *testing.T) {
+	kamis := time.Date(2026, 11, 5, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		tampilan       string
+		mulai, selesai string
+	}{
+		{JadwalTampilanHarian, "2026-11-05", "2026-11-06"},
+		{"", "2026-11-05", "2026-11-06"},
+		{JadwalTampilanMingguan, "2026-11-02", "2026-11-09"},
+		{JadwalTampilanBulanan, "2026-11-01", "2026-12-01"},
+	}
+	for _, tt := range tests {
+		mulai, selesai, err := JadwalRange(tt.tampilan, kamis)
+		if err != nil {
+			t.Fatalf("JadwalRange(%q) error = %v", tt.tampilan, err)
+		}
+		if got := mulai.Format("2006-01-02"); got != tt.mulai {
+			t.Errorf("JadwalRange(%q) mulai = %s, want %s", tt.tampilan, got, tt.mulai)
+		}
+		if got := selesai.Format("2006-01-02"); got != tt.selesai {
+			t.Errorf("JadwalRange(%q) selesai = %s, want %s", tt.tampilan, got, tt.selesai)
+		}
+	}
+
+	// Minggu masuk ke minggu yang dimulai Senin sebelumnya
+	minggu := time.Date(2026, 11, 8, 0, 0, 0, 0, time.UTC)
+	if mulai, _, _ := JadwalRange(JadwalTampilanMingguan, minggu); mulai.Format("2006-01-02") != "2026-11-02" {
+		t.Errorf("JadwalRange(mingguan, Minggu) mulai = %s, want 2026-11-02", mulai.Format("2006-01-02"))
+	}
+
+	if _, _, err := JadwalRange("tahunan", kamis); !errors.Is(err, ErrJadwalInvalid) {
+		t.Fatalf("JadwalRange(tahunan) = %v, want ErrJadwalInvalid", err)
+	}
+}
+
+func TestApplyJadwalInput(t *testing.T) {
+	coord := func(v float64) *float64 { return &v }
+	kua := &structs.KUA{Nama: "KUA Banjarmasin Utara", Alamat: "Jl. Sultan Adam", Latitude: coord(-3.29), Longitude: coord(114.59)}
+	diLuar := structs.PendaftaranNikah{
+		Tanggal_nikah: time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC),
+		Waktu_nikah:   "09:00",
+		Tempat_nikah:  "Di Luar KUA",
+		Alamat_akad:   "Jl. Pramuka No. 5",
+		Latitude:      coord(-3.31),
+		Longitude:     coord(114.62),
+	}
+
+	// Pindah ke KUA memakai alamat dan koordinat KUA
+	baru, err := applyJadwalInput(diLuar, kua, JadwalInput{Tempat: "Di KUA", Waktu: "10:00"})
+	if err != nil {
+		t.Fatalf("applyJadwalInput(Di KUA) error = %v", err)
+	}
+	if baru.Alamat_akad != "KUA Banjarmasin Utara, Jl. Sultan Adam" || baru.Latitude != kua.Latitude || baru.Waktu_nikah != "10:00" {
+		t.Fatalf("applyJadwalInput(Di KUA) = %+v, want alamat dan koordinat KUA", baru)
+	}
+
+	// Alamat baru tanpa koordinat menghapus koordinat lama
+	baru, err = applyJadwalInput(diLuar, kua, JadwalInput{AlamatAkad: "Jl. Veteran No. 10"})
+	if err != nil || baru.Latitude != nil || baru.Longitude != nil {
+		t.Fatalf("applyJadwalInput(alamat baru) = %+v, %v, want koordinat kosong", baru, err)
+	}
+
+	// Hanya tanggal berubah: alamat dan koordinat tetap
+	baru, err = applyJadwalInput(diLuar, kua, JadwalInput{Tanggal: "2026-11-09"})
+	if err != nil || baru.Alamat_akad != diLuar.Alamat_akad || *baru.Latitude != -3.31 {
+		t.Fatalf("applyJadwalInput(tanggal) = %+v, %v, want alamat tetap", baru, err)
+	}
+
+	invalid := []JadwalInput{
+		{},
+		{Tanggal: "09-11-2026"},
+		{Waktu: "9 pagi"},
+		{Tempat: "Di Masjid"},
+		{Latitude: coord(-3.3)},
+		{Tanggal: "2026-11-02", Latitude: coord(-3.31), Longitude: coord(114.62)},
+	}
+	for _, input := range invalid {
+		if _, err := applyJadwalInput(diLuar, kua, input); !errors.Is(err, ErrJadwalInvalid) {
+			t.Errorf("applyJadwalInput(%+v) = %v, want ErrJadwalInvalid", input, err)
+		}
+	}
+
+	// Pindah dari KUA ke luar KUA wajib alamat
+	diKUA := diLuar
+	diKUA.Tempat_nikah = "Di KUA"
+	if _, err := applyJadwalInput(diKUA, kua, JadwalInput{Tempat: "Di Luar KUA"}); !errors.Is(err, ErrJadwalInvalid) {
+		t.Errorf("applyJadwalInput(ke luar KUA tanpa alamat) = %v, want ErrJadwalInvalid", err)
+	}
+}
+
+func TestJadwalServiceReschedule(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	penghulu := createTestPenghulu(t, db, "PGH1", kua.ID)
+	staff := TransitionActor{UserID: "STF1", Role: structs.UserRoleStaff, KuaID: kua.ID}
+
+	hari := time.Now().UTC().AddDate(0, 1, 0)
+	tanggal := time.Date(hari.Year(), hari.Month(), hari.Day(), 0, 0, 0, 0, time.UTC)
+	besok := tanggal.AddDate(0, 0, 1)
+
+	p := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Tanggal_nikah:      tanggal,
+		Waktu_nikah:        "09:00",
+		Status_pendaftaran: structs.StatusPendaftaranMenungguBimbingan,
+		Penghulu_id:        &penghulu.ID,
+		Catatan:            "catatan staff",
+	})
+	createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Tanggal_nikah:      besok,
+		Waktu_nikah:        "10:00",
+		Tempat_nikah:       "Di Luar KUA",
+		Alamat_akad:        "Jl. Pramuka No. 5",
+		Status_pendaftaran: structs.StatusPendaftaranMenungguBimbingan,
+		Penghulu_id:        &penghulu.ID,
+	})
+	js := NewJadwalService(db)
+
+	// Jadwal penghulu dicek ulang saat perubahan disimpan
+	_, err := js.Reschedule(kua.ID, p.ID, JadwalInput{Tanggal: besok.Format("2006-01-02"), Waktu: "10:00"}, staff)
+	var conflict *ScheduleConflict
+	if !errors.As(err, &conflict) || conflict.Type != ScheduleConflictJeda {
+		t.Fatalf("Reschedule(bentrok jadwal penghulu) error = %v, want ScheduleConflict jeda", err)
+	}
+
+	change, err := js.Reschedule(kua.ID, p.ID, JadwalInput{Tanggal: besok.Format("2006-01-02"), Waktu: "13:00", Catatan: "permintaan catin"}, staff)
+	if err != nil {
+		t.Fatalf("Reschedule() error = %v", err)
+	}
+	if change.Pendaftaran.Waktu_nikah != "13:00" || change.Sebelum == change.Sesudah {
+		t.Errorf("Reschedule() change = %+v", change)
+	}
+
+	var saved structs.PendaftaranNikah
+	db.First(&saved, p.ID)
+	if !saved.Tanggal_nikah.Equal(besok) || saved.Waktu_nikah != "13:00" {
+		t.Errorf("jadwal tersimpan = %s %s, want %s 13:00", saved.Tanggal_nikah, saved.Waktu_nikah, besok)
+	}
+	if saved.Catatan != "catatan staff" || saved.Status_pendaftaran != structs.StatusPendaftaranMenungguBimbingan {
+		t.Errorf("kolom non-jadwal berubah: catatan = %q, status = %q", saved.Catatan, saved.Status_pendaftaran)
+	}
+
+	var slot structs.SlotNikah
+	if err := db.Where("kua_id = ? AND tanggal = ? AND waktu = ?", kua.ID, besok.Format("2006-01-02"), "13:00").First(&slot).Error; err != nil ||
+		slot.Pendaftaran_id == nil || *slot.Pendaftaran_id != p.ID {
+		t.Errorf("slot balai KUA baru = %+v, %v, want terpesan untuk pendaftaran %d", slot, err, p.ID)
+	}
+
+	var riwayat structs.RiwayatStatus
+	if err := db.Where("pendaftaran_nikah_id = ? AND aksi = ?", p.ID, AksiUbahJadwal).First(&riwayat).Error; err != nil {
+		t.Fatalf("riwayat ubah jadwal tidak tercatat: %v", err)
+	}
+
+	if _, err := js.Reschedule(kua.ID+1, p.ID, JadwalInput{Waktu: "14:00"}, staff); !errors.Is(err, ErrJadwalNotFound) {
+		t.Errorf("Reschedule(KUA lain) error = %v, want ErrJadwalNotFound", err)
+	}
+}
+
+func TestDescribeJadwal(t *testing.T) {
+	tanggal := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		p    structs.PendaftaranNikah
+		want string
+	}{
+		{structs.PendaftaranNikah{Tanggal_nikah: tanggal, Waktu_nikah: "09:00", Tempat_nikah: "Di KUA"},
+			"Senin, 2 November 2026 pukul 09.00 WITA Di KUA"},
+		{structs.PendaftaranNikah{Tanggal_nikah: tanggal, Waktu_nikah: "13:30", Tempat_nikah: "Di Luar KUA", Alamat_akad: "Jl. Sultan Adam No. 5"},
+			"Senin, 2 November 2026 pukul 13.30 WITA Di Luar KUA (Jl. Sultan Adam No. 5)"},
+	}
+	for _, tt := range tests {
+		if got := describeJadwal(&tt.p, locale.WITA); got != tt.want {
+			t.Errorf("describeJadwal() = %q, want %q", got, tt.want)
+		}
+	}
+}
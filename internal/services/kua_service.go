@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/locale"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrKUANotFound dikembalikan jika KUA tidak ada atau tidak aktif
+	ErrKUANotFound = errors.New("KUA tidak ditemukan")
+	// ErrKUARequired dikembalikan jika KUA tidak bisa ditentukan dan harus dipilih user
+	ErrKUARequired = errors.New("KUA harus dipilih")
+	// ErrPenghuluMasihDitugaskan dikembalikan jika penghulu yang akan dipindahkan masih ditugaskan di pendaftaran aktif
+	ErrPenghuluMasihDitugaskan = errors.New("penghulu masih ditugaskan di pendaftaran yang belum selesai, ganti penghulu pendaftaran tersebut terlebih dahulu")
+)
+
+// KUAService untuk data KUA (tenant) dan penentuan KUA yang menangani user/pendaftaran
+type KUAService struct {
+	DB *gorm.DB
+}
+
+// NewKUAService membuat instance baru dari KUAService
+func NewKUAService(db *gorm.DB) *KUAService {
+	return &KUAService{DB: db}
+}
+
+// TenantScope membatasi query ke data milik satu KUA (kolom kua_id).
+// Dipakai dengan db.Scopes(services.TenantScope(kuaID)).
+func TenantScope(kuaID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("kua_id = ?", kuaID)
+	}
+}
+
+// GetActive mengambil KUA aktif berdasarkan ID
+func (ks *KUAService) GetActive(id uint) (*structs.KUA, error) {
+	var kua structs.KUA
+	if err := ks.DB.Where("id = ? AND status = ?", id, structs.KUAStatusAktif).First(&kua).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrKUANotFound
+		}
+		return nil, err
+	}
+	return &kua, nil
+}
+
+// ListActive mengambil semua KUA aktif, bisa difilter kecamatan/kabupaten (tidak case-sensitive)
+func (ks *KUAService) ListActive(kecamatan, kabupaten string) ([]structs.KUA, error) {
+	query := ks.DB.Where("status = ?", structs.KUAStatusAktif)
+	if kecamatan = strings.TrimSpace(kecamatan); kecamatan != "" {
+		query = query.Where("LOWER(kecamatan) = ?", strings.ToLower(kecamatan))
+	}
+	if kabupaten = strings.TrimSpace(kabupaten); kabupaten != "" {
+		query = query.Where("LOWER(kabupaten) = ?", strings.ToLower(kabupaten))
+	}
+
+	var list []structs.KUA
+	if err := query.Order("kabupaten ASC, kecamatan ASC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Resolve menentukan KUA yang menangani pendaftaran dengan urutan:
+// KUA yang dipilih (kuaID), KUA untuk kecamatan tempat tinggal, KUA user saat ini (fallbackID),
+// lalu satu-satunya KUA aktif. Jika masih tidak bisa ditentukan, mengembalikan ErrKUARequired.
+func (ks *KUAService) Resolve(kuaID uint, kecamatan, kabupaten string, fallbackID uint) (*structs.KUA, error) {
+	if kuaID != 0 {
+		return ks.GetActive(kuaID)
+	}
+
+	if strings.TrimSpace(kecamatan) != "" {
+		list, err := ks.ListActive(kecamatan, kabupaten)
+		if err != nil {
+			return nil, err
+		}
+		switch len(list) {
+		case 0:
+			return nil, ErrKUANotFound
+		case 1:
+			return &list[0], nil
+		default:
+			// Nama kecamatan sama di beberapa kabupaten, user harus memilih
+			return nil, ErrKUARequired
+		}
+	}
+
+	if fallbackID != 0 {
+		if kua, err := ks.GetActive(fallbackID); err == nil {
+			return kua, nil
+		}
+	}
+
+	list, err := ks.ListActive("", "")
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 1 {
+		return &list[0], nil
+	}
+	return nil, ErrKUARequired
+}
+
+// CountActivePenghulu menghitung penghulu aktif di sebuah KUA
+func (ks *KUAService) CountActivePenghulu(kuaID uint) int64 {
+	var count int64
+	ks.DB.Model(&structs.Penghulu{}).Scopes(TenantScope(kuaID)).
+		Where("status = ?", structs.PenghuluStatusAktif).Count(&count)
+	return count
+}
+
+// ZonaWaktu mengembalikan zona waktu lokal KUA (WIB/WITA/WIT) berdasarkan provinsinya.
+// Jam nikah dan bimbingan disimpan dalam waktu lokal KUA; KUA yang tidak ditemukan dianggap WIB.
+func (ks *KUAService) ZonaWaktu(kuaID uint) *time.Location {
+	var kua structs.KUA
+	if err := ks.DB.Select("provinsi").First(&kua, kuaID).Error; err != nil {
+		return locale.WIB
+	}
+	return locale.ZonaProvinsi(kua.Provinsi)
+}
+
+// TanggalLokal mengembalikan tanggal kalender t di zona loc sebagai tengah malam UTC, format yang sama
+// dengan tanggal hasil time.Parse("2006-01-02", ...) sehingga bisa dibandingkan langsung.
+// Mis. 16:30 UTC tanggal 1 sudah tanggal 2 di WITA.
+func TanggalLokal(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// MoveUser memindahkan user (beserta data staff/penghulu-nya) ke KUA lain.
+// Penghulu yang masih ditugaskan di pendaftaran yang belum berstatus akhir tidak bisa dipindahkan
+// (ErrPenghuluMasihDitugaskan) karena pendaftaran tersebut tetap milik KUA lama.
+// Semua sesi user dicabut agar token berikutnya membawa KUA yang baru.
+func (ks *KUAService) MoveUser(userID string, kuaID uint) error {
+	if _, err := ks.GetActive(kuaID); err != nil {
+		return err
+	}
+
+	return ks.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&structs.Users{}).Where("user_id = ?", userID).Update("kua_id", kuaID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var count int64
+			if tx.Model(&structs.Users{}).Where("user_id = ?", userID).Count(&count); count == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+
+		// Penugasan dicek di dalam transaksi dengan baris penghulu dikunci agar tidak ada penugasan baru di antaranya
+		var penghulu structs.Penghulu
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&penghulu).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && penghulu.Kua_id != kuaID {
+			var aktif int64
+			if err := tx.Model(&structs.PendaftaranNikah{}).
+				Where("penghulu_id = ? AND status_pendaftaran NOT IN ?", penghulu.ID, FinalStatuses).
+				Count(&aktif).Error; err != nil {
+				return err
+			}
+			if aktif > 0 {
+				return fmt.Errorf("%w (%d pendaftaran)", ErrPenghuluMasihDitugaskan, aktif)
+			}
+		}
+
+		if err := tx.Model(&structs.StaffKUA{}).Where("user_id = ?", userID).Update("kua_id", kuaID).Error; err != nil {
+			return fmt.Errorf("gagal memindahkan data staff: %v", err)
+		}
+		if err := tx.Model(&structs.Penghulu{}).Where("user_id = ?", userID).Update("kua_id", kuaID).Error; err != nil {
+			return fmt.Errorf("gagal memindahkan data penghulu: %v", err)
+		}
+
+		_, err = revokeAllSessions(tx, userID, structs.SesiAlasanKuaBerubah)
+		return err
+	})
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+)
+
+func TestKUAServiceResolve(t *testing.T) {
+	db := newTestDB(t)
+	ks := NewKUAService(db)
+
+	// Satu-satunya KUA aktif dipakai jika tidak ada petunjuk lain
+	utara := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	if kua, err := ks.Resolve(0, "", "", 0); err != nil || kua.ID != utara.ID {
+		t.Fatalf("Resolve(satu KUA) = %v, %v, want KUA %d", kua, err, utara.ID)
+	}
+
+	selatan := createTestKUA(t, db, "KUA-BJM-SELATAN", "Banjarmasin Selatan", "Kota Banjarmasin", "Kalimantan Selatan")
+	createTestKUA(t, db, "KUA-BJB-SELATAN", "Banjarmasin Selatan", "Kota Banjarbaru", "Kalimantan Selatan")
+	nonaktif := createTestKUA(t, db, "KUA-TUTUP", "Tutup", "Kota Banjarmasin", "Kalimantan Selatan")
+	db.Model(&nonaktif).Update("status", structs.KUAStatusNonaktif)
+
+	tests := []struct {
+		name       string
+		kuaID      uint
+		kecamatan  string
+		kabupaten  string
+		fallbackID uint
+		want       uint
+		wantErr    error
+	}{
+		{"KUA dipilih", utara.ID, "Banjarmasin Selatan", "", 0, utara.ID, nil},
+		{"KUA dipilih nonaktif", nonaktif.ID, "", "", 0, 0, ErrKUANotFound},
+		{"kecamatan tidak peka huruf besar", 0, "banjarmasin UTARA", "", 0, utara.ID, nil},
+		{"kecamatan di beberapa kabupaten", 0, "Banjarmasin Selatan", "", 0, 0, ErrKUARequired},
+		{"kecamatan dan kabupaten", 0, "Banjarmasin Selatan", "Kota Banjarmasin", 0, selatan.ID, nil},
+		{"kecamatan tanpa KUA", 0, "Martapura", "", utara.ID, 0, ErrKUANotFound},
+		{"KUA user saat ini", 0, "", "", selatan.ID, selatan.ID, nil},
+		{"KUA user nonaktif", 0, "", "", nonaktif.ID, 0, ErrKUARequired},
+		{"tidak ada petunjuk", 0, "", "", 0, 0, ErrKUARequired},
+	}
+	for _, tt := range tests {
+		kua, err := ks.Resolve(tt.kuaID, tt.kecamatan, tt.kabupaten, tt.fallbackID)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: Resolve() error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || kua.ID != tt.want {
+			t.Errorf("%s: Resolve() = %v, %v, want KUA %d", tt.name, kua, err, tt.want)
+		}
+	}
+}
+
+func TestKUAServiceMoveUser(t *testing.T) {
+	db := newTestDB(t)
+	ks := NewKUAService(db)
+
+	asal := createTestKUA(t, db, "KUA-ASAL", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	tujuan := createTestKUA(t, db, "KUA-TUJUAN", "Banjarmasin Selatan", "Kota Banjarmasin", "Kalimantan Selatan")
+	penghulu := createTestPenghulu(t, db, "PGH1", asal.ID)
+
+	if err := db.Create(&structs.SesiPengguna{Session_id: "sesi-1", User_id: "PGH1", Refresh_token_hash: "hash-1", Access_jti: "jti-1", Access_kedaluwarsa_pada: time.Now().Add(time.Minute), Kedaluwarsa_pada: time.Now().Add(time.Hour)}).Error; err != nil {
+		t.Fatalf("create sesi: %v", err)
+	}
+
+	// Penugasan aktif menahan penghulu di KUA lama
+	aktif := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             asal.ID,
+		Penghulu_id:        &penghulu.ID,
+		Status_pendaftaran: structs.StatusPendaftaranMenungguBimbingan,
+	})
+	createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             asal.ID,
+		Penghulu_id:        &penghulu.ID,
+		Status_pendaftaran: structs.StatusPendaftaranSelesai,
+	})
+
+	if err := ks.MoveUser("PGH1", tujuan.ID); !errors.Is(err, ErrPenghuluMasihDitugaskan) {
+		t.Fatalf("MoveUser(penugasan aktif) error = %v, want ErrPenghuluMasihDitugaskan", err)
+	}
+	var user structs.Users
+	db.Where("user_id = ?", "PGH1").First(&user)
+	if user.Kua_id != asal.ID {
+		t.Fatalf("user kua_id = %d setelah pemindahan ditolak, want %d", user.Kua_id, asal.ID)
+	}
+
+	// Setelah pendaftaran aktif dibatalkan, penghulu bisa dipindahkan
+	db.Model(&aktif).Update("status_pendaftaran", structs.StatusPendaftaranDibatalkan)
+	if err := ks.MoveUser("PGH1", tujuan.ID); err != nil {
+		t.Fatalf("MoveUser() error = %v", err)
+	}
+
+	db.Where("user_id = ?", "PGH1").First(&user)
+	var moved structs.Penghulu
+	db.First(&moved, penghulu.ID)
+	if user.Kua_id != tujuan.ID || moved.Kua_id != tujuan.ID {
+		t.Errorf("kua_id user = %d, penghulu = %d, want %d", user.Kua_id, moved.Kua_id, tujuan.ID)
+	}
+	var sesi structs.SesiPengguna
+	db.Where("session_id = ?", "sesi-1").First(&sesi)
+	if sesi.Dicabut_pada == nil || sesi.Alasan_dicabut != structs.SesiAlasanKuaBerubah {
+		t.Errorf("sesi setelah pindah KUA = %+v, want dicabut dengan alasan %s", sesi, structs.SesiAlasanKuaBerubah)
+	}
+
+	// Staff tanpa profil penghulu tidak terpengaruh pengecekan penugasan
+	createTestUser(t, db, "STF1", structs.UserRoleStaff, asal.ID)
+	if err := ks.MoveUser("STF1", tujuan.ID); err != nil {
+		t.Fatalf("MoveUser(staff) error = %v", err)
+	}
+
+	if err := ks.MoveUser("TIDAKADA", tujuan.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("MoveUser(user tidak ada) error = %v, want gorm.ErrRecordNotFound", err)
+	}
+	if err := ks.MoveUser("STF1", 999); !errors.Is(err, ErrKUANotFound) {
+		t.Errorf("MoveUser(KUA tidak ada) error = %v, want ErrKUANotFound", err)
+	}
+}
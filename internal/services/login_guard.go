@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/crypto"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// LoginLockoutThreshold adalah jumlah login gagal berturut-turut sebelum akun dikunci
+	LoginLockoutThreshold = 5
+	// LoginLockoutBase adalah durasi kunci pertama; berlipat dua untuk setiap kegagalan berikutnya
+	LoginLockoutBase = time.Minute
+	// LoginLockoutMax adalah durasi kunci maksimum
+	LoginLockoutMax = time.Hour
+)
+
+var (
+	// ErrLoginFailed adalah error seragam untuk username salah, password salah, atau akun terkunci
+	ErrLoginFailed = errors.New("username atau password salah")
+	// ErrAccountInactive dikembalikan jika password benar tetapi akun tidak aktif
+	ErrAccountInactive = errors.New("akun tidak aktif")
+)
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// LoginGuardService memeriksa kredensial login dengan penguncian akun bertahap dan audit trail
+type LoginGuardService struct {
+	DB *gorm.DB
+}
+
+// NewLoginGuardService membuat instance baru dari LoginGuardService
+func NewLoginGuardService(db *gorm.DB) *LoginGuardService {
+	return &LoginGuardService{DB: db}
+}
+
+// Authenticate memverifikasi username & password dan mencatat kegagalan ke LoginAudit.
+// Username tidak ditemukan, password salah, dan akun terkunci semuanya mengembalikan ErrLoginFailed.
+// Login berhasil baru dicatat lewat CompleteLogin setelah faktor kedua (jika ada) lolos.
+func (lg *LoginGuardService) Authenticate(username, password, ipAddress, userAgent string) (*structs.Users, error) {
+	var user structs.Users
+	if err := lg.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		// Samakan waktu respons dengan username yang ada agar username tidak bisa ditebak
+		crypto.VerifyPassword(password, getDummyHash())
+		lg.recordAudit("", username, ipAddress, userAgent, false, structs.LoginAlasanUserTidakDitemukan)
+		return nil, ErrLoginFailed
+	}
+
+	now := time.Now()
+	if user.Terkunci_sampai != nil && now.Before(*user.Terkunci_sampai) {
+		lg.recordAudit(user.User_id, username, ipAddress, userAgent, false, structs.LoginAlasanAkunTerkunci)
+		return nil, ErrLoginFailed
+	}
+
+	if err := crypto.VerifyPassword(password, user.Password); err != nil {
+		lg.registerFailure(&user, now)
+		lg.recordAudit(user.User_id, username, ipAddress, userAgent, false, structs.LoginAlasanPasswordSalah)
+		return nil, ErrLoginFailed
+	}
+
+	if user.Status != structs.UserStatusAktif {
+		lg.recordAudit(user.User_id, username, ipAddress, userAgent, false, structs.LoginAlasanAkunTidakAktif)
+		return nil, ErrAccountInactive
+	}
+
+	return &user, nil
+}
+
+// CompleteLogin dipanggil setelah semua faktor login lolos (password, dan kode 2FA jika aktif):
+// mereset penghitung login gagal dan mencatat login berhasil.
+func (lg *LoginGuardService) CompleteLogin(user *structs.Users, ipAddress, userAgent string) {
+	if user.Gagal_login > 0 || user.Terkunci_sampai != nil {
+		if err := lg.DB.Model(&structs.Users{}).Where("user_id = ?", user.User_id).Updates(map[string]interface{}{
+			"gagal_login":     0,
+			"terkunci_sampai": nil,
+		}).Error; err != nil {
+			log.Printf("Gagal mereset penghitung login gagal %s: %v", user.User_id, err)
+		}
+	}
+
+	lg.recordAudit(user.User_id, user.Username, ipAddress, userAgent, true, structs.LoginAlasanBerhasil)
+}
+
+// CheckSecondFactorAllowed memastikan akun tidak sedang terkunci sebelum memeriksa kode 2FA
+func (lg *LoginGuardService) CheckSecondFactorAllowed(user *structs.Users, ipAddress, userAgent string) error {
+	if user.Terkunci_sampai != nil && time.Now().Before(*user.Terkunci_sampai) {
+		lg.recordAudit(user.User_id, user.Username, ipAddress, userAgent, false, structs.LoginAlasanAkunTerkunci)
+		return ErrLoginFailed
+	}
+	return nil
+}
+
+// RegisterSecondFactorFailure mencatat kode 2FA yang salah; dihitung sama seperti password salah
+func (lg *LoginGuardService) RegisterSecondFactorFailure(user *structs.Users, ipAddress, userAgent string) {
+	lg.registerFailure(user, time.Now())
+	lg.recordAudit(user.User_id, user.Username, ipAddress, userAgent, false, structs.LoginAlasanKodeMfaSalah)
+}
+
+// UnlockAccount membuka kunci akun dan mereset penghitung login gagal
+func (lg *LoginGuardService) UnlockAccount(userID string) error {
+	result := lg.DB.Model(&structs.Users{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+		"gagal_login":     0,
+		"terkunci_sampai": nil,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// LockoutDuration menghitung durasi kunci untuk jumlah login gagal berturut-turut
+func LockoutDuration(failures int) time.Duration {
+	if failures < LoginLockoutThreshold {
+		return 0
+	}
+	duration := LoginLockoutBase
+	for i := LoginLockoutThreshold; i < failures; i++ {
+		duration *= 2
+		if duration >= LoginLockoutMax {
+			return LoginLockoutMax
+		}
+	}
+	return duration
+}
+
+func (lg *LoginGuardService) registerFailure(user *structs.Users, now time.Time) {
+	// Increment atomik agar percobaan paralel tetap terhitung semua
+	if err := lg.DB.Model(&structs.Users{}).Where("user_id = ?", user.User_id).
+		Update("gagal_login", gorm.Expr("gagal_login + 1")).Error; err != nil {
+		log.Printf("Gagal mencatat login gagal %s: %v", user.User_id, err)
+		return
+	}
+
+	var failures int
+	lg.DB.Model(&structs.Users{}).Where("user_id = ?", user.User_id).Select("gagal_login").Scan(&failures)
+
+	if lockFor := LockoutDuration(failures); lockFor > 0 {
+		lockedUntil := now.Add(lockFor)
+		if err := lg.DB.Model(&structs.Users{}).Where("user_id = ?", user.User_id).
+			Update("terkunci_sampai", lockedUntil).Error; err != nil {
+			log.Printf("Gagal mengunci akun %s: %v", user.User_id, err)
+		}
+	}
+}
+
+func (lg *LoginGuardService) recordAudit(userID, username, ipAddress, userAgent string, berhasil bool, alasan string) {
+	if len(username) > 50 {
+		username = username[:50]
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	audit := structs.LoginAudit{
+		User_id:    userID,
+		Username:   username,
+		Ip_address: ipAddress,
+		User_agent: userAgent,
+		Berhasil:   berhasil,
+		Alasan:     alasan,
+		Created_at: time.Now(),
+	}
+	if err := lg.DB.Create(&audit).Error; err != nil {
+		log.Printf("Gagal mencatat audit login: %v", err)
+	}
+}
+
+// getDummyHash membuat hash bcrypt sekali untuk menyamakan waktu respons username yang tidak ada
+func getDummyHash() string {
+	dummyHashOnce.Do(func() {
+		hash, err := crypto.HashPassword("simnikah-dummy-password")
+		if err == nil {
+			dummyHash = hash
+		}
+	})
+	return dummyHash
+}
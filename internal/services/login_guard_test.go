@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{LoginLockoutThreshold - 1, 0},
+		{LoginLockoutThreshold, time.Minute},
+		{LoginLockoutThreshold + 1, 2 * time.Minute},
+		{LoginLockoutThreshold + 2, 4 * time.Minute},
+		{LoginLockoutThreshold + 5, 32 * time.Minute},
+		{LoginLockoutThreshold + 6, LoginLockoutMax},
+		{LoginLockoutThreshold + 100, LoginLockoutMax},
+	}
+
+	for _, tt := range tests {
+		if got := LockoutDuration(tt.failures); got != tt.want {
+			t.Errorf("LockoutDuration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginGuardLockout(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "STF1", structs.UserRoleStaff, 1)
+	setTestPassword(t, db, user.User_id, "rahasia123")
+	lg := NewLoginGuardService(db)
+
+	if _, err := lg.Authenticate("tidak-ada", "rahasia123", "127.0.0.1", "uji"); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("Authenticate(username tidak ada) error = %v, want ErrLoginFailed", err)
+	}
+
+	for i := 0; i < LoginLockoutThreshold; i++ {
+		if _, err := lg.Authenticate(user.Username, "salah", "127.0.0.1", "uji"); !errors.Is(err, ErrLoginFailed) {
+			t.Fatalf("Authenticate(password salah) error = %v, want ErrLoginFailed", err)
+		}
+	}
+	var saved structs.Users
+	db.Where("user_id = ?", user.User_id).First(&saved)
+	if saved.Gagal_login != LoginLockoutThreshold || saved.Terkunci_sampai == nil {
+		t.Fatalf("gagal_login = %d, terkunci_sampai = %v, want terkunci", saved.Gagal_login, saved.Terkunci_sampai)
+	}
+	if sisa := time.Until(*saved.Terkunci_sampai); sisa <= 0 || sisa > LoginLockoutBase {
+		t.Errorf("terkunci selama %v, want paling lama %v", sisa, LoginLockoutBase)
+	}
+
+	// Password benar saat terkunci tetap ditolak dengan error yang sama
+	if _, err := lg.Authenticate(user.Username, "rahasia123", "127.0.0.1", "uji"); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("Authenticate(terkunci) error = %v, want ErrLoginFailed", err)
+	}
+	if err := lg.CheckSecondFactorAllowed(&saved, "127.0.0.1", "uji"); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("CheckSecondFactorAllowed(terkunci) error = %v, want ErrLoginFailed", err)
+	}
+
+	var audits []structs.LoginAudit
+	db.Order("id").Find(&audits)
+	want := []string{structs.LoginAlasanUserTidakDitemukan}
+	for i := 0; i < LoginLockoutThreshold; i++ {
+		want = append(want, structs.LoginAlasanPasswordSalah)
+	}
+	want = append(want, structs.LoginAlasanAkunTerkunci, structs.LoginAlasanAkunTerkunci)
+	if len(audits) != len(want) {
+		t.Fatalf("audit login = %d baris, want %d", len(audits), len(want))
+	}
+	for i, a := range audits {
+		if a.Alasan != want[i] || a.Berhasil {
+			t.Errorf("audit[%d] = %s berhasil %v, want %s gagal", i, a.Alasan, a.Berhasil, want[i])
+		}
+	}
+
+	if err := lg.UnlockAccount(user.User_id); err != nil {
+		t.Fatalf("UnlockAccount() error = %v", err)
+	}
+	if err := lg.UnlockAccount("TIDAKADA"); err == nil {
+		t.Error("UnlockAccount(user tidak ada) error = nil")
+	}
+	got, err := lg.Authenticate(user.Username, "rahasia123", "127.0.0.1", "uji")
+	if err != nil {
+		t.Fatalf("Authenticate(setelah dibuka) error = %v", err)
+	}
+	if got.Gagal_login != 0 || got.Terkunci_sampai != nil {
+		t.Errorf("penghitung setelah dibuka = %d, %v", got.Gagal_login, got.Terkunci_sampai)
+	}
+}
+
+func TestLoginGuardCompleteLogin(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "PGH1", structs.UserRolePenghulu, 1)
+	setTestPassword(t, db, user.User_id, "rahasia123")
+	lg := NewLoginGuardService(db)
+
+	lg.Authenticate(user.Username, "salah", "127.0.0.1", "uji")
+	authed, err := lg.Authenticate(user.Username, "rahasia123", "127.0.0.1", "uji")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if authed.Gagal_login != 1 {
+		t.Errorf("gagal_login sebelum CompleteLogin = %d, want 1", authed.Gagal_login)
+	}
+
+	// Kode 2FA salah dihitung seperti password salah
+	lg.RegisterSecondFactorFailure(authed, "127.0.0.1", "uji")
+	var saved structs.Users
+	db.Where("user_id = ?", user.User_id).First(&saved)
+	if saved.Gagal_login != 2 {
+		t.Errorf("gagal_login setelah kode 2FA salah = %d, want 2", saved.Gagal_login)
+	}
+
+	lg.CompleteLogin(&saved, "127.0.0.1", "uji")
+	db.Where("user_id = ?", user.User_id).First(&saved)
+	if saved.Gagal_login != 0 {
+		t.Errorf("gagal_login setelah CompleteLogin = %d, want 0", saved.Gagal_login)
+	}
+	var last structs.LoginAudit
+	db.Order("id DESC").First(&last)
+	if !last.Berhasil || last.Alasan != structs.LoginAlasanBerhasil || last.User_id != user.User_id {
+		t.Errorf("audit terakhir = %+v, want login berhasil", last)
+	}
+
+	// Akun nonaktif: password benar tetapi ditolak dengan error berbeda
+	db.Model(&structs.Users{}).Where("user_id = ?", user.User_id).Update("status", structs.UserStatusNonaktif)
+	if _, err := lg.Authenticate(user.Username, "rahasia123", "127.0.0.1", "uji"); !errors.Is(err, ErrAccountInactive) {
+		t.Errorf("Authenticate(nonaktif) error = %v, want ErrAccountInactive", err)
+	}
+	var nonaktif structs.LoginAudit
+	db.Order("id DESC").First(&nonaktif)
+	if nonaktif.Alasan != structs.LoginAlasanAkunTidakAktif {
+		t.Errorf("audit terakhir = %s, want %s", nonaktif.Alasan, structs.LoginAlasanAkunTidakAktif)
+	}
+}
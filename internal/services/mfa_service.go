@@ -0,0 +1,374 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/crypto"
+	"simnikah/pkg/totp"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MfaIssuer adalah nama aplikasi yang tampil di authenticator
+	MfaIssuer = "SimNikah KUA"
+	// MfaRecoveryCodeCount adalah jumlah kode pemulihan yang dibuat sekaligus
+	MfaRecoveryCodeCount = 10
+	// mfaSkew adalah toleransi langkah waktu TOTP (±30 detik)
+	mfaSkew = 1
+)
+
+var (
+	// ErrMfaNotEnrolled dikembalikan jika user belum memulai/menyelesaikan pendaftaran 2FA
+	ErrMfaNotEnrolled = errors.New("2FA belum diaktifkan")
+	// ErrMfaAlreadyActive dikembalikan jika user mendaftar ulang saat 2FA masih aktif
+	ErrMfaAlreadyActive = errors.New("2FA sudah aktif")
+	// ErrMfaCodeInvalid dikembalikan jika kode TOTP atau kode pemulihan salah/sudah dipakai
+	ErrMfaCodeInvalid = errors.New("kode 2FA tidak valid")
+	// ErrMfaRequired dikembalikan jika 2FA tidak boleh dinonaktifkan karena diwajibkan untuk role user
+	ErrMfaRequired = errors.New("2FA wajib untuk role ini dan tidak dapat dinonaktifkan")
+	// ErrMfaRoleNotAllowed dikembalikan jika role user tidak mendukung 2FA
+	ErrMfaRoleNotAllowed = errors.New("2FA hanya tersedia untuk staff, penghulu, dan kepala KUA")
+)
+
+// mfaRoles adalah role yang bisa memakai 2FA. Kewajiban 2FA diatur per role dan per KUA
+// (lihat MandatoryRoles), nilainya di sini adalah default jika KUA belum mengaturnya.
+var mfaRoles = map[string]bool{
+	structs.UserRoleStaff:     false,
+	structs.UserRolePenghulu:  false,
+	structs.UserRoleKepalaKUA: false,
+}
+
+// MfaService untuk pendaftaran dan verifikasi TOTP 2FA serta kode pemulihan
+type MfaService struct {
+	DB        *gorm.DB
+	secretKey []byte
+}
+
+// NewMfaService membuat instance baru dari MfaService.
+// Secret TOTP dienkripsi dengan kunci dari MFA_SECRET_KEY (fallback ke JWT_KEY).
+func NewMfaService(db *gorm.DB) *MfaService {
+	return &MfaService{DB: db, secretKey: crypto.DeriveKey(string(SecretKey(EnvMfaSecretKey)))}
+}
+
+// RoleSupportsMfa mengecek apakah role boleh memakai 2FA
+func RoleSupportsMfa(role string) bool {
+	_, ok := mfaRoles[role]
+	return ok
+}
+
+// MfaRoles mengembalikan role yang bisa memakai 2FA
+func MfaRoles() []string {
+	return []string{structs.UserRoleStaff, structs.UserRolePenghulu, structs.UserRoleKepalaKUA}
+}
+
+// mfaSettingKey adalah kunci pengaturan 2FA wajib untuk satu role, mis. "mfa_wajib.penghulu"
+func mfaSettingKey(role string) string {
+	return structs.PengaturanMfaWajib + "." + role
+}
+
+// IsRequiredFor mengecek apakah 2FA wajib untuk role tertentu di sebuah KUA. Pengaturan lama tanpa role
+// ("mfa_wajib") berlaku untuk semua role sampai role tersebut diatur sendiri.
+func (ms *MfaService) IsRequiredFor(kuaID uint, role string) bool {
+	def, ok := mfaRoles[role]
+	if !ok {
+		return false
+	}
+	legacy, err := GetSetting(ms.DB, kuaID, structs.PengaturanMfaWajib, fmt.Sprintf("%t", def))
+	if err != nil {
+		return false
+	}
+	value, err := GetSetting(ms.DB, kuaID, mfaSettingKey(role), legacy)
+	return err == nil && value == "true"
+}
+
+// MandatoryRoles mengembalikan status 2FA wajib setiap role di sebuah KUA
+func (ms *MfaService) MandatoryRoles(kuaID uint) map[string]bool {
+	result := make(map[string]bool, len(mfaRoles))
+	for role := range mfaRoles {
+		result[role] = ms.IsRequiredFor(kuaID, role)
+	}
+	return result
+}
+
+// SetMandatory mewajibkan atau membebaskan 2FA untuk satu role di sebuah KUA
+func (ms *MfaService) SetMandatory(kuaID uint, role string, wajib bool, diubahOleh string) error {
+	if !RoleSupportsMfa(role) {
+		return ErrMfaRoleNotAllowed
+	}
+	return SetSetting(ms.DB, kuaID, mfaSettingKey(role), fmt.Sprintf("%t", wajib), diubahOleh)
+}
+
+// sealSecret mengenkripsi secret TOTP sebelum disimpan
+func (ms *MfaService) sealSecret(secret string) (string, error) {
+	return crypto.EncryptString(ms.secretKey, secret)
+}
+
+// openSecret membuka secret TOTP tersimpan. Secret lama yang belum dienkripsi dikembalikan apa adanya.
+func (ms *MfaService) openSecret(stored string) (string, error) {
+	if !crypto.IsEncrypted(stored) {
+		return stored, nil
+	}
+	return crypto.DecryptString(ms.secretKey, stored)
+}
+
+// EncryptStoredSecrets mengenkripsi secret TOTP lama yang masih tersimpan sebagai plaintext.
+// Dijalankan saat startup; mengembalikan jumlah secret yang dienkripsi.
+func (ms *MfaService) EncryptStoredSecrets() (int, error) {
+	var rows []structs.MfaPengguna
+	if err := ms.DB.Select("id", "secret").Where("secret NOT LIKE ?", "v1:%").Find(&rows).Error; err != nil {
+		return 0, err
+	}
+	for _, row := range rows {
+		sealed, err := ms.sealSecret(row.Secret)
+		if err != nil {
+			return 0, err
+		}
+		if err := ms.DB.Model(&structs.MfaPengguna{}).Where("id = ? AND secret = ?", row.ID, row.Secret).
+			Update("secret", sealed).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), nil
+}
+
+// IsEnabled mengecek apakah user sudah mengaktifkan 2FA
+func (ms *MfaService) IsEnabled(userID string) bool {
+	var count int64
+	ms.DB.Model(&structs.MfaPengguna{}).Where("user_id = ? AND aktif = ?", userID, true).Count(&count)
+	return count > 0
+}
+
+// BeginEnrollment membuat secret baru (belum aktif) dan mengembalikan secret serta otpauth URI
+func (ms *MfaService) BeginEnrollment(user *structs.Users) (string, string, error) {
+	if !RoleSupportsMfa(user.Role) {
+		return "", "", ErrMfaRoleNotAllowed
+	}
+	if ms.IsEnabled(user.User_id) {
+		return "", "", ErrMfaAlreadyActive
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", fmt.Errorf("gagal membuat secret 2FA: %v", err)
+	}
+
+	sealed, err := ms.sealSecret(secret)
+	if err != nil {
+		return "", "", fmt.Errorf("gagal mengenkripsi secret 2FA: %v", err)
+	}
+
+	now := time.Now()
+	mfa := structs.MfaPengguna{
+		User_id:    user.User_id,
+		Secret:     sealed,
+		Aktif:      false,
+		Created_at: now,
+		Updated_at: now,
+	}
+	if err := ms.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "aktif", "terakhir_step", "updated_at"}),
+	}).Create(&mfa).Error; err != nil {
+		return "", "", fmt.Errorf("gagal menyimpan secret 2FA: %v", err)
+	}
+
+	return secret, totp.URI(MfaIssuer, user.Username, secret), nil
+}
+
+// ConfirmEnrollment mengaktifkan 2FA setelah user memasukkan kode pertama dari authenticator.
+// Mengembalikan kode pemulihan mentah (hanya ditampilkan sekali).
+func (ms *MfaService) ConfirmEnrollment(userID, code string) ([]string, error) {
+	var codes []string
+	err := ms.DB.Transaction(func(tx *gorm.DB) error {
+		var mfa structs.MfaPengguna
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&mfa).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMfaNotEnrolled
+			}
+			return err
+		}
+		if mfa.Aktif {
+			return ErrMfaAlreadyActive
+		}
+
+		secret, err := ms.openSecret(mfa.Secret)
+		if err != nil {
+			return fmt.Errorf("gagal membaca secret 2FA: %v", err)
+		}
+		step, ok := totp.Validate(secret, code, time.Now(), mfaSkew)
+		if !ok {
+			return ErrMfaCodeInvalid
+		}
+
+		now := time.Now()
+		if err := tx.Model(&mfa).Updates(map[string]interface{}{
+			"aktif":           true,
+			"terakhir_step":   step,
+			"diaktifkan_pada": now,
+			"updated_at":      now,
+		}).Error; err != nil {
+			return fmt.Errorf("gagal mengaktifkan 2FA: %v", err)
+		}
+
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyCode memverifikasi kode TOTP. Kode yang sudah pernah dipakai (langkah yang sama atau lebih lama) ditolak.
+func (ms *MfaService) VerifyCode(userID, code string) error {
+	return ms.DB.Transaction(func(tx *gorm.DB) error {
+		var mfa structs.MfaPengguna
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND aktif = ?", userID, true).First(&mfa).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMfaNotEnrolled
+			}
+			return err
+		}
+
+		secret, err := ms.openSecret(mfa.Secret)
+		if err != nil {
+			return fmt.Errorf("gagal membaca secret 2FA: %v", err)
+		}
+		step, ok := totp.Validate(secret, code, time.Now(), mfaSkew)
+		if !ok || step <= mfa.Terakhir_step {
+			return ErrMfaCodeInvalid
+		}
+
+		return tx.Model(&mfa).Updates(map[string]interface{}{
+			"terakhir_step": step,
+			"updated_at":    time.Now(),
+		}).Error
+	})
+}
+
+// UseRecoveryCode memakai satu kode pemulihan (sekali pakai)
+func (ms *MfaService) UseRecoveryCode(userID, code string) error {
+	codeHash := hashToken(normalizeRecoveryCode(code))
+
+	var kode structs.KodePemulihanMfa
+	if err := ms.DB.Where("user_id = ? AND kode_hash = ? AND digunakan_pada IS NULL", userID, codeHash).First(&kode).Error; err != nil {
+		return ErrMfaCodeInvalid
+	}
+
+	result := ms.DB.Model(&structs.KodePemulihanMfa{}).
+		Where("id = ? AND digunakan_pada IS NULL", kode.ID).
+		Update("digunakan_pada", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMfaCodeInvalid
+	}
+	return nil
+}
+
+// VerifySecondFactor menerima kode TOTP atau kode pemulihan
+func (ms *MfaService) VerifySecondFactor(userID, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		return ms.UseRecoveryCode(userID, recoveryCode)
+	}
+	return ms.VerifyCode(userID, code)
+}
+
+// RegenerateRecoveryCodes mengganti semua kode pemulihan; kode lama tidak berlaku lagi
+func (ms *MfaService) RegenerateRecoveryCodes(userID string) ([]string, error) {
+	if !ms.IsEnabled(userID) {
+		return nil, ErrMfaNotEnrolled
+	}
+
+	var codes []string
+	err := ms.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// RemainingRecoveryCodes menghitung kode pemulihan yang belum dipakai
+func (ms *MfaService) RemainingRecoveryCodes(userID string) int64 {
+	var count int64
+	ms.DB.Model(&structs.KodePemulihanMfa{}).Where("user_id = ? AND digunakan_pada IS NULL", userID).Count(&count)
+	return count
+}
+
+// Disable menonaktifkan 2FA dan menghapus kode pemulihan. Ditolak jika 2FA wajib untuk role user.
+func (ms *MfaService) Disable(user *structs.Users) error {
+	if ms.IsRequiredFor(user.Kua_id, user.Role) {
+		return ErrMfaRequired
+	}
+
+	return ms.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.User_id).Delete(&structs.MfaPengguna{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.User_id).Delete(&structs.KodePemulihanMfa{}).Error
+	})
+}
+
+// Reset menghapus 2FA user tanpa syarat (dipakai Kepala KUA jika user kehilangan authenticator)
+func (ms *MfaService) Reset(userID string) error {
+	return ms.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&structs.MfaPengguna{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&structs.KodePemulihanMfa{}).Error
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&structs.KodePemulihanMfa{}).Error; err != nil {
+		return nil, fmt.Errorf("gagal menghapus kode pemulihan lama: %v", err)
+	}
+
+	now := time.Now()
+	codes := make([]string, 0, MfaRecoveryCodeCount)
+	records := make([]structs.KodePemulihanMfa, 0, MfaRecoveryCodeCount)
+	for i := 0; i < MfaRecoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("gagal membuat kode pemulihan: %v", err)
+		}
+		codes = append(codes, code)
+		records = append(records, structs.KodePemulihanMfa{
+			User_id:    userID,
+			Kode_hash:  hashToken(normalizeRecoveryCode(code)),
+			Created_at: now,
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("gagal menyimpan kode pemulihan: %v", err)
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode membuat kode pemulihan 10 karakter base32 (50 bit) dengan format XXXXX-XXXXX
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)[:10]
+	return raw[:5] + "-" + raw[5:], nil
+}
+
+// normalizeRecoveryCode menghapus tanda hubung/spasi dan menyeragamkan huruf besar
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
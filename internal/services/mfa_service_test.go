@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/crypto"
+	"simnikah/pkg/totp"
+)
+
+func TestMfaEnrollAndVerify(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "STF1", structs.UserRoleStaff, 1)
+	ms := NewMfaService(db)
+
+	secret, uri, err := ms.BeginEnrollment(&user)
+	if err != nil {
+		t.Fatalf("BeginEnrollment() error = %v", err)
+	}
+	if !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("otpauth URI %q tidak memuat secret", uri)
+	}
+
+	var stored structs.MfaPengguna
+	db.Where("user_id = ?", user.User_id).First(&stored)
+	if stored.Secret == secret || !crypto.IsEncrypted(stored.Secret) {
+		t.Errorf("secret tersimpan = %q, want terenkripsi", stored.Secret)
+	}
+	if ms.IsEnabled(user.User_id) {
+		t.Error("IsEnabled() = true sebelum konfirmasi")
+	}
+
+	if _, err := ms.ConfirmEnrollment(user.User_id, "000000"); !errors.Is(err, ErrMfaCodeInvalid) {
+		t.Errorf("ConfirmEnrollment(kode salah) error = %v, want ErrMfaCodeInvalid", err)
+	}
+
+	step := totp.Step(time.Now())
+	code, _ := totp.CodeAt(secret, step)
+	codes, err := ms.ConfirmEnrollment(user.User_id, code)
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment() error = %v", err)
+	}
+	if len(codes) != MfaRecoveryCodeCount || !ms.IsEnabled(user.User_id) {
+		t.Errorf("ConfirmEnrollment() = %d kode pemulihan, aktif %v", len(codes), ms.IsEnabled(user.User_id))
+	}
+	if _, _, err := ms.BeginEnrollment(&user); !errors.Is(err, ErrMfaAlreadyActive) {
+		t.Errorf("BeginEnrollment(sudah aktif) error = %v, want ErrMfaAlreadyActive", err)
+	}
+
+	// Kode yang dipakai saat aktivasi tidak bisa dipakai ulang untuk login
+	if err := ms.VerifyCode(user.User_id, code); !errors.Is(err, ErrMfaCodeInvalid) {
+		t.Errorf("VerifyCode(kode aktivasi) error = %v, want ErrMfaCodeInvalid", err)
+	}
+	next, _ := totp.CodeAt(secret, step+1)
+	if err := ms.VerifyCode(user.User_id, next); err != nil {
+		t.Fatalf("VerifyCode(langkah berikutnya) error = %v", err)
+	}
+	if err := ms.VerifyCode(user.User_id, next); !errors.Is(err, ErrMfaCodeInvalid) {
+		t.Errorf("VerifyCode(dipakai ulang) error = %v, want ErrMfaCodeInvalid", err)
+	}
+}
+
+func TestMfaRecoveryCodes(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "PGH1", structs.UserRolePenghulu, 1)
+	ms := NewMfaService(db)
+
+	secret, _, err := ms.BeginEnrollment(&user)
+	if err != nil {
+		t.Fatalf("BeginEnrollment() error = %v", err)
+	}
+	code, _ := totp.CodeAt(secret, totp.Step(time.Now()))
+	codes, err := ms.ConfirmEnrollment(user.User_id, code)
+	if err != nil {
+		t.Fatalf("ConfirmEnrollment() error = %v", err)
+	}
+
+	if err := ms.VerifySecondFactor(user.User_id, "", codes[0]); err != nil {
+		t.Fatalf("VerifySecondFactor(kode pemulihan) error = %v", err)
+	}
+	if err := ms.UseRecoveryCode(user.User_id, codes[0]); !errors.Is(err, ErrMfaCodeInvalid) {
+		t.Errorf("UseRecoveryCode(dipakai ulang) error = %v, want ErrMfaCodeInvalid", err)
+	}
+	// Huruf kecil tanpa tanda hubung tetap diterima
+	if err := ms.UseRecoveryCode(user.User_id, strings.ToLower(strings.ReplaceAll(codes[1], "-", ""))); err != nil {
+		t.Errorf("UseRecoveryCode(dinormalisasi) error = %v", err)
+	}
+	if n := ms.RemainingRecoveryCodes(user.User_id); n != MfaRecoveryCodeCount-2 {
+		t.Errorf("RemainingRecoveryCodes() = %d, want %d", n, MfaRecoveryCodeCount-2)
+	}
+
+	baru, err := ms.RegenerateRecoveryCodes(user.User_id)
+	if err != nil || len(baru) != MfaRecoveryCodeCount {
+		t.Fatalf("RegenerateRecoveryCodes() = %d kode, %v", len(baru), err)
+	}
+	if err := ms.UseRecoveryCode(user.User_id, codes[2]); !errors.Is(err, ErrMfaCodeInvalid) {
+		t.Errorf("UseRecoveryCode(kode lama) error = %v, want ErrMfaCodeInvalid", err)
+	}
+	if err := ms.UseRecoveryCode(user.User_id, baru[0]); err != nil {
+		t.Errorf("UseRecoveryCode(kode baru) error = %v", err)
+	}
+}
+
+func TestMfaEncryptStoredSecrets(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "STF1", structs.UserRoleStaff, 1)
+	ms := NewMfaService(db)
+
+	// Secret plaintext dari sebelum enkripsi diterapkan
+	secret, _ := totp.GenerateSecret()
+	now := time.Now()
+	if err := db.Create(&structs.MfaPengguna{User_id: user.User_id, Secret: secret, Aktif: true, Diaktifkan_pada: &now, Created_at: now, Updated_at: now}).Error; err != nil {
+		t.Fatalf("create mfa: %v", err)
+	}
+	step := totp.Step(now)
+	code, _ := totp.CodeAt(secret, step)
+	if err := ms.VerifyCode(user.User_id, code); err != nil {
+		t.Fatalf("VerifyCode(secret lama) error = %v", err)
+	}
+
+	n, err := ms.EncryptStoredSecrets()
+	if err != nil || n != 1 {
+		t.Fatalf("EncryptStoredSecrets() = %d, %v, want 1", n, err)
+	}
+	var stored structs.MfaPengguna
+	db.Where("user_id = ?", user.User_id).First(&stored)
+	if !crypto.IsEncrypted(stored.Secret) {
+		t.Errorf("secret tersimpan = %q, want terenkripsi", stored.Secret)
+	}
+	next, _ := totp.CodeAt(secret, step+1)
+	if err := ms.VerifyCode(user.User_id, next); err != nil {
+		t.Errorf("VerifyCode(setelah enkripsi) error = %v", err)
+	}
+	if n, _ := ms.EncryptStoredSecrets(); n != 0 {
+		t.Errorf("EncryptStoredSecrets() kedua = %d, want 0", n)
+	}
+}
+
+func TestMfaMandatoryPerRole(t *testing.T) {
+	db := newTestDB(t)
+	ms := NewMfaService(db)
+
+	if err := ms.SetMandatory(1, structs.UserRolePenghulu, true, "KPL1"); err != nil {
+		t.Fatalf("SetMandatory() error = %v", err)
+	}
+	if err := ms.SetMandatory(1, structs.UserRoleUserBiasa, true, "KPL1"); !errors.Is(err, ErrMfaRoleNotAllowed) {
+		t.Errorf("SetMandatory(user_biasa) error = %v, want ErrMfaRoleNotAllowed", err)
+	}
+
+	tests := []struct {
+		kuaID uint
+		role  string
+		want  bool
+	}{
+		{1, structs.UserRolePenghulu, true},
+		{1, structs.UserRoleStaff, false},
+		{1, structs.UserRoleKepalaKUA, false},
+		{1, structs.UserRoleUserBiasa, false},
+		{2, structs.UserRolePenghulu, false},
+	}
+	for _, tt := range tests {
+		if got := ms.IsRequiredFor(tt.kuaID, tt.role); got != tt.want {
+			t.Errorf("IsRequiredFor(%d, %s) = %v, want %v", tt.kuaID, tt.role, got, tt.want)
+		}
+	}
+
+	// Pengaturan lama tanpa role berlaku untuk role yang belum diatur sendiri
+	if err := SetSetting(db, 2, structs.PengaturanMfaWajib, "true", "KPL2"); err != nil {
+		t.Fatalf("SetSetting() error = %v", err)
+	}
+	if err := ms.SetMandatory(2, structs.UserRoleStaff, false, "KPL2"); err != nil {
+		t.Fatalf("SetMandatory() error = %v", err)
+	}
+	want := map[string]bool{structs.UserRoleStaff: false, structs.UserRolePenghulu: true, structs.UserRoleKepalaKUA: true}
+	got := ms.MandatoryRoles(2)
+	for role, w := range want {
+		if got[role] != w {
+			t.Errorf("MandatoryRoles(2)[%s] = %v, want %v", role, got[role], w)
+		}
+	}
+
+	// 2FA yang diwajibkan tidak bisa dinonaktifkan sendiri
+	penghulu := createTestUser(t, db, "PGH1", structs.UserRolePenghulu, 1)
+	if err := ms.Disable(&penghulu); !errors.Is(err, ErrMfaRequired) {
+		t.Errorf("Disable(penghulu wajib) error = %v, want ErrMfaRequired", err)
+	}
+	staff := createTestUser(t, db, "STF1", structs.UserRoleStaff, 1)
+	if err := ms.Disable(&staff); err != nil {
+		t.Errorf("Disable(staff tidak wajib) error = %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	structs "simnikah/internal/models"
+	"simnikah/pkg/locale"
 
 	"gorm.io/gorm"
 )
@@ -166,29 +167,28 @@ func (ns *NotificationService) SendBimbinganNotification(bimbinganID uint, actio
 
 	var tipe string
 	var judul, pesan string
+	zona := NewKUAService(ns.DB).ZonaWaktu(bimbingan.Kua_id)
 
 	switch action {
 	case "created":
 		tipe = structs.NotifikasiTipeInfo
 		judul = "Bimbingan Perkawinan Baru"
-		pesan = fmt.Sprintf("Bimbingan perkawinan baru telah dijadwalkan pada %s pukul %s - %s di %s.",
-			bimbingan.Tanggal_bimbingan.Format("02 Januari 2006"),
-			bimbingan.Waktu_mulai,
-			bimbingan.Waktu_selesai,
+		pesan = fmt.Sprintf("Bimbingan perkawinan baru telah dijadwalkan pada %s pukul %s di %s.",
+			locale.HariTanggal(bimbingan.Tanggal_bimbingan),
+			locale.RentangJam(bimbingan.Waktu_mulai, bimbingan.Waktu_selesai, zona),
 			bimbingan.Tempat_bimbingan)
 	case "updated":
 		tipe = structs.NotifikasiTipeWarning
 		judul = "Update Jadwal Bimbingan Perkawinan"
-		pesan = fmt.Sprintf("Jadwal bimbingan perkawinan telah diubah. Tanggal: %s, Waktu: %s - %s, Tempat: %s.",
-			bimbingan.Tanggal_bimbingan.Format("02 Januari 2006"),
-			bimbingan.Waktu_mulai,
-			bimbingan.Waktu_selesai,
+		pesan = fmt.Sprintf("Jadwal bimbingan perkawinan telah diubah. Tanggal: %s, Waktu: %s, Tempat: %s.",
+			locale.HariTanggal(bimbingan.Tanggal_bimbingan),
+			locale.RentangJam(bimbingan.Waktu_mulai, bimbingan.Waktu_selesai, zona),
 			bimbingan.Tempat_bimbingan)
 	case "cancelled":
 		tipe = structs.NotifikasiTipeError
 		judul = "Bimbingan Perkawinan Dibatalkan"
 		pesan = fmt.Sprintf("Bimbingan perkawinan pada %s telah dibatalkan. Silakan hubungi KUA untuk informasi lebih lanjut.",
-			bimbingan.Tanggal_bimbingan.Format("02 Januari 2006"))
+			locale.HariTanggal(bimbingan.Tanggal_bimbingan))
 	}
 
 	// Kirim notifikasi ke semua peserta
@@ -253,8 +253,8 @@ func (ns *NotificationService) SendPenghuluAssignmentNotification(pendaftaranID
 		Pesan: fmt.Sprintf("Anda ditugaskan untuk memimpin nikah %s dan %s pada %s pukul %s di %s.",
 			calonSuami.Nama_lengkap,
 			calonIstri.Nama_lengkap,
-			pendaftaran.Tanggal_nikah.Format("02 Januari 2006"),
-			pendaftaran.Waktu_nikah,
+			locale.HariTanggal(pendaftaran.Tanggal_nikah),
+			locale.FormatJam(pendaftaran.Waktu_nikah, NewKUAService(ns.DB).ZonaWaktu(pendaftaran.Kua_id)),
 			pendaftaran.Tempat_nikah),
 		Tipe:        structs.NotifikasiTipeInfo,
 		Status_baca: structs.NotifikasiStatusBelumDibaca,
@@ -290,6 +290,31 @@ func (ns *NotificationService) SendPenghuluAssignmentNotification(pendaftaranID
 	return nil
 }
 
+// SendRegistrationCancelledNotification mengirim notifikasi saat pendaftaran nikah dibatalkan
+// ke pendaftar dan ke penghulu yang sebelumnya ditugaskan (penghuluID nil jika belum ada)
+func (ns *NotificationService) SendRegistrationCancelledNotification(pendaftaran *structs.PendaftaranNikah, penghuluID *uint, alasan string) error {
+	jadwal := fmt.Sprintf("%s pukul %s (%s)", locale.HariTanggal(pendaftaran.Tanggal_nikah),
+		locale.FormatJam(pendaftaran.Waktu_nikah, NewKUAService(ns.DB).ZonaWaktu(pendaftaran.Kua_id)), pendaftaran.Tempat_nikah)
+
+	// Notifikasi untuk penghulu yang penugasannya dilepas
+	if penghuluID != nil {
+		var penghulu structs.Penghulu
+		if err := ns.DB.Select("user_id").First(&penghulu, *penghuluID).Error; err != nil {
+			log.Printf("Gagal mengambil data penghulu: %v", err)
+		} else if err := ns.SendSystemNotification(penghulu.User_id, "Penugasan Nikah Dibatalkan",
+			fmt.Sprintf("Pendaftaran nikah %s pada %s dibatalkan. Anda tidak lagi ditugaskan untuk akad ini. Alasan: %s",
+				pendaftaran.Nomor_pendaftaran, jadwal, alasan),
+			structs.NotifikasiTipeWarning, "/simnikah/penghulu/assigned-registrations"); err != nil {
+			log.Printf("Gagal mengirim notifikasi pembatalan ke penghulu: %v", err)
+		}
+	}
+
+	// Notifikasi untuk pendaftar
+	return ns.SendSystemNotification(pendaftaran.Pendaftar_id, "Pendaftaran Nikah Dibatalkan",
+		fmt.Sprintf("Pendaftaran nikah %s pada %s telah dibatalkan. Alasan: %s", pendaftaran.Nomor_pendaftaran, jadwal, alasan),
+		structs.NotifikasiTipeInfo, fmt.Sprintf("/simnikah/pendaftaran/%d", pendaftaran.ID))
+}
+
 // SendReminderNotification mengirim notifikasi pengingat
 func (ns *NotificationService) SendReminderNotification() error {
 	// Pengingat untuk nikah yang akan datang (1 hari sebelum)
@@ -318,8 +343,8 @@ func (ns *NotificationService) SendReminderNotification() error {
 			Judul:   "Pengingat Nikah Besok",
 			Pesan: fmt.Sprintf("Pengingat: Nikah Anda dengan %s akan dilaksanakan besok (%s) pukul %s di %s. Pastikan semua persiapan sudah siap!",
 				calonIstri.Nama_lengkap,
-				pendaftaran.Tanggal_nikah.Format("02 Januari 2006"),
-				pendaftaran.Waktu_nikah,
+				locale.HariTanggal(pendaftaran.Tanggal_nikah),
+				locale.FormatJam(pendaftaran.Waktu_nikah, NewKUAService(ns.DB).ZonaWaktu(pendaftaran.Kua_id)),
 				pendaftaran.Tempat_nikah),
 			Tipe:        structs.NotifikasiTipeWarning,
 			Status_baca: structs.NotifikasiStatusBelumDibaca,
@@ -347,6 +372,8 @@ func (ns *NotificationService) SendReminderNotification() error {
 	}
 
 	for _, bimbingan := range bimbinganBesok {
+		zona := NewKUAService(ns.DB).ZonaWaktu(bimbingan.Kua_id)
+
 		// Ambil semua peserta bimbingan
 		var pesertaBimbingan []structs.PendaftaranBimbingan
 		if err := ns.DB.Where("bimbingan_perkawinan_id = ?", bimbingan.ID).Find(&pesertaBimbingan).Error; err != nil {
@@ -358,10 +385,9 @@ func (ns *NotificationService) SendReminderNotification() error {
 			reminderNotification := structs.Notifikasi{
 				User_id: peserta.Calon_suami_id,
 				Judul:   "Pengingat Bimbingan Perkawinan Besok",
-				Pesan: fmt.Sprintf("Pengingat: Bimbingan perkawinan akan dilaksanakan besok (%s) pukul %s - %s di %s. Pastikan Anda hadir tepat waktu!",
-					bimbingan.Tanggal_bimbingan.Format("02 Januari 2006"),
-					bimbingan.Waktu_mulai,
-					bimbingan.Waktu_selesai,
+				Pesan: fmt.Sprintf("Pengingat: Bimbingan perkawinan akan dilaksanakan besok (%s) pukul %s di %s. Pastikan Anda hadir tepat waktu!",
+					locale.HariTanggal(bimbingan.Tanggal_bimbingan),
+					locale.RentangJam(bimbingan.Waktu_mulai, bimbingan.Waktu_selesai, zona),
 					bimbingan.Tempat_bimbingan),
 				Tipe:        structs.NotifikasiTipeWarning,
 				Status_baca: structs.NotifikasiStatusBelumDibaca,
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/crypto"
+	"simnikah/pkg/mailer"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// PasswordResetCodeTTL adalah masa berlaku kode reset password
+	PasswordResetCodeTTL = 15 * time.Minute
+	// PasswordResetMaxAttempts adalah batas percobaan kode salah sebelum kode hangus
+	PasswordResetMaxAttempts = 5
+	// PasswordResetResendInterval adalah jeda minimum antar permintaan kode untuk user yang sama
+	PasswordResetResendInterval = time.Minute
+)
+
+var (
+	// ErrOldPasswordWrong dikembalikan jika password lama tidak cocok
+	ErrOldPasswordWrong = errors.New("password lama salah")
+	// ErrPasswordUnchanged dikembalikan jika password baru sama dengan password lama
+	ErrPasswordUnchanged = errors.New("password baru tidak boleh sama dengan password lama")
+	// ErrResetCodeInvalid dikembalikan jika kode reset salah, sudah dipakai, atau kedaluwarsa
+	ErrResetCodeInvalid = errors.New("kode reset password tidak valid atau sudah kedaluwarsa")
+)
+
+// PasswordService untuk ganti password dan lupa password via kode OTP email
+type PasswordService struct {
+	DB     *gorm.DB
+	Mailer mailer.Mailer
+}
+
+// NewPasswordService membuat instance baru dari PasswordService
+func NewPasswordService(db *gorm.DB, m mailer.Mailer) *PasswordService {
+	return &PasswordService{DB: db, Mailer: m}
+}
+
+// ChangePassword mengganti password user yang login setelah memverifikasi password lama.
+// Sesi lain milik user dicabut; sesi yang sedang dipakai tetap aktif.
+func (ps *PasswordService) ChangePassword(userID, currentSessionID, oldPassword, newPassword string) error {
+	var user structs.Users
+	if err := ps.DB.Where("user_id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+
+	if err := crypto.VerifyPassword(oldPassword, user.Password); err != nil {
+		return ErrOldPasswordWrong
+	}
+	if oldPassword == newPassword {
+		return ErrPasswordUnchanged
+	}
+
+	hashedPassword, err := crypto.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return ps.DB.Transaction(func(tx *gorm.DB) error {
+		if err := updatePassword(tx, userID, hashedPassword); err != nil {
+			return err
+		}
+		return NewSessionService(tx).RevokeOtherSessions(userID, currentSessionID, structs.SesiAlasanGantiPassword)
+	})
+}
+
+// RequestPasswordReset membuat kode reset dan mengirimnya ke email user.
+// Selalu mengembalikan nil untuk email yang tidak terdaftar agar email tidak bisa ditebak.
+func (ps *PasswordService) RequestPasswordReset(email string) error {
+	var user structs.Users
+	if err := ps.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.Status != structs.UserStatusAktif {
+		return nil
+	}
+
+	now := time.Now()
+
+	// Batasi pengiriman ulang kode untuk user yang sama
+	var recent int64
+	ps.DB.Model(&structs.KodeResetPassword{}).
+		Where("user_id = ? AND created_at > ?", user.User_id, now.Add(-PasswordResetResendInterval)).
+		Count(&recent)
+	if recent > 0 {
+		return nil
+	}
+
+	code, err := generateNumericCode(6)
+	if err != nil {
+		return fmt.Errorf("gagal membuat kode reset: %v", err)
+	}
+	codeHash, err := crypto.HashPassword(code)
+	if err != nil {
+		return fmt.Errorf("gagal membuat kode reset: %v", err)
+	}
+
+	err = ps.DB.Transaction(func(tx *gorm.DB) error {
+		// Kode lama yang belum dipakai tidak berlaku lagi
+		if err := tx.Model(&structs.KodeResetPassword{}).
+			Where("user_id = ? AND digunakan_pada IS NULL", user.User_id).
+			Update("kedaluwarsa_pada", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&structs.KodeResetPassword{
+			User_id:          user.User_id,
+			Kode_hash:        codeHash,
+			Kedaluwarsa_pada: now.Add(PasswordResetCodeTTL),
+			Created_at:       now,
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("gagal menyimpan kode reset: %v", err)
+	}
+
+	subject := "Kode Reset Password SimNikah"
+	body := fmt.Sprintf("Assalamu'alaikum %s,\n\n"+
+		"Kode reset password Anda adalah: %s\n\n"+
+		"Kode berlaku selama %d menit dan hanya dapat digunakan satu kali.\n"+
+		"Jika Anda tidak meminta reset password, abaikan email ini.\n\n"+
+		"%s",
+		user.Nama, code, int(PasswordResetCodeTTL.Minutes()), ps.senderName(&user))
+
+	// Kegagalan kirim hanya dicatat di log agar response tetap sama untuk semua email
+	if err := ps.Mailer.Send(user.Email, subject, body); err != nil {
+		log.Printf("Gagal mengirim kode reset password ke %s: %v", user.Email, err)
+	}
+
+	return nil
+}
+
+// ResetPassword mengganti password menggunakan kode reset dari email.
+// Kode hanya bisa dipakai sekali; setelah PasswordResetMaxAttempts kali salah, kode hangus.
+// Semua sesi user dicabut setelah password diganti.
+func (ps *PasswordService) ResetPassword(email, code, newPassword string) error {
+	var user structs.Users
+	if err := ps.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return ErrResetCodeInvalid
+	}
+	if user.Status != structs.UserStatusAktif {
+		return ErrResetCodeInvalid
+	}
+
+	hashedPassword, err := crypto.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	var codeErr error
+	err = ps.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var kode structs.KodeResetPassword
+		if err := tx.Where("user_id = ? AND digunakan_pada IS NULL AND kedaluwarsa_pada > ? AND percobaan < ?",
+			user.User_id, now, PasswordResetMaxAttempts).
+			Order("created_at DESC").
+			First(&kode).Error; err != nil {
+			codeErr = ErrResetCodeInvalid
+			return nil
+		}
+
+		if err := crypto.VerifyPassword(code, kode.Kode_hash); err != nil {
+			// Catat percobaan salah (tetap di-commit)
+			codeErr = ErrResetCodeInvalid
+			return tx.Model(&kode).Update("percobaan", gorm.Expr("percobaan + 1")).Error
+		}
+
+		// Tandai digunakan hanya jika belum dipakai request lain secara bersamaan
+		result := tx.Model(&structs.KodeResetPassword{}).
+			Where("id = ? AND digunakan_pada IS NULL", kode.ID).
+			Update("digunakan_pada", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			codeErr = ErrResetCodeInvalid
+			return nil
+		}
+
+		if err := updatePassword(tx, user.User_id, hashedPassword); err != nil {
+			return err
+		}
+		_, err := revokeAllSessions(tx, user.User_id, structs.SesiAlasanResetPassword)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return codeErr
+}
+
+// senderName mengembalikan nama KUA user sebagai penanda tangan email. Catin yang belum memilih KUA
+// memakai KUA pendaftaran terakhirnya; tanpa KUA sama sekali dipakai nama aplikasi.
+func (ps *PasswordService) senderName(user *structs.Users) string {
+	kuaID := user.Kua_id
+	if kuaID == 0 {
+		var p structs.PendaftaranNikah
+		if err := ps.DB.Select("kua_id").Where("pendaftar_id = ?", user.User_id).Order("created_at DESC").First(&p).Error; err == nil {
+			kuaID = p.Kua_id
+		}
+	}
+	if kuaID != 0 {
+		if kua, err := NewKUAService(ps.DB).GetActive(kuaID); err == nil {
+			return kua.Nama
+		}
+	}
+	return "SimNikah"
+}
+
+func updatePassword(tx *gorm.DB, userID, hashedPassword string) error {
+	if err := tx.Model(&structs.Users{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+		"password":        hashedPassword,
+		"gagal_login":     0, // Password baru membuka kunci akun
+		"terkunci_sampai": nil,
+		"updated_at":      time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("gagal menyimpan password baru: %v", err)
+	}
+	return nil
+}
+
+// generateNumericCode membuat kode angka acak (crypto/rand) dengan panjang tertentu
+func generateNumericCode(length int) (string, error) {
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		code[i] = byte('0' + n.Int64())
+	}
+	return string(code), nil
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/crypto"
+	"simnikah/pkg/mailer"
+
+	"gorm.io/gorm"
+)
+
+var resetCodePattern = regexp.MustCompile(`\b\d{6}\b`)
+
+// setTestPassword mengganti password user uji dengan hash bcrypt dari password
+func setTestPassword(t *testing.T, db *gorm.DB, userID, password string) {
+	t.Helper()
+
+	hash, err := crypto.HashPassword(password)
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	db.Model(&structs.Users{}).Where("user_id = ?", userID).Update("password", hash)
+}
+
+// lastResetCode mengambil kode reset dari email terakhir yang diterima alamat to
+func lastResetCode(t *testing.T, m *mailer.FakeMailer, to string) (string, mailer.Message) {
+	t.Helper()
+
+	msg, ok := m.LastMessageTo(to)
+	if !ok {
+		t.Fatalf("tidak ada email untuk %s", to)
+	}
+	code := resetCodePattern.FindString(msg.Body)
+	if code == "" {
+		t.Fatalf("email tidak memuat kode reset: %q", msg.Body)
+	}
+	return code, msg
+}
+
+func TestForgotAndResetPassword(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-SELATAN", "Banjarmasin Selatan", "Kota Banjarmasin", "Kalimantan Selatan")
+	user := createTestUser(t, db, "CATIN1", structs.UserRoleUserBiasa, 0)
+	createTestPendaftaran(t, db, structs.PendaftaranNikah{Kua_id: kua.ID, Pendaftar_id: user.User_id, Status_pendaftaran: structs.StatusPendaftaranDraft})
+	setTestPassword(t, db, user.User_id, "lama123")
+	m := mailer.NewFakeMailer()
+	ps := NewPasswordService(db, m)
+
+	// Email tidak terdaftar tidak dikirimi apa pun dan tidak membocorkan error
+	if err := ps.RequestPasswordReset("tidak.ada@kua.go.id"); err != nil || len(m.Messages()) != 0 {
+		t.Fatalf("RequestPasswordReset(tidak terdaftar) = %v, %d email", err, len(m.Messages()))
+	}
+
+	if err := ps.RequestPasswordReset(user.Email); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v", err)
+	}
+	code, msg := lastResetCode(t, m, user.Email)
+	if !strings.HasSuffix(msg.Body, kua.Nama) {
+		t.Errorf("email ditandatangani %q, want %s", msg.Body[strings.LastIndex(msg.Body, "\n")+1:], kua.Nama)
+	}
+	// Permintaan ulang dalam jeda kirim ulang tidak mengirim kode baru
+	if err := ps.RequestPasswordReset(user.Email); err != nil || len(m.Messages()) != 1 {
+		t.Errorf("RequestPasswordReset(ulang) = %v, %d email, want 1", err, len(m.Messages()))
+	}
+
+	sesi, _, err := NewSessionService(db).StartSession(user.User_id, "uji", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("StartSession() error = %v", err)
+	}
+
+	salah := "000000"
+	if code == salah {
+		salah = "111111"
+	}
+	if err := ps.ResetPassword(user.Email, salah, "baru123"); !errors.Is(err, ErrResetCodeInvalid) {
+		t.Errorf("ResetPassword(kode salah) error = %v, want ErrResetCodeInvalid", err)
+	}
+	var kode structs.KodeResetPassword
+	db.Where("user_id = ?", user.User_id).First(&kode)
+	if kode.Percobaan != 1 {
+		t.Errorf("percobaan = %d, want 1", kode.Percobaan)
+	}
+
+	if err := ps.ResetPassword(user.Email, code, "baru123"); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	var saved structs.Users
+	db.Where("user_id = ?", user.User_id).First(&saved)
+	if crypto.VerifyPassword("baru123", saved.Password) != nil {
+		t.Error("password baru tidak tersimpan")
+	}
+	if _, err := NewSessionService(db).GetActiveSession(sesi.Session_id); err == nil {
+		t.Error("sesi lama masih aktif setelah reset password")
+	}
+	if err := ps.ResetPassword(user.Email, code, "lagi123"); !errors.Is(err, ErrResetCodeInvalid) {
+		t.Errorf("ResetPassword(kode dipakai ulang) error = %v, want ErrResetCodeInvalid", err)
+	}
+}
+
+func TestResetPasswordMaxAttempts(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	user := createTestUser(t, db, "STF1", structs.UserRoleStaff, kua.ID)
+	m := mailer.NewFakeMailer()
+	ps := NewPasswordService(db, m)
+
+	if err := ps.RequestPasswordReset(user.Email); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v", err)
+	}
+	code, msg := lastResetCode(t, m, user.Email)
+	if !strings.HasSuffix(msg.Body, kua.Nama) {
+		t.Errorf("email tidak ditandatangani %s", kua.Nama)
+	}
+
+	salah := "000000"
+	if code == salah {
+		salah = "111111"
+	}
+	for i := 0; i < PasswordResetMaxAttempts; i++ {
+		ps.ResetPassword(user.Email, salah, "baru123")
+	}
+	// Kode hangus setelah batas percobaan, kode yang benar pun ditolak
+	if err := ps.ResetPassword(user.Email, code, "baru123"); !errors.Is(err, ErrResetCodeInvalid) {
+		t.Errorf("ResetPassword(setelah batas percobaan) error = %v, want ErrResetCodeInvalid", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "STF1", structs.UserRoleStaff, 1)
+	setTestPassword(t, db, user.User_id, "lama123")
+	ps := NewPasswordService(db, mailer.NewFakeMailer())
+
+	ss := NewSessionService(db)
+	aktif, _, _ := ss.StartSession(user.User_id, "laptop", "127.0.0.1")
+	lain, _, _ := ss.StartSession(user.User_id, "ponsel", "127.0.0.2")
+
+	if err := ps.ChangePassword(user.User_id, aktif.Session_id, "salah", "baru123"); !errors.Is(err, ErrOldPasswordWrong) {
+		t.Errorf("ChangePassword(password lama salah) error = %v, want ErrOldPasswordWrong", err)
+	}
+	if err := ps.ChangePassword(user.User_id, aktif.Session_id, "lama123", "lama123"); !errors.Is(err, ErrPasswordUnchanged) {
+		t.Errorf("ChangePassword(sama) error = %v, want ErrPasswordUnchanged", err)
+	}
+	if err := ps.ChangePassword(user.User_id, aktif.Session_id, "lama123", "baru123"); err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+
+	if _, err := ss.GetActiveSession(aktif.Session_id); err != nil {
+		t.Errorf("sesi yang dipakai dicabut: %v", err)
+	}
+	if _, err := ss.GetActiveSession(lain.Session_id); err == nil {
+		t.Error("sesi lain masih aktif setelah ganti password")
+	}
+	if err := ps.ChangePassword(user.User_id, aktif.Session_id, "lama123", "lagi123"); !errors.Is(err, ErrOldPasswordWrong) {
+		t.Errorf("ChangePassword(password lama setelah diganti) error = %v, want ErrOldPasswordWrong", err)
+	}
+}
@@ -0,0 +1,469 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/locale"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidUnavailability dikembalikan jika data cuti/ketidaksediaan penghulu tidak valid
+	ErrInvalidUnavailability = errors.New("data ketidaksediaan penghulu tidak valid")
+	// ErrUnavailabilityNotFound dikembalikan jika ketidaksediaan tidak ada atau bukan milik KUA/penghulu user
+	ErrUnavailabilityNotFound = errors.New("ketidaksediaan penghulu tidak ditemukan")
+	// ErrUnavailabilityProcessed dikembalikan jika pengajuan sudah disetujui, ditolak, atau dibatalkan
+	ErrUnavailabilityProcessed = errors.New("pengajuan ketidaksediaan sudah diproses")
+)
+
+// UnavailabilityInput adalah body pengajuan cuti, sakit, tugas luar, atau blokir jadwal penghulu
+type UnavailabilityInput struct {
+	PenghuluID     uint   `json:"penghulu_id"` // diisi kepala KUA; penghulu selalu mengajukan untuk dirinya sendiri
+	Jenis          string `json:"jenis"`
+	TanggalMulai   string `json:"tanggal_mulai"`   // YYYY-MM-DD
+	TanggalSelesai string `json:"tanggal_selesai"` // YYYY-MM-DD, default sama dengan tanggal_mulai
+	JamMulai       string `json:"jam_mulai"`       // HH:MM, kosong = sepanjang hari
+	JamSelesai     string `json:"jam_selesai"`     // HH:MM
+	HariBerulang   []int  `json:"hari_berulang"`   // 0 = Minggu ... 6 = Sabtu, kosong = setiap hari dalam rentang
+	Alasan         string `json:"alasan"`
+}
+
+// Normalize merapikan dan memvalidasi pengajuan ketidaksediaan
+func (in *UnavailabilityInput) Normalize() error {
+	in.Jenis = strings.TrimSpace(in.Jenis)
+	in.TanggalMulai = strings.TrimSpace(in.TanggalMulai)
+	in.TanggalSelesai = strings.TrimSpace(in.TanggalSelesai)
+	in.JamMulai = strings.TrimSpace(in.JamMulai)
+	in.JamSelesai = strings.TrimSpace(in.JamSelesai)
+	in.Alasan = strings.TrimSpace(in.Alasan)
+
+	switch in.Jenis {
+	case structs.KetidaksediaanJenisCuti, structs.KetidaksediaanJenisSakit,
+		structs.KetidaksediaanJenisTugasLuar, structs.KetidaksediaanJenisBlokir:
+	default:
+		return fmt.Errorf("%w: jenis harus Cuti, Sakit, Tugas Luar, atau Blokir", ErrInvalidUnavailability)
+	}
+
+	mulai, err := time.Parse("2006-01-02", in.TanggalMulai)
+	if err != nil {
+		return fmt.Errorf("%w: tanggal_mulai harus YYYY-MM-DD", ErrInvalidUnavailability)
+	}
+	if in.TanggalSelesai == "" {
+		in.TanggalSelesai = in.TanggalMulai
+	}
+	selesai, err := time.Parse("2006-01-02", in.TanggalSelesai)
+	if err != nil {
+		return fmt.Errorf("%w: tanggal_selesai harus YYYY-MM-DD", ErrInvalidUnavailability)
+	}
+	if selesai.Before(mulai) {
+		return fmt.Errorf("%w: tanggal_selesai tidak boleh sebelum tanggal_mulai", ErrInvalidUnavailability)
+	}
+	if selesai.Sub(mulai) > 366*24*time.Hour {
+		return fmt.Errorf("%w: rentang tanggal maksimal satu tahun", ErrInvalidUnavailability)
+	}
+
+	if (in.JamMulai == "") != (in.JamSelesai == "") {
+		return fmt.Errorf("%w: jam_mulai dan jam_selesai harus diisi bersamaan", ErrInvalidUnavailability)
+	}
+	if in.JamMulai != "" {
+		jamMulai, err1 := time.Parse("15:04", in.JamMulai)
+		jamSelesai, err2 := time.Parse("15:04", in.JamSelesai)
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("%w: jam harus HH:MM", ErrInvalidUnavailability)
+		}
+		if !jamSelesai.After(jamMulai) {
+			return fmt.Errorf("%w: jam_selesai harus setelah jam_mulai", ErrInvalidUnavailability)
+		}
+	}
+
+	for _, day := range in.HariBerulang {
+		if day < 0 || day > 6 {
+			return fmt.Errorf("%w: hari_berulang harus 0 (Minggu) sampai 6 (Sabtu)", ErrInvalidUnavailability)
+		}
+	}
+	return nil
+}
+
+// UnavailabilityCoversDate mengecek apakah ketidaksediaan berlaku pada tanggal tersebut
+// (di dalam rentang tanggal dan, jika berulang, pada hari yang dipilih)
+func UnavailabilityCoversDate(k *structs.KetidaksediaanPenghulu, tanggal time.Time) bool {
+	t := tanggal.Format("2006-01-02")
+	if t < k.Tanggal_mulai || t > k.Tanggal_selesai {
+		return false
+	}
+	if k.Hari_berulang == "" {
+		return true
+	}
+	for _, day := range parseWeekdays(k.Hari_berulang) {
+		if int(tanggal.Weekday()) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// UnavailabilityFullDay mengecek apakah ketidaksediaan berlaku sepanjang hari
+func UnavailabilityFullDay(k *structs.KetidaksediaanPenghulu) bool {
+	return k.Jam_mulai == "" || k.Jam_selesai == ""
+}
+
+// UnavailabilityBlocks mengecek apakah ketidaksediaan memblokir akad pada tanggal dan waktu (HH:MM) tersebut.
+// Rentang jam bersifat [jam_mulai, jam_selesai).
+func UnavailabilityBlocks(k *structs.KetidaksediaanPenghulu, tanggal time.Time, waktu string) bool {
+	if !UnavailabilityCoversDate(k, tanggal) {
+		return false
+	}
+	if UnavailabilityFullDay(k) {
+		return true
+	}
+	return waktu >= k.Jam_mulai && waktu < k.Jam_selesai
+}
+
+// PenghuluAvailabilityService untuk cuti, sakit, tugas luar, dan blokir jadwal penghulu
+type PenghuluAvailabilityService struct {
+	DB *gorm.DB
+}
+
+// NewPenghuluAvailabilityService membuat instance baru dari PenghuluAvailabilityService
+func NewPenghuluAvailabilityService(db *gorm.DB) *PenghuluAvailabilityService {
+	return &PenghuluAvailabilityService{DB: db}
+}
+
+// Create menyimpan ketidaksediaan penghulu di KUA. approved=true (kepala KUA) langsung berstatus Disetujui
+// dan menandai jadwal yang bentrok; selain itu berstatus Menunggu Persetujuan.
+func (as *PenghuluAvailabilityService) Create(kuaID uint, input UnavailabilityInput, diajukanOleh string, approved bool) (*structs.KetidaksediaanPenghulu, []structs.PendaftaranNikah, error) {
+	if err := input.Normalize(); err != nil {
+		return nil, nil, err
+	}
+
+	var penghulu structs.Penghulu
+	if err := as.DB.Scopes(TenantScope(kuaID)).First(&penghulu, input.PenghuluID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, fmt.Errorf("%w: penghulu tidak ditemukan di KUA ini", ErrInvalidUnavailability)
+		}
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	k := structs.KetidaksediaanPenghulu{
+		Kua_id:          kuaID,
+		Penghulu_id:     penghulu.ID,
+		Jenis:           input.Jenis,
+		Tanggal_mulai:   input.TanggalMulai,
+		Tanggal_selesai: input.TanggalSelesai,
+		Jam_mulai:       input.JamMulai,
+		Jam_selesai:     input.JamSelesai,
+		Hari_berulang:   formatWeekdays(input.HariBerulang),
+		Alasan:          input.Alasan,
+		Status:          structs.KetidaksediaanStatusMenunggu,
+		Diajukan_oleh:   diajukanOleh,
+		Created_at:      now,
+		Updated_at:      now,
+	}
+	if approved {
+		k.Status = structs.KetidaksediaanStatusDisetujui
+		k.Diproses_oleh = diajukanOleh
+		k.Diproses_pada = &now
+	}
+
+	if !approved {
+		if err := as.DB.Create(&k).Error; err != nil {
+			return nil, nil, err
+		}
+		as.notifyKepalaKUA(kuaID, "Pengajuan Ketidaksediaan Penghulu",
+			fmt.Sprintf("%s mengajukan %s pada %s.", penghulu.Nama_lengkap, strings.ToLower(k.Jenis), describeRange(&k)),
+			structs.NotifikasiTipeInfo)
+		return &k, nil, nil
+	}
+
+	// Ketidaksediaan yang langsung disetujui disimpan bersama penandaan jadwal yang bentrok
+	var konflik []structs.PendaftaranNikah
+	err := as.DB.Transaction(func(tx *gorm.DB) error {
+		if err := LockPenghulu(tx, penghulu.ID); err != nil {
+			return err
+		}
+		if err := tx.Create(&k).Error; err != nil {
+			return err
+		}
+		var err error
+		konflik, err = markConflicts(tx, &k)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	as.notifyConflicts(&k, konflik)
+	return &k, konflik, nil
+}
+
+// Process menyetujui atau menolak pengajuan ketidaksediaan (kepala KUA).
+// Status hanya diubah jika pengajuan masih menunggu, dan pengajuan yang disetujui menandai jadwal nikah
+// penghulu yang bentrok di dalam transaksi yang sama.
+func (as *PenghuluAvailabilityService) Process(id string, kuaID uint, approve bool, diprosesOleh, catatan string) (*structs.KetidaksediaanPenghulu, []structs.PendaftaranNikah, error) {
+	var k structs.KetidaksediaanPenghulu
+	if err := as.DB.Scopes(TenantScope(kuaID)).First(&k, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrUnavailabilityNotFound
+		}
+		return nil, nil, err
+	}
+	if k.Status != structs.KetidaksediaanStatusMenunggu {
+		return nil, nil, ErrUnavailabilityProcessed
+	}
+
+	now := time.Now()
+	k.Status = structs.KetidaksediaanStatusDitolak
+	if approve {
+		k.Status = structs.KetidaksediaanStatusDisetujui
+	}
+	k.Diproses_oleh = diprosesOleh
+	k.Diproses_pada = &now
+	k.Catatan_proses = strings.TrimSpace(catatan)
+	k.Updated_at = now
+
+	var konflik []structs.PendaftaranNikah
+	err := as.DB.Transaction(func(tx *gorm.DB) error {
+		// Penghulu dikunci agar penugasan yang berjalan bersamaan melihat ketidaksediaan ini atau ikut ditandai
+		if approve {
+			if err := LockPenghulu(tx, k.Penghulu_id); err != nil {
+				return err
+			}
+		}
+		result := tx.Model(&structs.KetidaksediaanPenghulu{}).
+			Where("id = ? AND status = ?", k.ID, structs.KetidaksediaanStatusMenunggu).
+			Updates(map[string]interface{}{
+				"status":         k.Status,
+				"diproses_oleh":  k.Diproses_oleh,
+				"diproses_pada":  k.Diproses_pada,
+				"catatan_proses": k.Catatan_proses,
+				"updated_at":     k.Updated_at,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUnavailabilityProcessed
+		}
+		if !approve {
+			return nil
+		}
+		var err error
+		konflik, err = markConflicts(tx, &k)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var penghulu structs.Penghulu
+	if err := as.DB.First(&penghulu, k.Penghulu_id).Error; err == nil {
+		tipe := structs.NotifikasiTipeSuccess
+		if !approve {
+			tipe = structs.NotifikasiTipeWarning
+		}
+		pesan := fmt.Sprintf("Pengajuan %s Anda pada %s %s.", strings.ToLower(k.Jenis), describeRange(&k), strings.ToLower(k.Status))
+		if k.Catatan_proses != "" {
+			pesan += " Catatan: " + k.Catatan_proses
+		}
+		if err := NewNotificationService(as.DB).SendSystemNotification(penghulu.User_id, "Pengajuan Ketidaksediaan "+k.Status, pesan, tipe, "/simnikah/penghulu/ketidaksediaan"); err != nil {
+			log.Printf("Gagal mengirim notifikasi ketidaksediaan ke penghulu: %v", err)
+		}
+	}
+
+	if !approve {
+		return &k, nil, nil
+	}
+	as.notifyConflicts(&k, konflik)
+	return &k, konflik, nil
+}
+
+// Cancel membatalkan ketidaksediaan. penghuluID > 0 membatasi ke pengajuan milik penghulu tersebut
+// yang belum diproses; kepala KUA (penghuluID = 0) juga boleh membatalkan yang sudah disetujui.
+// Tanda konflik pada jadwal nikah dari ketidaksediaan ini dihapus.
+func (as *PenghuluAvailabilityService) Cancel(id string, kuaID, penghuluID uint, dibatalkanOleh string) (*structs.KetidaksediaanPenghulu, error) {
+	query := as.DB.Scopes(TenantScope(kuaID))
+	if penghuluID > 0 {
+		query = query.Where("penghulu_id = ?", penghuluID)
+	}
+
+	var k structs.KetidaksediaanPenghulu
+	if err := query.First(&k, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnavailabilityNotFound
+		}
+		return nil, err
+	}
+
+	cancellable := k.Status == structs.KetidaksediaanStatusMenunggu ||
+		(penghuluID == 0 && k.Status == structs.KetidaksediaanStatusDisetujui)
+	if !cancellable {
+		return nil, ErrUnavailabilityProcessed
+	}
+
+	statusLama := k.Status
+	now := time.Now()
+	k.Status = structs.KetidaksediaanStatusDibatalkan
+	k.Diproses_oleh = dibatalkanOleh
+	k.Diproses_pada = &now
+	k.Updated_at = now
+
+	err := as.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&structs.KetidaksediaanPenghulu{}).
+			Where("id = ? AND status = ?", k.ID, statusLama).
+			Updates(map[string]interface{}{
+				"status":        k.Status,
+				"diproses_oleh": k.Diproses_oleh,
+				"diproses_pada": k.Diproses_pada,
+				"updated_at":    k.Updated_at,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUnavailabilityProcessed
+		}
+		return tx.Model(&structs.PendaftaranNikah{}).Where("konflik_ketidaksediaan_id = ?", k.ID).
+			Update("konflik_ketidaksediaan_id", nil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// List mengambil ketidaksediaan penghulu di KUA, bisa difilter penghulu (0 = semua) dan status
+func (as *PenghuluAvailabilityService) List(kuaID, penghuluID uint, status string) ([]structs.KetidaksediaanPenghulu, error) {
+	query := as.DB.Scopes(TenantScope(kuaID))
+	if penghuluID > 0 {
+		query = query.Where("penghulu_id = ?", penghuluID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var rows []structs.KetidaksediaanPenghulu
+	err := query.Order("tanggal_mulai DESC, id DESC").Find(&rows).Error
+	return rows, err
+}
+
+// ForDate mengembalikan ketidaksediaan yang disetujui dan berlaku pada tanggal tersebut, per penghulu
+func (as *PenghuluAvailabilityService) ForDate(kuaID uint, tanggal time.Time) (map[uint][]structs.KetidaksediaanPenghulu, error) {
+	t := tanggal.Format("2006-01-02")
+	var rows []structs.KetidaksediaanPenghulu
+	if err := as.DB.Scopes(TenantScope(kuaID)).
+		Where("status = ? AND tanggal_mulai <= ? AND tanggal_selesai >= ?", structs.KetidaksediaanStatusDisetujui, t, t).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint][]structs.KetidaksediaanPenghulu)
+	for i := range rows {
+		if UnavailabilityCoversDate(&rows[i], tanggal) {
+			result[rows[i].Penghulu_id] = append(result[rows[i].Penghulu_id], rows[i])
+		}
+	}
+	return result, nil
+}
+
+// Blocking mengembalikan ketidaksediaan disetujui yang memblokir penghulu pada tanggal dan waktu tersebut,
+// atau nil jika penghulu tersedia
+func (as *PenghuluAvailabilityService) Blocking(kuaID, penghuluID uint, tanggal time.Time, waktu string) (*structs.KetidaksediaanPenghulu, error) {
+	byPenghulu, err := as.ForDate(kuaID, tanggal)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range byPenghulu[penghuluID] {
+		if UnavailabilityBlocks(&k, tanggal, waktu) {
+			return &k, nil
+		}
+	}
+	return nil, nil
+}
+
+// markConflicts menandai jadwal nikah penghulu yang belum berstatus akhir dan bentrok dengan ketidaksediaan
+// yang disetujui. Dijalankan di dalam transaksi yang menyetujui ketidaksediaan tersebut.
+func markConflicts(tx *gorm.DB, k *structs.KetidaksediaanPenghulu) ([]structs.PendaftaranNikah, error) {
+	mulai, _ := time.Parse("2006-01-02", k.Tanggal_mulai)
+	selesai, _ := time.Parse("2006-01-02", k.Tanggal_selesai)
+
+	var jadwal []structs.PendaftaranNikah
+	if err := tx.Where("penghulu_id = ? AND tanggal_nikah >= ? AND tanggal_nikah < ? AND status_pendaftaran IN ? AND status_pendaftaran NOT IN ?",
+		k.Penghulu_id, mulai, selesai.AddDate(0, 0, 1), ScheduledStatuses, FinalStatuses).
+		Order("tanggal_nikah ASC").Find(&jadwal).Error; err != nil {
+		return nil, err
+	}
+
+	konflik := make([]structs.PendaftaranNikah, 0)
+	ids := make([]uint, 0)
+	for _, p := range jadwal {
+		if UnavailabilityBlocks(k, p.Tanggal_nikah, p.Waktu_nikah) {
+			p.Konflik_ketidaksediaan_id = &k.ID
+			konflik = append(konflik, p)
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return konflik, nil
+	}
+
+	if err := tx.Model(&structs.PendaftaranNikah{}).Where("id IN ?", ids).
+		Update("konflik_ketidaksediaan_id", k.ID).Error; err != nil {
+		return nil, err
+	}
+	return konflik, nil
+}
+
+// notifyConflicts memberi tahu kepala KUA dan penghulu tentang jadwal yang bentrok dengan ketidaksediaan
+// agar penghulu pengganti ditugaskan
+func (as *PenghuluAvailabilityService) notifyConflicts(k *structs.KetidaksediaanPenghulu, konflik []structs.PendaftaranNikah) {
+	if len(konflik) == 0 {
+		return
+	}
+
+	var penghulu structs.Penghulu
+	as.DB.First(&penghulu, k.Penghulu_id)
+	nomor := make([]string, 0, len(konflik))
+	for _, p := range konflik {
+		nomor = append(nomor, p.Nomor_pendaftaran)
+	}
+	pesan := fmt.Sprintf("%s tidak tersedia (%s) pada %s, bentrok dengan %d jadwal nikah: %s. Segera tugaskan penghulu pengganti.",
+		penghulu.Nama_lengkap, strings.ToLower(k.Jenis), describeRange(k), len(konflik), strings.Join(nomor, ", "))
+	as.notifyKepalaKUA(k.Kua_id, "Jadwal Bentrok dengan Ketidaksediaan Penghulu", pesan, structs.NotifikasiTipeWarning)
+	if penghulu.User_id != "" {
+		if err := NewNotificationService(as.DB).SendSystemNotification(penghulu.User_id, "Jadwal Bentrok dengan Ketidaksediaan", pesan,
+			structs.NotifikasiTipeWarning, "/simnikah/penghulu/assigned-registrations"); err != nil {
+			log.Printf("Gagal mengirim notifikasi konflik ke penghulu: %v", err)
+		}
+	}
+}
+
+// notifyKepalaKUA mengirim notifikasi ke semua kepala KUA aktif di KUA tersebut
+func (as *PenghuluAvailabilityService) notifyKepalaKUA(kuaID uint, judul, pesan, tipe string) {
+	var userIDs []string
+	if err := as.DB.Model(&structs.Users{}).Scopes(TenantScope(kuaID)).
+		Where("role = ? AND status = ?", structs.UserRoleKepalaKUA, structs.UserStatusAktif).
+		Pluck("user_id", &userIDs).Error; err != nil || len(userIDs) == 0 {
+		return
+	}
+	if err := NewNotificationService(as.DB).SendBulkNotification(userIDs, judul, pesan, tipe, "/simnikah/penghulu/konflik-ketidaksediaan"); err != nil {
+		log.Printf("Gagal mengirim notifikasi ke kepala KUA: %v", err)
+	}
+}
+
+// describeRange menuliskan rentang ketidaksediaan untuk pesan notifikasi
+func describeRange(k *structs.KetidaksediaanPenghulu) string {
+	rentang := locale.FormatTanggal(k.Tanggal_mulai)
+	if k.Tanggal_selesai != k.Tanggal_mulai {
+		rentang += " s.d. " + locale.FormatTanggal(k.Tanggal_selesai)
+	}
+	if !UnavailabilityFullDay(k) {
+		rentang += " pukul " + locale.RentangJam(k.Jam_mulai, k.Jam_selesai, nil)
+	}
+	return rentang
+}
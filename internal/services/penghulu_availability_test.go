@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+)
+
+func TestUnavailabilityInputNormalize(t *testing.T) {
+	valid := UnavailabilityInput{Jenis: structs.KetidaksediaanJenisCuti, TanggalMulai: "2026-11-02"}
+
+	tests := []struct {
+		name   string
+		modify func(in *UnavailabilityInput)
+		valid  bool
+	}{
+		{"satu hari", func(in *UnavailabilityInput) {}, true},
+		{"rentang dengan jam", func(in *UnavailabilityInput) {
+			in.TanggalSelesai, in.JamMulai, in.JamSelesai = "2026-11-06", "13:00", "16:00"
+		}, true},
+		{"berulang jumat", func(in *UnavailabilityInput) { in.TanggalSelesai, in.HariBerulang = "2026-12-31", []int{5} }, true},
+		{"jenis tidak dikenal", func(in *UnavailabilityInput) { in.Jenis = "Libur" }, false},
+		{"tanggal salah", func(in *UnavailabilityInput) { in.TanggalMulai = "02-11-2026" }, false},
+		{"selesai sebelum mulai", func(in *UnavailabilityInput) { in.TanggalSelesai = "2026-11-01" }, false},
+		{"lebih dari setahun", func(in *UnavailabilityInput) { in.TanggalSelesai = "2027-12-01" }, false},
+		{"jam tidak lengkap", func(in *UnavailabilityInput) { in.JamMulai = "08:00" }, false},
+		{"jam terbalik", func(in *UnavailabilityInput) { in.JamMulai, in.JamSelesai = "12:00", "09:00" }, false},
+		{"hari tidak dikenal", func(in *UnavailabilityInput) { in.HariBerulang = []int{7} }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := valid
+			tt.modify(&in)
+			err := in.Normalize()
+			if tt.valid && err != nil {
+				t.Fatalf("Normalize() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidUnavailability) {
+				t.Fatalf("Normalize() = %v, want ErrInvalidUnavailability", err)
+			}
+		})
+	}
+
+	in := valid
+	if err := in.Normalize(); err != nil || in.TanggalSelesai != in.TanggalMulai {
+		t.Fatalf("Normalize() tanggal_selesai default = %q, want %q", in.TanggalSelesai, in.TanggalMulai)
+	}
+}
+
+func TestUnavailabilityBlocks(t *testing.T) {
+	senin := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	jumat := senin.AddDate(0, 0, 4)
+
+	cuti := structs.KetidaksediaanPenghulu{Tanggal_mulai: "2026-11-02", Tanggal_selesai: "2026-11-03"}
+	if !UnavailabilityBlocks(&cuti, senin, "09:00") || UnavailabilityBlocks(&cuti, jumat, "09:00") {
+		t.Fatalf("cuti sepanjang hari harus memblokir senin saja")
+	}
+
+	rapat := structs.KetidaksediaanPenghulu{Tanggal_mulai: "2026-11-02", Tanggal_selesai: "2026-11-02", Jam_mulai: "13:00", Jam_selesai: "15:00"}
+	for waktu, want := range map[string]bool{"12:59": false, "13:00": true, "14:30": true, "15:00": false} {
+		if got := UnavailabilityBlocks(&rapat, senin, waktu); got != want {
+			t.Errorf("UnavailabilityBlocks(rapat, %s) = %v, want %v", waktu, got, want)
+		}
+	}
+
+	jumatan := structs.KetidaksediaanPenghulu{Tanggal_mulai: "2026-11-01", Tanggal_selesai: "2026-12-31", Hari_berulang: "5", Jam_mulai: "11:00", Jam_selesai: "13:00"}
+	if !UnavailabilityBlocks(&jumatan, jumat, "11:30") || UnavailabilityBlocks(&jumatan, senin, "11:30") {
+		t.Fatalf("ketidaksediaan berulang harus memblokir jumat saja")
+	}
+	if UnavailabilityCoversDate(&jumatan, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("ketidaksediaan berulang tidak boleh berlaku di luar rentang tanggal")
+	}
+}
+
+func TestPenghuluAvailabilityProcess(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	penghulu := createTestPenghulu(t, db, "PGH1", kua.ID)
+	createTestUser(t, db, "KPL1", structs.UserRoleKepalaKUA, kua.ID)
+
+	hari := time.Now().UTC().AddDate(0, 1, 0)
+	tanggal := time.Date(hari.Year(), hari.Month(), hari.Day(), 0, 0, 0, 0, time.UTC)
+	bentrok := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Tanggal_nikah:      tanggal,
+		Waktu_nikah:        "09:00",
+		Status_pendaftaran: structs.StatusPendaftaranMenungguBimbingan,
+		Penghulu_id:        &penghulu.ID,
+	})
+	selesai := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Tanggal_nikah:      tanggal,
+		Waktu_nikah:        "13:00",
+		Status_pendaftaran: structs.StatusPendaftaranSelesai,
+		Penghulu_id:        &penghulu.ID,
+	})
+
+	as := NewPenghuluAvailabilityService(db)
+	ajukan := func() *structs.KetidaksediaanPenghulu {
+		k, _, err := as.Create(kua.ID, UnavailabilityInput{
+			PenghuluID:   penghulu.ID,
+			Jenis:        structs.KetidaksediaanJenisCuti,
+			TanggalMulai: tanggal.Format("2006-01-02"),
+		}, penghulu.User_id, false)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return k
+	}
+	konflikID := func(id uint) *uint {
+		var p structs.PendaftaranNikah
+		db.First(&p, id)
+		return p.Konflik_ketidaksediaan_id
+	}
+
+	// Penghulu membatalkan pengajuan tepat sebelum status disimpan: persetujuan tidak menandai jadwal
+	k := ajukan()
+	batalSebelumSimpan := func(tx *gorm.DB) {
+		if tx.Statement.Table == "ketidaksediaan_penghulus" {
+			tx.Session(&gorm.Session{NewDB: true}).Exec("UPDATE ketidaksediaan_penghulus SET status = ? WHERE id = ?",
+				structs.KetidaksediaanStatusDibatalkan, k.ID)
+		}
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("test:batal_sebelum_simpan", batalSebelumSimpan); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	_, _, err := as.Process(fmt.Sprint(k.ID), kua.ID, true, "KPL1", "")
+	db.Callback().Update().Remove("test:batal_sebelum_simpan")
+	if !errors.Is(err, ErrUnavailabilityProcessed) {
+		t.Fatalf("Process(dibatalkan bersamaan) error = %v, want ErrUnavailabilityProcessed", err)
+	}
+	if id := konflikID(bentrok.ID); id != nil {
+		t.Errorf("jadwal ditandai konflik %d setelah persetujuan gagal", *id)
+	}
+
+	// Persetujuan menandai jadwal yang bentrok, kecuali yang sudah berstatus akhir
+	k = ajukan()
+	processed, konflik, err := as.Process(fmt.Sprint(k.ID), kua.ID, true, "KPL1", "disetujui")
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if processed.Status != structs.KetidaksediaanStatusDisetujui {
+		t.Errorf("status = %q, want %q", processed.Status, structs.KetidaksediaanStatusDisetujui)
+	}
+	if len(konflik) != 1 || konflik[0].ID != bentrok.ID {
+		t.Errorf("konflik = %v, want hanya pendaftaran %d", konflik, bentrok.ID)
+	}
+	if id := konflikID(bentrok.ID); id == nil || *id != k.ID {
+		t.Errorf("konflik_ketidaksediaan_id = %v, want %d", id, k.ID)
+	}
+	if id := konflikID(selesai.ID); id != nil {
+		t.Errorf("pendaftaran selesai ditandai konflik %d", *id)
+	}
+
+	// Pengajuan yang sudah diproses tidak bisa diproses ulang
+	if _, _, err := as.Process(fmt.Sprint(k.ID), kua.ID, false, "KPL1", ""); !errors.Is(err, ErrUnavailabilityProcessed) {
+		t.Errorf("Process(ulang) error = %v, want ErrUnavailabilityProcessed", err)
+	}
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// errPreviewRollback membatalkan transaction pratinjau auto-assign
+var errPreviewRollback = errors.New("pratinjau auto-assign")
+
+// PenghuluRecommendation adalah peringkat satu penghulu untuk sebuah pendaftaran.
+// Skor 0-100: makin tinggi makin cocok. Penghulu yang tidak layak (bentrok) selalu di urutan bawah.
+type PenghuluRecommendation struct {
+	PenghuluID       uint     `json:"penghulu_id"`
+	Nama             string   `json:"nama"`
+	Skor             float64  `json:"skor"`
+	Layak            bool     `json:"layak"`
+	KonflikTipe      string   `json:"konflik_tipe,omitempty"`
+	KonflikPesan     string   `json:"konflik_pesan,omitempty"`
+	JadwalHariIni    int      `json:"jadwal_hari_ini"`
+	BebanBulanIni    int      `json:"beban_bulan_ini"`
+	JarakTerdekatKm  *float64 `json:"jarak_terdekat_km,omitempty"`
+	WaktuTempuhMenit *int     `json:"waktu_tempuh_menit,omitempty"`
+	Alasan           []string `json:"alasan"`
+}
+
+// AutoAssignItem adalah satu pasangan pendaftaran-penghulu pada auto-assign
+type AutoAssignItem struct {
+	PendaftaranID uint `json:"pendaftaran_id"`
+	PenghuluID    uint `json:"penghulu_id"`
+}
+
+// AutoAssignResult adalah hasil pratinjau atau konfirmasi auto-assign untuk satu pendaftaran
+type AutoAssignResult struct {
+	PendaftaranID    uint    `json:"pendaftaran_id"`
+	NomorPendaftaran string  `json:"nomor_pendaftaran"`
+	TanggalNikah     string  `json:"tanggal_nikah"`
+	WaktuNikah       string  `json:"waktu_nikah"`
+	TempatNikah      string  `json:"tempat_nikah"`
+	PenghuluID       uint    `json:"penghulu_id,omitempty"`
+	PenghuluNama     string  `json:"penghulu_nama,omitempty"`
+	PenghuluUserID   string  `json:"-"`
+	Skor             float64 `json:"skor"`
+	Berhasil         bool    `json:"berhasil"`
+	Pesan            string  `json:"pesan,omitempty"`
+}
+
+// PenghuluRecommenderService memberi peringkat penghulu untuk pendaftaran dan menjalankan auto-assign
+type PenghuluRecommenderService struct {
+	DB *gorm.DB
+}
+
+// NewPenghuluRecommenderService membuat instance baru dari PenghuluRecommenderService
+func NewPenghuluRecommenderService(db *gorm.DB) *PenghuluRecommenderService {
+	return &PenghuluRecommenderService{DB: db}
+}
+
+// Recommend memberi peringkat semua penghulu aktif di KUA pendaftaran berdasarkan ketersediaan,
+// jadwal harian, jeda antar akad, jarak/waktu tempuh ke akad lain di hari yang sama, dan
+// pemerataan beban bulanan
+func (rs *PenghuluRecommenderService) Recommend(p *structs.PendaftaranNikah) ([]PenghuluRecommendation, error) {
+	var kua structs.KUA
+	if err := rs.DB.First(&kua, p.Kua_id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrKUANotFound
+		}
+		return nil, err
+	}
+	rules := RulesForKUA(&kua)
+
+	var penghulu []structs.Penghulu
+	if err := rs.DB.Scopes(TenantScope(kua.ID)).Where("status = ?", structs.PenghuluStatusAktif).
+		Order("id ASC").Find(&penghulu).Error; err != nil {
+		return nil, err
+	}
+
+	// Jadwal semua penghulu pada tanggal akad (tanpa pendaftaran ini)
+	var jadwalHariIni []structs.PendaftaranNikah
+	if err := rs.DB.Scopes(TenantScope(kua.ID)).
+		Where("DATE(tanggal_nikah) = ? AND status_pendaftaran IN ? AND penghulu_id IS NOT NULL AND id <> ?",
+			p.Tanggal_nikah.Format("2006-01-02"), ScheduledStatuses, p.ID).
+		Find(&jadwalHariIni).Error; err != nil {
+		return nil, err
+	}
+	jadwalPerPenghulu := make(map[uint][]structs.PendaftaranNikah)
+	for _, j := range jadwalHariIni {
+		jadwalPerPenghulu[*j.Penghulu_id] = append(jadwalPerPenghulu[*j.Penghulu_id], j)
+	}
+
+	beban, err := rs.monthlyLoad(kua.ID, p)
+	if err != nil {
+		return nil, err
+	}
+	bebanMin := -1
+	for _, ph := range penghulu {
+		if bebanMin < 0 || beban[ph.ID] < bebanMin {
+			bebanMin = beban[ph.ID]
+		}
+	}
+
+	lokasi, adaLokasi := ceremonyLocation(p, &kua)
+	estimator := NewTravelEstimator(rules)
+	schedulingService := NewSchedulingRuleService(rs.DB)
+
+	result := make([]PenghuluRecommendation, 0, len(penghulu))
+	for _, ph := range penghulu {
+		rec := PenghuluRecommendation{
+			PenghuluID:    ph.ID,
+			Nama:          ph.Nama_lengkap,
+			Layak:         true,
+			JadwalHariIni: len(jadwalPerPenghulu[ph.ID]),
+			BebanBulanIni: beban[ph.ID],
+			Alasan:        []string{},
+		}
+
+		// Ketidaksediaan, kuota, jeda, dan waktu tempuh antar akad
+		schedulingService.PrefetchTravel(p, ph.ID)
+		if _, err := schedulingService.CheckPenghuluAssignment(p, ph.ID); err != nil {
+			var conflict *ScheduleConflict
+			if !errors.As(err, &conflict) {
+				return nil, err
+			}
+			rec.Layak = false
+			rec.KonflikTipe = conflict.Type
+			rec.KonflikPesan = conflict.Message
+		}
+
+		// Jarak ke akad terdekat penghulu di hari yang sama
+		if adaLokasi {
+			for _, j := range jadwalPerPenghulu[ph.ID] {
+				jLokasi, ok := ceremonyLocation(&j, &kua)
+				if !ok {
+					continue
+				}
+				route := estimator.Estimate(lokasi, jLokasi)
+				if rec.JarakTerdekatKm == nil || route.JarakKm < *rec.JarakTerdekatKm {
+					jarak, tempuh := route.JarakKm, route.DurasiMenit
+					rec.JarakTerdekatKm = &jarak
+					rec.WaktuTempuhMenit = &tempuh
+				}
+			}
+		}
+
+		rec.Skor = ScorePenghulu(rec.JadwalHariIni, rules.MaksNikahPenghuluHarian, rec.BebanBulanIni, bebanMin, rec.JarakTerdekatKm)
+		rec.Alasan = append(rec.Alasan, fmt.Sprintf("%d jadwal pada hari akad (maksimal %d)", rec.JadwalHariIni, rules.MaksNikahPenghuluHarian))
+		rec.Alasan = append(rec.Alasan, fmt.Sprintf("%d nikah bulan ini (terendah di KUA %d)", rec.BebanBulanIni, bebanMin))
+		if rec.JarakTerdekatKm != nil {
+			rec.Alasan = append(rec.Alasan, fmt.Sprintf("akad terdekat %.1f km (±%d menit perjalanan)", *rec.JarakTerdekatKm, *rec.WaktuTempuhMenit))
+		}
+		if !rec.Layak {
+			rec.Skor = 0
+			rec.Alasan = append(rec.Alasan, rec.KonflikPesan)
+		}
+		result = append(result, rec)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Layak != result[j].Layak {
+			return result[i].Layak
+		}
+		if result[i].Skor != result[j].Skor {
+			return result[i].Skor > result[j].Skor
+		}
+		return result[i].BebanBulanIni < result[j].BebanBulanIni
+	})
+	return result, nil
+}
+
+// PreviewAutoAssign menyusun rencana penugasan untuk semua pendaftaran "Menunggu Penugasan" tanpa penghulu,
+// urut tanggal dan waktu akad. Setiap penugasan dicoba di dalam transaction yang dibatalkan di akhir
+// sehingga rekomendasi berikutnya sudah memperhitungkan penugasan sebelumnya, tanpa menyimpan apa pun.
+func (rs *PenghuluRecommenderService) PreviewAutoAssign(kuaID uint, actor TransitionActor) ([]AutoAssignResult, error) {
+	results := make([]AutoAssignResult, 0)
+
+	err := rs.DB.Transaction(func(tx *gorm.DB) error {
+		var pending []structs.PendaftaranNikah
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		if err := tx.Scopes(TenantScope(kuaID)).
+			Where("status_pendaftaran = ? AND penghulu_id IS NULL AND tanggal_nikah >= ?", structs.StatusPendaftaranMenungguPenugasan, today).
+			Order("tanggal_nikah ASC, waktu_nikah ASC").Find(&pending).Error; err != nil {
+			return err
+		}
+
+		recommender := NewPenghuluRecommenderService(tx)
+		for i := range pending {
+			p := &pending[i]
+			res := newAutoAssignResult(p)
+
+			recs, err := recommender.Recommend(p)
+			if err != nil {
+				return err
+			}
+			if len(recs) == 0 || !recs[0].Layak {
+				res.Pesan = "Tidak ada penghulu yang tersedia untuk jadwal ini"
+				results = append(results, res)
+				continue
+			}
+
+			best := recs[0]
+			if _, err := AssignPenghulu(tx, p, best.PenghuluID, actor); err != nil {
+				res.Pesan = err.Error()
+				results = append(results, res)
+				continue
+			}
+			res.PenghuluID = best.PenghuluID
+			res.PenghuluNama = best.Nama
+			res.Skor = best.Skor
+			res.Berhasil = true
+			results = append(results, res)
+		}
+		return errPreviewRollback
+	})
+	if err != nil && !errors.Is(err, errPreviewRollback) {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ConfirmAutoAssign menjalankan rencana auto-assign (hasil pratinjau, boleh diubah kepala KUA).
+// Setiap pasangan divalidasi ulang dengan Recommend; yang gagal dilaporkan tanpa membatalkan yang lain.
+func (rs *PenghuluRecommenderService) ConfirmAutoAssign(kuaID uint, items []AutoAssignItem, actor TransitionActor) ([]AutoAssignResult, error) {
+	results := make([]AutoAssignResult, 0, len(items))
+	for _, item := range items {
+		var p structs.PendaftaranNikah
+		if err := rs.DB.Scopes(TenantScope(kuaID)).First(&p, item.PendaftaranID).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+			results = append(results, AutoAssignResult{PendaftaranID: item.PendaftaranID, Pesan: "Pendaftaran tidak ditemukan"})
+			continue
+		}
+
+		res := newAutoAssignResult(&p)
+		res.PenghuluID = item.PenghuluID
+		if p.Status_pendaftaran != structs.StatusPendaftaranMenungguPenugasan || p.Penghulu_id != nil {
+			res.Pesan = "Pendaftaran tidak lagi menunggu penugasan penghulu"
+			results = append(results, res)
+			continue
+		}
+
+		recs, err := rs.Recommend(&p)
+		if err != nil {
+			return nil, err
+		}
+		var chosen *PenghuluRecommendation
+		for i := range recs {
+			if recs[i].PenghuluID == item.PenghuluID {
+				chosen = &recs[i]
+				break
+			}
+		}
+		switch {
+		case chosen == nil:
+			res.Pesan = "Penghulu tidak ditemukan atau tidak aktif"
+		case !chosen.Layak:
+			res.PenghuluNama = chosen.Nama
+			res.Pesan = chosen.KonflikPesan
+		default:
+			res.PenghuluNama = chosen.Nama
+			res.Skor = chosen.Skor
+			if _, err := AssignPenghulu(rs.DB, &p, chosen.PenghuluID, actor); err != nil {
+				res.Pesan = err.Error()
+			} else {
+				var penghulu structs.Penghulu
+				rs.DB.Select("user_id").First(&penghulu, chosen.PenghuluID)
+				res.PenghuluUserID = penghulu.User_id
+				res.Berhasil = true
+			}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// AssignPenghulu menugaskan penghulu ke pendaftaran dan menjalankan transisi ke "Menunggu Verifikasi Penghulu".
+// Pendaftaran dimuat ulang dan penghulu dikunci sebelum aturan penjadwalan diperiksa, semuanya di dalam satu
+// transaksi, sehingga dua penugasan bersamaan tidak bisa melampaui kuota atau jeda penghulu. Mengembalikan
+// jumlah jadwal lain penghulu pada tanggal tersebut. Pemanggil bertanggung jawab atas notifikasi.
+func AssignPenghulu(db *gorm.DB, p *structs.PendaftaranNikah, penghuluID uint, actor TransitionActor) (int, error) {
+	// Rute perjalanan diminta sebelum kunci diambil; pengecekan di dalam transaction memakai cache
+	NewSchedulingRuleService(db).PrefetchTravel(p, penghuluID)
+
+	var count int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := LockPendaftaran(tx, p); err != nil {
+			return err
+		}
+		if err := LockPenghulu(tx, penghuluID); err != nil {
+			return err
+		}
+		var err error
+		count, err = NewSchedulingRuleService(tx).CheckPenghuluAssignment(p, penghuluID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		p.Penghulu_id = &penghuluID
+		p.Penghulu_assigned_by = actor.UserID
+		p.Penghulu_assigned_at = &now
+
+		_, err = NewStatusTransitionService(tx).Apply(p, structs.StatusPendaftaranMenungguVerifikasiPenghulu, actor, "")
+		return err
+	})
+	if err != nil {
+		p.Penghulu_id = nil
+		p.Penghulu_assigned_by = ""
+		p.Penghulu_assigned_at = nil
+	}
+	return count, err
+}
+
+// ScorePenghulu menghitung skor 0-100 penghulu yang layak: dikurangi jadwal harian yang sudah terisi,
+// beban bulanan di atas penghulu dengan beban terendah, dan jarak ke akad terdekat di hari yang sama
+func ScorePenghulu(jadwalHariIni, maksHarian, bebanBulan, bebanMin int, jarakKm *float64) float64 {
+	skor := 100.0
+
+	if maksHarian > 0 {
+		skor -= 40 * float64(jadwalHariIni) / float64(maksHarian)
+	}
+	if selisih := bebanBulan - bebanMin; selisih > 0 {
+		skor -= math.Min(30, 3*float64(selisih))
+	}
+	if jarakKm != nil {
+		skor -= math.Min(30, *jarakKm)
+	}
+
+	if skor < 0 {
+		skor = 0
+	}
+	return math.Round(skor*10) / 10
+}
+
+// monthlyLoad menghitung jumlah nikah terjadwal per penghulu pada bulan akad (tanpa pendaftaran ini)
+func (rs *PenghuluRecommenderService) monthlyLoad(kuaID uint, p *structs.PendaftaranNikah) (map[uint]int, error) {
+	awal := time.Date(p.Tanggal_nikah.Year(), p.Tanggal_nikah.Month(), 1, 0, 0, 0, 0, p.Tanggal_nikah.Location())
+	akhir := awal.AddDate(0, 1, 0)
+
+	var rows []struct {
+		PenghuluID uint
+		Jumlah     int
+	}
+	if err := rs.DB.Model(&structs.PendaftaranNikah{}).Scopes(TenantScope(kuaID)).
+		Select("penghulu_id, COUNT(*) AS jumlah").
+		Where("penghulu_id IS NOT NULL AND tanggal_nikah >= ? AND tanggal_nikah < ? AND status_pendaftaran IN ? AND id <> ?",
+			awal, akhir, ScheduledStatuses, p.ID).
+		Group("penghulu_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	beban := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		beban[row.PenghuluID] = row.Jumlah
+	}
+	return beban, nil
+}
+
+// newAutoAssignResult mengisi data pendaftaran pada hasil auto-assign
+func newAutoAssignResult(p *structs.PendaftaranNikah) AutoAssignResult {
+	return AutoAssignResult{
+		PendaftaranID:    p.ID,
+		NomorPendaftaran: p.Nomor_pendaftaran,
+		TanggalNikah:     p.Tanggal_nikah.Format("2006-01-02"),
+		WaktuNikah:       p.Waktu_nikah,
+		TempatNikah:      p.Tempat_nikah,
+	}
+}
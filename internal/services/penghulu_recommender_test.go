@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/utils"
+)
+
+func TestScorePenghulu(t *testing.T) {
+	jarak := 12.0
+	jauh := 80.0
+
+	tests := []struct {
+		name                          string
+		jadwal, maks, beban, bebanMin int
+		jarakKm                       *float64
+		want                          float64
+	}{
+		{"kosong", 0, 3, 4, 4, nil, 100},
+		{"jadwal harian", 1, 2, 0, 0, nil, 80},
+		{"beban bulanan di atas minimum", 0, 3, 6, 2, nil, 88},
+		{"beban bulanan dibatasi", 0, 3, 40, 0, nil, 70},
+		{"jarak antar akad", 0, 3, 0, 0, &jarak, 88},
+		{"jarak dibatasi", 0, 3, 0, 0, &jauh, 70},
+		{"tanpa batas harian", 5, 0, 0, 0, nil, 100},
+		{"semua penalti", 3, 3, 20, 0, &jauh, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScorePenghulu(tt.jadwal, tt.maks, tt.beban, tt.bebanMin, tt.jarakKm); got != tt.want {
+				t.Fatalf("ScorePenghulu() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHaversineKm(t *testing.T) {
+	// Monas (Jakarta) ke Gedung Sate (Bandung) sekitar 119 km (garis lurus)
+	got := utils.HaversineKm(-6.175392, 106.827153, -6.902464, 107.618781)
+	if math.Abs(got-119) > 2 {
+		t.Fatalf("HaversineKm() = %.1f, want sekitar 119", got)
+	}
+	if d := utils.HaversineKm(-6.2, 106.8, -6.2, 106.8); d != 0 {
+		t.Fatalf("HaversineKm() titik sama = %v, want 0", d)
+	}
+}
+
+func TestAssignPenghulu(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	penghulu := createTestPenghulu(t, db, "PGH1", kua.ID)
+	kepala := TransitionActor{UserID: "KPL1", Role: structs.UserRoleKepalaKUA, KuaID: kua.ID}
+
+	hari := time.Now().UTC().AddDate(0, 1, 0)
+	tanggal := time.Date(hari.Year(), hari.Month(), hari.Day(), 0, 0, 0, 0, time.UTC)
+	pertama := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Tanggal_nikah:      tanggal,
+		Waktu_nikah:        "09:00",
+		Status_pendaftaran: structs.StatusPendaftaranMenungguPenugasan,
+	})
+	kedua := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Tanggal_nikah:      tanggal,
+		Waktu_nikah:        "09:30",
+		Status_pendaftaran: structs.StatusPendaftaranMenungguPenugasan,
+	})
+
+	// Salinan dibaca sebelum penugasan pertama, seperti dua request kepala KUA yang berjalan bersamaan
+	basiPertama, basiKedua := pertama, kedua
+
+	count, err := AssignPenghulu(db, &pertama, penghulu.ID, kepala)
+	if err != nil || count != 0 {
+		t.Fatalf("AssignPenghulu(pertama) = %d, %v, want 0, nil", count, err)
+	}
+	if pertama.Status_pendaftaran != structs.StatusPendaftaranMenungguVerifikasiPenghulu || pertama.Penghulu_id == nil {
+		t.Errorf("pertama setelah assign = status %q, penghulu %v", pertama.Status_pendaftaran, pertama.Penghulu_id)
+	}
+
+	// Jadwal penghulu dicek ulang di dalam transaksi, bukan dari data yang dibaca sebelumnya
+	_, err = AssignPenghulu(db, &basiKedua, penghulu.ID, kepala)
+	var conflict *ScheduleConflict
+	if !errors.As(err, &conflict) || conflict.Type != ScheduleConflictJeda {
+		t.Fatalf("AssignPenghulu(kedua) error = %v, want ScheduleConflict jeda", err)
+	}
+	if basiKedua.Penghulu_id != nil {
+		t.Errorf("kedua.Penghulu_id = %v setelah gagal, want nil", *basiKedua.Penghulu_id)
+	}
+	var saved structs.PendaftaranNikah
+	db.First(&saved, kedua.ID)
+	if saved.Penghulu_id != nil || saved.Status_pendaftaran != structs.StatusPendaftaranMenungguPenugasan {
+		t.Errorf("kedua tersimpan = status %q, penghulu %v, want tidak berubah", saved.Status_pendaftaran, saved.Penghulu_id)
+	}
+
+	// Status yang sudah berubah sejak dibaca ditolak sebagai konflik
+	_, err = AssignPenghulu(db, &basiPertama, penghulu.ID, kepala)
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) || transitionErr.Type != TransitionErrorConflict {
+		t.Errorf("AssignPenghulu(status basi) error = %v, want TransitionError conflict", err)
+	}
+}
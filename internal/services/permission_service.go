@@ -0,0 +1,292 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PermissionCacheTTL adalah lama matriks izin disimpan di memori sebelum dibaca ulang dari database
+const PermissionCacheTTL = time.Minute
+
+var (
+	// ErrUnknownPermission dikembalikan jika nama izin tidak ada di katalog
+	ErrUnknownPermission = errors.New("izin tidak dikenal")
+	// ErrUnknownRole dikembalikan jika role tidak bisa diberi izin
+	ErrUnknownRole = errors.New("role tidak dikenal")
+	// ErrPermissionLockout dikembalikan jika perubahan akan membuat Kepala KUA kehilangan izin mengelola izin
+	ErrPermissionLockout = errors.New("izin izin.manage tidak boleh dicabut dari kepala_kua")
+)
+
+// Permission adalah satu izin di katalog beserta role yang mendapatkannya secara default
+type Permission struct {
+	Name         string   `json:"izin"`
+	Deskripsi    string   `json:"deskripsi"`
+	DefaultRoles []string `json:"default_roles"`
+}
+
+// PermissionCatalog adalah daftar semua izin yang dikenal aplikasi.
+// Default role mengikuti pembatasan role lama di route agar perilaku tidak berubah setelah migrasi.
+var PermissionCatalog = []Permission{
+	{structs.IzinPendaftaranViewAll, "Melihat semua pendaftaran nikah", []string{structs.UserRoleStaff, structs.UserRoleKepalaKUA}},
+	{structs.IzinPendaftaranVerifyFormulir, "Verifikasi formulir pendaftaran", []string{structs.UserRoleStaff}},
+	{structs.IzinPendaftaranVerifyBerkas, "Verifikasi berkas fisik pendaftaran", []string{structs.UserRoleStaff}},
+	{structs.IzinPendaftaranVerifyPenghulu, "Verifikasi dokumen oleh penghulu", []string{structs.UserRolePenghulu}},
+	{structs.IzinPendaftaranUpdateStatus, "Mengubah status pendaftaran", []string{structs.UserRoleStaff, structs.UserRolePenghulu, structs.UserRoleKepalaKUA}},
+	{structs.IzinPendaftaranUpdateAlamat, "Mengubah alamat akad nikah", []string{structs.UserRoleStaff, structs.UserRoleKepalaKUA}},
+	{structs.IzinPendaftaranCompleteBimbingan, "Menandai bimbingan perkawinan selesai", []string{structs.UserRoleStaff, structs.UserRoleKepalaKUA}},
+	{structs.IzinPendaftaranCompleteNikah, "Menandai pernikahan selesai", []string{structs.UserRoleStaff, structs.UserRoleKepalaKUA}},
+	{structs.IzinPenghuluAssign, "Menugaskan dan mengganti penghulu", []string{structs.UserRoleKepalaKUA}},
+	{structs.IzinPenghuluViewAssigned, "Melihat pendaftaran yang ditugaskan", []string{structs.UserRolePenghulu}},
+	{structs.IzinPenghuluManage, "Menambah dan mengubah data penghulu", []string{structs.UserRoleKepalaKUA}},
+	{structs.IzinStaffManage, "Mengelola staff dan undangan staff", []string{structs.UserRoleKepalaKUA}},
+	{structs.IzinJadwalManage, "Membuat dan mengubah jadwal nikah", []string{structs.UserRoleStaff, structs.UserRoleKepalaKUA}},
+	{structs.IzinBimbinganManage, "Mengelola sesi bimbingan perkawinan", []string{structs.UserRoleStaff, structs.UserRoleKepalaKUA}},
+	{structs.IzinNotifikasiManage, "Mengirim notifikasi dan menjalankan reminder", []string{structs.UserRoleStaff, structs.UserRoleKepalaKUA}},
+	{structs.IzinAkunManage, "Mengubah status, membuka kunci, dan reset 2FA akun", []string{structs.UserRoleKepalaKUA}},
+	{structs.IzinAkunMfa, "Mengelola 2FA akun sendiri", []string{structs.UserRoleStaff, structs.UserRolePenghulu, structs.UserRoleKepalaKUA}},
+	{structs.IzinAuditView, "Melihat audit login", []string{structs.UserRoleKepalaKUA}},
+	{structs.IzinPengaturanManage, "Mengubah pengaturan sistem", []string{structs.UserRoleKepalaKUA}},
+	{structs.IzinIzinManage, "Mengelola pemetaan izin ke role", []string{structs.UserRoleKepalaKUA}},
+	{structs.IzinKuaManage, "Mengelola data KUA dan memindahkan user antar KUA", []string{structs.UserRoleKepalaKUA}},
+	{structs.IzinHariLiburManage, "Mengelola hari libur dan penutupan KUA", []string{structs.UserRoleKepalaKUA}},
+	{structs.IzinPenghuluRequestCuti, "Mengajukan cuti dan ketidaksediaan penghulu", []string{structs.UserRolePenghulu}},
+	{structs.IzinPenghuluApproveCuti, "Menyetujui cuti dan memblokir jadwal penghulu", []string{structs.UserRoleKepalaKUA}},
+	{structs.IzinDokumenReview, "Memeriksa berkas persyaratan yang diunggah catin", []string{structs.UserRoleStaff, structs.UserRolePenghulu}},
+}
+
+// PermissionRoles adalah role yang izinnya diatur lewat tabel izin_roles
+var PermissionRoles = []string{
+	structs.UserRoleUserBiasa,
+	structs.UserRoleStaff,
+	structs.UserRolePenghulu,
+	structs.UserRoleKepalaKUA,
+}
+
+// GlobalPermissions adalah izin lintas KUA yang melekat pada role di luar matriks izin_roles.
+// Izin ini tidak ada di PermissionCatalog sehingga Kepala KUA tidak bisa memberikannya ke role lain.
+var GlobalPermissions = map[string][]string{
+	structs.UserRoleSuperAdmin: {structs.IzinKuaCreate, structs.IzinKuaManage},
+}
+
+// permissionMatrix adalah isi tabel izin_roles: kua_id -> role -> izin -> diizinkan
+type permissionMatrix map[uint]map[string]map[string]bool
+
+// allowed mengembalikan izin efektif: baris KUA jika ada, selain itu baris default (kua_id 0)
+func (m permissionMatrix) allowed(kuaID uint, role, permission string) bool {
+	if allowed, ok := m[kuaID][role][permission]; ok {
+		return allowed
+	}
+	return m[0][role][permission]
+}
+
+// permissionCache menyimpan matriks izin semua KUA agar middleware tidak query di setiap request
+var permissionCache struct {
+	sync.RWMutex
+	matrix   permissionMatrix
+	loadedAt time.Time
+}
+
+// PermissionService untuk membaca dan mengubah pemetaan izin ke role
+type PermissionService struct {
+	DB *gorm.DB
+}
+
+// NewPermissionService membuat instance baru dari PermissionService
+func NewPermissionService(db *gorm.DB) *PermissionService {
+	return &PermissionService{DB: db}
+}
+
+// IsKnownPermission mengecek apakah nama izin ada di katalog
+func IsKnownPermission(name string) bool {
+	for _, p := range PermissionCatalog {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsKnownRole mengecek apakah role bisa diberi izin
+func IsKnownRole(role string) bool {
+	for _, r := range PermissionRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureDefaults menambahkan baris default izin_roles (kua_id 0) yang belum ada dengan nilai dari katalog.
+// Baris yang sudah ada (termasuk yang sudah diubah Kepala KUA) tidak disentuh.
+func (ps *PermissionService) EnsureDefaults() (int64, error) {
+	now := time.Now()
+	rows := make([]structs.IzinRole, 0, len(PermissionCatalog)*len(PermissionRoles))
+	for _, p := range PermissionCatalog {
+		for _, role := range PermissionRoles {
+			rows = append(rows, structs.IzinRole{
+				Role:       role,
+				Izin:       p.Name,
+				Diizinkan:  containsString(p.DefaultRoles, role),
+				Created_at: now,
+				Updated_at: now,
+			})
+		}
+	}
+
+	result := ps.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows)
+	if result.Error != nil {
+		return 0, fmt.Errorf("gagal menyimpan izin default: %v", result.Error)
+	}
+	invalidatePermissionCache()
+	return result.RowsAffected, nil
+}
+
+// HasPermission mengecek apakah role memiliki izin tertentu di sebuah KUA
+func (ps *PermissionService) HasPermission(kuaID uint, role, permission string) (bool, error) {
+	if containsString(GlobalPermissions[role], permission) {
+		return true, nil
+	}
+	matrix, err := ps.matrix()
+	if err != nil {
+		return false, err
+	}
+	return matrix.allowed(kuaID, role, permission), nil
+}
+
+// PermissionsForRole mengembalikan daftar izin efektif sebuah role di sebuah KUA (terurut)
+func (ps *PermissionService) PermissionsForRole(kuaID uint, role string) ([]string, error) {
+	matrix, err := ps.matrix()
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := append([]string{}, GlobalPermissions[role]...)
+	for _, p := range PermissionCatalog {
+		if matrix.allowed(kuaID, role, p.Name) && !containsString(permissions, p.Name) {
+			permissions = append(permissions, p.Name)
+		}
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+// Matrix mengembalikan pemetaan lengkap role -> daftar izin yang diizinkan di sebuah KUA
+func (ps *PermissionService) Matrix(kuaID uint) (map[string][]string, error) {
+	result := make(map[string][]string, len(PermissionRoles))
+	for _, role := range PermissionRoles {
+		permissions, err := ps.PermissionsForRole(kuaID, role)
+		if err != nil {
+			return nil, err
+		}
+		result[role] = permissions
+	}
+	return result, nil
+}
+
+// SetRolePermissions mengganti seluruh izin sebuah role di sebuah KUA dengan daftar yang diberikan.
+// Izin di katalog yang tidak ada di daftar akan dicabut dari role tersebut; KUA lain tidak terpengaruh.
+func (ps *PermissionService) SetRolePermissions(kuaID uint, role string, permissions []string, diubahOleh string) error {
+	if kuaID == 0 {
+		return ErrKUARequired
+	}
+	if !IsKnownRole(role) {
+		return ErrUnknownRole
+	}
+
+	granted := make(map[string]bool, len(permissions))
+	for _, name := range permissions {
+		if !IsKnownPermission(name) {
+			return fmt.Errorf("%w: %s", ErrUnknownPermission, name)
+		}
+		granted[name] = true
+	}
+	if role == structs.UserRoleKepalaKUA && !granted[structs.IzinIzinManage] {
+		return ErrPermissionLockout
+	}
+
+	err := ps.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for _, p := range PermissionCatalog {
+			row := structs.IzinRole{
+				Kua_id:      kuaID,
+				Role:        role,
+				Izin:        p.Name,
+				Diizinkan:   granted[p.Name],
+				Diubah_oleh: diubahOleh,
+				Created_at:  now,
+				Updated_at:  now,
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "kua_id"}, {Name: "role"}, {Name: "izin"}},
+				DoUpdates: clause.AssignmentColumns([]string{"diizinkan", "diubah_oleh", "updated_at"}),
+			}).Create(&row).Error; err != nil {
+				return fmt.Errorf("gagal menyimpan izin %s: %v", p.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidatePermissionCache()
+	return nil
+}
+
+// matrix membaca matriks izin dari cache, atau dari database jika cache sudah kedaluwarsa
+func (ps *PermissionService) matrix() (permissionMatrix, error) {
+	permissionCache.RLock()
+	if permissionCache.matrix != nil && time.Since(permissionCache.loadedAt) < PermissionCacheTTL {
+		matrix := permissionCache.matrix
+		permissionCache.RUnlock()
+		return matrix, nil
+	}
+	permissionCache.RUnlock()
+
+	// Baris yang mencabut izin ikut dibaca karena menimpa default untuk KUA tersebut
+	var rows []structs.IzinRole
+	if err := ps.DB.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("gagal membaca izin: %v", err)
+	}
+
+	matrix := make(permissionMatrix)
+	for _, row := range rows {
+		if matrix[row.Kua_id] == nil {
+			matrix[row.Kua_id] = make(map[string]map[string]bool)
+		}
+		if matrix[row.Kua_id][row.Role] == nil {
+			matrix[row.Kua_id][row.Role] = make(map[string]bool)
+		}
+		matrix[row.Kua_id][row.Role][row.Izin] = row.Diizinkan
+	}
+
+	permissionCache.Lock()
+	permissionCache.matrix = matrix
+	permissionCache.loadedAt = time.Now()
+	permissionCache.Unlock()
+
+	return matrix, nil
+}
+
+func invalidatePermissionCache() {
+	permissionCache.Lock()
+	permissionCache.matrix = nil
+	permissionCache.Unlock()
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// newPermissionTestDB membuat database uji berisi izin default. Cache izin bersifat global,
+// sehingga dikosongkan sebelum dan sesudah test agar tidak terbawa dari database lain.
+func newPermissionTestDB(t *testing.T) (*gorm.DB, *PermissionService) {
+	t.Helper()
+
+	invalidatePermissionCache()
+	t.Cleanup(invalidatePermissionCache)
+
+	db := newTestDB(t)
+	ps := NewPermissionService(db)
+	if _, err := ps.EnsureDefaults(); err != nil {
+		t.Fatalf("EnsureDefaults() error = %v", err)
+	}
+	return db, ps
+}
+
+func TestPermissionDefaults(t *testing.T) {
+	_, ps := newPermissionTestDB(t)
+
+	// Matriks default mengikuti DefaultRoles di katalog untuk setiap role
+	for _, p := range PermissionCatalog {
+		for _, role := range PermissionRoles {
+			got, err := ps.HasPermission(1, role, p.Name)
+			if err != nil {
+				t.Fatalf("HasPermission() error = %v", err)
+			}
+			if want := containsString(p.DefaultRoles, role); got != want {
+				t.Errorf("HasPermission(%s, %s) = %v, want %v", role, p.Name, got, want)
+			}
+		}
+	}
+
+	// Baris default yang sudah ada tidak ditimpa
+	if n, err := ps.EnsureDefaults(); err != nil || n != 0 {
+		t.Errorf("EnsureDefaults() kedua = %d, %v, want 0", n, err)
+	}
+}
+
+func TestPermissionGlobal(t *testing.T) {
+	_, ps := newPermissionTestDB(t)
+
+	for _, permission := range GlobalPermissions[structs.UserRoleSuperAdmin] {
+		for _, kuaID := range []uint{0, 1, 2} {
+			if ok, _ := ps.HasPermission(kuaID, structs.UserRoleSuperAdmin, permission); !ok {
+				t.Errorf("HasPermission(super_admin, %s, KUA %d) = false", permission, kuaID)
+			}
+		}
+	}
+	if ok, _ := ps.HasPermission(1, structs.UserRoleKepalaKUA, structs.IzinKuaCreate); ok {
+		t.Error("kepala_kua mendapat izin global kua.create")
+	}
+	if IsKnownPermission(structs.IzinKuaCreate) {
+		t.Error("izin global ada di katalog sehingga bisa diberikan Kepala KUA")
+	}
+	if err := ps.SetRolePermissions(1, structs.UserRoleStaff, []string{structs.IzinKuaCreate}, "KPL1"); !errors.Is(err, ErrUnknownPermission) {
+		t.Errorf("SetRolePermissions(izin global) error = %v, want ErrUnknownPermission", err)
+	}
+
+	permissions, _ := ps.PermissionsForRole(1, structs.UserRoleSuperAdmin)
+	for _, permission := range GlobalPermissions[structs.UserRoleSuperAdmin] {
+		if !containsString(permissions, permission) {
+			t.Errorf("PermissionsForRole(super_admin) = %v, want memuat %s", permissions, permission)
+		}
+	}
+}
+
+func TestSetRolePermissionsPerKUA(t *testing.T) {
+	_, ps := newPermissionTestDB(t)
+
+	if err := ps.SetRolePermissions(1, structs.UserRoleStaff, []string{structs.IzinPendaftaranViewAll, structs.IzinPenghuluAssign}, "KPL1"); err != nil {
+		t.Fatalf("SetRolePermissions() error = %v", err)
+	}
+
+	tests := []struct {
+		kuaID      uint
+		permission string
+		want       bool
+	}{
+		{1, structs.IzinPenghuluAssign, true},
+		{1, structs.IzinPendaftaranViewAll, true},
+		{1, structs.IzinJadwalManage, false}, // dicabut karena tidak ada di daftar
+		{2, structs.IzinPenghuluAssign, false},
+		{2, structs.IzinJadwalManage, true},
+	}
+	for _, tt := range tests {
+		if got, _ := ps.HasPermission(tt.kuaID, structs.UserRoleStaff, tt.permission); got != tt.want {
+			t.Errorf("HasPermission(KUA %d, staff, %s) = %v, want %v", tt.kuaID, tt.permission, got, tt.want)
+		}
+	}
+
+	matrix, err := ps.Matrix(1)
+	if err != nil {
+		t.Fatalf("Matrix() error = %v", err)
+	}
+	if len(matrix[structs.UserRoleStaff]) != 2 {
+		t.Errorf("Matrix(1)[staff] = %v, want 2 izin", matrix[structs.UserRoleStaff])
+	}
+
+	if err := ps.SetRolePermissions(0, structs.UserRoleStaff, nil, "KPL1"); !errors.Is(err, ErrKUARequired) {
+		t.Errorf("SetRolePermissions(KUA 0) error = %v, want ErrKUARequired", err)
+	}
+	if err := ps.SetRolePermissions(1, structs.UserRoleSuperAdmin, nil, "KPL1"); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("SetRolePermissions(super_admin) error = %v, want ErrUnknownRole", err)
+	}
+	if err := ps.SetRolePermissions(1, structs.UserRoleStaff, []string{"tidak.ada"}, "KPL1"); !errors.Is(err, ErrUnknownPermission) {
+		t.Errorf("SetRolePermissions(izin tidak dikenal) error = %v, want ErrUnknownPermission", err)
+	}
+}
+
+func TestSetRolePermissionsKepalaLockout(t *testing.T) {
+	_, ps := newPermissionTestDB(t)
+
+	if err := ps.SetRolePermissions(1, structs.UserRoleKepalaKUA, []string{structs.IzinStaffManage}, "KPL1"); !errors.Is(err, ErrPermissionLockout) {
+		t.Fatalf("SetRolePermissions(tanpa izin.manage) error = %v, want ErrPermissionLockout", err)
+	}
+	// Perubahan yang ditolak tidak menyentuh matriks
+	if ok, _ := ps.HasPermission(1, structs.UserRoleKepalaKUA, structs.IzinPenghuluAssign); !ok {
+		t.Error("izin kepala_kua berubah walaupun perubahan ditolak")
+	}
+	if err := ps.SetRolePermissions(1, structs.UserRoleKepalaKUA, []string{structs.IzinIzinManage}, "KPL1"); err != nil {
+		t.Errorf("SetRolePermissions(dengan izin.manage) error = %v", err)
+	}
+}
+
+func TestPermissionCache(t *testing.T) {
+	db, ps := newPermissionTestDB(t)
+
+	if ok, _ := ps.HasPermission(1, structs.UserRoleStaff, structs.IzinJadwalManage); !ok {
+		t.Fatal("staff tidak punya izin jadwal.manage default")
+	}
+
+	// Perubahan langsung di database baru terbaca setelah cache kedaluwarsa
+	db.Model(&structs.IzinRole{}).Where("kua_id = 0 AND role = ? AND izin = ?", structs.UserRoleStaff, structs.IzinJadwalManage).
+		Update("diizinkan", false)
+	if ok, _ := ps.HasPermission(1, structs.UserRoleStaff, structs.IzinJadwalManage); !ok {
+		t.Error("HasPermission() membaca database sebelum cache kedaluwarsa")
+	}
+	permissionCache.Lock()
+	permissionCache.loadedAt = time.Now().Add(-PermissionCacheTTL)
+	permissionCache.Unlock()
+	if ok, _ := ps.HasPermission(1, structs.UserRoleStaff, structs.IzinJadwalManage); ok {
+		t.Error("HasPermission() memakai cache yang sudah kedaluwarsa")
+	}
+
+	// SetRolePermissions langsung mengosongkan cache
+	if err := ps.SetRolePermissions(1, structs.UserRoleStaff, []string{structs.IzinJadwalManage}, "KPL1"); err != nil {
+		t.Fatalf("SetRolePermissions() error = %v", err)
+	}
+	if ok, _ := ps.HasPermission(1, structs.UserRoleStaff, structs.IzinJadwalManage); !ok {
+		t.Error("HasPermission() tidak melihat perubahan SetRolePermissions")
+	}
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/locale"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPerubahanJadwalInvalid dikembalikan jika pengajuan perubahan jadwal atau pembatalan tidak valid
+	ErrPerubahanJadwalInvalid = errors.New("pengajuan perubahan jadwal tidak valid")
+	// ErrPerubahanJadwalNotFound dikembalikan jika pengajuan tidak ada atau bukan milik KUA/catin user
+	ErrPerubahanJadwalNotFound = errors.New("pengajuan perubahan jadwal tidak ditemukan")
+	// ErrPerubahanJadwalProcessed dikembalikan jika pengajuan sudah disetujui, ditolak, atau dibatalkan
+	ErrPerubahanJadwalProcessed = errors.New("pengajuan perubahan jadwal sudah diproses")
+	// ErrPerubahanJadwalPending dikembalikan jika pendaftaran masih punya pengajuan yang menunggu persetujuan
+	ErrPerubahanJadwalPending = errors.New("masih ada pengajuan perubahan jadwal yang menunggu persetujuan")
+)
+
+// PerubahanJadwalInput adalah body pengajuan perubahan tanggal/waktu akad oleh catin
+type PerubahanJadwalInput struct {
+	Tanggal         string `json:"tanggal"` // YYYY-MM-DD
+	Waktu           string `json:"waktu"`   // HH:MM
+	Alasan          string `json:"alasan"`
+	NomorDispensasi string `json:"nomor_dispensasi"` // wajib jika jadwal baru memerlukan dispensasi
+}
+
+// Normalize merapikan dan memvalidasi pengajuan perubahan jadwal
+func (in *PerubahanJadwalInput) Normalize() error {
+	in.Tanggal = strings.TrimSpace(in.Tanggal)
+	in.Waktu = strings.TrimSpace(in.Waktu)
+	in.Alasan = strings.TrimSpace(in.Alasan)
+	in.NomorDispensasi = strings.TrimSpace(in.NomorDispensasi)
+
+	if _, err := time.Parse("2006-01-02", in.Tanggal); err != nil {
+		return fmt.Errorf("%w: tanggal harus YYYY-MM-DD", ErrPerubahanJadwalInvalid)
+	}
+	if _, err := time.Parse("15:04", in.Waktu); err != nil {
+		return fmt.Errorf("%w: waktu harus HH:MM", ErrPerubahanJadwalInvalid)
+	}
+	if in.Alasan == "" {
+		return fmt.Errorf("%w: alasan wajib diisi", ErrPerubahanJadwalInvalid)
+	}
+	if utf8.RuneCountInString(in.Alasan) > 300 {
+		return fmt.Errorf("%w: alasan maksimal 300 karakter", ErrPerubahanJadwalInvalid)
+	}
+	if len(in.NomorDispensasi) > 50 {
+		return fmt.Errorf("%w: nomor dispensasi maksimal 50 karakter", ErrPerubahanJadwalInvalid)
+	}
+	return nil
+}
+
+// PerubahanJadwalService untuk pengajuan perubahan jadwal dan pembatalan pendaftaran oleh catin
+type PerubahanJadwalService struct {
+	DB *gorm.DB
+}
+
+// NewPerubahanJadwalService membuat instance baru dari PerubahanJadwalService
+func NewPerubahanJadwalService(db *gorm.DB) *PerubahanJadwalService {
+	return &PerubahanJadwalService{DB: db}
+}
+
+// Create menyimpan pengajuan perubahan jadwal untuk pendaftaran p. Jadwal baru langsung dicek seperti perubahan
+// oleh staff (aturan KUA, hari libur, dispensasi, kuota, jadwal penghulu, slot balai KUA) agar catin tahu lebih awal
+// jika tidak mungkin disetujui; pengecekan diulang saat staff menyetujui.
+func (ps *PerubahanJadwalService) Create(p *structs.PendaftaranNikah, input PerubahanJadwalInput, diajukanOleh string) (*structs.PerubahanJadwal, *JadwalChange, error) {
+	if err := input.Normalize(); err != nil {
+		return nil, nil, err
+	}
+	if input.Tanggal == p.Tanggal_nikah.Format("2006-01-02") && input.Waktu == p.Waktu_nikah {
+		return nil, nil, fmt.Errorf("%w: jadwal baru sama dengan jadwal saat ini", ErrPerubahanJadwalInvalid)
+	}
+
+	var pending int64
+	if err := ps.DB.Model(&structs.PerubahanJadwal{}).
+		Where("pendaftaran_id = ? AND status = ?", p.ID, structs.PerubahanJadwalStatusMenunggu).
+		Count(&pending).Error; err != nil {
+		return nil, nil, err
+	}
+	if pending > 0 {
+		return nil, nil, ErrPerubahanJadwalPending
+	}
+
+	change, err := NewJadwalService(ps.DB).Check(p.Kua_id, p.ID, JadwalInput{
+		Tanggal:         input.Tanggal,
+		Waktu:           input.Waktu,
+		NomorDispensasi: input.NomorDispensasi,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	r := structs.PerubahanJadwal{
+		Kua_id:           p.Kua_id,
+		Pendaftaran_id:   p.ID,
+		Tanggal_lama:     p.Tanggal_nikah.Format("2006-01-02"),
+		Waktu_lama:       p.Waktu_nikah,
+		Tanggal_baru:     input.Tanggal,
+		Waktu_baru:       input.Waktu,
+		Nomor_dispensasi: input.NomorDispensasi,
+		Alasan:           input.Alasan,
+		Status:           structs.PerubahanJadwalStatusMenunggu,
+		Diajukan_oleh:    diajukanOleh,
+		Created_at:       now,
+		Updated_at:       now,
+	}
+	if err := ps.DB.Create(&r).Error; err != nil {
+		return nil, nil, err
+	}
+
+	ps.notifyPetugasKUA(p.Kua_id, "Pengajuan Perubahan Jadwal Nikah",
+		fmt.Sprintf("Catin %s mengajukan perubahan jadwal dari %s menjadi %s. Alasan: %s",
+			p.Nomor_pendaftaran, change.Sebelum, change.Sesudah, r.Alasan),
+		structs.NotifikasiTipeInfo, "/simnikah/perubahan-jadwal")
+	return &r, change, nil
+}
+
+// Process menyetujui atau menolak pengajuan perubahan jadwal (staff/kepala KUA).
+// Persetujuan menjalankan perubahan jadwal seperti JadwalService.Reschedule sehingga jadwal divalidasi ulang,
+// slot dipindah, dan catin serta penghulu yang ditugaskan diberi tahu. Perubahan jadwal dan status pengajuan
+// disimpan dalam satu transaction; status hanya diubah jika pengajuan masih menunggu (misalnya belum dibatalkan
+// catin di saat yang sama). Jika gagal, jadwal tidak berubah dan pengajuan tetap menunggu.
+func (ps *PerubahanJadwalService) Process(id string, kuaID uint, approve bool, actor TransitionActor, catatan string) (*structs.PerubahanJadwal, *JadwalChange, error) {
+	var r structs.PerubahanJadwal
+	if err := ps.DB.Scopes(TenantScope(kuaID)).First(&r, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrPerubahanJadwalNotFound
+		}
+		return nil, nil, err
+	}
+	if r.Status != structs.PerubahanJadwalStatusMenunggu {
+		return nil, nil, ErrPerubahanJadwalProcessed
+	}
+
+	now := time.Now()
+	status := structs.PerubahanJadwalStatusDitolak
+	if approve {
+		status = structs.PerubahanJadwalStatusDisetujui
+	}
+	catatan = strings.TrimSpace(catatan)
+
+	input := JadwalInput{
+		Tanggal:         r.Tanggal_baru,
+		Waktu:           r.Waktu_baru,
+		NomorDispensasi: r.Nomor_dispensasi,
+		Catatan:         "Pengajuan catin: " + r.Alasan,
+	}
+	if approve {
+		NewJadwalService(ps.DB).prefetchTravel(kuaID, r.Pendaftaran_id, input)
+	}
+
+	var change *JadwalChange
+	var lama structs.PendaftaranNikah
+	err := ps.DB.Transaction(func(tx *gorm.DB) error {
+		if approve {
+			var err error
+			change, lama, err = NewJadwalService(tx).rescheduleTx(kuaID, r.Pendaftaran_id, input, actor)
+			if err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&structs.PerubahanJadwal{}).
+			Where("id = ? AND status = ?", r.ID, structs.PerubahanJadwalStatusMenunggu).
+			Updates(map[string]interface{}{
+				"status":         status,
+				"diproses_oleh":  actor.UserID,
+				"diproses_pada":  now,
+				"catatan_proses": catatan,
+				"updated_at":     now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPerubahanJadwalProcessed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.Status = status
+	r.Diproses_oleh = actor.UserID
+	r.Diproses_pada = &now
+	r.Catatan_proses = catatan
+	r.Updated_at = now
+
+	if approve {
+		NewJadwalService(ps.DB).finishReschedule(kuaID, &lama, change)
+		return &r, change, nil
+	}
+
+	// Penolakan diberitahukan ke pendaftar
+	var p structs.PendaftaranNikah
+	if err := ps.DB.Select("id", "pendaftar_id", "kua_id").First(&p, r.Pendaftaran_id).Error; err == nil {
+		pesan := fmt.Sprintf("Pengajuan perubahan jadwal ke %s pukul %s ditolak.", locale.FormatTanggal(r.Tanggal_baru),
+			locale.FormatJam(r.Waktu_baru, NewKUAService(ps.DB).ZonaWaktu(p.Kua_id)))
+		if r.Catatan_proses != "" {
+			pesan += " Catatan: " + r.Catatan_proses
+		}
+		if err := NewNotificationService(ps.DB).SendSystemNotification(p.Pendaftar_id, "Pengajuan Perubahan Jadwal Ditolak",
+			pesan, structs.NotifikasiTipeWarning, fmt.Sprintf("/simnikah/pendaftaran/%d/perubahan-jadwal", p.ID)); err != nil {
+			log.Printf("Gagal mengirim notifikasi penolakan perubahan jadwal: %v", err)
+		}
+	}
+	return &r, nil, nil
+}
+
+// Cancel membatalkan pengajuan perubahan jadwal yang belum diproses milik pendaftar
+func (ps *PerubahanJadwalService) Cancel(id string, pendaftarID string) (*structs.PerubahanJadwal, error) {
+	var r structs.PerubahanJadwal
+	if err := ps.DB.First(&r, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPerubahanJadwalNotFound
+		}
+		return nil, err
+	}
+	var milik int64
+	if err := ps.DB.Model(&structs.PendaftaranNikah{}).Where("id = ? AND pendaftar_id = ?", r.Pendaftaran_id, pendaftarID).
+		Count(&milik).Error; err != nil {
+		return nil, err
+	}
+	if milik == 0 {
+		return nil, ErrPerubahanJadwalNotFound
+	}
+	if r.Status != structs.PerubahanJadwalStatusMenunggu {
+		return nil, ErrPerubahanJadwalProcessed
+	}
+
+	// Hanya pengajuan yang masih menunggu yang dibatalkan, agar tidak menimpa persetujuan yang berjalan bersamaan
+	now := time.Now()
+	result := ps.DB.Model(&structs.PerubahanJadwal{}).
+		Where("id = ? AND status = ?", r.ID, structs.PerubahanJadwalStatusMenunggu).
+		Updates(map[string]interface{}{
+			"status":        structs.PerubahanJadwalStatusDibatalkan,
+			"diproses_oleh": pendaftarID,
+			"diproses_pada": now,
+			"updated_at":    now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrPerubahanJadwalProcessed
+	}
+
+	r.Status = structs.PerubahanJadwalStatusDibatalkan
+	r.Diproses_oleh = pendaftarID
+	r.Diproses_pada = &now
+	r.Updated_at = now
+	return &r, nil
+}
+
+// List mengambil pengajuan perubahan jadwal di KUA, bisa difilter status
+func (ps *PerubahanJadwalService) List(kuaID uint, status string) ([]structs.PerubahanJadwal, error) {
+	query := ps.DB.Scopes(TenantScope(kuaID))
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var rows []structs.PerubahanJadwal
+	err := query.Order("created_at DESC, id DESC").Find(&rows).Error
+	return rows, err
+}
+
+// ListForRegistration mengambil semua pengajuan perubahan jadwal sebuah pendaftaran, terbaru lebih dulu
+func (ps *PerubahanJadwalService) ListForRegistration(pendaftaranID uint) ([]structs.PerubahanJadwal, error) {
+	var rows []structs.PerubahanJadwal
+	err := ps.DB.Where("pendaftaran_id = ?", pendaftaranID).Order("created_at DESC, id DESC").Find(&rows).Error
+	return rows, err
+}
+
+// CancelRegistration membatalkan pendaftaran lewat state machine: slot balai KUA, penugasan penghulu,
+// kursi bimbingan yang belum diikuti, dan pengajuan perubahan jadwal yang menunggu ikut dilepas.
+// Penghulu yang sebelumnya ditugaskan dan pendaftar diberi tahu; petugas KUA diberi tahu jika catin sendiri yang membatalkan.
+func (ps *PerubahanJadwalService) CancelRegistration(p *structs.PendaftaranNikah, actor TransitionActor, alasan string) error {
+	alasan = strings.TrimSpace(alasan)
+	if alasan == "" {
+		return fmt.Errorf("%w: alasan pembatalan wajib diisi", ErrPerubahanJadwalInvalid)
+	}
+	if utf8.RuneCountInString(alasan) > 300 {
+		return fmt.Errorf("%w: alasan maksimal 300 karakter", ErrPerubahanJadwalInvalid)
+	}
+
+	penghuluID := p.Penghulu_id
+	if _, err := NewStatusTransitionService(ps.DB).Apply(p, structs.StatusPendaftaranDibatalkan, actor, alasan); err != nil {
+		return err
+	}
+
+	if err := NewNotificationService(ps.DB).SendRegistrationCancelledNotification(p, penghuluID, alasan); err != nil {
+		log.Printf("Gagal mengirim notifikasi pembatalan pendaftaran: %v", err)
+	}
+	if actor.Role == structs.UserRoleUserBiasa {
+		ps.notifyPetugasKUA(p.Kua_id, "Pendaftaran Nikah Dibatalkan Catin",
+			fmt.Sprintf("Catin membatalkan pendaftaran %s. Alasan: %s", p.Nomor_pendaftaran, alasan),
+			structs.NotifikasiTipeWarning, fmt.Sprintf("/simnikah/pendaftaran/%d/status-flow", p.ID))
+	}
+	return nil
+}
+
+// notifyPetugasKUA mengirim notifikasi ke semua staff dan kepala KUA aktif di KUA tersebut
+func (ps *PerubahanJadwalService) notifyPetugasKUA(kuaID uint, judul, pesan, tipe, link string) {
+	var userIDs []string
+	if err := ps.DB.Model(&structs.Users{}).Scopes(TenantScope(kuaID)).
+		Where("role IN ? AND status = ?", petugasKUA, structs.UserStatusAktif).
+		Pluck("user_id", &userIDs).Error; err != nil || len(userIDs) == 0 {
+		return
+	}
+	if err := NewNotificationService(ps.DB).SendBulkNotification(userIDs, judul, pesan, tipe, link); err != nil {
+		log.Printf("Gagal mengirim notifikasi ke petugas KUA: %v", err)
+	}
+}
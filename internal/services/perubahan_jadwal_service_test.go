@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+)
+
+func TestPerubahanJadwalInputNormalize(t *testing.T) {
+	valid := PerubahanJadwalInput{Tanggal: " 2026-11-02 ", Waktu: "09:00", Alasan: " Keluarga berhalangan "}
+
+	tests := []struct {
+		name   string
+		modify func(in *PerubahanJadwalInput)
+		valid  bool
+	}{
+		{"lengkap", func(in *PerubahanJadwalInput) {}, true},
+		{"dengan dispensasi", func(in *PerubahanJadwalInput) { in.NomorDispensasi = "B-12/Kua.10/PW.01/2026" }, true},
+		{"tanggal salah", func(in *PerubahanJadwalInput) { in.Tanggal = "02-11-2026" }, false},
+		{"waktu salah", func(in *PerubahanJadwalInput) { in.Waktu = "9 pagi" }, false},
+		{"tanpa alasan", func(in *PerubahanJadwalInput) { in.Alasan = "   " }, false},
+		{"alasan terlalu panjang", func(in *PerubahanJadwalInput) { in.Alasan = strings.Repeat("a", 301) }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := valid
+			tt.modify(&in)
+			err := in.Normalize()
+			if tt.valid && err != nil {
+				t.Fatalf("Normalize() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrPerubahanJadwalInvalid) {
+				t.Fatalf("Normalize() = %v, want ErrPerubahanJadwalInvalid", err)
+			}
+		})
+	}
+
+	in := valid
+	if err := in.Normalize(); err != nil || in.Tanggal != "2026-11-02" || in.Alasan != "Keluarga berhalangan" {
+		t.Fatalf("Normalize() = %+v, want trimmed fields", in)
+	}
+}
+
+func TestTransisiBatal(t *testing.T) {
+	final := map[string]bool{
+		structs.StatusPendaftaranSelesai:    true,
+		structs.StatusPendaftaranDitolak:    true,
+		structs.StatusPendaftaranDibatalkan: true,
+	}
+	for _, from := range []string{
+		structs.StatusPendaftaranDraft,
+		structs.StatusPendaftaranMenungguVerifikasi,
+		structs.StatusPendaftaranMenungguPengumpulanBerkas,
+		structs.StatusPendaftaranBerkasDiterima,
+		structs.StatusPendaftaranMenungguPenugasan,
+		structs.StatusPendaftaranMenungguVerifikasiPenghulu,
+		structs.StatusPendaftaranMenungguBimbingan,
+		structs.StatusPendaftaranSudahBimbingan,
+		structs.StatusPendaftaranSelesai,
+		structs.StatusPendaftaranDitolak,
+	} {
+		transition, ok := FindTransition(from, structs.StatusPendaftaranDibatalkan)
+		if final[from] {
+			if ok {
+				t.Errorf("FindTransition(%q, Dibatalkan) ditemukan, want tidak ada", from)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("FindTransition(%q, Dibatalkan) tidak ditemukan", from)
+			continue
+		}
+		if !transition.AllowsRole(structs.UserRoleUserBiasa) || !transition.AllowsRole(structs.UserRoleStaff) || transition.AllowsRole(structs.UserRolePenghulu) {
+			t.Errorf("transisi %q -> Dibatalkan roles = %v", from, transition.Roles)
+		}
+	}
+}
+
+func TestPerubahanJadwalProcess(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	staff := TransitionActor{UserID: "STF1", Role: structs.UserRoleStaff, KuaID: kua.ID}
+
+	hari := time.Now().UTC().AddDate(0, 1, 0)
+	tanggal := time.Date(hari.Year(), hari.Month(), hari.Day(), 0, 0, 0, 0, time.UTC)
+	baru := tanggal.AddDate(0, 0, 1).Format("2006-01-02")
+
+	p := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Tanggal_nikah:      tanggal,
+		Waktu_nikah:        "09:00",
+		Status_pendaftaran: structs.StatusPendaftaranMenungguBimbingan,
+	})
+	ajukan := func() structs.PerubahanJadwal {
+		r := structs.PerubahanJadwal{
+			Kua_id:         kua.ID,
+			Pendaftaran_id: p.ID,
+			Tanggal_lama:   tanggal.Format("2006-01-02"),
+			Waktu_lama:     "09:00",
+			Tanggal_baru:   baru,
+			Waktu_baru:     "10:00",
+			Alasan:         "Keluarga berhalangan",
+			Status:         structs.PerubahanJadwalStatusMenunggu,
+			Diajukan_oleh:  p.Pendaftar_id,
+		}
+		if err := db.Create(&r).Error; err != nil {
+			t.Fatalf("create perubahan jadwal: %v", err)
+		}
+		return r
+	}
+	ps := NewPerubahanJadwalService(db)
+
+	// Catin membatalkan pengajuan tepat sebelum status disimpan: perubahan jadwal ikut dibatalkan
+	r := ajukan()
+	batalSebelumSimpan := func(tx *gorm.DB) {
+		if tx.Statement.Table == "perubahan_jadwals" {
+			tx.Session(&gorm.Session{NewDB: true}).Exec("UPDATE perubahan_jadwals SET status = ? WHERE id = ?",
+				structs.PerubahanJadwalStatusDibatalkan, r.ID)
+		}
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("test:batal_sebelum_simpan", batalSebelumSimpan); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	_, _, err := ps.Process(fmt.Sprint(r.ID), kua.ID, true, staff, "")
+	db.Callback().Update().Remove("test:batal_sebelum_simpan")
+	if !errors.Is(err, ErrPerubahanJadwalProcessed) {
+		t.Fatalf("Process(dibatalkan bersamaan) error = %v, want ErrPerubahanJadwalProcessed", err)
+	}
+	var saved structs.PendaftaranNikah
+	db.First(&saved, p.ID)
+	if !saved.Tanggal_nikah.Equal(tanggal) || saved.Waktu_nikah != "09:00" {
+		t.Errorf("jadwal = %s %s setelah persetujuan gagal, want jadwal lama", saved.Tanggal_nikah, saved.Waktu_nikah)
+	}
+	var slots int64
+	db.Model(&structs.SlotNikah{}).Where("pendaftaran_id = ?", p.ID).Count(&slots)
+	if slots != 0 {
+		t.Errorf("slot baru tetap terpesan (%d) setelah persetujuan gagal", slots)
+	}
+
+	// Persetujuan normal memindahkan jadwal dan menandai pengajuan disetujui
+	r = ajukan()
+	got, change, err := ps.Process(fmt.Sprint(r.ID), kua.ID, true, staff, "disetujui")
+	if err != nil {
+		t.Fatalf("Process(setujui) error = %v", err)
+	}
+	if got.Status != structs.PerubahanJadwalStatusDisetujui || change == nil || change.Pendaftaran.Waktu_nikah != "10:00" {
+		t.Errorf("Process(setujui) = %+v, %+v", got, change)
+	}
+	db.First(&saved, p.ID)
+	if saved.Tanggal_nikah.Format("2006-01-02") != baru || saved.Waktu_nikah != "10:00" {
+		t.Errorf("jadwal = %s %s, want %s 10:00", saved.Tanggal_nikah, saved.Waktu_nikah, baru)
+	}
+
+	// Pengajuan yang sudah diproses tidak bisa diproses atau dibatalkan lagi
+	if _, _, err := ps.Process(fmt.Sprint(r.ID), kua.ID, false, staff, ""); !errors.Is(err, ErrPerubahanJadwalProcessed) {
+		t.Errorf("Process(ulang) error = %v, want ErrPerubahanJadwalProcessed", err)
+	}
+	if _, err := ps.Cancel(fmt.Sprint(r.ID), p.Pendaftar_id); !errors.Is(err, ErrPerubahanJadwalProcessed) {
+		t.Errorf("Cancel(sudah disetujui) error = %v, want ErrPerubahanJadwalProcessed", err)
+	}
+}
@@ -0,0 +1,484 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidSchedulingRules dikembalikan jika aturan penjadwalan tidak valid
+var ErrInvalidSchedulingRules = errors.New("aturan penjadwalan tidak valid")
+
+// ScheduledStatuses adalah status pendaftaran yang sudah memakai jadwal penghulu dan kuota harian
+var ScheduledStatuses = []string{
+	structs.StatusPendaftaranMenungguVerifikasiPenghulu,
+	structs.StatusPendaftaranMenungguBimbingan,
+	structs.StatusPendaftaranSudahBimbingan,
+	structs.StatusPendaftaranSelesai,
+}
+
+// FinalStatuses adalah status akhir pendaftaran; pendaftaran dengan status ini tidak lagi memakai
+// jadwal atau penghulu
+var FinalStatuses = []string{
+	structs.StatusPendaftaranSelesai,
+	structs.StatusPendaftaranDitolak,
+	structs.StatusPendaftaranDibatalkan,
+}
+
+// IsFinalStatus mengecek apakah status termasuk FinalStatuses
+func IsFinalStatus(status string) bool {
+	for _, s := range FinalStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// SchedulingRules adalah aturan penjadwalan nikah sebuah KUA.
+// Dipakai semua handler kalender, ketersediaan, dan penugasan penghulu.
+type SchedulingRules struct {
+	KapasitasKUAHarian      int    `json:"kapasitas_nikah_harian"`     // Maksimal nikah di balai KUA per hari
+	MaksNikahPenghuluHarian int    `json:"maks_nikah_penghulu_harian"` // Maksimal nikah per penghulu per hari
+	JedaMinimalMenit        int    `json:"jeda_minimal_menit"`         // Jeda minimal antar nikah untuk penghulu yang sama
+	IntervalSlotMenit       int    `json:"interval_slot_menit"`        // Jarak antar slot waktu akad di balai KUA
+	JamMulai                string `json:"jam_mulai"`                  // HH:MM
+	JamSelesai              string `json:"jam_selesai"`                // HH:MM, jam terakhir akad boleh dimulai
+	HariTutup               []int  `json:"hari_tutup"`                 // 0 = Minggu ... 6 = Sabtu
+	KecepatanTempuhKmJam    int    `json:"kecepatan_tempuh_km_jam"`    // Kecepatan rata-rata penghulu antar lokasi akad
+}
+
+// DefaultSchedulingRules adalah aturan penjadwalan untuk KUA baru:
+// 9 nikah di KUA per hari, 3 nikah per penghulu, jeda 60 menit, slot setiap 60 menit, 08:00-16:00,
+// buka setiap hari, perjalanan antar lokasi akad 30 km/jam
+func DefaultSchedulingRules() SchedulingRules {
+	return SchedulingRules{
+		KapasitasKUAHarian:      9,
+		MaksNikahPenghuluHarian: 3,
+		JedaMinimalMenit:        60,
+		IntervalSlotMenit:       60,
+		JamMulai:                "08:00",
+		JamSelesai:              "16:00",
+		HariTutup:               []int{},
+		KecepatanTempuhKmJam:    30,
+	}
+}
+
+// RulesForKUA membaca aturan penjadwalan dari data KUA
+func RulesForKUA(kua *structs.KUA) SchedulingRules {
+	return SchedulingRules{
+		KapasitasKUAHarian:      kua.Kapasitas_nikah_harian,
+		MaksNikahPenghuluHarian: kua.Maks_nikah_penghulu_harian,
+		JedaMinimalMenit:        kua.Jeda_minimal_menit,
+		IntervalSlotMenit:       kua.Interval_slot_menit,
+		JamMulai:                kua.Jam_mulai,
+		JamSelesai:              kua.Jam_selesai,
+		HariTutup:               parseWeekdays(kua.Hari_tutup),
+		KecepatanTempuhKmJam:    kua.Kecepatan_tempuh_km_jam,
+	}
+}
+
+// ApplyTo menyalin aturan penjadwalan ke data KUA
+func (r SchedulingRules) ApplyTo(kua *structs.KUA) {
+	kua.Kapasitas_nikah_harian = r.KapasitasKUAHarian
+	kua.Maks_nikah_penghulu_harian = r.MaksNikahPenghuluHarian
+	kua.Jeda_minimal_menit = r.JedaMinimalMenit
+	kua.Interval_slot_menit = r.IntervalSlotMenit
+	kua.Jam_mulai = r.JamMulai
+	kua.Jam_selesai = r.JamSelesai
+	kua.Hari_tutup = formatWeekdays(r.HariTutup)
+	kua.Kecepatan_tempuh_km_jam = r.KecepatanTempuhKmJam
+}
+
+// Validate memeriksa aturan penjadwalan
+func (r SchedulingRules) Validate() error {
+	if r.KapasitasKUAHarian < 1 {
+		return fmt.Errorf("%w: kapasitas nikah harian minimal 1", ErrInvalidSchedulingRules)
+	}
+	if r.MaksNikahPenghuluHarian < 1 {
+		return fmt.Errorf("%w: maksimal nikah per penghulu minimal 1", ErrInvalidSchedulingRules)
+	}
+	if r.JedaMinimalMenit < 0 || r.JedaMinimalMenit > 24*60 {
+		return fmt.Errorf("%w: jeda minimal harus 0-1440 menit", ErrInvalidSchedulingRules)
+	}
+	if r.IntervalSlotMenit < 5 || r.IntervalSlotMenit > 24*60 {
+		return fmt.Errorf("%w: interval slot harus 5-1440 menit", ErrInvalidSchedulingRules)
+	}
+	if r.KecepatanTempuhKmJam < 1 || r.KecepatanTempuhKmJam > 120 {
+		return fmt.Errorf("%w: kecepatan tempuh harus 1-120 km/jam", ErrInvalidSchedulingRules)
+	}
+
+	mulai, err := time.Parse("15:04", r.JamMulai)
+	if err != nil {
+		return fmt.Errorf("%w: jam mulai harus HH:MM", ErrInvalidSchedulingRules)
+	}
+	selesai, err := time.Parse("15:04", r.JamSelesai)
+	if err != nil {
+		return fmt.Errorf("%w: jam selesai harus HH:MM", ErrInvalidSchedulingRules)
+	}
+	if selesai.Before(mulai) {
+		return fmt.Errorf("%w: jam selesai tidak boleh sebelum jam mulai", ErrInvalidSchedulingRules)
+	}
+
+	if len(r.HariTutup) >= 7 {
+		return fmt.Errorf("%w: minimal satu hari harus melayani nikah", ErrInvalidSchedulingRules)
+	}
+	for _, day := range r.HariTutup {
+		if day < 0 || day > 6 {
+			return fmt.Errorf("%w: hari tutup harus 0 (Minggu) sampai 6 (Sabtu)", ErrInvalidSchedulingRules)
+		}
+	}
+	return nil
+}
+
+// IsClosedDay mengecek apakah KUA tidak melayani nikah pada tanggal tersebut
+func (r SchedulingRules) IsClosedDay(t time.Time) bool {
+	for _, day := range r.HariTutup {
+		if int(t.Weekday()) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// WithinWorkingHours mengecek apakah waktu (HH:MM) berada di jam layanan
+func (r SchedulingRules) WithinWorkingHours(waktu string) bool {
+	t, err := time.Parse("15:04", waktu)
+	if err != nil {
+		return false
+	}
+	mulai, errMulai := time.Parse("15:04", r.JamMulai)
+	selesai, errSelesai := time.Parse("15:04", r.JamSelesai)
+	if errMulai != nil || errSelesai != nil {
+		return false
+	}
+	return !t.Before(mulai) && !t.After(selesai)
+}
+
+// TimeSlots membuat slot waktu dari jam mulai sampai jam selesai dengan jarak interval slot
+// (default 08:00-16:00 setiap 60 menit = 9 slot). Jeda minimal penghulu tidak memengaruhi slot.
+func (r SchedulingRules) TimeSlots() []string {
+	mulai, errMulai := time.Parse("15:04", r.JamMulai)
+	selesai, errSelesai := time.Parse("15:04", r.JamSelesai)
+	if errMulai != nil || errSelesai != nil {
+		return nil
+	}
+
+	step := time.Duration(r.IntervalSlotMenit) * time.Minute
+	if step <= 0 {
+		step = time.Hour
+	}
+
+	slots := make([]string, 0)
+	for t := mulai; !t.After(selesai); t = t.Add(step) {
+		slots = append(slots, t.Format("15:04"))
+	}
+	return slots
+}
+
+// GapConflict menghitung selisih dua waktu (HH:MM) dalam menit dan apakah kurang dari jeda minimal.
+// Waktu yang tidak bisa dibaca dianggap tidak konflik.
+func (r SchedulingRules) GapConflict(a, b string) (int, bool) {
+	selisih, ok := minutesBetween(a, b)
+	if !ok {
+		return 0, false
+	}
+	return selisih, selisih < r.JedaMinimalMenit
+}
+
+// SchedulingRuleService untuk membaca dan mengubah aturan penjadwalan per KUA
+type SchedulingRuleService struct {
+	DB *gorm.DB
+}
+
+// NewSchedulingRuleService membuat instance baru dari SchedulingRuleService
+func NewSchedulingRuleService(db *gorm.DB) *SchedulingRuleService {
+	return &SchedulingRuleService{DB: db}
+}
+
+// ForKUA mengambil aturan penjadwalan sebuah KUA
+func (ss *SchedulingRuleService) ForKUA(kuaID uint) (SchedulingRules, error) {
+	kua, err := ss.findKUA(kuaID)
+	if err != nil {
+		return SchedulingRules{}, err
+	}
+	return RulesForKUA(kua), nil
+}
+
+// findKUA mengambil data KUA (ErrKUANotFound jika tidak ada)
+func (ss *SchedulingRuleService) findKUA(kuaID uint) (*structs.KUA, error) {
+	var kua structs.KUA
+	if err := ss.DB.First(&kua, kuaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrKUANotFound
+		}
+		return nil, err
+	}
+	return &kua, nil
+}
+
+// Update memvalidasi lalu menyimpan aturan penjadwalan sebuah KUA
+func (ss *SchedulingRuleService) Update(kuaID uint, rules SchedulingRules) (*structs.KUA, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+
+	var kua structs.KUA
+	if err := ss.DB.First(&kua, kuaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrKUANotFound
+		}
+		return nil, err
+	}
+
+	rules.ApplyTo(&kua)
+	kua.Updated_at = time.Now()
+	if err := ss.DB.Save(&kua).Error; err != nil {
+		return nil, fmt.Errorf("gagal menyimpan aturan penjadwalan: %v", err)
+	}
+	return &kua, nil
+}
+
+// Jenis ScheduleConflict
+const (
+	ScheduleConflictKuotaKUA      = "kuota_kua"
+	ScheduleConflictKuotaPenghulu = "kuota_penghulu"
+	ScheduleConflictJeda          = "jeda"
+	ScheduleConflictTidakTersedia = "penghulu_tidak_tersedia"
+	ScheduleConflictWaktuTempuh   = "waktu_tempuh" // Jeda antar akad tidak cukup untuk perjalanan penghulu
+)
+
+// ScheduleConflict adalah alasan penugasan penghulu ditolak oleh aturan penjadwalan
+type ScheduleConflict struct {
+	Type    string
+	Message string
+	Details map[string]interface{}
+}
+
+func (e *ScheduleConflict) Error() string {
+	return e.Message
+}
+
+// CheckPenghuluAssignment memeriksa apakah penghulu boleh ditugaskan ke pendaftaran menurut aturan KUA pendaftaran:
+// ketidaksediaan penghulu (cuti/sakit/tugas luar/blokir), kuota harian balai KUA (khusus nikah di KUA),
+// maksimal nikah per penghulu, jeda minimal antar nikah, dan waktu tempuh ke/dari lokasi akad sebelum dan sesudahnya.
+// Pendaftaran itu sendiri tidak ikut dihitung. Mengembalikan jumlah jadwal penghulu pada tanggal tersebut.
+func (ss *SchedulingRuleService) CheckPenghuluAssignment(p *structs.PendaftaranNikah, penghuluID uint) (int, error) {
+	kua, err := ss.findKUA(p.Kua_id)
+	if err != nil {
+		return 0, err
+	}
+	rules := RulesForKUA(kua)
+
+	tanggal := p.Tanggal_nikah.Format("2006-01-02")
+
+	blok, err := NewPenghuluAvailabilityService(ss.DB).Blocking(p.Kua_id, penghuluID, p.Tanggal_nikah, p.Waktu_nikah)
+	if err != nil {
+		return 0, err
+	}
+	if blok != nil {
+		return 0, &ScheduleConflict{
+			Type:    ScheduleConflictTidakTersedia,
+			Message: fmt.Sprintf("Penghulu tidak tersedia (%s) pada jadwal tersebut", blok.Jenis),
+			Details: map[string]interface{}{"tanggal": tanggal, "ketidaksediaan": blok},
+		}
+	}
+
+	if err := ss.checkKUACapacity(p, rules); err != nil {
+		return 0, err
+	}
+
+	jadwal, err := ss.penghuluSchedule(p, penghuluID)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(jadwal) >= rules.MaksNikahPenghuluHarian {
+		return len(jadwal), &ScheduleConflict{
+			Type:    ScheduleConflictKuotaPenghulu,
+			Message: fmt.Sprintf("Penghulu sudah memiliki %d jadwal pada tanggal tersebut", len(jadwal)),
+			Details: map[string]interface{}{"tanggal": tanggal, "maksimal": rules.MaksNikahPenghuluHarian},
+		}
+	}
+
+	for _, j := range jadwal {
+		if selisih, konflik := rules.GapConflict(p.Waktu_nikah, j.Waktu_nikah); konflik {
+			return len(jadwal), &ScheduleConflict{
+				Type:    ScheduleConflictJeda,
+				Message: "Konflik jadwal! Penghulu sudah memiliki jadwal pada waktu yang berdekatan",
+				Details: map[string]interface{}{
+					"waktu_konflik":   j.Waktu_nikah,
+					"tempat":          j.Tempat_nikah,
+					"selisih_menit":   selisih,
+					"minimal_selisih": fmt.Sprintf("%d menit", rules.JedaMinimalMenit),
+				},
+			}
+		}
+	}
+
+	// Rute tidak diminta ke server di sini karena pemanggil bisa sedang memegang kunci pendaftaran dan
+	// penghulu; rute diambil dari cache yang diisi PrefetchTravel, selain itu perkiraan lokal
+	estimator := NewTravelEstimator(rules)
+	estimator.Offline = true
+	if conflict := estimator.TravelConflict(rules, kua, p, jadwal); conflict != nil {
+		return len(jadwal), conflict
+	}
+
+	return len(jadwal), nil
+}
+
+// PrefetchTravel meminta rute perjalanan penghulu ke/dari lokasi akad p ke provider rute dan menyimpannya
+// di cache, sehingga CheckPenghuluAssignment di dalam transaction tidak perlu menghubungi server rute.
+// Dipanggil sebelum kunci diambil; error diabaikan karena pengecekan di dalam transaction tetap berjalan.
+func (ss *SchedulingRuleService) PrefetchTravel(p *structs.PendaftaranNikah, penghuluID uint) {
+	kua, err := ss.findKUA(p.Kua_id)
+	if err != nil {
+		return
+	}
+	jadwal, err := ss.penghuluSchedule(p, penghuluID)
+	if err != nil {
+		return
+	}
+	rules := RulesForKUA(kua)
+	NewTravelEstimator(rules).PlanTravel(rules, kua, append(jadwal, *p))
+}
+
+// penghuluSchedule mengambil jadwal penghulu lain pada tanggal nikah p (p sendiri tidak ikut)
+func (ss *SchedulingRuleService) penghuluSchedule(p *structs.PendaftaranNikah, penghuluID uint) ([]structs.PendaftaranNikah, error) {
+	var jadwal []structs.PendaftaranNikah
+	err := ss.DB.Where("penghulu_id = ? AND DATE(tanggal_nikah) = ? AND status_pendaftaran IN ? AND id <> ?",
+		penghuluID, p.Tanggal_nikah.Format("2006-01-02"), ScheduledStatuses, p.ID).Find(&jadwal).Error
+	return jadwal, err
+}
+
+// LockPenghulu mengunci baris penghulu (FOR UPDATE) di dalam transaction tx sehingga pengecekan jadwal
+// dan penugasan untuk penghulu yang sama berjalan bergantian
+func LockPenghulu(tx *gorm.DB, penghuluID uint) error {
+	var penghulu structs.Penghulu
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&penghulu, penghuluID).Error
+}
+
+// CheckKUACapacity memeriksa kuota harian balai KUA untuk nikah "Di KUA" (pendaftaran itu sendiri tidak dihitung)
+func (ss *SchedulingRuleService) CheckKUACapacity(p *structs.PendaftaranNikah) error {
+	rules, err := ss.ForKUA(p.Kua_id)
+	if err != nil {
+		return err
+	}
+	return ss.checkKUACapacity(p, rules)
+}
+
+func (ss *SchedulingRuleService) checkKUACapacity(p *structs.PendaftaranNikah, rules SchedulingRules) error {
+	if p.Tempat_nikah != "Di KUA" {
+		return nil
+	}
+
+	tanggal := p.Tanggal_nikah.Format("2006-01-02")
+	beban, err := KUADailyLoad(ss.DB, p.Kua_id, p.Tanggal_nikah, p)
+	if err != nil {
+		return err
+	}
+	if beban >= rules.KapasitasKUAHarian {
+		return &ScheduleConflict{
+			Type:    ScheduleConflictKuotaKUA,
+			Message: "Kuota pernikahan harian di KUA penuh",
+			Details: map[string]interface{}{"tanggal": tanggal, "maksimal": rules.KapasitasKUAHarian},
+		}
+	}
+	return nil
+}
+
+// KUADailyLoad menghitung beban balai KUA pada satu tanggal: pendaftaran "Di KUA" yang belum ditolak atau
+// dibatalkan ditambah penahanan slot yang masih berlaku. Definisi yang sama dipakai kuota penugasan,
+// kalender ketersediaan, dan daftar tunggu. Pendaftaran except dan slot yang ditahan pendaftarnya tidak dihitung.
+func KUADailyLoad(db *gorm.DB, kuaID uint, tanggal time.Time, except *structs.PendaftaranNikah) (int, error) {
+	beban, err := KUADailyLoads(db, kuaID, tanggal, tanggal, except)
+	return beban[tanggal.Format("2006-01-02")], err
+}
+
+// KUADailyLoads menghitung KUADailyLoad untuk setiap tanggal pada rentang from sampai to, dengan kunci YYYY-MM-DD
+func KUADailyLoads(db *gorm.DB, kuaID uint, from, to time.Time, except *structs.PendaftaranNikah) (map[string]int, error) {
+	dari, sampai := from.Format("2006-01-02"), to.Format("2006-01-02")
+	pendaftaran := db.Model(&structs.PendaftaranNikah{}).Scopes(TenantScope(kuaID)).
+		Where("DATE(tanggal_nikah) BETWEEN ? AND ? AND tempat_nikah = ? AND status_pendaftaran NOT IN ?",
+			dari, sampai, "Di KUA", []string{structs.StatusPendaftaranDitolak, structs.StatusPendaftaranDibatalkan})
+	ditahan := db.Model(&structs.SlotNikah{}).Scopes(TenantScope(kuaID)).
+		Where("tanggal BETWEEN ? AND ? AND status = ? AND kedaluwarsa_pada >= ?", dari, sampai, structs.SlotNikahStatusDitahan, time.Now())
+	if except != nil {
+		pendaftaran = pendaftaran.Where("id <> ?", except.ID)
+		if except.Pendaftar_id != "" {
+			ditahan = ditahan.Where("ditahan_oleh <> ?", except.Pendaftar_id)
+		}
+	}
+
+	var tanggalNikah []time.Time
+	if err := pendaftaran.Pluck("tanggal_nikah", &tanggalNikah).Error; err != nil {
+		return nil, err
+	}
+	var tanggalTahan []string
+	if err := ditahan.Pluck("tanggal", &tanggalTahan).Error; err != nil {
+		return nil, err
+	}
+
+	beban := make(map[string]int)
+	for _, t := range tanggalNikah {
+		beban[t.Format("2006-01-02")]++
+	}
+	for _, t := range tanggalTahan {
+		beban[t]++
+	}
+	return beban, nil
+}
+
+// minutesBetween menghitung selisih absolut (menit) dua waktu HH:MM
+func minutesBetween(a, b string) (int, bool) {
+	ta, err1 := time.Parse("15:04", a)
+	tb, err2 := time.Parse("15:04", b)
+	if err1 != nil || err2 != nil {
+		return 0, false
+	}
+	selisih := int(ta.Sub(tb).Minutes())
+	if selisih < 0 {
+		selisih = -selisih
+	}
+	return selisih, true
+}
+
+// parseWeekdays membaca daftar hari "0,6" menjadi []int (nilai tidak valid diabaikan)
+func parseWeekdays(value string) []int {
+	days := make([]int, 0)
+	for _, part := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || day < 0 || day > 6 {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// formatWeekdays menyimpan daftar hari sebagai "0,6" (terurut, tanpa duplikat)
+func formatWeekdays(days []int) string {
+	unique := make(map[int]bool, len(days))
+	sorted := make([]int, 0, len(days))
+	for _, day := range days {
+		if !unique[day] {
+			unique[day] = true
+			sorted = append(sorted, day)
+		}
+	}
+	sort.Ints(sorted)
+
+	parts := make([]string, 0, len(sorted))
+	for _, day := range sorted {
+		parts = append(parts, strconv.Itoa(day))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+)
+
+func TestSchedulingRulesValidate(t *testing.T) {
+	valid := DefaultSchedulingRules()
+
+	tests := []struct {
+		name   string
+		modify func(r *SchedulingRules)
+		valid  bool
+	}{
+		{"default", func(r *SchedulingRules) {}, true},
+		{"tutup akhir pekan", func(r *SchedulingRules) { r.HariTutup = []int{0, 6} }, true},
+		{"tanpa jeda", func(r *SchedulingRules) { r.JedaMinimalMenit = 0 }, true},
+		{"kapasitas nol", func(r *SchedulingRules) { r.KapasitasKUAHarian = 0 }, false},
+		{"maks penghulu nol", func(r *SchedulingRules) { r.MaksNikahPenghuluHarian = 0 }, false},
+		{"jeda negatif", func(r *SchedulingRules) { r.JedaMinimalMenit = -1 }, false},
+		{"interval slot nol", func(r *SchedulingRules) { r.IntervalSlotMenit = 0 }, false},
+		{"interval slot terlalu rapat", func(r *SchedulingRules) { r.IntervalSlotMenit = 4 }, false},
+		{"interval slot 30 menit", func(r *SchedulingRules) { r.IntervalSlotMenit = 30 }, true},
+		{"kecepatan nol", func(r *SchedulingRules) { r.KecepatanTempuhKmJam = 0 }, false},
+		{"kecepatan berlebihan", func(r *SchedulingRules) { r.KecepatanTempuhKmJam = 200 }, false},
+		{"jam mulai salah", func(r *SchedulingRules) { r.JamMulai = "8 pagi" }, false},
+		{"jam selesai sebelum mulai", func(r *SchedulingRules) { r.JamSelesai = "07:00" }, false},
+		{"hari tidak dikenal", func(r *SchedulingRules) { r.HariTutup = []int{7} }, false},
+		{"tutup setiap hari", func(r *SchedulingRules) { r.HariTutup = []int{0, 1, 2, 3, 4, 5, 6} }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			tt.modify(&r)
+			err := r.Validate()
+			if tt.valid && err != nil {
+				t.Fatalf("Validate() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidSchedulingRules) {
+				t.Fatalf("Validate() = %v, want ErrInvalidSchedulingRules", err)
+			}
+		})
+	}
+}
+
+func TestSchedulingRulesTimeSlots(t *testing.T) {
+	r := DefaultSchedulingRules()
+	if got := r.TimeSlots(); len(got) != 9 || got[0] != "08:00" || got[8] != "16:00" {
+		t.Fatalf("TimeSlots() default = %v, want 9 slot 08:00-16:00", got)
+	}
+
+	r.IntervalSlotMenit = 90
+	r.JamSelesai = "12:00"
+	want := []string{"08:00", "09:30", "11:00"}
+	if got := r.TimeSlots(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("TimeSlots() interval 90 = %v, want %v", got, want)
+	}
+
+	// Jeda penghulu tidak mengubah slot balai KUA
+	r.JedaMinimalMenit = 0
+	if got := r.TimeSlots(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("TimeSlots() jeda 0 = %v, want %v", got, want)
+	}
+	r.IntervalSlotMenit = 30
+	r.JedaMinimalMenit = 120
+	if got := r.TimeSlots(); len(got) != 9 || got[1] != "08:30" {
+		t.Fatalf("TimeSlots() interval 30 = %v, want 9 slot setiap 30 menit", got)
+	}
+}
+
+func TestSchedulingRulesGapConflict(t *testing.T) {
+	r := DefaultSchedulingRules()
+	r.JedaMinimalMenit = 120
+
+	tests := []struct {
+		a, b        string
+		wantMenit   int
+		wantKonflik bool
+	}{
+		{"09:00", "10:00", 60, true},
+		{"11:00", "09:00", 120, false},
+		{"09:00", "09:00", 0, true},
+		{"09:00", "-", 0, false},
+	}
+
+	for _, tt := range tests {
+		menit, konflik := r.GapConflict(tt.a, tt.b)
+		if menit != tt.wantMenit || konflik != tt.wantKonflik {
+			t.Errorf("GapConflict(%q, %q) = (%d, %v), want (%d, %v)", tt.a, tt.b, menit, konflik, tt.wantMenit, tt.wantKonflik)
+		}
+	}
+}
+
+func TestSchedulingRulesDayAndHours(t *testing.T) {
+	r := DefaultSchedulingRules()
+	r.HariTutup = []int{0}
+
+	minggu := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	senin := minggu.AddDate(0, 0, 1)
+	if !r.IsClosedDay(minggu) || r.IsClosedDay(senin) {
+		t.Fatalf("IsClosedDay: minggu harus tutup, senin buka")
+	}
+
+	for waktu, want := range map[string]bool{"08:00": true, "16:00": true, "07:59": false, "16:30": false, "jam 9": false} {
+		if got := r.WithinWorkingHours(waktu); got != want {
+			t.Errorf("WithinWorkingHours(%q) = %v, want %v", waktu, got, want)
+		}
+	}
+}
+
+func TestSchedulingRulesKUARoundTrip(t *testing.T) {
+	rules := DefaultSchedulingRules()
+	rules.HariTutup = []int{6, 0, 6}
+
+	var kua structs.KUA
+	rules.ApplyTo(&kua)
+	if kua.Hari_tutup != "0,6" {
+		t.Fatalf("Hari_tutup = %q, want %q", kua.Hari_tutup, "0,6")
+	}
+
+	got := RulesForKUA(&kua)
+	if !reflect.DeepEqual(got.HariTutup, []int{0, 6}) || got.KapasitasKUAHarian != 9 || got.JamMulai != "08:00" {
+		t.Fatalf("RulesForKUA() = %+v", got)
+	}
+
+	if days := parseWeekdays(" 1, x,9,3"); !reflect.DeepEqual(days, []int{1, 3}) {
+		t.Fatalf("parseWeekdays() = %v, want [1 3]", days)
+	}
+}
+
+func TestKUADailyLoad(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	kua.Kapasitas_nikah_harian = 3
+	db.Save(&kua)
+
+	hari := time.Now().UTC().AddDate(0, 1, 0)
+	tanggal := time.Date(hari.Year(), hari.Month(), hari.Day(), 0, 0, 0, 0, time.UTC)
+	tanggalStr := tanggal.Format("2006-01-02")
+	besok := tanggal.AddDate(0, 0, 1)
+
+	// Pendaftaran yang belum ditugaskan penghulu ikut dihitung; yang ditolak, dibatalkan, atau di luar KUA tidak
+	p := createTestPendaftaran(t, db, structs.PendaftaranNikah{Kua_id: kua.ID, Tanggal_nikah: tanggal, Waktu_nikah: "08:00",
+		Status_pendaftaran: structs.StatusPendaftaranMenungguPenugasan})
+	createTestPendaftaran(t, db, structs.PendaftaranNikah{Kua_id: kua.ID, Tanggal_nikah: tanggal, Waktu_nikah: "09:00",
+		Status_pendaftaran: structs.StatusPendaftaranMenungguVerifikasi})
+	createTestPendaftaran(t, db, structs.PendaftaranNikah{Kua_id: kua.ID, Tanggal_nikah: tanggal, Waktu_nikah: "10:00",
+		Status_pendaftaran: structs.StatusPendaftaranDibatalkan})
+	createTestPendaftaran(t, db, structs.PendaftaranNikah{Kua_id: kua.ID, Tanggal_nikah: tanggal, Waktu_nikah: "10:00",
+		Tempat_nikah: "Di Luar KUA", Status_pendaftaran: structs.StatusPendaftaranMenungguBimbingan})
+	createTestPendaftaran(t, db, structs.PendaftaranNikah{Kua_id: kua.ID, Tanggal_nikah: besok, Waktu_nikah: "08:00"})
+
+	// Penahanan slot yang masih berlaku ikut dihitung, yang sudah habis tidak
+	berlaku, lewat := time.Now().Add(time.Hour), time.Now().Add(-time.Minute)
+	db.Create(&structs.SlotNikah{Kua_id: kua.ID, Tanggal: tanggalStr, Waktu: "11:00", Status: structs.SlotNikahStatusDitahan, Ditahan_oleh: "CATIN7", Kedaluwarsa_pada: &berlaku})
+	db.Create(&structs.SlotNikah{Kua_id: kua.ID, Tanggal: tanggalStr, Waktu: "13:00", Status: structs.SlotNikahStatusDitahan, Ditahan_oleh: "CATIN8", Kedaluwarsa_pada: &lewat})
+
+	if beban, err := KUADailyLoad(db, kua.ID, tanggal, nil); err != nil || beban != 3 {
+		t.Errorf("KUADailyLoad() = %d, %v, want 3", beban, err)
+	}
+	if beban, err := KUADailyLoad(db, kua.ID, tanggal, &p); err != nil || beban != 2 {
+		t.Errorf("KUADailyLoad(kecuali p) = %d, %v, want 2", beban, err)
+	}
+	beban, err := KUADailyLoads(db, kua.ID, tanggal, besok, nil)
+	if err != nil || beban[tanggalStr] != 3 || beban[besok.Format("2006-01-02")] != 1 {
+		t.Errorf("KUADailyLoads() = %v, %v, want %s: 3, besok: 1", beban, err, tanggalStr)
+	}
+
+	// Kuota penugasan memakai beban yang sama: 2 lainnya + 1 < 3 masih boleh, satu penahanan lagi penuh
+	ss := NewSchedulingRuleService(db)
+	if err := ss.CheckKUACapacity(&p); err != nil {
+		t.Fatalf("CheckKUACapacity() = %v, want nil", err)
+	}
+	db.Create(&structs.SlotNikah{Kua_id: kua.ID, Tanggal: tanggalStr, Waktu: "14:00", Status: structs.SlotNikahStatusDitahan, Ditahan_oleh: "CATIN9", Kedaluwarsa_pada: &berlaku})
+	var conflict *ScheduleConflict
+	if err := ss.CheckKUACapacity(&p); !errors.As(err, &conflict) || conflict.Type != ScheduleConflictKuotaKUA {
+		t.Errorf("CheckKUACapacity(penuh) = %v, want konflik kuota_kua", err)
+	}
+	if space, err := NewWaitlistService(db).dateSpace(&kua, tanggal); err != nil || space != 0 {
+		t.Errorf("dateSpace(penuh) = %d, %v, want 0", space, err)
+	}
+}
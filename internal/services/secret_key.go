@@ -0,0 +1,79 @@
+package services
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"simnikah/config"
+)
+
+// Variabel environment kunci rahasia. Kunci per fitur fallback ke JWT_KEY jika kosong.
+const (
+	EnvInviteSigningKey       = "INVITE_SIGNING_KEY"
+	EnvCalendarFeedSigningKey = "CALENDAR_FEED_SIGNING_KEY"
+	EnvUndanganSigningKey     = "UNDANGAN_SIGNING_KEY"
+	EnvMfaSecretKey           = "MFA_SECRET_KEY"
+	EnvJWTKey                 = "JWT_KEY"
+)
+
+var secretKeyEnvs = []string{EnvInviteSigningKey, EnvCalendarFeedSigningKey, EnvUndanganSigningKey, EnvMfaSecretKey}
+
+var (
+	devKeysMu sync.Mutex
+	devKeys   = map[string][]byte{}
+)
+
+// lookupSecretKey mengambil kunci dari env, fallback ke JWT_KEY
+func lookupSecretKey(env string) string {
+	if key := os.Getenv(env); key != "" {
+		return key
+	}
+	return os.Getenv(EnvJWTKey)
+}
+
+// CheckSecretKeys memastikan semua kunci rahasia tersedia. Dipanggil saat startup: di luar mode
+// pengembangan server tidak boleh berjalan dengan kunci kosong.
+func CheckSecretKeys() error {
+	if config.IsDevEnvironment() {
+		return nil
+	}
+	var missing []string
+	if os.Getenv(EnvJWTKey) == "" {
+		missing = append(missing, EnvJWTKey)
+	}
+	for _, env := range secretKeyEnvs {
+		if lookupSecretKey(env) == "" {
+			missing = append(missing, env)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("kunci rahasia belum diatur: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// SecretKey mengembalikan kunci rahasia untuk env (fallback ke JWT_KEY). Tanpa kunci, mode pengembangan
+// memakai kunci acak per proses sehingga token yang sudah dibuat tidak berlaku setelah server dijalankan
+// ulang; di luar mode pengembangan CheckSecretKeys sudah menghentikan server saat startup.
+func SecretKey(env string) []byte {
+	if key := lookupSecretKey(env); key != "" {
+		return []byte(key)
+	}
+
+	devKeysMu.Lock()
+	defer devKeysMu.Unlock()
+	if key, ok := devKeys[env]; ok {
+		return key
+	}
+	log.Printf("Warning: %s/%s not set, using a random key for this process", env, EnvJWTKey)
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("gagal membuat kunci acak %s: %v", env, err))
+	}
+	devKeys[env] = key
+	return key
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCheckSecretKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"pengembangan tanpa kunci", map[string]string{}, ""},
+		{"produksi tanpa kunci", map[string]string{"GIN_MODE": "release"}, "JWT_KEY"},
+		{"environment produksi tanpa kunci", map[string]string{"ENVIRONMENT": "production"}, "MFA_SECRET_KEY"},
+		{"produksi dengan JWT_KEY", map[string]string{"GIN_MODE": "release", "JWT_KEY": "kunci-jwt"}, ""},
+		{"produksi hanya kunci fitur", map[string]string{"GIN_MODE": "release", EnvInviteSigningKey: "kunci-undangan"}, "JWT_KEY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range append([]string{"GIN_MODE", "ENVIRONMENT", EnvJWTKey}, secretKeyEnvs...) {
+				t.Setenv(env, tt.env[env])
+			}
+			err := CheckSecretKeys()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckSecretKeys() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckSecretKeys() error = %v, want menyebut %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSecretKey(t *testing.T) {
+	t.Setenv(EnvJWTKey, "kunci-jwt")
+	t.Setenv(EnvInviteSigningKey, "kunci-undangan")
+	t.Setenv(EnvUndanganSigningKey, "")
+
+	if got := string(SecretKey(EnvInviteSigningKey)); got != "kunci-undangan" {
+		t.Errorf("SecretKey(%s) = %q, want kunci fitur", EnvInviteSigningKey, got)
+	}
+	if got := string(SecretKey(EnvUndanganSigningKey)); got != "kunci-jwt" {
+		t.Errorf("SecretKey(%s) = %q, want fallback JWT_KEY", EnvUndanganSigningKey, got)
+	}
+
+	// Tanpa kunci sama sekali, kunci acak yang sama dipakai selama proses berjalan
+	t.Setenv(EnvJWTKey, "")
+	t.Setenv(EnvCalendarFeedSigningKey, "")
+	first := SecretKey(EnvCalendarFeedSigningKey)
+	if len(first) != 32 || !bytes.Equal(first, SecretKey(EnvCalendarFeedSigningKey)) {
+		t.Errorf("SecretKey(tanpa kunci) = %x, want kunci acak 32 byte yang stabil", first)
+	}
+}
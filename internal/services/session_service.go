@@ -0,0 +1,294 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// AccessTokenTTL adalah masa berlaku access token (JWT)
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL adalah masa berlaku sesi/refresh token sejak login
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrRefreshTokenInvalid dikembalikan jika refresh token tidak dikenal, dicabut, atau kedaluwarsa
+	ErrRefreshTokenInvalid = errors.New("refresh token tidak valid atau sudah tidak berlaku")
+	// ErrRefreshTokenReused dikembalikan jika refresh token lama dipakai ulang; sesi langsung dicabut
+	ErrRefreshTokenReused = errors.New("refresh token sudah pernah digunakan, sesi dicabut demi keamanan")
+)
+
+// SessionService mengelola sesi login, rotasi refresh token, dan denylist jti access token
+type SessionService struct {
+	DB *gorm.DB
+}
+
+// NewSessionService membuat instance baru dari SessionService
+func NewSessionService(db *gorm.DB) *SessionService {
+	return &SessionService{DB: db}
+}
+
+// StartSession membuat sesi baru untuk user yang berhasil login.
+// Mengembalikan sesi (berisi jti & waktu kedaluwarsa access token) dan refresh token mentah.
+func (ss *SessionService) StartSession(userID, userAgent, ipAddress string) (*structs.SesiPengguna, string, error) {
+	sessionID, err := randomTokenID()
+	if err != nil {
+		return nil, "", fmt.Errorf("gagal membuat sesi: %v", err)
+	}
+	refreshToken, err := randomTokenID()
+	if err != nil {
+		return nil, "", fmt.Errorf("gagal membuat refresh token: %v", err)
+	}
+	accessJTI, err := randomTokenID()
+	if err != nil {
+		return nil, "", fmt.Errorf("gagal membuat jti access token: %v", err)
+	}
+
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	now := time.Now()
+	sesi := structs.SesiPengguna{
+		Session_id:              sessionID,
+		User_id:                 userID,
+		Refresh_token_hash:      hashToken(refreshToken),
+		Access_jti:              accessJTI,
+		Access_kedaluwarsa_pada: now.Add(AccessTokenTTL),
+		Kedaluwarsa_pada:        now.Add(RefreshTokenTTL),
+		User_agent:              userAgent,
+		Ip_address:              ipAddress,
+		Terakhir_digunakan_pada: now,
+		Created_at:              now,
+		Updated_at:              now,
+	}
+
+	if err := ss.DB.Create(&sesi).Error; err != nil {
+		return nil, "", fmt.Errorf("gagal menyimpan sesi: %v", err)
+	}
+
+	return &sesi, refreshToken, nil
+}
+
+// RotateSession menukar refresh token dengan refresh token baru dan jti access token baru.
+// Access token lama dari sesi yang sama dimasukkan ke denylist.
+// Jika refresh token lama (sudah dirotasi) dipakai lagi, seluruh sesi dicabut.
+func (ss *SessionService) RotateSession(refreshToken string) (*structs.SesiPengguna, string, error) {
+	tokenHash := hashToken(refreshToken)
+	var rotated *structs.SesiPengguna
+	var newRefreshToken string
+	reused := false
+
+	err := ss.DB.Transaction(func(tx *gorm.DB) error {
+		var sesi structs.SesiPengguna
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("refresh_token_hash = ?", tokenHash).
+			First(&sesi).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Refresh token lama dipakai ulang: kemungkinan token dicuri, cabut sesinya
+			if err := tx.Where("refresh_token_lama_hash = ? AND dicabut_pada IS NULL", tokenHash).First(&sesi).Error; err == nil {
+				reused = true
+				return revokeSession(tx, &sesi, structs.SesiAlasanPakaiUlang)
+			}
+			return ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if sesi.Dicabut_pada != nil || now.After(sesi.Kedaluwarsa_pada) {
+			return ErrRefreshTokenInvalid
+		}
+
+		// Access token lama tidak boleh dipakai lagi setelah rotasi
+		if err := denyAccessToken(tx, sesi.Access_jti, sesi.User_id, sesi.Access_kedaluwarsa_pada, structs.SesiAlasanRotasi); err != nil {
+			return err
+		}
+
+		newRefreshToken, err = randomTokenID()
+		if err != nil {
+			return fmt.Errorf("gagal membuat refresh token: %v", err)
+		}
+		accessJTI, err := randomTokenID()
+		if err != nil {
+			return fmt.Errorf("gagal membuat jti access token: %v", err)
+		}
+
+		sesi.Refresh_token_lama_hash = sesi.Refresh_token_hash
+		sesi.Refresh_token_hash = hashToken(newRefreshToken)
+		sesi.Access_jti = accessJTI
+		sesi.Access_kedaluwarsa_pada = now.Add(AccessTokenTTL)
+		sesi.Terakhir_digunakan_pada = now
+		sesi.Updated_at = now
+
+		if err := tx.Save(&sesi).Error; err != nil {
+			return fmt.Errorf("gagal merotasi sesi: %v", err)
+		}
+
+		rotated = &sesi
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if reused {
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	return rotated, newRefreshToken, nil
+}
+
+// GetActiveSession mengambil sesi yang belum dicabut dan belum kedaluwarsa
+func (ss *SessionService) GetActiveSession(sessionID string) (*structs.SesiPengguna, error) {
+	var sesi structs.SesiPengguna
+	if err := ss.DB.Where("session_id = ? AND dicabut_pada IS NULL AND kedaluwarsa_pada > ?", sessionID, time.Now()).First(&sesi).Error; err != nil {
+		return nil, err
+	}
+	return &sesi, nil
+}
+
+// RevokeSession mencabut satu sesi (logout dari perangkat ini)
+func (ss *SessionService) RevokeSession(sessionID, alasan string) error {
+	return ss.DB.Transaction(func(tx *gorm.DB) error {
+		var sesi structs.SesiPengguna
+		if err := tx.Where("session_id = ? AND dicabut_pada IS NULL", sessionID).First(&sesi).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil // Sudah dicabut sebelumnya
+			}
+			return err
+		}
+		return revokeSession(tx, &sesi, alasan)
+	})
+}
+
+// RevokeAllSessions mencabut semua sesi aktif milik user (logout dari semua perangkat)
+func (ss *SessionService) RevokeAllSessions(userID, alasan string) (int, error) {
+	var count int
+	err := ss.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		count, err = revokeAllSessions(tx, userID, alasan)
+		return err
+	})
+	return count, err
+}
+
+// RevokeOtherSessions mencabut semua sesi user kecuali sesi yang sedang dipakai
+func (ss *SessionService) RevokeOtherSessions(userID, keepSessionID, alasan string) error {
+	return ss.DB.Transaction(func(tx *gorm.DB) error {
+		var sessions []structs.SesiPengguna
+		if err := tx.Where("user_id = ? AND session_id <> ? AND dicabut_pada IS NULL", userID, keepSessionID).Find(&sessions).Error; err != nil {
+			return err
+		}
+		for i := range sessions {
+			if err := revokeSession(tx, &sessions[i], alasan); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetUserStatus mengubah status user. Jika status berubah, semua sesi user dicabut
+// sehingga akun yang dinonaktifkan/diblokir langsung kehilangan akses.
+// Gunakan NewSessionService(tx) untuk menjalankannya di dalam transaction pemanggil.
+func (ss *SessionService) SetUserStatus(userID, status string) error {
+	return ss.DB.Transaction(func(tx *gorm.DB) error {
+		var user structs.Users
+		if err := tx.Where("user_id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+		if user.Status == status {
+			return nil
+		}
+
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"status":     status,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("gagal mengubah status user: %v", err)
+		}
+
+		_, err := revokeAllSessions(tx, userID, structs.SesiAlasanStatusBerubah)
+		return err
+	})
+}
+
+// IsAccessTokenRevoked mengecek apakah jti access token ada di denylist
+func (ss *SessionService) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int64
+	if err := ss.DB.Model(&structs.TokenDicabut{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// DenyAccessToken memasukkan jti access token ke denylist sampai token kedaluwarsa
+func (ss *SessionService) DenyAccessToken(jti, userID string, expiresAt time.Time, alasan string) error {
+	return denyAccessToken(ss.DB, jti, userID, expiresAt, alasan)
+}
+
+// PurgeExpired menghapus entri denylist dan sesi yang sudah kedaluwarsa
+func (ss *SessionService) PurgeExpired() error {
+	now := time.Now()
+	if err := ss.DB.Where("kedaluwarsa_pada < ?", now).Delete(&structs.TokenDicabut{}).Error; err != nil {
+		return err
+	}
+	return ss.DB.Where("kedaluwarsa_pada < ?", now).Delete(&structs.SesiPengguna{}).Error
+}
+
+func revokeAllSessions(tx *gorm.DB, userID, alasan string) (int, error) {
+	var sessions []structs.SesiPengguna
+	if err := tx.Where("user_id = ? AND dicabut_pada IS NULL", userID).Find(&sessions).Error; err != nil {
+		return 0, err
+	}
+	for i := range sessions {
+		if err := revokeSession(tx, &sessions[i], alasan); err != nil {
+			return 0, err
+		}
+	}
+	return len(sessions), nil
+}
+
+func revokeSession(tx *gorm.DB, sesi *structs.SesiPengguna, alasan string) error {
+	now := time.Now()
+	sesi.Dicabut_pada = &now
+	sesi.Alasan_dicabut = alasan
+	sesi.Updated_at = now
+	if err := tx.Save(sesi).Error; err != nil {
+		return fmt.Errorf("gagal mencabut sesi: %v", err)
+	}
+	return denyAccessToken(tx, sesi.Access_jti, sesi.User_id, sesi.Access_kedaluwarsa_pada, alasan)
+}
+
+func denyAccessToken(tx *gorm.DB, jti, userID string, expiresAt time.Time, alasan string) error {
+	if jti == "" || time.Now().After(expiresAt) {
+		return nil // Token sudah kedaluwarsa, tidak perlu masuk denylist
+	}
+	entry := structs.TokenDicabut{
+		Jti:              jti,
+		User_id:          userID,
+		Kedaluwarsa_pada: expiresAt,
+		Alasan:           alasan,
+		Created_at:       time.Now(),
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error; err != nil {
+		return fmt.Errorf("gagal mencabut access token: %v", err)
+	}
+	return nil
+}
+
+// hashToken menghasilkan hash SHA-256 (hex) dari token acak; token mentah tidak pernah disimpan
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
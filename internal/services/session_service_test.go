@@ -0,0 +1,162 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+)
+
+func TestSessionRotate(t *testing.T) {
+	db := newTestDB(t)
+	ss := NewSessionService(db)
+
+	sesi, refresh, err := ss.StartSession("STF1", "laptop", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("StartSession() error = %v", err)
+	}
+	var stored structs.SesiPengguna
+	db.Where("session_id = ?", sesi.Session_id).First(&stored)
+	if stored.Refresh_token_hash == refresh || stored.Refresh_token_hash != hashToken(refresh) {
+		t.Error("refresh token mentah tersimpan, want hanya hash")
+	}
+
+	rotated, refresh2, err := ss.RotateSession(refresh)
+	if err != nil {
+		t.Fatalf("RotateSession() error = %v", err)
+	}
+	if rotated.Session_id != sesi.Session_id || refresh2 == refresh || rotated.Access_jti == sesi.Access_jti {
+		t.Errorf("RotateSession() = sesi %s jti %s, want sesi sama dengan token dan jti baru", rotated.Session_id, rotated.Access_jti)
+	}
+	// Access token sebelum rotasi masuk denylist, yang baru tidak
+	if revoked, _ := ss.IsAccessTokenRevoked(sesi.Access_jti); !revoked {
+		t.Error("jti lama tidak masuk denylist setelah rotasi")
+	}
+	if revoked, _ := ss.IsAccessTokenRevoked(rotated.Access_jti); revoked {
+		t.Error("jti baru masuk denylist")
+	}
+
+	if _, _, err := ss.RotateSession(refresh2); err != nil {
+		t.Fatalf("RotateSession(token baru) error = %v", err)
+	}
+	if _, _, err := ss.RotateSession("token-asal"); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("RotateSession(tidak dikenal) error = %v, want ErrRefreshTokenInvalid", err)
+	}
+
+	// Kedaluwarsa
+	lama, refreshLama, _ := ss.StartSession("STF1", "ponsel", "127.0.0.2")
+	db.Model(&structs.SesiPengguna{}).Where("id = ?", lama.ID).Update("kedaluwarsa_pada", time.Now().Add(-time.Minute))
+	if _, _, err := ss.RotateSession(refreshLama); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("RotateSession(kedaluwarsa) error = %v, want ErrRefreshTokenInvalid", err)
+	}
+}
+
+func TestSessionRefreshTokenReuse(t *testing.T) {
+	db := newTestDB(t)
+	ss := NewSessionService(db)
+
+	sesi, refresh, _ := ss.StartSession("CATIN1", "ponsel", "127.0.0.1")
+	rotated, refresh2, err := ss.RotateSession(refresh)
+	if err != nil {
+		t.Fatalf("RotateSession() error = %v", err)
+	}
+
+	// Token lama dipakai lagi (mis. dicuri): sesi dicabut, token terbaru ikut tidak berlaku
+	if _, _, err := ss.RotateSession(refresh); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("RotateSession(dipakai ulang) error = %v, want ErrRefreshTokenReused", err)
+	}
+	var stored structs.SesiPengguna
+	db.Where("session_id = ?", sesi.Session_id).First(&stored)
+	if stored.Dicabut_pada == nil || stored.Alasan_dicabut != structs.SesiAlasanPakaiUlang {
+		t.Errorf("sesi dicabut = %v alasan %q, want dicabut karena %s", stored.Dicabut_pada, stored.Alasan_dicabut, structs.SesiAlasanPakaiUlang)
+	}
+	if revoked, _ := ss.IsAccessTokenRevoked(rotated.Access_jti); !revoked {
+		t.Error("jti terbaru tidak masuk denylist setelah pemakaian ulang")
+	}
+	if _, _, err := ss.RotateSession(refresh2); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("RotateSession(token terbaru setelah dicabut) error = %v, want ErrRefreshTokenInvalid", err)
+	}
+	// Pemakaian ulang berikutnya tidak lagi menemukan sesi aktif
+	if _, _, err := ss.RotateSession(refresh); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("RotateSession(dipakai ulang kedua) error = %v, want ErrRefreshTokenInvalid", err)
+	}
+}
+
+func TestSessionDenylist(t *testing.T) {
+	db := newTestDB(t)
+	ss := NewSessionService(db)
+
+	sesi, _, _ := ss.StartSession("STF1", "laptop", "127.0.0.1")
+	if err := ss.RevokeSession(sesi.Session_id, structs.SesiAlasanLogout); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+	if _, err := ss.GetActiveSession(sesi.Session_id); err == nil {
+		t.Error("GetActiveSession() menemukan sesi yang sudah logout")
+	}
+	if revoked, _ := ss.IsAccessTokenRevoked(sesi.Access_jti); !revoked {
+		t.Error("jti sesi yang logout tidak masuk denylist")
+	}
+	// Logout kedua kali tidak error dan tidak menggandakan denylist
+	if err := ss.RevokeSession(sesi.Session_id, structs.SesiAlasanLogout); err != nil {
+		t.Errorf("RevokeSession(ulang) error = %v", err)
+	}
+	if err := ss.DenyAccessToken(sesi.Access_jti, "STF1", time.Now().Add(time.Minute), structs.SesiAlasanLogout); err != nil {
+		t.Errorf("DenyAccessToken(duplikat) error = %v", err)
+	}
+
+	// Token yang sudah kedaluwarsa tidak perlu dicatat; entri kedaluwarsa dibersihkan PurgeExpired
+	ss.DenyAccessToken("jti-lewat", "STF1", time.Now().Add(-time.Minute), structs.SesiAlasanLogout)
+	if revoked, _ := ss.IsAccessTokenRevoked("jti-lewat"); revoked {
+		t.Error("jti kedaluwarsa dicatat di denylist")
+	}
+	db.Model(&structs.TokenDicabut{}).Where("jti = ?", sesi.Access_jti).Update("kedaluwarsa_pada", time.Now().Add(-time.Minute))
+	if err := ss.PurgeExpired(); err != nil {
+		t.Fatalf("PurgeExpired() error = %v", err)
+	}
+	var count int64
+	db.Model(&structs.TokenDicabut{}).Count(&count)
+	if count != 0 {
+		t.Errorf("denylist setelah PurgeExpired = %d entri, want 0", count)
+	}
+}
+
+func TestSetUserStatusRevokesSessions(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "STF1", structs.UserRoleStaff, 1)
+	ss := NewSessionService(db)
+
+	laptop, _, _ := ss.StartSession(user.User_id, "laptop", "127.0.0.1")
+	ponsel, _, _ := ss.StartSession(user.User_id, "ponsel", "127.0.0.2")
+	lain, _, _ := ss.StartSession("STF2", "laptop", "127.0.0.3")
+
+	// Status tidak berubah: sesi dibiarkan
+	if err := ss.SetUserStatus(user.User_id, structs.UserStatusAktif); err != nil {
+		t.Fatalf("SetUserStatus(sama) error = %v", err)
+	}
+	if _, err := ss.GetActiveSession(laptop.Session_id); err != nil {
+		t.Errorf("sesi dicabut walaupun status tidak berubah: %v", err)
+	}
+
+	if err := ss.SetUserStatus(user.User_id, structs.UserStatusBlokir); err != nil {
+		t.Fatalf("SetUserStatus() error = %v", err)
+	}
+	var saved structs.Users
+	db.Where("user_id = ?", user.User_id).First(&saved)
+	if saved.Status != structs.UserStatusBlokir {
+		t.Errorf("status = %q, want %q", saved.Status, structs.UserStatusBlokir)
+	}
+	for _, sesi := range []*structs.SesiPengguna{laptop, ponsel} {
+		var stored structs.SesiPengguna
+		db.Where("session_id = ?", sesi.Session_id).First(&stored)
+		if stored.Dicabut_pada == nil || stored.Alasan_dicabut != structs.SesiAlasanStatusBerubah {
+			t.Errorf("sesi %s dicabut = %v alasan %q", sesi.User_agent, stored.Dicabut_pada, stored.Alasan_dicabut)
+		}
+		if revoked, _ := ss.IsAccessTokenRevoked(sesi.Access_jti); !revoked {
+			t.Errorf("jti sesi %s tidak masuk denylist", sesi.User_agent)
+		}
+	}
+	if _, err := ss.GetActiveSession(lain.Session_id); err != nil {
+		t.Errorf("sesi user lain ikut dicabut: %v", err)
+	}
+}
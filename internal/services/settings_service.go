@@ -0,0 +1,42 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetSetting mengambil nilai pengaturan sistem sebuah KUA. Jika KUA belum mengatur kunci tersebut,
+// dipakai nilai default semua KUA (kua_id 0), lalu defaultValue jika keduanya belum diset.
+func GetSetting(db *gorm.DB, kuaID uint, kunci, defaultValue string) (string, error) {
+	var pengaturan structs.PengaturanSistem
+	if err := db.Where("kunci = ? AND kua_id IN ?", kunci, []uint{0, kuaID}).
+		Order("kua_id DESC").First(&pengaturan).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return defaultValue, nil
+		}
+		return defaultValue, err
+	}
+	return pengaturan.Nilai, nil
+}
+
+// SetSetting menyimpan (insert atau update) nilai pengaturan sistem untuk sebuah KUA
+func SetSetting(db *gorm.DB, kuaID uint, kunci, nilai, diubahOleh string) error {
+	now := time.Now()
+	pengaturan := structs.PengaturanSistem{
+		Kua_id:      kuaID,
+		Kunci:       kunci,
+		Nilai:       nilai,
+		Diubah_oleh: diubahOleh,
+		Created_at:  now,
+		Updated_at:  now,
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kua_id"}, {Name: "kunci"}},
+		DoUpdates: clause.AssignmentColumns([]string{"nilai", "diubah_oleh", "updated_at"}),
+	}).Create(&pengaturan).Error
+}
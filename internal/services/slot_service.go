@@ -0,0 +1,234 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SlotHoldDuration adalah lama slot ditahan untuk catin yang sedang mengisi formulir pendaftaran
+const SlotHoldDuration = 15 * time.Minute
+
+var (
+	// ErrSlotUnavailable dikembalikan jika slot sudah dipesan atau sedang ditahan catin lain
+	ErrSlotUnavailable = errors.New("slot waktu sudah dipesan atau sedang ditahan catin lain")
+	// ErrSlotInvalid dikembalikan jika slot tidak termasuk jadwal layanan KUA
+	ErrSlotInvalid = errors.New("slot waktu tidak tersedia")
+	// ErrSlotHoldNotFound dikembalikan jika penahanan slot tidak ada atau bukan milik user
+	ErrSlotHoldNotFound = errors.New("penahanan slot tidak ditemukan")
+)
+
+// Status slot yang hanya dihitung (tidak disimpan)
+const (
+	SlotStatusTersedia = "Tersedia"
+	SlotStatusTutup    = "Tutup"
+)
+
+// SlotInfo adalah status satu slot waktu nikah di KUA pada tanggal tertentu
+type SlotInfo struct {
+	Waktu         string     `json:"waktu"`
+	Status        string     `json:"status"` // Tersedia, Ditahan, Terpesan, Tutup
+	Tersedia      bool       `json:"tersedia"`
+	MilikSaya     bool       `json:"milik_saya"`
+	HoldID        uint       `json:"hold_id,omitempty"`
+	DitahanSampai *time.Time `json:"ditahan_sampai,omitempty"`
+}
+
+// SlotService untuk penahanan dan pemesanan slot waktu nikah di KUA
+type SlotService struct {
+	DB *gorm.DB
+}
+
+// NewSlotService membuat instance baru dari SlotService
+func NewSlotService(db *gorm.DB) *SlotService {
+	return &SlotService{DB: db}
+}
+
+// ValidateSlot memastikan tanggal dan waktu termasuk slot layanan KUA:
+// bukan tanggal lampau menurut zona waktu KUA, bukan hari tutup, dan waktu sama dengan salah satu slot
+// dari aturan penjadwalan
+func ValidateSlot(rules SchedulingRules, zona *time.Location, tanggal time.Time, waktu string) error {
+	if tanggal.Before(TanggalLokal(time.Now(), zona)) {
+		return fmt.Errorf("%w: tanggal sudah lewat", ErrSlotInvalid)
+	}
+	if rules.IsClosedDay(tanggal) {
+		return fmt.Errorf("%w: KUA tidak melayani nikah pada hari tersebut", ErrSlotInvalid)
+	}
+	for _, slot := range rules.TimeSlots() {
+		if slot == waktu {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: waktu %s bukan slot layanan KUA", ErrSlotInvalid, waktu)
+}
+
+// ListDay mengembalikan status semua slot KUA pada tanggal tertentu.
+// userID dipakai untuk menandai slot yang sedang ditahan user itu sendiri.
+func (ss *SlotService) ListDay(kua *structs.KUA, tanggal time.Time, userID string) ([]SlotInfo, error) {
+	rules := RulesForKUA(kua)
+
+	var rows []structs.SlotNikah
+	if err := ss.DB.Scopes(TenantScope(kua.ID)).Where("tanggal = ?", tanggal.Format("2006-01-02")).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	byWaktu := make(map[string]structs.SlotNikah, len(rows))
+	for _, row := range rows {
+		byWaktu[row.Waktu] = row
+	}
+
+	libur, err := NewHolidayService(ss.DB).Get(kua.ID, tanggal)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tutup := rules.IsClosedDay(tanggal) || libur != nil
+	slots := make([]SlotInfo, 0)
+	for _, waktu := range rules.TimeSlots() {
+		info := SlotInfo{Waktu: waktu, Status: SlotStatusTersedia, Tersedia: true}
+		if tutup {
+			info.Status = SlotStatusTutup
+			info.Tersedia = false
+		} else if row, ok := byWaktu[waktu]; ok && !isExpiredHold(&row, now) {
+			info.Status = row.Status
+			info.Tersedia = false
+			if userID != "" && row.Ditahan_oleh == userID && row.Status == structs.SlotNikahStatusDitahan {
+				info.MilikSaya = true
+				info.HoldID = row.ID
+				info.DitahanSampai = row.Kedaluwarsa_pada
+			}
+		}
+		slots = append(slots, info)
+	}
+	return slots, nil
+}
+
+// Hold menahan slot untuk user selama SlotHoldDuration. Penahanan lain milik user yang sama dilepas
+// (satu user hanya boleh menahan satu slot). Menahan ulang slot sendiri memperpanjang waktunya.
+func (ss *SlotService) Hold(kua *structs.KUA, tanggal time.Time, waktu, userID string) (*structs.SlotNikah, error) {
+	if err := ValidateSlot(RulesForKUA(kua), NewKUAService(ss.DB).ZonaWaktu(kua.ID), tanggal, waktu); err != nil {
+		return nil, err
+	}
+	if libur, err := NewHolidayService(ss.DB).Get(kua.ID, tanggal); err != nil {
+		return nil, err
+	} else if libur != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSlotInvalid, libur.Nama)
+	}
+
+	tanggalStr := tanggal.Format("2006-01-02")
+	var slot structs.SlotNikah
+	err := ss.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("ditahan_oleh = ? AND status = ? AND NOT (kua_id = ? AND tanggal = ? AND waktu = ?)",
+			userID, structs.SlotNikahStatusDitahan, kua.ID, tanggalStr, waktu).
+			Delete(&structs.SlotNikah{}).Error; err != nil {
+			return err
+		}
+
+		expires := time.Now().Add(SlotHoldDuration)
+		return claimSlot(tx, &slot, kua.ID, tanggalStr, waktu, userID, func(s *structs.SlotNikah) {
+			s.Status = structs.SlotNikahStatusDitahan
+			s.Pendaftaran_id = nil
+			s.Kedaluwarsa_pada = &expires
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &slot, nil
+}
+
+// Release melepas penahanan slot milik user
+func (ss *SlotService) Release(holdID uint, userID string) error {
+	result := ss.DB.Where("id = ? AND ditahan_oleh = ? AND status = ?", holdID, userID, structs.SlotNikahStatusDitahan).
+		Delete(&structs.SlotNikah{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSlotHoldNotFound
+	}
+	return nil
+}
+
+// PurgeExpiredHolds menghapus penahanan slot yang sudah kedaluwarsa
+func (ss *SlotService) PurgeExpiredHolds() (int64, error) {
+	result := ss.DB.Where("status = ? AND kedaluwarsa_pada < ?", structs.SlotNikahStatusDitahan, time.Now()).
+		Delete(&structs.SlotNikah{})
+	return result.RowsAffected, result.Error
+}
+
+// BookSlot memesan slot untuk pendaftaran di dalam transaction pemanggil.
+// Slot boleh dipesan jika belum ada barisnya, ditahan oleh user yang sama, atau penahanannya sudah kedaluwarsa.
+// Baris slot dikunci (SELECT ... FOR UPDATE) dan unique index mencegah dua pemesanan bersamaan.
+func BookSlot(tx *gorm.DB, kuaID uint, tanggal, waktu, userID string, pendaftaranID uint) error {
+	var slot structs.SlotNikah
+	return claimSlot(tx, &slot, kuaID, tanggal, waktu, userID, func(s *structs.SlotNikah) {
+		s.Status = structs.SlotNikahStatusTerpesan
+		s.Pendaftaran_id = &pendaftaranID
+		s.Kedaluwarsa_pada = nil
+	})
+}
+
+// ReleaseRegistrationSlot melepas slot yang dipesan pendaftaran (mis. saat pendaftaran ditolak)
+func ReleaseRegistrationSlot(tx *gorm.DB, pendaftaranID uint) error {
+	return tx.Where("pendaftaran_id = ?", pendaftaranID).Delete(&structs.SlotNikah{}).Error
+}
+
+// claimSlot mengunci baris slot lalu mengambilnya untuk user, atau membuat baris baru jika slot masih kosong
+func claimSlot(tx *gorm.DB, slot *structs.SlotNikah, kuaID uint, tanggal, waktu, userID string, apply func(*structs.SlotNikah)) error {
+	now := time.Now()
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("kua_id = ? AND tanggal = ? AND waktu = ?", kuaID, tanggal, waktu).
+		First(slot).Error
+
+	switch {
+	case err == nil:
+		if !canClaim(slot, userID, now) {
+			return ErrSlotUnavailable
+		}
+		slot.Ditahan_oleh = userID
+		apply(slot)
+		slot.Updated_at = now
+		return tx.Save(slot).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		*slot = structs.SlotNikah{
+			Kua_id:       kuaID,
+			Tanggal:      tanggal,
+			Waktu:        waktu,
+			Ditahan_oleh: userID,
+			Created_at:   now,
+			Updated_at:   now,
+		}
+		apply(slot)
+		if err := tx.Create(slot).Error; err != nil {
+			// Pelanggaran unique index: slot baru saja diambil request lain
+			var count int64
+			if tx.Model(&structs.SlotNikah{}).Where("kua_id = ? AND tanggal = ? AND waktu = ?", kuaID, tanggal, waktu).Count(&count); count > 0 {
+				return ErrSlotUnavailable
+			}
+			return err
+		}
+		return nil
+	default:
+		return err
+	}
+}
+
+// canClaim mengecek apakah slot yang sudah ada barisnya boleh diambil user
+func canClaim(slot *structs.SlotNikah, userID string, now time.Time) bool {
+	if slot.Status != structs.SlotNikahStatusDitahan {
+		return false
+	}
+	return slot.Ditahan_oleh == userID || isExpiredHold(slot, now)
+}
+
+// isExpiredHold mengecek apakah baris slot adalah penahanan yang sudah kedaluwarsa
+func isExpiredHold(slot *structs.SlotNikah, now time.Time) bool {
+	return slot.Status == structs.SlotNikahStatusDitahan &&
+		slot.Kedaluwarsa_pada != nil && slot.Kedaluwarsa_pada.Before(now)
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/locale"
+)
+
+func TestValidateSlot(t *testing.T) {
+	rules := DefaultSchedulingRules()
+	rules.HariTutup = []int{0}
+
+	besok := time.Now().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if besok.Weekday() == time.Sunday {
+		besok = besok.AddDate(0, 0, 1)
+	}
+	minggu := besok
+	for minggu.Weekday() != time.Sunday {
+		minggu = minggu.AddDate(0, 0, 1)
+	}
+
+	tests := []struct {
+		name    string
+		tanggal time.Time
+		waktu   string
+		valid   bool
+	}{
+		{"slot layanan", besok, "09:00", true},
+		{"slot terakhir", besok, "16:00", true},
+		{"bukan awal slot", besok, "09:30", false},
+		{"di luar jam layanan", besok, "17:00", false},
+		{"hari tutup", minggu, "09:00", false},
+		{"tanggal lewat", besok.AddDate(0, 0, -7), "09:00", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSlot(rules, locale.WITA, tt.tanggal, tt.waktu)
+			if tt.valid && err != nil {
+				t.Fatalf("ValidateSlot() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrSlotInvalid) {
+				t.Fatalf("ValidateSlot() = %v, want ErrSlotInvalid", err)
+			}
+		})
+	}
+}
+
+func TestTanggalLokal(t *testing.T) {
+	tests := []struct {
+		name string
+		now  time.Time
+		loc  *time.Location
+		want string
+	}{
+		{"siang WIB", time.Date(2026, 11, 1, 5, 0, 0, 0, time.UTC), locale.WIB, "2026-11-01"},
+		{"lewat tengah malam WITA", time.Date(2026, 11, 1, 16, 30, 0, 0, time.UTC), locale.WITA, "2026-11-02"},
+		{"belum tengah malam WITA", time.Date(2026, 11, 1, 15, 30, 0, 0, time.UTC), locale.WITA, "2026-11-01"},
+		{"lewat tengah malam WIT", time.Date(2026, 11, 1, 15, 0, 0, 0, time.UTC), locale.WIT, "2026-11-02"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TanggalLokal(tt.now, tt.loc)
+			want, _ := time.Parse("2006-01-02", tt.want)
+			if !got.Equal(want) {
+				t.Errorf("TanggalLokal() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	// Tanggal kemarin di zona KUA ditolak walaupun masih "hari ini" menurut UTC
+	kemarin := TanggalLokal(time.Now(), locale.WIT).AddDate(0, 0, -1)
+	if err := ValidateSlot(DefaultSchedulingRules(), locale.WIT, kemarin, "09:00"); !errors.Is(err, ErrSlotInvalid) || !strings.Contains(err.Error(), "sudah lewat") {
+		t.Errorf("ValidateSlot(kemarin WIT) = %v, want tanggal sudah lewat", err)
+	}
+}
+
+func TestCanClaimSlot(t *testing.T) {
+	now := time.Now()
+	nanti := now.Add(SlotHoldDuration)
+	tadi := now.Add(-time.Minute)
+
+	ditahanA := &structs.SlotNikah{Status: structs.SlotNikahStatusDitahan, Ditahan_oleh: "USR1", Kedaluwarsa_pada: &nanti}
+	kedaluwarsaA := &structs.SlotNikah{Status: structs.SlotNikahStatusDitahan, Ditahan_oleh: "USR1", Kedaluwarsa_pada: &tadi}
+	terpesanA := &structs.SlotNikah{Status: structs.SlotNikahStatusTerpesan, Ditahan_oleh: "USR1"}
+
+	tests := []struct {
+		name   string
+		slot   *structs.SlotNikah
+		userID string
+		want   bool
+	}{
+		{"penahan sendiri", ditahanA, "USR1", true},
+		{"ditahan user lain", ditahanA, "USR2", false},
+		{"penahanan kedaluwarsa", kedaluwarsaA, "USR2", true},
+		{"sudah dipesan", terpesanA, "USR1", false},
+		{"sudah dipesan user lain", terpesanA, "USR2", false},
+	}
+
+	for _, tt := range tests {
+		if got := canClaim(tt.slot, tt.userID, now); got != tt.want {
+			t.Errorf("%s: canClaim() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
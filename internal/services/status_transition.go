@@ -0,0 +1,795 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== STATUS TRANSITION (STATE MACHINE) ====================
+// Semua perubahan Status_pendaftaran harus melewati tabel transisi di bawah ini.
+// Handler tidak boleh lagi meng-assign Status_pendaftaran secara langsung.
+
+// Define constants for TransitionError Type
+const (
+	TransitionErrorInvalid      = "invalid_transition"
+	TransitionErrorForbidden    = "forbidden"
+	TransitionErrorPrecondition = "precondition"
+	TransitionErrorConflict     = "conflict"
+)
+
+// CatatanMaxLength adalah panjang maksimum catatan transisi (kolom riwayat_statuses.catatan)
+const CatatanMaxLength = 500
+
+// ErrCatatanTooLong dikembalikan ValidateCatatan jika catatan melebihi CatatanMaxLength karakter
+var ErrCatatanTooLong = fmt.Errorf("catatan maksimal %d karakter", CatatanMaxLength)
+
+// ValidateCatatan memastikan catatan dari input user muat di riwayat status
+func ValidateCatatan(catatan string) error {
+	if utf8.RuneCountInString(catatan) > CatatanMaxLength {
+		return ErrCatatanTooLong
+	}
+	return nil
+}
+
+// StatusFlowSteps adalah urutan status alur pendaftaran normal yang ditampilkan di timeline
+// (tanpa Draft, Ditolak, dan Dibatalkan)
+var StatusFlowSteps = []string{
+	structs.StatusPendaftaranMenungguVerifikasi,
+	structs.StatusPendaftaranMenungguPengumpulanBerkas,
+	structs.StatusPendaftaranBerkasDiterima,
+	structs.StatusPendaftaranMenungguPenugasan,
+	structs.StatusPendaftaranMenungguVerifikasiPenghulu,
+	structs.StatusPendaftaranMenungguBimbingan,
+	structs.StatusPendaftaranSudahBimbingan,
+	structs.StatusPendaftaranSelesai,
+}
+
+// StatusFlowProgress menghitung posisi pendaftaran di StatusFlowSteps dari riwayat status (urut waktu).
+// reached berisi riwayat yang mencapai setiap langkah pada alur yang sedang berjalan: kembali ke langkah
+// sebelumnya (mis. diajukan ulang setelah ditolak) membatalkan langkah sesudahnya. Langkah completed
+// adalah langkah yang sudah dilewati; Selesai ikut completed begitu tercapai. Untuk pendaftaran Ditolak
+// atau Dibatalkan, langkah tempat pendaftaran berhenti tidak dihitung selesai.
+func StatusFlowProgress(riwayat []structs.RiwayatStatus, current string) (reached map[string]structs.RiwayatStatus, completed map[string]bool) {
+	stepIndex := func(status string) int {
+		for i, step := range StatusFlowSteps {
+			if step == status {
+				return i
+			}
+		}
+		return -1
+	}
+
+	path := make(map[int]structs.RiwayatStatus)
+	currentIdx := -1
+	for _, r := range riwayat {
+		if r.Status_dari == r.Status_ke {
+			continue
+		}
+		idx := stepIndex(r.Status_ke)
+		switch {
+		case idx >= 0:
+			for i := range path {
+				if i > idx {
+					delete(path, i)
+				}
+			}
+			path[idx] = r
+			currentIdx = idx
+		case r.Status_ke == structs.StatusPendaftaranDraft:
+			path = make(map[int]structs.RiwayatStatus)
+			currentIdx = -1
+		default:
+			// Ditolak/Dibatalkan: berhenti di langkah asalnya
+			currentIdx = stepIndex(r.Status_dari)
+		}
+	}
+	if idx := stepIndex(current); idx >= 0 {
+		currentIdx = idx
+	}
+
+	reached = make(map[string]structs.RiwayatStatus, len(path))
+	completed = make(map[string]bool, len(path))
+	for idx, r := range path {
+		reached[StatusFlowSteps[idx]] = r
+		if idx < currentIdx || StatusFlowSteps[idx] == structs.StatusPendaftaranSelesai {
+			completed[StatusFlowSteps[idx]] = true
+		}
+	}
+	return reached, completed
+}
+
+// TransitionActor adalah user yang melakukan perubahan status
+type TransitionActor struct {
+	UserID string
+	Role   string
+	KuaID  uint // KUA tempat actor bertugas (0 untuk catin yang belum memilih KUA)
+}
+
+// TransitionPrecondition adalah syarat yang harus dipenuhi sebelum transisi dijalankan
+type TransitionPrecondition struct {
+	Name        string
+	Description string
+	Check       func(db *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error
+}
+
+// StatusTransition mendefinisikan satu perpindahan status yang sah
+type StatusTransition struct {
+	From          string
+	To            string
+	Aksi          string
+	Deskripsi     string
+	Roles         []string
+	Endpoint      string // jika diisi, transisi hanya boleh dilakukan lewat endpoint khusus ini
+	Preconditions []TransitionPrecondition
+	SideEffect    func(tx *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error
+}
+
+// TransitionError adalah error dari state machine beserta tipenya
+type TransitionError struct {
+	Type    string
+	Message string
+	Reasons []string
+}
+
+func (e *TransitionError) Error() string {
+	if len(e.Reasons) == 0 {
+		return e.Message
+	}
+	return e.Message + ": " + strings.Join(e.Reasons, "; ")
+}
+
+// StatusCode memetakan tipe error ke HTTP status code
+func (e *TransitionError) StatusCode() int {
+	switch e.Type {
+	case TransitionErrorForbidden:
+		return http.StatusForbidden
+	case TransitionErrorConflict:
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// AvailableTransition adalah transisi berikutnya yang dapat dilihat oleh user
+type AvailableTransition struct {
+	StatusTujuan string   `json:"status_tujuan"`
+	Aksi         string   `json:"aksi"`
+	Deskripsi    string   `json:"deskripsi"`
+	Endpoint     string   `json:"endpoint,omitempty"`
+	Diizinkan    bool     `json:"diizinkan"`
+	Alasan       []string `json:"alasan,omitempty"`
+}
+
+// ==================== PRECONDITIONS ====================
+
+// forbiddenPrecondition dipakai precondition yang menyangkut hak akses actor,
+// sehingga kegagalannya dilaporkan sebagai 403 dan bukan sekadar syarat yang belum terpenuhi
+func forbiddenPrecondition(message string) error {
+	return &TransitionError{Type: TransitionErrorForbidden, Message: message}
+}
+
+var (
+	// precondPemilik - hanya pendaftar sendiri yang boleh melakukan transisi (untuk role user_biasa)
+	precondPemilik = TransitionPrecondition{
+		Name:        "pemilik_pendaftaran",
+		Description: "Pendaftaran milik user yang login",
+		Check: func(db *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+			if actor.Role == structs.UserRoleUserBiasa && p.Pendaftar_id != actor.UserID {
+				return forbiddenPrecondition("Pendaftaran bukan milik Anda")
+			}
+			return nil
+		},
+	}
+
+	// precondPenghuluDitugaskan - pendaftaran sudah memiliki penghulu
+	precondPenghuluDitugaskan = TransitionPrecondition{
+		Name:        "penghulu_ditugaskan",
+		Description: "Penghulu sudah ditugaskan",
+		Check: func(db *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+			if p.Penghulu_id == nil || *p.Penghulu_id == 0 {
+				return errors.New("penghulu belum ditugaskan")
+			}
+			return nil
+		},
+	}
+
+	// precondPenghuluPelaksana - jika dilakukan oleh penghulu, harus penghulu yang ditugaskan
+	precondPenghuluPelaksana = TransitionPrecondition{
+		Name:        "penghulu_pelaksana",
+		Description: "Penghulu yang login adalah penghulu yang ditugaskan",
+		Check: func(db *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+			if actor.Role != structs.UserRolePenghulu {
+				return nil
+			}
+			if p.Penghulu_id == nil {
+				return forbiddenPrecondition("Anda tidak ditugaskan untuk pendaftaran ini")
+			}
+			var penghulu structs.Penghulu
+			if err := db.Where("user_id = ?", actor.UserID).First(&penghulu).Error; err != nil {
+				return forbiddenPrecondition("Data penghulu tidak ditemukan")
+			}
+			if penghulu.ID != *p.Penghulu_id {
+				return forbiddenPrecondition("Anda tidak ditugaskan untuk pendaftaran ini")
+			}
+			return nil
+		},
+	}
+
+	// precondTerdaftarBimbingan - sudah terdaftar bimbingan dan tidak ditandai tidak hadir
+	precondTerdaftarBimbingan = TransitionPrecondition{
+		Name:        "terdaftar_bimbingan",
+		Description: "Terdaftar bimbingan perkawinan dan tidak ditandai tidak hadir",
+		Check: func(db *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+			var pb structs.PendaftaranBimbingan
+			if err := db.Where("pendaftaran_nikah_id = ?", p.ID).First(&pb).Error; err != nil {
+				return errors.New("belum terdaftar bimbingan perkawinan")
+			}
+			if pb.Status_kehadiran == structs.PendaftaranBimbinganKehadiranTidakHadir {
+				return errors.New("catin ditandai tidak hadir pada bimbingan perkawinan")
+			}
+			return nil
+		},
+	}
+
+	// precondHadirBimbingan - kehadiran bimbingan sudah tercatat "Hadir"
+	precondHadirBimbingan = TransitionPrecondition{
+		Name:        "hadir_bimbingan",
+		Description: "Kehadiran bimbingan perkawinan tercatat Hadir",
+		Check: func(db *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+			var pb structs.PendaftaranBimbingan
+			if err := db.Where("pendaftaran_nikah_id = ?", p.ID).First(&pb).Error; err != nil {
+				return errors.New("belum terdaftar bimbingan perkawinan")
+			}
+			if pb.Status_kehadiran != structs.PendaftaranBimbinganKehadiranHadir {
+				return errors.New("kehadiran bimbingan perkawinan belum tercatat")
+			}
+			return nil
+		},
+	}
+
+	// precondDispensasi - nomor dispensasi wajib ada jika nikah < 10 hari kerja atau catin < 19 tahun
+	precondDispensasi = TransitionPrecondition{
+		Name:        "dispensasi",
+		Description: "Nomor dispensasi tersedia jika diperlukan",
+		Check: func(db *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+			if strings.TrimSpace(p.Nomor_dispensasi) != "" {
+				return nil
+			}
+			if reasons := DispensationReasons(db, p); len(reasons) > 0 {
+				return errors.New("nomor dispensasi wajib ada karena: " + strings.Join(reasons, " dan "))
+			}
+			return nil
+		},
+	}
+
+	// precondBerkasLengkap - semua dokumen di checklist pendaftaran sudah diterima atau ditandai ada
+	precondBerkasLengkap = TransitionPrecondition{
+		Name:        "berkas_lengkap",
+		Description: "Semua dokumen di checklist sudah diterima atau ditandai ada oleh petugas",
+		Check: func(db *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+			items, err := NewDokumenChecklistService(db).Build(p)
+			if err != nil {
+				return errors.New("gagal memeriksa checklist dokumen")
+			}
+			if kurang := ChecklistKurang(items); len(kurang) > 0 {
+				return errors.New("dokumen belum lengkap: " + strings.Join(kurang, ", "))
+			}
+			return nil
+		},
+	}
+)
+
+// DispensationReasons mengembalikan alasan kenapa pendaftaran memerlukan dispensasi
+func DispensationReasons(db *gorm.DB, p *structs.PendaftaranNikah) []string {
+	var reasons []string
+
+	tanggalDaftar := p.Tanggal_pendaftaran
+	if tanggalDaftar.IsZero() {
+		tanggalDaftar = p.Created_at
+	}
+	workingDays, err := NewHolidayService(db).WorkingDays(p.Kua_id, tanggalDaftar, p.Tanggal_nikah)
+	if err != nil {
+		workingDays = utils.CalculateWorkingDays(tanggalDaftar, p.Tanggal_nikah)
+	}
+	if workingDays < 10 {
+		reasons = append(reasons, "Pelaksanaan nikah kurang dari 10 hari kerja")
+	}
+
+	calon := []struct {
+		id    string
+		label string
+	}{
+		{p.Calon_suami_id, "Calon suami"},
+		{p.Calon_istri_id, "Calon istri"},
+	}
+	for _, cp := range calon {
+		id, err := strconv.ParseUint(cp.id, 10, 64)
+		if err != nil {
+			continue
+		}
+		var calonPasangan structs.CalonPasangan
+		if err := db.First(&calonPasangan, id).Error; err != nil {
+			continue
+		}
+		if utils.CalculateAge(calonPasangan.Tanggal_lahir, tanggalDaftar) < 19 {
+			reasons = append(reasons, cp.label+" berumur kurang dari 19 tahun")
+		}
+	}
+
+	return reasons
+}
+
+// ==================== SIDE EFFECTS ====================
+
+// efekDisetujuiPetugas mencatat petugas yang memverifikasi
+func efekDisetujuiPetugas(tx *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+	now := time.Now()
+	p.Disetujui_oleh = actor.UserID
+	p.Disetujui_pada = &now
+	return nil
+}
+
+// efekSelesaiBimbingan menandai bimbingan selesai beserta kehadiran dan sertifikatnya
+func efekSelesaiBimbingan(tx *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+	p.Status_bimbingan = structs.StatusBimbinganSudah
+	return tx.Model(&structs.PendaftaranBimbingan{}).
+		Where("pendaftaran_nikah_id = ?", p.ID).
+		Updates(map[string]interface{}{
+			"status_kehadiran":  structs.PendaftaranBimbinganKehadiranHadir,
+			"status_sertifikat": structs.PendaftaranBimbinganSertifikatSudah,
+			"updated_at":        time.Now(),
+		}).Error
+}
+
+// efekSelesaiNikah menerbitkan sertifikat bimbingan saat nikah selesai
+func efekSelesaiNikah(tx *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+	p.Status_bimbingan = structs.StatusBimbinganSertifikatDiterbitkan
+	return nil
+}
+
+// efekBukaKembali memesan ulang slot KUA yang dilepas saat pendaftaran ditolak
+func efekBukaKembali(tx *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+	if p.Tempat_nikah != "Di KUA" {
+		return nil
+	}
+	err := BookSlot(tx, p.Kua_id, p.Tanggal_nikah.Format("2006-01-02"), p.Waktu_nikah, p.Pendaftar_id, p.ID)
+	if errors.Is(err, ErrSlotUnavailable) {
+		return &TransitionError{
+			Type:    TransitionErrorConflict,
+			Message: fmt.Sprintf("Slot %s %s sudah dipesan pendaftaran lain", p.Tanggal_nikah.Format("2006-01-02"), p.Waktu_nikah),
+		}
+	}
+	return err
+}
+
+// efekBatal melepas penugasan penghulu, kursi bimbingan yang belum diikuti, dan pengajuan perubahan jadwal
+// yang masih menunggu. Slot balai KUA dilepas oleh Apply.
+func efekBatal(tx *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+	if p.Penghulu_id != nil {
+		p.Penghulu_sebelumnya_id = p.Penghulu_id
+	}
+	p.Penghulu_id = nil
+	p.Penghulu_assigned_by = ""
+	p.Penghulu_assigned_at = nil
+	p.Konflik_ketidaksediaan_id = nil
+
+	if err := tx.Where("pendaftaran_nikah_id = ? AND status_kehadiran = ?", p.ID, structs.PendaftaranBimbinganKehadiranBelum).
+		Delete(&structs.PendaftaranBimbingan{}).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return tx.Model(&structs.PerubahanJadwal{}).
+		Where("pendaftaran_id = ? AND status = ?", p.ID, structs.PerubahanJadwalStatusMenunggu).
+		Updates(map[string]interface{}{
+			"status":         structs.PerubahanJadwalStatusDibatalkan,
+			"diproses_oleh":  actor.UserID,
+			"diproses_pada":  now,
+			"catatan_proses": "Pendaftaran dibatalkan",
+			"updated_at":     now,
+		}).Error
+}
+
+// ==================== TRANSITION TABLE ====================
+
+var petugasKUA = []string{structs.UserRoleStaff, structs.UserRoleKepalaKUA}
+
+// transisiBatal membuat transisi pembatalan pendaftaran oleh catin pemilik atau petugas KUA.
+// Pembatalan hanya lewat endpoint khusus agar alasan dicatat dan penghulu diberi tahu.
+func transisiBatal(from string) StatusTransition {
+	return StatusTransition{
+		From:          from,
+		To:            structs.StatusPendaftaranDibatalkan,
+		Aksi:          "batalkan",
+		Deskripsi:     "Batalkan pendaftaran, lepas slot dan penugasan penghulu",
+		Roles:         []string{structs.UserRoleUserBiasa, structs.UserRoleStaff, structs.UserRoleKepalaKUA},
+		Endpoint:      "POST /simnikah/pendaftaran/:id/batalkan",
+		Preconditions: []TransitionPrecondition{precondPemilik},
+		SideEffect:    efekBatal,
+	}
+}
+
+// statusTransitions adalah satu-satunya sumber kebenaran alur status pendaftaran nikah
+var statusTransitions = []StatusTransition{
+	{
+		From:          structs.StatusPendaftaranDraft,
+		To:            structs.StatusPendaftaranMenungguVerifikasi,
+		Aksi:          "ajukan",
+		Deskripsi:     "Ajukan pendaftaran untuk diverifikasi staff",
+		Roles:         []string{structs.UserRoleUserBiasa},
+		Preconditions: []TransitionPrecondition{precondPemilik},
+	},
+	{
+		From:          structs.StatusPendaftaranMenungguVerifikasi,
+		To:            structs.StatusPendaftaranMenungguPengumpulanBerkas,
+		Aksi:          "setujui_formulir",
+		Deskripsi:     "Formulir disetujui, catin diminta mengumpulkan berkas",
+		Roles:         petugasKUA,
+		Preconditions: []TransitionPrecondition{precondDispensasi},
+		SideEffect:    efekDisetujuiPetugas,
+	},
+	{
+		From:       structs.StatusPendaftaranMenungguVerifikasi,
+		To:         structs.StatusPendaftaranDitolak,
+		Aksi:       "tolak_formulir",
+		Deskripsi:  "Formulir ditolak",
+		Roles:      petugasKUA,
+		SideEffect: efekDisetujuiPetugas,
+	},
+	{
+		From:          structs.StatusPendaftaranMenungguPengumpulanBerkas,
+		To:            structs.StatusPendaftaranBerkasDiterima,
+		Aksi:          "terima_berkas",
+		Deskripsi:     "Berkas fisik diterima staff",
+		Roles:         petugasKUA,
+		Preconditions: []TransitionPrecondition{precondDispensasi, precondBerkasLengkap},
+		SideEffect:    efekDisetujuiPetugas,
+	},
+	{
+		From:       structs.StatusPendaftaranMenungguPengumpulanBerkas,
+		To:         structs.StatusPendaftaranDitolak,
+		Aksi:       "tolak_berkas",
+		Deskripsi:  "Berkas fisik ditolak",
+		Roles:      petugasKUA,
+		SideEffect: efekDisetujuiPetugas,
+	},
+	{
+		From:          structs.StatusPendaftaranBerkasDiterima,
+		To:            structs.StatusPendaftaranMenungguPenugasan,
+		Aksi:          "tandai_datang",
+		Deskripsi:     "Catin sudah datang ke kantor, siap ditugaskan penghulu",
+		Roles:         []string{structs.UserRoleUserBiasa, structs.UserRoleStaff, structs.UserRoleKepalaKUA},
+		Preconditions: []TransitionPrecondition{precondPemilik},
+	},
+	{
+		From:          structs.StatusPendaftaranMenungguPenugasan,
+		To:            structs.StatusPendaftaranMenungguVerifikasiPenghulu,
+		Aksi:          "tugaskan_penghulu",
+		Deskripsi:     "Tugaskan penghulu untuk memverifikasi berkas",
+		Roles:         []string{structs.UserRoleKepalaKUA},
+		Endpoint:      "POST /simnikah/pendaftaran/:id/assign-penghulu",
+		Preconditions: []TransitionPrecondition{precondPenghuluDitugaskan},
+	},
+	{
+		From:      structs.StatusPendaftaranMenungguPenugasan,
+		To:        structs.StatusPendaftaranDitolak,
+		Aksi:      "tolak_pendaftaran",
+		Deskripsi: "Pendaftaran ditolak sebelum penugasan penghulu",
+		Roles:     []string{structs.UserRoleKepalaKUA},
+	},
+	{
+		From:          structs.StatusPendaftaranMenungguVerifikasiPenghulu,
+		To:            structs.StatusPendaftaranMenungguBimbingan,
+		Aksi:          "setujui_penghulu",
+		Deskripsi:     "Berkas disetujui penghulu, catin mengikuti bimbingan",
+		Roles:         []string{structs.UserRolePenghulu},
+		Preconditions: []TransitionPrecondition{precondPenghuluDitugaskan, precondPenghuluPelaksana},
+	},
+	{
+		From:          structs.StatusPendaftaranMenungguVerifikasiPenghulu,
+		To:            structs.StatusPendaftaranDitolak,
+		Aksi:          "tolak_penghulu",
+		Deskripsi:     "Berkas ditolak penghulu",
+		Roles:         []string{structs.UserRolePenghulu},
+		Preconditions: []TransitionPrecondition{precondPenghuluDitugaskan, precondPenghuluPelaksana},
+	},
+	{
+		From:          structs.StatusPendaftaranMenungguBimbingan,
+		To:            structs.StatusPendaftaranSudahBimbingan,
+		Aksi:          "selesai_bimbingan",
+		Deskripsi:     "Bimbingan perkawinan selesai diikuti",
+		Roles:         petugasKUA,
+		Preconditions: []TransitionPrecondition{precondPenghuluDitugaskan, precondTerdaftarBimbingan},
+		SideEffect:    efekSelesaiBimbingan,
+	},
+	{
+		From:          structs.StatusPendaftaranSudahBimbingan,
+		To:            structs.StatusPendaftaranSelesai,
+		Aksi:          "selesai_nikah",
+		Deskripsi:     "Akad nikah telah dilaksanakan",
+		Roles:         petugasKUA,
+		Preconditions: []TransitionPrecondition{precondPenghuluDitugaskan, precondHadirBimbingan, precondDispensasi},
+		SideEffect:    efekSelesaiNikah,
+	},
+	{
+		From:       structs.StatusPendaftaranDitolak,
+		To:         structs.StatusPendaftaranMenungguVerifikasi,
+		Aksi:       "buka_kembali",
+		Deskripsi:  "Buka kembali pendaftaran yang ditolak untuk diverifikasi ulang",
+		Roles:      petugasKUA,
+		SideEffect: efekBukaKembali,
+	},
+	transisiBatal(structs.StatusPendaftaranDraft),
+	transisiBatal(structs.StatusPendaftaranMenungguVerifikasi),
+	transisiBatal(structs.StatusPendaftaranMenungguPengumpulanBerkas),
+	transisiBatal(structs.StatusPendaftaranBerkasDiterima),
+	transisiBatal(structs.StatusPendaftaranMenungguPenugasan),
+	transisiBatal(structs.StatusPendaftaranMenungguVerifikasiPenghulu),
+	transisiBatal(structs.StatusPendaftaranMenungguBimbingan),
+	transisiBatal(structs.StatusPendaftaranSudahBimbingan),
+}
+
+// GetStatusTransitions mengembalikan salinan tabel transisi
+func GetStatusTransitions() []StatusTransition {
+	transitions := make([]StatusTransition, len(statusTransitions))
+	copy(transitions, statusTransitions)
+	return transitions
+}
+
+// FindTransition mencari transisi dari status asal ke status tujuan
+func FindTransition(from, to string) (*StatusTransition, bool) {
+	for i := range statusTransitions {
+		if statusTransitions[i].From == from && statusTransitions[i].To == to {
+			return &statusTransitions[i], true
+		}
+	}
+	return nil, false
+}
+
+// AllowsRole mengecek apakah role boleh menjalankan transisi
+func (t *StatusTransition) AllowsRole(role string) bool {
+	for _, r := range t.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ==================== SERVICE ====================
+
+// StatusTransitionService menjalankan transisi status pendaftaran sesuai tabel transisi
+type StatusTransitionService struct {
+	DB *gorm.DB
+}
+
+// NewStatusTransitionService membuat instance baru dari StatusTransitionService
+func NewStatusTransitionService(db *gorm.DB) *StatusTransitionService {
+	return &StatusTransitionService{DB: db}
+}
+
+// Validate mengecek transisi tanpa menyimpan perubahan apapun
+func (s *StatusTransitionService) Validate(p *structs.PendaftaranNikah, to string, actor TransitionActor) (*StatusTransition, error) {
+	return validateTransition(s.DB, p, to, actor)
+}
+
+func validateTransition(db *gorm.DB, p *structs.PendaftaranNikah, to string, actor TransitionActor) (*StatusTransition, error) {
+	transition, ok := FindTransition(p.Status_pendaftaran, to)
+	if !ok {
+		return nil, &TransitionError{
+			Type:    TransitionErrorInvalid,
+			Message: fmt.Sprintf("Perubahan status dari '%s' ke '%s' tidak diizinkan", p.Status_pendaftaran, to),
+		}
+	}
+
+	if !transition.AllowsRole(actor.Role) {
+		return transition, &TransitionError{
+			Type:    TransitionErrorForbidden,
+			Message: fmt.Sprintf("Role '%s' tidak dapat mengubah status dari '%s' ke '%s'", actor.Role, transition.From, transition.To),
+		}
+	}
+
+	forbidden, reasons := checkPreconditions(db, transition, p, actor)
+	if forbidden != nil {
+		return transition, forbidden
+	}
+	if len(reasons) > 0 {
+		return transition, &TransitionError{
+			Type:    TransitionErrorPrecondition,
+			Message: "Syarat perubahan status belum terpenuhi",
+			Reasons: reasons,
+		}
+	}
+
+	return transition, nil
+}
+
+// checkPreconditions menjalankan setiap precondition transisi tepat satu kali. Mengembalikan
+// precondition hak akses pertama yang gagal (forbidden) beserta alasan semua precondition yang gagal.
+func checkPreconditions(db *gorm.DB, t *StatusTransition, p *structs.PendaftaranNikah, actor TransitionActor) (*TransitionError, []string) {
+	var forbidden *TransitionError
+	var reasons []string
+	for _, pre := range t.Preconditions {
+		err := pre.Check(db, p, actor)
+		if err == nil {
+			continue
+		}
+		var transitionErr *TransitionError
+		if forbidden == nil && errors.As(err, &transitionErr) && transitionErr.Type == TransitionErrorForbidden {
+			forbidden = transitionErr
+		}
+		reasons = append(reasons, err.Error())
+	}
+	return forbidden, reasons
+}
+
+// LockPendaftaran memuat ulang p dengan kunci baris di dalam transaksi tx. Mengembalikan TransitionError
+// conflict jika status pendaftaran sudah berubah sejak p dibaca.
+func LockPendaftaran(tx *gorm.DB, p *structs.PendaftaranNikah) error {
+	var current structs.PendaftaranNikah
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, p.ID).Error; err != nil {
+		return err
+	}
+	if current.Status_pendaftaran != p.Status_pendaftaran {
+		return &TransitionError{
+			Type:    TransitionErrorConflict,
+			Message: fmt.Sprintf("Status pendaftaran sudah berubah menjadi '%s'", current.Status_pendaftaran),
+		}
+	}
+	*p = current
+	return nil
+}
+
+// bawaPenugasanPenghulu menyalin penugasan penghulu yang baru diisi pemanggil ke baris p yang dimuat ulang.
+// Penugasan dianggap baru jika waktu penugasannya lebih baru dari yang tersimpan; salinan usang diabaikan.
+func bawaPenugasanPenghulu(p, diminta *structs.PendaftaranNikah) {
+	if diminta.Penghulu_id == nil || diminta.Penghulu_assigned_at == nil {
+		return
+	}
+	if p.Penghulu_assigned_at != nil && !diminta.Penghulu_assigned_at.After(*p.Penghulu_assigned_at) {
+		return
+	}
+	p.Penghulu_id = diminta.Penghulu_id
+	p.Penghulu_assigned_by = diminta.Penghulu_assigned_by
+	p.Penghulu_assigned_at = diminta.Penghulu_assigned_at
+}
+
+// Apply menjalankan transisi status di dalam satu database transaction:
+// validasi role & precondition, side effect, lalu menyimpan pendaftaran.
+// Pendaftaran dimuat ulang di bawah kunci baris sehingga salinan p milik pemanggil yang sudah usang
+// (misalnya sebelum jadwal diubah) tidak menimpa data terbaru. Dari p hanya penugasan penghulu baru
+// yang dibawa; yang disimpan hanya kolom yang diubah transisi dan side effect-nya.
+func (s *StatusTransitionService) Apply(p *structs.PendaftaranNikah, to string, actor TransitionActor, catatan string) (*StatusTransition, error) {
+	var applied *StatusTransition
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Kunci baris pendaftaran agar tidak ada dua transisi berjalan bersamaan
+		diminta := *p
+		if err := LockPendaftaran(tx, p); err != nil {
+			return err
+		}
+		bawaPenugasanPenghulu(p, &diminta)
+
+		transition, err := validateTransition(tx, p, to, actor)
+		if err != nil {
+			return err
+		}
+
+		if transition.SideEffect != nil {
+			if err := transition.SideEffect(tx, p, actor); err != nil {
+				var transitionErr *TransitionError
+				if errors.As(err, &transitionErr) {
+					return err
+				}
+				return fmt.Errorf("gagal menjalankan efek transisi: %v", err)
+			}
+		}
+
+		// Pendaftaran yang ditolak atau dibatalkan melepas slot KUA agar bisa dipesan catin lain
+		if transition.To == structs.StatusPendaftaranDitolak || transition.To == structs.StatusPendaftaranDibatalkan {
+			if err := ReleaseRegistrationSlot(tx, p.ID); err != nil {
+				return fmt.Errorf("gagal melepas slot nikah: %v", err)
+			}
+		}
+
+		statusLama := p.Status_pendaftaran
+		p.Status_pendaftaran = transition.To
+		if catatan != "" {
+			p.Catatan = catatan
+		}
+		p.Updated_at = time.Now()
+
+		if err := tx.Model(&structs.PendaftaranNikah{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
+			"status_pendaftaran":        p.Status_pendaftaran,
+			"status_bimbingan":          p.Status_bimbingan,
+			"catatan":                   p.Catatan,
+			"penghulu_id":               p.Penghulu_id,
+			"penghulu_assigned_by":      p.Penghulu_assigned_by,
+			"penghulu_assigned_at":      p.Penghulu_assigned_at,
+			"penghulu_sebelumnya_id":    p.Penghulu_sebelumnya_id,
+			"konflik_ketidaksediaan_id": p.Konflik_ketidaksediaan_id,
+			"disetujui_oleh":            p.Disetujui_oleh,
+			"disetujui_pada":            p.Disetujui_pada,
+			"updated_at":                p.Updated_at,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := RecordStatusHistory(tx, p.ID, statusLama, transition.To, transition.Aksi, actor, catatan); err != nil {
+			return fmt.Errorf("gagal mencatat riwayat status: %v", err)
+		}
+
+		applied = transition
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Slot dan kursi bimbingan yang dilepas ditawarkan ke daftar tunggu
+	if applied.To == structs.StatusPendaftaranDitolak || applied.To == structs.StatusPendaftaranDibatalkan {
+		NewWaitlistService(s.DB).PromoteAfterRelease(p.Kua_id)
+	}
+
+	return applied, nil
+}
+
+// NextTransitions mengembalikan transisi berikutnya yang dapat dilakukan actor dari status saat ini
+func (s *StatusTransitionService) NextTransitions(p *structs.PendaftaranNikah, actor TransitionActor) []AvailableTransition {
+	result := make([]AvailableTransition, 0)
+	for i := range statusTransitions {
+		t := &statusTransitions[i]
+		if t.From != p.Status_pendaftaran || !t.AllowsRole(actor.Role) {
+			continue
+		}
+
+		_, reasons := checkPreconditions(s.DB, t, p, actor)
+		result = append(result, AvailableTransition{
+			StatusTujuan: t.To,
+			Aksi:         t.Aksi,
+			Deskripsi:    t.Deskripsi,
+			Endpoint:     t.Endpoint,
+			Diizinkan:    len(reasons) == 0,
+			Alasan:       reasons,
+		})
+	}
+	return result
+}
+
+// ==================== RIWAYAT STATUS ====================
+
+// RecordStatusHistory mencatat satu baris riwayat status pendaftaran.
+// Gunakan tx yang sama dengan perubahan datanya agar riwayat selalu konsisten.
+func RecordStatusHistory(tx *gorm.DB, pendaftaranID uint, from, to, aksi string, actor TransitionActor, catatan string) error {
+	riwayat := structs.RiwayatStatus{
+		Pendaftaran_nikah_id: pendaftaranID,
+		Status_dari:          from,
+		Status_ke:            to,
+		Aksi:                 aksi,
+		User_id:              actor.UserID,
+		Role:                 actor.Role,
+		Catatan:              catatan,
+		Created_at:           time.Now(),
+	}
+	return tx.Create(&riwayat).Error
+}
+
+// GetStatusHistory mengambil riwayat status pendaftaran dari yang paling lama
+func (s *StatusTransitionService) GetStatusHistory(pendaftaranID uint) ([]structs.RiwayatStatus, error) {
+	var riwayat []structs.RiwayatStatus
+	if err := s.DB.Where("pendaftaran_nikah_id = ?", pendaftaranID).Order("created_at ASC, id ASC").Find(&riwayat).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil riwayat status: %v", err)
+	}
+	return riwayat, nil
+}
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/gorm"
+)
+
+func TestFindTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{structs.StatusPendaftaranDraft, structs.StatusPendaftaranMenungguVerifikasi, true},
+		{structs.StatusPendaftaranMenungguVerifikasi, structs.StatusPendaftaranMenungguPengumpulanBerkas, true},
+		{structs.StatusPendaftaranMenungguPenugasan, structs.StatusPendaftaranMenungguVerifikasiPenghulu, true},
+		{structs.StatusPendaftaranSudahBimbingan, structs.StatusPendaftaranSelesai, true},
+		{structs.StatusPendaftaranDitolak, structs.StatusPendaftaranMenungguVerifikasi, true},
+		{structs.StatusPendaftaranMenungguBimbingan, structs.StatusPendaftaranDibatalkan, true},
+		{structs.StatusPendaftaranDraft, structs.StatusPendaftaranSelesai, false},
+		{structs.StatusPendaftaranSelesai, structs.StatusPendaftaranDibatalkan, false},
+		{structs.StatusPendaftaranDibatalkan, structs.StatusPendaftaranMenungguVerifikasi, false},
+		{structs.StatusPendaftaranMenungguVerifikasi, structs.StatusPendaftaranMenungguPenugasan, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" -> "+tt.to, func(t *testing.T) {
+			transition, ok := FindTransition(tt.from, tt.to)
+			if ok != tt.want {
+				t.Fatalf("FindTransition() ok = %v, want %v", ok, tt.want)
+			}
+			if ok && (transition.From != tt.from || transition.To != tt.to) {
+				t.Errorf("FindTransition() = %s -> %s", transition.From, transition.To)
+			}
+		})
+	}
+}
+
+func TestStatusTransitionValidate(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	pelaksana := createTestPenghulu(t, db, "PGH1", kua.ID)
+	createTestPenghulu(t, db, "PGH2", kua.ID)
+
+	draft := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Status_pendaftaran: structs.StatusPendaftaranDraft,
+	})
+	verifikasiPenghulu := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Status_pendaftaran: structs.StatusPendaftaranMenungguVerifikasiPenghulu,
+		Penghulu_id:        &pelaksana.ID,
+	})
+	bimbingan := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Status_pendaftaran: structs.StatusPendaftaranMenungguBimbingan,
+		Penghulu_id:        &pelaksana.ID,
+	})
+	penugasan := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Status_pendaftaran: structs.StatusPendaftaranMenungguPenugasan,
+	})
+
+	pemilik := TransitionActor{UserID: draft.Pendaftar_id, Role: structs.UserRoleUserBiasa}
+	catinLain := TransitionActor{UserID: "CATIN9", Role: structs.UserRoleUserBiasa}
+	staff := TransitionActor{UserID: "STF1", Role: structs.UserRoleStaff, KuaID: kua.ID}
+	kepala := TransitionActor{UserID: "KPL1", Role: structs.UserRoleKepalaKUA, KuaID: kua.ID}
+	penghulu1 := TransitionActor{UserID: "PGH1", Role: structs.UserRolePenghulu, KuaID: kua.ID}
+	penghulu2 := TransitionActor{UserID: "PGH2", Role: structs.UserRolePenghulu, KuaID: kua.ID}
+
+	tests := []struct {
+		name     string
+		p        structs.PendaftaranNikah
+		to       string
+		actor    TransitionActor
+		wantType string // kosong = transisi sah
+		reason   string
+	}{
+		{"catin mengajukan pendaftaran sendiri", draft, structs.StatusPendaftaranMenungguVerifikasi, pemilik, "", ""},
+		{"catin lain", draft, structs.StatusPendaftaranMenungguVerifikasi, catinLain, TransitionErrorForbidden, ""},
+		{"staff tidak mengajukan", draft, structs.StatusPendaftaranMenungguVerifikasi, staff, TransitionErrorForbidden, ""},
+		{"lompat status", draft, structs.StatusPendaftaranSelesai, staff, TransitionErrorInvalid, ""},
+		{"penghulu pelaksana menyetujui", verifikasiPenghulu, structs.StatusPendaftaranMenungguBimbingan, penghulu1, "", ""},
+		{"penghulu lain", verifikasiPenghulu, structs.StatusPendaftaranMenungguBimbingan, penghulu2, TransitionErrorForbidden, ""},
+		{"staff bukan penghulu", verifikasiPenghulu, structs.StatusPendaftaranMenungguBimbingan, staff, TransitionErrorForbidden, ""},
+		{"belum terdaftar bimbingan", bimbingan, structs.StatusPendaftaranSudahBimbingan, staff, TransitionErrorPrecondition, "belum terdaftar bimbingan perkawinan"},
+		{"penghulu belum ditugaskan", penugasan, structs.StatusPendaftaranMenungguVerifikasiPenghulu, kepala, TransitionErrorPrecondition, "penghulu belum ditugaskan"},
+	}
+
+	ts := NewStatusTransitionService(db)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.p
+			_, err := ts.Validate(&p, tt.to, tt.actor)
+			if tt.wantType == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			var transitionErr *TransitionError
+			if !errors.As(err, &transitionErr) || transitionErr.Type != tt.wantType {
+				t.Fatalf("Validate() error = %v, want TransitionError %s", err, tt.wantType)
+			}
+			if tt.reason != "" && (len(transitionErr.Reasons) != 1 || transitionErr.Reasons[0] != tt.reason) {
+				t.Errorf("Reasons = %v, want [%s]", transitionErr.Reasons, tt.reason)
+			}
+		})
+	}
+}
+
+func TestCheckPreconditionsRunsEachCheckOnce(t *testing.T) {
+	calls := map[string]int{}
+	precond := func(name string, err error) TransitionPrecondition {
+		return TransitionPrecondition{Name: name, Check: func(db *gorm.DB, p *structs.PendaftaranNikah, actor TransitionActor) error {
+			calls[name]++
+			return err
+		}}
+	}
+	transition := &StatusTransition{Preconditions: []TransitionPrecondition{
+		precond("lolos", nil),
+		precond("syarat", errors.New("syarat belum terpenuhi")),
+		precond("akses", forbiddenPrecondition("bukan milik Anda")),
+	}}
+
+	forbidden, reasons := checkPreconditions(nil, transition, &structs.PendaftaranNikah{}, TransitionActor{})
+	if forbidden == nil || forbidden.Message != "bukan milik Anda" {
+		t.Errorf("forbidden = %v, want precondition akses", forbidden)
+	}
+	if len(reasons) != 2 {
+		t.Errorf("reasons = %v, want 2 alasan", reasons)
+	}
+	for name, n := range calls {
+		if n != 1 {
+			t.Errorf("precondition %s dijalankan %d kali, want 1", name, n)
+		}
+	}
+}
+
+func TestStatusTransitionApply(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	p := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Status_pendaftaran: structs.StatusPendaftaranDraft,
+	})
+	pemilik := TransitionActor{UserID: p.Pendaftar_id, Role: structs.UserRoleUserBiasa}
+	staff := TransitionActor{UserID: "STF1", Role: structs.UserRoleStaff, KuaID: kua.ID}
+	ts := NewStatusTransitionService(db)
+
+	basi := p
+	if _, err := ts.Apply(&p, structs.StatusPendaftaranMenungguVerifikasi, pemilik, ""); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	var saved structs.PendaftaranNikah
+	db.First(&saved, p.ID)
+	if saved.Status_pendaftaran != structs.StatusPendaftaranMenungguVerifikasi {
+		t.Errorf("status tersimpan = %q, want %q", saved.Status_pendaftaran, structs.StatusPendaftaranMenungguVerifikasi)
+	}
+	riwayat, err := ts.GetStatusHistory(p.ID)
+	if err != nil || len(riwayat) != 1 || riwayat[0].Aksi != "ajukan" || riwayat[0].Status_dari != structs.StatusPendaftaranDraft {
+		t.Errorf("riwayat = %+v, %v, want satu baris ajukan dari Draft", riwayat, err)
+	}
+
+	// Data yang dibaca sebelum transisi lain tersimpan ditolak sebagai konflik
+	_, err = ts.Apply(&basi, structs.StatusPendaftaranDibatalkan, staff, "")
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) || transitionErr.Type != TransitionErrorConflict {
+		t.Errorf("Apply(status basi) error = %v, want TransitionError conflict", err)
+	}
+	db.First(&saved, p.ID)
+	if saved.Status_pendaftaran != structs.StatusPendaftaranMenungguVerifikasi {
+		t.Errorf("status setelah konflik = %q, want tidak berubah", saved.Status_pendaftaran)
+	}
+}
+
+func TestStatusTransitionApplyKeepsConcurrentReschedule(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	penghulu := createTestPenghulu(t, db, "PGH1", kua.ID)
+	tanggalLama := time.Date(2030, 3, 4, 0, 0, 0, 0, time.UTC)
+	p := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Status_pendaftaran: structs.StatusPendaftaranMenungguPenugasan,
+		Tanggal_nikah:      tanggalLama,
+		Waktu_nikah:        "09:00",
+		Alamat_akad:        "Jl. Lama",
+	})
+
+	// Salinan dibaca sebelum jadwal diubah tanpa mengubah status
+	basi := p
+	tanggalBaru := tanggalLama.AddDate(0, 0, 7)
+	db.Model(&structs.PendaftaranNikah{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
+		"tanggal_nikah": tanggalBaru,
+		"waktu_nikah":   "13:00",
+		"alamat_akad":   "Jl. Baru",
+	})
+
+	now := time.Now()
+	basi.Penghulu_id = &penghulu.ID
+	basi.Penghulu_assigned_by = "KEPALA1"
+	basi.Penghulu_assigned_at = &now
+	kepala := TransitionActor{UserID: "KEPALA1", Role: structs.UserRoleKepalaKUA, KuaID: kua.ID}
+	if _, err := NewStatusTransitionService(db).Apply(&basi, structs.StatusPendaftaranMenungguVerifikasiPenghulu, kepala, "Ditugaskan"); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	var saved structs.PendaftaranNikah
+	db.First(&saved, p.ID)
+	if !saved.Tanggal_nikah.Equal(tanggalBaru) || saved.Waktu_nikah != "13:00" || saved.Alamat_akad != "Jl. Baru" {
+		t.Errorf("jadwal tersimpan = %s %s %q, want jadwal baru tidak ditimpa", saved.Tanggal_nikah, saved.Waktu_nikah, saved.Alamat_akad)
+	}
+	if saved.Penghulu_id == nil || *saved.Penghulu_id != penghulu.ID || saved.Penghulu_assigned_by != "KEPALA1" {
+		t.Errorf("penghulu tersimpan = %v oleh %q, want penugasan baru tersimpan", saved.Penghulu_id, saved.Penghulu_assigned_by)
+	}
+	if saved.Status_pendaftaran != structs.StatusPendaftaranMenungguVerifikasiPenghulu || saved.Catatan != "Ditugaskan" {
+		t.Errorf("status = %q catatan = %q, want transisi tersimpan", saved.Status_pendaftaran, saved.Catatan)
+	}
+	if basi.Waktu_nikah != "13:00" {
+		t.Errorf("p setelah Apply waktu = %q, want dimuat ulang dari database", basi.Waktu_nikah)
+	}
+}
+
+func TestStatusTransitionTerimaBerkasButuhChecklistLengkap(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	p := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Status_pendaftaran: structs.StatusPendaftaranMenungguPengumpulanBerkas,
+	})
+	db.Create(&structs.WaliNikah{Pendaftaran_id: p.ID, NIK: "6371010101700001", Nama_lengkap: "Abdul Hadi", Hubungan_wali: structs.WaliHubunganKakek, Alamat: "Banjarmasin", Agama: "Islam"})
+	db.Create(&structs.DataOrangTua{User_id: p.Pendaftar_id, Jenis_kelamin_calon: "P", Hubungan: structs.HubunganAyah, Nama_lengkap: "Rahman", Status_keberadaan: structs.StatusKeberadaanMeninggal, Jenis_kelamin: "L"})
+	staff := TransitionActor{UserID: "STF1", Role: structs.UserRoleStaff, KuaID: kua.ID}
+	ts := NewStatusTransitionService(db)
+
+	_, err := ts.Apply(&p, structs.StatusPendaftaranBerkasDiterima, staff, "")
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) || transitionErr.Type != TransitionErrorPrecondition {
+		t.Fatalf("Apply(berkas belum lengkap) error = %v, want TransitionError precondition", err)
+	}
+	for _, label := range []string{"Akta Kematian Ayah (Calon Istri)", "KTP Wali (Calon Istri)"} {
+		if !strings.Contains(err.Error(), label) {
+			t.Errorf("Apply() error = %v, want menyebut %s", err, label)
+		}
+	}
+
+	items, err := NewDokumenChecklistService(db).Build(&p)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	for _, item := range items {
+		db.Create(&structs.KelengkapanBerkas{Pendaftaran_id: p.ID, Jenis: item.Jenis, Pihak: item.Pihak, Ada: true, Ditandai_oleh: staff.UserID})
+	}
+	if _, err := ts.Apply(&p, structs.StatusPendaftaranBerkasDiterima, staff, ""); err != nil {
+		t.Fatalf("Apply(berkas lengkap) error = %v", err)
+	}
+	var saved structs.PendaftaranNikah
+	db.First(&saved, p.ID)
+	if saved.Status_pendaftaran != structs.StatusPendaftaranBerkasDiterima {
+		t.Errorf("status tersimpan = %q, want %q", saved.Status_pendaftaran, structs.StatusPendaftaranBerkasDiterima)
+	}
+}
+
+func TestStatusFlowProgress(t *testing.T) {
+	riwayat := func(pairs ...string) []structs.RiwayatStatus {
+		rows := make([]structs.RiwayatStatus, 0, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			rows = append(rows, structs.RiwayatStatus{ID: uint(i/2 + 1), Status_dari: pairs[i], Status_ke: pairs[i+1]})
+		}
+		return rows
+	}
+	const (
+		draft      = structs.StatusPendaftaranDraft
+		verifikasi = structs.StatusPendaftaranMenungguVerifikasi
+		berkas     = structs.StatusPendaftaranMenungguPengumpulanBerkas
+		diterima   = structs.StatusPendaftaranBerkasDiterima
+		penugasan  = structs.StatusPendaftaranMenungguPenugasan
+		ditolak    = structs.StatusPendaftaranDitolak
+		selesai    = structs.StatusPendaftaranSelesai
+	)
+
+	tests := []struct {
+		name          string
+		riwayat       []structs.RiwayatStatus
+		current       string
+		wantReached   []string
+		wantCompleted []string
+	}{
+		{"baru diajukan", riwayat("", draft, draft, verifikasi), verifikasi, []string{verifikasi}, nil},
+		{"berkas diterima", riwayat("", verifikasi, verifikasi, berkas, berkas, diterima), diterima,
+			[]string{verifikasi, berkas, diterima}, []string{verifikasi, berkas}},
+		// Ditolak saat verifikasi berkas: langkah tempat berhenti tidak selesai
+		{"ditolak", riwayat("", verifikasi, verifikasi, berkas, berkas, ditolak), ditolak,
+			[]string{verifikasi, berkas}, []string{verifikasi}},
+		// Diajukan ulang setelah ditolak: langkah sesudah verifikasi dari alur lama tidak dihitung
+		{"diajukan ulang", riwayat("", verifikasi, verifikasi, berkas, berkas, ditolak, ditolak, verifikasi), verifikasi,
+			[]string{verifikasi}, nil},
+		// Baris tanpa perpindahan status (mis. ganti penghulu) diabaikan
+		{"catatan tanpa perpindahan", riwayat("", verifikasi, verifikasi, verifikasi), verifikasi, []string{verifikasi}, nil},
+		{"selesai", riwayat("", penugasan, penugasan, selesai), selesai, []string{penugasan, selesai}, []string{penugasan, selesai}},
+		{"tanpa riwayat", nil, diterima, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached, completed := StatusFlowProgress(tt.riwayat, tt.current)
+			if len(reached) != len(tt.wantReached) {
+				t.Errorf("reached = %v, want %v", reached, tt.wantReached)
+			}
+			for _, status := range tt.wantReached {
+				if _, ok := reached[status]; !ok {
+					t.Errorf("reached tidak memuat %s", status)
+				}
+			}
+			if len(completed) != len(tt.wantCompleted) {
+				t.Errorf("completed = %v, want %v", completed, tt.wantCompleted)
+			}
+			for _, status := range tt.wantCompleted {
+				if !completed[status] {
+					t.Errorf("completed[%s] = false, want true", status)
+				}
+			}
+		})
+	}
+
+	// Riwayat terakhir yang mencapai langkah dipakai untuk tanggal dan actor
+	reached, _ := StatusFlowProgress(riwayat("", verifikasi, verifikasi, ditolak, ditolak, verifikasi), verifikasi)
+	if reached[verifikasi].ID != 3 {
+		t.Errorf("reached[%s] = riwayat %d, want 3", verifikasi, reached[verifikasi].ID)
+	}
+}
+
+func TestValidateCatatan(t *testing.T) {
+	if err := ValidateCatatan(strings.Repeat("é", CatatanMaxLength)); err != nil {
+		t.Errorf("ValidateCatatan(%d karakter) error = %v", CatatanMaxLength, err)
+	}
+	if err := ValidateCatatan(strings.Repeat("a", CatatanMaxLength+1)); !errors.Is(err, ErrCatatanTooLong) {
+		t.Errorf("ValidateCatatan(%d karakter) error = %v, want ErrCatatanTooLong", CatatanMaxLength+1, err)
+	}
+}
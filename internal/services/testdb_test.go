@@ -0,0 +1,141 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB membuat database SQLite sementara dengan semua tabel aplikasi.
+// SQLite mengabaikan klausa FOR UPDATE, sehingga test hanya memeriksa logika di dalam transaksi.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "simnikah.db") + "?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=off"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("gorm.Open() error = %v", err)
+	}
+	if err := db.AutoMigrate(&structs.Users{}, &structs.StaffKUA{}, &structs.Penghulu{}, &structs.DataOrangTua{}, &structs.CalonPasangan{}, &structs.PendaftaranNikah{}, &structs.WaliNikah{}, &structs.BimbinganPerkawinan{}, &structs.PendaftaranBimbingan{}, &structs.Notifikasi{}, &structs.RiwayatStatus{}, &structs.UndanganStaff{}, &structs.SesiPengguna{}, &structs.TokenDicabut{}, &structs.KodeResetPassword{}, &structs.LoginAudit{}, &structs.MfaPengguna{}, &structs.KodePemulihanMfa{}, &structs.PengaturanSistem{}, &structs.IzinRole{}, &structs.KUA{}, &structs.SlotNikah{}, &structs.HariLibur{}, &structs.KetidaksediaanPenghulu{}, &structs.PerubahanJadwal{}, &structs.DaftarTunggu{}, &structs.KalenderFeed{}, &structs.DokumenPendaftaran{}, &structs.KelengkapanBerkas{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// createTestKUA menyimpan KUA aktif dengan aturan penjadwalan default
+func createTestKUA(t *testing.T, db *gorm.DB, kode, kecamatan, kabupaten, provinsi string) structs.KUA {
+	t.Helper()
+
+	now := time.Now()
+	kua := structs.KUA{
+		Kode:       kode,
+		Nama:       "KUA Kecamatan " + kecamatan,
+		Kecamatan:  kecamatan,
+		Kabupaten:  kabupaten,
+		Provinsi:   provinsi,
+		Alamat:     "Jl. " + kecamatan,
+		Status:     structs.KUAStatusAktif,
+		Created_at: now,
+		Updated_at: now,
+	}
+	DefaultSchedulingRules().ApplyTo(&kua)
+	if err := db.Create(&kua).Error; err != nil {
+		t.Fatalf("create KUA %s: %v", kode, err)
+	}
+	return kua
+}
+
+// createTestUser menyimpan user aktif dengan role dan KUA tertentu
+func createTestUser(t *testing.T, db *gorm.DB, userID, role string, kuaID uint) structs.Users {
+	t.Helper()
+
+	now := time.Now()
+	user := structs.Users{
+		User_id:    userID,
+		Username:   userID,
+		Email:      userID + "@kua.go.id",
+		Password:   "-",
+		Role:       role,
+		Status:     structs.UserStatusAktif,
+		Nama:       "User " + userID,
+		Kua_id:     kuaID,
+		Created_at: now,
+		Updated_at: now,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", userID, err)
+	}
+	return user
+}
+
+// createTestPenghulu menyimpan user penghulu beserta profil penghulu aktif di sebuah KUA
+func createTestPenghulu(t *testing.T, db *gorm.DB, userID string, kuaID uint) structs.Penghulu {
+	t.Helper()
+
+	createTestUser(t, db, userID, structs.UserRolePenghulu, kuaID)
+	now := time.Now()
+	penghulu := structs.Penghulu{
+		User_id:      userID,
+		NIP:          "NIP" + userID,
+		Nama_lengkap: "Penghulu " + userID,
+		Status:       structs.PenghuluStatusAktif,
+		Kua_id:       kuaID,
+		Created_at:   now,
+		Updated_at:   now,
+	}
+	if err := db.Create(&penghulu).Error; err != nil {
+		t.Fatalf("create penghulu %s: %v", userID, err)
+	}
+	return penghulu
+}
+
+// createTestPendaftaran menyimpan pendaftaran nikah; field wajib yang kosong diisi nilai contoh
+func createTestPendaftaran(t *testing.T, db *gorm.DB, p structs.PendaftaranNikah) structs.PendaftaranNikah {
+	t.Helper()
+
+	var count int64
+	db.Model(&structs.PendaftaranNikah{}).Count(&count)
+	if p.Nomor_pendaftaran == "" {
+		p.Nomor_pendaftaran = fmt.Sprintf("NIK-TEST-%03d", count+1)
+	}
+	if p.Pendaftar_id == "" {
+		p.Pendaftar_id = "CATIN1"
+	}
+	if p.Calon_suami_id == "" {
+		p.Calon_suami_id = p.Pendaftar_id
+	}
+	if p.Calon_istri_id == "" {
+		p.Calon_istri_id = "CATIN2"
+	}
+	if p.Tanggal_pendaftaran.IsZero() {
+		p.Tanggal_pendaftaran = time.Now()
+	}
+	if p.Tanggal_nikah.IsZero() {
+		p.Tanggal_nikah = time.Now().AddDate(0, 1, 0).Truncate(24 * time.Hour)
+	}
+	if p.Waktu_nikah == "" {
+		p.Waktu_nikah = "09:00"
+	}
+	if p.Tempat_nikah == "" {
+		p.Tempat_nikah = "Di KUA"
+	}
+	if p.Status_pendaftaran == "" {
+		p.Status_pendaftaran = structs.StatusPendaftaranMenungguVerifikasi
+	}
+	if err := db.Create(&p).Error; err != nil {
+		t.Fatalf("create pendaftaran: %v", err)
+	}
+	return p
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/routing"
+)
+
+// NewRoutingProvider membuat provider rute dengan kecepatan rata-rata KUA.
+// Default dari environment (OSRM jika ROUTING_OSRM_URL diset, selain itu perkiraan lokal);
+// bisa diganti untuk memakai provider rute lain.
+var NewRoutingProvider = routing.NewFromEnv
+
+// RouteCacheTTL adalah lama rute dari provider disimpan sebelum diminta ulang
+const RouteCacheTTL = 24 * time.Hour
+
+// routeCacheMax membatasi jumlah rute yang disimpan; cache dikosongkan jika penuh
+const routeCacheMax = 10000
+
+// RouteCache menyimpan rute dari provider per pasangan koordinat (berarah) agar server rute tidak
+// dihubungi ulang untuk perjalanan yang sama
+type RouteCache struct {
+	mu   sync.Mutex
+	rute map[[2]routing.Point]cachedRoute
+}
+
+type cachedRoute struct {
+	route   routing.Route
+	berlaku time.Time
+}
+
+// sharedRouteCache dipakai bersama oleh semua TravelEstimator dari NewTravelEstimator
+var sharedRouteCache = &RouteCache{}
+
+// Get mengambil rute yang masih berlaku
+func (rc *RouteCache) Get(from, to routing.Point) (routing.Route, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	c, ok := rc.rute[[2]routing.Point{from, to}]
+	if !ok || time.Now().After(c.berlaku) {
+		return routing.Route{}, false
+	}
+	return c.route, true
+}
+
+// Put menyimpan rute selama RouteCacheTTL
+func (rc *RouteCache) Put(from, to routing.Point, route routing.Route) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.rute == nil || len(rc.rute) >= routeCacheMax {
+		rc.rute = make(map[[2]routing.Point]cachedRoute)
+	}
+	rc.rute[[2]routing.Point{from, to}] = cachedRoute{route: route, berlaku: time.Now().Add(RouteCacheTTL)}
+}
+
+// TravelEstimator memperkirakan perjalanan penghulu antar lokasi akad di satu KUA
+type TravelEstimator struct {
+	Provider routing.Provider
+	Lokal    routing.Local // Dipakai jika provider gagal
+	Cache    *RouteCache   // Rute dari provider yang sudah pernah diminta (nil = tanpa cache)
+	// Offline tidak menghubungi provider: hanya rute dari Cache, selain itu perkiraan lokal.
+	// Dipakai di dalam transaction yang memegang kunci baris agar tidak menunggu server rute.
+	Offline bool
+}
+
+// NewTravelEstimator membuat TravelEstimator dengan kecepatan rata-rata dari aturan penjadwalan KUA
+func NewTravelEstimator(rules SchedulingRules) *TravelEstimator {
+	kecepatan := float64(rules.KecepatanTempuhKmJam)
+	return &TravelEstimator{
+		Provider: NewRoutingProvider(kecepatan),
+		Lokal:    routing.Local{KecepatanKmJam: kecepatan},
+		Cache:    sharedRouteCache,
+	}
+}
+
+// Estimate memperkirakan jarak dan waktu tempuh; jika provider gagal (mis. timeout) atau estimator
+// Offline tanpa rute di cache, dipakai perkiraan lokal
+func (te *TravelEstimator) Estimate(from, to routing.Point) routing.Route {
+	if te.Cache != nil {
+		if route, ok := te.Cache.Get(from, to); ok {
+			return route
+		}
+	}
+	if te.Offline {
+		route, _ := te.Lokal.Route(from, to)
+		return route
+	}
+
+	route, err := te.Provider.Route(from, to)
+	if err != nil {
+		log.Printf("Warning: %v, memakai perkiraan jarak lokal", err)
+		route, _ = te.Lokal.Route(from, to)
+		return route
+	}
+	if te.Cache != nil && route.Sumber != "lokal" {
+		te.Cache.Put(from, to, route)
+	}
+	return route
+}
+
+// TravelLeg adalah perjalanan penghulu dari satu akad ke akad berikutnya di hari yang sama
+type TravelLeg struct {
+	DariPendaftaranID uint    `json:"dari_pendaftaran_id"`
+	DariWaktu         string  `json:"dari_waktu"`
+	KePendaftaranID   uint    `json:"ke_pendaftaran_id"`
+	KeWaktu           string  `json:"ke_waktu"`
+	JarakKm           float64 `json:"jarak_km"`
+	WaktuTempuhMenit  int     `json:"waktu_tempuh_menit"`
+	SelisihMenit      int     `json:"selisih_menit"`
+	DibutuhkanMenit   int     `json:"dibutuhkan_menit"` // Jeda minimal (lama akad) + waktu tempuh
+	Layak             bool    `json:"layak"`
+	Sumber            string  `json:"sumber"`
+}
+
+// PlanTravel menyusun perjalanan antar akad berurutan (urut waktu nikah) dari jadwal satu penghulu.
+// Perjalanan tidak layak jika selisih waktu mulai kurang dari jeda minimal ditambah waktu tempuh.
+// Akad tanpa koordinat atau dengan waktu tidak valid dilewati.
+func (te *TravelEstimator) PlanTravel(rules SchedulingRules, kua *structs.KUA, jadwal []structs.PendaftaranNikah) []TravelLeg {
+	urut := make([]structs.PendaftaranNikah, len(jadwal))
+	copy(urut, jadwal)
+	sort.SliceStable(urut, func(i, j int) bool { return urut[i].Waktu_nikah < urut[j].Waktu_nikah })
+
+	legs := make([]TravelLeg, 0)
+	for i := 0; i+1 < len(urut); i++ {
+		dari, ke := &urut[i], &urut[i+1]
+		asal, ok1 := ceremonyLocation(dari, kua)
+		tujuan, ok2 := ceremonyLocation(ke, kua)
+		selisih, ok3 := minutesBetween(dari.Waktu_nikah, ke.Waktu_nikah)
+		if !ok1 || !ok2 || !ok3 {
+			continue
+		}
+
+		route := te.Estimate(asal, tujuan)
+		dibutuhkan := rules.JedaMinimalMenit + route.DurasiMenit
+		legs = append(legs, TravelLeg{
+			DariPendaftaranID: dari.ID,
+			DariWaktu:         dari.Waktu_nikah,
+			KePendaftaranID:   ke.ID,
+			KeWaktu:           ke.Waktu_nikah,
+			JarakKm:           route.JarakKm,
+			WaktuTempuhMenit:  route.DurasiMenit,
+			SelisihMenit:      selisih,
+			DibutuhkanMenit:   dibutuhkan,
+			Layak:             selisih >= dibutuhkan,
+			Sumber:            route.Sumber,
+		})
+	}
+	return legs
+}
+
+// TravelConflict memeriksa apakah penghulu sempat berpindah dari/ke lokasi akad p jika p ditambahkan
+// ke jadwalnya. Perjalanan tidak layak yang sudah ada sebelumnya (tanpa p) tidak ikut dilaporkan.
+func (te *TravelEstimator) TravelConflict(rules SchedulingRules, kua *structs.KUA, p *structs.PendaftaranNikah, jadwal []structs.PendaftaranNikah) *ScheduleConflict {
+	gabungan := append(append(make([]structs.PendaftaranNikah, 0, len(jadwal)+1), jadwal...), *p)
+	for _, leg := range te.PlanTravel(rules, kua, gabungan) {
+		if leg.Layak || (leg.DariPendaftaranID != p.ID && leg.KePendaftaranID != p.ID) {
+			continue
+		}
+		return &ScheduleConflict{
+			Type: ScheduleConflictWaktuTempuh,
+			Message: fmt.Sprintf("Jeda %d menit antara akad pukul %s dan %s tidak cukup untuk perjalanan %.1f km (±%d menit)",
+				leg.SelisihMenit, leg.DariWaktu, leg.KeWaktu, leg.JarakKm, leg.WaktuTempuhMenit),
+			Details: map[string]interface{}{
+				"perjalanan":      leg,
+				"minimal_selisih": fmt.Sprintf("%d menit", leg.DibutuhkanMenit),
+			},
+		}
+	}
+	return nil
+}
+
+// ceremonyLocation mengembalikan koordinat lokasi akad: balai KUA untuk nikah "Di KUA",
+// koordinat alamat akad untuk nikah di luar KUA
+func ceremonyLocation(p *structs.PendaftaranNikah, kua *structs.KUA) (routing.Point, bool) {
+	if p.Tempat_nikah == "Di KUA" {
+		if kua.Latitude != nil && kua.Longitude != nil {
+			return routing.Point{Lat: *kua.Latitude, Lon: *kua.Longitude}, true
+		}
+		return routing.Point{}, false
+	}
+	if p.Latitude != nil && p.Longitude != nil {
+		return routing.Point{Lat: *p.Latitude, Lon: *p.Longitude}, true
+	}
+	return routing.Point{}, false
+}
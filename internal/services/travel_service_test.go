@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/routing"
+)
+
+// stubRoute memakai 1 km = 2 menit, gagal untuk titik tujuan tertentu
+type stubRoute struct{ gagalLat float64 }
+
+func (s stubRoute) Route(from, to routing.Point) (routing.Route, error) {
+	if to.Lat == s.gagalLat {
+		return routing.Route{}, errors.New("stub gagal")
+	}
+	km := (to.Lat - from.Lat) * 100
+	if km < 0 {
+		km = -km
+	}
+	return routing.Route{JarakKm: km, DurasiMenit: int(km * 2), Sumber: "stub"}, nil
+}
+
+func TestPlanTravel(t *testing.T) {
+	lat := func(v float64) *float64 { return &v }
+	kuaLat, kuaLon := -7.0, 110.0
+	kua := &structs.KUA{Latitude: &kuaLat, Longitude: &kuaLon}
+	rules := DefaultSchedulingRules()
+	te := &TravelEstimator{Provider: stubRoute{gagalLat: -9}, Lokal: routing.Local{KecepatanKmJam: 30}}
+
+	tanggal := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	jadwal := []structs.PendaftaranNikah{
+		{ID: 2, Tanggal_nikah: tanggal, Waktu_nikah: "11:00", Tempat_nikah: "Di Luar KUA", Latitude: lat(-7.2), Longitude: lat(110.0)},
+		{ID: 1, Tanggal_nikah: tanggal, Waktu_nikah: "08:00", Tempat_nikah: "Di KUA"},
+		{ID: 3, Tanggal_nikah: tanggal, Waktu_nikah: "13:00", Tempat_nikah: "Di Luar KUA"},
+	}
+
+	// 08:00 KUA -> 11:00 (20 km, 40 menit): butuh 100 menit, ada 180; akad tanpa koordinat dilewati
+	legs := te.PlanTravel(rules, kua, jadwal)
+	if len(legs) != 1 || legs[0].DariPendaftaranID != 1 || legs[0].KePendaftaranID != 2 {
+		t.Fatalf("PlanTravel() = %+v, want satu perjalanan 1 -> 2", legs)
+	}
+	if !legs[0].Layak || legs[0].DibutuhkanMenit != 100 || legs[0].SelisihMenit != 180 {
+		t.Fatalf("PlanTravel() leg = %+v, want layak, butuh 100 menit, selisih 180", legs[0])
+	}
+
+	// Akad baru 09:30 di lokasi 25 km dari KUA: 08:00 -> 09:30 butuh 60 + 50 menit, hanya ada 90
+	baru := structs.PendaftaranNikah{ID: 9, Tanggal_nikah: tanggal, Waktu_nikah: "09:30", Tempat_nikah: "Di Luar KUA", Latitude: lat(-7.25), Longitude: lat(110.0)}
+	conflict := te.TravelConflict(rules, kua, &baru, jadwal)
+	if conflict == nil || conflict.Type != ScheduleConflictWaktuTempuh {
+		t.Fatalf("TravelConflict() = %v, want konflik waktu_tempuh", conflict)
+	}
+
+	// Cukup waktu jika jeda minimal lebih pendek
+	rules.JedaMinimalMenit = 30
+	if conflict := te.TravelConflict(rules, kua, &baru, jadwal); conflict != nil {
+		t.Fatalf("TravelConflict() jeda 30 = %v, want nil", conflict)
+	}
+
+	// Provider gagal: memakai perkiraan lokal
+	route := te.Estimate(routing.Point{Lat: -9.1, Lon: 110}, routing.Point{Lat: -9, Lon: 110})
+	if route.Sumber != "lokal" {
+		t.Fatalf("Estimate() sumber = %q, want lokal", route.Sumber)
+	}
+}
+
+// countingRoute menghitung permintaan rute ke provider
+type countingRoute struct {
+	stubRoute
+	calls *int
+}
+
+func (c countingRoute) Route(from, to routing.Point) (routing.Route, error) {
+	*c.calls++
+	return c.stubRoute.Route(from, to)
+}
+
+func TestTravelEstimatorCache(t *testing.T) {
+	calls := 0
+	cache := &RouteCache{}
+	te := &TravelEstimator{Provider: countingRoute{calls: &calls}, Lokal: routing.Local{KecepatanKmJam: 30}, Cache: cache}
+	asal, tujuan := routing.Point{Lat: -7, Lon: 110}, routing.Point{Lat: -7.2, Lon: 110}
+
+	if route := te.Estimate(asal, tujuan); route.Sumber != "stub" || calls != 1 {
+		t.Fatalf("Estimate() = %+v (%d permintaan), want dari provider", route, calls)
+	}
+	if route := te.Estimate(asal, tujuan); route.Sumber != "stub" || calls != 1 {
+		t.Errorf("Estimate() kedua = %+v (%d permintaan), want dari cache", route, calls)
+	}
+
+	// Offline tidak menghubungi provider: rute di cache dipakai, selain itu perkiraan lokal
+	offline := &TravelEstimator{Provider: te.Provider, Lokal: te.Lokal, Cache: cache, Offline: true}
+	if route := offline.Estimate(asal, tujuan); route.Sumber != "stub" {
+		t.Errorf("Estimate(offline, ada di cache) sumber = %q, want stub", route.Sumber)
+	}
+	if route := offline.Estimate(tujuan, asal); route.Sumber != "lokal" || calls != 1 {
+		t.Errorf("Estimate(offline, arah sebaliknya) = %+v (%d permintaan), want lokal tanpa provider", route, calls)
+	}
+}
+
+func TestCheckPenghuluAssignmentUsesPrefetchedRoutes(t *testing.T) {
+	calls := 0
+	providerLama, cacheLama := NewRoutingProvider, sharedRouteCache
+	NewRoutingProvider = func(float64) routing.Provider { return countingRoute{calls: &calls} }
+	sharedRouteCache = &RouteCache{}
+	t.Cleanup(func() { NewRoutingProvider, sharedRouteCache = providerLama, cacheLama })
+
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	kuaLat, kuaLon := -7.0, 110.0
+	kua.Latitude, kua.Longitude = &kuaLat, &kuaLon
+	kua.Kecepatan_tempuh_km_jam = 60
+	db.Save(&kua)
+	penghulu := createTestPenghulu(t, db, "PGH1", kua.ID)
+
+	tanggal := time.Now().UTC().AddDate(0, 1, 0).Truncate(24 * time.Hour)
+	createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Tanggal_nikah:      tanggal,
+		Waktu_nikah:        "08:00",
+		Status_pendaftaran: structs.StatusPendaftaranMenungguBimbingan,
+		Penghulu_id:        &penghulu.ID,
+	})
+	akadLat := -7.25
+	p := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:             kua.ID,
+		Pendaftar_id:       "CATIN3",
+		Tanggal_nikah:      tanggal,
+		Waktu_nikah:        "09:30",
+		Tempat_nikah:       "Di Luar KUA",
+		Latitude:           &akadLat,
+		Longitude:          &kuaLon,
+		Status_pendaftaran: structs.StatusPendaftaranMenungguPenugasan,
+	})
+	ss := NewSchedulingRuleService(db)
+
+	// Tanpa rute di cache dipakai perkiraan lokal (±28 menit pada 60 km/jam): 60 + 28 <= 90 menit
+	if _, err := ss.CheckPenghuluAssignment(&p, penghulu.ID); err != nil || calls != 0 {
+		t.Fatalf("CheckPenghuluAssignment() = %v (%d permintaan rute), want lolos tanpa menghubungi provider", err, calls)
+	}
+
+	// Rute dari provider (50 menit) diminta sebelum kunci, lalu dipakai dari cache: 60 + 50 > 90 menit
+	ss.PrefetchTravel(&p, penghulu.ID)
+	if calls == 0 {
+		t.Fatal("PrefetchTravel() tidak meminta rute ke provider")
+	}
+	sebelum := calls
+	_, err := ss.CheckPenghuluAssignment(&p, penghulu.ID)
+	var conflict *ScheduleConflict
+	if !errors.As(err, &conflict) || conflict.Type != ScheduleConflictWaktuTempuh {
+		t.Errorf("CheckPenghuluAssignment() setelah prefetch = %v, want konflik waktu_tempuh", err)
+	}
+	if calls != sebelum {
+		t.Errorf("CheckPenghuluAssignment() meminta %d rute ke provider, want 0", calls-sebelum)
+	}
+}
@@ -0,0 +1,453 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"simnikah/config"
+	structs "simnikah/internal/models"
+	"simnikah/pkg/locale"
+	"simnikah/pkg/pdf"
+	"simnikah/pkg/qrcode"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// ==================== UNDANGAN BIMBINGAN PERKAWINAN ====================
+// Undangan dicetak sebagai PDF A4 (satu halaman per pasangan, digabung untuk semua peserta) atau
+// halaman HTML siap cetak. Setiap undangan memuat QR code ke URL verifikasi bertanda tangan sehingga
+// petugas bisa memastikan undangan asli saat peserta datang.
+
+const (
+	// EnvUndanganVerifyBaseURL adalah URL publik endpoint verifikasi yang dikodekan di QR code undangan
+	EnvUndanganVerifyBaseURL = "UNDANGAN_VERIFY_BASE_URL"
+
+	defaultUndanganVerifyBaseURL = "http://localhost:8080/simnikah/undangan-bimbingan/verifikasi"
+)
+
+var (
+	// ErrUndanganInvalid dikembalikan jika token verifikasi undangan tidak valid atau pesertanya sudah tidak ada
+	ErrUndanganInvalid = errors.New("undangan tidak valid atau sudah tidak berlaku")
+)
+
+// undanganInstruksi adalah ketentuan yang dicetak di setiap undangan
+var undanganInstruksi = []string{
+	"Harap hadir tepat waktu sesuai jadwal yang telah ditentukan",
+	"Membawa dokumen asli untuk verifikasi",
+	"Menggunakan pakaian yang sopan dan rapi",
+	"Mengikuti seluruh rangkaian bimbingan dengan baik",
+	"Sertifikat bimbingan akan diberikan setelah selesai mengikuti bimbingan",
+}
+
+// UndanganKop adalah kop surat undangan
+type UndanganKop struct {
+	Instansi  string // mis. KEMENTERIAN AGAMA REPUBLIK INDONESIA
+	Kantor    string // mis. KANTOR KEMENTERIAN AGAMA KOTA BANDUNG
+	NamaKUA   string
+	Alamat    string
+	Kontak    string // telepon dan email KUA
+	Kecamatan string
+	Kepala    string // nama kepala KUA untuk tanda tangan
+	NIPKepala string
+}
+
+// UndanganBimbingan adalah isi satu undangan; semua nilai sudah diformat sebagai teks
+type UndanganBimbingan struct {
+	PesertaID        uint   `json:"pendaftaran_bimbingan_id"`
+	NomorPendaftaran string `json:"nomor_pendaftaran"`
+	Hari             string `json:"hari"`
+	Tanggal          string `json:"tanggal"`
+	Waktu            string `json:"waktu"`
+	Tempat           string `json:"tempat"`
+	Pembimbing       string `json:"pembimbing"`
+	CalonSuami       string `json:"calon_suami"`
+	CalonIstri       string `json:"calon_istri"`
+	TanggalNikah     string `json:"tanggal_nikah"`
+	StatusKehadiran  string `json:"status_kehadiran"`
+	NoSertifikat     string `json:"nomor_sertifikat,omitempty"`
+	VerifikasiURL    string `json:"verifikasi_url"`
+}
+
+// UndanganClaims adalah isi token verifikasi undangan yang ditandatangani (HS256); token tidak kedaluwarsa
+type UndanganClaims struct {
+	PesertaID uint `json:"pid"`
+	jwt.RegisteredClaims
+}
+
+// UndanganBimbinganService menyusun, mencetak, dan memverifikasi undangan bimbingan perkawinan
+type UndanganBimbinganService struct {
+	DB         *gorm.DB
+	Now        func() time.Time
+	signingKey []byte
+	baseURL    string
+}
+
+// NewUndanganBimbinganService membuat instance baru dari UndanganBimbinganService.
+// Kunci tanda tangan diambil dari UNDANGAN_SIGNING_KEY (fallback ke JWT_KEY).
+// URL verifikasi dari UNDANGAN_VERIFY_BASE_URL; default localhost hanya untuk mode pengembangan
+// (lihat CheckUndanganVerifyBaseURL).
+func NewUndanganBimbinganService(db *gorm.DB) *UndanganBimbinganService {
+	baseURL := os.Getenv(EnvUndanganVerifyBaseURL)
+	if baseURL == "" {
+		baseURL = defaultUndanganVerifyBaseURL
+	}
+
+	return &UndanganBimbinganService{DB: db, Now: time.Now, signingKey: SecretKey(EnvUndanganSigningKey), baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// CheckUndanganVerifyBaseURL memastikan URL verifikasi QR code undangan bisa dibuka peserta. Dipanggil
+// saat startup: di mode pengembangan URL kosong hanya diberi peringatan dan memakai localhost, di luar
+// mode pengembangan URL wajib diatur, absolut (http/https), dan tidak menunjuk ke localhost.
+func CheckUndanganVerifyBaseURL() error {
+	raw := strings.TrimSpace(os.Getenv(EnvUndanganVerifyBaseURL))
+	if raw == "" {
+		if config.IsDevEnvironment() {
+			log.Printf("Warning: %s not set, QR code undangan bimbingan memakai %s", EnvUndanganVerifyBaseURL, defaultUndanganVerifyBaseURL)
+			return nil
+		}
+		return fmt.Errorf("%s belum diatur", EnvUndanganVerifyBaseURL)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s harus URL absolut http/https, mis. https://simnikah.example.go.id/simnikah/undangan-bimbingan/verifikasi", EnvUndanganVerifyBaseURL)
+	}
+	if !config.IsDevEnvironment() {
+		switch u.Hostname() {
+		case "localhost", "127.0.0.1", "::1":
+			return fmt.Errorf("%s menunjuk ke %s, QR code tidak bisa dibuka peserta", EnvUndanganVerifyBaseURL, u.Hostname())
+		}
+	}
+	return nil
+}
+
+// VerifikasiURL mengembalikan URL verifikasi bertanda tangan untuk satu peserta bimbingan
+func (us *UndanganBimbinganService) VerifikasiURL(pesertaID uint) (string, error) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, UndanganClaims{PesertaID: pesertaID}).SignedString(us.signingKey)
+	if err != nil {
+		return "", fmt.Errorf("gagal menandatangani token undangan: %v", err)
+	}
+	return us.baseURL + "/" + token, nil
+}
+
+// Verify memvalidasi token dari QR code dan mengembalikan undangan peserta beserta kop KUA-nya
+func (us *UndanganBimbinganService) Verify(token string) (*UndanganBimbingan, *UndanganKop, error) {
+	claims := &UndanganClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("metode signing token tidak valid")
+		}
+		return us.signingKey, nil
+	})
+	if err != nil || !parsed.Valid || claims.PesertaID == 0 {
+		return nil, nil, ErrUndanganInvalid
+	}
+
+	var peserta structs.PendaftaranBimbingan
+	if err := us.DB.First(&peserta, claims.PesertaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrUndanganInvalid
+		}
+		return nil, nil, err
+	}
+	var bimbingan structs.BimbinganPerkawinan
+	if err := us.DB.First(&bimbingan, peserta.Bimbingan_perkawinan_id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrUndanganInvalid
+		}
+		return nil, nil, err
+	}
+
+	undangan, err := us.Undangan(&bimbingan, []structs.PendaftaranBimbingan{peserta})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(undangan) == 0 {
+		return nil, nil, ErrUndanganInvalid
+	}
+	kop, err := us.Kop(bimbingan.Kua_id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &undangan[0], kop, nil
+}
+
+// TanggalCetak mengembalikan tanggal hari ini menurut zona waktu KUA untuk dicetak di undangan
+func (us *UndanganBimbinganService) TanggalCetak(kuaID uint) string {
+	return locale.Tanggal(us.Now().In(NewKUAService(us.DB).ZonaWaktu(kuaID)))
+}
+
+// PesertaMilik mengembalikan peserta bimbingan dari pendaftaran nikah milik user
+func (us *UndanganBimbinganService) PesertaMilik(bimbinganID uint, userID string) (*structs.PendaftaranBimbingan, error) {
+	var peserta structs.PendaftaranBimbingan
+	err := us.DB.Where("bimbingan_perkawinan_id = ? AND pendaftaran_nikah_id IN (?)", bimbinganID,
+		us.DB.Model(&structs.PendaftaranNikah{}).Select("id").Where("pendaftar_id = ?", userID)).
+		First(&peserta).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrResourceNotFound
+		}
+		return nil, err
+	}
+	return &peserta, nil
+}
+
+// Kop menyusun kop surat dari data KUA dan konfigurasi UNDANGAN_KOP_INSTANSI / UNDANGAN_KOP_KANTOR.
+// Tanpa konfigurasi, baris kantor diturunkan dari kabupaten KUA.
+func (us *UndanganBimbinganService) Kop(kuaID uint) (*UndanganKop, error) {
+	var kua structs.KUA
+	if err := us.DB.First(&kua, kuaID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	kop := &UndanganKop{
+		Instansi:  os.Getenv("UNDANGAN_KOP_INSTANSI"),
+		Kantor:    os.Getenv("UNDANGAN_KOP_KANTOR"),
+		NamaKUA:   kua.Nama,
+		Alamat:    kua.Alamat,
+		Kecamatan: kua.Kecamatan,
+	}
+	if kop.Instansi == "" {
+		kop.Instansi = "KEMENTERIAN AGAMA REPUBLIK INDONESIA"
+	}
+	if kop.Kantor == "" && kua.Kabupaten != "" {
+		kop.Kantor = "KANTOR KEMENTERIAN AGAMA " + strings.ToUpper(kua.Kabupaten)
+	}
+	if kop.NamaKUA == "" {
+		kop.NamaKUA = "Kantor Urusan Agama"
+	}
+	var kontak []string
+	if kua.No_telepon != "" {
+		kontak = append(kontak, "Telepon "+kua.No_telepon)
+	}
+	if kua.Email != "" {
+		kontak = append(kontak, "Email "+kua.Email)
+	}
+	kop.Kontak = strings.Join(kontak, " | ")
+
+	var kepala structs.StaffKUA
+	err := us.DB.Where("kua_id = ? AND jabatan = ? AND status = ?", kuaID, structs.StaffJabatanKepalaKUA, structs.StaffStatusAktif).
+		Order("id").First(&kepala).Error
+	if err == nil {
+		kop.Kepala, kop.NIPKepala = kepala.Nama_lengkap, kepala.NIP
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return kop, nil
+}
+
+// Undangan menyusun isi undangan untuk peserta bimbingan; peserta tanpa data pendaftaran dilewati
+func (us *UndanganBimbinganService) Undangan(bimbingan *structs.BimbinganPerkawinan, peserta []structs.PendaftaranBimbingan) ([]UndanganBimbingan, error) {
+	zona := NewKUAService(us.DB).ZonaWaktu(bimbingan.Kua_id)
+	result := make([]UndanganBimbingan, 0, len(peserta))
+	for _, p := range peserta {
+		var pendaftaran structs.PendaftaranNikah
+		if err := us.DB.First(&pendaftaran, p.Pendaftaran_nikah_id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return nil, err
+		}
+
+		var calonSuami, calonIstri structs.CalonPasangan
+		us.DB.First(&calonSuami, p.Calon_suami_id)
+		us.DB.First(&calonIstri, p.Calon_istri_id)
+
+		verifikasiURL, err := us.VerifikasiURL(p.ID)
+		if err != nil {
+			return nil, err
+		}
+		u := UndanganBimbingan{
+			PesertaID:        p.ID,
+			NomorPendaftaran: pendaftaran.Nomor_pendaftaran,
+			Hari:             locale.Hari(bimbingan.Tanggal_bimbingan.Weekday()),
+			Tanggal:          locale.Tanggal(bimbingan.Tanggal_bimbingan),
+			Waktu:            locale.RentangJam(bimbingan.Waktu_mulai, bimbingan.Waktu_selesai, zona),
+			Tempat:           bimbingan.Tempat_bimbingan,
+			Pembimbing:       bimbingan.Pembimbing,
+			CalonSuami:       calonSuami.Nama_lengkap,
+			CalonIstri:       calonIstri.Nama_lengkap,
+			TanggalNikah:     locale.HariTanggal(pendaftaran.Tanggal_nikah),
+			StatusKehadiran:  p.Status_kehadiran,
+			NoSertifikat:     p.No_sertifikat,
+			VerifikasiURL:    verifikasiURL,
+		}
+		if !pendaftaran.Tanggal_nikah.IsZero() && pendaftaran.Waktu_nikah != "" {
+			u.TanggalNikah += " pukul " + locale.FormatJam(pendaftaran.Waktu_nikah, zona)
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+// RenderUndanganPDF mencetak undangan sebagai PDF A4, satu halaman per undangan dalam satu dokumen
+func RenderUndanganPDF(kop *UndanganKop, undangan []UndanganBimbingan, tanggalCetak string) ([]byte, error) {
+	doc := pdf.New()
+	doc.Title = "Undangan Bimbingan Perkawinan"
+	doc.Author = kop.NamaKUA
+	for _, u := range undangan {
+		page := pdf.New()
+		if err := renderUndanganPage(page, kop, u, tanggalCetak); err != nil {
+			return nil, err
+		}
+		doc.Append(page)
+	}
+	if doc.Pages() == 0 {
+		doc.AddPage()
+	}
+	return doc.Bytes(), nil
+}
+
+func renderUndanganPage(doc *pdf.Document, kop *UndanganKop, u UndanganBimbingan, tanggalCetak string) error {
+	qr, err := qrcode.Encode(u.VerifikasiURL)
+	if err != nil {
+		return err
+	}
+
+	l := pdf.NewLayout(doc)
+	l.Top = 15
+	l.Y = l.Top
+
+	// Kop surat
+	for _, line := range []string{kop.Instansi, kop.Kantor} {
+		if line != "" {
+			l.Paragraph(pdf.HelveticaBold, 12, line, pdf.AlignCenter)
+		}
+	}
+	l.Paragraph(pdf.HelveticaBold, 14, strings.ToUpper(kop.NamaKUA), pdf.AlignCenter)
+	for _, line := range []string{kop.Alamat, kop.Kontak} {
+		if line != "" {
+			l.Paragraph(pdf.Helvetica, 9, line, pdf.AlignCenter)
+		}
+	}
+	l.Rule(0.8)
+	l.Page.Line(l.Left, l.Y-0.2, l.Left+l.Width(), l.Y-0.2, 0.25)
+	l.Space(4)
+
+	size := 11.0
+	tempatTanggal := tanggalCetak
+	if kop.Kecamatan != "" {
+		tempatTanggal = kop.Kecamatan + ", " + tanggalCetak
+	}
+	l.Paragraph(pdf.Helvetica, size, tempatTanggal, pdf.AlignRight)
+	l.Field("Nomor pendaftaran", u.NomorPendaftaran, 0, 38, size)
+	l.Field("Perihal", "Undangan Bimbingan Perkawinan", 0, 38, size)
+	l.Space(4)
+	l.Paragraph(pdf.Helvetica, size, "Kepada Yth.", pdf.AlignLeft)
+	l.Paragraph(pdf.HelveticaBold, size, "Sdr. "+dashIfEmpty(u.CalonSuami)+" dan Sdri. "+dashIfEmpty(u.CalonIstri), pdf.AlignLeft)
+	l.Paragraph(pdf.Helvetica, size, "di tempat", pdf.AlignLeft)
+	l.Space(4)
+	l.Paragraph(pdf.Helvetica, size, "Assalamu'alaikum warahmatullahi wabarakatuh.", pdf.AlignLeft)
+	l.Space(1)
+	l.Paragraph(pdf.Helvetica, size, "Dengan hormat, kami mengundang Saudara/Saudari untuk mengikuti Bimbingan Perkawinan sebagai persyaratan sebelum melaksanakan pernikahan, yang insyaallah akan dilaksanakan pada:", pdf.AlignLeft)
+	l.Space(1)
+	l.Field("Hari, tanggal", u.Hari+", "+u.Tanggal, 8, 34, size)
+	l.Field("Waktu", u.Waktu, 8, 34, size)
+	l.Field("Tempat", u.Tempat, 8, 34, size)
+	l.Field("Pembimbing", dashIfEmpty(u.Pembimbing), 8, 34, size)
+	if u.TanggalNikah != "" {
+		l.Field("Rencana akad nikah", u.TanggalNikah, 8, 34, size)
+	}
+	l.Space(3)
+	l.Paragraph(pdf.HelveticaBold, size, "Ketentuan:", pdf.AlignLeft)
+	for i, instruksi := range undanganInstruksi {
+		l.Field(fmt.Sprintf("%d.", i+1), instruksi, 4, 2, size)
+	}
+	l.Space(3)
+	l.Paragraph(pdf.Helvetica, size, "Demikian undangan ini kami sampaikan. Atas perhatian dan kehadirannya kami ucapkan terima kasih.", pdf.AlignLeft)
+	l.Paragraph(pdf.Helvetica, size, "Wassalamu'alaikum warahmatullahi wabarakatuh.", pdf.AlignLeft)
+	l.Space(4)
+
+	// QR code verifikasi di kiri, tanda tangan kepala KUA di kanan
+	const qrSize = 32.0
+	l.Ensure(qrSize + 10)
+	top := l.Y
+	drawQR(l.Page, qr, l.Left, top+2, qrSize)
+	l.Page.Text(l.Left, top+qrSize+6, pdf.Helvetica, 7.5, "Pindai untuk memverifikasi keaslian undangan")
+
+	kepala := kop.Kepala
+	if kepala == "" {
+		kepala = "( .................................... )"
+	}
+	l.Signatures([]pdf.Signature{{}, {Lines: []string{"Hormat kami,", "Kepala " + kop.NamaKUA}, Name: kepala}}, size)
+	if kop.NIPKepala != "" {
+		l.Y += 3.5
+		l.Page.TextAlign(l.Left+l.Width()/2, l.Y, l.Width()/2, pdf.Helvetica, size, "NIP. "+kop.NIPKepala, pdf.AlignCenter)
+	}
+	return nil
+}
+
+// drawQR menggambar QR code dengan quiet zone di (x, y) selebar size mm; modul gelap berurutan dalam satu
+// baris digabung menjadi satu kotak agar content stream tetap kecil
+func drawQR(p *pdf.Page, qr *qrcode.Code, x, y, size float64) {
+	module := size / float64(qr.Size+2*qrcode.QuietZone)
+	x += module * qrcode.QuietZone
+	y += module * qrcode.QuietZone
+	for row := 0; row < qr.Size; row++ {
+		for col := 0; col < qr.Size; {
+			if !qr.Dark(col, row) {
+				col++
+				continue
+			}
+			start := col
+			for col < qr.Size && qr.Dark(col, row) {
+				col++
+			}
+			p.Rect(x+float64(start)*module, y+float64(row)*module, float64(col-start)*module, module, true)
+		}
+	}
+}
+
+func dashIfEmpty(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
+
+//go:embed templates/undangan/*.html
+var undanganTemplateFS embed.FS
+
+var undanganHTML = template.Must(template.ParseFS(undanganTemplateFS, "templates/undangan/undangan_bimbingan.html"))
+
+// undanganHTMLPage adalah data satu halaman undangan di template HTML
+type undanganHTMLPage struct {
+	UndanganBimbingan
+	QR template.HTML
+}
+
+// RenderUndanganHTML menulis halaman HTML A4 siap cetak, satu halaman kertas per undangan
+func RenderUndanganHTML(w io.Writer, kop *UndanganKop, undangan []UndanganBimbingan, tanggalCetak string) error {
+	pages := make([]undanganHTMLPage, 0, len(undangan))
+	for _, u := range undangan {
+		qr, err := qrcode.Encode(u.VerifikasiURL)
+		if err != nil {
+			return err
+		}
+		// SVG dibangun dari matriks QR (hanya angka), aman disisipkan tanpa escape
+		pages = append(pages, undanganHTMLPage{UndanganBimbingan: u, QR: template.HTML(qr.SVG())})
+	}
+
+	var buf bytes.Buffer
+	err := undanganHTML.Execute(&buf, map[string]interface{}{
+		"Kop":          kop,
+		"Halaman":      pages,
+		"Instruksi":    undanganInstruksi,
+		"TanggalCetak": tanggalCetak,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
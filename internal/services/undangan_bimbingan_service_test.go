@@ -0,0 +1,114 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"simnikah/pkg/locale"
+)
+
+func undanganTestData(t *testing.T, us *UndanganBimbinganService) (*UndanganKop, []UndanganBimbingan) {
+	kop := &UndanganKop{
+		Instansi: "KEMENTERIAN AGAMA REPUBLIK INDONESIA", Kantor: "KANTOR KEMENTERIAN AGAMA KOTA BANDUNG",
+		NamaKUA: "KUA Kecamatan Coblong", Alamat: "Jl. Ir. H. Juanda No. 200, Bandung", Kecamatan: "Coblong",
+		Kontak: "Telepon 022-2500000", Kepala: "H. Abdul Rahman, S.Ag.", NIPKepala: "197501012000031001",
+	}
+	tanggal := time.Date(2026, 11, 7, 0, 0, 0, 0, time.UTC)
+	var undangan []UndanganBimbingan
+	for i, pasangan := range [][2]string{{"Muhammad Rizki", "Aisyah Putri"}, {"Budi Santoso", "Dewi <Lestari>"}} {
+		url, err := us.VerifikasiURL(uint(i + 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		undangan = append(undangan, UndanganBimbingan{
+			PesertaID: uint(i + 1), NomorPendaftaran: "NIK-20261001-000" + string(rune('1'+i)),
+			Hari: locale.Hari(tanggal.Weekday()), Tanggal: locale.Tanggal(tanggal), Waktu: locale.RentangJam("08:00", "12:00", locale.WITA),
+			Tempat: "Aula KUA Coblong", Pembimbing: "Dra. Hj. Nurhayati",
+			CalonSuami: pasangan[0], CalonIstri: pasangan[1], VerifikasiURL: url,
+		})
+	}
+	return kop, undangan
+}
+
+func TestRenderUndangan(t *testing.T) {
+	us := &UndanganBimbinganService{signingKey: []byte("uji"), baseURL: "https://simnikah.example.go.id/verifikasi"}
+	kop, undangan := undanganTestData(t, us)
+
+	out, err := RenderUndanganPDF(kop, undangan, "18 Oktober 2026")
+	if err != nil {
+		t.Fatalf("RenderUndanganPDF() error = %v", err)
+	}
+	if !bytes.Contains(out, []byte("/Count 2")) {
+		t.Errorf("PDF gabungan harus berisi satu halaman per undangan")
+	}
+	if again, _ := RenderUndanganPDF(kop, undangan, "18 Oktober 2026"); !bytes.Equal(out, again) {
+		t.Errorf("RenderUndanganPDF() tidak deterministik")
+	}
+
+	var html bytes.Buffer
+	if err := RenderUndanganHTML(&html, kop, undangan, "18 Oktober 2026"); err != nil {
+		t.Fatalf("RenderUndanganHTML() error = %v", err)
+	}
+	s := html.String()
+	if strings.Count(s, `class="halaman"`) != 2 || strings.Count(s, "<svg") != 2 {
+		t.Errorf("HTML harus berisi dua halaman dengan QR code")
+	}
+	if !strings.Contains(s, "Sabtu, 7 November 2026") || !strings.Contains(s, "08.00 - 12.00 WITA") || !strings.Contains(s, "Dewi &lt;Lestari&gt;") {
+		t.Errorf("HTML tidak memuat tanggal berbahasa Indonesia atau nama tidak di-escape")
+	}
+}
+
+func TestUndanganVerifikasiToken(t *testing.T) {
+	us := &UndanganBimbinganService{signingKey: []byte("uji"), baseURL: "https://simnikah.example.go.id/verifikasi"}
+	url, err := us.VerifikasiURL(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := strings.TrimPrefix(url, us.baseURL+"/")
+
+	// Token dari kunci lain ditolak sebelum menyentuh database
+	other := &UndanganBimbinganService{signingKey: []byte("lain")}
+	if _, _, err := other.Verify(token); !errors.Is(err, ErrUndanganInvalid) {
+		t.Errorf("Verify() dengan kunci lain error = %v, want ErrUndanganInvalid", err)
+	}
+	if _, _, err := us.Verify(token + "x"); !errors.Is(err, ErrUndanganInvalid) {
+		t.Errorf("Verify() token rusak error = %v, want ErrUndanganInvalid", err)
+	}
+}
+
+func TestCheckUndanganVerifyBaseURL(t *testing.T) {
+	publik := "https://simnikah.kemenag.go.id/simnikah/undangan-bimbingan/verifikasi"
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"pengembangan tanpa URL", map[string]string{}, ""},
+		{"pengembangan localhost", map[string]string{EnvUndanganVerifyBaseURL: defaultUndanganVerifyBaseURL}, ""},
+		{"produksi tanpa URL", map[string]string{"GIN_MODE": "release"}, "belum diatur"},
+		{"produksi localhost", map[string]string{"ENVIRONMENT": "production", EnvUndanganVerifyBaseURL: defaultUndanganVerifyBaseURL}, "localhost"},
+		{"produksi URL relatif", map[string]string{"GIN_MODE": "release", EnvUndanganVerifyBaseURL: "/simnikah/undangan-bimbingan/verifikasi"}, "URL absolut"},
+		{"produksi URL publik", map[string]string{"GIN_MODE": "release", EnvUndanganVerifyBaseURL: publik}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range []string{"GIN_MODE", "ENVIRONMENT", EnvUndanganVerifyBaseURL} {
+				t.Setenv(env, tt.env[env])
+			}
+			err := CheckUndanganVerifyBaseURL()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckUndanganVerifyBaseURL() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckUndanganVerifyBaseURL() error = %v, want menyebut %q", err, tt.wantErr)
+			}
+		})
+	}
+}
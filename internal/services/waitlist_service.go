@@ -0,0 +1,895 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/locale"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// WaitlistOfferDuration adalah batas waktu catin menerima tawaran dari daftar tunggu
+	WaitlistOfferDuration = 24 * time.Hour
+	// WaitlistRegistrationDuration adalah lama slot balai KUA tetap ditahan setelah tawaran diterima,
+	// untuk menyelesaikan formulir pendaftaran nikah
+	WaitlistRegistrationDuration = 2 * time.Hour
+)
+
+var (
+	// ErrDaftarTungguInvalid dikembalikan jika catin tidak memenuhi syarat masuk daftar tunggu
+	ErrDaftarTungguInvalid = errors.New("tidak dapat masuk daftar tunggu")
+	// ErrDaftarTungguNotFound dikembalikan jika entri daftar tunggu tidak ada atau bukan milik user
+	ErrDaftarTungguNotFound = errors.New("daftar tunggu tidak ditemukan")
+	// ErrDaftarTungguProcessed dikembalikan jika entri sudah diterima, kedaluwarsa, atau dibatalkan
+	ErrDaftarTungguProcessed = errors.New("daftar tunggu sudah diproses")
+	// ErrDaftarTungguExists dikembalikan jika catin sudah mengantre untuk tanggal/sesi yang sama
+	ErrDaftarTungguExists = errors.New("anda sudah berada di daftar tunggu ini")
+	// ErrDaftarTungguNotFull dikembalikan jika tanggal/sesi masih punya tempat sehingga bisa langsung dipesan
+	ErrDaftarTungguNotFull = errors.New("masih tersedia, silakan langsung mendaftar")
+	// ErrDaftarTungguExpired dikembalikan jika tawaran sudah melewati batas waktu menerima
+	ErrDaftarTungguExpired = errors.New("tawaran daftar tunggu sudah kedaluwarsa")
+
+	// errKursiBimbinganPenuh menandai tawaran kursi bimbingan yang tidak bisa dipenuhi saat diterima
+	errKursiBimbinganPenuh = errors.New("kursi bimbingan sudah penuh")
+)
+
+// daftarTungguAktif adalah status entri yang masih menempati antrean
+var daftarTungguAktif = []string{structs.DaftarTungguStatusMenunggu, structs.DaftarTungguStatusDitawarkan}
+
+// WaitlistEntry adalah entri daftar tunggu beserta posisinya di antrean (0 jika sudah tidak menunggu)
+type WaitlistEntry struct {
+	structs.DaftarTunggu
+	Posisi int `json:"posisi"`
+}
+
+// WaitlistService untuk daftar tunggu tanggal nikah di balai KUA dan sesi bimbingan perkawinan.
+// Ketika tempat kosong (pembatalan, penolakan, perubahan jadwal, atau kapasitas dinaikkan), antrean
+// dipromosikan berurutan: catin terdepan mendapat tawaran yang harus diterima dalam WaitlistOfferDuration.
+type WaitlistService struct {
+	DB *gorm.DB
+}
+
+// NewWaitlistService membuat instance baru dari WaitlistService
+func NewWaitlistService(db *gorm.DB) *WaitlistService {
+	return &WaitlistService{DB: db}
+}
+
+// availableSeats menghitung tempat kosong: sisa kapasitas harian, dibatasi jumlah slot waktu yang masih kosong
+func availableSeats(kapasitas, terpakai, slotKosong int) int {
+	sisa := kapasitas - terpakai
+	if slotKosong < sisa {
+		sisa = slotKosong
+	}
+	if sisa < 0 {
+		return 0
+	}
+	return sisa
+}
+
+// offerExpired mengecek apakah tawaran daftar tunggu sudah melewati batas waktu menerima
+func offerExpired(e *structs.DaftarTunggu, now time.Time) bool {
+	return e.Status == structs.DaftarTungguStatusDitawarkan && e.Berlaku_sampai != nil && e.Berlaku_sampai.Before(now)
+}
+
+// JoinDate memasukkan catin ke daftar tunggu tanggal nikah di balai KUA yang sudah penuh.
+// Catin yang sudah punya pendaftaran aktif memakai pengajuan perubahan jadwal, bukan daftar tunggu.
+func (ws *WaitlistService) JoinDate(kua *structs.KUA, tanggal time.Time, userID string) (*structs.DaftarTunggu, error) {
+	if tanggal.Before(TanggalLokal(time.Now(), NewKUAService(ws.DB).ZonaWaktu(kua.ID))) {
+		return nil, fmt.Errorf("%w: tanggal sudah lewat", ErrDaftarTungguInvalid)
+	}
+	if RulesForKUA(kua).IsClosedDay(tanggal) {
+		return nil, fmt.Errorf("%w: KUA tidak melayani nikah pada hari tersebut", ErrDaftarTungguInvalid)
+	}
+	if libur, err := NewHolidayService(ws.DB).Get(kua.ID, tanggal); err != nil {
+		return nil, err
+	} else if libur != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDaftarTungguInvalid, libur.Nama)
+	}
+
+	if aktif, err := ws.hasActiveRegistration(userID); err != nil {
+		return nil, err
+	} else if aktif {
+		return nil, fmt.Errorf("%w: anda sudah memiliki pendaftaran nikah aktif, ajukan perubahan jadwal", ErrDaftarTungguInvalid)
+	}
+
+	tanggalStr := tanggal.Format("2006-01-02")
+	if err := ws.ensureNotQueued(ws.DB.Scopes(TenantScope(kua.ID)).
+		Where("jenis = ? AND tanggal = ?", structs.DaftarTungguJenisTanggal, tanggalStr), userID); err != nil {
+		return nil, err
+	}
+
+	space, err := ws.dateSpace(kua, tanggal)
+	if err != nil {
+		return nil, err
+	}
+	if space > 0 {
+		return nil, ErrDaftarTungguNotFull
+	}
+
+	now := time.Now()
+	entry := structs.DaftarTunggu{
+		Kua_id:     kua.ID,
+		Jenis:      structs.DaftarTungguJenisTanggal,
+		Tanggal:    tanggalStr,
+		User_id:    userID,
+		Status:     structs.DaftarTungguStatusMenunggu,
+		Created_at: now,
+		Updated_at: now,
+	}
+	if err := ws.DB.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// JoinBimbingan memasukkan catin ke daftar tunggu sesi bimbingan perkawinan yang sudah penuh.
+// Syaratnya sama dengan mendaftar bimbingan: pendaftaran "Menunggu Bimbingan" di KUA penyelenggara.
+func (ws *WaitlistService) JoinBimbingan(bimbinganID string, userID string) (*structs.DaftarTunggu, error) {
+	var b structs.BimbinganPerkawinan
+	if err := ws.DB.First(&b, bimbinganID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: bimbingan perkawinan tidak ditemukan", ErrDaftarTungguInvalid)
+		}
+		return nil, err
+	}
+	if b.Status != "Aktif" {
+		return nil, fmt.Errorf("%w: bimbingan perkawinan tidak aktif", ErrDaftarTungguInvalid)
+	}
+	if b.Tanggal_bimbingan.Before(TanggalLokal(time.Now(), NewKUAService(ws.DB).ZonaWaktu(b.Kua_id))) {
+		return nil, fmt.Errorf("%w: tanggal bimbingan sudah lewat", ErrDaftarTungguInvalid)
+	}
+
+	var p structs.PendaftaranNikah
+	if err := ws.DB.Where("pendaftar_id = ? AND status_pendaftaran = ?", userID, structs.StatusPendaftaranMenungguBimbingan).
+		First(&p).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: anda belum memiliki pendaftaran nikah yang siap untuk bimbingan", ErrDaftarTungguInvalid)
+		}
+		return nil, err
+	}
+	if p.Kua_id != b.Kua_id {
+		return nil, fmt.Errorf("%w: bimbingan perkawinan ini diselenggarakan oleh KUA lain", ErrDaftarTungguInvalid)
+	}
+
+	var terdaftar int64
+	if err := ws.DB.Model(&structs.PendaftaranBimbingan{}).
+		Where("pendaftaran_nikah_id = ? AND bimbingan_perkawinan_id = ?", p.ID, b.ID).Count(&terdaftar).Error; err != nil {
+		return nil, err
+	}
+	if terdaftar > 0 {
+		return nil, fmt.Errorf("%w: anda sudah terdaftar di bimbingan perkawinan ini", ErrDaftarTungguInvalid)
+	}
+
+	if err := ws.ensureNotQueued(ws.DB.Where("jenis = ? AND bimbingan_id = ?", structs.DaftarTungguJenisBimbingan, b.ID), userID); err != nil {
+		return nil, err
+	}
+
+	space, err := bimbinganSpace(ws.DB, &b)
+	if err != nil {
+		return nil, err
+	}
+	if space > 0 {
+		return nil, ErrDaftarTungguNotFull
+	}
+
+	now := time.Now()
+	entry := structs.DaftarTunggu{
+		Kua_id:         b.Kua_id,
+		Jenis:          structs.DaftarTungguJenisBimbingan,
+		Tanggal:        b.Tanggal_bimbingan.Format("2006-01-02"),
+		Bimbingan_id:   &b.ID,
+		Pendaftaran_id: &p.ID,
+		User_id:        userID,
+		Status:         structs.DaftarTungguStatusMenunggu,
+		Created_at:     now,
+		Updated_at:     now,
+	}
+	if err := ws.DB.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Accept menerima tawaran daftar tunggu milik user. Untuk tanggal nikah, slot balai KUA tetap ditahan selama
+// WaitlistRegistrationDuration agar catin menyelesaikan pendaftaran; untuk bimbingan, catin langsung didaftarkan.
+func (ws *WaitlistService) Accept(id string, userID string) (*structs.DaftarTunggu, error) {
+	entry, err := ws.findOwn(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Status != structs.DaftarTungguStatusDitawarkan {
+		return nil, ErrDaftarTungguProcessed
+	}
+
+	now := time.Now()
+	if offerExpired(entry, now) {
+		ws.expire(entry)
+		ws.promoteTarget(entry)
+		return nil, ErrDaftarTungguExpired
+	}
+
+	err = ws.DB.Transaction(func(tx *gorm.DB) error {
+		switch entry.Jenis {
+		case structs.DaftarTungguJenisTanggal:
+			sampai := now.Add(WaitlistRegistrationDuration)
+			result := tx.Model(&structs.SlotNikah{}).
+				Where("kua_id = ? AND tanggal = ? AND waktu = ? AND ditahan_oleh = ? AND status = ?",
+					entry.Kua_id, entry.Tanggal, entry.Waktu_tawaran, userID, structs.SlotNikahStatusDitahan).
+				Updates(map[string]interface{}{"kedaluwarsa_pada": sampai, "updated_at": now})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 && !ws.slotBookedBy(entry) {
+				// Penahanan sudah dilepas, mis. catin menahan slot lain lewat /slot-nikah/hold
+				return ErrDaftarTungguExpired
+			}
+		case structs.DaftarTungguJenisBimbingan:
+			if err := joinBimbinganFromWaitlist(tx, entry, now); err != nil {
+				return err
+			}
+		}
+
+		entry.Status = structs.DaftarTungguStatusDiterima
+		entry.Diproses_pada = &now
+		entry.Updated_at = now
+		return tx.Save(entry).Error
+	})
+	if errors.Is(err, ErrDaftarTungguExpired) {
+		ws.expire(entry)
+		ws.promoteTarget(entry)
+		return nil, err
+	}
+	if errors.Is(err, errKursiBimbinganPenuh) {
+		ws.requeue(entry)
+		return nil, fmt.Errorf("%w: kursi bimbingan sudah penuh, anda tetap berada di daftar tunggu", ErrDaftarTungguInvalid)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.Jenis == structs.DaftarTungguJenisBimbingan {
+		if err := NewNotificationService(ws.DB).SendBimbinganNotification(*entry.Bimbingan_id, "created"); err != nil {
+			log.Printf("Gagal mengirim notifikasi pendaftaran bimbingan: %v", err)
+		}
+	}
+	return entry, nil
+}
+
+// joinBimbinganFromWaitlist mendaftarkan pendaftaran nikah entri daftar tunggu ke sesi bimbingannya.
+// Mengembalikan errKursiBimbinganPenuh jika sesi ternyata sudah penuh, mis. kapasitas diturunkan setelah tawaran dibuat.
+func joinBimbinganFromWaitlist(tx *gorm.DB, entry *structs.DaftarTunggu, now time.Time) error {
+	var p structs.PendaftaranNikah
+	if err := tx.First(&p, *entry.Pendaftaran_id).Error; err != nil {
+		return err
+	}
+	if p.Status_pendaftaran != structs.StatusPendaftaranMenungguBimbingan {
+		return fmt.Errorf("%w: pendaftaran nikah tidak lagi menunggu bimbingan", ErrDaftarTungguInvalid)
+	}
+
+	var terdaftar int64
+	if err := tx.Model(&structs.PendaftaranBimbingan{}).
+		Where("pendaftaran_nikah_id = ? AND bimbingan_perkawinan_id = ?", p.ID, *entry.Bimbingan_id).Count(&terdaftar).Error; err != nil {
+		return err
+	}
+	if terdaftar > 0 {
+		return nil
+	}
+
+	// Hitung ulang peserta di bawah kunci sesi; kursi tawaran lain tetap dicadangkan untuk pemiliknya
+	var b structs.BimbinganPerkawinan
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&b, *entry.Bimbingan_id).Error; err != nil {
+		return err
+	}
+	var peserta int64
+	if err := tx.Model(&structs.PendaftaranBimbingan{}).Where("bimbingan_perkawinan_id = ?", b.ID).Count(&peserta).Error; err != nil {
+		return err
+	}
+	ditawarkan, err := reservedBimbinganSeats(tx, b.ID, entry.User_id)
+	if err != nil {
+		return err
+	}
+	if int(peserta+ditawarkan) >= b.Kapasitas {
+		return errKursiBimbinganPenuh
+	}
+
+	return tx.Create(&structs.PendaftaranBimbingan{
+		Pendaftaran_nikah_id:    p.ID,
+		Bimbingan_perkawinan_id: *entry.Bimbingan_id,
+		Calon_suami_id:          p.Calon_suami_id,
+		Calon_istri_id:          p.Calon_istri_id,
+		Status_kehadiran:        "Belum",
+		Status_sertifikat:       "Belum",
+		Created_at:              now,
+		Updated_at:              now,
+	}).Error
+}
+
+// Cancel mengeluarkan user dari daftar tunggu. Tawaran yang dibatalkan melepas slot yang ditahan
+// dan langsung diteruskan ke antrean berikutnya.
+func (ws *WaitlistService) Cancel(id string, userID string) (*structs.DaftarTunggu, error) {
+	entry, err := ws.findOwn(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Status != structs.DaftarTungguStatusMenunggu && entry.Status != structs.DaftarTungguStatusDitawarkan {
+		return nil, ErrDaftarTungguProcessed
+	}
+
+	ditawarkan := entry.Status == structs.DaftarTungguStatusDitawarkan
+	if ditawarkan {
+		if err := ws.releaseOfferedSlot(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	entry.Status = structs.DaftarTungguStatusDibatalkan
+	entry.Diproses_pada = &now
+	entry.Updated_at = now
+	if err := ws.DB.Save(entry).Error; err != nil {
+		return nil, err
+	}
+
+	if ditawarkan {
+		ws.promoteTarget(entry)
+	}
+	return entry, nil
+}
+
+// ListMine mengambil daftar tunggu milik user beserta posisi antrean, terbaru lebih dulu
+func (ws *WaitlistService) ListMine(userID string) ([]WaitlistEntry, error) {
+	var rows []structs.DaftarTunggu
+	if err := ws.DB.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return ws.withPosition(rows)
+}
+
+// List mengambil daftar tunggu di KUA, bisa difilter jenis dan status; urut sesuai antrean
+func (ws *WaitlistService) List(kuaID uint, jenis, status string) ([]WaitlistEntry, error) {
+	query := ws.DB.Scopes(TenantScope(kuaID))
+	if jenis != "" {
+		query = query.Where("jenis = ?", jenis)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var rows []structs.DaftarTunggu
+	if err := query.Order("tanggal, created_at, id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return ws.withPosition(rows)
+}
+
+// CountDates menghitung jumlah catin yang menunggu per tanggal nikah di KUA pada rentang tanggal
+func (ws *WaitlistService) CountDates(kuaID uint, from, to time.Time) (map[string]int, error) {
+	var rows []struct {
+		Tanggal string
+		Jumlah  int
+	}
+	err := ws.DB.Model(&structs.DaftarTunggu{}).Scopes(TenantScope(kuaID)).
+		Select("tanggal, COUNT(*) AS jumlah").
+		Where("jenis = ? AND status = ? AND tanggal BETWEEN ? AND ?",
+			structs.DaftarTungguJenisTanggal, structs.DaftarTungguStatusMenunggu, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Group("tanggal").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int, len(rows))
+	for _, row := range rows {
+		result[row.Tanggal] = row.Jumlah
+	}
+	return result, nil
+}
+
+// ReservedBimbinganSeats menghitung kursi sesi bimbingan yang sedang ditawarkan ke catin daftar tunggu
+// selain exceptUserID. Kursi ini tidak boleh diambil lewat pendaftaran bimbingan biasa.
+func (ws *WaitlistService) ReservedBimbinganSeats(bimbinganID uint, exceptUserID string) (int64, error) {
+	return reservedBimbinganSeats(ws.DB, bimbinganID, exceptUserID)
+}
+
+// reservedBimbinganSeats adalah ReservedBimbinganSeats yang berjalan di koneksi atau transaksi db
+func reservedBimbinganSeats(db *gorm.DB, bimbinganID uint, exceptUserID string) (int64, error) {
+	var count int64
+	err := db.Model(&structs.DaftarTunggu{}).
+		Where("jenis = ? AND bimbingan_id = ? AND status = ? AND berlaku_sampai >= ? AND user_id <> ?",
+			structs.DaftarTungguJenisBimbingan, bimbinganID, structs.DaftarTungguStatusDitawarkan, time.Now(), exceptUserID).
+		Count(&count).Error
+	return count, err
+}
+
+// CompleteBimbingan menandai entri daftar tunggu user untuk sesi bimbingan sebagai diterima
+// setelah user mendaftar bimbingan tersebut secara langsung
+func (ws *WaitlistService) CompleteBimbingan(bimbinganID uint, userID string) error {
+	now := time.Now()
+	return ws.DB.Model(&structs.DaftarTunggu{}).
+		Where("jenis = ? AND bimbingan_id = ? AND user_id = ? AND status IN ?",
+			structs.DaftarTungguJenisBimbingan, bimbinganID, userID, daftarTungguAktif).
+		Updates(map[string]interface{}{"status": structs.DaftarTungguStatusDiterima, "diproses_pada": now, "updated_at": now}).Error
+}
+
+// PromoteDate menawarkan slot balai KUA yang kosong pada tanggal tersebut ke antrean terdepan.
+// Tawaran yang kedaluwarsa dilepas lebih dulu; slot yang ditawarkan ditahan atas nama catin sampai batas menerima.
+func (ws *WaitlistService) PromoteDate(kuaID uint, tanggal string) error {
+	scope := ws.DB.Scopes(TenantScope(kuaID)).Where("jenis = ? AND tanggal = ?", structs.DaftarTungguJenisTanggal, tanggal)
+	if err := ws.expireOffers(scope.Session(&gorm.Session{})); err != nil {
+		return err
+	}
+
+	t, err := time.Parse("2006-01-02", tanggal)
+	if err != nil {
+		return err
+	}
+	if t.Before(TanggalLokal(time.Now(), NewKUAService(ws.DB).ZonaWaktu(kuaID))) {
+		return ws.closeQueue(scope.Session(&gorm.Session{}))
+	}
+
+	var kua structs.KUA
+	if err := ws.DB.First(&kua, kuaID).Error; err != nil {
+		return err
+	}
+	space, err := ws.dateSpace(&kua, t)
+	if err != nil || space == 0 {
+		return err
+	}
+
+	var queue []structs.DaftarTunggu
+	if err := scope.Session(&gorm.Session{}).Where("status = ?", structs.DaftarTungguStatusMenunggu).
+		Order("created_at, id").Find(&queue).Error; err != nil {
+		return err
+	}
+	for i := range queue {
+		if space == 0 {
+			break
+		}
+		entry := &queue[i]
+		if aktif, err := ws.hasActiveRegistration(entry.User_id); err != nil {
+			return err
+		} else if aktif {
+			ws.close(entry, structs.DaftarTungguStatusDibatalkan)
+			continue
+		}
+
+		offered, err := ws.offerDate(&kua, t, entry)
+		if err != nil {
+			return err
+		}
+		if !offered {
+			break
+		}
+		space--
+	}
+	return nil
+}
+
+// PromoteBimbingan menawarkan kursi sesi bimbingan yang kosong ke antrean terdepan. Baris sesi dikunci
+// selama kursi dihitung dan tawaran ditulis agar promosi yang berjalan bersamaan tidak menawarkan kursi yang sama.
+func (ws *WaitlistService) PromoteBimbingan(bimbinganID uint) error {
+	scope := ws.DB.Where("jenis = ? AND bimbingan_id = ?", structs.DaftarTungguJenisBimbingan, bimbinganID)
+	if err := ws.expireOffers(scope.Session(&gorm.Session{})); err != nil {
+		return err
+	}
+
+	var b structs.BimbinganPerkawinan
+	if err := ws.DB.First(&b, bimbinganID).Error; err != nil {
+		return err
+	}
+	zona := NewKUAService(ws.DB).ZonaWaktu(b.Kua_id)
+	if b.Status != "Aktif" || b.Tanggal_bimbingan.Before(TanggalLokal(time.Now(), zona)) {
+		return ws.closeQueue(scope.Session(&gorm.Session{}))
+	}
+
+	var ditawarkan, batal []structs.DaftarTunggu
+	err := ws.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&b, bimbinganID).Error; err != nil {
+			return err
+		}
+		space, err := bimbinganSpace(tx, &b)
+		if err != nil || space == 0 {
+			return err
+		}
+
+		var queue []structs.DaftarTunggu
+		if err := tx.Where("jenis = ? AND bimbingan_id = ? AND status = ?", structs.DaftarTungguJenisBimbingan, bimbinganID, structs.DaftarTungguStatusMenunggu).
+			Order("created_at, id").Find(&queue).Error; err != nil {
+			return err
+		}
+		for i := range queue {
+			if space == 0 {
+				break
+			}
+			entry := queue[i]
+
+			var siap int64
+			if err := tx.Model(&structs.PendaftaranNikah{}).
+				Where("id = ? AND status_pendaftaran = ?", entry.Pendaftaran_id, structs.StatusPendaftaranMenungguBimbingan).
+				Count(&siap).Error; err != nil {
+				return err
+			}
+			if siap == 0 {
+				batal = append(batal, entry)
+				continue
+			}
+
+			now := time.Now()
+			berlaku := now.Add(WaitlistOfferDuration)
+			if b.Tanggal_bimbingan.Before(berlaku) {
+				berlaku = b.Tanggal_bimbingan
+			}
+			result := tx.Model(&structs.DaftarTunggu{}).
+				Where("id = ? AND status = ?", entry.ID, structs.DaftarTungguStatusMenunggu).
+				Updates(map[string]interface{}{
+					"status":          structs.DaftarTungguStatusDitawarkan,
+					"ditawarkan_pada": now,
+					"berlaku_sampai":  berlaku,
+					"updated_at":      now,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			space--
+			entry.Berlaku_sampai = &berlaku
+			ditawarkan = append(ditawarkan, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range batal {
+		ws.close(&batal[i], structs.DaftarTungguStatusDibatalkan)
+	}
+	for _, entry := range ditawarkan {
+		ws.notify(entry.User_id, "Kursi Bimbingan Perkawinan Tersedia",
+			fmt.Sprintf("Kursi bimbingan perkawinan tanggal %s pukul %s tersedia untuk Anda. Terima tawaran sebelum %s atau kursi diteruskan ke antrean berikutnya.",
+				locale.HariTanggal(b.Tanggal_bimbingan), locale.FormatJam(b.Waktu_mulai, zona), locale.TanggalJam(entry.Berlaku_sampai.In(zona))),
+			structs.NotifikasiTipeSuccess)
+	}
+	return nil
+}
+
+// PromoteKUA mempromosikan semua antrean aktif di KUA, mis. setelah pembatalan atau kapasitas dinaikkan
+func (ws *WaitlistService) PromoteKUA(kuaID uint) error {
+	var tanggal []string
+	if err := ws.DB.Model(&structs.DaftarTunggu{}).Scopes(TenantScope(kuaID)).
+		Where("jenis = ? AND status IN ?", structs.DaftarTungguJenisTanggal, daftarTungguAktif).
+		Distinct().Pluck("tanggal", &tanggal).Error; err != nil {
+		return err
+	}
+	var bimbingan []uint
+	if err := ws.DB.Model(&structs.DaftarTunggu{}).Scopes(TenantScope(kuaID)).
+		Where("jenis = ? AND status IN ?", structs.DaftarTungguJenisBimbingan, daftarTungguAktif).
+		Distinct().Pluck("bimbingan_id", &bimbingan).Error; err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, t := range tanggal {
+		if err := ws.PromoteDate(kuaID, t); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, id := range bimbingan {
+		if err := ws.PromoteBimbingan(id); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Run menjalankan promosi semua KUA yang punya antrean aktif (dipanggil berkala):
+// tawaran kedaluwarsa diteruskan dan tempat yang kosong karena penahanan slot habis ditawarkan
+func (ws *WaitlistService) Run() error {
+	var kuaIDs []uint
+	if err := ws.DB.Model(&structs.DaftarTunggu{}).Where("status IN ?", daftarTungguAktif).
+		Distinct().Pluck("kua_id", &kuaIDs).Error; err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, kuaID := range kuaIDs {
+		if err := ws.PromoteKUA(kuaID); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// PromoteAfterRelease mempromosikan antrean KUA setelah tempat dilepas atau kapasitas bertambah; error hanya dicatat
+func (ws *WaitlistService) PromoteAfterRelease(kuaID uint) {
+	if err := ws.PromoteKUA(kuaID); err != nil {
+		log.Printf("Gagal mempromosikan daftar tunggu KUA %d: %v", kuaID, err)
+	}
+}
+
+// offerDate menahan slot kosong pertama pada tanggal tersebut untuk entri lalu memberi tahu catin.
+// Mengembalikan false jika tidak ada slot yang bisa ditahan.
+func (ws *WaitlistService) offerDate(kua *structs.KUA, t time.Time, entry *structs.DaftarTunggu) (bool, error) {
+	slots, err := NewSlotService(ws.DB).ListDay(kua, t, "")
+	if err != nil {
+		return false, err
+	}
+
+	zona := locale.ZonaProvinsi(kua.Provinsi)
+	for _, info := range slots {
+		if !info.Tersedia {
+			continue
+		}
+
+		now := time.Now()
+		berlaku := now.Add(WaitlistOfferDuration)
+		err := ws.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&structs.DaftarTunggu{}).
+				Where("id = ? AND status = ?", entry.ID, structs.DaftarTungguStatusMenunggu).
+				Updates(map[string]interface{}{
+					"status":          structs.DaftarTungguStatusDitawarkan,
+					"waktu_tawaran":   info.Waktu,
+					"ditawarkan_pada": now,
+					"berlaku_sampai":  berlaku,
+					"updated_at":      now,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrDaftarTungguProcessed
+			}
+
+			var slot structs.SlotNikah
+			return claimSlot(tx, &slot, kua.ID, entry.Tanggal, info.Waktu, entry.User_id, func(s *structs.SlotNikah) {
+				s.Status = structs.SlotNikahStatusDitahan
+				s.Pendaftaran_id = nil
+				s.Kedaluwarsa_pada = &berlaku
+			})
+		})
+		switch {
+		case errors.Is(err, ErrSlotUnavailable):
+			// Slot baru saja diambil catin lain, coba slot berikutnya
+			continue
+		case errors.Is(err, ErrDaftarTungguProcessed):
+			// Entri sudah ditawarkan oleh proses promosi lain
+			return true, nil
+		case err != nil:
+			return false, err
+		}
+
+		ws.notify(entry.User_id, "Slot Nikah di KUA Tersedia",
+			fmt.Sprintf("Slot nikah di %s tanggal %s pukul %s tersedia dan ditahan untuk Anda. Terima tawaran sebelum %s atau slot diteruskan ke antrean berikutnya.",
+				kua.Nama, locale.FormatTanggal(entry.Tanggal), locale.FormatJam(info.Waktu, zona), locale.TanggalJam(berlaku.In(zona))),
+			structs.NotifikasiTipeSuccess)
+		return true, nil
+	}
+	return false, nil
+}
+
+// dateSpace menghitung tempat nikah di balai KUA yang masih kosong pada tanggal tersebut:
+// kapasitas harian dikurangi KUADailyLoad, dibatasi jumlah slot waktu yang masih kosong.
+func (ws *WaitlistService) dateSpace(kua *structs.KUA, t time.Time) (int, error) {
+	slots, err := NewSlotService(ws.DB).ListDay(kua, t, "")
+	if err != nil {
+		return 0, err
+	}
+	slotKosong := 0
+	for _, info := range slots {
+		if info.Tersedia {
+			slotKosong++
+		}
+	}
+
+	beban, err := KUADailyLoad(ws.DB, kua.ID, t, nil)
+	if err != nil {
+		return 0, err
+	}
+	return availableSeats(RulesForKUA(kua).KapasitasKUAHarian, beban, slotKosong), nil
+}
+
+// bimbinganSpace menghitung kursi sesi bimbingan yang kosong: kapasitas dikurangi peserta dan tawaran yang berlaku
+func bimbinganSpace(db *gorm.DB, b *structs.BimbinganPerkawinan) (int, error) {
+	var peserta int64
+	if err := db.Model(&structs.PendaftaranBimbingan{}).Where("bimbingan_perkawinan_id = ?", b.ID).
+		Count(&peserta).Error; err != nil {
+		return 0, err
+	}
+	ditawarkan, err := reservedBimbinganSeats(db, b.ID, "")
+	if err != nil {
+		return 0, err
+	}
+	return availableSeats(b.Kapasitas, int(peserta+ditawarkan), b.Kapasitas), nil
+}
+
+// expireOffers menandai tawaran yang melewati batas waktu sebagai kedaluwarsa dan melepas slot yang ditahan
+func (ws *WaitlistService) expireOffers(scope *gorm.DB) error {
+	var expired []structs.DaftarTunggu
+	if err := scope.Where("status = ? AND berlaku_sampai < ?", structs.DaftarTungguStatusDitawarkan, time.Now()).
+		Find(&expired).Error; err != nil {
+		return err
+	}
+	for i := range expired {
+		ws.expire(&expired[i])
+	}
+	return nil
+}
+
+// expire menandai satu tawaran kedaluwarsa, melepas slot yang ditahan, dan memberi tahu catin.
+// Tawaran yang slotnya sudah dipesan catin lewat pendaftaran nikah dianggap diterima.
+func (ws *WaitlistService) expire(entry *structs.DaftarTunggu) {
+	if ws.slotBookedBy(entry) {
+		ws.close(entry, structs.DaftarTungguStatusDiterima)
+		return
+	}
+	if err := ws.releaseOfferedSlot(entry); err != nil {
+		log.Printf("Gagal melepas slot tawaran daftar tunggu %d: %v", entry.ID, err)
+	}
+	ws.close(entry, structs.DaftarTungguStatusKedaluwarsa)
+	ws.notify(entry.User_id, "Tawaran Daftar Tunggu Kedaluwarsa",
+		fmt.Sprintf("Tawaran %s tanggal %s tidak diterima sampai batas waktu dan diteruskan ke antrean berikutnya.",
+			describeDaftarTunggu(entry), locale.FormatTanggal(entry.Tanggal)),
+		structs.NotifikasiTipeWarning)
+}
+
+// closeQueue menutup semua entri aktif pada antrean yang tanggal/sesinya sudah lewat atau tidak aktif
+func (ws *WaitlistService) closeQueue(scope *gorm.DB) error {
+	var rows []structs.DaftarTunggu
+	if err := scope.Where("status IN ?", daftarTungguAktif).Find(&rows).Error; err != nil {
+		return err
+	}
+	for i := range rows {
+		if err := ws.releaseOfferedSlot(&rows[i]); err != nil {
+			return err
+		}
+		ws.close(&rows[i], structs.DaftarTungguStatusKedaluwarsa)
+	}
+	return nil
+}
+
+// requeue mengembalikan tawaran yang tidak bisa dipenuhi ke antrean tanpa mengubah urutannya
+func (ws *WaitlistService) requeue(entry *structs.DaftarTunggu) {
+	now := time.Now()
+	if err := ws.DB.Model(&structs.DaftarTunggu{}).Where("id = ? AND status = ?", entry.ID, structs.DaftarTungguStatusDitawarkan).
+		Updates(map[string]interface{}{
+			"status":          structs.DaftarTungguStatusMenunggu,
+			"ditawarkan_pada": nil,
+			"berlaku_sampai":  nil,
+			"updated_at":      now,
+		}).Error; err != nil {
+		log.Printf("Gagal mengembalikan daftar tunggu %d ke antrean: %v", entry.ID, err)
+	}
+}
+
+// close menyimpan status akhir entri daftar tunggu
+func (ws *WaitlistService) close(entry *structs.DaftarTunggu, status string) {
+	now := time.Now()
+	entry.Status = status
+	entry.Diproses_pada = &now
+	entry.Updated_at = now
+	if err := ws.DB.Save(entry).Error; err != nil {
+		log.Printf("Gagal memperbarui daftar tunggu %d: %v", entry.ID, err)
+	}
+}
+
+// releaseOfferedSlot melepas slot balai KUA yang masih ditahan untuk tawaran daftar tunggu tanggal nikah
+func (ws *WaitlistService) releaseOfferedSlot(entry *structs.DaftarTunggu) error {
+	if entry.Jenis != structs.DaftarTungguJenisTanggal || entry.Status != structs.DaftarTungguStatusDitawarkan {
+		return nil
+	}
+	return ws.DB.Where("kua_id = ? AND tanggal = ? AND waktu = ? AND ditahan_oleh = ? AND status = ?",
+		entry.Kua_id, entry.Tanggal, entry.Waktu_tawaran, entry.User_id, structs.SlotNikahStatusDitahan).
+		Delete(&structs.SlotNikah{}).Error
+}
+
+// slotBookedBy mengecek apakah slot tawaran tanggal nikah sudah dipesan pendaftaran milik catin itu sendiri
+func (ws *WaitlistService) slotBookedBy(entry *structs.DaftarTunggu) bool {
+	if entry.Jenis != structs.DaftarTungguJenisTanggal || entry.Waktu_tawaran == "" {
+		return false
+	}
+	var count int64
+	ws.DB.Model(&structs.SlotNikah{}).
+		Where("kua_id = ? AND tanggal = ? AND waktu = ? AND ditahan_oleh = ? AND status = ?",
+			entry.Kua_id, entry.Tanggal, entry.Waktu_tawaran, entry.User_id, structs.SlotNikahStatusTerpesan).
+		Count(&count)
+	return count > 0
+}
+
+// promoteTarget mempromosikan antrean tempat entri berada; error hanya dicatat
+func (ws *WaitlistService) promoteTarget(entry *structs.DaftarTunggu) {
+	var err error
+	if entry.Jenis == structs.DaftarTungguJenisBimbingan && entry.Bimbingan_id != nil {
+		err = ws.PromoteBimbingan(*entry.Bimbingan_id)
+	} else {
+		err = ws.PromoteDate(entry.Kua_id, entry.Tanggal)
+	}
+	if err != nil {
+		log.Printf("Gagal mempromosikan daftar tunggu: %v", err)
+	}
+}
+
+// withPosition melengkapi entri yang masih menunggu dengan posisi antreannya (1 = terdepan)
+func (ws *WaitlistService) withPosition(rows []structs.DaftarTunggu) ([]WaitlistEntry, error) {
+	result := make([]WaitlistEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := WaitlistEntry{DaftarTunggu: row}
+		if row.Status == structs.DaftarTungguStatusMenunggu {
+			query := ws.DB.Model(&structs.DaftarTunggu{}).
+				Where("jenis = ? AND status = ? AND (created_at < ? OR (created_at = ? AND id < ?))",
+					row.Jenis, structs.DaftarTungguStatusMenunggu, row.Created_at, row.Created_at, row.ID)
+			if row.Jenis == structs.DaftarTungguJenisBimbingan {
+				query = query.Where("bimbingan_id = ?", row.Bimbingan_id)
+			} else {
+				query = query.Where("kua_id = ? AND tanggal = ?", row.Kua_id, row.Tanggal)
+			}
+
+			var didepan int64
+			if err := query.Count(&didepan).Error; err != nil {
+				return nil, err
+			}
+			entry.Posisi = int(didepan) + 1
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// findOwn memuat entri daftar tunggu milik user
+func (ws *WaitlistService) findOwn(id string, userID string) (*structs.DaftarTunggu, error) {
+	var entry structs.DaftarTunggu
+	if err := ws.DB.Where("user_id = ?", userID).First(&entry, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDaftarTungguNotFound
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// ensureNotQueued memastikan user belum punya entri aktif pada antrean scope
+func (ws *WaitlistService) ensureNotQueued(scope *gorm.DB, userID string) error {
+	var count int64
+	if err := scope.Model(&structs.DaftarTunggu{}).Where("user_id = ? AND status IN ?", userID, daftarTungguAktif).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDaftarTungguExists
+	}
+	return nil
+}
+
+// hasActiveRegistration mengecek apakah user punya pendaftaran nikah yang belum selesai, ditolak, atau dibatalkan
+func (ws *WaitlistService) hasActiveRegistration(userID string) (bool, error) {
+	var count int64
+	err := ws.DB.Model(&structs.PendaftaranNikah{}).
+		Where("pendaftar_id = ? AND status_pendaftaran NOT IN ?", userID,
+			[]string{structs.StatusPendaftaranSelesai, structs.StatusPendaftaranDitolak, structs.StatusPendaftaranDibatalkan}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// notify mengirim notifikasi daftar tunggu ke catin; error hanya dicatat
+func (ws *WaitlistService) notify(userID, judul, pesan, tipe string) {
+	if err := NewNotificationService(ws.DB).SendSystemNotification(userID, judul, pesan, tipe, "/simnikah/daftar-tunggu"); err != nil {
+		log.Printf("Gagal mengirim notifikasi daftar tunggu: %v", err)
+	}
+}
+
+// describeDaftarTunggu mengembalikan nama antrean untuk pesan notifikasi
+func describeDaftarTunggu(entry *structs.DaftarTunggu) string {
+	if entry.Jenis == structs.DaftarTungguJenisBimbingan {
+		return "kursi bimbingan perkawinan"
+	}
+	if entry.Waktu_tawaran != "" {
+		return "slot nikah pukul " + locale.FormatJam(entry.Waktu_tawaran, nil)
+	}
+	return "slot nikah"
+}
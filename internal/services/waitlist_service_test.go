@@ -0,0 +1,340 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	structs "simnikah/internal/models"
+	"simnikah/pkg/locale"
+
+	"gorm.io/gorm"
+)
+
+func TestAvailableSeats(t *testing.T) {
+	tests := []struct {
+		name                            string
+		kapasitas, terpakai, slotKosong int
+		want                            int
+	}{
+		{"kosong", 9, 0, 9, 9},
+		{"sisa kapasitas", 9, 7, 5, 2},
+		{"dibatasi slot kosong", 9, 3, 1, 1},
+		{"penuh", 9, 9, 4, 0},
+		{"melebihi kapasitas setelah diturunkan", 5, 7, 2, 0},
+		{"tidak ada slot kosong", 9, 2, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := availableSeats(tt.kapasitas, tt.terpakai, tt.slotKosong); got != tt.want {
+				t.Errorf("availableSeats(%d, %d, %d) = %d, want %d", tt.kapasitas, tt.terpakai, tt.slotKosong, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOfferExpired(t *testing.T) {
+	now := time.Date(2026, 11, 2, 10, 0, 0, 0, time.UTC)
+	lewat := now.Add(-time.Minute)
+	berlaku := now.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		entry structs.DaftarTunggu
+		want  bool
+	}{
+		{"tawaran lewat batas", structs.DaftarTunggu{Status: structs.DaftarTungguStatusDitawarkan, Berlaku_sampai: &lewat}, true},
+		{"tawaran masih berlaku", structs.DaftarTunggu{Status: structs.DaftarTungguStatusDitawarkan, Berlaku_sampai: &berlaku}, false},
+		{"masih menunggu", structs.DaftarTunggu{Status: structs.DaftarTungguStatusMenunggu}, false},
+		{"sudah diterima", structs.DaftarTunggu{Status: structs.DaftarTungguStatusDiterima, Berlaku_sampai: &lewat}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := offerExpired(&tt.entry, now); got != tt.want {
+				t.Errorf("offerExpired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// waitlistTestDate mengembalikan tanggal sebulan lagi yang bukan hari tutup KUA
+func waitlistTestDate(kua *structs.KUA) time.Time {
+	hari := time.Now().UTC().AddDate(0, 1, 0)
+	tanggal := time.Date(hari.Year(), hari.Month(), hari.Day(), 0, 0, 0, 0, time.UTC)
+	for RulesForKUA(kua).IsClosedDay(tanggal) {
+		tanggal = tanggal.AddDate(0, 0, 1)
+	}
+	return tanggal
+}
+
+// loadDaftarTunggu memuat ulang entri daftar tunggu dari database
+func loadDaftarTunggu(t *testing.T, db *gorm.DB, id uint) structs.DaftarTunggu {
+	t.Helper()
+
+	var entry structs.DaftarTunggu
+	if err := db.First(&entry, id).Error; err != nil {
+		t.Fatalf("load daftar tunggu %d: %v", id, err)
+	}
+	return entry
+}
+
+func TestWaitlistServiceDate(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	kua.Kapasitas_nikah_harian = 1
+	db.Save(&kua)
+	tanggal := waitlistTestDate(&kua)
+	tanggalStr := tanggal.Format("2006-01-02")
+	ws := NewWaitlistService(db)
+
+	if _, err := ws.JoinDate(&kua, tanggal, "CATIN3"); !errors.Is(err, ErrDaftarTungguNotFull) {
+		t.Fatalf("JoinDate(tanggal kosong) error = %v, want ErrDaftarTungguNotFull", err)
+	}
+	if _, err := ws.JoinDate(&kua, TanggalLokal(time.Now(), locale.WITA).AddDate(0, 0, -1), "CATIN3"); !errors.Is(err, ErrDaftarTungguInvalid) {
+		t.Fatalf("JoinDate(kemarin) error = %v, want ErrDaftarTungguInvalid", err)
+	}
+
+	p := createTestPendaftaran(t, db, structs.PendaftaranNikah{
+		Kua_id:        kua.ID,
+		Tanggal_nikah: tanggal,
+		Waktu_nikah:   "09:00",
+	})
+	if err := BookSlot(db, kua.ID, tanggalStr, "09:00", p.Pendaftar_id, p.ID); err != nil {
+		t.Fatalf("BookSlot() error = %v", err)
+	}
+
+	// Antrean FIFO: A, B, C
+	var antrean []uint
+	for _, userID := range []string{"CATIN3", "CATIN4", "CATIN5"} {
+		entry, err := ws.JoinDate(&kua, tanggal, userID)
+		if err != nil {
+			t.Fatalf("JoinDate(%s) error = %v", userID, err)
+		}
+		antrean = append(antrean, entry.ID)
+	}
+	if _, err := ws.JoinDate(&kua, tanggal, "CATIN3"); !errors.Is(err, ErrDaftarTungguExists) {
+		t.Errorf("JoinDate(mengantre dua kali) error = %v, want ErrDaftarTungguExists", err)
+	}
+	mine, err := ws.ListMine("CATIN5")
+	if err != nil || len(mine) != 1 || mine[0].Posisi != 3 {
+		t.Errorf("ListMine(CATIN5) = %+v, %v, want posisi 3", mine, err)
+	}
+
+	// Pembatalan melepas slot dan menawarkannya ke antrean terdepan
+	staff := TransitionActor{UserID: "STF1", Role: structs.UserRoleStaff, KuaID: kua.ID}
+	if _, err := NewStatusTransitionService(db).Apply(&p, structs.StatusPendaftaranDibatalkan, staff, "Catin membatalkan"); err != nil {
+		t.Fatalf("Apply(batal) error = %v", err)
+	}
+	a := loadDaftarTunggu(t, db, antrean[0])
+	if a.Status != structs.DaftarTungguStatusDitawarkan || a.Waktu_tawaran == "" || a.Berlaku_sampai == nil {
+		t.Fatalf("entri A = %+v, want ditawarkan dengan slot", a)
+	}
+	var slot structs.SlotNikah
+	if err := db.Where("kua_id = ? AND tanggal = ? AND waktu = ?", kua.ID, tanggalStr, a.Waktu_tawaran).First(&slot).Error; err != nil ||
+		slot.Status != structs.SlotNikahStatusDitahan || slot.Ditahan_oleh != "CATIN3" {
+		t.Errorf("slot tawaran A = %+v, %v, want ditahan atas nama CATIN3", slot, err)
+	}
+	if b := loadDaftarTunggu(t, db, antrean[1]); b.Status != structs.DaftarTungguStatusMenunggu {
+		t.Errorf("entri B status = %q, want tetap menunggu selama kapasitas penuh", b.Status)
+	}
+
+	// Tawaran yang lewat batas diteruskan ke antrean berikutnya
+	db.Model(&structs.DaftarTunggu{}).Where("id = ?", a.ID).Update("berlaku_sampai", time.Now().Add(-time.Minute))
+	if err := ws.PromoteDate(kua.ID, tanggalStr); err != nil {
+		t.Fatalf("PromoteDate() error = %v", err)
+	}
+	if a = loadDaftarTunggu(t, db, antrean[0]); a.Status != structs.DaftarTungguStatusKedaluwarsa {
+		t.Errorf("entri A status = %q, want kedaluwarsa", a.Status)
+	}
+	b := loadDaftarTunggu(t, db, antrean[1])
+	if b.Status != structs.DaftarTungguStatusDitawarkan {
+		t.Fatalf("entri B status = %q, want ditawarkan setelah A kedaluwarsa", b.Status)
+	}
+
+	// Tawaran yang dibatalkan melepas slot dan langsung diteruskan
+	if _, err := ws.Cancel(fmt.Sprint(b.ID), "CATIN4"); err != nil {
+		t.Fatalf("Cancel(B) error = %v", err)
+	}
+	var ditahanB int64
+	db.Model(&structs.SlotNikah{}).Where("ditahan_oleh = ?", "CATIN4").Count(&ditahanB)
+	if ditahanB != 0 {
+		t.Errorf("slot milik B = %d, want dilepas", ditahanB)
+	}
+	c := loadDaftarTunggu(t, db, antrean[2])
+	if c.Status != structs.DaftarTungguStatusDitawarkan {
+		t.Fatalf("entri C status = %q, want ditawarkan setelah B batal", c.Status)
+	}
+
+	// Menerima tawaran memperpanjang penahanan slot untuk mengisi formulir
+	if _, err := ws.Accept(fmt.Sprint(c.ID), "CATIN4"); !errors.Is(err, ErrDaftarTungguNotFound) {
+		t.Errorf("Accept(milik user lain) error = %v, want ErrDaftarTungguNotFound", err)
+	}
+	accepted, err := ws.Accept(fmt.Sprint(c.ID), "CATIN5")
+	if err != nil || accepted.Status != structs.DaftarTungguStatusDiterima {
+		t.Fatalf("Accept(C) = %+v, %v, want diterima", accepted, err)
+	}
+	var slotC structs.SlotNikah
+	if err := db.Where("ditahan_oleh = ? AND status = ?", "CATIN5", structs.SlotNikahStatusDitahan).First(&slotC).Error; err != nil ||
+		slotC.Kedaluwarsa_pada == nil || slotC.Kedaluwarsa_pada.Before(time.Now().Add(WaitlistRegistrationDuration-time.Minute)) {
+		t.Errorf("slot C = %+v, %v, want ditahan selama WaitlistRegistrationDuration", slotC, err)
+	}
+	if _, err := ws.Cancel(fmt.Sprint(c.ID), "CATIN5"); !errors.Is(err, ErrDaftarTungguProcessed) {
+		t.Errorf("Cancel(sudah diterima) error = %v, want ErrDaftarTungguProcessed", err)
+	}
+}
+
+func TestWaitlistServiceExpireBookedOffer(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	tanggalStr := waitlistTestDate(&kua).Format("2006-01-02")
+	lewat := time.Now().Add(-time.Minute)
+	entry := structs.DaftarTunggu{
+		Kua_id:         kua.ID,
+		Jenis:          structs.DaftarTungguJenisTanggal,
+		Tanggal:        tanggalStr,
+		User_id:        "CATIN3",
+		Status:         structs.DaftarTungguStatusDitawarkan,
+		Waktu_tawaran:  "10:00",
+		Berlaku_sampai: &lewat,
+	}
+	db.Create(&entry)
+	p := createTestPendaftaran(t, db, structs.PendaftaranNikah{Kua_id: kua.ID, Pendaftar_id: "CATIN3", Waktu_nikah: "10:00"})
+	if err := BookSlot(db, kua.ID, tanggalStr, "10:00", "CATIN3", p.ID); err != nil {
+		t.Fatalf("BookSlot() error = %v", err)
+	}
+
+	// Catin yang sudah mendaftar dengan slot tawaran dianggap menerima walau batas waktunya lewat
+	if err := NewWaitlistService(db).PromoteDate(kua.ID, tanggalStr); err != nil {
+		t.Fatalf("PromoteDate() error = %v", err)
+	}
+	if got := loadDaftarTunggu(t, db, entry.ID); got.Status != structs.DaftarTungguStatusDiterima {
+		t.Errorf("entri status = %q, want diterima", got.Status)
+	}
+	var slot structs.SlotNikah
+	if err := db.Where("kua_id = ? AND tanggal = ? AND waktu = ?", kua.ID, tanggalStr, "10:00").First(&slot).Error; err != nil ||
+		slot.Status != structs.SlotNikahStatusTerpesan {
+		t.Errorf("slot = %+v, %v, want tetap terpesan", slot, err)
+	}
+}
+
+func TestWaitlistServiceClosesPastQueue(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	kemarin := TanggalLokal(time.Now(), locale.WITA).AddDate(0, 0, -1).Format("2006-01-02")
+	entry := structs.DaftarTunggu{
+		Kua_id:  kua.ID,
+		Jenis:   structs.DaftarTungguJenisTanggal,
+		Tanggal: kemarin,
+		User_id: "CATIN3",
+		Status:  structs.DaftarTungguStatusMenunggu,
+	}
+	db.Create(&entry)
+
+	if err := NewWaitlistService(db).PromoteDate(kua.ID, kemarin); err != nil {
+		t.Fatalf("PromoteDate() error = %v", err)
+	}
+	if got := loadDaftarTunggu(t, db, entry.ID); got.Status != structs.DaftarTungguStatusKedaluwarsa {
+		t.Errorf("entri tanggal lewat status = %q, want kedaluwarsa", got.Status)
+	}
+}
+
+func TestWaitlistServiceBimbingan(t *testing.T) {
+	db := newTestDB(t)
+	kua := createTestKUA(t, db, "KUA-BJM-UTARA", "Banjarmasin Utara", "Kota Banjarmasin", "Kalimantan Selatan")
+	b := structs.BimbinganPerkawinan{
+		Tanggal_bimbingan: waitlistTestDate(&kua),
+		Waktu_mulai:       "08:00",
+		Waktu_selesai:     "12:00",
+		Tempat_bimbingan:  "Aula KUA",
+		Pembimbing:        "Penyuluh",
+		Kapasitas:         1,
+		Status:            "Aktif",
+		Kua_id:            kua.ID,
+	}
+	db.Create(&b)
+	bimbinganID := fmt.Sprint(b.ID)
+
+	peserta := map[string]structs.PendaftaranNikah{}
+	for _, userID := range []string{"CATIN1", "CATIN3", "CATIN4"} {
+		peserta[userID] = createTestPendaftaran(t, db, structs.PendaftaranNikah{
+			Kua_id:             kua.ID,
+			Pendaftar_id:       userID,
+			Tempat_nikah:       "Di Luar KUA",
+			Status_pendaftaran: structs.StatusPendaftaranMenungguBimbingan,
+		})
+	}
+	db.Create(&structs.PendaftaranBimbingan{
+		Pendaftaran_nikah_id:    peserta["CATIN1"].ID,
+		Bimbingan_perkawinan_id: b.ID,
+		Status_kehadiran:        structs.PendaftaranBimbinganKehadiranBelum,
+		Status_sertifikat:       "Belum",
+	})
+
+	ws := NewWaitlistService(db)
+	a, err := ws.JoinBimbingan(bimbinganID, "CATIN3")
+	if err != nil {
+		t.Fatalf("JoinBimbingan(CATIN3) error = %v", err)
+	}
+	c, err := ws.JoinBimbingan(bimbinganID, "CATIN4")
+	if err != nil {
+		t.Fatalf("JoinBimbingan(CATIN4) error = %v", err)
+	}
+
+	// Peserta yang membatalkan pendaftaran melepas kursinya ke antrean terdepan
+	p := peserta["CATIN1"]
+	pemilik := TransitionActor{UserID: "CATIN1", Role: structs.UserRoleUserBiasa}
+	if _, err := NewStatusTransitionService(db).Apply(&p, structs.StatusPendaftaranDibatalkan, pemilik, "Berhalangan"); err != nil {
+		t.Fatalf("Apply(batal) error = %v", err)
+	}
+	if got := loadDaftarTunggu(t, db, a.ID); got.Status != structs.DaftarTungguStatusDitawarkan || got.Berlaku_sampai == nil {
+		t.Fatalf("entri CATIN3 = %+v, want ditawarkan", got)
+	}
+
+	// Kursi yang sedang ditawarkan tidak ditawarkan lagi ke antrean berikutnya
+	if err := ws.PromoteBimbingan(b.ID); err != nil {
+		t.Fatalf("PromoteBimbingan() error = %v", err)
+	}
+	if got := loadDaftarTunggu(t, db, c.ID); got.Status != structs.DaftarTungguStatusMenunggu {
+		t.Errorf("entri CATIN4 status = %q, want tetap menunggu", got.Status)
+	}
+
+	if _, err := ws.Accept(fmt.Sprint(a.ID), "CATIN3"); err != nil {
+		t.Fatalf("Accept(CATIN3) error = %v", err)
+	}
+	var terdaftar int64
+	db.Model(&structs.PendaftaranBimbingan{}).
+		Where("bimbingan_perkawinan_id = ? AND pendaftaran_nikah_id = ?", b.ID, peserta["CATIN3"].ID).Count(&terdaftar)
+	if terdaftar != 1 {
+		t.Errorf("pendaftaran bimbingan CATIN3 = %d, want 1", terdaftar)
+	}
+
+	// Tawaran yang tidak bisa dipenuhi saat diterima (kapasitas diturunkan) kembali ke antrean
+	db.Model(&b).Update("kapasitas", 2)
+	if err := ws.PromoteBimbingan(b.ID); err != nil {
+		t.Fatalf("PromoteBimbingan(kapasitas naik) error = %v", err)
+	}
+	if got := loadDaftarTunggu(t, db, c.ID); got.Status != structs.DaftarTungguStatusDitawarkan {
+		t.Fatalf("entri CATIN4 status = %q, want ditawarkan setelah kapasitas naik", got.Status)
+	}
+	db.Model(&b).Update("kapasitas", 1)
+	if _, err := ws.Accept(fmt.Sprint(c.ID), "CATIN4"); !errors.Is(err, ErrDaftarTungguInvalid) {
+		t.Errorf("Accept(sesi penuh) error = %v, want ErrDaftarTungguInvalid", err)
+	}
+	if got := loadDaftarTunggu(t, db, c.ID); got.Status != structs.DaftarTungguStatusMenunggu || got.Berlaku_sampai != nil {
+		t.Errorf("entri CATIN4 = %+v, want kembali menunggu", got)
+	}
+	db.Model(&structs.PendaftaranBimbingan{}).Where("bimbingan_perkawinan_id = ?", b.ID).Count(&terdaftar)
+	if terdaftar != 1 {
+		t.Errorf("peserta bimbingan = %d, want tidak melebihi kapasitas", terdaftar)
+	}
+
+	// Sesi yang dinonaktifkan menutup antreannya
+	db.Model(&b).Update("status", "Tidak Aktif")
+	if err := ws.PromoteBimbingan(b.ID); err != nil {
+		t.Fatalf("PromoteBimbingan(tidak aktif) error = %v", err)
+	}
+	if got := loadDaftarTunggu(t, db, c.ID); got.Status != structs.DaftarTungguStatusKedaluwarsa {
+		t.Errorf("entri CATIN4 status = %q, want kedaluwarsa setelah sesi dinonaktifkan", got.Status)
+	}
+}
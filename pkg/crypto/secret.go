@@ -0,0 +1,76 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// encryptedPrefix menandai nilai hasil EncryptString (versi format)
+const encryptedPrefix = "v1:"
+
+// DeriveKey menurunkan kunci AES-256 dari kunci rahasia konfigurasi (panjang bebas)
+func DeriveKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// EncryptString mengenkripsi plaintext dengan AES-256-GCM. Hasilnya "v1:" diikuti base64 dari
+// nonce dan ciphertext, aman disimpan di kolom teks.
+func EncryptString(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString membuka nilai hasil EncryptString. Error jika kunci salah atau nilai sudah diubah.
+func DecryptString(key []byte, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", errors.New("nilai tidak terenkripsi")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", errors.New("format nilai terenkripsi tidak valid")
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("format nilai terenkripsi tidak valid")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("gagal mendekripsi nilai")
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted mengecek apakah value dihasilkan EncryptString (bukan plaintext lama)
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("kunci enkripsi harus 32 byte")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
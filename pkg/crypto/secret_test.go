@@ -0,0 +1,36 @@
+package crypto
+
+import "testing"
+
+func TestEncryptString(t *testing.T) {
+	key := DeriveKey("kunci-rahasia")
+
+	sealed, err := EncryptString(key, "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("EncryptString() error = %v", err)
+	}
+	if !IsEncrypted(sealed) || sealed == "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("EncryptString() = %q, want nilai terenkripsi", sealed)
+	}
+	if again, _ := EncryptString(key, "JBSWY3DPEHPK3PXP"); again == sealed {
+		t.Error("EncryptString() dua kali menghasilkan nilai sama, nonce harus acak")
+	}
+
+	plain, err := DecryptString(key, sealed)
+	if err != nil || plain != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("DecryptString() = %q, %v", plain, err)
+	}
+
+	if _, err := DecryptString(DeriveKey("kunci-lain"), sealed); err == nil {
+		t.Error("DecryptString(kunci lain) error = nil")
+	}
+	if _, err := DecryptString(key, sealed[:len(sealed)-2]+"AA"); err == nil {
+		t.Error("DecryptString(diubah) error = nil")
+	}
+	if _, err := DecryptString(key, "JBSWY3DPEHPK3PXP"); err == nil {
+		t.Error("DecryptString(plaintext) error = nil")
+	}
+	if _, err := EncryptString([]byte("pendek"), "x"); err == nil {
+		t.Error("EncryptString(kunci bukan 32 byte) error = nil")
+	}
+}
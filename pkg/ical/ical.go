@@ -0,0 +1,169 @@
+// Package ical menulis kalender iCalendar (RFC 5545) untuk langganan feed jadwal.
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Status event (RFC 5545 3.8.1.11)
+const (
+	StatusTentative = "TENTATIVE"
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// maxLineOctets adalah panjang maksimal satu baris content sebelum dilipat (RFC 5545 3.1)
+const maxLineOctets = 75
+
+// Calendar adalah satu VCALENDAR beserta event-eventnya
+type Calendar struct {
+	ProdID  string // mis. "-//SimNikah//Jadwal Nikah//ID"
+	Name    string // X-WR-CALNAME, nama kalender di aplikasi klien
+	Refresh time.Duration
+	Events  []Event
+}
+
+// Event adalah satu VEVENT. Waktu selalu ditulis dalam UTC.
+type Event struct {
+	UID          string // stabil untuk event yang sama agar perubahan menimpa event lama di klien
+	Sequence     int    // dinaikkan setiap kali jadwal event berubah
+	Stamp        time.Time
+	LastModified time.Time
+	Start        time.Time
+	End          time.Time
+	Summary      string
+	Description  string
+	Location     string
+	Lat, Lon     *float64
+	Status       string
+	URL          string
+	Categories   []string
+}
+
+// WriteTo menulis kalender dengan akhir baris CRLF dan baris panjang dilipat
+func (c *Calendar) WriteTo(w io.Writer) (int64, error) {
+	lw := &lineWriter{w: w}
+	lw.line("BEGIN:VCALENDAR")
+	lw.line("VERSION:2.0")
+	lw.line("PRODID:" + c.ProdID)
+	lw.line("CALSCALE:GREGORIAN")
+	lw.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		lw.line("X-WR-CALNAME:" + EscapeText(c.Name))
+	}
+	if c.Refresh > 0 {
+		lw.line(fmt.Sprintf("REFRESH-INTERVAL;VALUE=DURATION:PT%dM", int(c.Refresh.Minutes())))
+		lw.line(fmt.Sprintf("X-PUBLISHED-TTL:PT%dM", int(c.Refresh.Minutes())))
+	}
+	for i := range c.Events {
+		c.Events[i].write(lw)
+	}
+	lw.line("END:VCALENDAR")
+	return lw.n, lw.err
+}
+
+// String mengembalikan kalender sebagai teks iCalendar
+func (c *Calendar) String() string {
+	var sb strings.Builder
+	c.WriteTo(&sb)
+	return sb.String()
+}
+
+func (e *Event) write(lw *lineWriter) {
+	lw.line("BEGIN:VEVENT")
+	lw.line("UID:" + e.UID)
+	lw.line("DTSTAMP:" + FormatTime(e.Stamp))
+	if !e.LastModified.IsZero() {
+		lw.line("LAST-MODIFIED:" + FormatTime(e.LastModified))
+	}
+	lw.line(fmt.Sprintf("SEQUENCE:%d", e.Sequence))
+	lw.line("DTSTART:" + FormatTime(e.Start))
+	if !e.End.IsZero() {
+		lw.line("DTEND:" + FormatTime(e.End))
+	}
+	lw.line("SUMMARY:" + EscapeText(e.Summary))
+	if e.Description != "" {
+		lw.line("DESCRIPTION:" + EscapeText(e.Description))
+	}
+	if e.Location != "" {
+		lw.line("LOCATION:" + EscapeText(e.Location))
+	}
+	if e.Lat != nil && e.Lon != nil {
+		lw.line(fmt.Sprintf("GEO:%.6f;%.6f", *e.Lat, *e.Lon))
+	}
+	if len(e.Categories) > 0 {
+		escaped := make([]string, len(e.Categories))
+		for i, category := range e.Categories {
+			escaped[i] = EscapeText(category)
+		}
+		lw.line("CATEGORIES:" + strings.Join(escaped, ","))
+	}
+	if e.URL != "" {
+		lw.line("URL:" + e.URL)
+	}
+	if e.Status != "" {
+		lw.line("STATUS:" + e.Status)
+	}
+	lw.line("END:VEVENT")
+}
+
+// FormatTime memformat waktu sebagai DATE-TIME UTC (mis. 20261102T020000Z)
+func FormatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// EscapeText meng-escape nilai TEXT: backslash, titik koma, koma, dan baris baru (RFC 5545 3.3.11)
+func EscapeText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// Fold melipat satu baris content menjadi potongan maksimal 75 oktet tanpa memotong karakter UTF-8.
+// Potongan lanjutan diawali satu spasi.
+func Fold(line string) string {
+	if len(line) <= maxLineOctets {
+		return line
+	}
+
+	var sb strings.Builder
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		sb.WriteString(line[:cut])
+		sb.WriteString("\r\n ")
+		line = line[cut:]
+		// Spasi pembuka ikut dihitung dalam 75 oktet baris lanjutan
+		limit = maxLineOctets - 1
+	}
+	sb.WriteString(line)
+	return sb.String()
+}
+
+// lineWriter menulis baris content yang sudah dilipat dan mencatat error pertama
+type lineWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (lw *lineWriter) line(s string) {
+	if lw.err != nil {
+		return
+	}
+	n, err := io.WriteString(lw.w, Fold(s)+"\r\n")
+	lw.n += int64(n)
+	lw.err = err
+}
@@ -0,0 +1,93 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEscapeText(t *testing.T) {
+	got := EscapeText("Jl. Merdeka No. 5, RT 01; Semarang\\Tengah\r\nLantai 2")
+	want := `Jl. Merdeka No. 5\, RT 01\; Semarang\\Tengah\nLantai 2`
+	if got != want {
+		t.Errorf("EscapeText() = %q, want %q", got, want)
+	}
+}
+
+func TestFold(t *testing.T) {
+	if got := Fold("SUMMARY:Akad nikah"); got != "SUMMARY:Akad nikah" {
+		t.Errorf("Fold() mengubah baris pendek: %q", got)
+	}
+
+	line := "DESCRIPTION:" + strings.Repeat("é", 80)
+	folded := Fold(line)
+	parts := strings.Split(folded, "\r\n")
+	if len(parts) < 2 {
+		t.Fatalf("Fold() tidak melipat baris %d oktet", len(line))
+	}
+	var unfolded strings.Builder
+	for i, part := range parts {
+		if len(part) > maxLineOctets {
+			t.Errorf("potongan %d panjangnya %d oktet, maksimal %d", i, len(part), maxLineOctets)
+		}
+		if i > 0 {
+			if !strings.HasPrefix(part, " ") {
+				t.Fatalf("potongan lanjutan %d tidak diawali spasi: %q", i, part)
+			}
+			part = part[1:]
+		}
+		if !strings.HasPrefix(part, "é") && i > 0 {
+			t.Errorf("potongan %d memotong karakter UTF-8: %q", i, part)
+		}
+		unfolded.WriteString(part)
+	}
+	if unfolded.String() != line {
+		t.Errorf("baris setelah unfold berbeda dari aslinya")
+	}
+}
+
+func TestCalendarWriteTo(t *testing.T) {
+	wib := time.FixedZone("WIB", 7*60*60)
+	lat, lon := -6.966667, 110.416664
+	cal := Calendar{
+		ProdID:  "-//SimNikah//Jadwal Nikah//ID",
+		Name:    "Jadwal Nikah",
+		Refresh: time.Hour,
+		Events: []Event{
+			{
+				UID:      "pendaftaran-12@simnikah",
+				Sequence: 2,
+				Stamp:    time.Date(2026, 10, 1, 8, 0, 0, 0, wib),
+				Start:    time.Date(2026, 11, 2, 9, 0, 0, 0, wib),
+				End:      time.Date(2026, 11, 2, 10, 0, 0, 0, wib),
+				Summary:  "Akad Nikah NIK/2026/001",
+				Location: "Jl. Pandanaran, Semarang",
+				Lat:      &lat,
+				Lon:      &lon,
+				Status:   StatusCancelled,
+			},
+		},
+	}
+
+	got := cal.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"X-WR-CALNAME:Jadwal Nikah\r\n",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT60M\r\n",
+		"UID:pendaftaran-12@simnikah\r\n",
+		"SEQUENCE:2\r\n",
+		"DTSTAMP:20261001T010000Z\r\n",
+		"DTSTART:20261102T020000Z\r\n",
+		"DTEND:20261102T030000Z\r\n",
+		"LOCATION:Jl. Pandanaran\\, Semarang\r\n",
+		"GEO:-6.966667;110.416664\r\n",
+		"STATUS:CANCELLED\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("kalender tidak memuat %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(strings.ReplaceAll(got, "\r\n", ""), "\n") {
+		t.Errorf("kalender memuat LF tanpa CR")
+	}
+}
@@ -0,0 +1,72 @@
+package locale
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+var namaBulanHijriah = [...]string{
+	"Muharram", "Safar", "Rabiul Awal", "Rabiul Akhir", "Jumadil Awal", "Jumadil Akhir",
+	"Rajab", "Syakban", "Ramadan", "Syawal", "Zulkaidah", "Zulhijah",
+}
+
+// epochHijriah adalah 1 Muharram 1 H (16 Juli 622 kalender Julian) dalam kalender Gregorian proleptik
+var epochHijriah = time.Date(622, time.July, 19, 0, 0, 0, 0, time.UTC)
+
+// TanggalHijriah adalah tanggal kalender Hijriah
+type TanggalHijriah struct {
+	Tahun int
+	Bulan int // 1 = Muharram
+	Hari  int
+}
+
+// Hijriah mengonversi tanggal kalender t ke kalender Hijriah tabular (siklus 30 tahun dengan
+// tahun kabisat 2, 5, 7, 10, 13, 16, 18, 21, 24, 26, 29). Hasilnya bisa selisih satu sampai dua
+// hari dari penetapan Kementerian Agama berdasarkan rukyat/hisab, sehingga cukup untuk keterangan
+// di dokumen tetapi bukan penentu awal bulan ibadah.
+func Hijriah(t time.Time) TanggalHijriah {
+	tanggal := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	n := int((tanggal.Unix() - epochHijriah.Unix()) / 86400)
+
+	tahun := int(math.Floor((30*float64(n) + 10646) / 10631))
+	bulan := int(math.Ceil(float64(n-29-hariHijriah(tahun, 1, 1))/29.5)) + 1
+	if bulan > 12 {
+		bulan = 12
+	}
+	if bulan < 1 {
+		bulan = 1
+	}
+	return TanggalHijriah{Tahun: tahun, Bulan: bulan, Hari: n - hariHijriah(tahun, bulan, 1) + 1}
+}
+
+// hariHijriah mengembalikan jumlah hari sejak 1 Muharram 1 H
+func hariHijriah(tahun, bulan, hari int) int {
+	return hari - 1 + (59*(bulan-1)+1)/2 + (tahun-1)*354 + (3+11*tahun)/30
+}
+
+// Gregorian mengonversi tanggal Hijriah kembali ke tanggal Gregorian (UTC tengah malam)
+func (h TanggalHijriah) Gregorian() time.Time {
+	return epochHijriah.AddDate(0, 0, hariHijriah(h.Tahun, h.Bulan, h.Hari))
+}
+
+// NamaBulan mengembalikan nama bulan Hijriah, mis. "Ramadan"
+func (h TanggalHijriah) NamaBulan() string {
+	if h.Bulan < 1 || h.Bulan > 12 {
+		return ""
+	}
+	return namaBulanHijriah[h.Bulan-1]
+}
+
+// String menulis tanggal Hijriah, mis. "1 Ramadan 1447 H"
+func (h TanggalHijriah) String() string {
+	return fmt.Sprintf("%d %s %d H", h.Hari, h.NamaBulan(), h.Tahun)
+}
+
+// TanggalHijriahTeks menulis tanggal Hijriah dari tanggal kalender t; waktu nol menjadi string kosong
+func TanggalHijriahTeks(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return Hijriah(t).String()
+}
@@ -0,0 +1,103 @@
+// Package locale memformat tanggal dan jam dalam bahasa Indonesia.
+//
+// time.Format hanya mengenal nama bulan dan hari bahasa Inggris: layout "02 Januari 2006" selalu
+// menulis "Januari" apa pun bulannya dan "Monday" menghasilkan nama hari bahasa Inggris. Semua teks
+// untuk pengguna (notifikasi, undangan, dan PDF) memakai fungsi di paket ini.
+package locale
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	namaBulan = [...]string{"Januari", "Februari", "Maret", "April", "Mei", "Juni", "Juli", "Agustus", "September", "Oktober", "November", "Desember"}
+	namaHari  = [...]string{"Minggu", "Senin", "Selasa", "Rabu", "Kamis", "Jumat", "Sabtu"}
+)
+
+// Bulan mengembalikan nama bulan, mis. "Januari"
+func Bulan(m time.Month) string {
+	if m < time.January || m > time.December {
+		return ""
+	}
+	return namaBulan[m-1]
+}
+
+// Hari mengembalikan nama hari, mis. "Senin"
+func Hari(d time.Weekday) string {
+	if d < time.Sunday || d > time.Saturday {
+		return ""
+	}
+	return namaHari[d]
+}
+
+// Tanggal menulis tanggal kalender t, mis. "2 Januari 2026"; waktu nol menjadi string kosong.
+// Tanggal dibaca di zona waktu t apa adanya (kolom tanggal di database tidak dikonversi).
+func Tanggal(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%d %s %d", t.Day(), Bulan(t.Month()), t.Year())
+}
+
+// HariTanggal menulis tanggal beserta nama hari, mis. "Jumat, 2 Januari 2026"
+func HariTanggal(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return Hari(t.Weekday()) + ", " + Tanggal(t)
+}
+
+// BulanTahun menulis bulan dan tahun, mis. "Januari 2026"
+func BulanTahun(t time.Time) string {
+	return Bulan(t.Month()) + " " + strconv.Itoa(t.Year())
+}
+
+// Jam menulis jam t dengan titik sebagai pemisah, mis. "09.00 WIB". Singkatan zona ditulis
+// jika t berada di WIB, WITA, atau WIT; zona lain tidak diberi keterangan.
+func Jam(t time.Time) string {
+	s := t.Format("15.04")
+	if nama := NamaZona(t.Location()); nama != "" {
+		s += " " + nama
+	}
+	return s
+}
+
+// TanggalJam menulis hari, tanggal, dan jam, mis. "Jumat, 2 Januari 2026 pukul 09.00 WIB"
+func TanggalJam(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return HariTanggal(t) + " pukul " + Jam(t)
+}
+
+// FormatTanggal menulis tanggal berformat "2006-01-02" (seperti kolom tanggal bertipe string),
+// mis. "2026-01-02" menjadi "2 Januari 2026". Teks yang bukan tanggal valid dikembalikan apa adanya.
+func FormatTanggal(yyyymmdd string) string {
+	t, err := time.Parse("2006-01-02", strings.TrimSpace(yyyymmdd))
+	if err != nil {
+		return yyyymmdd
+	}
+	return Tanggal(t)
+}
+
+// FormatJam menulis jam berformat "HH:MM" (seperti Waktu_nikah) sebagai jam di zona loc,
+// mis. "09.00 WITA". Teks yang bukan jam valid dikembalikan apa adanya.
+func FormatJam(hhmm string, loc *time.Location) string {
+	jam, err := time.Parse("15:04", strings.TrimSpace(hhmm))
+	if err != nil {
+		return hhmm
+	}
+	s := jam.Format("15.04")
+	if nama := NamaZona(loc); nama != "" {
+		s += " " + nama
+	}
+	return s
+}
+
+// RentangJam menulis rentang jam "HH:MM", mis. "08.00 - 12.00 WIB"
+func RentangJam(mulai, selesai string, loc *time.Location) string {
+	return FormatJam(mulai, nil) + " - " + FormatJam(selesai, loc)
+}
@@ -0,0 +1,85 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTanggal(t *testing.T) {
+	d := time.Date(2026, time.October, 18, 9, 5, 0, 0, WIB)
+	tests := []struct {
+		name, got, want string
+	}{
+		{"Tanggal", Tanggal(d), "18 Oktober 2026"},
+		{"HariTanggal", HariTanggal(d), "Minggu, 18 Oktober 2026"},
+		{"BulanTahun", BulanTahun(d), "Oktober 2026"},
+		{"Jam", Jam(d), "09.05 WIB"},
+		{"Jam WITA", Jam(d.In(WITA)), "10.05 WITA"},
+		{"Jam UTC", Jam(d.UTC()), "02.05"},
+		{"TanggalJam", TanggalJam(d.In(WIT)), "Minggu, 18 Oktober 2026 pukul 11.05 WIT"},
+		{"FormatTanggal", FormatTanggal("2026-01-02"), "2 Januari 2026"},
+		{"FormatTanggal invalid", FormatTanggal("besok"), "besok"},
+		{"FormatJam", FormatJam("08:30", WITA), "08.30 WITA"},
+		{"FormatJam invalid", FormatJam("pagi", WIB), "pagi"},
+		{"RentangJam", RentangJam("08:00", "12:00", WIB), "08.00 - 12.00 WIB"},
+		{"Tanggal nol", Tanggal(time.Time{}), ""},
+		{"Hari invalid", Hari(time.Weekday(9)), ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestZonaProvinsi(t *testing.T) {
+	tests := map[string]*time.Location{
+		"Jawa Barat":           WIB,
+		"":                     WIB,
+		"Provinsi Bali":        WITA,
+		"sulawesi  selatan":    WITA,
+		"Kalimantan Barat":     WIB,
+		"Kalimantan Timur":     WITA,
+		"Papua Barat Daya":     WIT,
+		"MALUKU UTARA":         WIT,
+		"Daerah Tidak Dikenal": WIB,
+	}
+	for provinsi, want := range tests {
+		if got := ZonaProvinsi(provinsi); got != want {
+			t.Errorf("ZonaProvinsi(%q) = %s, want %s", provinsi, got, want)
+		}
+	}
+	if loc, ok := ParseZona("wita"); !ok || loc != WITA {
+		t.Errorf("ParseZona(wita) = %v, %v", loc, ok)
+	}
+	if _, ok := ParseZona("UTC"); ok {
+		t.Errorf("ParseZona(UTC) harus gagal")
+	}
+}
+
+func TestHijriah(t *testing.T) {
+	tests := []struct {
+		tanggal time.Time
+		want    string
+	}{
+		{time.Date(622, time.July, 19, 0, 0, 0, 0, time.UTC), "1 Muharram 1 H"},
+		{time.Date(2024, time.March, 11, 0, 0, 0, 0, time.UTC), "1 Ramadan 1445 H"},
+		{time.Date(2025, time.March, 30, 0, 0, 0, 0, time.UTC), "30 Ramadan 1446 H"},
+		{time.Date(2026, time.February, 18, 0, 0, 0, 0, time.UTC), "1 Ramadan 1447 H"},
+		// Jam dan zona diabaikan: yang dikonversi adalah tanggal kalendernya
+		{time.Date(2026, time.October, 18, 23, 30, 0, 0, WIT), "6 Jumadil Awal 1448 H"},
+	}
+	for _, tt := range tests {
+		if got := TanggalHijriahTeks(tt.tanggal); got != tt.want {
+			t.Errorf("Hijriah(%s) = %q, want %q", tt.tanggal.Format("2006-01-02"), got, tt.want)
+		}
+	}
+
+	// Konversi bolak-balik untuk setiap hari selama beberapa siklus 30 tahun
+	for d := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC); d.Year() < 2090; d = d.AddDate(0, 0, 1) {
+		h := Hijriah(d)
+		if h.Hari < 1 || h.Hari > 30 || !h.Gregorian().Equal(d) {
+			t.Fatalf("Hijriah(%s) = %+v, kembali ke %s", d.Format("2006-01-02"), h, h.Gregorian().Format("2006-01-02"))
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package locale
+
+import (
+	"strings"
+	"time"
+)
+
+// Zona waktu Indonesia. Ketiganya tidak mengenal daylight saving sehingga cukup memakai zona tetap
+// (tidak bergantung pada tzdata di server).
+var (
+	WIB  = time.FixedZone("WIB", 7*60*60)
+	WITA = time.FixedZone("WITA", 8*60*60)
+	WIT  = time.FixedZone("WIT", 9*60*60)
+)
+
+// provinsiWITA dan provinsiWIT adalah provinsi di luar WIB (huruf kecil, tanpa awalan "provinsi")
+var (
+	provinsiWITA = map[string]bool{
+		"bali": true, "nusa tenggara barat": true, "nusa tenggara timur": true,
+		"kalimantan selatan": true, "kalimantan timur": true, "kalimantan utara": true,
+		"sulawesi utara": true, "sulawesi tengah": true, "sulawesi selatan": true,
+		"sulawesi tenggara": true, "sulawesi barat": true, "gorontalo": true,
+	}
+	provinsiWIT = map[string]bool{
+		"maluku": true, "maluku utara": true, "papua": true, "papua barat": true,
+		"papua barat daya": true, "papua selatan": true, "papua tengah": true, "papua pegunungan": true,
+	}
+)
+
+// ZonaProvinsi mengembalikan zona waktu provinsi, mis. "Bali" -> WITA. Provinsi yang tidak
+// dikenal (termasuk kosong) dianggap WIB.
+func ZonaProvinsi(provinsi string) *time.Location {
+	p := strings.ToLower(strings.Join(strings.Fields(provinsi), " "))
+	p = strings.TrimPrefix(p, "provinsi ")
+	switch {
+	case provinsiWITA[p]:
+		return WITA
+	case provinsiWIT[p]:
+		return WIT
+	default:
+		return WIB
+	}
+}
+
+// ParseZona mengembalikan zona dari singkatannya ("WIB", "WITA", "WIT"; tidak peka huruf besar)
+func ParseZona(nama string) (*time.Location, bool) {
+	switch strings.ToUpper(strings.TrimSpace(nama)) {
+	case "WIB":
+		return WIB, true
+	case "WITA":
+		return WITA, true
+	case "WIT":
+		return WIT, true
+	}
+	return nil, false
+}
+
+// NamaZona mengembalikan singkatan zona Indonesia untuk loc, atau string kosong jika loc bukan
+// WIB/WITA/WIT. Lokasi tzdata (Asia/Jakarta, Asia/Makassar, Asia/Jayapura) juga dikenali.
+func NamaZona(loc *time.Location) string {
+	if loc == nil {
+		return ""
+	}
+	switch loc.String() {
+	case "WIB", "Asia/Jakarta", "Asia/Pontianak":
+		return "WIB"
+	case "WITA", "Asia/Makassar":
+		return "WITA"
+	case "WIT", "Asia/Jayapura":
+		return "WIT"
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer adalah antarmuka pengiriman email (SMTP di production, FakeMailer untuk test)
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// Message adalah email yang dikirim lewat FakeMailer
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	SentAt  time.Time
+}
+
+// ErrSMTPNotConfigured dikembalikan NewFromEnv jika SMTP_HOST kosong di luar mode pengembangan
+var ErrSMTPNotConfigured = errors.New("SMTP_HOST tidak diset")
+
+// NewFromEnv membuat Mailer dari environment variable SMTP_*.
+// Jika SMTP_HOST tidak diset, mode pengembangan (allowFake) memakai FakeMailer yang hanya menyimpan
+// email di memori; di luar mode pengembangan dikembalikan ErrSMTPNotConfigured agar server tidak
+// berjalan tanpa bisa mengirim kode reset password.
+func NewFromEnv(allowFake bool) (Mailer, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		if !allowFake {
+			return nil, ErrSMTPNotConfigured
+		}
+		log.Println("Warning: SMTP_HOST tidak diset, email tidak akan dikirim. Set SMTP_* environment variable di production.")
+		return NewFakeMailer(), nil
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = os.Getenv("SMTP_USERNAME")
+	}
+
+	return &SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}, nil
+}
+
+// ==================== SMTP ====================
+
+// SMTPMailer mengirim email lewat server SMTP (STARTTLS jika didukung server)
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send mengirim email plain text
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := m.Host + ":" + m.Port
+	if err := smtp.SendMail(addr, auth, m.From, []string{to}, buildMessage(m.From, to, subject, body)); err != nil {
+		return fmt.Errorf("gagal mengirim email ke %s: %v", to, err)
+	}
+	return nil
+}
+
+// buildMessage menyusun email RFC 5322 sederhana (UTF-8, plain text)
+func buildMessage(from, to, subject, body string) []byte {
+	var sb strings.Builder
+	sb.WriteString("From: " + from + "\r\n")
+	sb.WriteString("To: " + to + "\r\n")
+	sb.WriteString("Subject: " + subject + "\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(sb.String())
+}
+
+// ==================== FAKE (IN-MEMORY) ====================
+
+// FakeMailer menyimpan email di memori, dipakai untuk test dan development tanpa SMTP
+type FakeMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewFakeMailer membuat FakeMailer kosong
+func NewFakeMailer() *FakeMailer {
+	return &FakeMailer{}
+}
+
+// Send menyimpan email ke memori
+func (m *FakeMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, Message{To: to, Subject: subject, Body: body, SentAt: time.Now()})
+	return nil
+}
+
+// Messages mengembalikan salinan semua email yang sudah dikirim
+func (m *FakeMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Message, len(m.messages))
+	copy(out, m.messages)
+	return out
+}
+
+// LastMessageTo mengembalikan email terakhir untuk alamat tertentu
+func (m *FakeMailer) LastMessageTo(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i], true
+		}
+	}
+	return Message{}, false
+}
@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFakeMailer(t *testing.T) {
+	m := NewFakeMailer()
+
+	if _, found := m.LastMessageTo("budi@example.com"); found {
+		t.Error("Expected no message before Send")
+	}
+
+	m.Send("budi@example.com", "Kode 1", "111111")
+	m.Send("siti@example.com", "Kode", "222222")
+	m.Send("budi@example.com", "Kode 2", "333333")
+
+	if len(m.Messages()) != 3 {
+		t.Errorf("Expected 3 messages, got %d", len(m.Messages()))
+	}
+
+	msg, found := m.LastMessageTo("budi@example.com")
+	if !found {
+		t.Fatal("Expected to find message for budi@example.com")
+	}
+	if msg.Subject != "Kode 2" || msg.Body != "333333" {
+		t.Errorf("Expected last message to budi, got %+v", msg)
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	raw := string(buildMessage("kua@example.com", "budi@example.com", "Reset Password", "Baris 1\nBaris 2"))
+
+	for _, header := range []string{"From: kua@example.com\r\n", "To: budi@example.com\r\n", "Subject: Reset Password\r\n"} {
+		if !strings.Contains(raw, header) {
+			t.Errorf("Expected header %q in message", header)
+		}
+	}
+
+	if !strings.HasSuffix(raw, "\r\n\r\nBaris 1\r\nBaris 2") {
+		t.Errorf("Expected CRLF body, got %q", raw)
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+
+	if _, err := NewFromEnv(false); err != ErrSMTPNotConfigured {
+		t.Errorf("Expected ErrSMTPNotConfigured outside development, got %v", err)
+	}
+	if m, err := NewFromEnv(true); err != nil {
+		t.Errorf("Expected FakeMailer in development, got error %v", err)
+	} else if _, ok := m.(*FakeMailer); !ok {
+		t.Errorf("Expected FakeMailer in development, got %T", m)
+	}
+
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_USERNAME", "kua@example.com")
+	t.Setenv("SMTP_FROM", "")
+	m, err := NewFromEnv(false)
+	if err != nil {
+		t.Fatalf("Expected SMTPMailer, got error %v", err)
+	}
+	smtpMailer, ok := m.(*SMTPMailer)
+	if !ok || smtpMailer.Port != "587" || smtpMailer.From != "kua@example.com" {
+		t.Errorf("Expected SMTPMailer with default port and from, got %+v", m)
+	}
+}
@@ -0,0 +1,83 @@
+package pdf
+
+// Font adalah salah satu font standar PDF (Type1) yang tidak perlu di-embed
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+	HelveticaOblique
+)
+
+var fontNames = [...]string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique"}
+
+// Lebar karakter ASCII 32..126 dalam 1/1000 em (Adobe Font Metrics Helvetica dan Helvetica-Bold).
+// Helvetica-Oblique memakai lebar Helvetica.
+var (
+	widthsHelvetica = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // ' ' .. '/'
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // '0' .. '?'
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // '@' .. 'O'
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // 'P' .. '_'
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // '`' .. 'o'
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // 'p' .. '~'
+	}
+	widthsHelveticaBold = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// charWidth mengembalikan lebar satu byte WinAnsi dalam 1/1000 em
+func charWidth(f Font, b byte) int {
+	widths := &widthsHelvetica
+	if f == HelveticaBold {
+		widths = &widthsHelveticaBold
+	}
+	if b >= 32 && b <= 126 {
+		return widths[b-32]
+	}
+	if b == 0xA0 {
+		return widths[0]
+	}
+	// Huruf beraksen dan tanda baca Latin-1 kira-kira selebar huruf kecil
+	return 556
+}
+
+// winAnsi mengubah teks UTF-8 ke WinAnsiEncoding; karakter di luar encoding diganti "?"
+func winAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			out = append(out, ' ')
+		case r >= 32 && r <= 126, r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		default:
+			if b, ok := winAnsiExtra[r]; ok {
+				out = append(out, b)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+// winAnsiExtra memetakan karakter umum di rentang 0x80..0x9F WinAnsiEncoding
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+}
+
+// StringWidth mengembalikan lebar teks dalam mm untuk font dan ukuran (pt) tertentu
+func StringWidth(f Font, size float64, s string) float64 {
+	total := 0
+	for _, b := range winAnsi(s) {
+		total += charWidth(f, b)
+	}
+	return float64(total) * size / 1000 / ptPerMM
+}
@@ -0,0 +1,149 @@
+package pdf
+
+import "strings"
+
+// Layout menulis blok-blok teks dari atas ke bawah dengan margin tetap dan pindah halaman otomatis.
+// Dipakai untuk surat dan formulir yang isinya mengalir seperti dokumen ketikan.
+type Layout struct {
+	Doc                      *Document
+	Page                     *Page
+	Left, Right, Top, Bottom float64 // margin dalam mm
+	Y                        float64 // posisi baseline berikutnya dari atas halaman
+	Leading                  float64 // jarak antar baris sebagai kelipatan ukuran font
+}
+
+// NewLayout membuat layout A4 dengan margin 20 mm dan langsung menambah halaman pertama
+func NewLayout(d *Document) *Layout {
+	l := &Layout{Doc: d, Left: 20, Right: 20, Top: 20, Bottom: 20, Leading: 1.35}
+	l.NewPage()
+	return l
+}
+
+// NewPage pindah ke halaman baru
+func (l *Layout) NewPage() {
+	l.Page = l.Doc.AddPage()
+	l.Y = l.Top
+}
+
+// Width mengembalikan lebar area tulis
+func (l *Layout) Width() float64 {
+	return l.Page.Width - l.Left - l.Right
+}
+
+// Space menambah jarak kosong vertikal (mm)
+func (l *Layout) Space(mm float64) {
+	l.Y += mm
+}
+
+// Ensure pindah halaman jika sisa halaman kurang dari h mm
+func (l *Layout) Ensure(h float64) {
+	if l.Y+h > l.Page.Height-l.Bottom {
+		l.NewPage()
+	}
+}
+
+// lineHeight mengembalikan tinggi satu baris dalam mm
+func (l *Layout) lineHeight(size float64) float64 {
+	return size * l.Leading / ptPerMM
+}
+
+// Paragraph menulis teks yang dibungkus selebar area tulis
+func (l *Layout) Paragraph(f Font, size float64, s string, align Align) {
+	l.ParagraphAt(l.Left, l.Width(), f, size, s, align)
+}
+
+// ParagraphAt menulis teks yang dibungkus di kolom mulai x selebar w
+func (l *Layout) ParagraphAt(x, w float64, f Font, size float64, s string, align Align) {
+	lh := l.lineHeight(size)
+	for _, line := range Wrap(f, size, s, w) {
+		l.Ensure(lh)
+		l.Y += lh
+		l.Page.TextAlign(x, l.Y, w, f, size, line, align)
+	}
+}
+
+// Field menulis baris isian "label : nilai"; nilai yang panjang dibungkus di kolom nilai
+func (l *Layout) Field(label, value string, indent, labelWidth float64, size float64) {
+	lh := l.lineHeight(size)
+	x := l.Left + indent
+	valueX := x + labelWidth + 4
+	lines := Wrap(Helvetica, size, value, l.Left+l.Width()-valueX)
+	l.Ensure(lh)
+	l.Y += lh
+	l.Page.Text(x, l.Y, Helvetica, size, label)
+	l.Page.Text(x+labelWidth, l.Y, Helvetica, size, ":")
+	for i, line := range lines {
+		if i > 0 {
+			l.Ensure(lh)
+			l.Y += lh
+		}
+		l.Page.Text(valueX, l.Y, Helvetica, size, line)
+	}
+}
+
+// Rule menggambar garis horizontal selebar area tulis
+func (l *Layout) Rule(width float64) {
+	l.Y += 2
+	l.Page.Line(l.Left, l.Y, l.Left+l.Width(), l.Y, width)
+	l.Y += 1
+}
+
+// Signature adalah satu kolom tanda tangan: baris keterangan di atas, nama di bawah ruang tanda tangan
+type Signature struct {
+	Lines []string
+	Name  string
+}
+
+// Signatures menulis kolom tanda tangan berdampingan dengan lebar sama
+func (l *Layout) Signatures(cols []Signature, size float64) {
+	if len(cols) == 0 {
+		return
+	}
+	lh := l.lineHeight(size)
+	rows := 0
+	for _, c := range cols {
+		if len(c.Lines) > rows {
+			rows = len(c.Lines)
+		}
+	}
+	l.Ensure(float64(rows+1)*lh + 20)
+
+	w := l.Width() / float64(len(cols))
+	top := l.Y
+	for i, c := range cols {
+		x := l.Left + float64(i)*w
+		y := top
+		for _, line := range c.Lines {
+			y += lh
+			l.Page.TextAlign(x, y, w, Helvetica, size, line, AlignCenter)
+		}
+		if c.Name == "" {
+			continue
+		}
+		y = top + float64(rows)*lh + 20 + lh
+		l.Page.TextAlign(x, y, w, HelveticaBold, size, c.Name, AlignCenter)
+		nameW := StringWidth(HelveticaBold, size, c.Name)
+		l.Page.Line(x+(w-nameW)/2, y+0.8, x+(w+nameW)/2, y+0.8, 0.2)
+	}
+	l.Y = top + float64(rows)*lh + 20 + lh + 1
+}
+
+// Wrap memecah teks per kata agar setiap baris tidak lebih lebar dari width (mm).
+// Kata yang lebih panjang dari width tetap ditulis utuh dalam satu baris.
+func Wrap(f Font, size float64, s string, width float64) []string {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return []string{""}
+	}
+	var lines []string
+	line := words[0]
+	for _, word := range words[1:] {
+		if StringWidth(f, size, line+" "+word) > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line += " " + word
+	}
+	return append(lines, line)
+}
@@ -0,0 +1,201 @@
+// Package pdf menulis dokumen PDF 1.4 sederhana (teks, garis, kotak) dengan font standar Helvetica
+// tanpa dependensi eksternal. Keluaran deterministik: dokumen yang sama selalu menghasilkan byte yang
+// sama (tanpa tanggal pembuatan atau ID acak), sehingga bisa diuji dengan golden file.
+//
+// Semua koordinat dalam milimeter dari pojok kiri atas halaman; y teks adalah posisi baseline.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Ukuran kertas A4 dalam mm
+const (
+	A4Width  = 210.0
+	A4Height = 297.0
+)
+
+const ptPerMM = 72 / 25.4
+
+// Align adalah perataan teks terhadap kotak selebar w
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// Document adalah kumpulan halaman beserta metadata
+type Document struct {
+	Title   string
+	Author  string
+	Subject string
+	pages   []*Page
+}
+
+// New membuat dokumen kosong
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage menambah halaman A4 tegak
+func (d *Document) AddPage() *Page {
+	p := &Page{Width: A4Width, Height: A4Height}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Pages mengembalikan jumlah halaman
+func (d *Document) Pages() int {
+	return len(d.pages)
+}
+
+// Append menyalin semua halaman dokumen lain ke akhir dokumen ini (mis. menggabungkan beberapa surat)
+func (d *Document) Append(other *Document) {
+	for _, p := range other.pages {
+		cp := &Page{Width: p.Width, Height: p.Height}
+		cp.content.Write(p.content.Bytes())
+		d.pages = append(d.pages, cp)
+	}
+}
+
+// Page adalah satu halaman; isi halaman ditulis sebagai content stream PDF
+type Page struct {
+	Width, Height float64 // mm
+	content       bytes.Buffer
+}
+
+// Text menulis teks satu baris dengan baseline di (x, y)
+func (p *Page) Text(x, y float64, f Font, size float64, s string) {
+	if s == "" {
+		return
+	}
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		int(f)+1, num(size), num(x*ptPerMM), num((p.Height-y)*ptPerMM), escape(winAnsi(s)))
+}
+
+// TextAlign menulis teks satu baris yang diratakan di dalam kotak mulai x selebar w
+func (p *Page) TextAlign(x, y, w float64, f Font, size float64, s string, align Align) {
+	switch align {
+	case AlignCenter:
+		x += (w - StringWidth(f, size, s)) / 2
+	case AlignRight:
+		x += w - StringWidth(f, size, s)
+	}
+	p.Text(x, y, f, size, s)
+}
+
+// Line menggambar garis dengan ketebalan width (mm)
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n",
+		num(width*ptPerMM), num(x1*ptPerMM), num((p.Height-y1)*ptPerMM), num(x2*ptPerMM), num((p.Height-y2)*ptPerMM))
+}
+
+// Rect menggambar kotak dengan pojok kiri atas (x, y); fill true mengisi kotak hitam, false hanya garis tepi
+func (p *Page) Rect(x, y, w, h float64, fill bool) {
+	op := "S"
+	if fill {
+		op = "f"
+	}
+	fmt.Fprintf(&p.content, "%s %s %s %s re %s\n",
+		num(x*ptPerMM), num((p.Height-y-h)*ptPerMM), num(w*ptPerMM), num(h*ptPerMM), op)
+}
+
+// WriteTo menulis dokumen PDF lengkap ke w
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	pw := &pdfWriter{}
+	pw.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Nomor objek: 1 katalog, 2 pohon halaman, 3 info, 4.. font, lalu pasangan halaman + content
+	fontObj := 4
+	pageObj := fontObj + len(fontNames)
+
+	pw.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageObj+2*i)
+	}
+	pw.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	info := "<< /Producer (SimNikah)"
+	for _, kv := range [][2]string{{"Title", d.Title}, {"Author", d.Author}, {"Subject", d.Subject}} {
+		if kv[1] != "" {
+			info += fmt.Sprintf(" /%s (%s)", kv[0], escape(winAnsi(kv[1])))
+		}
+	}
+	pw.object(3, info+" >>")
+
+	fonts := make([]string, len(fontNames))
+	for i, name := range fontNames {
+		pw.object(fontObj+i, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+		fonts[i] = fmt.Sprintf("/F%d %d 0 R", i+1, fontObj+i)
+	}
+
+	for i, p := range d.pages {
+		obj := pageObj + 2*i
+		pw.object(obj, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			num(p.Width*ptPerMM), num(p.Height*ptPerMM), strings.Join(fonts, " "), obj+1))
+		pw.object(obj+1, fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.Bytes()))
+	}
+
+	xref := pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1)
+	for _, off := range pw.offsets {
+		fmt.Fprintf(&pw.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&pw.buf, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pw.offsets)+1, xref)
+
+	n, err := w.Write(pw.buf.Bytes())
+	return int64(n), err
+}
+
+// Bytes mengembalikan dokumen PDF lengkap
+func (d *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	d.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+// pdfWriter mencatat offset setiap objek untuk tabel xref; objek harus ditulis berurutan mulai 1
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (pw *pdfWriter) object(n int, body string) {
+	pw.offsets = append(pw.offsets, pw.buf.Len())
+	fmt.Fprintf(&pw.buf, "%d 0 obj\n%s\nendobj\n", n, body)
+}
+
+// num menulis angka dengan paling banyak dua desimal tanpa nol di belakang
+func num(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// escape menulis string literal PDF; byte di luar ASCII ditulis oktal agar file tetap teks
+func escape(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < 32 || c > 126:
+			fmt.Fprintf(&sb, "\\%03o", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,97 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDocumentXref(t *testing.T) {
+	doc := New()
+	doc.Title = "Uji (kurung)"
+	p := doc.AddPage()
+	p.Text(20, 30, HelveticaBold, 12, "Halo dunia")
+	p.Line(20, 32, 190, 32, 0.5)
+	p.Rect(20, 40, 10, 10, true)
+	doc.AddPage().Text(20, 30, Helvetica, 10, "Halaman 2")
+	out := doc.Bytes()
+
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatalf("header/trailer PDF tidak valid")
+	}
+	if !bytes.Contains(out, []byte("/Count 2")) || !bytes.Contains(out, []byte(`/Title (Uji \(kurung\))`)) {
+		t.Errorf("pohon halaman atau info tidak sesuai:\n%s", out)
+	}
+
+	// startxref menunjuk tabel xref, dan setiap entri menunjuk awal objeknya
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	if m == nil {
+		t.Fatal("startxref tidak ditemukan")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(out[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d tidak menunjuk tabel xref", xref)
+	}
+	lines := strings.Split(string(out[xref:]), "\n")
+	for i, entry := range lines[3:] {
+		if !strings.HasSuffix(entry, " n ") {
+			break
+		}
+		off, _ := strconv.Atoi(entry[:10])
+		want := fmt.Sprintf("%d 0 obj\n", i+1)
+		if !bytes.HasPrefix(out[off:], []byte(want)) {
+			t.Errorf("xref objek %d menunjuk offset %d, bukan %q", i+1, off, want)
+		}
+	}
+
+	if !bytes.Equal(out, doc.Bytes()) {
+		t.Errorf("Bytes() tidak deterministik")
+	}
+}
+
+func TestTextEncoding(t *testing.T) {
+	p := &Page{Width: A4Width, Height: A4Height}
+	p.Text(0, 0, Helvetica, 10, `a(b)\ é “c” 中`)
+	want := `(a\(b\)\\ \351 \223c\224 ?) Tj`
+	if got := p.content.String(); !strings.Contains(got, want) {
+		t.Errorf("Text() = %q, want memuat %q", got, want)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	// "MMMM" Helvetica 10pt = 4 * 833 * 10 / 1000 pt ~ 11.75 mm
+	if w := StringWidth(Helvetica, 10, "MMMM"); w < 11.7 || w > 11.8 {
+		t.Errorf("StringWidth(MMMM) = %.2f mm, want ~11.75", w)
+	}
+	got := Wrap(Helvetica, 10, "MMMM MMMM MMMM", 25)
+	if len(got) != 2 || got[0] != "MMMM MMMM" || got[1] != "MMMM" {
+		t.Errorf("Wrap() = %q", got)
+	}
+	if got := Wrap(Helvetica, 10, "  ", 25); len(got) != 1 || got[0] != "" {
+		t.Errorf("Wrap(kosong) = %q, want satu baris kosong", got)
+	}
+}
+
+func TestLayoutPageBreak(t *testing.T) {
+	doc := New()
+	l := NewLayout(doc)
+	for i := 0; i < 80; i++ {
+		l.Field("Baris", strconv.Itoa(i), 0, 30, 10)
+	}
+	if doc.Pages() < 2 {
+		t.Fatalf("Pages() = %d, want pindah halaman otomatis", doc.Pages())
+	}
+	if l.Y > l.Page.Height-l.Bottom {
+		t.Errorf("Y = %.1f melewati margin bawah", l.Y)
+	}
+
+	merged := New()
+	merged.Append(doc)
+	merged.Append(doc)
+	if merged.Pages() != 2*doc.Pages() {
+		t.Errorf("Append() = %d halaman, want %d", merged.Pages(), 2*doc.Pages())
+	}
+}
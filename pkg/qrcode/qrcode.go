@@ -0,0 +1,459 @@
+// Package qrcode membuat QR code (ISO/IEC 18004) mode byte dengan koreksi error level M,
+// versi 1 sampai 10 (maksimal 213 byte), cukup untuk URL verifikasi dokumen.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTooLong dikembalikan jika data melebihi kapasitas versi 10 level M
+var ErrTooLong = errors.New("data terlalu panjang untuk QR code")
+
+// QuietZone adalah lebar margin kosong (modul) di sekeliling QR code yang disarankan standar
+const QuietZone = 4
+
+// versionInfo adalah parameter satu versi pada level koreksi M
+type versionInfo struct {
+	ecPerBlock int
+	blocks     [][2]int // pasangan {jumlah blok, codeword data per blok}
+	align      []int    // posisi pusat alignment pattern
+}
+
+var versions = [...]versionInfo{
+	1:  {10, [][2]int{{1, 16}}, nil},
+	2:  {16, [][2]int{{1, 28}}, []int{6, 18}},
+	3:  {26, [][2]int{{1, 44}}, []int{6, 22}},
+	4:  {18, [][2]int{{2, 32}}, []int{6, 26}},
+	5:  {24, [][2]int{{2, 43}}, []int{6, 30}},
+	6:  {16, [][2]int{{4, 27}}, []int{6, 34}},
+	7:  {18, [][2]int{{4, 31}}, []int{6, 22, 38}},
+	8:  {22, [][2]int{{2, 38}, {2, 39}}, []int{6, 24, 42}},
+	9:  {22, [][2]int{{3, 36}, {2, 37}}, []int{6, 26, 46}},
+	10: {26, [][2]int{{4, 43}, {1, 44}}, []int{6, 28, 50}},
+}
+
+func (v versionInfo) dataCodewords() int {
+	n := 0
+	for _, b := range v.blocks {
+		n += b[0] * b[1]
+	}
+	return n
+}
+
+// Code adalah matriks modul QR code; true berarti modul gelap
+type Code struct {
+	Version int
+	Size    int
+	Mask    int
+	modules [][]bool
+	isFunc  [][]bool
+}
+
+// Dark mengembalikan true jika modul di kolom x, baris y gelap. Koordinat di luar matriks (quiet zone) terang.
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// Encode membuat QR code level M dengan versi terkecil yang memuat data
+func Encode(data string) (*Code, error) {
+	for v := 1; v < len(versions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= versions[v].dataCodewords()*8 {
+			return encode([]byte(data), v, countBits), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d byte", ErrTooLong, len(data))
+}
+
+func encode(data []byte, version, countBits int) *Code {
+	info := versions[version]
+	capacity := info.dataCodewords()
+
+	// Segmen mode byte: indikator mode 0100, jumlah karakter, data, terminator, lalu padding
+	var bb bitBuffer
+	bb.append(0x4, 4)
+	bb.append(len(data), countBits)
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	bb.append(0, min(4, capacity*8-len(bb)))
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacity*8; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	codewords := interleave(bb.bytes(), info)
+
+	size := version*4 + 17
+	c := &Code{Version: version, Size: size, modules: grid(size), isFunc: grid(size)}
+	c.drawFunctionPatterns(info)
+	c.drawCodewords(codewords)
+
+	// Pilih mask dengan penalti terkecil
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask) // XOR dua kali mengembalikan matriks
+	}
+	c.Mask = best
+	c.applyMask(best)
+	c.drawFormatBits(best)
+	return c
+}
+
+func grid(size int) [][]bool {
+	g := make([][]bool, size)
+	for i := range g {
+		g[i] = make([]bool, size)
+	}
+	return g
+}
+
+// interleave membagi data ke blok, menambah codeword Reed-Solomon, lalu menyelang-nyeling per kolom
+func interleave(data []byte, info versionInfo) []byte {
+	var dataBlocks, ecBlocks [][]byte
+	divisor := rsDivisor(info.ecPerBlock)
+	k := 0
+	for _, group := range info.blocks {
+		for i := 0; i < group[0]; i++ {
+			block := data[k : k+group[1]]
+			k += group[1]
+			dataBlocks = append(dataBlocks, block)
+			ecBlocks = append(ecBlocks, rsRemainder(block, divisor))
+		}
+	}
+
+	var out []byte
+	maxData := info.blocks[len(info.blocks)-1][1]
+	for i := 0; i < maxData; i++ {
+		for _, b := range dataBlocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < info.ecPerBlock; i++ {
+		for _, b := range ecBlocks {
+			out = append(out, b[i])
+		}
+	}
+	return out
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.isFunc[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns(info versionInfo) {
+	// Timing pattern
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	// Finder pattern beserta separator di tiga pojok
+	for _, pos := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := pos[0]+dx, pos[1]+dy
+				if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+					continue
+				}
+				d := max(abs(dx), abs(dy))
+				c.set(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+
+	// Alignment pattern, kecuali yang menimpa finder pattern
+	n := len(info.align)
+	for i, ay := range info.align {
+		for j, ax := range info.align {
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(ax+dx, ay+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Area format (diisi setelah mask dipilih) dan dark module
+	c.drawFormatBits(0)
+
+	// Informasi versi untuk versi 7 ke atas
+	if c.Version >= 7 {
+		rem := c.Version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := c.Version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 == 1
+			a, b := c.Size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+// formatBits mengembalikan 15 bit informasi format untuk level M dan mask tertentu
+func formatBits(mask int) int {
+	data := 0<<3 | mask // indikator level M = 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	// Salinan pertama di sekitar finder kiri atas
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	// Salinan kedua di finder kanan atas dan kiri bawah
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true) // dark module
+}
+
+// drawCodewords menempatkan bit data secara zig-zag dua kolom dari kanan bawah
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // lewati kolom timing pattern
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert // naik
+				}
+				if !c.isFunc[y][x] && i < len(data)*8 {
+					c.modules[y][x] = (data[i>>3]>>(7-i&7))&1 == 1
+					i++
+				}
+				// Sisa modul (remainder bits) tetap terang
+			}
+		}
+	}
+}
+
+func maskBit(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.isFunc[y][x] && maskBit(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty menghitung skor penalti mask (aturan N1-N4 standar)
+func (c *Code) penalty() int {
+	score := 0
+	finderLike := []bool{true, false, true, true, true, false, true}
+	line := make([]bool, c.Size)
+	for pass := 0; pass < 2; pass++ {
+		for a := 0; a < c.Size; a++ {
+			for b := 0; b < c.Size; b++ {
+				if pass == 0 {
+					line[b] = c.modules[a][b]
+				} else {
+					line[b] = c.modules[b][a]
+				}
+			}
+			// N1: lima atau lebih modul sewarna berturut-turut
+			run := 1
+			for b := 1; b <= c.Size; b++ {
+				if b < c.Size && line[b] == line[b-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += 3 + run - 5
+				}
+				run = 1
+			}
+			// N3: pola menyerupai finder dengan empat modul terang di salah satu sisi
+			for b := 0; b+7 <= c.Size; b++ {
+				match := true
+				for k, v := range finderLike {
+					if line[b+k] != v {
+						match = false
+						break
+					}
+				}
+				if match && (lightRun(line, b-4, b) || lightRun(line, b+7, b+11)) {
+					score += 40
+				}
+			}
+		}
+	}
+	// N2: blok 2x2 sewarna
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				v := c.modules[y][x]
+				if c.modules[y-1][x] == v && c.modules[y][x-1] == v && c.modules[y-1][x-1] == v {
+					score += 3
+				}
+			}
+		}
+	}
+	// N4: proporsi modul gelap menjauhi 50%
+	total := c.Size * c.Size
+	score += 10 * (abs(dark*100/total-50) / 5)
+	return score
+}
+
+// lightRun bernilai true jika modul [from, to) terang; di luar matriks dianggap terang
+func lightRun(line []bool, from, to int) bool {
+	for i := from; i < to; i++ {
+		if i >= 0 && i < len(line) && line[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SVG mengembalikan QR code sebagai elemen <svg> (satu path) termasuk quiet zone, untuk halaman HTML
+func (c *Code) SVG() string {
+	n := c.Size + 2*QuietZone
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, n, n)
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&sb, "M%d %dh1v1h-1z", x+QuietZone, y+QuietZone)
+			}
+		}
+	}
+	sb.WriteString(`"/></svg>`)
+	return sb.String()
+}
+
+// bitBuffer menampung bit data sebelum dipecah menjadi codeword
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, (v>>i)&1 == 1)
+	}
+}
+
+func (bb bitBuffer) bytes() []byte {
+	out := make([]byte, len(bb)/8)
+	for i, bit := range bb {
+		if bit {
+			out[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	return out
+}
+
+// rsDivisor mengembalikan koefisien polinom generator Reed-Solomon berderajat degree (tanpa koefisien utama)
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder menghitung codeword koreksi error untuk data
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMul(coef, factor)
+		}
+	}
+	return result
+}
+
+// gfMul mengalikan dua elemen GF(2^8) dengan polinom primitif 0x11D
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
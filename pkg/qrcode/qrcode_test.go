@@ -0,0 +1,199 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" versi 1-M (contoh thonky.com QR code tutorial)
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	// Tabel informasi format level M (ISO/IEC 18004 lampiran C)
+	want := map[int]string{0: "101010000010010", 4: "100010111111001", 7: "100101010100000"}
+	for mask, bits := range want {
+		if got := strconv.FormatInt(int64(formatBits(mask)), 2); got != bits {
+			t.Errorf("formatBits(%d) = %s, want %s", mask, got, bits)
+		}
+	}
+
+	c, err := Encode(strings.Repeat("a", 120)) // versi 7
+	if err != nil || c.Version != 7 {
+		t.Fatalf("Encode() versi = %v, err = %v; want 7", c.Version, err)
+	}
+	// Informasi versi 7 = 000111110010010100, bit 0 di pojok kiri atas blok kanan atas
+	const v7 = 0x07C94
+	for i := 0; i < 18; i++ {
+		want := (v7>>i)&1 == 1
+		if c.Dark(c.Size-11+i%3, i/3) != want || c.Dark(i/3, c.Size-11+i%3) != want {
+			t.Errorf("bit informasi versi %d tidak sesuai", i)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	inputs := []string{
+		"",
+		"HELLO WORLD",
+		"https://simnikah.example.go.id/simnikah/undangan-bimbingan/verifikasi/eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.eyJzdWIiOiIxMiJ9.abc",
+		strings.Repeat("x", 213),
+	}
+	for _, in := range inputs {
+		c, err := Encode(in)
+		if err != nil {
+			t.Fatalf("Encode(%d byte) error = %v", len(in), err)
+		}
+		if c.Size != c.Version*4+17 {
+			t.Errorf("Size = %d untuk versi %d", c.Size, c.Version)
+		}
+		got, err := decode(c)
+		if err != nil {
+			t.Errorf("decode(versi %d) error = %v", c.Version, err)
+			continue
+		}
+		if got != in {
+			t.Errorf("decode() = %q, want %q", got, in)
+		}
+	}
+
+	if _, err := Encode(strings.Repeat("x", 214)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Encode(214 byte) error = %v, want ErrTooLong", err)
+	}
+}
+
+func TestFinderPattern(t *testing.T) {
+	c, _ := Encode("SimNikah")
+	rows := []string{"#######.", "#.....#.", "#.###.#.", "#.###.#.", "#.###.#.", "#.....#.", "#######.", "........"}
+	for _, origin := range [][2]int{{0, 0}, {c.Size - 7, 0}, {0, c.Size - 7}} {
+		for y, row := range rows[:7] {
+			for x, ch := range row[:7] {
+				if c.Dark(origin[0]+x, origin[1]+y) != (ch == '#') {
+					t.Fatalf("finder pattern di %v salah pada (%d,%d)", origin, x, y)
+				}
+			}
+		}
+	}
+	if !c.Dark(8, c.Size-8) {
+		t.Errorf("dark module tidak ada")
+	}
+	if svg := c.SVG(); !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, "M4 4h1v1h-1z") {
+		t.Errorf("SVG() tidak memuat modul pojok kiri atas: %.80s", svg)
+	}
+}
+
+// decode membaca ulang matriks: informasi format, unmask, urutan zig-zag, de-interleave,
+// lalu memeriksa Reed-Solomon setiap blok dan mengurai segmen mode byte
+func decode(c *Code) (string, error) {
+	format := 0
+	for i := 0; i <= 5; i++ {
+		format |= b2i(c.Dark(8, i)) << i
+	}
+	format |= b2i(c.Dark(8, 7))<<6 | b2i(c.Dark(8, 8))<<7 | b2i(c.Dark(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		format |= b2i(c.Dark(14-i, 8)) << i
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+	if mask < 0 || mask != c.Mask {
+		return "", errors.New("informasi format tidak dikenali")
+	}
+
+	info := versions[c.Version]
+	total := info.dataCodewords()
+	for _, g := range info.blocks {
+		total += g[0] * info.ecPerBlock
+	}
+	var bits bitBuffer
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.isFunc[y][x] && len(bits) < total*8 {
+					bits = append(bits, c.Dark(x, y) != maskBit(mask, x, y))
+				}
+			}
+		}
+	}
+	raw := bits.bytes()
+
+	// De-interleave ke blok
+	var sizes []int
+	for _, g := range info.blocks {
+		for i := 0; i < g[0]; i++ {
+			sizes = append(sizes, g[1])
+		}
+	}
+	blocks := make([][]byte, len(sizes))
+	k := 0
+	for i := 0; i < sizes[len(sizes)-1]; i++ {
+		for b, n := range sizes {
+			if i < n {
+				blocks[b] = append(blocks[b], raw[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	divisor := rsDivisor(info.ecPerBlock)
+	for b := range blocks {
+		ec := make([]byte, info.ecPerBlock)
+		for i := range ec {
+			ec[i] = raw[k+i*len(blocks)+b]
+		}
+		if !bytes.Equal(rsRemainder(blocks[b], divisor), ec) {
+			return "", errors.New("codeword koreksi error blok tidak cocok")
+		}
+		data = append(data, blocks[b]...)
+	}
+
+	var stream bitBuffer
+	for _, b := range data {
+		stream.append(int(b), 8)
+	}
+	read := func(n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | b2i(stream[i])
+		}
+		stream = stream[n:]
+		return v
+	}
+	if read(4) != 0x4 {
+		return "", errors.New("mode bukan byte")
+	}
+	countBits := 8
+	if c.Version >= 10 {
+		countBits = 16
+	}
+	out := make([]byte, read(countBits))
+	for i := range out {
+		out[i] = byte(read(8))
+	}
+	return string(out), nil
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
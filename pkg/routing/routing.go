@@ -0,0 +1,116 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"simnikah/pkg/utils"
+)
+
+// Point adalah koordinat lokasi (derajat desimal)
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Route adalah perkiraan perjalanan antara dua titik
+type Route struct {
+	JarakKm     float64 `json:"jarak_km"`
+	DurasiMenit int     `json:"durasi_menit"`
+	Sumber      string  `json:"sumber"` // "lokal" atau nama provider rute
+}
+
+// Provider adalah antarmuka perkiraan rute (OSRM di production, Local untuk development dan test)
+type Provider interface {
+	Route(from, to Point) (Route, error)
+}
+
+// NewFromEnv membuat Provider dari environment variable ROUTING_*.
+// Jika ROUTING_OSRM_URL tidak diset, rute diperkirakan lokal dari jarak garis lurus dengan kecepatan rata-rata.
+func NewFromEnv(kecepatanKmJam float64) Provider {
+	baseURL := os.Getenv("ROUTING_OSRM_URL")
+	if baseURL == "" {
+		return Local{KecepatanKmJam: kecepatanKmJam}
+	}
+
+	profile := os.Getenv("ROUTING_OSRM_PROFILE")
+	if profile == "" {
+		profile = "driving"
+	}
+	return &OSRM{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Profile: profile,
+		Client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// ==================== LOCAL ====================
+
+// DefaultKecepatanKmJam adalah kecepatan rata-rata perjalanan penghulu jika tidak diatur
+const DefaultKecepatanKmJam = 30.0
+
+// Local memperkirakan rute dari jarak haversine dan kecepatan rata-rata, tanpa layanan eksternal
+type Local struct {
+	KecepatanKmJam float64
+}
+
+// Route menghitung jarak garis lurus dan durasi (menit, dibulatkan ke atas)
+func (l Local) Route(from, to Point) (Route, error) {
+	jarak := math.Round(utils.HaversineKm(from.Lat, from.Lon, to.Lat, to.Lon)*10) / 10
+	return Route{JarakKm: jarak, DurasiMenit: l.Minutes(jarak), Sumber: "lokal"}, nil
+}
+
+// Minutes memperkirakan waktu tempuh (menit, dibulatkan ke atas) untuk jarak tertentu
+func (l Local) Minutes(jarakKm float64) int {
+	kecepatan := l.KecepatanKmJam
+	if kecepatan <= 0 {
+		kecepatan = DefaultKecepatanKmJam
+	}
+	return int(math.Ceil(jarakKm / kecepatan * 60))
+}
+
+// ==================== OSRM ====================
+
+// OSRM memakai layanan rute OSRM (http://project-osrm.org) untuk jarak dan durasi jalan sebenarnya
+type OSRM struct {
+	BaseURL string // mis. https://router.project-osrm.org
+	Profile string // driving, car, bike, foot (tergantung server)
+	Client  *http.Client
+}
+
+// Route meminta rute tercepat dari server OSRM
+func (o *OSRM) Route(from, to Point) (Route, error) {
+	url := fmt.Sprintf("%s/route/v1/%s/%f,%f;%f,%f?overview=false",
+		o.BaseURL, o.Profile, from.Lon, from.Lat, to.Lon, to.Lat)
+
+	resp, err := o.Client.Get(url)
+	if err != nil {
+		return Route{}, fmt.Errorf("gagal menghubungi server rute: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Code   string `json:"code"`
+		Routes []struct {
+			Distance float64 `json:"distance"` // meter
+			Duration float64 `json:"duration"` // detik
+		} `json:"routes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Route{}, fmt.Errorf("respons server rute tidak valid: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Code != "Ok" || len(body.Routes) == 0 {
+		return Route{}, fmt.Errorf("rute tidak ditemukan (status %d, %s)", resp.StatusCode, body.Code)
+	}
+
+	return Route{
+		JarakKm:     math.Round(body.Routes[0].Distance/100) / 10,
+		DurasiMenit: int(math.Ceil(body.Routes[0].Duration / 60)),
+		Sumber:      "osrm",
+	}, nil
+}
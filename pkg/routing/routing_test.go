@@ -0,0 +1,66 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocalRoute(t *testing.T) {
+	l := Local{KecepatanKmJam: 30}
+	for km, want := range map[float64]int{0: 0, 10: 20, 15: 30, 15.1: 31} {
+		if got := l.Minutes(km); got != want {
+			t.Errorf("Minutes(%v) = %d, want %d", km, got, want)
+		}
+	}
+	if got := (Local{}).Minutes(15); got != 30 {
+		t.Errorf("Expected default speed 30 km/h when unset, got %d minutes for 15 km", got)
+	}
+
+	// Sekitar 11 km ke utara (0,1 derajat lintang)
+	route, err := l.Route(Point{Lat: -7.0, Lon: 110.4}, Point{Lat: -6.9, Lon: 110.4})
+	if err != nil {
+		t.Fatalf("Route() error = %v", err)
+	}
+	if route.JarakKm != 11.1 || route.DurasiMenit != 23 || route.Sumber != "lokal" {
+		t.Errorf("Route() = %+v, want 11.1 km, 23 menit, lokal", route)
+	}
+}
+
+func TestOSRMRoute(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/route/v1/driving/110.400000,-7.000000;110.400000,-6.900000") {
+			http.Error(w, `{"code":"InvalidUrl"}`, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"code":"Ok","routes":[{"distance":14250.5,"duration":1530.2}]}`))
+	}))
+	defer server.Close()
+
+	o := &OSRM{BaseURL: server.URL, Profile: "driving", Client: server.Client()}
+	route, err := o.Route(Point{Lat: -7.0, Lon: 110.4}, Point{Lat: -6.9, Lon: 110.4})
+	if err != nil {
+		t.Fatalf("Route() error = %v", err)
+	}
+	if route.JarakKm != 14.3 || route.DurasiMenit != 26 || route.Sumber != "osrm" {
+		t.Errorf("Route() = %+v, want 14.3 km, 26 menit, osrm", route)
+	}
+
+	if _, err := o.Route(Point{Lat: 1, Lon: 1}, Point{Lat: 2, Lon: 2}); err == nil {
+		t.Error("Expected error for route rejected by server")
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("ROUTING_OSRM_URL", "")
+	if _, ok := NewFromEnv(40).(Local); !ok {
+		t.Error("Expected Local provider when ROUTING_OSRM_URL is not set")
+	}
+
+	t.Setenv("ROUTING_OSRM_URL", "https://osrm.example.com/")
+	o, ok := NewFromEnv(40).(*OSRM)
+	if !ok || o.BaseURL != "https://osrm.example.com" || o.Profile != "driving" {
+		t.Errorf("Expected OSRM provider from environment, got %+v", o)
+	}
+}
@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ==================== S3 ====================
+
+// unsignedPayload dipakai agar isi file tidak perlu dibaca dua kali untuk menghitung hash sebelum upload
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// emptyPayloadHash adalah SHA-256 dari body kosong (GET dan DELETE)
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3 menyimpan file di object storage yang kompatibel dengan S3 memakai path-style URL
+// (Endpoint/Bucket/key) dan tanda tangan AWS Signature Version 4
+type S3 struct {
+	Endpoint  string // mis. https://s3.ap-southeast-3.amazonaws.com atau http://localhost:9000 (MinIO)
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+
+	// Now dipakai untuk waktu tanda tangan; nil berarti time.Now (diganti di test)
+	Now func() time.Time
+}
+
+// Put mengunggah object; size wajib diketahui karena S3 tidak menerima upload chunked tanpa panjang
+func (s *S3) Put(key string, r io.Reader, size int64, contentType string) error {
+	if size < 0 {
+		return fmt.Errorf("ukuran file wajib diketahui untuk upload S3")
+	}
+	req, err := s.newRequest(http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// Get mengunduh object; pemanggil wajib menutup body
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp.Body, nil
+}
+
+// Delete menghapus object; object yang sudah tidak ada tidak dianggap error
+func (s *S3) Delete(key string) error {
+	req, err := s.newRequest(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+	return responseError(resp)
+}
+
+func (s *S3) newRequest(method, key string, body io.Reader) (*http.Request, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequest(method, s.Endpoint+"/"+uriEncode(s.Bucket, false)+"/"+uriEncode(cleaned, false), body)
+}
+
+func (s *S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	Sign(req, payloadHash, s.AccessKey, s.SecretKey, s.Region, now())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gagal menghubungi storage S3: %v", err)
+	}
+	return resp, nil
+}
+
+// responseError membaca pesan error S3 (XML) secara singkat
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("storage S3 menolak permintaan (status %d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// Sign menandatangani request dengan AWS Signature Version 4 untuk layanan s3.
+// Header yang sudah diset di request (beserta host, x-amz-date, x-amz-content-sha256) ikut ditandatangani.
+func Sign(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode meng-encode sesuai aturan SigV4: hanya A-Z a-z 0-9 - _ . ~ yang tidak di-encode,
+// "/" di-encode hanya jika encodeSlash (nilai query string)
+func uriEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			sb.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			sb.WriteByte(ch)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", ch)
+		}
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,143 @@
+// Package storage menyimpan file unggahan (berkas persyaratan nikah) di filesystem lokal
+// atau object storage yang kompatibel dengan S3 (AWS S3, MinIO, Cloudflare R2, dll).
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound dikembalikan jika object dengan key tersebut tidak ada
+	ErrNotFound = errors.New("file tidak ditemukan di storage")
+	// ErrInvalidKey dikembalikan jika key kosong, absolut, atau keluar dari root storage ("..")
+	ErrInvalidKey = errors.New("key storage tidak valid")
+)
+
+// Storage adalah antarmuka penyimpanan file (Local untuk development, S3 di production).
+// Key selalu memakai pemisah "/" (mis. "pendaftaran/12/ktp-1a2b.pdf").
+type Storage interface {
+	Put(key string, r io.Reader, size int64, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// NewFromEnv membuat Storage dari environment variable STORAGE_* dan S3_*.
+// STORAGE_DRIVER=s3 memakai object storage S3; selain itu file disimpan di STORAGE_LOCAL_DIR (default "uploads").
+func NewFromEnv() Storage {
+	if strings.EqualFold(os.Getenv("STORAGE_DRIVER"), "s3") {
+		region := os.Getenv("S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		return &S3{
+			Endpoint:  strings.TrimRight(os.Getenv("S3_ENDPOINT"), "/"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    region,
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Client:    &http.Client{Timeout: 60 * time.Second},
+		}
+	}
+
+	dir := os.Getenv("STORAGE_LOCAL_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		log.Printf("Warning: Gagal membuat folder storage %s: %v", dir, err)
+	}
+	return &Local{Dir: dir}
+}
+
+// cleanKey memvalidasi key dan mengembalikan bentuk bersihnya
+func cleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return "", ErrInvalidKey
+	}
+	cleaned := path.Clean(key)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
+
+// ==================== LOCAL ====================
+
+// Local menyimpan file di folder pada filesystem server
+type Local struct {
+	Dir string
+}
+
+// Put menulis file ke file sementara lalu me-rename agar file tidak pernah terbaca setengah jadi
+func (l *Local) Put(key string, r io.Reader, size int64, contentType string) error {
+	target, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+		return fmt.Errorf("gagal membuat folder storage: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("gagal membuat file sementara: %v", err)
+	}
+	written, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("ukuran file %d byte, seharusnya %d byte", written, size)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("gagal menulis file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("gagal menyimpan file: %v", err)
+	}
+	return nil
+}
+
+// Get membuka file untuk dibaca; pemanggil wajib menutupnya
+func (l *Local) Get(key string) (io.ReadCloser, error) {
+	target, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete menghapus file; file yang sudah tidak ada tidak dianggap error
+func (l *Local) Delete(key string) error {
+	target, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Dir, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,76 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Secret dari RFC 6238 Appendix B (SHA1): "12345678901234567890"
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeAtRFC6238(t *testing.T) {
+	// Vektor uji RFC 6238 (8 digit) dipotong ke 6 digit terakhir
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tc := range cases {
+		code, err := CodeAt(rfcSecret, Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("CodeAt(%d) error: %v", tc.unix, err)
+		}
+		if code != tc.code {
+			t.Errorf("CodeAt(%d) = %s, expected %s", tc.unix, code, tc.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := CodeAt(rfcSecret, Step(now))
+
+	step, ok := Validate(rfcSecret, code, now, 1)
+	if !ok || step != Step(now) {
+		t.Errorf("Expected current code to be valid at step %d, got %d/%v", Step(now), step, ok)
+	}
+
+	// Kode dari langkah sebelumnya masih diterima dengan skew 1
+	if _, ok := Validate(rfcSecret, code, now.Add(Period), 1); !ok {
+		t.Error("Expected previous-step code to be valid with skew 1")
+	}
+
+	if _, ok := Validate(rfcSecret, code, now.Add(3*Period), 1); ok {
+		t.Error("Expected old code to be rejected")
+	}
+
+	if _, ok := Validate(rfcSecret, "12345", now, 1); ok {
+		t.Error("Expected short code to be rejected")
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret error: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("Expected 32-char base32 secret, got %d", len(secret))
+	}
+
+	uri := URI("SimNikah KUA", "budi.staff", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/SimNikah%20KUA:budi.staff?") {
+		t.Errorf("Unexpected URI prefix: %s", uri)
+	}
+	if !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("Expected secret in URI: %s", uri)
+	}
+}
@@ -4,6 +4,12 @@ import "time"
 
 // CalculateWorkingDays calculates working days between two dates (excluding weekends)
 func CalculateWorkingDays(startDate, endDate time.Time) int {
+	return CalculateWorkingDaysExcluding(startDate, endDate, nil)
+}
+
+// CalculateWorkingDaysExcluding calculates working days between two dates, excluding weekends
+// and every day for which isHoliday returns true (e.g. national holidays and cuti bersama)
+func CalculateWorkingDaysExcluding(startDate, endDate time.Time, isHoliday func(time.Time) bool) int {
 	if endDate.Before(startDate) {
 		return 0
 	}
@@ -14,7 +20,7 @@ func CalculateWorkingDays(startDate, endDate time.Time) int {
 	for current.Before(endDate) || current.Equal(endDate) {
 		// Check if current day is not Saturday (6) or Sunday (0)
 		weekday := current.Weekday()
-		if weekday != time.Saturday && weekday != time.Sunday {
+		if weekday != time.Saturday && weekday != time.Sunday && (isHoliday == nil || !isHoliday(current)) {
 			workingDays++
 		}
 		current = current.AddDate(0, 0, 1)